@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"errors"
+	"livechat/backend/middleware"
+	"livechat/backend/model"
+	"livechat/backend/repository"
+	"livechat/backend/service"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PresenceService 定義了在線人數快照服務的接口
+type PresenceService interface {
+	GetPresenceHistory(roomID string, limit int) ([]model.PresenceSnapshot, error)
+	GetOnlineCount() service.OnlineCount
+	GetConnections() []*model.Client
+}
+
+// ClientManager 定義管理 WebSocket 客戶端的接口，由 service.BroadcastService 實作
+type ClientManager interface {
+	GetAllClients() []*model.Client
+	DisconnectClient(clientID string) error
+}
+
+// AdminHandler 處理管理員相關的 HTTP 請求
+type AdminHandler struct {
+	userService     service.UserService
+	presenceService PresenceService
+	clientManager   ClientManager // 列出與中斷 WebSocket 客戶端，nil 時不註冊 /api/admin/clients
+}
+
+// AdminHandlerOption 定義管理員處理器選項
+type AdminHandlerOption func(*AdminHandler)
+
+// WithClientManager 設置管理 WebSocket 客戶端的服務，啟用 /api/admin/clients 端點
+func WithClientManager(clientManager ClientManager) AdminHandlerOption {
+	return func(h *AdminHandler) {
+		h.clientManager = clientManager
+	}
+}
+
+// PresenceSnapshotResponse 是在線人數快照的 API 響應格式
+type PresenceSnapshotResponse struct {
+	RoomID            string `json:"roomId"`
+	ActiveConnections int    `json:"activeConnections"`
+	CapturedAt        int64  `json:"capturedAt"`
+}
+
+// ConnectionResponse 是活躍 WebSocket 連接的 API 響應格式
+type ConnectionResponse struct {
+	ID          string `json:"id"`
+	UserID      string `json:"userId,omitempty"`
+	UserName    string `json:"userName"`
+	RoomID      string `json:"roomId,omitempty"`
+	Compressed  bool   `json:"compressed"`
+	ConnectedAt int64  `json:"connectedAt"`
+}
+
+// ClientResponse 是 WebSocket 客戶端的 API 響應格式
+type ClientResponse struct {
+	ID         string `json:"id"`
+	UserID     string `json:"userId,omitempty"`
+	UserName   string `json:"userName"`
+	RoomID     string `json:"roomId,omitempty"`
+	JoinedAt   int64  `json:"joinedAt"`
+	LastActive int64  `json:"lastActive"`
+}
+
+// NewAdminHandler 創建一個新的管理員處理器
+func NewAdminHandler(userService service.UserService, presenceService PresenceService, opts ...AdminHandlerOption) *AdminHandler {
+	h := &AdminHandler{
+		userService:     userService,
+		presenceService: presenceService,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// RegisterRoutes 註冊管理員相關的路由
+func (h *AdminHandler) RegisterRoutes(router *gin.Engine) {
+	admin := router.Group("/api/admin", middleware.AdminRequired(h.userService))
+	{
+		admin.GET("/rooms/:id/presence-history", h.GetPresenceHistory)
+		admin.GET("/connections", h.GetConnections)
+		if h.clientManager != nil {
+			admin.GET("/clients", h.GetClients)
+			admin.DELETE("/clients/:id", h.DisconnectClient)
+		}
+	}
+}
+
+// GetPresenceHistory 獲取聊天室的在線人數快照歷史
+func (h *AdminHandler) GetPresenceHistory(c *gin.Context) {
+	// 獲取聊天室 ID
+	roomID := c.Param("id")
+
+	// 獲取快照數量限制
+	page := parsePagination(c)
+
+	// 獲取快照
+	snapshots, err := h.presenceService.GetPresenceHistory(roomID, page.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取在線人數歷史失敗"})
+		return
+	}
+
+	// 構建響應
+	response := make([]PresenceSnapshotResponse, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		response = append(response, PresenceSnapshotResponse{
+			RoomID:            snapshot.RoomID,
+			ActiveConnections: snapshot.ActiveConnections,
+			CapturedAt:        snapshot.CapturedAt.Unix(),
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetConnections 獲取所有活躍的 WebSocket 連接，包含是否協商啟用了壓縮，用於排查頻寬問題
+func (h *AdminHandler) GetConnections(c *gin.Context) {
+	clients := h.presenceService.GetConnections()
+
+	response := make([]ConnectionResponse, 0, len(clients))
+	for _, client := range clients {
+		response = append(response, ConnectionResponse{
+			ID:          client.ID,
+			UserID:      client.UserID,
+			UserName:    client.UserName,
+			RoomID:      client.RoomID,
+			Compressed:  client.Compressed,
+			ConnectedAt: client.JoinedAtTime().Unix(),
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetClients 獲取目前所有的 WebSocket 客戶端，依加入時間由舊到新排序
+func (h *AdminHandler) GetClients(c *gin.Context) {
+	clients := h.clientManager.GetAllClients()
+	sort.Slice(clients, func(i, j int) bool {
+		if !clients[i].JoinedAtTime().Equal(clients[j].JoinedAtTime()) {
+			return clients[i].JoinedAtTime().Before(clients[j].JoinedAtTime())
+		}
+		return clients[i].ID < clients[j].ID
+	})
+
+	response := make([]ClientResponse, 0, len(clients))
+	for _, client := range clients {
+		response = append(response, ClientResponse{
+			ID:         client.ID,
+			UserID:     client.UserID,
+			UserName:   client.UserName,
+			RoomID:     client.RoomID,
+			JoinedAt:   client.JoinedAtTime().Unix(),
+			LastActive: client.LastActiveTime().Unix(),
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DisconnectClient 強制中斷指定的 WebSocket 客戶端
+func (h *AdminHandler) DisconnectClient(c *gin.Context) {
+	if err := h.clientManager.DisconnectClient(c.Param("id")); err != nil {
+		if errors.Is(err, repository.ErrClientNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "中斷客戶端失敗"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "客戶端已中斷"})
+}
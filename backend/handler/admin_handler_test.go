@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"encoding/json"
+	"livechat/backend/middleware"
+	"livechat/backend/model"
+	"livechat/backend/repository"
+	"livechat/backend/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPresenceService 是一個模擬的在線人數快照服務
+type MockPresenceService struct {
+	mock.Mock
+}
+
+func (m *MockPresenceService) GetPresenceHistory(roomID string, limit int) ([]model.PresenceSnapshot, error) {
+	args := m.Called(roomID, limit)
+	return args.Get(0).([]model.PresenceSnapshot), args.Error(1)
+}
+
+func (m *MockPresenceService) GetOnlineCount() service.OnlineCount {
+	args := m.Called()
+	return args.Get(0).(service.OnlineCount)
+}
+
+func (m *MockPresenceService) GetConnections() []*model.Client {
+	args := m.Called()
+	return args.Get(0).([]*model.Client)
+}
+
+// 設置帶有登入用戶的 Gin 測試環境
+func setupAdminRouter(user *middleware.UserResponse) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if user != nil {
+			c.Set("user", user)
+		}
+		c.Next()
+	})
+	return router
+}
+
+// 測試管理員獲取在線人數歷史
+func TestGetPresenceHistory(t *testing.T) {
+	// 安排 (Arrange)
+	mockUserService := new(MockUserService)
+	mockPresenceService := new(MockPresenceService)
+	handler := NewAdminHandler(mockUserService, mockPresenceService)
+	router := setupAdminRouter(&middleware.UserResponse{ID: "admin-1", Role: "admin"})
+	handler.RegisterRoutes(router)
+
+	admin := &model.User{ID: "admin-1", Role: "admin"}
+	capturedAt := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	snapshots := []model.PresenceSnapshot{
+		{RoomID: "room-1", ActiveConnections: 4, CapturedAt: capturedAt},
+	}
+
+	mockUserService.On("GetUserByID", "admin-1").Return(admin, nil)
+	mockUserService.On("IsAdmin", admin).Return(true)
+	mockPresenceService.On("GetPresenceHistory", "room-1", 50).Return(snapshots, nil)
+
+	req, _ := http.NewRequest("GET", "/api/admin/rooms/room-1/presence-history", nil)
+	w := httptest.NewRecorder()
+
+	// 動作 (Act)
+	router.ServeHTTP(w, req)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+
+	var response []PresenceSnapshotResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err, "應該能夠解析響應")
+	assert.Equal(t, 1, len(response), "應該有 1 筆快照")
+	assert.Equal(t, 4, response[0].ActiveConnections, "連線數應該匹配")
+	assert.Equal(t, capturedAt.Unix(), response[0].CapturedAt, "快照時間應該匹配")
+
+	mockUserService.AssertExpectations(t)
+	mockPresenceService.AssertExpectations(t)
+}
+
+// 測試非管理員無法獲取在線人數歷史
+func TestGetPresenceHistoryForbidden(t *testing.T) {
+	// 安排 (Arrange)
+	mockUserService := new(MockUserService)
+	mockPresenceService := new(MockPresenceService)
+	handler := NewAdminHandler(mockUserService, mockPresenceService)
+	router := setupAdminRouter(&middleware.UserResponse{ID: "user-1", Role: "user"})
+	handler.RegisterRoutes(router)
+
+	user := &model.User{ID: "user-1", Role: "user"}
+	mockUserService.On("GetUserByID", "user-1").Return(user, nil)
+	mockUserService.On("IsAdmin", user).Return(false)
+
+	req, _ := http.NewRequest("GET", "/api/admin/rooms/room-1/presence-history", nil)
+	w := httptest.NewRecorder()
+
+	// 動作 (Act)
+	router.ServeHTTP(w, req)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusForbidden, w.Code, "狀態碼應該是 403")
+	mockPresenceService.AssertNotCalled(t, "GetPresenceHistory", mock.Anything, mock.Anything)
+}
+
+// 測試管理員列出與強制中斷 WebSocket 客戶端
+//
+// 測試目標：
+// 1. GET /api/admin/clients 返回所有客戶端的 ID、用戶名、聊天室與時間戳
+// 2. DELETE /api/admin/clients/:id 關閉該客戶端的連接並將其移除
+// 3. 中斷不存在的客戶端返回 404，非管理員不能存取
+func TestAdminClients(t *testing.T) {
+	// 安排 (Arrange)：真實的廣播服務，並建立兩個 WebSocket 連接
+	broadcastService := service.NewBroadcastService(repository.NewClientRepository())
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	server := httptest.NewServer(http.HandlerFunc(NewWebSocketHandler(broadcastService, WithLogger(mockLogger)).HandleConnection))
+	defer server.Close()
+
+	connect := func(userName string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?username="+userName, nil)
+		assert.NoError(t, err, "應該能夠建立連接")
+		return conn
+	}
+	alice := connect("Alice")
+	defer alice.Close()
+	bob := connect("Bob")
+	defer bob.Close()
+	assert.Eventually(t, func() bool {
+		return len(broadcastService.GetAllClients()) == 2
+	}, time.Second, 10*time.Millisecond, "所有連接都應該被記錄")
+
+	mockUserService := new(MockUserService)
+	admin := &model.User{ID: "admin-1", Role: "admin"}
+	mockUserService.On("GetUserByID", "admin-1").Return(admin, nil)
+	mockUserService.On("IsAdmin", admin).Return(true)
+	router := setupAdminRouter(&middleware.UserResponse{ID: "admin-1", Role: "admin"})
+	NewAdminHandler(mockUserService, new(MockPresenceService), WithClientManager(broadcastService)).RegisterRoutes(router)
+	request := func(method string, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	listClients := func() map[string]ClientResponse {
+		w := request("GET", "/api/admin/clients")
+		assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+		var response []ClientResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+		byName := make(map[string]ClientResponse)
+		for _, client := range response {
+			byName[client.UserName] = client
+		}
+		return byName
+	}
+
+	// 動作 & 斷言：列出客戶端
+	clients := listClients()
+	if !assert.Len(t, clients, 2, "應該列出所有客戶端") {
+		return
+	}
+	assert.NotEmpty(t, clients["Alice"].ID, "應該包含客戶端 ID")
+	assert.NotZero(t, clients["Alice"].JoinedAt, "應該包含加入時間")
+	assert.NotZero(t, clients["Alice"].LastActive, "應該包含最後活躍時間")
+
+	// 動作 & 斷言：強制中斷 Alice
+	w := request("DELETE", "/api/admin/clients/"+clients["Alice"].ID)
+	assert.Equal(t, http.StatusOK, w.Code, "中斷客戶端應該返回 200")
+
+	alice.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := alice.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), "被中斷的客戶端應該收到關閉幀，實際錯誤：%v", err)
+	remaining := listClients()
+	assert.Len(t, remaining, 1, "被中斷的客戶端應該被移除")
+	assert.Contains(t, remaining, "Bob", "其他客戶端不受影響")
+
+	// 動作 & 斷言：中斷不存在的客戶端
+	w = request("DELETE", "/api/admin/clients/"+clients["Alice"].ID)
+	assert.Equal(t, http.StatusNotFound, w.Code, "不存在的客戶端應該返回 404")
+
+	// 動作 & 斷言：非管理員不能存取
+	user := &model.User{ID: "user-1", Role: "user"}
+	mockUserService.On("GetUserByID", "user-1").Return(user, nil)
+	mockUserService.On("IsAdmin", user).Return(false)
+	userRouter := setupAdminRouter(&middleware.UserResponse{ID: "user-1", Role: "user"})
+	NewAdminHandler(mockUserService, new(MockPresenceService), WithClientManager(broadcastService)).RegisterRoutes(userRouter)
+	req, _ := http.NewRequest("GET", "/api/admin/clients", nil)
+	forbidden := httptest.NewRecorder()
+	userRouter.ServeHTTP(forbidden, req)
+	assert.Equal(t, http.StatusForbidden, forbidden.Code, "非管理員應該返回 403")
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"errors"
+	"livechat/backend/middleware"
+	"livechat/backend/model"
+	"livechat/backend/repository"
+	"livechat/backend/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyService 定義了 API 金鑰服務的接口
+type APIKeyService interface {
+	CreateKey(userID string, name string, role string) (string, *model.APIKey, error)
+	RevokeKey(id uint) error
+}
+
+// APIKeyHandler 處理 API 金鑰相關的 HTTP 請求，只有管理員可以簽發與撤銷金鑰
+type APIKeyHandler struct {
+	keyService  APIKeyService
+	userService service.UserService
+}
+
+// CreateAPIKeyRequest 是簽發 API 金鑰的請求格式
+type CreateAPIKeyRequest struct {
+	UserID string `json:"userId" binding:"required"` // 金鑰綁定的用戶
+	Name   string `json:"name"`                      // 用於辨識金鑰用途的名稱
+	Role   string `json:"role"`                      // 金鑰的角色，未提供時沿用用戶的角色
+}
+
+// APIKeyResponse 是 API 金鑰的響應格式，Key 只在簽發時返回這一次
+type APIKeyResponse struct {
+	ID        uint   `json:"id"`
+	Key       string `json:"key"`
+	Name      string `json:"name"`
+	UserID    string `json:"userId"`
+	Role      string `json:"role"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// NewAPIKeyHandler 創建一個新的 API 金鑰處理器
+func NewAPIKeyHandler(keyService APIKeyService, userService service.UserService) *APIKeyHandler {
+	return &APIKeyHandler{
+		keyService:  keyService,
+		userService: userService,
+	}
+}
+
+// RegisterRoutes 註冊 API 金鑰相關的路由
+func (h *APIKeyHandler) RegisterRoutes(router *gin.Engine) {
+	keys := router.Group("/api/keys", middleware.AdminRequired(h.userService))
+	{
+		keys.POST("", h.CreateKey)
+		keys.DELETE("/:id", h.RevokeKey)
+	}
+}
+
+// CreateKey 為指定用戶簽發 API 金鑰，外部服務以 X-API-Key 標頭攜帶金鑰即可以該用戶的身分存取 API
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+	var request CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求"})
+		return
+	}
+
+	plaintext, key, err := h.keyService.CreateKey(request.UserID, request.Name, request.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidAPIKeyRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "簽發 API 金鑰失敗"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIKeyResponse{
+		ID:        key.ID,
+		Key:       plaintext,
+		Name:      key.Name,
+		UserID:    key.UserID,
+		Role:      key.Role,
+		CreatedAt: key.CreatedAt.Unix(),
+	})
+}
+
+// RevokeKey 撤銷 API 金鑰
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的 API 金鑰 ID"})
+		return
+	}
+
+	if err := h.keyService.RevokeKey(uint(id)); err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撤銷 API 金鑰失敗"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API 金鑰已撤銷"})
+}
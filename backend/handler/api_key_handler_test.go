@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"livechat/backend/middleware"
+	"livechat/backend/model"
+	"livechat/backend/repository"
+	"livechat/backend/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// 測試管理員簽發與撤銷 API 金鑰，並以金鑰透過 REST 發送訊息
+//
+// 測試目標：
+// 1. 只有管理員可以簽發金鑰，非管理員返回 403
+// 2. 簽發的金鑰可以代表綁定的用戶發送訊息
+// 3. 撤銷後的金鑰返回 401，重複撤銷返回 404
+func TestAPIKeyHandler(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := repository.NewMockDBWithSchema()
+	userRepo := repository.NewUserRepository(mockDB)
+	assert.NoError(t, userRepo.CreateUser(&model.User{ID: "admin-1", Username: "admin1", Email: "admin@example.com", Password: "Password1", Role: "admin"}))
+	assert.NoError(t, userRepo.CreateUser(&model.User{ID: "bot-1", Username: "bot", Email: "bot@example.com", Password: "Password1", Role: "user"}))
+	userService := service.NewUserService(userRepo)
+	keyService := service.NewAPIKeyService(repository.NewAPIKeyRepository(mockDB), userService)
+
+	roomRepo := repository.NewRoomRepository(mockDB)
+	assert.NoError(t, roomRepo.CreateRoom(&model.Room{ID: "room-1", Name: "聊天室", IsPublic: true, IsActive: true, IsListed: true}))
+
+	newRouter := func(user *middleware.UserResponse) *gin.Engine {
+		router := setupAdminRouter(user)
+		router.Use(middleware.APIKeyMiddleware(keyService))
+		NewAPIKeyHandler(keyService, userService).RegisterRoutes(router)
+		NewRoomHandler(service.NewRoomService(roomRepo)).RegisterRoutes(router)
+		return router
+	}
+	adminRouter := newRouter(&middleware.UserResponse{ID: "admin-1", Role: "admin"})
+	userRouter := newRouter(&middleware.UserResponse{ID: "bot-1", Role: "user"})
+	anonymousRouter := newRouter(nil)
+
+	serve := func(router *gin.Engine, method string, path string, body string, apiKey string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if apiKey != "" {
+			req.Header.Set(middleware.APIKeyHeader, apiKey)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 動作 (Act)
+	forbidden := serve(userRouter, "POST", "/api/keys", `{"userId":"bot-1"}`, "")
+	created := serve(adminRouter, "POST", "/api/keys", `{"userId":"bot-1","name":"通知機器人"}`, "")
+	var key APIKeyResponse
+	assert.NoError(t, json.Unmarshal(created.Body.Bytes(), &key), "應該能夠解析響應")
+	sent := serve(anonymousRouter, "POST", "/api/rooms/room-1/messages", `{"content":"部署完成"}`, key.Key)
+	revoked := serve(adminRouter, "DELETE", fmt.Sprintf("/api/keys/%d", key.ID), "", "")
+	afterRevoke := serve(anonymousRouter, "POST", "/api/rooms/room-1/messages", `{"content":"不應該送出"}`, key.Key)
+	revokedAgain := serve(adminRouter, "DELETE", fmt.Sprintf("/api/keys/%d", key.ID), "", "")
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusForbidden, forbidden.Code, "非管理員簽發金鑰應該返回 403")
+	assert.Equal(t, http.StatusCreated, created.Code, "管理員簽發金鑰應該返回 201")
+	assert.NotEmpty(t, key.Key, "簽發時應該返回金鑰")
+	assert.Equal(t, "user", key.Role, "未指定角色時應該沿用用戶的角色")
+
+	assert.Equal(t, http.StatusCreated, sent.Code, "有效的金鑰應該能發送訊息")
+	messages, err := roomRepo.GetRoomMessages("room-1", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, messages, 1, "只有撤銷前的訊息應該被保存") {
+		assert.Equal(t, "bot-1", messages[0].UserID, "訊息應該以金鑰綁定的用戶發送")
+	}
+
+	assert.Equal(t, http.StatusOK, revoked.Code, "撤銷金鑰應該返回 200")
+	assert.Equal(t, http.StatusUnauthorized, afterRevoke.Code, "撤銷後的金鑰應該返回 401")
+	assert.Equal(t, http.StatusNotFound, revokedAgain.Code, "重複撤銷應該返回 404")
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Capabilities 描述伺服器目前啟用的選用功能，讓前端依設定調整介面
+// 尚未實作的功能（討論串、加入審核）固定為 false，前端可先以相同方式檢查
+type Capabilities struct {
+	Typing                   bool `json:"typing"`
+	Reactions                bool `json:"reactions"`
+	Threads                  bool `json:"threads"`
+	ApprovalJoins            bool `json:"approvalJoins"`
+	ModeratorMessageDeletion bool `json:"moderatorMessageDeletion"`
+	RequireMembership        bool `json:"requireMembership"`
+	AutoCreateRooms          bool `json:"autoCreateRooms"`
+	SelfDM                   bool `json:"selfDM"`
+	MessageNewlines          bool `json:"messageNewlines"`
+	EchoToSender             bool `json:"echoToSender"`
+	GuestIdentity            bool `json:"guestIdentity"`
+	RateLimit                bool `json:"rateLimit"`
+}
+
+// Capabilities 返回 WebSocket 處理器依選項啟用的功能
+func (h *WebSocketHandler) Capabilities() Capabilities {
+	return Capabilities{
+		Typing:            h.typingTracker != nil,
+		Reactions:         h.roomService != nil,
+		RequireMembership: h.requireMembership,
+		AutoCreateRooms:   h.autoCreateRooms,
+		SelfDM:            h.allowSelfDM,
+		MessageNewlines:   h.allowNewlines,
+		EchoToSender:      h.echoToSender,
+		GuestIdentity:     len(h.guestSecret) > 0,
+		RateLimit:         h.rateLimiter != nil,
+	}
+}
+
+// CapabilitiesHandler 處理伺服器功能查詢的 HTTP 請求
+type CapabilitiesHandler struct {
+	capabilities Capabilities
+}
+
+// NewCapabilitiesHandler 創建一個新的功能查詢處理器
+func NewCapabilitiesHandler(capabilities Capabilities) *CapabilitiesHandler {
+	return &CapabilitiesHandler{
+		capabilities: capabilities,
+	}
+}
+
+// RegisterRoutes 註冊功能查詢相關的路由
+func (h *CapabilitiesHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/capabilities", h.GetCapabilities)
+}
+
+// GetCapabilities 返回伺服器啟用的選用功能
+func (h *CapabilitiesHandler) GetCapabilities(c *gin.Context) {
+	c.JSON(http.StatusOK, h.capabilities)
+}
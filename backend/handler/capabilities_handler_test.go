@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"livechat/backend/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 查詢 /api/capabilities 並解析響應
+func getCapabilities(t *testing.T, capabilities Capabilities) map[string]bool {
+	handler := NewCapabilitiesHandler(capabilities)
+	router := setupRouter()
+	handler.RegisterRoutes(router)
+
+	req, _ := http.NewRequest("GET", "/api/capabilities", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+
+	var response map[string]bool
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+	return response
+}
+
+// 測試功能查詢反映 WebSocket 處理器設定的選項
+func TestGetCapabilities(t *testing.T) {
+	// 安排 (Arrange)
+	configured := NewWebSocketHandler(
+		new(MockBroadcastService),
+		WithTypingTracker(service.NewTypingService(time.Second)),
+		WithRoomService(new(MockRoomService)),
+		WithRequireMembership(true),
+		WithAutoCreateRooms(true),
+		WithAllowSelfDM(false),
+		WithGuestIdentity([]byte("secret"), CookieConfig{}),
+		WithRateLimit(5, 10),
+		WithEchoToSender(false),
+	).Capabilities()
+	configured.ModeratorMessageDeletion = true
+	defaults := NewWebSocketHandler(new(MockBroadcastService)).Capabilities()
+
+	// 動作 (Act)
+	enabled := getCapabilities(t, configured)
+	disabled := getCapabilities(t, defaults)
+
+	// 斷言 (Assert)
+	assert.True(t, enabled["typing"], "設置輸入中記錄器後應該啟用 typing")
+	assert.True(t, enabled["requireMembership"], "應該反映需要成員資格的設定")
+	assert.True(t, enabled["autoCreateRooms"], "應該反映自動建立聊天室的設定")
+	assert.False(t, enabled["selfDM"], "停用自我私訊後應該為 false")
+	assert.True(t, enabled["guestIdentity"], "設置訪客密鑰後應該啟用訪客身分")
+	assert.True(t, enabled["rateLimit"], "設置速率限制後應該啟用")
+	assert.False(t, enabled["echoToSender"], "停用回傳給發送者後應該為 false")
+	assert.True(t, enabled["moderatorMessageDeletion"], "應該反映管理員刪除訊息的設定")
+	assert.True(t, enabled["reactions"], "設置聊天室服務後應該啟用表情回應")
+	assert.False(t, enabled["threads"], "尚未支援的功能應該為 false")
+	assert.Contains(t, enabled, "threads", "尚未支援的功能也應該出現在響應中")
+	assert.Contains(t, enabled, "approvalJoins", "尚未支援的功能也應該出現在響應中")
+
+	assert.False(t, disabled["typing"], "未設置輸入中記錄器時不應該啟用 typing")
+	assert.False(t, disabled["reactions"], "未設置聊天室服務時不應該啟用表情回應")
+	assert.False(t, disabled["requireMembership"], "預設不需要成員資格")
+	assert.False(t, disabled["guestIdentity"], "未設置訪客密鑰時不應該啟用訪客身分")
+	assert.False(t, disabled["rateLimit"], "預設不限制訊息速率")
+	assert.True(t, disabled["echoToSender"], "預設回傳訊息給發送者")
+	assert.False(t, disabled["moderatorMessageDeletion"], "預設不允許管理員刪除訊息")
+}
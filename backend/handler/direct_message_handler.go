@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"errors"
+	"livechat/backend/middleware"
+	"livechat/backend/model"
+	"livechat/backend/repository"
+	"livechat/backend/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DirectMessageHandler 處理用戶之間私人訊息的 HTTP 請求
+type DirectMessageHandler struct {
+	userService service.UserService
+}
+
+// DirectMessageResponse 是私人訊息的響應格式
+type DirectMessageResponse struct {
+	ID          uint   `json:"id"`
+	SenderID    string `json:"senderId"`
+	RecipientID string `json:"recipientId"`
+	Content     string `json:"content"`
+	Time        int64  `json:"time"`
+}
+
+// NewDirectMessageHandler 創建一個新的私人訊息處理器
+func NewDirectMessageHandler(userService service.UserService) *DirectMessageHandler {
+	return &DirectMessageHandler{
+		userService: userService,
+	}
+}
+
+// RegisterRoutes 註冊私人訊息相關的路由
+func (h *DirectMessageHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/dm/:userId", middleware.AuthRequired(), h.GetConversation)
+}
+
+// GetConversation 獲取登入用戶與指定用戶之間最近的私人訊息，依時間由舊到新排列，支援 limit 參數
+func (h *DirectMessageHandler) GetConversation(c *gin.Context) {
+	otherUserID := c.Param("userId")
+	if _, err := h.userService.GetUserByID(otherUserID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用戶不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取用戶失敗"})
+		return
+	}
+
+	page := parsePagination(c)
+	messages, err := h.userService.GetDirectMessages(currentUserID(c), otherUserID, page.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取私人訊息失敗"})
+		return
+	}
+
+	response := make([]DirectMessageResponse, 0, len(messages))
+	for _, message := range messages {
+		response = append(response, directMessageResponse(message))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// directMessageResponse 將私人訊息轉換為響應格式
+func directMessageResponse(message model.DirectMessage) DirectMessageResponse {
+	return DirectMessageResponse{
+		ID:          message.ID,
+		SenderID:    message.SenderID,
+		RecipientID: message.RecipientID,
+		Content:     message.Content,
+		Time:        message.CreatedAt.Unix(),
+	}
+}
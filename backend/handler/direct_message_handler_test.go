@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"livechat/backend/model"
+	"livechat/backend/repository"
+	"livechat/backend/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// 測試透過 WebSocket 發送的私人訊息會依用戶保存，並可透過 GET /api/dm/:userId 取得
+//
+// 測試目標：
+// 1. 接收者離線時訊息仍然被保存，之後可以取得
+// 2. 接收者在線時訊息被保存並推送到接收者的所有連接
+// 3. 私人訊息的目標可以使用接收者的用戶名
+func TestDirectMessageDelivery(t *testing.T) {
+	setup := func(t *testing.T) (*WebSocketHandler, *MockBroadcastService, service.UserService, *model.User, *model.User) {
+		userService := service.NewUserService(repository.NewUserRepository(repository.NewMockDB()))
+		alice, err := userService.RegisterUser("alice", "alice@example.com", "Password123")
+		assert.NoError(t, err)
+		bob, err := userService.RegisterUser("bob", "bob@example.com", "Password123")
+		assert.NoError(t, err)
+
+		mockBroadcastService := new(MockBroadcastService)
+		mockBroadcastService.On("GetClient", "bob").Return(nil, repository.ErrClientNotFound)
+		mockLogger := new(MockLogger)
+		mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+		handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithDirectMessages(userService))
+		return handler, mockBroadcastService, userService, alice, bob
+	}
+	conversation := func(t *testing.T, userService service.UserService, userID string, otherUserID string) []DirectMessageResponse {
+		router := setupRoomRouterWithUser(userID)
+		NewDirectMessageHandler(userService).RegisterRoutes(router)
+
+		req, _ := http.NewRequest("GET", "/api/dm/"+otherUserID, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+
+		var response []DirectMessageResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+		return response
+	}
+	dm := []byte(`{"type":"private","target":"bob","content":"嗨 Bob"}`)
+
+	t.Run("接收者離線", func(t *testing.T) {
+		// 安排 (Arrange)
+		handler, mockBroadcastService, userService, alice, bob := setup(t)
+		mockBroadcastService.On("GetClientsByUser", bob.ID).Return([]*model.Client{})
+		aliceSocket := &model.Client{ID: "alice-socket", UserID: alice.ID, UserName: "alice"}
+
+		// 動作 (Act)
+		handler.processTextMessage(aliceSocket, dm)
+
+		// 斷言 (Assert)
+		mockBroadcastService.AssertNotCalled(t, "SendPrivateMessage", mock.Anything, mock.Anything)
+		messages := conversation(t, userService, bob.ID, alice.ID)
+		if assert.Len(t, messages, 1, "離線時訊息應該被保存") {
+			assert.Equal(t, "嗨 Bob", messages[0].Content)
+			assert.Equal(t, alice.ID, messages[0].SenderID, "應該記錄發送者的用戶 ID")
+			assert.Equal(t, bob.ID, messages[0].RecipientID, "應該記錄接收者的用戶 ID")
+		}
+	})
+
+	t.Run("接收者在線", func(t *testing.T) {
+		// 安排 (Arrange)
+		handler, mockBroadcastService, userService, alice, bob := setup(t)
+		bobTabs := []*model.Client{
+			{ID: "bob-tab-1", UserID: bob.ID, UserName: "bob"},
+			{ID: "bob-tab-2", UserID: bob.ID, UserName: "bob"},
+		}
+		mockBroadcastService.On("GetClientsByUser", bob.ID).Return(bobTabs)
+		mockBroadcastService.On("SendPrivateMessage", mock.Anything, mock.Anything).Return(nil)
+		aliceSocket := &model.Client{ID: "alice-socket", UserID: alice.ID, UserName: "alice"}
+
+		// 動作 (Act)
+		handler.processTextMessage(aliceSocket, dm)
+
+		// 斷言 (Assert)
+		messages := conversation(t, userService, alice.ID, bob.ID)
+		if !assert.Len(t, messages, 1, "在線時訊息也應該被保存") {
+			return
+		}
+		mockBroadcastService.AssertNumberOfCalls(t, "SendPrivateMessage", len(bobTabs))
+		for _, tab := range bobTabs {
+			mockBroadcastService.AssertCalled(t, "SendPrivateMessage", tab.ID, mock.Anything)
+		}
+
+		var pushed map[string]interface{}
+		assert.NoError(t, json.Unmarshal(mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1].Arguments.Get(1).([]byte), &pushed))
+		assert.Equal(t, "private", pushed["type"])
+		assert.Equal(t, "嗨 Bob", pushed["content"])
+		assert.Equal(t, "alice", pushed["from"], "推送的訊息應該包含發送者名稱")
+		assert.Equal(t, alice.ID, pushed["fromUserId"], "推送的訊息應該包含發送者的用戶 ID")
+		assert.Equal(t, float64(messages[0].ID), pushed["messageId"], "推送的訊息應該包含保存後的訊息 ID")
+	})
+
+	t.Run("不存在的用戶", func(t *testing.T) {
+		// 安排 (Arrange)
+		_, _, userService, alice, _ := setup(t)
+		router := setupRoomRouterWithUser(alice.ID)
+		NewDirectMessageHandler(userService).RegisterRoutes(router)
+
+		// 動作 (Act)
+		req, _ := http.NewRequest("GET", "/api/dm/unknown-user", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		// 斷言 (Assert)
+		assert.Equal(t, http.StatusNotFound, w.Code, "不存在的用戶應該返回 404")
+	})
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	guestCookieName   = "guest_id"
+	guestCookieMaxAge = 30 * 24 * 60 * 60 // 30 天
+	guestSenderPrefix = "guest:"          // 訪客訊息的發送者 ID 前綴，避免與用戶 ID 混淆
+)
+
+// guestIdentity 是匿名訪客跨連線保持不變的身分
+type guestIdentity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// newGuestIdentity 創建新的訪客身分，未提供名稱時以 ID 前綴產生
+func newGuestIdentity(name string) guestIdentity {
+	id := uuid.New().String()
+	if name == "" {
+		name = "訪客-" + id[:6]
+	}
+	return guestIdentity{ID: id, Name: name}
+}
+
+// signGuestIdentity 將訪客身分編碼並簽名為 cookie 值，格式為「內容.簽名」
+func signGuestIdentity(secret []byte, identity guestIdentity) string {
+	data, _ := json.Marshal(identity)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + guestSignature(secret, payload)
+}
+
+// parseGuestIdentity 驗證 cookie 值的簽名並解析訪客身分，簽名不符或格式錯誤時返回 false
+func parseGuestIdentity(secret []byte, value string) (guestIdentity, bool) {
+	var identity guestIdentity
+
+	payload, signature, found := strings.Cut(value, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(guestSignature(secret, payload))) {
+		return identity, false
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(data, &identity) != nil {
+		return identity, false
+	}
+	if identity.ID == "" || identity.Name == "" {
+		return identity, false
+	}
+	return identity, true
+}
+
+func guestSignature(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// resolveGuestIdentity 從請求的 cookie 取得訪客身分，沒有有效 cookie 時創建新的身分
+func (h *WebSocketHandler) resolveGuestIdentity(r *http.Request, name string) guestIdentity {
+	if cookie, err := r.Cookie(guestCookieName); err == nil {
+		if identity, ok := parseGuestIdentity(h.guestSecret, cookie.Value); ok {
+			return identity
+		}
+	}
+	return newGuestIdentity(name)
+}
+
+// guestCookie 創建保存訪客身分的 cookie，每次連線都會刷新有效期限
+func (h *WebSocketHandler) guestCookie(identity guestIdentity) *http.Cookie {
+	return &http.Cookie{
+		Name:     guestCookieName,
+		Value:    signGuestIdentity(h.guestSecret, identity),
+		Path:     "/",
+		MaxAge:   guestCookieMaxAge,
+		Secure:   h.guestCookieConfig.Secure,
+		HttpOnly: true,
+		SameSite: h.guestCookieConfig.SameSite,
+	}
+}
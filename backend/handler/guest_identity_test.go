@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"livechat/backend/middleware"
+	"livechat/backend/model"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestGuestIdentitySignature 測試訪客身分 cookie 的簽名與驗證
+//
+// 測試目標：
+// 1. 簽名後的身分可以被正確解析
+// 2. 被竄改或使用不同密鑰簽名的 cookie 會被拒絕
+func TestGuestIdentitySignature(t *testing.T) {
+	// 安排 (Arrange)
+	secret := []byte("test-secret")
+	identity := guestIdentity{ID: "guest-1", Name: "Bob"}
+
+	// 動作 (Act)
+	value := signGuestIdentity(secret, identity)
+	parsed, ok := parseGuestIdentity(secret, value)
+
+	// 斷言 (Assert)
+	assert.True(t, ok, "有效的簽名應該通過驗證")
+	assert.Equal(t, identity, parsed, "解析出的身分應該與原本相同")
+
+	forged := signGuestIdentity(secret, guestIdentity{ID: "guest-1", Name: "Mallory"})
+	payload, _, _ := strings.Cut(forged, ".")
+	_, signature, _ := strings.Cut(value, ".")
+	_, ok = parseGuestIdentity(secret, payload+"."+signature)
+	assert.False(t, ok, "內容被竄改的 cookie 應該被拒絕")
+
+	_, ok = parseGuestIdentity([]byte("other-secret"), value)
+	assert.False(t, ok, "使用不同密鑰簽名的 cookie 應該被拒絕")
+
+	_, ok = parseGuestIdentity(secret, "not-a-cookie")
+	assert.False(t, ok, "格式錯誤的 cookie 應該被拒絕")
+}
+
+// TestGuestIdentityAcrossReconnects 測試匿名訪客重新連線時保持相同的身分
+//
+// 測試目標：
+// 1. 首次連線的訪客會收到簽名的身分 cookie
+// 2. 攜帶 cookie 重新連線的訪客保持相同的 ID 與名稱
+// 3. 已登入用戶不受影響，不會收到訪客 cookie
+func TestGuestIdentityAcrossReconnects(t *testing.T) {
+	// 安排 (Arrange)
+	mockBroadcastService := new(MockBroadcastService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(
+		mockBroadcastService,
+		WithLogger(mockLogger),
+		WithGuestIdentity([]byte("test-secret"), CookieConfig{SameSite: http.SameSiteLaxMode}),
+	)
+
+	added := make(chan *model.Client, 1)
+	mockBroadcastService.On("AddClient", mock.Anything).Run(func(args mock.Arguments) {
+		added <- args.Get(0).(*model.Client)
+	}).Return(nil)
+	mockBroadcastService.On("RemoveClient", mock.Anything).Return(nil)
+
+	router := setupRouter()
+	router.Use(func(c *gin.Context) {
+		if c.Query("auth") == "1" {
+			middleware.SetCurrentUser(c, &middleware.UserResponse{ID: "user-1", Username: "Alice"})
+		}
+		c.Next()
+	})
+	router.GET("/ws", func(c *gin.Context) {
+		handler.HandleConnection(c.Writer, c.Request)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	jar, _ := cookiejar.New(nil)
+	dialer := websocket.Dialer{Jar: jar}
+
+	connect := func(dialer *websocket.Dialer, query string) (*model.Client, *http.Response) {
+		conn, resp, err := dialer.Dial(wsURL+query, nil)
+		assert.NoError(t, err, "應該能夠建立連接")
+		defer conn.Close()
+		select {
+		case client := <-added:
+			return client, resp
+		case <-time.After(time.Second):
+			t.Fatal("客戶端應該被添加")
+			return nil, nil
+		}
+	}
+
+	// 動作 (Act)
+	first, firstResp := connect(&dialer, "?username=Bob")
+	second, _ := connect(&dialer, "?username=Someone")
+	anonymous, _ := connect(&dialer, "")
+
+	// 斷言 (Assert)
+	assert.NotEmpty(t, firstResp.Header.Get("Set-Cookie"), "首次連線的訪客應該收到身分 cookie")
+	assert.Equal(t, "Bob", first.UserName, "首次連線應該使用提供的名稱")
+	assert.NotEmpty(t, first.GuestID, "訪客應該有持久 ID")
+	assert.Equal(t, first.GuestID, second.GuestID, "重新連線的訪客應該保持相同的 ID")
+	assert.Equal(t, "Bob", second.UserName, "重新連線的訪客應該保持相同的名稱")
+	assert.Equal(t, "Bob", anonymous.UserName, "未提供名稱重新連線時也應該保持相同的名稱")
+
+	// 沒有 cookie 的新訪客獲得新的身分
+	stranger, _ := connect(websocket.DefaultDialer, "")
+	assert.NotEqual(t, first.GuestID, stranger.GuestID, "新的訪客應該獲得不同的 ID")
+	assert.True(t, strings.HasPrefix(stranger.UserName, "訪客-"), "未提供名稱的訪客應該獲得自動產生的名稱")
+
+	// 已登入用戶不受影響
+	user, userResp := connect(&dialer, "?auth=1")
+	assert.Empty(t, userResp.Header.Get("Set-Cookie"), "已登入用戶不應該收到訪客 cookie")
+	assert.Empty(t, user.GuestID, "已登入用戶不應該有訪客 ID")
+	assert.Equal(t, "Alice", user.UserName, "已登入用戶應該使用會話中的用戶名")
+}
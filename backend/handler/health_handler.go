@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// readinessTimeout 是就緒檢查等待資料庫回應的最長時間
+const readinessTimeout = 2 * time.Second
+
+// HealthHandler 處理負載平衡器使用的存活與就緒檢查
+type HealthHandler struct {
+	db *gorm.DB
+}
+
+// NewHealthHandler 創建一個新的健康檢查處理器
+func NewHealthHandler(db *gorm.DB) *HealthHandler {
+	return &HealthHandler{
+		db: db,
+	}
+}
+
+// RegisterRoutes 註冊健康檢查相關的路由
+func (h *HealthHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/healthz", h.Liveness)
+	router.GET("/readyz", h.Readiness)
+}
+
+// Liveness 只要伺服器仍在處理請求就返回 200
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness 在資料庫能回應查詢時返回 200，否則返回 503
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	if err := h.db.WithContext(ctx).Exec("SELECT 1").Error; err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "資料庫無法連接"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
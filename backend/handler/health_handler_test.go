@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"livechat/backend/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 發送健康檢查請求並返回狀態碼
+func getHealth(handler *HealthHandler, path string) int {
+	router := setupRouter()
+	handler.RegisterRoutes(router)
+
+	req, _ := http.NewRequest("GET", path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+// 測試資料庫可用時存活與就緒檢查都返回 200
+func TestHealthChecksReady(t *testing.T) {
+	// 安排 (Arrange)
+	handler := NewHealthHandler(repository.NewMockDB().DB)
+
+	// 動作 (Act)
+	liveness := getHealth(handler, "/healthz")
+	readiness := getHealth(handler, "/readyz")
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, liveness, "存活檢查應該返回 200")
+	assert.Equal(t, http.StatusOK, readiness, "資料庫可用時就緒檢查應該返回 200")
+}
+
+// 測試資料庫無法連接時就緒檢查返回 503，存活檢查仍返回 200
+func TestHealthChecksDatabaseFailure(t *testing.T) {
+	// 安排 (Arrange)
+	db := repository.NewMockDB().DB
+	sqlDB, err := db.DB()
+	assert.NoError(t, err, "應該能夠取得底層資料庫連接")
+	sqlDB.Close()
+
+	handler := NewHealthHandler(db)
+
+	// 動作 (Act)
+	liveness := getHealth(handler, "/healthz")
+	readiness := getHealth(handler, "/readyz")
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, liveness, "資料庫失敗時存活檢查仍應該返回 200")
+	assert.Equal(t, http.StatusServiceUnavailable, readiness, "資料庫失敗時就緒檢查應該返回 503")
+}
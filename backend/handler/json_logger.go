@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"livechat/backend/model"
+	"sync"
+	"time"
+)
+
+// jsonLogEntry 是 JSONLogger 每行輸出的日誌格式
+type jsonLogEntry struct {
+	Level string   `json:"level"`
+	Msg   string   `json:"msg"`
+	Time  string   `json:"time"`
+	Args  []string `json:"args"`
+}
+
+// JSONLogger 以每行一個 JSON 物件的格式輸出日誌，方便日誌系統收集
+type JSONLogger struct {
+	writer io.Writer
+	mutex  sync.Mutex
+}
+
+// NewJSONLogger 創建一個輸出到 writer 的 JSON 日誌記錄器
+func NewJSONLogger(writer io.Writer) *JSONLogger {
+	return &JSONLogger{writer: writer}
+}
+
+func (l *JSONLogger) Info(msg string, args ...interface{}) {
+	l.write("info", msg, args)
+}
+
+func (l *JSONLogger) Error(msg string, args ...interface{}) {
+	l.write("error", msg, args)
+}
+
+// write 將格式化後的訊息與各參數的字串形式寫成一行 JSON
+func (l *JSONLogger) write(level string, msg string, args []interface{}) {
+	formattedArgs := make([]string, len(args))
+	for i, arg := range args {
+		formattedArgs[i] = fmt.Sprint(arg)
+	}
+
+	line, err := json.Marshal(jsonLogEntry{
+		Level: level,
+		Msg:   fmt.Sprintf(msg, args...),
+		Time:  model.Now().UTC().Format(time.RFC3339),
+		Args:  formattedArgs,
+	})
+	if err != nil {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.writer.Write(append(line, '\n'))
+}
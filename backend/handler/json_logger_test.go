@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"livechat/backend/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 測試 JSONLogger 每行輸出一個包含預期欄位的 JSON 物件
+func TestJSONLogger(t *testing.T) {
+	// 安排 (Arrange)
+	mockTime := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time {
+		return mockTime
+	})
+	defer model.ResetTimeNow()
+
+	var buffer bytes.Buffer
+	var logger Logger = NewJSONLogger(&buffer)
+
+	// 動作 (Act)
+	logger.Info("Client %s joined room %s", "client-1", "room-1")
+	logger.Error("Read error: %v", errors.New("connection reset"))
+
+	// 斷言 (Assert)
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	assert.Len(t, lines, 2, "每次記錄應該輸出一行")
+
+	var info, failure map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &info), "輸出應該是有效的 JSON")
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &failure), "輸出應該是有效的 JSON")
+
+	assert.Equal(t, "info", info["level"], "日誌等級應該匹配")
+	assert.Equal(t, "Client client-1 joined room room-1", info["msg"], "訊息應該已格式化")
+	assert.Equal(t, "2025-08-01T10:00:00Z", info["time"], "時間應該依注入的時鐘計算")
+	assert.Equal(t, []interface{}{"client-1", "room-1"}, info["args"], "參數應該格式化為字串")
+
+	assert.Equal(t, "error", failure["level"], "日誌等級應該匹配")
+	assert.Equal(t, "Read error: connection reset", failure["msg"], "訊息應該已格式化")
+	assert.Equal(t, []interface{}{"connection reset"}, failure["args"], "錯誤參數應該格式化為字串")
+}
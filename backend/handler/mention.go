@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"livechat/backend/model"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// mentionSeparators 是空白以外會結束提及名稱的標點，例如「@bob，@alice 你好」
+// 句點可能出現在用戶名稱中，只在名稱結尾時忽略
+const mentionSeparators = ",!?:;()，。！？：；、（）"
+
+// parseMentions 從訊息內容中取出以 @ 開頭的用戶名稱，依出現順序去重
+func parseMentions(content string) []string {
+	words := strings.FieldsFunc(content, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(mentionSeparators, r)
+	})
+
+	var names []string
+	seen := make(map[string]struct{})
+	for _, word := range words {
+		if !strings.HasPrefix(word, "@") {
+			continue
+		}
+
+		name := strings.TrimRight(word[1:], ".")
+		if name == "" {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+	return names
+}
+
+// notifyMentions 向訊息中提及、且目前在同一聊天室連線的用戶發送 mention 事件
+// 同一用戶的每個連接都會收到通知；提及自己或不在線上的用戶會被略過
+func (h *WebSocketHandler) notifyMentions(client *model.Client, content string) {
+	if client.RoomID == "" {
+		return
+	}
+
+	names := parseMentions(content)
+	if len(names) == 0 {
+		return
+	}
+
+	mentioned := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		if name != client.UserName {
+			mentioned[name] = struct{}{}
+		}
+	}
+	if len(mentioned) == 0 {
+		return
+	}
+
+	event, err := json.Marshal(map[string]interface{}{
+		"type":    "mention",
+		"from":    client.UserName,
+		"roomId":  client.RoomID,
+		"content": content,
+		"time":    time.Now().Unix(),
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal mention event: %v", err)
+		return
+	}
+
+	for _, target := range h.broadcastService.GetClientsInRoom(client.RoomID) {
+		if _, ok := mentioned[target.UserName]; !ok || target.ID == client.ID {
+			continue
+		}
+		if err := h.broadcastService.SendPrivateMessage(target.ID, event); err != nil {
+			h.logger.Error("Failed to send mention to %s: %v", target.ID, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"encoding/json"
+	"livechat/backend/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// 測試從訊息內容中解析提及的用戶名稱
+func TestParseMentions(t *testing.T) {
+	// 動作 & 斷言 (Act & Assert)
+	assert.Equal(t, []string{"bob"}, parseMentions("@bob 你好"), "應該解析開頭的提及")
+	assert.Equal(t, []string{"bob", "alice"}, parseMentions("嗨 @bob，@alice! 還有 @bob"), "應該忽略結尾標點並去除重複")
+	assert.Equal(t, []string{"小明"}, parseMentions("找 @小明。"), "應該支援非英文的用戶名稱")
+	assert.Equal(t, []string{"john.doe"}, parseMentions("謝謝 @john.doe."), "名稱中的句點應該保留，結尾的句點應該忽略")
+	assert.Empty(t, parseMentions("寄信到 bob@example.com"), "不以 @ 開頭的字不應該視為提及")
+	assert.Empty(t, parseMentions("只有 @ 符號"), "單獨的 @ 不應該視為提及")
+}
+
+// 設置提及測試的聊天室，返回處理器與記錄每個客戶端收到的 mention 事件
+func setupMentionRoom(t *testing.T, clients []*model.Client) (*WebSocketHandler, map[string][]map[string]interface{}) {
+	mockBroadcastService := new(MockBroadcastService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	received := make(map[string][]map[string]interface{})
+	mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Return(nil)
+	mockBroadcastService.On("GetClientsInRoom", "room-1").Return(clients)
+	mockBroadcastService.On("SendPrivateMessage", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		var event map[string]interface{}
+		assert.NoError(t, json.Unmarshal(args.Get(1).([]byte), &event))
+		if event["type"] == "mention" {
+			received[args.String(0)] = append(received[args.String(0)], event)
+		}
+	}).Return(nil)
+
+	return NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger)), received
+}
+
+// TestMentionNotifications 測試聊天室訊息中的 @ 提及通知
+//
+// 測試目標：
+// 1. 被提及且在線的用戶收到包含發送者、聊天室與內容的 mention 事件
+// 2. 一則訊息提及多位用戶時每位用戶各收到一次，同一用戶的多個連接都會收到
+// 3. 提及不在線的用戶或提及自己時不發送通知，一般廣播仍照常進行
+func TestMentionNotifications(t *testing.T) {
+	// 安排 (Arrange)
+	alice := &model.Client{ID: "alice-id", UserName: "alice", RoomID: "room-1"}
+	bob := &model.Client{ID: "bob-id", UserName: "bob", RoomID: "room-1"}
+	bobSecondTab := &model.Client{ID: "bob-tab-2", UserName: "bob", RoomID: "room-1"}
+	carol := &model.Client{ID: "carol-id", UserName: "carol", RoomID: "room-1"}
+	dave := &model.Client{ID: "dave-id", UserName: "dave", RoomID: "room-1"}
+
+	// 動作 & 斷言：單一提及
+	handler, received := setupMentionRoom(t, []*model.Client{alice, bob, carol})
+	handler.processTextMessage(alice, []byte(`{"type":"message","content":"@bob 看一下這個"}`))
+
+	if assert.Len(t, received["bob-id"], 1, "被提及的用戶應該收到一次通知") {
+		event := received["bob-id"][0]
+		assert.Equal(t, "alice", event["from"], "通知應該包含發送者")
+		assert.Equal(t, "room-1", event["roomId"], "通知應該包含聊天室 ID")
+		assert.Equal(t, "@bob 看一下這個", event["content"], "通知應該包含訊息內容")
+	}
+	assert.Empty(t, received["carol-id"], "未被提及的用戶不應該收到通知")
+	assert.Empty(t, received["alice-id"], "發送者不應該收到通知")
+
+	// 動作 & 斷言：多個提及
+	handler, received = setupMentionRoom(t, []*model.Client{alice, bob, bobSecondTab, carol, dave})
+	handler.processTextMessage(alice, []byte(`{"type":"message","content":"@bob @carol，還有 @bob 一起開會"}`))
+
+	assert.Len(t, received["bob-id"], 1, "重複提及同一用戶只應該通知一次")
+	assert.Len(t, received["bob-tab-2"], 1, "同一用戶的其他連接也應該收到通知")
+	assert.Len(t, received["carol-id"], 1, "每位被提及的用戶都應該收到通知")
+	assert.Empty(t, received["dave-id"], "未被提及的用戶不應該收到通知")
+
+	// 動作 & 斷言：提及不在線的用戶與自己
+	handler, received = setupMentionRoom(t, []*model.Client{alice, bob})
+	handler.processTextMessage(alice, []byte(`{"type":"message","content":"@erin @alice 有人在嗎"}`))
+
+	assert.Empty(t, received, "提及不在線的用戶或自己不應該發送任何通知")
+	handler.broadcastService.(*MockBroadcastService).AssertCalled(t, "BroadcastToRoom", "room-1", mock.Anything)
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// allowAllOrigins 是允許所有來源的設定值，必須明確設定才會生效
+const allowAllOrigins = "*"
+
+// matchOrigin 依允許清單檢查請求的 Origin，返回允許時應回應的 Access-Control-Allow-Origin 值
+func matchOrigin(allowed []string, origin string) (string, bool) {
+	if origin == "" {
+		return "", false
+	}
+	for _, pattern := range allowed {
+		if originMatches(strings.TrimSpace(pattern), origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// originMatches 檢查來源是否符合單一規則
+// 支援 "*"（全部允許）、完整來源（例如 https://chat.example.com）
+// 以及子網域萬用字元（例如 *.example.com 或 https://*.example.com，不包含 example.com 本身）
+func originMatches(pattern string, origin string) bool {
+	if pattern == "" {
+		return false
+	}
+	if pattern == allowAllOrigins {
+		return true
+	}
+	if strings.EqualFold(pattern, origin) {
+		return true
+	}
+
+	scheme, host, hasScheme := strings.Cut(pattern, "://")
+	if !hasScheme {
+		scheme, host = "", pattern
+	}
+	suffix, ok := strings.CutPrefix(host, "*.")
+	if !ok {
+		return false
+	}
+
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Hostname() == "" {
+		return false
+	}
+	if scheme != "" && !strings.EqualFold(scheme, parsed.Scheme) {
+		return false
+	}
+	return strings.HasSuffix(strings.ToLower(parsed.Hostname()), "."+strings.ToLower(suffix))
+}
+
+// originChecker 建立 websocket.Upgrader 使用的來源檢查函數
+// 沒有 Origin 標頭的請求（非瀏覽器客戶端）不受跨站請求影響，因此允許；與伺服器相同的來源也一律允許
+func originChecker(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if _, ok := matchOrigin(allowed, origin); ok {
+			return true
+		}
+		parsed, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(parsed.Host, r.Host)
+	}
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"livechat/backend/repository"
+	"livechat/backend/service"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// 測試來源允許清單的比對規則
+func TestMatchOrigin(t *testing.T) {
+	allowed := []string{"https://chat.example.com", "*.example.org", "https://*.example.net"}
+
+	tests := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{"完整來源", "https://chat.example.com", true},
+		{"大小寫不同的完整來源", "https://Chat.Example.com", true},
+		{"相同主機但不同協定", "http://chat.example.com", false},
+		{"不在清單中的來源", "https://evil.com", false},
+		{"萬用字元子網域", "https://app.example.org", true},
+		{"萬用字元多層子網域與連接埠", "http://a.b.example.org:8080", true},
+		{"萬用字元不包含主網域本身", "https://example.org", false},
+		{"萬用字元不匹配相似網域", "https://evilexample.org", false},
+		{"指定協定的萬用字元", "https://app.example.net", true},
+		{"指定協定的萬用字元不匹配其他協定", "http://app.example.net", false},
+		{"沒有 Origin", "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// 動作 (Act)
+			origin, ok := matchOrigin(allowed, tc.origin)
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.allowed, ok)
+			if tc.allowed {
+				assert.Equal(t, tc.origin, origin, "應該回應請求的來源而不是 *")
+			}
+		})
+	}
+
+	_, ok := matchOrigin([]string{"*"}, "https://anything.test")
+	assert.True(t, ok, "明確設定 * 時應該允許所有來源")
+	_, ok = matchOrigin(nil, "https://anything.test")
+	assert.False(t, ok, "未設定清單時不應該允許跨來源")
+}
+
+// 測試 WebSocket 握手時套用來源允許清單
+//
+// 測試目標：
+// 1. 允許的來源可以建立連接，並以該來源作為 Access-Control-Allow-Origin
+// 2. 不允許的來源在升級前被拒絕
+// 3. 符合萬用字元的子網域可以建立連接
+// 4. 未設置清單時只允許相同來源
+func TestAllowedOriginsHandshake(t *testing.T) {
+	// 安排 (Arrange)
+	broadcastService := service.NewBroadcastService(repository.NewClientRepository())
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+
+	restricted := httptest.NewServer(http.HandlerFunc(NewWebSocketHandler(broadcastService, WithLogger(mockLogger),
+		WithAllowedOrigins([]string{"https://chat.example.com", "*.example.org"})).HandleConnection))
+	defer restricted.Close()
+	defaults := httptest.NewServer(http.HandlerFunc(NewWebSocketHandler(broadcastService, WithLogger(mockLogger)).HandleConnection))
+	defer defaults.Close()
+
+	dial := func(server *httptest.Server, origin string) (*http.Response, error) {
+		header := http.Header{}
+		header.Set("Origin", origin)
+		conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), header)
+		if err == nil {
+			conn.Close()
+		}
+		return resp, err
+	}
+
+	t.Run("允許的來源", func(t *testing.T) {
+		// 動作 (Act)
+		resp, err := dial(restricted, "https://chat.example.com")
+
+		// 斷言 (Assert)
+		assert.NoError(t, err, "允許的來源應該能夠建立連接")
+		assert.Equal(t, "https://chat.example.com", resp.Header.Get("Access-Control-Allow-Origin"), "應該回應符合的來源")
+	})
+
+	t.Run("不允許的來源", func(t *testing.T) {
+		// 動作 (Act)
+		resp, err := dial(restricted, "https://evil.com")
+
+		// 斷言 (Assert)
+		assert.Error(t, err, "不允許的來源不應該建立連接")
+		if assert.NotNil(t, resp) {
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode, "應該返回 403")
+			assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"), "不應該回應 CORS 標頭")
+		}
+	})
+
+	t.Run("萬用字元子網域", func(t *testing.T) {
+		// 動作 (Act)
+		resp, err := dial(restricted, "https://app.example.org")
+
+		// 斷言 (Assert)
+		assert.NoError(t, err, "符合萬用字元的子網域應該能夠建立連接")
+		assert.Equal(t, "https://app.example.org", resp.Header.Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("默認只允許相同來源", func(t *testing.T) {
+		// 動作 (Act)
+		_, crossErr := dial(defaults, "https://chat.example.com")
+		_, sameErr := dial(defaults, defaults.URL)
+
+		// 斷言 (Assert)
+		assert.Error(t, crossErr, "未設置清單時不應該允許跨來源連接")
+		assert.NoError(t, sameErr, "相同來源應該能夠建立連接")
+
+		_, err := dial(restricted, restricted.URL)
+		assert.NoError(t, err, "設置清單時相同來源仍然應該能夠建立連接")
+	})
+}
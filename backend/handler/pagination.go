@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Pagination 是從查詢參數解析出的分頁設定
+type Pagination struct {
+	Limit  int
+	Offset int
+}
+
+// 分頁的預設筆數與上限，所有分頁端點共用
+var (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// SetPaginationLimits 設置所有分頁端點共用的預設筆數與上限，非正數的值會被忽略
+func SetPaginationLimits(defaultLimit int, maxLimit int) {
+	if maxLimit > 0 {
+		maxPageLimit = maxLimit
+	}
+	if defaultLimit > 0 {
+		defaultPageLimit = defaultLimit
+	}
+	if defaultPageLimit > maxPageLimit {
+		defaultPageLimit = maxPageLimit
+	}
+}
+
+// parsePagination 解析 limit 與 offset 查詢參數
+// 缺少或無效的 limit 使用預設值，超過上限時以上限為準；缺少或無效的 offset 為 0
+func parsePagination(c *gin.Context) Pagination {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	return Pagination{Limit: limit, Offset: offset}
+}
+
+// parsePageParams 解析 page（從 1 開始）與 pageSize 查詢參數並轉換為分頁設定
+// 兩者都未提供時返回 false 表示不分頁；無效的 page 視為第 1 頁，pageSize 的預設值與上限同 limit
+func parsePageParams(c *gin.Context) (Pagination, bool) {
+	pageParam, hasPage := c.GetQuery("page")
+	sizeParam, hasSize := c.GetQuery("pageSize")
+	if !hasPage && !hasSize {
+		return Pagination{}, false
+	}
+
+	pageSize, err := strconv.Atoi(sizeParam)
+	if err != nil || pageSize <= 0 {
+		pageSize = defaultPageLimit
+	}
+	if pageSize > maxPageLimit {
+		pageSize = maxPageLimit
+	}
+
+	page, err := strconv.Atoi(pageParam)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	return Pagination{Limit: pageSize, Offset: (page - 1) * pageSize}, true
+}
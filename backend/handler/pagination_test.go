@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// 以指定的查詢字串解析分頁參數
+func parsePaginationQuery(query string) Pagination {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("GET", "/?"+query, nil)
+	return parsePagination(c)
+}
+
+// 測試分頁參數的預設值、上限與無效值處理
+func TestParsePagination(t *testing.T) {
+	testCases := []struct {
+		name     string
+		query    string
+		expected Pagination
+	}{
+		{name: "未提供參數使用預設值", query: "", expected: Pagination{Limit: 50, Offset: 0}},
+		{name: "有效的參數", query: "limit=20&offset=40", expected: Pagination{Limit: 20, Offset: 40}},
+		{name: "超過上限時限制為上限", query: "limit=1000", expected: Pagination{Limit: 200, Offset: 0}},
+		{name: "剛好等於上限", query: "limit=200", expected: Pagination{Limit: 200, Offset: 0}},
+		{name: "非數字的 limit 使用預設值", query: "limit=abc", expected: Pagination{Limit: 50, Offset: 0}},
+		{name: "零或負數的 limit 使用預設值", query: "limit=-5", expected: Pagination{Limit: 50, Offset: 0}},
+		{name: "負數的 offset 為 0", query: "offset=-10", expected: Pagination{Limit: 50, Offset: 0}},
+		{name: "非數字的 offset 為 0", query: "offset=x", expected: Pagination{Limit: 50, Offset: 0}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 動作 (Act)
+			page := parsePaginationQuery(tc.query)
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.expected, page, "分頁參數應該匹配")
+		})
+	}
+}
+
+// 測試設置共用的分頁預設值與上限
+func TestSetPaginationLimits(t *testing.T) {
+	// 安排 (Arrange)
+	originalDefault, originalMax := defaultPageLimit, maxPageLimit
+	defer func() {
+		defaultPageLimit, maxPageLimit = originalDefault, originalMax
+	}()
+
+	// 動作 (Act)
+	SetPaginationLimits(10, 30)
+
+	// 斷言 (Assert)
+	assert.Equal(t, Pagination{Limit: 10}, parsePaginationQuery(""), "應該使用設置的預設值")
+	assert.Equal(t, Pagination{Limit: 30}, parsePaginationQuery("limit=100"), "應該使用設置的上限")
+
+	// 非正數的設定被忽略，預設值不超過上限
+	SetPaginationLimits(0, 5)
+	assert.Equal(t, Pagination{Limit: 5}, parsePaginationQuery(""), "預設值不應該超過上限")
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PresenceHandler 處理公開的在線狀態相關 HTTP 請求
+type PresenceHandler struct {
+	presenceService PresenceService
+}
+
+// OnlineCountResponse 是全站在線人數的 API 響應格式
+type OnlineCountResponse struct {
+	Users  int `json:"users"`
+	Guests int `json:"guests"`
+}
+
+// NewPresenceHandler 創建一個新的在線狀態處理器
+func NewPresenceHandler(presenceService PresenceService) *PresenceHandler {
+	return &PresenceHandler{
+		presenceService: presenceService,
+	}
+}
+
+// RegisterRoutes 註冊在線狀態相關的路由
+func (h *PresenceHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/online", h.GetOnlineCount)
+}
+
+// GetOnlineCount 獲取全站在線人數
+func (h *PresenceHandler) GetOnlineCount(c *gin.Context) {
+	count := h.presenceService.GetOnlineCount()
+
+	c.JSON(http.StatusOK, OnlineCountResponse{
+		Users:  count.Users,
+		Guests: count.Guests,
+	})
+}
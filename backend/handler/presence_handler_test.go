@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"encoding/json"
+	"livechat/backend/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 測試獲取全站在線人數
+func TestGetOnlineCount(t *testing.T) {
+	// 安排 (Arrange)
+	mockPresenceService := new(MockPresenceService)
+	handler := NewPresenceHandler(mockPresenceService)
+	router := setupRouter()
+	handler.RegisterRoutes(router)
+
+	mockPresenceService.On("GetOnlineCount").Return(service.OnlineCount{Users: 3, Guests: 2})
+
+	req, _ := http.NewRequest("GET", "/api/online", nil)
+	w := httptest.NewRecorder()
+
+	// 動作 (Act)
+	router.ServeHTTP(w, req)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+
+	var response OnlineCountResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err, "應該能夠解析響應")
+	assert.Equal(t, 3, response.Users, "登入用戶數應該匹配")
+	assert.Equal(t, 2, response.Guests, "訪客數應該匹配")
+
+	mockPresenceService.AssertExpectations(t)
+}
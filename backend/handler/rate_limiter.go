@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"livechat/backend/model"
+	"math"
+	"sync"
+	"time"
+)
+
+// tokenBucket 是單一客戶端的令牌桶狀態
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter 以令牌桶限制每個客戶端的訊息速率，各客戶端的狀態以客戶端 ID 為鍵保存
+type rateLimiter struct {
+	rate    float64 // 每秒補充的令牌數
+	burst   float64 // 令牌桶容量，即允許的瞬間訊息數
+	buckets map[string]*tokenBucket
+	mutex   sync.Mutex
+}
+
+// newRateLimiter 創建一個新的速率限制器，burst 小於 1 時視為 1
+func newRateLimiter(msgsPerSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		rate:    msgsPerSecond,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Allow 嘗試為客戶端消耗一個令牌，令牌不足時返回 false
+func (l *rateLimiter) Allow(clientID string) bool {
+	return l.AllowN(clientID, 1)
+}
+
+// AllowN 嘗試為客戶端一次消耗 n 個令牌，令牌不足時不消耗任何令牌並返回 false
+func (l *rateLimiter) AllowN(clientID string, n int) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := model.Now()
+	bucket, exists := l.buckets[clientID]
+	if !exists {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[clientID] = bucket
+	} else if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens = math.Min(l.burst, bucket.tokens+elapsed.Seconds()*l.rate)
+		bucket.last = now
+	}
+
+	if bucket.tokens < float64(n) {
+		return false
+	}
+
+	bucket.tokens -= float64(n)
+	return true
+}
+
+// Remove 移除客戶端的令牌桶，於客戶端斷線時呼叫
+func (l *rateLimiter) Remove(clientID string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.buckets, clientID)
+}
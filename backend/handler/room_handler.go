@@ -1,11 +1,17 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"livechat/backend/middleware"
 	"livechat/backend/model"
+	"livechat/backend/repository"
 	"livechat/backend/service"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,46 +19,250 @@ import (
 // RoomService 定義了聊天室服務的接口
 type RoomService interface {
 	GetRoom(roomID string) (*model.Room, error)
-	GetAllRooms() ([]model.Room, error)
+	GetRoomByName(name string) (*model.Room, error)
+	GetAllRoomsWithActiveCounts(order repository.RoomOrder, filter repository.RoomFilter) ([]model.RoomWithActiveCount, int64, error)
 	CreateRoom(data service.RoomData, createdBy string) (*model.Room, error)
+	DeleteRoom(roomID string, requesterID string) error
 	JoinRoom(roomID string, userID string, role string) error
 	LeaveRoom(roomID string, userID string) error
 	GetRoomMessages(roomID string, limit int) ([]model.Message, error)
-	SendMessage(roomID string, userID string, content string) error
+	GetRoomMessagesBefore(roomID string, beforeID uint, limit int) ([]model.Message, error)
+	SearchMessages(roomID string, query string, limit int) ([]model.MessageSearchResult, error)
+	IsActiveMember(roomID string, userID string) (bool, error)
+	IsMember(roomID string, userID string) (bool, error)
+	CanReadRoom(roomID string, userID string) (bool, error)
+	GetMembershipHistory(roomID string, requesterID string, limit int, offset int) ([]model.RoomUser, error)
+	GetRecentRooms(userID string, limit int, offset int) ([]model.RoomWithActiveCount, error)
+	MarkRead(userID string, roomID string) error
+	GetUnreadCounts(userID string, roomIDs []string) (map[string]int64, error)
+	SendMessage(roomID string, userID string, content string) (*model.Message, error)
+	GetMessageInRoom(roomID string, messageID uint) (*model.Message, error)
+	EditMessage(messageID uint, userID string, newContent string) (*model.Message, error)
+	DeleteMessage(messageID uint, userID string) (*model.Message, error)
+	DeleteMessagesByUser(userID string) ([]model.Message, error)
+	AddReaction(roomID string, messageID uint, userID string, emoji string) ([]model.ReactionCount, error)
+	RemoveReaction(roomID string, messageID uint, userID string, emoji string) ([]model.ReactionCount, error)
+	GetReactionCounts(roomID string, messageID uint) ([]model.ReactionCount, error)
+	GetMessagesReactionCounts(messages []model.Message) (map[uint][]model.ReactionCount, error)
 	SendSystemMessage(roomID string, content string) error
 	GetRoomActiveUserCount(roomID string) (int64, error)
-	GetRoomUsers(roomID string) ([]model.RoomUser, error)
+	GetRoomUsersWithDetails(roomID string) ([]model.RoomUserDetail, error)
+	CreateInvite(roomID string, requesterID string, ttl time.Duration, maxUses int) (*model.RoomInvite, error)
+	AcceptInvite(token string, userID string) (*model.Room, error)
+	KickUser(roomID string, targetUserID string, moderatorID string) error
+	BanUser(roomID string, targetUserID string, moderatorID string) error
+}
+
+// EventBroadcaster 定義向聊天室發送暫態事件的接口
+type EventBroadcaster interface {
+	BroadcastEventToRoom(roomID string, excludeClientID string, message []byte) error
+}
+
+// MessageBroadcaster 定義向聊天室廣播聊天訊息的接口，廣播的訊息會與 WebSocket 發送的訊息一樣記錄到訊息歷史
+type MessageBroadcaster interface {
+	BroadcastToRoom(roomID string, message []byte) error
+}
+
+// RoomJoiner 定義將用戶的即時連接移入聊天室的接口，由 WebSocketHandler 實作
+type RoomJoiner interface {
+	MoveUserToRoom(userID string, room *model.Room) int
+}
+
+// ContentValidator 定義檢查訊息內容（長度上限與控制字元）的接口，由 WebSocketHandler 實作
+type ContentValidator interface {
+	ValidateContent(content string) error
+}
+
+// RoomCloser 定義將即時連接移出已刪除聊天室的接口，由 WebSocketHandler 實作
+type RoomCloser interface {
+	CloseRoom(roomID string) int
+}
+
+// RoomKicker 定義將被移出聊天室的用戶的即時連接移出的接口，由 WebSocketHandler 實作
+type RoomKicker interface {
+	RemoveUserFromRoom(roomID string, userID string, banned bool) int
 }
 
 // RoomHandler 處理聊天室相關的 HTTP 請求
 type RoomHandler struct {
 	roomService RoomService
+	broadcaster EventBroadcaster
+	messenger   MessageBroadcaster
+	joiner      RoomJoiner
+	closer      RoomCloser
+	kicker      RoomKicker
+	validator   ContentValidator
+	createGuard gin.HandlerFunc // 創建聊天室前執行的檢查，nil 表示不檢查
+}
+
+// RoomHandlerOption 定義聊天室處理器選項
+type RoomHandlerOption func(*RoomHandler)
+
+// WithCreatorAutoJoin 設置創建聊天室後自動將創建者目前的 WebSocket 連接移入新聊天室
+func WithCreatorAutoJoin(joiner RoomJoiner) RoomHandlerOption {
+	return func(h *RoomHandler) {
+		h.joiner = joiner
+	}
+}
+
+// WithRoomCloser 設置刪除聊天室後通知並移出聊天室中的 WebSocket 連接
+func WithRoomCloser(closer RoomCloser) RoomHandlerOption {
+	return func(h *RoomHandler) {
+		h.closer = closer
+	}
+}
+
+// WithRoomKicker 設置移出或封禁用戶後，強制該用戶的 WebSocket 連接離開聊天室
+func WithRoomKicker(kicker RoomKicker) RoomHandlerOption {
+	return func(h *RoomHandler) {
+		h.kicker = kicker
+	}
+}
+
+// WithEventBroadcaster 設置事件廣播器，用於通知聊天室中的客戶端訊息已被編輯或刪除
+func WithEventBroadcaster(broadcaster EventBroadcaster) RoomHandlerOption {
+	return func(h *RoomHandler) {
+		h.broadcaster = broadcaster
+	}
+}
+
+// WithMessageBroadcaster 設置訊息廣播器，透過 REST 發送的訊息會即時推送給聊天室中的 WebSocket 客戶端
+func WithMessageBroadcaster(messenger MessageBroadcaster) RoomHandlerOption {
+	return func(h *RoomHandler) {
+		h.messenger = messenger
+	}
+}
+
+// WithContentValidator 設置透過 REST 發送訊息時的內容檢查，與 WebSocket 訊息套用相同的規則
+func WithContentValidator(validator ContentValidator) RoomHandlerOption {
+	return func(h *RoomHandler) {
+		h.validator = validator
+	}
+}
+
+// WithVerifiedRoomCreation 要求創建聊天室的用戶已登入且已驗證電子郵件
+func WithVerifiedRoomCreation(userService service.UserService) RoomHandlerOption {
+	return func(h *RoomHandler) {
+		h.createGuard = middleware.VerifiedRequired(userService)
+	}
 }
 
 // RoomResponse 是聊天室的 API 響應格式
 type RoomResponse struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	IsPublic    bool   `json:"isPublic"`
-	MaxUsers    int    `json:"maxUsers"`
-	CreatedBy   string `json:"createdBy"`
-	ActiveUsers int64  `json:"activeUsers"`
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	Description       string `json:"description"`
+	IsPublic          bool   `json:"isPublic"`
+	MaxUsers          int    `json:"maxUsers"`
+	IsListed          bool   `json:"isListed"`
+	CreatedBy         string `json:"createdBy"`
+	ActiveUsers       int64  `json:"activeUsers"`
+	MessageTTLSeconds int    `json:"messageTtlSeconds"`
+	UnreadCount       *int64 `json:"unreadCount,omitempty"` // 登入用戶在聊天室中的未讀訊息數，未登入時不提供
 }
 
 // CreateRoomRequest 是創建聊天室的請求格式
 type CreateRoomRequest struct {
-	Name        string `json:"name" binding:"required"`
-	Description string `json:"description"`
-	IsPublic    bool   `json:"isPublic"`
-	MaxUsers    int    `json:"maxUsers"`
+	Name              string `json:"name" binding:"required"`
+	Description       string `json:"description"`
+	IsPublic          *bool  `json:"isPublic"` // 未提供時預設為公開聊天室
+	MaxUsers          int    `json:"maxUsers"`
+	IsListed          *bool  `json:"isListed"`                          // 未提供時預設列在公開列表中
+	MessageTTLSeconds int    `json:"messageTtlSeconds" binding:"min=0"` // 訊息存活秒數，0 表示永久保存
+}
+
+// CreateInviteRequest 是創建聊天室邀請的請求格式
+type CreateInviteRequest struct {
+	ExpiresIn int `json:"expiresIn"` // 有效秒數，未提供時使用服務的預設有效期限
+	MaxUses   int `json:"maxUses"`   // 最大使用次數，0 表示不限（伺服器設置上限時必須提供）
+}
+
+// InviteResponse 是聊天室邀請的 API 響應格式
+type InviteResponse struct {
+	Token     string `json:"token"`
+	RoomID    string `json:"roomId"`
+	ExpiresAt int64  `json:"expiresAt"`
+	MaxUses   int    `json:"maxUses"`
+	Uses      int    `json:"uses"`
+}
+
+// MembershipEventResponse 是聊天室成員記錄的 API 響應格式
+// LeftAt 只在成員已離開時提供，取自記錄最後更新的時間
+type MembershipEventResponse struct {
+	UserID       string `json:"userId"`
+	Role         string `json:"role"`
+	JoinedAt     int64  `json:"joinedAt"`
+	LastActiveAt int64  `json:"lastActiveAt"`
+	LeftAt       int64  `json:"leftAt,omitempty"`
+	IsActive     bool   `json:"isActive"`
+}
+
+// RoomUserResponse 是聊天室活躍用戶的 API 響應格式
+type RoomUserResponse struct {
+	UserID       string `json:"userId"`
+	Username     string `json:"username"`
+	DisplayName  string `json:"displayName,omitempty"`
+	Role         string `json:"role"`
+	JoinedAt     int64  `json:"joinedAt"`
+	LastActiveAt int64  `json:"lastActiveAt"`
+}
+
+// MessageSearchResponse 是訊息搜尋結果的 API 響應格式
+type MessageSearchResponse struct {
+	ID          uint   `json:"id"`
+	UserID      string `json:"userId"`
+	Username    string `json:"username"`
+	DisplayName string `json:"displayName,omitempty"`
+	Content     string `json:"content"`
+	Time        int64  `json:"time"`
+}
+
+// ReactionResponse 是訊息上單一表情回應數量的 API 響應格式
+type ReactionResponse struct {
+	Emoji string `json:"emoji"`
+	Count int64  `json:"count"`
+}
+
+// HistoryMessageResponse 是歷史訊息的格式，在訊息欄位之外附上各表情的回應數量
+type HistoryMessageResponse struct {
+	model.Message
+	Reactions []ReactionResponse `json:"reactions"`
+}
+
+// EditMessageRequest 是編輯訊息的請求格式
+type EditMessageRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// SendMessageRequest 是透過 REST 發送訊息的請求格式
+type SendMessageRequest struct {
+	Content string `json:"content"`
+}
+
+// ChatMessageResponse 是聊天訊息的格式，與 WebSocket 客戶端發送與接收的聊天訊息相同
+type ChatMessageResponse struct {
+	MessageID uint   `json:"messageId,omitempty"` // 保存後的訊息 ID，用於編輯、刪除與表情回應
+	Content   string `json:"content"`
+	Sender    string `json:"sender"`
+	Time      int64  `json:"time"`
+}
+
+// ModerateUserRequest 是移出或封禁用戶請求的格式
+type ModerateUserRequest struct {
+	UserID string `json:"userId" binding:"required"`
 }
 
 // NewRoomHandler 創建一個新的聊天室處理器
-func NewRoomHandler(roomService RoomService) *RoomHandler {
-	return &RoomHandler{
+func NewRoomHandler(roomService RoomService, opts ...RoomHandlerOption) *RoomHandler {
+	h := &RoomHandler{
 		roomService: roomService,
 	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
 }
 
 // RegisterRoutes 註冊聊天室相關的路由
@@ -61,16 +271,115 @@ func (h *RoomHandler) RegisterRoutes(router *gin.Engine) {
 	{
 		rooms.GET("", h.GetAllRooms)
 		rooms.GET("/:id", h.GetRoom)
-		rooms.POST("", h.CreateRoom)
+		if h.createGuard != nil {
+			rooms.POST("", h.createGuard, h.CreateRoom)
+		} else {
+			rooms.POST("", h.CreateRoom)
+		}
+		rooms.DELETE("/:id", middleware.AuthRequired(), h.DeleteRoom)
 		rooms.GET("/:id/messages", h.GetRoomMessages)
+		rooms.GET("/:id/messages/search", h.SearchMessages)
+		rooms.POST("/:id/messages", middleware.AuthRequired(), h.SendMessage)
+		rooms.PUT("/:id/messages/:msgId", middleware.AuthRequired(), h.EditMessage)
+		rooms.DELETE("/:id/messages/:msgId", middleware.AuthRequired(), h.DeleteMessage)
+		rooms.GET("/:id/messages/:msgId/reactions", h.GetMessageReactions)
 		rooms.GET("/:id/users", h.GetRoomUsers)
+		rooms.POST("/:id/invites", middleware.AuthRequired(), h.CreateInvite)
+		rooms.POST("/:id/read", middleware.AuthRequired(), h.MarkRead)
+		rooms.GET("/:id/membership-history", middleware.AuthRequired(), h.GetMembershipHistory)
+		rooms.POST("/:id/kick", middleware.AuthRequired(), h.KickUser)
+		rooms.POST("/:id/ban", middleware.AuthRequired(), h.BanUser)
 	}
+
+	router.POST("/api/invites/:token/accept", middleware.AuthRequired(), h.AcceptInvite)
+	router.GET("/api/user/recent-rooms", middleware.AuthRequired(), h.GetRecentRooms)
+	router.DELETE("/api/user/messages", middleware.AuthRequired(), h.DeleteUserMessages)
 }
 
-// GetAllRooms 獲取所有聊天室
+// authorizeRoomRead 檢查目前的用戶是否可以讀取聊天室內容，不可讀取時寫入錯誤響應並返回 false
+// 私人聊天室只有創建者與有效成員可以讀取
+func (h *RoomHandler) authorizeRoomRead(c *gin.Context, roomID string) bool {
+	allowed, err := h.roomService.CanReadRoom(roomID, currentUserID(c))
+	switch {
+	case errors.Is(err, repository.ErrRoomNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "聊天室不存在"})
+		return false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "檢查聊天室權限失敗"})
+		return false
+	case !allowed:
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有聊天室成員可以查看私人聊天室的內容"})
+		return false
+	}
+	return true
+}
+
+// currentUserID 從上下文中獲取登入用戶的 ID，未登入時返回空字串
+func currentUserID(c *gin.Context) string {
+	user := currentUser(c)
+	if user == nil {
+		return ""
+	}
+
+	return user.ID
+}
+
+// currentUser 從上下文中獲取登入用戶，未登入時返回 nil
+func currentUser(c *gin.Context) *middleware.UserResponse {
+	userValue, exists := c.Get("user")
+	if !exists {
+		return nil
+	}
+
+	user, ok := userValue.(*middleware.UserResponse)
+	if !ok {
+		return nil
+	}
+
+	return user
+}
+
+// roomSortOrders 對應 sort 查詢參數與聊天室排序方式
+var roomSortOrders = map[string]repository.RoomOrder{
+	"active": repository.RoomOrderActive,
+	"recent": repository.RoomOrderActivity,
+	"name":   repository.RoomOrderName,
+}
+
+// GetAllRooms 獲取公開聊天室，可透過 order 參數指定排序（name、created、activity、active，預設 activity）
+// 或以 sort 參數指定（active 依活躍人數、recent 依最近訊息、name 依名稱），同時提供時以 sort 為準
+//
+// 支援 q（名稱或描述包含的文字）、public 與 page/pageSize 分頁參數，符合條件的總數放在 X-Total-Count 標頭。
+// 私人聊天室不會出現在列表中，因此 public 只接受 true
 func (h *RoomHandler) GetAllRooms(c *gin.Context) {
-	// 獲取所有聊天室
-	rooms, err := h.roomService.GetAllRooms()
+	order := repository.RoomOrder(c.DefaultQuery("order", string(repository.RoomOrderActivity)))
+	if sort, ok := c.GetQuery("sort"); ok {
+		if order, ok = roomSortOrders[sort]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": repository.ErrInvalidRoomOrder.Error()})
+			return
+		}
+	}
+
+	if public := c.DefaultQuery("public", "true"); public != "true" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "私人聊天室不會出現在列表中，public 只能為 true"})
+		return
+	}
+
+	filter := repository.RoomFilter{
+		Query:      strings.TrimSpace(c.Query("q")),
+		PublicOnly: true,
+	}
+	if page, ok := parsePageParams(c); ok {
+		filter.Limit = page.Limit
+		filter.Offset = page.Offset
+	}
+
+	// 獲取符合條件的公開聊天室及其活躍用戶數
+	rooms, total, err := h.roomService.GetAllRoomsWithActiveCounts(order, filter)
+	if errors.Is(err, repository.ErrInvalidRoomOrder) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		fmt.Printf("Error getting rooms: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取聊天室失敗"})
@@ -82,27 +391,43 @@ func (h *RoomHandler) GetAllRooms(c *gin.Context) {
 		fmt.Printf("Room %d: ID=%s, Name=%s\n", i+1, room.ID, room.Name)
 	}
 
+	// 登入用戶額外取得各聊天室的未讀訊息數
+	var unreadCounts map[string]int64
+	if userID := currentUserID(c); userID != "" {
+		roomIDs := make([]string, 0, len(rooms))
+		for _, room := range rooms {
+			roomIDs = append(roomIDs, room.ID)
+		}
+		unreadCounts, err = h.roomService.GetUnreadCounts(userID, roomIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取未讀訊息數失敗"})
+			return
+		}
+	}
+
 	// 構建響應
 	var response []RoomResponse
 	for _, room := range rooms {
-		// 獲取活躍用戶數
-		activeUsers, err := h.roomService.GetRoomActiveUserCount(room.ID)
-		if err != nil {
-			activeUsers = 0
+		var unreadCount *int64
+		if count, ok := unreadCounts[room.ID]; ok {
+			unreadCount = &count
 		}
-
 		response = append(response, RoomResponse{
-			ID:          room.ID,
-			Name:        room.Name,
-			Description: room.Description,
-			IsPublic:    room.IsPublic,
-			MaxUsers:    room.MaxUsers,
-			CreatedBy:   room.CreatedBy,
-			ActiveUsers: activeUsers,
+			ID:                room.ID,
+			Name:              room.Name,
+			Description:       room.Description,
+			IsPublic:          room.IsPublic,
+			MaxUsers:          room.MaxUsers,
+			IsListed:          room.IsListed,
+			CreatedBy:         room.CreatedBy,
+			MessageTTLSeconds: room.MessageTTLSeconds,
+			ActiveUsers:       room.ActiveUsers,
+			UnreadCount:       unreadCount,
 		})
 	}
 
 	fmt.Printf("Sending %d rooms to frontend\n", len(response))
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
 	c.JSON(http.StatusOK, response)
 }
 
@@ -126,13 +451,15 @@ func (h *RoomHandler) GetRoom(c *gin.Context) {
 
 	// 構建響應
 	response := RoomResponse{
-		ID:          room.ID,
-		Name:        room.Name,
-		Description: room.Description,
-		IsPublic:    room.IsPublic,
-		MaxUsers:    room.MaxUsers,
-		CreatedBy:   room.CreatedBy,
-		ActiveUsers: activeUsers,
+		ID:                room.ID,
+		Name:              room.Name,
+		Description:       room.Description,
+		IsPublic:          room.IsPublic,
+		MaxUsers:          room.MaxUsers,
+		IsListed:          room.IsListed,
+		CreatedBy:         room.CreatedBy,
+		MessageTTLSeconds: room.MessageTTLSeconds,
+		ActiveUsers:       activeUsers,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -148,73 +475,674 @@ func (h *RoomHandler) CreateRoom(c *gin.Context) {
 	}
 
 	// 獲取用戶 ID
-	userID := c.GetString("userID")
+	userID := currentUserID(c)
 	if userID == "" {
 		// 如果未設置用戶 ID，使用默認值
 		userID = "system"
 	}
 
 	// 創建聊天室
+	isListed := true
+	if request.IsListed != nil {
+		isListed = *request.IsListed
+	}
+	isPublic := true
+	if request.IsPublic != nil {
+		isPublic = *request.IsPublic
+	}
+
 	roomData := service.RoomData{
-		Name:        request.Name,
-		Description: request.Description,
-		IsPublic:    request.IsPublic,
-		MaxUsers:    request.MaxUsers,
+		Name:              request.Name,
+		Description:       request.Description,
+		IsPublic:          isPublic,
+		MaxUsers:          request.MaxUsers,
+		IsListed:          isListed,
+		MessageTTLSeconds: request.MessageTTLSeconds,
+	}
+
+	// 管理員不受聊天室數量上限限制
+	if user := currentUser(c); user != nil && user.Role == "admin" {
+		roomData.BypassRoomLimit = true
 	}
 
 	room, err := h.roomService.CreateRoom(roomData, userID)
 	if err != nil {
+		if errors.Is(err, service.ErrRoomCapacityReached) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "創建聊天室失敗"})
 		return
 	}
 
+	// 已登入的創建者的即時連接自動加入新聊天室
+	if h.joiner != nil && currentUserID(c) != "" {
+		h.joiner.MoveUserToRoom(userID, room)
+	}
+
 	// 構建響應
 	response := RoomResponse{
-		ID:          room.ID,
-		Name:        room.Name,
-		Description: room.Description,
-		IsPublic:    room.IsPublic,
-		MaxUsers:    room.MaxUsers,
-		CreatedBy:   room.CreatedBy,
-		ActiveUsers: 0,
+		ID:                room.ID,
+		Name:              room.Name,
+		Description:       room.Description,
+		IsPublic:          room.IsPublic,
+		MaxUsers:          room.MaxUsers,
+		IsListed:          room.IsListed,
+		CreatedBy:         room.CreatedBy,
+		MessageTTLSeconds: room.MessageTTLSeconds,
+		ActiveUsers:       0,
 	}
 
 	c.JSON(http.StatusCreated, response)
 }
 
+// DeleteRoom 刪除聊天室，只有聊天室創建者或管理員可以刪除，聊天室中的連接會收到 room_closed 通知
+func (h *RoomHandler) DeleteRoom(c *gin.Context) {
+	roomID := c.Param("id")
+
+	if err := h.roomService.DeleteRoom(roomID, currentUserID(c)); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRoomNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "聊天室不存在"})
+		case errors.Is(err, service.ErrNotRoomAdmin):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "刪除聊天室失敗"})
+		}
+		return
+	}
+
+	if h.closer != nil {
+		h.closer.CloseRoom(roomID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "聊天室已刪除"})
+}
+
+// KickUser 將用戶移出聊天室，只有聊天室創建者或管理員可以操作
+func (h *RoomHandler) KickUser(c *gin.Context) {
+	h.moderateUser(c, false)
+}
+
+// BanUser 將用戶移出聊天室並禁止其再次加入，只有聊天室創建者或管理員可以操作
+func (h *RoomHandler) BanUser(c *gin.Context) {
+	h.moderateUser(c, true)
+}
+
+// moderateUser 處理移出與封禁請求，成功後強制目標用戶的連接離開並通知聊天室
+func (h *RoomHandler) moderateUser(c *gin.Context, ban bool) {
+	roomID := c.Param("id")
+
+	var request ModerateUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求"})
+		return
+	}
+
+	moderate := h.roomService.KickUser
+	if ban {
+		moderate = h.roomService.BanUser
+	}
+
+	if err := moderate(roomID, request.UserID, currentUserID(c)); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRoomNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "聊天室不存在"})
+		case errors.Is(err, repository.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "用戶不在聊天室中"})
+		case errors.Is(err, service.ErrNotRoomAdmin):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrCannotKickSelf), errors.Is(err, service.ErrCannotKickCreator):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "移出用戶失敗"})
+		}
+		return
+	}
+
+	if h.kicker != nil {
+		h.kicker.RemoveUserFromRoom(roomID, request.UserID, ban)
+	}
+	h.broadcastEvent(roomID, userKickedEvent(roomID, request.UserID, ban))
+
+	message := "用戶已被移出聊天室"
+	if ban {
+		message = "用戶已被封禁"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
 // GetRoomMessages 獲取聊天室的訊息
 func (h *RoomHandler) GetRoomMessages(c *gin.Context) {
 	// 獲取聊天室 ID
 	roomID := c.Param("id")
+	if !h.authorizeRoomRead(c, roomID) {
+		return
+	}
 
 	// 獲取訊息數量限制
-	limitStr := c.DefaultQuery("limit", "50")
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 50
+	page := parsePagination(c)
+
+	// 獲取訊息，提供 before 游標時返回該訊息 ID 之前的較舊訊息
+	var messages []model.Message
+	var err error
+	if before := c.Query("before"); before != "" {
+		beforeID, parseErr := strconv.ParseUint(before, 10, 64)
+		if parseErr != nil || beforeID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "無效的 before 參數"})
+			return
+		}
+		messages, err = h.roomService.GetRoomMessagesBefore(roomID, uint(beforeID), page.Limit)
+	} else {
+		messages, err = h.roomService.GetRoomMessages(roomID, page.Limit)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取訊息失敗"})
+		return
 	}
 
-	// 獲取訊息
-	messages, err := h.roomService.GetRoomMessages(roomID, limit)
+	response, err := historyMessageResponses(h.roomService, messages)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取訊息失敗"})
 		return
 	}
 
-	c.JSON(http.StatusOK, messages)
+	c.JSON(http.StatusOK, response)
 }
 
-// GetRoomUsers 獲取聊天室的用戶
+// MarkRead 將聊天室中目前所有的訊息標記為登入用戶已讀
+func (h *RoomHandler) MarkRead(c *gin.Context) {
+	if err := h.roomService.MarkRead(currentUserID(c), c.Param("id")); err != nil {
+		if errors.Is(err, repository.ErrRoomNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "聊天室不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "標記已讀失敗"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unreadCount": 0})
+}
+
+// SearchMessages 搜尋聊天室中內容包含關鍵字 q 的訊息，最新的在前，支援 limit 參數
+func (h *RoomHandler) SearchMessages(c *gin.Context) {
+	roomID := c.Param("id")
+	if !h.authorizeRoomRead(c, roomID) {
+		return
+	}
+	page := parsePagination(c)
+
+	results, err := h.roomService.SearchMessages(roomID, c.Query("q"), page.Limit)
+	if err != nil {
+		if errors.Is(err, service.ErrEmptySearchQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜尋訊息失敗"})
+		return
+	}
+
+	response := make([]MessageSearchResponse, 0, len(results))
+	for _, result := range results {
+		response = append(response, MessageSearchResponse{
+			ID:          result.ID,
+			UserID:      result.UserID,
+			Username:    result.Username,
+			DisplayName: result.DisplayName,
+			Content:     result.Content,
+			Time:        result.CreatedAt.Unix(),
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SendMessage 以登入用戶的身分發送訊息到聊天室，供無法維持 WebSocket 連接的客戶端（例如機器人或 webhook）使用
+// 訊息保存後會廣播給聊天室中的 WebSocket 客戶端
+func (h *RoomHandler) SendMessage(c *gin.Context) {
+	var request SendMessageRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求"})
+		return
+	}
+	content := strings.TrimSpace(request.Content)
+	if content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrEmptyMessageContent.Error()})
+		return
+	}
+	if h.validator != nil {
+		if err := h.validator.ValidateContent(content); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	user := currentUser(c)
+	roomID := c.Param("id")
+	message, err := h.roomService.SendMessage(roomID, user.ID, content)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRoomNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "聊天室不存在"})
+		case errors.Is(err, service.ErrRoomPrivate), errors.Is(err, service.ErrUserBanned):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "發送訊息失敗"})
+		}
+		return
+	}
+
+	response := ChatMessageResponse{
+		MessageID: message.ID,
+		Content:   message.Content,
+		Sender:    user.Username,
+		Time:      message.CreatedAt.Unix(),
+	}
+	if h.messenger != nil {
+		msg, _ := json.Marshal(response)
+		if err := h.messenger.BroadcastToRoom(roomID, msg); err != nil {
+			fmt.Printf("Error broadcasting message to room %s: %v\n", roomID, err)
+		}
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// EditMessage 編輯訊息，只有訊息作者可以編輯，權限檢查與 WebSocket 的 edit_message 相同
+func (h *RoomHandler) EditMessage(c *gin.Context) {
+	messageID, ok := parseMessageID(c)
+	if !ok {
+		return
+	}
+
+	var request EditMessageRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求"})
+		return
+	}
+	content := strings.TrimSpace(request.Content)
+	if content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrEmptyMessageContent.Error()})
+		return
+	}
+	if h.validator != nil {
+		if err := h.validator.ValidateContent(content); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if !h.requireRoomMessage(c, messageID) {
+		return
+	}
+
+	message, err := h.roomService.EditMessage(messageID, currentUserID(c), content)
+	if err != nil {
+		respondMessageError(c, err, "編輯訊息失敗")
+		return
+	}
+
+	h.broadcastEvent(message.RoomID, messageEditedEvent(message))
+	c.JSON(http.StatusOK, message)
+}
+
+// DeleteMessage 軟刪除訊息，權限檢查與 WebSocket 的 delete_message 相同
+func (h *RoomHandler) DeleteMessage(c *gin.Context) {
+	messageID, ok := parseMessageID(c)
+	if !ok {
+		return
+	}
+
+	if !h.requireRoomMessage(c, messageID) {
+		return
+	}
+
+	message, err := h.roomService.DeleteMessage(messageID, currentUserID(c))
+	if err != nil {
+		respondMessageError(c, err, "刪除訊息失敗")
+		return
+	}
+
+	h.broadcastEvent(message.RoomID, messageDeletedEvent(message))
+	c.JSON(http.StatusOK, gin.H{"message": "訊息已刪除"})
+}
+
+// requireRoomMessage 確認訊息屬於路徑中的聊天室，不存在或屬於其他聊天室時返回 404
+func (h *RoomHandler) requireRoomMessage(c *gin.Context, messageID uint) bool {
+	if _, err := h.roomService.GetMessageInRoom(c.Param("id"), messageID); err != nil {
+		respondMessageError(c, err, "獲取訊息失敗")
+		return false
+	}
+	return true
+}
+
+// DeleteUserMessages 刪除登入用戶在所有聊天室發送的訊息，並通知受影響的聊天室
+func (h *RoomHandler) DeleteUserMessages(c *gin.Context) {
+	messages, err := h.roomService.DeleteMessagesByUser(currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "刪除訊息失敗"})
+		return
+	}
+
+	for i := range messages {
+		h.broadcastEvent(messages[i].RoomID, messageDeletedEvent(&messages[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": len(messages)})
+}
+
+// GetMessageReactions 獲取訊息上各表情的回應數量，供客戶端初次載入時顯示
+func (h *RoomHandler) GetMessageReactions(c *gin.Context) {
+	messageID, ok := parseMessageID(c)
+	if !ok || !h.authorizeRoomRead(c, c.Param("id")) {
+		return
+	}
+
+	counts, err := h.roomService.GetReactionCounts(c.Param("id"), messageID)
+	if err != nil {
+		respondMessageError(c, err, "獲取表情回應失敗")
+		return
+	}
+
+	c.JSON(http.StatusOK, reactionResponses(counts))
+}
+
+// reactionResponses 將表情回應數量轉換為 API 響應格式，沒有回應時返回空陣列
+func reactionResponses(counts []model.ReactionCount) []ReactionResponse {
+	response := make([]ReactionResponse, 0, len(counts))
+	for _, count := range counts {
+		response = append(response, ReactionResponse{
+			Emoji: count.Emoji,
+			Count: count.Count,
+		})
+	}
+	return response
+}
+
+// historyMessageResponses 為歷史訊息附上各表情的回應數量，所有訊息的回應以一次查詢載入，沒有訊息時返回空陣列
+func historyMessageResponses(roomService RoomService, messages []model.Message) ([]HistoryMessageResponse, error) {
+	response := make([]HistoryMessageResponse, 0, len(messages))
+	if len(messages) == 0 {
+		return response, nil
+	}
+
+	counts, err := roomService.GetMessagesReactionCounts(messages)
+	if err != nil {
+		return nil, err
+	}
+	for _, message := range messages {
+		response = append(response, HistoryMessageResponse{
+			Message:   message,
+			Reactions: reactionResponses(counts[message.ID]),
+		})
+	}
+	return response, nil
+}
+
+// parseMessageID 解析路徑中的訊息 ID，無效時返回 400
+func parseMessageID(c *gin.Context) (uint, bool) {
+	messageID, err := strconv.ParseUint(c.Param("msgId"), 10, 64)
+	if err != nil || messageID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的訊息 ID"})
+		return 0, false
+	}
+	return uint(messageID), true
+}
+
+// respondMessageError 將編輯或刪除訊息的錯誤轉換為 HTTP 響應
+func respondMessageError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, repository.ErrMessageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNotMessageAuthor):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrEmptyMessageContent), errors.Is(err, service.ErrInvalidReaction):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// broadcastEvent 向聊天室中所有客戶端發送事件，未設置事件廣播器時略過
+func (h *RoomHandler) broadcastEvent(roomID string, event []byte) {
+	if h.broadcaster == nil || event == nil {
+		return
+	}
+	h.broadcaster.BroadcastEventToRoom(roomID, "", event)
+}
+
+// messageEditedEvent 建立訊息已編輯的 WebSocket 事件
+func messageEditedEvent(message *model.Message) []byte {
+	event, _ := json.Marshal(map[string]interface{}{
+		"type":      "message_edited",
+		"messageId": message.ID,
+		"roomId":    message.RoomID,
+		"content":   message.Content,
+		"time":      message.UpdatedAt.Unix(),
+	})
+	return event
+}
+
+// reactionUpdateEvent 建立訊息表情回應數量已更新的 WebSocket 事件
+func reactionUpdateEvent(roomID string, messageID uint, counts []model.ReactionCount) []byte {
+	event, _ := json.Marshal(map[string]interface{}{
+		"type":      "reaction_update",
+		"messageId": messageID,
+		"roomId":    roomID,
+		"reactions": reactionResponses(counts),
+		"time":      time.Now().Unix(),
+	})
+	return event
+}
+
+// messageDeletedEvent 建立訊息已刪除的 WebSocket 事件
+func messageDeletedEvent(message *model.Message) []byte {
+	event, _ := json.Marshal(map[string]interface{}{
+		"type":      "message_deleted",
+		"messageId": message.ID,
+		"roomId":    message.RoomID,
+		"time":      time.Now().Unix(),
+	})
+	return event
+}
+
+// userKickedEvent 建立用戶被移出聊天室的 WebSocket 事件
+func userKickedEvent(roomID string, userID string, banned bool) []byte {
+	event, _ := json.Marshal(map[string]interface{}{
+		"type":   "user_kicked",
+		"roomId": roomID,
+		"userId": userID,
+		"banned": banned,
+		"time":   time.Now().Unix(),
+	})
+	return event
+}
+
+// GetRoomUsers 獲取聊天室的活躍用戶，包含用戶名稱、角色與加入時間
 func (h *RoomHandler) GetRoomUsers(c *gin.Context) {
 	// 獲取聊天室 ID
 	roomID := c.Param("id")
+	if !h.authorizeRoomRead(c, roomID) {
+		return
+	}
 
 	// 獲取用戶
-	users, err := h.roomService.GetRoomUsers(roomID)
+	users, err := h.roomService.GetRoomUsersWithDetails(roomID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取用戶失敗"})
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	response := make([]RoomUserResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, RoomUserResponse{
+			UserID:       user.UserID,
+			Username:     user.Username,
+			DisplayName:  user.DisplayName,
+			Role:         user.Role,
+			JoinedAt:     user.JoinedAt.Unix(),
+			LastActiveAt: user.LastActiveAt.Unix(),
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetRecentRooms 獲取登入用戶參與過的聊天室，依用戶最近在各聊天室發送訊息或活躍的時間排序，支援分頁
+func (h *RoomHandler) GetRecentRooms(c *gin.Context) {
+	page := parsePagination(c)
+
+	rooms, err := h.roomService.GetRecentRooms(currentUserID(c), page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取最近的聊天室失敗"})
+		return
+	}
+
+	response := make([]RoomResponse, 0, len(rooms))
+	for _, room := range rooms {
+		response = append(response, RoomResponse{
+			ID:                room.ID,
+			Name:              room.Name,
+			Description:       room.Description,
+			IsPublic:          room.IsPublic,
+			MaxUsers:          room.MaxUsers,
+			IsListed:          room.IsListed,
+			CreatedBy:         room.CreatedBy,
+			MessageTTLSeconds: room.MessageTTLSeconds,
+			ActiveUsers:       room.ActiveUsers,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetMembershipHistory 獲取聊天室的成員加入與離開記錄，支援 limit 與 offset 分頁
+func (h *RoomHandler) GetMembershipHistory(c *gin.Context) {
+	// 獲取聊天室 ID
+	roomID := c.Param("id")
+
+	// 獲取分頁參數
+	page := parsePagination(c)
+
+	members, err := h.roomService.GetMembershipHistory(roomID, currentUserID(c), page.Limit, page.Offset)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRoomNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "聊天室不存在"})
+		case errors.Is(err, service.ErrNotRoomAdmin):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取成員記錄失敗"})
+		}
+		return
+	}
+
+	response := make([]MembershipEventResponse, 0, len(members))
+	for _, member := range members {
+		event := MembershipEventResponse{
+			UserID:       member.UserID,
+			Role:         member.Role,
+			JoinedAt:     member.JoinedAt.Unix(),
+			LastActiveAt: member.LastActiveAt.Unix(),
+			IsActive:     member.IsActive,
+		}
+		if !member.IsActive {
+			event.LeftAt = member.UpdatedAt.Unix()
+		}
+		response = append(response, event)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateInvite 為聊天室創建邀請連結
+func (h *RoomHandler) CreateInvite(c *gin.Context) {
+	// 獲取聊天室 ID
+	roomID := c.Param("id")
+
+	// 解析請求（請求體可省略）
+	var request CreateInviteRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求"})
+			return
+		}
+	}
+
+	// 未提供有效期限時交由服務使用預設值
+	ttl := time.Duration(request.ExpiresIn) * time.Second
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	// 創建邀請
+	invite, err := h.roomService.CreateInvite(roomID, currentUserID(c), ttl, request.MaxUses)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRoomNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "聊天室不存在"})
+		case errors.Is(err, service.ErrNotRoomAdmin):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidInviteUses), errors.Is(err, service.ErrInvalidInviteTTL):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "創建邀請失敗"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, InviteResponse{
+		Token:     invite.Token,
+		RoomID:    invite.RoomID,
+		ExpiresAt: invite.ExpiresAt.Unix(),
+		MaxUses:   invite.MaxUses,
+		Uses:      invite.Uses,
+	})
+}
+
+// AcceptInvite 使用邀請碼加入聊天室
+func (h *RoomHandler) AcceptInvite(c *gin.Context) {
+	// 獲取邀請碼
+	token := c.Param("token")
+
+	// 使用邀請
+	room, err := h.roomService.AcceptInvite(token, currentUserID(c))
+	if err != nil {
+		status := inviteErrorStatus(err)
+		c.JSON(status, gin.H{"error": inviteErrorMessage(err, status)})
+		return
+	}
+
+	c.JSON(http.StatusOK, RoomResponse{
+		ID:                room.ID,
+		Name:              room.Name,
+		Description:       room.Description,
+		IsPublic:          room.IsPublic,
+		MaxUsers:          room.MaxUsers,
+		IsListed:          room.IsListed,
+		CreatedBy:         room.CreatedBy,
+		MessageTTLSeconds: room.MessageTTLSeconds,
+	})
+}
+
+// inviteErrorStatus 將使用邀請的錯誤對應到 HTTP 狀態碼
+func inviteErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrInviteNotFound), errors.Is(err, repository.ErrRoomNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInviteExpired), errors.Is(err, repository.ErrInviteExhausted):
+		return http.StatusGone
+	case errors.Is(err, service.ErrUserBanned):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrRoomFull):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// inviteErrorMessage 返回使用邀請失敗時回覆給客戶端的錯誤訊息，未預期的錯誤不透露內部細節
+func inviteErrorMessage(err error, status int) string {
+	if status == http.StatusInternalServerError {
+		return "使用邀請失敗"
+	}
+	return err.Error()
 }
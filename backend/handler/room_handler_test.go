@@ -3,16 +3,24 @@ package handler
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"livechat/backend/middleware"
 	"livechat/backend/model"
+	"livechat/backend/repository"
 	"livechat/backend/service"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 )
 
 // MockRoomService 是一個模擬的聊天室服務
@@ -28,9 +36,17 @@ func (m *MockRoomService) GetRoom(roomID string) (*model.Room, error) {
 	return args.Get(0).(*model.Room), args.Error(1)
 }
 
-func (m *MockRoomService) GetAllRooms() ([]model.Room, error) {
-	args := m.Called()
-	return args.Get(0).([]model.Room), args.Error(1)
+func (m *MockRoomService) GetRoomByName(name string) (*model.Room, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Room), args.Error(1)
+}
+
+func (m *MockRoomService) GetAllRoomsWithActiveCounts(order repository.RoomOrder, filter repository.RoomFilter) ([]model.RoomWithActiveCount, int64, error) {
+	args := m.Called(order, filter)
+	return args.Get(0).([]model.RoomWithActiveCount), args.Get(1).(int64), args.Error(2)
 }
 
 func (m *MockRoomService) CreateRoom(data service.RoomData, createdBy string) (*model.Room, error) {
@@ -41,6 +57,11 @@ func (m *MockRoomService) CreateRoom(data service.RoomData, createdBy string) (*
 	return args.Get(0).(*model.Room), args.Error(1)
 }
 
+func (m *MockRoomService) DeleteRoom(roomID string, requesterID string) error {
+	args := m.Called(roomID, requesterID)
+	return args.Error(0)
+}
+
 func (m *MockRoomService) JoinRoom(roomID string, userID string, role string) error {
 	args := m.Called(roomID, userID, role)
 	return args.Error(0)
@@ -56,11 +77,144 @@ func (m *MockRoomService) GetRoomMessages(roomID string, limit int) ([]model.Mes
 	return args.Get(0).([]model.Message), args.Error(1)
 }
 
-func (m *MockRoomService) SendMessage(roomID string, userID string, content string) error {
+func (m *MockRoomService) GetRoomMessagesBefore(roomID string, beforeID uint, limit int) ([]model.Message, error) {
+	args := m.Called(roomID, beforeID, limit)
+	return args.Get(0).([]model.Message), args.Error(1)
+}
+
+func (m *MockRoomService) SearchMessages(roomID string, query string, limit int) ([]model.MessageSearchResult, error) {
+	args := m.Called(roomID, query, limit)
+	return args.Get(0).([]model.MessageSearchResult), args.Error(1)
+}
+
+func (m *MockRoomService) IsMember(roomID string, userID string) (bool, error) {
+	args := m.Called(roomID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRoomService) IsActiveMember(roomID string, userID string) (bool, error) {
+	args := m.Called(roomID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRoomService) CanReadRoom(roomID string, userID string) (bool, error) {
+	args := m.Called(roomID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRoomService) GetMembershipHistory(roomID string, requesterID string, limit int, offset int) ([]model.RoomUser, error) {
+	args := m.Called(roomID, requesterID, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.RoomUser), args.Error(1)
+}
+
+func (m *MockRoomService) GetRecentRooms(userID string, limit int, offset int) ([]model.RoomWithActiveCount, error) {
+	args := m.Called(userID, limit, offset)
+	return args.Get(0).([]model.RoomWithActiveCount), args.Error(1)
+}
+
+func (m *MockRoomService) MarkRead(userID string, roomID string) error {
+	args := m.Called(userID, roomID)
+	return args.Error(0)
+}
+
+func (m *MockRoomService) GetUnreadCount(userID string, roomID string) (int64, error) {
+	args := m.Called(userID, roomID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRoomService) GetUnreadCounts(userID string, roomIDs []string) (map[string]int64, error) {
+	args := m.Called(userID, roomIDs)
+	return args.Get(0).(map[string]int64), args.Error(1)
+}
+
+func (m *MockRoomService) SendMessage(roomID string, userID string, content string) (*model.Message, error) {
 	args := m.Called(roomID, userID, content)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Message), args.Error(1)
+}
+
+func (m *MockRoomService) GetMessageInRoom(roomID string, messageID uint) (*model.Message, error) {
+	args := m.Called(roomID, messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Message), args.Error(1)
+}
+
+func (m *MockRoomService) EditMessage(messageID uint, userID string, newContent string) (*model.Message, error) {
+	args := m.Called(messageID, userID, newContent)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Message), args.Error(1)
+}
+
+func (m *MockRoomService) DeleteMessage(messageID uint, userID string) (*model.Message, error) {
+	args := m.Called(messageID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Message), args.Error(1)
+}
+
+func (m *MockRoomService) DeleteMessagesByUser(userID string) ([]model.Message, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Message), args.Error(1)
+}
+
+func (m *MockRoomService) ReleaseOwnedRooms(userID string) ([]model.Room, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Room), args.Error(1)
+}
+
+func (m *MockRoomService) LeaveAllRooms(userID string) error {
+	args := m.Called(userID)
 	return args.Error(0)
 }
 
+func (m *MockRoomService) AddReaction(roomID string, messageID uint, userID string, emoji string) ([]model.ReactionCount, error) {
+	args := m.Called(roomID, messageID, userID, emoji)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.ReactionCount), args.Error(1)
+}
+
+func (m *MockRoomService) RemoveReaction(roomID string, messageID uint, userID string, emoji string) ([]model.ReactionCount, error) {
+	args := m.Called(roomID, messageID, userID, emoji)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.ReactionCount), args.Error(1)
+}
+
+func (m *MockRoomService) GetReactionCounts(roomID string, messageID uint) ([]model.ReactionCount, error) {
+	args := m.Called(roomID, messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.ReactionCount), args.Error(1)
+}
+
+func (m *MockRoomService) GetMessagesReactionCounts(messages []model.Message) (map[uint][]model.ReactionCount, error) {
+	args := m.Called(messages)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uint][]model.ReactionCount), args.Error(1)
+}
+
 func (m *MockRoomService) SendSystemMessage(roomID string, content string) error {
 	args := m.Called(roomID, content)
 	return args.Error(0)
@@ -71,9 +225,35 @@ func (m *MockRoomService) GetRoomActiveUserCount(roomID string) (int64, error) {
 	return args.Get(0).(int64), args.Error(1)
 }
 
-func (m *MockRoomService) GetRoomUsers(roomID string) ([]model.RoomUser, error) {
+func (m *MockRoomService) GetRoomUsersWithDetails(roomID string) ([]model.RoomUserDetail, error) {
 	args := m.Called(roomID)
-	return args.Get(0).([]model.RoomUser), args.Error(1)
+	return args.Get(0).([]model.RoomUserDetail), args.Error(1)
+}
+
+func (m *MockRoomService) CreateInvite(roomID string, requesterID string, ttl time.Duration, maxUses int) (*model.RoomInvite, error) {
+	args := m.Called(roomID, requesterID, ttl, maxUses)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RoomInvite), args.Error(1)
+}
+
+func (m *MockRoomService) AcceptInvite(token string, userID string) (*model.Room, error) {
+	args := m.Called(token, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Room), args.Error(1)
+}
+
+func (m *MockRoomService) KickUser(roomID string, targetUserID string, moderatorID string) error {
+	args := m.Called(roomID, targetUserID, moderatorID)
+	return args.Error(0)
+}
+
+func (m *MockRoomService) BanUser(roomID string, targetUserID string, moderatorID string) error {
+	args := m.Called(roomID, targetUserID, moderatorID)
+	return args.Error(0)
 }
 
 // 設置 Gin 測試環境
@@ -92,15 +272,13 @@ func TestGetAllRooms(t *testing.T) {
 	handler.RegisterRoutes(router)
 
 	// 模擬數據
-	rooms := []model.Room{
-		{ID: "1", Name: "聊天室1", Description: "描述1", CreatedAt: time.Now(), UpdatedAt: time.Now()},
-		{ID: "2", Name: "聊天室2", Description: "描述2", CreatedAt: time.Now(), UpdatedAt: time.Now()},
+	rooms := []model.RoomWithActiveCount{
+		{Room: model.Room{ID: "1", Name: "聊天室1", Description: "描述1", CreatedAt: time.Now(), UpdatedAt: time.Now()}, ActiveUsers: 5},
+		{Room: model.Room{ID: "2", Name: "聊天室2", Description: "描述2", CreatedAt: time.Now(), UpdatedAt: time.Now()}, ActiveUsers: 3},
 	}
 
-	// 設置模擬行為
-	mockService.On("GetAllRooms").Return(rooms, nil)
-	mockService.On("GetRoomActiveUserCount", "1").Return(int64(5), nil)
-	mockService.On("GetRoomActiveUserCount", "2").Return(int64(3), nil)
+	// 設置模擬行為：活躍用戶數隨列表一併取得，不應逐一查詢
+	mockService.On("GetAllRoomsWithActiveCounts", repository.RoomOrderActivity, repository.RoomFilter{PublicOnly: true}).Return(rooms, int64(2), nil)
 
 	// 創建請求
 	req, _ := http.NewRequest("GET", "/api/rooms", nil)
@@ -120,6 +298,7 @@ func TestGetAllRooms(t *testing.T) {
 	assert.Equal(t, int64(5), response[0].ActiveUsers, "第一個聊天室的活躍用戶數應該匹配")
 
 	mockService.AssertExpectations(t)
+	mockService.AssertNotCalled(t, "GetRoomActiveUserCount", mock.Anything)
 }
 
 // 測試獲取特定聊天室
@@ -165,6 +344,35 @@ func TestGetRoom(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+// 測試公開聊天室列表不包含私人聊天室
+func TestGetAllRoomsHidesPrivateRooms(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	roomService := service.NewRoomService(roomRepo)
+	assert.NoError(t, roomRepo.CreateRoom(&model.Room{ID: "public-1", Name: "公開聊天室", IsPublic: true, IsActive: true, IsListed: true}))
+	assert.NoError(t, roomRepo.CreateRoom(&model.Room{ID: "private-1", Name: "私人聊天室", IsPublic: false, IsActive: true, IsListed: true}))
+
+	handler := NewRoomHandler(roomService)
+	router := setupRouter()
+	handler.RegisterRoutes(router)
+
+	req, _ := http.NewRequest("GET", "/api/rooms", nil)
+	w := httptest.NewRecorder()
+
+	// 動作 (Act)
+	router.ServeHTTP(w, req)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+
+	var response []RoomResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+	if assert.Len(t, response, 1, "列表中應該只有公開聊天室") {
+		assert.Equal(t, "public-1", response[0].ID, "列表中應該只有公開聊天室")
+	}
+}
+
 // 測試創建聊天室
 func TestCreateRoom(t *testing.T) {
 	// 安排 (Arrange)
@@ -174,10 +382,11 @@ func TestCreateRoom(t *testing.T) {
 	handler.RegisterRoutes(router)
 
 	// 模擬數據
+	isPublic := true
 	request := CreateRoomRequest{
 		Name:        "新聊天室",
 		Description: "這是一個新的聊天室",
-		IsPublic:    true,
+		IsPublic:    &isPublic,
 		MaxUsers:    100,
 	}
 
@@ -215,74 +424,1266 @@ func TestCreateRoom(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
-// 測試獲取聊天室訊息
-func TestGetRoomMessages(t *testing.T) {
+// 測試創建聊天室後自動將創建者的 WebSocket 連接移入新聊天室
+//
+// 測試目標：
+// 1. 啟用時，創建者的連接收到 room_created 通知並加入新聊天室
+// 2. 其他用戶的連接不受影響
+// 3. 未啟用時，創建者的連接保持不變
+func TestCreateRoomAutoJoinsCreator(t *testing.T) {
+	setup := func(autoJoin bool) (*gin.Engine, *model.Client, *model.Client, *MockBroadcastService) {
+		mockService := new(MockRoomService)
+		mockBroadcastService := new(MockBroadcastService)
+		mockLogger := new(MockLogger)
+		mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+		creatorSocket := &model.Client{ID: "creator-socket", UserID: "creator-1", UserName: "Alice"}
+		otherSocket := &model.Client{ID: "other-socket", UserID: "other-user", UserName: "Bob"}
+
+		room := &model.Room{ID: "new-room", Name: "新聊天室", CreatedBy: "creator-1"}
+		mockService.On("CreateRoom", mock.AnythingOfType("service.RoomData"), "creator-1").Return(room, nil)
+		mockService.On("GetRoomActiveUserCount", "new-room").Return(int64(0), nil)
+		mockService.On("JoinRoom", "new-room", "creator-1", "member").Return(nil)
+		mockService.On("GetRoomMessages", "new-room", mock.Anything).Return([]model.Message{}, nil)
+		mockBroadcastService.On("GetClientsByUser", "creator-1").Return([]*model.Client{creatorSocket})
+		mockBroadcastService.On("GetClientsInRoom", "new-room").Return([]*model.Client{creatorSocket})
+		mockBroadcastService.On("SendPrivateMessage", "creator-socket", mock.Anything).Return(nil)
+		mockBroadcastService.On("BroadcastToRoom", "new-room", mock.Anything).Return(nil)
+
+		wsHandler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRoomService(mockService))
+		var opts []RoomHandlerOption
+		if autoJoin {
+			opts = append(opts, WithCreatorAutoJoin(wsHandler))
+		}
+		handler := NewRoomHandler(mockService, opts...)
+		router := setupRoomRouterWithUser("creator-1")
+		handler.RegisterRoutes(router)
+		return router, creatorSocket, otherSocket, mockBroadcastService
+	}
+
+	createRoom := func(router *gin.Engine) int {
+		req, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBufferString(`{"name":"新聊天室"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("啟用自動加入", func(t *testing.T) {
+		// 安排 (Arrange)
+		router, creatorSocket, otherSocket, mockBroadcastService := setup(true)
+
+		// 動作 (Act)
+		code := createRoom(router)
+
+		// 斷言 (Assert)
+		assert.Equal(t, http.StatusCreated, code, "狀態碼應該是 201")
+		assert.Equal(t, "new-room", creatorSocket.RoomID, "創建者的連接應該加入新聊天室")
+		assert.Equal(t, "", otherSocket.RoomID, "其他用戶的連接不應該受影響")
+
+		var notice map[string]interface{}
+		for _, call := range mockBroadcastService.Calls {
+			var message map[string]interface{}
+			if call.Method == "SendPrivateMessage" && json.Unmarshal(call.Arguments.Get(1).([]byte), &message) == nil && message["type"] == "room_created" {
+				notice = message
+			}
+		}
+		assert.Equal(t, "room_created", notice["type"], "創建者應該收到 room_created 通知")
+		assert.Equal(t, true, notice["autoJoin"], "通知應該標示自動加入")
+		assert.Equal(t, "new-room", notice["room"].(map[string]interface{})["id"], "通知應該包含新聊天室")
+	})
+
+	t.Run("未啟用自動加入", func(t *testing.T) {
+		// 安排 (Arrange)
+		router, creatorSocket, _, mockBroadcastService := setup(false)
+
+		// 動作 (Act)
+		code := createRoom(router)
+
+		// 斷言 (Assert)
+		assert.Equal(t, http.StatusCreated, code, "狀態碼應該是 201")
+		assert.Equal(t, "", creatorSocket.RoomID, "未啟用時創建者的連接不應該被移動")
+		mockBroadcastService.AssertNotCalled(t, "GetClientsByUser", mock.Anything)
+	})
+}
+
+// 測試創建聊天室時 isListed 的預設值與明確設定
+func TestCreateRoomIsListed(t *testing.T) {
 	// 安排 (Arrange)
 	mockService := new(MockRoomService)
 	handler := NewRoomHandler(mockService)
 	router := setupRouter()
 	handler.RegisterRoutes(router)
 
-	// 模擬數據
-	messages := []model.Message{
-		{RoomID: "1", UserID: "user-1", Content: "訊息1"},
-		{RoomID: "1", UserID: "user-2", Content: "訊息2"},
-	}
-
-	// 設置模擬行為
-	mockService.On("GetRoomMessages", "1", 50).Return(messages, nil)
+	room := &model.Room{ID: "1", Name: "隱藏聊天室", IsListed: false}
 
-	// 創建請求
-	req, _ := http.NewRequest("GET", "/api/rooms/1/messages", nil)
-	w := httptest.NewRecorder()
+	// 設置模擬行為：未提供 isListed 時應預設為 true
+	mockService.On("CreateRoom", mock.MatchedBy(func(data service.RoomData) bool {
+		return data.Name == "預設聊天室" && data.IsListed
+	}), "system").Return(&model.Room{ID: "2", Name: "預設聊天室", IsListed: true}, nil)
+	mockService.On("CreateRoom", mock.MatchedBy(func(data service.RoomData) bool {
+		return data.Name == "隱藏聊天室" && !data.IsListed
+	}), "system").Return(room, nil)
 
 	// 動作 (Act)
+	req, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBufferString(`{"name":"預設聊天室"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
+	req2, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBufferString(`{"name":"隱藏聊天室","isListed":false}`))
+	req2.Header.Set("Content-Type", "application/json")
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+
 	// 斷言 (Assert)
-	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+	assert.Equal(t, http.StatusCreated, w.Code, "狀態碼應該是 201")
+	assert.Equal(t, http.StatusCreated, w2.Code, "狀態碼應該是 201")
 
-	var response []model.Message
-	err := json.Unmarshal(w.Body.Bytes(), &response)
+	var response RoomResponse
+	err := json.Unmarshal(w2.Body.Bytes(), &response)
 	assert.NoError(t, err, "應該能夠解析響應")
-	assert.Equal(t, 2, len(response), "應該有 2 條訊息")
-	assert.Equal(t, "訊息1", response[0].Content, "第一條訊息的內容應該匹配")
+	assert.False(t, response.IsListed, "聊天室應該不列出")
 
 	mockService.AssertExpectations(t)
 }
 
-// 測試獲取聊天室用戶
-func TestGetRoomUsers(t *testing.T) {
+// 測試以 order 參數指定聊天室排序
+func TestGetAllRoomsOrder(t *testing.T) {
 	// 安排 (Arrange)
 	mockService := new(MockRoomService)
 	handler := NewRoomHandler(mockService)
 	router := setupRouter()
 	handler.RegisterRoutes(router)
 
-	// 模擬數據
-	users := []model.RoomUser{
-		{RoomID: "1", UserID: "user-1", IsActive: true},
-		{RoomID: "1", UserID: "user-2", IsActive: true},
+	mockService.On("GetAllRoomsWithActiveCounts", repository.RoomOrderName, repository.RoomFilter{PublicOnly: true}).Return([]model.RoomWithActiveCount{}, int64(0), nil)
+	mockService.On("GetAllRoomsWithActiveCounts", repository.RoomOrder("unknown"), repository.RoomFilter{PublicOnly: true}).Return([]model.RoomWithActiveCount(nil), int64(0), repository.ErrInvalidRoomOrder)
+
+	// 動作 (Act)
+	req, _ := http.NewRequest("GET", "/api/rooms?order=name", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	req2, _ := http.NewRequest("GET", "/api/rooms?order=unknown", nil)
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, w.Code, "有效的排序方式狀態碼應該是 200")
+	assert.Equal(t, http.StatusBadRequest, w2.Code, "無效的排序方式狀態碼應該是 400")
+	mockService.AssertExpectations(t)
+}
+
+// 測試聊天室列表的搜尋與分頁參數
+func TestGetAllRoomsSearchAndPaging(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockRoomService)
+	handler := NewRoomHandler(mockService)
+	router := setupRouter()
+	handler.RegisterRoutes(router)
+
+	filter := repository.RoomFilter{Query: "go", PublicOnly: true, Limit: 10, Offset: 20}
+	mockService.On("GetAllRoomsWithActiveCounts", repository.RoomOrderActivity, filter).Return([]model.RoomWithActiveCount{{Room: model.Room{ID: "1", Name: "golang"}}}, int64(21), nil)
+
+	// 動作 (Act)
+	req, _ := http.NewRequest("GET", "/api/rooms?q=go&page=3&pageSize=10", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	req2, _ := http.NewRequest("GET", "/api/rooms?public=false", nil)
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+	assert.Equal(t, "21", w.Header().Get("X-Total-Count"), "應該在標頭返回符合條件的總數")
+	assert.Equal(t, http.StatusBadRequest, w2.Code, "私人聊天室不能被列出")
+	mockService.AssertExpectations(t)
+}
+
+// 測試以 sort 參數指定聊天室排序
+//
+// 測試資料：
+// - room-a：名稱最前，沒有訊息，1 位活躍成員
+// - room-b：3 位活躍成員，較早有訊息
+// - room-c：沒有活躍成員，最近有訊息
+func TestGetAllRoomsSort(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	handler := NewRoomHandler(service.NewRoomService(roomRepo))
+	router := setupRouter()
+	handler.RegisterRoutes(router)
+
+	base := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	for _, room := range []*model.Room{
+		{ID: "room-b", Name: "B 聊天室", IsPublic: true, IsActive: true, IsListed: true, CreatedAt: base},
+		{ID: "room-c", Name: "C 聊天室", IsPublic: true, IsActive: true, IsListed: true, CreatedAt: base.Add(time.Hour)},
+		{ID: "room-a", Name: "A 聊天室", IsPublic: true, IsActive: true, IsListed: true, CreatedAt: base.Add(2 * time.Hour)},
+	} {
+		assert.NoError(t, roomRepo.CreateRoom(room), "創建聊天室不應該失敗")
+	}
+	assert.NoError(t, roomRepo.JoinRoom("room-a", "user-1", "member"))
+	for _, userID := range []string{"user-1", "user-2", "user-3"} {
+		assert.NoError(t, roomRepo.JoinRoom("room-b", userID, "member"))
+	}
+	for _, message := range []model.Message{
+		{RoomID: "room-b", UserID: "user-1", Content: "較早的訊息", Model: gorm.Model{CreatedAt: base.Add(3 * time.Hour)}},
+		{RoomID: "room-c", UserID: "user-1", Content: "最新的訊息", Model: gorm.Model{CreatedAt: base.Add(5 * time.Hour)}},
+	} {
+		assert.NoError(t, mockDB.DB.Create(&message).Error, "插入測試訊息不應該失敗")
 	}
 
-	// 設置模擬行為
-	mockService.On("GetRoomUsers", "1").Return(users, nil)
+	testCases := []struct {
+		sort           string
+		expectedStatus int
+		expected       []string
+	}{
+		{sort: "active", expectedStatus: http.StatusOK, expected: []string{"room-b", "room-a", "room-c"}},
+		{sort: "recent", expectedStatus: http.StatusOK, expected: []string{"room-c", "room-b", "room-a"}},
+		{sort: "name", expectedStatus: http.StatusOK, expected: []string{"room-a", "room-b", "room-c"}},
+		{sort: "unknown", expectedStatus: http.StatusBadRequest},
+	}
 
-	// 創建請求
-	req, _ := http.NewRequest("GET", "/api/rooms/1/users", nil)
+	for _, tc := range testCases {
+		t.Run(tc.sort, func(t *testing.T) {
+			// 動作 (Act)
+			req, _ := http.NewRequest("GET", "/api/rooms?sort="+tc.sort, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.expectedStatus, w.Code, "狀態碼應該匹配")
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			var response []RoomResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+			ids := make([]string, 0, len(response))
+			for _, room := range response {
+				ids = append(ids, room.ID)
+			}
+			assert.Equal(t, tc.expected, ids, "聊天室順序應該匹配")
+		})
+	}
+}
+
+// 測試創建聊天室時設置訊息 TTL，負數的 TTL 會被拒絕
+func TestCreateRoomMessageTTL(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockRoomService)
+	handler := NewRoomHandler(mockService)
+	router := setupRouter()
+	handler.RegisterRoutes(router)
+
+	room := &model.Room{ID: "1", Name: "短暫聊天室", MessageTTLSeconds: 300}
+	mockService.On("CreateRoom", mock.MatchedBy(func(data service.RoomData) bool {
+		return data.MessageTTLSeconds == 300
+	}), "system").Return(room, nil)
+
+	// 動作 (Act)
+	req, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBufferString(`{"name":"短暫聊天室","messageTtlSeconds":300}`))
+	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	req2, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBufferString(`{"name":"無效聊天室","messageTtlSeconds":-1}`))
+	req2.Header.Set("Content-Type", "application/json")
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusCreated, w.Code, "狀態碼應該是 201")
+	var response RoomResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+	assert.Equal(t, 300, response.MessageTTLSeconds, "響應應該包含訊息 TTL")
+
+	assert.Equal(t, http.StatusBadRequest, w2.Code, "負數的 TTL 應該返回 400")
+	mockService.AssertExpectations(t)
+}
+
+// 測試達到聊天室數量上限時創建聊天室
+func TestCreateRoomCapacityReached(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockRoomService)
+	handler := NewRoomHandler(mockService)
+	router := setupRouter()
+	handler.RegisterRoutes(router)
+
+	mockService.On("CreateRoom", mock.MatchedBy(func(data service.RoomData) bool {
+		return !data.BypassRoomLimit
+	}), "system").Return(nil, service.ErrRoomCapacityReached)
 
 	// 動作 (Act)
+	req, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBufferString(`{"name":"新聊天室"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
 	// 斷言 (Assert)
-	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "達到上限時狀態碼應該是 503")
+	mockService.AssertExpectations(t)
+}
 
-	var response []model.RoomUser
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err, "應該能夠解析響應")
-	assert.Equal(t, 2, len(response), "應該有 2 個用戶")
-	assert.Equal(t, "user-1", response[0].UserID, "第一個用戶的 ID 應該匹配")
+// 測試管理員創建聊天室時略過數量上限
+func TestCreateRoomAdminBypassesCapacity(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockRoomService)
+	handler := NewRoomHandler(mockService)
+	router := setupRouter()
+	router.Use(func(c *gin.Context) {
+		middleware.SetCurrentUser(c, &middleware.UserResponse{ID: "admin-1", Username: "admin", Role: "admin"})
+		c.Next()
+	})
+	handler.RegisterRoutes(router)
 
+	mockService.On("CreateRoom", mock.MatchedBy(func(data service.RoomData) bool {
+		return data.BypassRoomLimit
+	}), "admin-1").Return(&model.Room{ID: "1", Name: "新聊天室", IsListed: true}, nil)
+
+	// 動作 (Act)
+	req, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBufferString(`{"name":"新聊天室"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusCreated, w.Code, "管理員應該能創建聊天室")
 	mockService.AssertExpectations(t)
 }
+
+// 測試獲取聊天室訊息
+func TestGetRoomMessages(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockRoomService)
+	handler := NewRoomHandler(mockService)
+	router := setupRouter()
+	handler.RegisterRoutes(router)
+
+	// 模擬數據
+	messages := []model.Message{
+		{Model: gorm.Model{ID: 1}, RoomID: "1", UserID: "user-1", Content: "訊息1"},
+		{Model: gorm.Model{ID: 2}, RoomID: "1", UserID: "user-2", Content: "訊息2"},
+	}
+
+	// 設置模擬行為
+	mockService.On("CanReadRoom", "1", "").Return(true, nil)
+	mockService.On("GetRoomMessages", "1", 50).Return(messages, nil)
+	mockService.On("GetMessagesReactionCounts", messages).Return(map[uint][]model.ReactionCount{
+		1: {{Emoji: "👍", Count: 2}},
+	}, nil)
+
+	// 創建請求
+	req, _ := http.NewRequest("GET", "/api/rooms/1/messages", nil)
+	w := httptest.NewRecorder()
+
+	// 動作 (Act)
+	router.ServeHTTP(w, req)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+
+	var response []HistoryMessageResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err, "應該能夠解析響應")
+	assert.Equal(t, 2, len(response), "應該有 2 條訊息")
+	assert.Equal(t, "訊息1", response[0].Content, "第一條訊息的內容應該匹配")
+	assert.Equal(t, []ReactionResponse{{Emoji: "👍", Count: 2}}, response[0].Reactions, "訊息應該附上表情回應數量")
+	assert.Empty(t, response[1].Reactions, "沒有回應的訊息應該返回空陣列")
+
+	mockService.AssertExpectations(t)
+}
+
+// 測試在聊天室中搜尋訊息
+func TestSearchMessages(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	roomService := service.NewRoomService(roomRepo)
+	assert.NoError(t, roomRepo.CreateRoom(&model.Room{ID: "room-1", Name: "測試聊天室", IsPublic: true, IsActive: true, IsListed: true}))
+	assert.NoError(t, mockDB.DB.Create(&model.User{ID: "user-1", Username: "alice", Email: "alice@example.com", Password: "hash"}).Error)
+	assert.NoError(t, roomRepo.SaveMessage(&model.Message{RoomID: "room-1", UserID: "user-1", Content: "Hello World"}))
+	assert.NoError(t, roomRepo.SaveMessage(&model.Message{RoomID: "room-1", UserID: "deleted-user", Content: "hello again"}))
+	assert.NoError(t, roomService.SendSystemMessage("room-1", "hello 系統訊息"))
+
+	handler := NewRoomHandler(roomService)
+	router := setupRouter()
+	handler.RegisterRoutes(router)
+	search := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/rooms/room-1/messages/search?q="+url.QueryEscape(query), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 動作 (Act)
+	w := search("HELLO")
+	emptyW := search("  ")
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+	var response []MessageSearchResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+	if assert.Len(t, response, 2, "應該返回不區分大小寫的匹配且排除系統訊息") {
+		assert.Equal(t, "hello again", response[0].Content, "最新的訊息應該在前")
+		assert.Equal(t, service.AnonymousUserName, response[0].Username, "找不到作者時應該以匿名用戶顯示")
+		assert.Equal(t, "alice", response[1].Username, "應該包含作者的用戶名稱")
+		assert.NotZero(t, response[1].Time, "應該包含訊息時間")
+	}
+	assert.Equal(t, http.StatusBadRequest, emptyW.Code, "空白的關鍵字應該返回 400")
+}
+
+// 測試系統訊息在訊息列表中以系統用戶為發送者呈現
+func TestGetRoomMessagesSystemMessage(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	roomService := service.NewRoomService(roomRepo)
+	assert.NoError(t, roomRepo.CreateRoom(&model.Room{ID: "room-1", Name: "測試聊天室", IsPublic: true, IsActive: true, IsListed: true}))
+	assert.NoError(t, roomService.SendSystemMessage("room-1", "使用者 Alice 已加入聊天室"))
+
+	handler := NewRoomHandler(roomService)
+	router := setupRouter()
+	handler.RegisterRoutes(router)
+
+	req, _ := http.NewRequest("GET", "/api/rooms/room-1/messages", nil)
+	w := httptest.NewRecorder()
+
+	// 動作 (Act)
+	router.ServeHTTP(w, req)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+
+	var response []model.Message
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+	if assert.Len(t, response, 1, "應該有 1 條訊息") {
+		assert.True(t, response[0].IsSystemMessage, "應該標記為系統訊息")
+		assert.Equal(t, model.SystemUserID, response[0].UserID, "系統訊息的發送者應該是系統用戶")
+		assert.Equal(t, "使用者 Alice 已加入聊天室", response[0].Content, "訊息內容應該匹配")
+	}
+}
+
+// 測試以 before 游標分頁瀏覽聊天室的歷史訊息
+//
+// 測試目標：
+// 1. 120 條訊息以每頁 50 條分三頁取得，每頁最新的在前且不重複
+// 2. 沒有更舊的訊息時返回空陣列而非錯誤
+// 3. 無效的 before 參數返回 400
+func TestGetRoomMessagesBeforeCursor(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	roomService := service.NewRoomService(roomRepo)
+	assert.NoError(t, roomRepo.CreateRoom(&model.Room{ID: "room-1", Name: "測試聊天室", IsPublic: true, IsActive: true, IsListed: true}))
+	for i := 1; i <= 120; i++ {
+		assert.NoError(t, roomRepo.SaveMessage(&model.Message{RoomID: "room-1", UserID: "user-1", Content: fmt.Sprintf("訊息%d", i)}))
+	}
+
+	handler := NewRoomHandler(roomService)
+	router := setupRouter()
+	handler.RegisterRoutes(router)
+
+	fetch := func(query string) (int, []model.Message) {
+		req, _ := http.NewRequest("GET", "/api/rooms/room-1/messages?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response []model.Message
+		if w.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+			assert.NotNil(t, response, "響應應該是陣列而不是 null")
+		}
+		return w.Code, response
+	}
+
+	// 動作 (Act)
+	var pages [][]model.Message
+	query := "limit=50"
+	for {
+		code, page := fetch(query)
+		assert.Equal(t, http.StatusOK, code, "狀態碼應該是 200")
+		pages = append(pages, page)
+		if len(page) == 0 {
+			break
+		}
+		query = fmt.Sprintf("limit=50&before=%d", page[len(page)-1].ID)
+	}
+
+	// 斷言 (Assert)
+	if assert.Len(t, pages, 4, "應該有三頁訊息加上一個空頁") {
+		assert.Len(t, pages[0], 50, "第一頁應該有 50 條訊息")
+		assert.Len(t, pages[1], 50, "第二頁應該有 50 條訊息")
+		assert.Len(t, pages[2], 20, "第三頁應該有剩餘的 20 條訊息")
+		assert.Empty(t, pages[3], "沒有更舊的訊息時應該返回空陣列")
+		assert.Equal(t, "訊息120", pages[0][0].Content, "第一頁應該從最新訊息開始")
+		assert.Equal(t, "訊息70", pages[1][0].Content, "第二頁應該接續第一頁")
+		assert.Equal(t, "訊息1", pages[2][19].Content, "最後一頁應該結束於最舊的訊息")
+	}
+
+	seen := make(map[uint]bool)
+	for _, page := range pages {
+		for i, message := range page {
+			assert.False(t, seen[message.ID], "訊息不應該在多個頁面重複出現")
+			seen[message.ID] = true
+			if i > 0 {
+				assert.Greater(t, page[i-1].ID, message.ID, "每頁的訊息應該最新的在前")
+			}
+		}
+	}
+	assert.Len(t, seen, 120, "所有訊息都應該被取得")
+
+	for _, invalid := range []string{"before=abc", "before=0", "before=-1"} {
+		code, _ := fetch(invalid)
+		assert.Equal(t, http.StatusBadRequest, code, "無效的 before 參數應該返回 400：%s", invalid)
+	}
+}
+
+// 測試獲取聊天室用戶
+func TestGetRoomUsers(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockRoomService)
+	handler := NewRoomHandler(mockService)
+	router := setupRouter()
+	handler.RegisterRoutes(router)
+
+	// 模擬數據
+	joinedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	users := []model.RoomUserDetail{
+		{UserID: "user-1", Username: "alice", DisplayName: "Alice", Role: "admin", JoinedAt: joinedAt, LastActiveAt: joinedAt},
+		{UserID: "user-2", Username: "bob", Role: "member", JoinedAt: joinedAt.Add(time.Minute), LastActiveAt: joinedAt.Add(time.Minute)},
+	}
+
+	// 設置模擬行為
+	mockService.On("CanReadRoom", "1", "").Return(true, nil)
+	mockService.On("GetRoomUsersWithDetails", "1").Return(users, nil)
+
+	// 創建請求
+	req, _ := http.NewRequest("GET", "/api/rooms/1/users", nil)
+	w := httptest.NewRecorder()
+
+	// 動作 (Act)
+	router.ServeHTTP(w, req)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+
+	var response []RoomUserResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err, "應該能夠解析響應")
+	assert.Equal(t, 2, len(response), "應該有 2 個用戶")
+	assert.Equal(t, "user-1", response[0].UserID, "第一個用戶的 ID 應該匹配")
+	assert.Equal(t, "alice", response[0].Username, "第一個用戶的用戶名應該匹配")
+	assert.Equal(t, "admin", response[0].Role, "第一個用戶的角色應該匹配")
+	assert.Equal(t, joinedAt.Unix(), response[0].JoinedAt, "加入時間應該以 Unix 時間戳返回")
+	assert.Equal(t, "bob", response[1].Username, "第二個用戶的用戶名應該匹配")
+	assert.Empty(t, response[1].DisplayName, "沒有顯示名稱時應該為空")
+
+	mockService.AssertExpectations(t)
+}
+
+// 設置帶有登入用戶的聊天室路由
+func setupRoomRouterWithUser(userID string) *gin.Engine {
+	router := setupRouter()
+	router.Use(func(c *gin.Context) {
+		middleware.SetCurrentUser(c, &middleware.UserResponse{ID: userID, Username: "user-" + userID})
+		c.Next()
+	})
+	return router
+}
+
+// 測試標記聊天室已讀與聊天室列表中的未讀訊息數
+//
+// 測試目標：
+// 1. 登入用戶的聊天室列表包含未讀訊息數，未登入時不包含
+// 2. POST /api/rooms/:id/read 後未讀數歸零
+// 3. 不存在的聊天室返回 404
+func TestUnreadCounts(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫
+	roomRepo := repository.NewRoomRepository(repository.NewMockDBWithSchema())
+	roomService := service.NewRoomService(roomRepo)
+	assert.NoError(t, roomRepo.CreateRoom(&model.Room{ID: "room-1", Name: "測試聊天室", IsPublic: true, IsActive: true, IsListed: true}))
+	for i := 0; i < 3; i++ {
+		_, err := roomService.SendMessage("room-1", "bob", "哈囉")
+		assert.NoError(t, err)
+	}
+
+	userRouter := setupRoomRouterWithUser("alice")
+	NewRoomHandler(roomService).RegisterRoutes(userRouter)
+	guestRouter := setupRouter()
+	NewRoomHandler(roomService).RegisterRoutes(guestRouter)
+
+	listRooms := func(router *gin.Engine) []map[string]interface{} {
+		req, _ := http.NewRequest("GET", "/api/rooms", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+
+		var rooms []map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rooms), "應該能夠解析響應")
+		return rooms
+	}
+	markRead := func(roomID string) int {
+		req, _ := http.NewRequest("POST", "/api/rooms/"+roomID+"/read", nil)
+		w := httptest.NewRecorder()
+		userRouter.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 動作 (Act)
+	before := listRooms(userRouter)
+	guest := listRooms(guestRouter)
+	readCode := markRead("room-1")
+	after := listRooms(userRouter)
+
+	// 斷言 (Assert)
+	if assert.Len(t, before, 1) {
+		assert.Equal(t, float64(3), before[0]["unreadCount"], "標記已讀前應該有 3 則未讀訊息")
+	}
+	if assert.Len(t, guest, 1) {
+		assert.NotContains(t, guest[0], "unreadCount", "未登入時不應該包含未讀訊息數")
+	}
+	assert.Equal(t, http.StatusOK, readCode, "標記已讀應該成功")
+	if assert.Len(t, after, 1) {
+		assert.Equal(t, float64(0), after[0]["unreadCount"], "標記已讀後未讀數應該歸零")
+	}
+	assert.Equal(t, http.StatusNotFound, markRead("unknown-room"), "不存在的聊天室應該返回 404")
+}
+
+// 測試私人聊天室的內容只有創建者與有效成員可以讀取
+//
+// 測試目標：
+// 1. 非成員與匿名用戶讀取訊息、搜尋、表情回應與成員列表時返回 403
+// 2. 創建者與有效成員可以讀取
+// 3. 非成員透過 WebSocket 的 load_history 指定聊天室時被拒絕
+func TestPrivateRoomReadAccess(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫
+	roomRepo := repository.NewRoomRepository(repository.NewMockDBWithSchema())
+	roomService := service.NewRoomService(roomRepo)
+	assert.NoError(t, roomRepo.CreateRoom(&model.Room{ID: "secret", Name: "私人聊天室", IsPublic: false, IsActive: true, CreatedBy: "owner"}))
+	assert.NoError(t, roomRepo.JoinRoom("secret", "member", "member"))
+	message := &model.Message{RoomID: "secret", UserID: "owner", Content: "機密內容"}
+	assert.NoError(t, roomRepo.SaveMessage(message))
+
+	paths := []string{
+		"/api/rooms/secret/messages",
+		"/api/rooms/secret/messages/search?q=" + url.QueryEscape("機密"),
+		fmt.Sprintf("/api/rooms/secret/messages/%d/reactions", message.ID),
+		"/api/rooms/secret/users",
+	}
+	get := func(router *gin.Engine, path string) int {
+		NewRoomHandler(roomService).RegisterRoutes(router)
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, path := range paths {
+		// 動作與斷言 (Act & Assert)
+		assert.Equal(t, http.StatusForbidden, get(setupRoomRouterWithUser("outsider"), path), "非成員應該返回 403: %s", path)
+		assert.Equal(t, http.StatusForbidden, get(setupRouter(), path), "匿名用戶應該返回 403: %s", path)
+		assert.Equal(t, http.StatusOK, get(setupRoomRouterWithUser("owner"), path), "創建者應該可以讀取: %s", path)
+		assert.Equal(t, http.StatusOK, get(setupRoomRouterWithUser("member"), path), "有效成員應該可以讀取: %s", path)
+	}
+
+	// 動作 (Act)：非成員透過 WebSocket 指定私人聊天室載入歷史訊息
+	mockBroadcastService := new(MockBroadcastService)
+	mockBroadcastService.On("SendPrivateMessage", "outsider-socket", mock.Anything).Return(nil)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	wsHandler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRoomService(roomService))
+	outsider := &model.Client{ID: "outsider-socket", UserID: "outsider", UserName: "outsider"}
+	wsHandler.processTextMessage(outsider, []byte(`{"type":"load_history","target":"secret"}`))
+
+	// 斷言 (Assert)
+	call := mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1]
+	var reply map[string]interface{}
+	assert.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &reply))
+	assert.NotEqual(t, "history", reply["type"], "非成員不應該收到私人聊天室的歷史訊息")
+	assert.NotContains(t, string(call.Arguments.Get(1).([]byte)), "機密內容")
+}
+
+// 測試透過 REST 發送訊息
+//
+// 測試目標：
+// 1. 訊息以登入用戶的身分保存到資料庫
+// 2. 已加入聊天室的 WebSocket 客戶端即時收到訊息
+// 3. 空白內容返回 400、不存在的聊天室返回 404、未登入返回 401
+func TestSendMessageViaREST(t *testing.T) {
+	// 安排 (Arrange)：真實的聊天室服務與廣播服務，並以 WebSocket 客戶端加入聊天室
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	assert.NoError(t, roomRepo.CreateRoom(&model.Room{ID: "room-1", Name: "聊天室", IsPublic: true, IsActive: true, IsListed: true}))
+
+	broadcastService := service.NewBroadcastService(repository.NewClientRepository())
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	wsHandler := NewWebSocketHandler(broadcastService, WithLogger(mockLogger), WithMaxContentLength(20))
+
+	wsRouter := setupRouter()
+	wsRouter.GET("/ws", func(c *gin.Context) {
+		wsHandler.HandleConnection(c.Writer, c.Request)
+	})
+	server := httptest.NewServer(wsRouter)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	assert.NoError(t, err, "應該能夠建立連接")
+	defer conn.Close()
+	readEvent := func() map[string]interface{} {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		var event map[string]interface{}
+		assert.NoError(t, conn.ReadJSON(&event), "應該收到 WebSocket 訊息")
+		return event
+	}
+	assert.NoError(t, conn.WriteJSON(map[string]string{"type": "join_room", "target": "room-1"}))
+	for event := readEvent(); event["type"] != "roster"; event = readEvent() {
+	}
+
+	handler := NewRoomHandler(service.NewRoomService(roomRepo), WithMessageBroadcaster(broadcastService), WithContentValidator(wsHandler))
+	router := setupRoomRouterWithUser("bot-1")
+	handler.RegisterRoutes(router)
+	anonymousRouter := setupRouter()
+	handler.RegisterRoutes(anonymousRouter)
+
+	serve := func(router *gin.Engine, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 動作 (Act)
+	w := serve(router, "/api/rooms/room-1/messages", `{"content":"來自機器人的訊息"}`)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusCreated, w.Code, "發送訊息應該返回 201")
+	var response ChatMessageResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+	assert.Equal(t, "user-bot-1", response.Sender, "發送者應該是登入用戶")
+
+	event := readEvent()
+	assert.Equal(t, "來自機器人的訊息", event["content"], "WebSocket 客戶端應該即時收到訊息")
+	assert.Equal(t, "user-bot-1", event["sender"], "廣播的訊息應該包含發送者")
+
+	messages, err := roomRepo.GetRoomMessages("room-1", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, messages, 1, "訊息應該保存到資料庫") {
+		assert.Equal(t, "bot-1", messages[0].UserID, "訊息應該以登入用戶的 ID 保存")
+		assert.Equal(t, "來自機器人的訊息", messages[0].Content)
+		assert.Equal(t, messages[0].ID, response.MessageID, "響應應該包含保存後的訊息 ID")
+		assert.Equal(t, messages[0].CreatedAt.Unix(), response.Time, "響應的時間應該是訊息保存的時間")
+		assert.Equal(t, float64(messages[0].ID), event["messageId"], "廣播的訊息應該包含保存後的訊息 ID")
+	}
+
+	// 與 WebSocket 相同的私人聊天室、封禁與長度檢查
+	assert.NoError(t, roomRepo.CreateRoom(&model.Room{ID: "secret", Name: "私人聊天室", IsPublic: false, IsActive: true, CreatedBy: "owner"}))
+	assert.Equal(t, http.StatusForbidden, serve(router, "/api/rooms/secret/messages", `{"content":"內容"}`).Code, "非成員不能在私人聊天室發送訊息")
+	assert.NoError(t, roomRepo.BanUser(&model.RoomBan{RoomID: "room-1", UserID: "bot-1", BannedBy: "owner"}))
+	assert.Equal(t, http.StatusForbidden, serve(router, "/api/rooms/room-1/messages", `{"content":"內容"}`).Code, "被封禁的用戶不能發送訊息")
+	otherRouter := setupRoomRouterWithUser("bot-2")
+	handler.RegisterRoutes(otherRouter)
+	assert.Equal(t, http.StatusBadRequest, serve(otherRouter, "/api/rooms/room-1/messages", `{"content":"`+strings.Repeat("長", 21)+`"}`).Code, "超過長度上限應該返回 400")
+
+	assert.Equal(t, http.StatusBadRequest, serve(router, "/api/rooms/room-1/messages", `{"content":"   "}`).Code, "空白內容應該返回 400")
+	assert.Equal(t, http.StatusNotFound, serve(router, "/api/rooms/missing/messages", `{"content":"內容"}`).Code, "不存在的聊天室應該返回 404")
+	assert.Equal(t, http.StatusUnauthorized, serve(anonymousRouter, "/api/rooms/room-1/messages", `{"content":"內容"}`).Code, "未登入應該返回 401")
+	messages, _ = roomRepo.GetRoomMessages("room-1", 10)
+	assert.Len(t, messages, 1, "被拒絕的訊息不應該保存")
+}
+
+// 測試透過 REST 編輯與刪除訊息
+//
+// 測試目標：
+// 1. 非作者編輯或刪除訊息返回 403，訊息保持不變
+// 2. 作者可以編輯訊息，並向聊天室廣播 message_edited 事件
+// 3. 作者可以軟刪除訊息，並向聊天室廣播 message_deleted 事件
+// 4. 無效的訊息 ID 返回 400，已刪除的訊息返回 404，未登入返回 401
+// 5. 編輯內容以與發送訊息相同的規則驗證，未通過返回 400
+// 6. 訊息不屬於路徑中的聊天室時返回 404，訊息保持不變
+func TestEditAndDeleteMessage(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	roomService := service.NewRoomService(roomRepo)
+
+	message := &model.Message{RoomID: "room-1", UserID: "author-1", Content: "原始內容"}
+	assert.NoError(t, roomRepo.SaveMessage(message))
+	messageURL := fmt.Sprintf("/api/rooms/room-1/messages/%d", message.ID)
+	otherRoomMessage := &model.Message{RoomID: "room-2", UserID: "author-1", Content: "其他聊天室的訊息"}
+	assert.NoError(t, roomRepo.SaveMessage(otherRoomMessage))
+	wrongRoomURL := fmt.Sprintf("/api/rooms/room-1/messages/%d", otherRoomMessage.ID)
+
+	mockBroadcaster := new(MockBroadcastService)
+	mockBroadcaster.On("BroadcastEventToRoom", "room-1", "", mock.Anything).Return(nil)
+	validator := NewWebSocketHandler(new(MockBroadcastService))
+	handler := NewRoomHandler(roomService, WithEventBroadcaster(mockBroadcaster), WithContentValidator(validator))
+
+	otherRouter := setupRoomRouterWithUser("other-user")
+	handler.RegisterRoutes(otherRouter)
+	authorRouter := setupRoomRouterWithUser("author-1")
+	handler.RegisterRoutes(authorRouter)
+	anonymousRouter := setupRouter()
+	handler.RegisterRoutes(anonymousRouter)
+
+	serve := func(router *gin.Engine, method, url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	lastEvent := func() map[string]interface{} {
+		call := mockBroadcaster.Calls[len(mockBroadcaster.Calls)-1]
+		var event map[string]interface{}
+		assert.NoError(t, json.Unmarshal(call.Arguments.Get(2).([]byte), &event))
+		return event
+	}
+
+	// 動作 & 斷言：非作者
+	assert.Equal(t, http.StatusForbidden, serve(otherRouter, "PUT", messageURL, `{"content":"竄改內容"}`).Code, "非作者編輯應該返回 403")
+	assert.Equal(t, http.StatusForbidden, serve(otherRouter, "DELETE", messageURL, "").Code, "非作者刪除應該返回 403")
+	stored, err := roomRepo.GetMessage(message.ID)
+	assert.NoError(t, err, "訊息不應該被刪除")
+	assert.Equal(t, "原始內容", stored.Content, "訊息內容不應該被改變")
+	mockBroadcaster.AssertNotCalled(t, "BroadcastEventToRoom", mock.Anything, mock.Anything, mock.Anything)
+
+	// 動作 & 斷言：未登入與無效 ID
+	assert.Equal(t, http.StatusUnauthorized, serve(anonymousRouter, "PUT", messageURL, `{"content":"內容"}`).Code, "未登入應該返回 401")
+	assert.Equal(t, http.StatusBadRequest, serve(authorRouter, "PUT", "/api/rooms/room-1/messages/abc", `{"content":"內容"}`).Code, "無效的訊息 ID 應該返回 400")
+
+	// 動作 & 斷言：內容未通過驗證
+	assert.Equal(t, http.StatusBadRequest, serve(authorRouter, "PUT", messageURL, `{"content":"含有\u0007控制字元"}`).Code, "含有控制字元的內容應該返回 400")
+	assert.Equal(t, http.StatusBadRequest, serve(authorRouter, "PUT", messageURL, `{"content":"   "}`).Code, "空白內容應該返回 400")
+
+	// 動作 & 斷言：訊息不屬於路徑中的聊天室
+	assert.Equal(t, http.StatusNotFound, serve(authorRouter, "PUT", wrongRoomURL, `{"content":"竄改內容"}`).Code, "編輯其他聊天室的訊息應該返回 404")
+	assert.Equal(t, http.StatusNotFound, serve(authorRouter, "DELETE", wrongRoomURL, "").Code, "刪除其他聊天室的訊息應該返回 404")
+	stored, err = roomRepo.GetMessage(otherRoomMessage.ID)
+	assert.NoError(t, err, "其他聊天室的訊息不應該被刪除")
+	assert.Equal(t, "其他聊天室的訊息", stored.Content, "其他聊天室的訊息內容不應該被改變")
+	stored, err = roomRepo.GetMessage(message.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "原始內容", stored.Content, "未通過驗證的編輯不應該改變訊息內容")
+	mockBroadcaster.AssertNotCalled(t, "BroadcastEventToRoom", mock.Anything, mock.Anything, mock.Anything)
+
+	// 動作 & 斷言：作者編輯
+	w := serve(authorRouter, "PUT", messageURL, `{"content":"已編輯"}`)
+	assert.Equal(t, http.StatusOK, w.Code, "作者編輯應該返回 200")
+	var edited model.Message
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &edited))
+	assert.Equal(t, "已編輯", edited.Content, "應該返回更新後的訊息")
+
+	event := lastEvent()
+	assert.Equal(t, "message_edited", event["type"], "應該廣播訊息已編輯事件")
+	assert.Equal(t, float64(message.ID), event["messageId"], "事件應該包含訊息 ID")
+	assert.Equal(t, "已編輯", event["content"], "事件應該包含新的內容")
+
+	// 動作 & 斷言：作者刪除
+	assert.Equal(t, http.StatusOK, serve(authorRouter, "DELETE", messageURL, "").Code, "作者刪除應該返回 200")
+	event = lastEvent()
+	assert.Equal(t, "message_deleted", event["type"], "應該廣播訊息已刪除事件")
+	assert.Equal(t, float64(message.ID), event["messageId"], "事件應該包含訊息 ID")
+
+	messages, err := roomService.GetRoomMessages("room-1", 50)
+	assert.NoError(t, err)
+	assert.Empty(t, messages, "已刪除的訊息不應該出現在訊息列表中")
+	assert.Equal(t, http.StatusNotFound, serve(authorRouter, "PUT", messageURL, `{"content":"再次編輯"}`).Code, "已刪除的訊息應該返回 404")
+}
+
+// 測試刪除登入用戶在所有聊天室的訊息
+//
+// 測試目標：
+// 1. 用戶在各聊天室的訊息都被刪除，其他用戶的訊息保留
+// 2. 向每個受影響的聊天室廣播 message_deleted 事件，並返回刪除的數量
+// 3. 未登入返回 401
+func TestDeleteUserMessages(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	roomService := service.NewRoomService(roomRepo)
+
+	own := []*model.Message{
+		{RoomID: "room-1", UserID: "author-1", Content: "第一則"},
+		{RoomID: "room-2", UserID: "author-1", Content: "第二則"},
+	}
+	for _, message := range own {
+		assert.NoError(t, roomRepo.SaveMessage(message))
+	}
+	assert.NoError(t, roomRepo.SaveMessage(&model.Message{RoomID: "room-1", UserID: "other-user", Content: "別人的訊息"}))
+
+	mockBroadcaster := new(MockBroadcastService)
+	mockBroadcaster.On("BroadcastEventToRoom", mock.Anything, "", mock.Anything).Return(nil)
+	handler := NewRoomHandler(roomService, WithEventBroadcaster(mockBroadcaster))
+
+	authorRouter := setupRoomRouterWithUser("author-1")
+	handler.RegisterRoutes(authorRouter)
+	anonymousRouter := setupRouter()
+	handler.RegisterRoutes(anonymousRouter)
+
+	// 動作 (Act)
+	req, _ := http.NewRequest("DELETE", "/api/user/messages", nil)
+	w := httptest.NewRecorder()
+	authorRouter.ServeHTTP(w, req)
+
+	anonymousReq, _ := http.NewRequest("DELETE", "/api/user/messages", nil)
+	anonymousW := httptest.NewRecorder()
+	anonymousRouter.ServeHTTP(anonymousW, anonymousReq)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+	var response map[string]int
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+	assert.Equal(t, 2, response["deleted"], "應該返回刪除的訊息數量")
+
+	room1, err := roomService.GetRoomMessages("room-1", 50)
+	assert.NoError(t, err)
+	if assert.Len(t, room1, 1, "其他用戶的訊息應該保留") {
+		assert.Equal(t, "other-user", room1[0].UserID, "保留的訊息應該屬於其他用戶")
+	}
+	room2, err := roomService.GetRoomMessages("room-2", 50)
+	assert.NoError(t, err)
+	assert.Empty(t, room2, "用戶在其他聊天室的訊息也應該被刪除")
+
+	for _, message := range own {
+		found := false
+		for _, call := range mockBroadcaster.Calls {
+			var event map[string]interface{}
+			assert.NoError(t, json.Unmarshal(call.Arguments.Get(2).([]byte), &event))
+			if call.Arguments.Get(0) == message.RoomID && event["messageId"] == float64(message.ID) {
+				assert.Equal(t, "message_deleted", event["type"], "應該廣播訊息已刪除事件")
+				found = true
+			}
+		}
+		assert.True(t, found, "應該向聊天室 %s 廣播訊息已刪除事件", message.RoomID)
+	}
+	assert.Len(t, mockBroadcaster.Calls, 2, "每則被刪除的訊息應該廣播一次")
+
+	assert.Equal(t, http.StatusUnauthorized, anonymousW.Code, "未登入應該返回 401")
+}
+
+// 測試創建聊天室邀請
+func TestCreateInvite(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockRoomService)
+	handler := NewRoomHandler(mockService)
+	router := setupRoomRouterWithUser("owner-1")
+	handler.RegisterRoutes(router)
+
+	expiresAt := time.Date(2025, 8, 1, 11, 0, 0, 0, time.UTC)
+	invite := &model.RoomInvite{Token: "token-1", RoomID: "1", ExpiresAt: expiresAt, MaxUses: 3}
+
+	mockService.On("CreateInvite", "1", "owner-1", time.Hour, 3).Return(invite, nil)
+	mockService.On("CreateInvite", "2", "owner-1", time.Duration(0), 0).Return(nil, service.ErrNotRoomAdmin)
+	mockService.On("CreateInvite", "1", "owner-1", time.Hour, 100).Return(nil, service.ErrInvalidInviteUses)
+
+	// 動作 (Act)
+	req, _ := http.NewRequest("POST", "/api/rooms/1/invites", bytes.NewBufferString(`{"expiresIn":3600,"maxUses":3}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	req2, _ := http.NewRequest("POST", "/api/rooms/2/invites", nil)
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+
+	req3, _ := http.NewRequest("POST", "/api/rooms/1/invites", bytes.NewBufferString(`{"expiresIn":3600,"maxUses":100}`))
+	req3.Header.Set("Content-Type", "application/json")
+	w3 := httptest.NewRecorder()
+	router.ServeHTTP(w3, req3)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusCreated, w.Code, "狀態碼應該是 201")
+	var response InviteResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err, "應該能夠解析響應")
+	assert.Equal(t, "token-1", response.Token, "邀請碼應該匹配")
+	assert.Equal(t, expiresAt.Unix(), response.ExpiresAt, "過期時間應該匹配")
+
+	assert.Equal(t, http.StatusForbidden, w2.Code, "非管理員創建邀請應該返回 403")
+	assert.Equal(t, http.StatusBadRequest, w3.Code, "無效的邀請參數應該返回 400")
+	mockService.AssertExpectations(t)
+}
+
+// 測試獲取聊天室成員記錄
+func TestGetMembershipHistory(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockRoomService)
+	handler := NewRoomHandler(mockService)
+	router := setupRoomRouterWithUser("owner-1")
+	handler.RegisterRoutes(router)
+
+	joinedAt := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	leftAt := joinedAt.Add(time.Hour)
+	members := []model.RoomUser{
+		{RoomID: "1", UserID: "user-1", Role: "member", JoinedAt: joinedAt, LastActiveAt: joinedAt, IsActive: true},
+		{Model: gorm.Model{UpdatedAt: leftAt}, RoomID: "1", UserID: "user-2", Role: "member", JoinedAt: joinedAt, LastActiveAt: joinedAt, IsActive: false},
+	}
+
+	mockService.On("GetMembershipHistory", "1", "owner-1", 10, 20).Return(members, nil)
+	mockService.On("GetMembershipHistory", "2", "owner-1", 50, 0).Return(nil, service.ErrNotRoomAdmin)
+
+	// 動作 (Act)
+	req, _ := http.NewRequest("GET", "/api/rooms/1/membership-history?limit=10&offset=20", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	req2, _ := http.NewRequest("GET", "/api/rooms/2/membership-history", nil)
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+	var response []MembershipEventResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err, "應該能夠解析響應")
+	assert.Len(t, response, 2, "應該有 2 筆成員記錄")
+	assert.True(t, response[0].IsActive, "第一位成員應該仍在聊天室")
+	assert.Zero(t, response[0].LeftAt, "仍在聊天室的成員不應該有離開時間")
+	assert.False(t, response[1].IsActive, "第二位成員應該已離開")
+	assert.Equal(t, leftAt.Unix(), response[1].LeftAt, "離開時間應該匹配")
+
+	assert.Equal(t, http.StatusForbidden, w2.Code, "非管理員查看成員記錄應該返回 403")
+	mockService.AssertExpectations(t)
+}
+
+// 測試獲取登入用戶最近的聊天室，分頁參數會被傳遞且未登入時返回 401
+func TestGetRecentRooms(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockRoomService)
+	handler := NewRoomHandler(mockService)
+	router := setupRoomRouterWithUser("user-1")
+	handler.RegisterRoutes(router)
+
+	anonymousRouter := setupRouter()
+	handler.RegisterRoutes(anonymousRouter)
+
+	rooms := []model.RoomWithActiveCount{
+		{Room: model.Room{ID: "room-b", Name: "最近的聊天室"}, ActiveUsers: 3},
+		{Room: model.Room{ID: "room-a", Name: "較早的聊天室"}},
+	}
+	mockService.On("GetRecentRooms", "user-1", 2, 4).Return(rooms, nil)
+
+	// 動作 (Act)
+	req, _ := http.NewRequest("GET", "/api/user/recent-rooms?limit=2&offset=4", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	req2, _ := http.NewRequest("GET", "/api/user/recent-rooms", nil)
+	w2 := httptest.NewRecorder()
+	anonymousRouter.ServeHTTP(w2, req2)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+	var response []RoomResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+	if assert.Len(t, response, 2, "應該有 2 個聊天室") {
+		assert.Equal(t, "room-b", response[0].ID, "應該保持服務返回的順序")
+		assert.Equal(t, int64(3), response[0].ActiveUsers, "應該包含活躍用戶數")
+		assert.Equal(t, "room-a", response[1].ID)
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, w2.Code, "未登入時應該返回 401")
+	mockService.AssertExpectations(t)
+}
+
+// 測試刪除聊天室後，聊天室中的 WebSocket 連接收到 room_closed 通知並被移出聊天室
+//
+// 測試目標：
+// 1. 有權限的用戶刪除聊天室返回 200，聊天室中的連接被移出並收到通知，訊息日誌被釋放
+// 2. 無權限與聊天室不存在分別返回 403 與 404，連接不受影響
+// 3. 未登入時返回 401
+func TestDeleteRoom(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockRoomService)
+	mockBroadcastService := new(MockBroadcastService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	member := &model.Client{ID: "socket-1", UserID: "user-2", UserName: "Bob", RoomID: "room-1"}
+	guest := &model.Client{ID: "socket-2", UserName: "訪客", RoomID: "room-1"}
+	mockBroadcastService.On("GetClientsInRoom", "room-1").Return([]*model.Client{member, guest})
+	mockBroadcastService.On("SendPrivateMessage", mock.Anything, mock.Anything).Return(nil)
+	mockBroadcastService.On("ClearRoomLog", "room-1").Return()
+
+	mockService.On("DeleteRoom", "room-1", "owner-1").Return(nil)
+	mockService.On("DeleteRoom", "room-2", "owner-1").Return(service.ErrNotRoomAdmin)
+	mockService.On("DeleteRoom", "missing", "owner-1").Return(repository.ErrRoomNotFound)
+
+	wsHandler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger))
+	handler := NewRoomHandler(mockService, WithRoomCloser(wsHandler))
+	router := setupRoomRouterWithUser("owner-1")
+	handler.RegisterRoutes(router)
+	anonymousRouter := setupRouter()
+	handler.RegisterRoutes(anonymousRouter)
+
+	deleteRoom := func(router *gin.Engine, roomID string) int {
+		req, _ := http.NewRequest("DELETE", "/api/rooms/"+roomID, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 動作 (Act)
+	forbidden := deleteRoom(router, "room-2")
+	notFound := deleteRoom(router, "missing")
+	unauthorized := deleteRoom(anonymousRouter, "room-1")
+	roomBeforeDelete := member.RoomID
+	deleted := deleteRoom(router, "room-1")
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusForbidden, forbidden, "無權限刪除應該返回 403")
+	assert.Equal(t, http.StatusNotFound, notFound, "聊天室不存在應該返回 404")
+	assert.Equal(t, http.StatusUnauthorized, unauthorized, "未登入時應該返回 401")
+	assert.Equal(t, "room-1", roomBeforeDelete, "刪除失敗時連接應該留在聊天室")
+	mockBroadcastService.AssertNumberOfCalls(t, "ClearRoomLog", 1)
+
+	assert.Equal(t, http.StatusOK, deleted, "刪除聊天室應該返回 200")
+	assert.Empty(t, member.RoomID, "成員的連接應該被移出已刪除的聊天室")
+	assert.Empty(t, guest.RoomID, "訪客的連接應該被移出已刪除的聊天室")
+	for _, clientID := range []string{"socket-1", "socket-2"} {
+		mockBroadcastService.AssertCalled(t, "SendPrivateMessage", clientID, mock.MatchedBy(func(msg []byte) bool {
+			var notice map[string]interface{}
+			return json.Unmarshal(msg, &notice) == nil && notice["type"] == "room_closed" && notice["roomId"] == "room-1"
+		}))
+	}
+	mockService.AssertExpectations(t)
+}
+
+// 測試移出與封禁聊天室用戶
+//
+// 測試目標：
+// 1. 移出成功後該用戶的連接被移出聊天室並收到 kicked 通知，其他人收到 user_kicked 事件
+// 2. 不能移出自己，非管理員返回 403
+// 3. 封禁走 BanUser，通知中標示為封禁
+func TestKickAndBanUser(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockRoomService)
+	mockBroadcastService := new(MockBroadcastService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	target := &model.Client{ID: "socket-1", UserID: "user-2", UserName: "Bob", RoomID: "room-1"}
+	other := &model.Client{ID: "socket-2", UserID: "user-3", UserName: "Carol", RoomID: "room-1"}
+	mockBroadcastService.On("GetClientsInRoom", "room-1").Return([]*model.Client{target, other})
+	mockBroadcastService.On("SendPrivateMessage", mock.Anything, mock.Anything).Return(nil)
+	mockBroadcastService.On("BroadcastEventToRoom", "room-1", "", mock.Anything).Return(nil)
+
+	mockService.On("KickUser", "room-1", "user-2", "mod-1").Return(nil)
+	mockService.On("KickUser", "room-1", "mod-1", "mod-1").Return(service.ErrCannotKickSelf)
+	mockService.On("KickUser", "room-2", "user-2", "mod-1").Return(service.ErrNotRoomAdmin)
+	mockService.On("BanUser", "room-1", "user-3", "mod-1").Return(nil)
+
+	wsHandler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger))
+	handler := NewRoomHandler(mockService, WithRoomKicker(wsHandler), WithEventBroadcaster(mockBroadcastService))
+	router := setupRoomRouterWithUser("mod-1")
+	handler.RegisterRoutes(router)
+
+	moderate := func(action string, roomID string, userID string) int {
+		body := fmt.Sprintf(`{"userId":"%s"}`, userID)
+		req, _ := http.NewRequest("POST", "/api/rooms/"+roomID+"/"+action, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	isEvent := func(eventType string, banned bool) interface{} {
+		return mock.MatchedBy(func(msg []byte) bool {
+			var event map[string]interface{}
+			return json.Unmarshal(msg, &event) == nil && event["type"] == eventType && event["roomId"] == "room-1" && event["banned"] == banned
+		})
+	}
+
+	// 動作 (Act)
+	selfKick := moderate("kick", "room-1", "mod-1")
+	forbidden := moderate("kick", "room-2", "user-2")
+	invalid := moderate("kick", "room-1", "")
+	roomBeforeKick := target.RoomID
+	kicked := moderate("kick", "room-1", "user-2")
+	banned := moderate("ban", "room-1", "user-3")
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusBadRequest, selfKick, "移出自己應該返回 400")
+	assert.Equal(t, http.StatusForbidden, forbidden, "非管理員應該返回 403")
+	assert.Equal(t, http.StatusBadRequest, invalid, "缺少 userId 應該返回 400")
+	assert.Equal(t, "room-1", roomBeforeKick, "移出失敗時連接應該留在聊天室")
+
+	assert.Equal(t, http.StatusOK, kicked, "移出用戶應該返回 200")
+	assert.Equal(t, http.StatusOK, banned, "封禁用戶應該返回 200")
+	assert.Empty(t, target.RoomID, "被移出用戶的連接應該離開聊天室")
+	assert.Empty(t, other.RoomID, "被封禁用戶的連接應該離開聊天室")
+	mockBroadcastService.AssertCalled(t, "SendPrivateMessage", "socket-1", isEvent("kicked", false))
+	mockBroadcastService.AssertCalled(t, "SendPrivateMessage", "socket-2", isEvent("kicked", true))
+	mockBroadcastService.AssertCalled(t, "BroadcastEventToRoom", "room-1", "", isEvent("user_kicked", false))
+	mockBroadcastService.AssertCalled(t, "BroadcastEventToRoom", "room-1", "", isEvent("user_kicked", true))
+	mockService.AssertExpectations(t)
+}
+
+// 測試使用有效、過期、已用盡的邀請，以及被封禁、聊天室已滿與未預期的錯誤
+func TestAcceptInvite(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockRoomService)
+	handler := NewRoomHandler(mockService)
+	router := setupRoomRouterWithUser("user-1")
+	handler.RegisterRoutes(router)
+
+	room := &model.Room{ID: "1", Name: "私人聊天室"}
+	mockService.On("AcceptInvite", "valid", "user-1").Return(room, nil)
+	mockService.On("AcceptInvite", "expired", "user-1").Return(nil, service.ErrInviteExpired)
+	mockService.On("AcceptInvite", "exhausted", "user-1").Return(nil, repository.ErrInviteExhausted)
+	mockService.On("AcceptInvite", "unknown", "user-1").Return(nil, repository.ErrInviteNotFound)
+	mockService.On("AcceptInvite", "banned", "user-1").Return(nil, service.ErrUserBanned)
+	mockService.On("AcceptInvite", "full", "user-1").Return(nil, service.ErrRoomFull)
+	mockService.On("AcceptInvite", "broken", "user-1").Return(nil, errors.New("pq: connection refused"))
+
+	testCases := []struct {
+		token    string
+		expected int
+		message  string
+	}{
+		{"valid", http.StatusOK, ""},
+		{"expired", http.StatusGone, service.ErrInviteExpired.Error()},
+		{"exhausted", http.StatusGone, repository.ErrInviteExhausted.Error()},
+		{"unknown", http.StatusNotFound, repository.ErrInviteNotFound.Error()},
+		{"banned", http.StatusForbidden, service.ErrUserBanned.Error()},
+		{"full", http.StatusConflict, service.ErrRoomFull.Error()},
+		{"broken", http.StatusInternalServerError, "使用邀請失敗"},
+	}
+
+	for _, tc := range testCases {
+		// 動作 (Act)
+		req, _ := http.NewRequest("POST", "/api/invites/"+tc.token+"/accept", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		// 斷言 (Assert)
+		assert.Equal(t, tc.expected, w.Code, "邀請 %s 的狀態碼應該匹配", tc.token)
+		if tc.message != "" {
+			var response map[string]string
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tc.message, response["error"], "邀請 %s 的錯誤訊息應該匹配", tc.token)
+		}
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+// 測試未登入時不能使用邀請
+func TestAcceptInviteRequiresLogin(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockRoomService)
+	handler := NewRoomHandler(mockService)
+	router := setupRouter()
+	handler.RegisterRoutes(router)
+
+	// 動作 (Act)
+	req, _ := http.NewRequest("POST", "/api/invites/valid/accept", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "未登入應該返回 401")
+	mockService.AssertNotCalled(t, "AcceptInvite", mock.Anything, mock.Anything)
+}
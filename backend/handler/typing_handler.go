@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"livechat/backend/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TypingService 定義了查詢輸入中狀態的接口
+type TypingService interface {
+	GetTypingUsers(roomID string) []service.TypingUser
+}
+
+// TypingHandler 處理輸入中狀態相關的 HTTP 請求
+type TypingHandler struct {
+	typingService TypingService
+}
+
+// TypingUserResponse 是輸入中用戶的 API 響應格式
+type TypingUserResponse struct {
+	UserID    string `json:"userId,omitempty"`
+	UserName  string `json:"userName"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+// NewTypingHandler 創建一個新的輸入中狀態處理器
+func NewTypingHandler(typingService TypingService) *TypingHandler {
+	return &TypingHandler{
+		typingService: typingService,
+	}
+}
+
+// RegisterRoutes 註冊輸入中狀態相關的路由
+func (h *TypingHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/rooms/:id/typing", h.GetTypingUsers)
+}
+
+// GetTypingUsers 獲取聊天室中目前正在輸入的用戶，供中途重新連接的客戶端同步狀態
+func (h *TypingHandler) GetTypingUsers(c *gin.Context) {
+	users := h.typingService.GetTypingUsers(c.Param("id"))
+
+	response := make([]TypingUserResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, TypingUserResponse{
+			UserID:    user.UserID,
+			UserName:  user.UserName,
+			UpdatedAt: user.UpdatedAt.Unix(),
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"livechat/backend/model"
+	"livechat/backend/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// 測試透過 API 查詢聊天室中正在輸入的用戶
+//
+// 測試目標：
+// 1. WebSocket 收到 typing 後，API 返回該用戶的輸入中狀態
+// 2. 超過有效時間後狀態自動過期
+// 3. 離開聊天室時狀態立即清除
+func TestGetTypingUsers(t *testing.T) {
+	// 安排 (Arrange)
+	now := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time {
+		return now
+	})
+	defer model.ResetTimeNow()
+
+	mockBroadcastService := new(MockBroadcastService)
+	mockBroadcastService.On("BroadcastEventToRoom", "room-1", mock.Anything, mock.Anything).Return(nil)
+	mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Return(nil)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	typingService := service.NewTypingService(5 * time.Second)
+	wsHandler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithTypingTracker(typingService))
+	handler := NewTypingHandler(typingService)
+	router := setupRouter()
+	handler.RegisterRoutes(router)
+
+	alice := &model.Client{ID: "client-1", UserID: "user-1", UserName: "Alice", RoomID: "room-1"}
+	guest := &model.Client{ID: "client-2", UserName: "訪客", RoomID: "room-1"}
+
+	getTyping := func() []TypingUserResponse {
+		req, _ := http.NewRequest("GET", "/api/rooms/room-1/typing", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+
+		var response []TypingUserResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+		return response
+	}
+
+	// 動作 (Act)
+	empty := getTyping()
+	wsHandler.processTextMessage(alice, []byte(`{"type":"typing"}`))
+	now = now.Add(time.Second)
+	wsHandler.processTextMessage(guest, []byte(`{"type":"typing"}`))
+	typing := getTyping()
+
+	// 斷言 (Assert)
+	assert.NotNil(t, empty, "沒有人輸入時應該返回空陣列而非 null")
+	assert.Empty(t, empty, "沒有人輸入時應該返回空陣列")
+	if assert.Len(t, typing, 2, "兩個正在輸入的用戶都應該返回") {
+		assert.Equal(t, "user-1", typing[0].UserID, "登入用戶應該包含 ID")
+		assert.Equal(t, "Alice", typing[0].UserName, "用戶名應該匹配")
+		assert.Equal(t, now.Add(-time.Second).Unix(), typing[0].UpdatedAt, "更新時間應該匹配")
+		assert.Empty(t, typing[1].UserID, "匿名訪客不應該有 ID")
+	}
+
+	// 動作 & 斷言：訪客離開聊天室後狀態立即清除，Alice 的狀態之後過期
+	wsHandler.handleLeaveRoom(guest)
+	typing = getTyping()
+	if assert.Len(t, typing, 1, "離開聊天室的用戶不應該再顯示為輸入中") {
+		assert.Equal(t, "Alice", typing[0].UserName, "仍在聊天室中的用戶應該保留")
+	}
+
+	now = now.Add(5 * time.Second)
+	assert.Empty(t, getTyping(), "超過有效時間的輸入中狀態應該過期")
+}
@@ -1,9 +1,13 @@
 package handler
 
 import (
+	"errors"
 	"livechat/backend/middleware"
+	"livechat/backend/model"
+	"livechat/backend/repository"
 	"livechat/backend/service"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -11,7 +15,111 @@ import (
 
 // UserHandler 處理用戶相關的 HTTP 請求
 type UserHandler struct {
-	userService service.UserService
+	userService  service.UserService
+	cookieConfig CookieConfig
+	auditLogger  Logger // 登入稽核日誌，為 nil 時不記錄
+	roomReleaser OwnedRoomsReleaser
+	sessionStore middleware.SessionStore
+	sessionTTL   time.Duration         // 會話與 cookie 的有效時間，0 表示不過期
+	tokenService *service.TokenService // 登入時簽發存取令牌，nil 表示只使用 cookie 會話
+	resetLogger  Logger                // 在尚未串接郵件寄送前，以日誌輸出密碼重設令牌
+	loginLimiter *service.LoginLimiter // 登入失敗次數限制，nil 表示不限制
+	errorLogger  Logger                // 記錄未預期的內部錯誤，這些錯誤不會回傳給客戶端
+}
+
+// OwnedRoomsReleaser 定義在帳號刪除前處理用戶所創建聊天室與成員記錄的接口
+type OwnedRoomsReleaser interface {
+	ReleaseOwnedRooms(userID string) ([]model.Room, error)
+	LeaveAllRooms(userID string) error
+}
+
+// CookieConfig 定義會話 cookie 的安全屬性
+type CookieConfig struct {
+	Secure   bool          // 只透過 HTTPS 傳送 cookie
+	SameSite http.SameSite // 跨站請求時是否傳送 cookie
+}
+
+// UserHandlerOption 定義用戶處理器選項
+type UserHandlerOption func(*UserHandler)
+
+// WithCookieConfig 設置會話 cookie 的安全屬性
+func WithCookieConfig(config CookieConfig) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.cookieConfig = config
+	}
+}
+
+// WithSessionStore 設置保存登入會話的存儲，必須與 SessionMiddleware 使用同一個存儲
+func WithSessionStore(store middleware.SessionStore) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.sessionStore = store
+	}
+}
+
+// WithSessionTTL 設置登入會話的有效時間，會話 cookie 的 MaxAge 也會依此設置，0 表示不過期
+func WithSessionTTL(ttl time.Duration) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.sessionTTL = ttl
+	}
+}
+
+// WithTokenService 設置存取令牌服務，登入成功時除了 cookie 會話外也返回 JWT 存取令牌
+func WithTokenService(tokenService *service.TokenService) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.tokenService = tokenService
+	}
+}
+
+// WithLoginAudit 啟用登入稽核，每次登入嘗試都會以結構化格式寫入日誌
+func WithLoginAudit(logger Logger) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.auditLogger = logger
+	}
+}
+
+// WithPasswordResetLogger 設置輸出密碼重設令牌的日誌，未串接郵件寄送前由管理員轉交令牌給用戶
+func WithPasswordResetLogger(logger Logger) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.resetLogger = logger
+	}
+}
+
+// WithErrorLogger 設置記錄未預期內部錯誤的日誌
+func WithErrorLogger(logger Logger) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.errorLogger = logger
+	}
+}
+
+// WithLoginLimiter 設置登入失敗次數限制，失敗過多的用戶名或 IP 會被暫時鎖定
+func WithLoginLimiter(limiter *service.LoginLimiter) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.loginLimiter = limiter
+	}
+}
+
+// WithOwnedRoomsReleaser 設置刪除帳號時處理用戶所創建聊天室的服務，未設置時聊天室保持原樣
+func WithOwnedRoomsReleaser(releaser OwnedRoomsReleaser) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.roomReleaser = releaser
+	}
+}
+
+// loginAuditFormat 是登入稽核記錄的格式，成功與失敗共用同一組欄位
+const loginAuditFormat = "login_audit result=%s username=%q ip=%s time=%s"
+
+// auditLogin 記錄一次登入嘗試
+//
+// 失敗記錄不包含失敗原因，避免從稽核日誌推斷用戶名是否存在
+func (h *UserHandler) auditLogin(c *gin.Context, username string, success bool) {
+	if h.auditLogger == nil {
+		return
+	}
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	h.auditLogger.Info(loginAuditFormat, result, username, c.ClientIP(), model.Now().UTC().Format(time.RFC3339))
 }
 
 // RegisterRequest 是註冊請求的格式
@@ -27,14 +135,91 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// LoginResponse 是登入成功的響應格式，啟用存取令牌時包含 JWT
+type LoginResponse struct {
+	middleware.UserResponse
+	Token string `json:"token,omitempty"`
+}
+
+// RegisterResponse 是註冊成功的響應格式，包含供郵件寄送使用的電子郵件驗證令牌
+type RegisterResponse struct {
+	middleware.UserResponse
+	VerificationToken string `json:"verificationToken,omitempty"`
+}
+
+// UpdateDisplayNameRequest 是更新顯示名稱請求的格式
+type UpdateDisplayNameRequest struct {
+	DisplayName string `json:"displayName" binding:"required"`
+}
+
+// PasswordResetRequest 是申請密碼重設請求的格式
+type PasswordResetRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// PasswordResetConfirmRequest 是確認密碼重設請求的格式
+type PasswordResetConfirmRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// UpdateProfileRequest 是更新個人資料請求的格式
+type UpdateProfileRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Username string `json:"username" binding:"required"`
+}
+
+// ChangePasswordRequest 是更換密碼請求的格式
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required"`
+}
+
+// DeleteAccountRequest 是刪除帳號請求的格式，需要再次輸入密碼確認
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
 // UserResponse 使用middleware包中的定義
 type UserResponse = middleware.UserResponse
 
 // NewUserHandler 創建一個新的用戶處理器
-func NewUserHandler(userService service.UserService) *UserHandler {
-	return &UserHandler{
+func NewUserHandler(userService service.UserService, opts ...UserHandlerOption) *UserHandler {
+	h := &UserHandler{
 		userService: userService,
+		cookieConfig: CookieConfig{
+			Secure:   false, // 默認允許 HTTP 以便本地開發
+			SameSite: http.SameSiteLaxMode,
+		},
+		sessionStore: middleware.NewMemorySessionStore(),
+		sessionTTL:   middleware.DefaultSessionTTL,
+		resetLogger:  &DefaultLogger{},
+		errorLogger:  &DefaultLogger{},
+	}
+
+	// 應用選項
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// setSessionCookie 依設定的安全屬性寫入會話 cookie，HttpOnly 一律啟用
+func (h *UserHandler) setSessionCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(h.cookieConfig.SameSite)
+	c.SetCookie("session_id", value, maxAge, "/", "", h.cookieConfig.Secure, true)
+}
+
+// startSession 為用戶創建新的會話並設置會話 cookie，兩者都在 sessionTTL 後過期
+func (h *UserHandler) startSession(c *gin.Context, user *model.User) error {
+	sessionID := uuid.New().String()
+	if err := h.sessionStore.Set(sessionID, middleware.NewSession(user, h.sessionTTL)); err != nil {
+		return err
 	}
+
+	h.setSessionCookie(c, sessionID, int(h.sessionTTL/time.Second))
+	return nil
 }
 
 // RegisterRoutes 註冊路由
@@ -42,9 +227,17 @@ func (h *UserHandler) RegisterRoutes(router *gin.Engine) {
 	router.GET("/login", h.ShowLoginPage)
 	router.GET("/register", h.ShowRegisterPage)
 	router.POST("/api/register", h.Register)
+	router.GET("/api/password-requirements", h.GetPasswordRequirements)
 	router.POST("/api/login", h.Login)
 	router.GET("/api/logout", h.Logout)
 	router.GET("/api/user", h.GetCurrentUser)
+	router.PUT("/api/user", middleware.AuthRequired(), h.UpdateProfile)
+	router.PUT("/api/user/display-name", middleware.AuthRequired(), h.UpdateDisplayName)
+	router.POST("/api/user/password", middleware.AuthRequired(), h.ChangePassword)
+	router.DELETE("/api/user", middleware.AuthRequired(), h.DeleteAccount)
+	router.POST("/api/password-reset/request", h.RequestPasswordReset)
+	router.POST("/api/password-reset/confirm", h.ConfirmPasswordReset)
+	router.GET("/api/verify", h.VerifyEmail)
 }
 
 // ShowLoginPage 顯示登入頁面
@@ -80,25 +273,70 @@ func (h *UserHandler) Register(c *gin.Context) {
 	// 註冊用戶
 	user, err := h.userService.RegisterUser(req.Username, req.Email, req.Password)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		status, code := registrationError(err)
+		message := err.Error()
+		if status == http.StatusInternalServerError {
+			h.errorLogger.Error("Failed to register user %s: %v", req.Username, err)
+			message = "註冊失敗，請稍後再試"
+		}
+		c.JSON(status, gin.H{"error": code, "message": message})
 		return
 	}
 
 	// 創建會話
-	sessionID := uuid.New().String()
-	middleware.SetSession(sessionID, user)
-	c.SetCookie("session_id", sessionID, 0, "/", "", false, true) // 無過期時間
+	if err := h.startSession(c, user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "創建會話失敗"})
+		return
+	}
 	c.Set("user", user)
 
+	// 註冊不要求先驗證電子郵件，驗證令牌產生失敗時仍視為註冊成功，用戶可稍後重新申請
+	var verificationToken string
+	if token, err := h.userService.GenerateVerificationToken(user.ID); err == nil {
+		verificationToken = token.Token
+	}
+
 	// 返回用戶信息
-	c.JSON(http.StatusCreated, middleware.UserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		Role:     user.Role,
+	c.JSON(http.StatusCreated, RegisterResponse{
+		UserResponse: middleware.UserResponse{
+			ID:       user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+			Role:     user.Role,
+		},
+		VerificationToken: verificationToken,
 	})
 }
 
+// registrationErrors 將註冊時的已知錯誤對應到 HTTP 狀態碼與穩定的錯誤代碼，供前端判斷錯誤類型
+var registrationErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{service.ErrInvalidUsername, http.StatusUnprocessableEntity, "invalid_username"},
+	{service.ErrReservedUsername, http.StatusUnprocessableEntity, "reserved_username"},
+	{service.ErrInvalidEmail, http.StatusUnprocessableEntity, "invalid_email"},
+	{service.ErrWeakPassword, http.StatusUnprocessableEntity, "weak_password"},
+	{repository.ErrUserAlreadyExists, http.StatusConflict, "username_taken"},
+	{repository.ErrEmailAlreadyExists, http.StatusConflict, "email_taken"},
+}
+
+// registrationError 返回註冊錯誤對應的狀態碼與錯誤代碼，未知錯誤視為內部錯誤
+func registrationError(err error) (int, string) {
+	for _, known := range registrationErrors {
+		if errors.Is(err, known.err) {
+			return known.status, known.code
+		}
+	}
+	return http.StatusInternalServerError, "internal_error"
+}
+
+// GetPasswordRequirements 返回目前的密碼強度規則，供註冊與更換密碼頁面顯示
+func (h *UserHandler) GetPasswordRequirements(c *gin.Context) {
+	c.JSON(http.StatusOK, h.userService.PasswordRequirements())
+}
+
 // Login 處理用戶登入請求
 func (h *UserHandler) Login(c *gin.Context) {
 	var req LoginRequest
@@ -107,25 +345,55 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// 被鎖定時不驗證密碼，響應與用戶名是否存在無關
+	if h.loginLimiter != nil {
+		if err := h.loginLimiter.Check(req.Username, c.ClientIP()); err != nil {
+			h.auditLogin(c, req.Username, false)
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// 驗證用戶
 	user, err := h.userService.LoginUser(req.Username, req.Password)
+	h.auditLogin(c, req.Username, err == nil)
+	if h.loginLimiter != nil {
+		if err != nil {
+			h.loginLimiter.RecordFailure(req.Username, c.ClientIP())
+		} else {
+			h.loginLimiter.RecordSuccess(req.Username)
+		}
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "用戶名或密碼錯誤"})
 		return
 	}
 
 	// 創建會話
-	sessionID := uuid.New().String()
-	middleware.SetSession(sessionID, user)
-	c.SetCookie("session_id", sessionID, 0, "/", "", false, true) // 無過期時間
+	if err := h.startSession(c, user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "創建會話失敗"})
+		return
+	}
 	c.Set("user", user)
 
+	// 啟用存取令牌時一併簽發
+	var token string
+	if h.tokenService != nil {
+		if token, err = h.tokenService.IssueToken(user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "簽發存取令牌失敗"})
+			return
+		}
+	}
+
 	// 返回用戶信息
-	c.JSON(http.StatusOK, middleware.UserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		Role:     user.Role,
+	c.JSON(http.StatusOK, LoginResponse{
+		UserResponse: middleware.UserResponse{
+			ID:       user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+			Role:     user.Role,
+		},
+		Token: token,
 	})
 }
 
@@ -134,11 +402,11 @@ func (h *UserHandler) Logout(c *gin.Context) {
 	// 從cookie中獲取sessionID並清除session
 	sessionID, err := c.Cookie("session_id")
 	if err == nil {
-		middleware.RemoveSession(sessionID)
+		h.sessionStore.Delete(sessionID)
 	}
 
 	// 刪除會話cookie
-	c.SetCookie("session_id", "", -1, "/", "", false, true)
+	h.setSessionCookie(c, "", -1)
 	c.JSON(http.StatusOK, gin.H{"message": "登出成功"})
 }
 
@@ -159,3 +427,226 @@ func (h *UserHandler) GetCurrentUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, user)
 }
+
+// UpdateDisplayName 更新當前登入用戶的顯示名稱
+func (h *UserHandler) UpdateDisplayName(c *gin.Context) {
+	var req UpdateDisplayNameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求格式"})
+		return
+	}
+
+	user, err := h.userService.UpdateDisplayName(currentUserID(c), req.DisplayName)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidDisplayName) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新顯示名稱失敗"})
+		return
+	}
+
+	h.refreshSessionUser(c, user)
+
+	c.JSON(http.StatusOK, middleware.UserResponse{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Email:       user.Email,
+		Role:        user.Role,
+	})
+}
+
+// UpdateProfile 更新當前登入用戶的電子郵件與用戶名
+func (h *UserHandler) UpdateProfile(c *gin.Context) {
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求格式"})
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(currentUserID(c), req.Email, req.Username)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidUsername),
+			errors.Is(err, service.ErrReservedUsername),
+			errors.Is(err, service.ErrInvalidEmail):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrUserAlreadyExists),
+			errors.Is(err, repository.ErrEmailAlreadyExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新個人資料失敗"})
+		}
+		return
+	}
+
+	h.refreshSessionUser(c, user)
+
+	c.JSON(http.StatusOK, middleware.UserResponse{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Email:       user.Email,
+		Role:        user.Role,
+	})
+}
+
+// ChangePassword 更換當前登入用戶的密碼，需要提供目前的密碼
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求格式"})
+		return
+	}
+
+	if err := h.userService.ChangePassword(currentUserID(c), req.CurrentPassword, req.NewPassword); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidCredentials):
+			c.JSON(http.StatusForbidden, gin.H{"error": "目前密碼錯誤"})
+		case errors.Is(err, service.ErrWeakPassword),
+			errors.Is(err, service.ErrSamePassword):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更換密碼失敗"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "密碼已更換"})
+}
+
+// refreshSessionUser 同步更新會話中的用戶資料，保留原本的過期時間
+func (h *UserHandler) refreshSessionUser(c *gin.Context, user *model.User) {
+	if sessionID, err := c.Cookie("session_id"); err == nil {
+		if session, err := h.sessionStore.Get(sessionID); err == nil {
+			h.sessionStore.Set(sessionID, &middleware.Session{User: user, ExpiresAt: session.ExpiresAt})
+		}
+	}
+}
+
+// DeleteAccount 確認密碼後刪除登入用戶的帳號並登出
+//
+// 刪除前先依設定處理用戶創建的聊天室，並將用戶移出所有聊天室。
+// 帳號為軟刪除，用戶名與電子郵件不會釋出供重新註冊
+func (h *UserHandler) DeleteAccount(c *gin.Context) {
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "請輸入密碼以確認刪除帳號"})
+		return
+	}
+
+	userID := currentUserID(c)
+	if err := h.userService.CheckPassword(userID, req.Password); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidCredentials):
+			c.JSON(http.StatusForbidden, gin.H{"error": "密碼錯誤"})
+		case errors.Is(err, repository.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "用戶不存在"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "刪除帳號失敗"})
+		}
+		return
+	}
+
+	if h.roomReleaser != nil {
+		if _, err := h.roomReleaser.ReleaseOwnedRooms(userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "處理用戶的聊天室失敗"})
+			return
+		}
+		if err := h.roomReleaser.LeaveAllRooms(userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "處理用戶的聊天室失敗"})
+			return
+		}
+	}
+
+	if err := h.userService.DeleteUser(userID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用戶不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "刪除帳號失敗"})
+		return
+	}
+
+	// 清除會話
+	if sessionID, err := c.Cookie("session_id"); err == nil {
+		h.sessionStore.Delete(sessionID)
+	}
+	h.setSessionCookie(c, "", -1)
+
+	c.JSON(http.StatusOK, gin.H{"message": "帳號已刪除，用戶名與電子郵件不會釋出供重新註冊"})
+}
+
+// passwordResetRequestedMessage 是申請密碼重設的響應訊息，電子郵件是否存在都返回相同內容以免洩漏帳號資訊
+const passwordResetRequestedMessage = "若該電子郵件已註冊，將會收到密碼重設說明"
+
+// RequestPasswordReset 處理申請密碼重設請求，產生的令牌目前寫入日誌
+func (h *UserHandler) RequestPasswordReset(c *gin.Context) {
+	var req PasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求格式"})
+		return
+	}
+
+	resetToken, err := h.userService.RequestPasswordReset(req.Email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusOK, gin.H{"message": passwordResetRequestedMessage})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "申請密碼重設失敗"})
+		return
+	}
+
+	h.resetLogger.Info("password_reset user=%s token=%s expires=%s",
+		resetToken.UserID, resetToken.Token, resetToken.ExpiresAt.UTC().Format(time.RFC3339))
+	c.JSON(http.StatusOK, gin.H{"message": passwordResetRequestedMessage})
+}
+
+// ConfirmPasswordReset 處理確認密碼重設請求，以令牌設定新密碼
+func (h *UserHandler) ConfirmPasswordReset(c *gin.Context) {
+	var req PasswordResetConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求格式"})
+		return
+	}
+
+	if err := h.userService.ResetPassword(req.Token, req.Password); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrResetTokenNotFound),
+			errors.Is(err, repository.ErrResetTokenUsed),
+			errors.Is(err, service.ErrResetTokenExpired),
+			errors.Is(err, service.ErrWeakPassword):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "重設密碼失敗"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "密碼已重設"})
+}
+
+// VerifyEmail 處理電子郵件驗證請求，令牌以查詢參數 token 傳入
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少驗證令牌"})
+		return
+	}
+
+	if _, err := h.userService.VerifyEmail(token); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrVerificationTokenNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrAlreadyVerified):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "驗證電子郵件失敗"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "電子郵件已驗證"})
+}
@@ -4,10 +4,16 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"livechat/backend/middleware"
 	"livechat/backend/model"
+	"livechat/backend/repository"
+	"livechat/backend/service"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -43,11 +49,100 @@ func (m *MockUserService) GetUserByID(id string) (*model.User, error) {
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
+func (m *MockUserService) GetUserByUsername(username string) (*model.User, error) {
+	args := m.Called(username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
 func (m *MockUserService) IsAdmin(user *model.User) bool {
 	args := m.Called(user)
 	return args.Bool(0)
 }
 
+func (m *MockUserService) UpdateDisplayName(userID, displayName string) (*model.User, error) {
+	args := m.Called(userID, displayName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockUserService) UpdateProfile(userID, newEmail, newUsername string) (*model.User, error) {
+	args := m.Called(userID, newEmail, newUsername)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockUserService) ChangePassword(userID, oldPassword, newPassword string) error {
+	args := m.Called(userID, oldPassword, newPassword)
+	return args.Error(0)
+}
+
+func (m *MockUserService) CheckPassword(userID, password string) error {
+	args := m.Called(userID, password)
+	return args.Error(0)
+}
+
+func (m *MockUserService) PasswordRequirements() service.PasswordPolicy {
+	args := m.Called()
+	return args.Get(0).(service.PasswordPolicy)
+}
+
+func (m *MockUserService) DeleteUser(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockUserService) RequestPasswordReset(email string) (*model.PasswordResetToken, error) {
+	args := m.Called(email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PasswordResetToken), args.Error(1)
+}
+
+func (m *MockUserService) ResetPassword(token, newPassword string) error {
+	args := m.Called(token, newPassword)
+	return args.Error(0)
+}
+
+func (m *MockUserService) GenerateVerificationToken(userID string) (*model.EmailVerificationToken, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.EmailVerificationToken), args.Error(1)
+}
+
+func (m *MockUserService) VerifyEmail(token string) (*model.User, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockUserService) SendDirectMessage(senderID, recipientID, content string) (*model.DirectMessage, error) {
+	args := m.Called(senderID, recipientID, content)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.DirectMessage), args.Error(1)
+}
+
+func (m *MockUserService) GetDirectMessages(userID, otherUserID string, limit int) ([]model.DirectMessage, error) {
+	args := m.Called(userID, otherUserID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.DirectMessage), args.Error(1)
+}
+
 // 設置 Gin 測試環境
 func setupUserRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
@@ -84,6 +179,7 @@ func TestRegisterSuccess(t *testing.T) {
 	}
 
 	mockService.On("RegisterUser", "testuser", "test@example.com", "Password123").Return(user, nil)
+	mockService.On("GenerateVerificationToken", "1").Return(&model.EmailVerificationToken{Token: "verify-token", UserID: "1"}, nil)
 
 	// 創建請求
 	reqBody := RegisterRequest{
@@ -102,12 +198,13 @@ func TestRegisterSuccess(t *testing.T) {
 	// 斷言 (Assert)
 	assert.Equal(t, http.StatusCreated, w.Code, "狀態碼應該是 201")
 
-	var response UserResponse
+	var response RegisterResponse
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err, "應該能夠解析響應")
 	assert.Equal(t, "testuser", response.Username, "用戶名應該匹配")
 	assert.Equal(t, "test@example.com", response.Email, "電子郵件應該匹配")
 	assert.Equal(t, "user", response.Role, "角色應該匹配")
+	assert.Equal(t, "verify-token", response.VerificationToken, "應該返回電子郵件驗證令牌")
 
 	mockService.AssertExpectations(t)
 }
@@ -137,33 +234,60 @@ func TestRegisterInvalidRequest(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code, "狀態碼應該是 400")
 }
 
-// 測試註冊用戶 - 用戶名已存在
-func TestRegisterUsernameExists(t *testing.T) {
-	// 安排 (Arrange)
-	mockService := new(MockUserService)
-	handler := NewUserHandler(mockService)
-	router := setupUserRouter()
-	handler.RegisterRoutes(router)
+// 測試註冊用戶 - 已知錯誤對應到穩定的錯誤代碼與狀態碼
+func TestRegisterErrorCodes(t *testing.T) {
+	testCases := []struct {
+		name            string
+		serviceErr      error
+		expectedStatus  int
+		expectedCode    string
+		expectedMessage string
+	}{
+		{name: "密碼強度不足", serviceErr: service.ErrWeakPassword, expectedStatus: http.StatusUnprocessableEntity, expectedCode: "weak_password", expectedMessage: service.ErrWeakPassword.Error()},
+		{name: "用戶名已存在", serviceErr: repository.ErrUserAlreadyExists, expectedStatus: http.StatusConflict, expectedCode: "username_taken", expectedMessage: repository.ErrUserAlreadyExists.Error()},
+		{name: "電子郵件已存在", serviceErr: repository.ErrEmailAlreadyExists, expectedStatus: http.StatusConflict, expectedCode: "email_taken", expectedMessage: repository.ErrEmailAlreadyExists.Error()},
+		{name: "未知錯誤", serviceErr: errors.New("db down"), expectedStatus: http.StatusInternalServerError, expectedCode: "internal_error", expectedMessage: "註冊失敗，請稍後再試"},
+	}
 
-	mockService.On("RegisterUser", "existinguser", "test@example.com", "Password123").Return(nil, errors.New("用戶名已被使用"))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 安排 (Arrange)
+			mockService := new(MockUserService)
+			mockLogger := new(MockLogger)
+			mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+			handler := NewUserHandler(mockService, WithErrorLogger(mockLogger))
+			router := setupUserRouter()
+			handler.RegisterRoutes(router)
 
-	// 創建請求
-	reqBody := RegisterRequest{
-		Username: "existinguser",
-		Email:    "test@example.com",
-		Password: "Password123",
-	}
-	reqJSON, _ := json.Marshal(reqBody)
-	req, _ := http.NewRequest("POST", "/api/register", bytes.NewBuffer(reqJSON))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
+			mockService.On("RegisterUser", "existinguser", "test@example.com", "Password123").Return(nil, tc.serviceErr)
 
-	// 動作 (Act)
-	router.ServeHTTP(w, req)
+			reqJSON, _ := json.Marshal(RegisterRequest{
+				Username: "existinguser",
+				Email:    "test@example.com",
+				Password: "Password123",
+			})
+			req, _ := http.NewRequest("POST", "/api/register", bytes.NewBuffer(reqJSON))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
 
-	// 斷言 (Assert)
-	assert.Equal(t, http.StatusBadRequest, w.Code, "狀態碼應該是 400")
-	mockService.AssertExpectations(t)
+			// 動作 (Act)
+			router.ServeHTTP(w, req)
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.expectedStatus, w.Code, "狀態碼應該匹配")
+			var response map[string]string
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+			assert.Equal(t, tc.expectedCode, response["error"], "錯誤代碼應該匹配")
+			assert.Equal(t, tc.expectedMessage, response["message"], "應該包含可顯示的錯誤訊息")
+			if tc.expectedStatus == http.StatusInternalServerError {
+				assert.NotContains(t, w.Body.String(), "db down", "響應不應該透露內部錯誤")
+				mockLogger.AssertCalled(t, "Error", mock.Anything, mock.Anything)
+			} else {
+				mockLogger.AssertNotCalled(t, "Error", mock.Anything, mock.Anything)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
 }
 
 // 測試登入用戶 - 成功
@@ -237,6 +361,123 @@ func TestLoginInvalidCredentials(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+// 測試登入稽核記錄
+//
+// 測試目標：
+// 1. 成功與失敗的登入各產生一筆包含結果、用戶名、IP 與時間的稽核記錄
+// 2. 用戶名存在但密碼錯誤，與用戶名不存在的失敗記錄格式完全相同，不洩漏用戶是否存在
+func TestLoginAudit(t *testing.T) {
+	// 安排 (Arrange)：使用真實的用戶服務與記憶體資料庫
+	fixed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	model.SetTimeNow(func() time.Time { return fixed })
+	defer model.ResetTimeNow()
+
+	userService := service.NewUserService(repository.NewUserRepository(repository.NewMockDBWithSchema()))
+	_, err := userService.RegisterUser("alice", "alice@example.com", "Password123")
+	assert.NoError(t, err, "註冊用戶不應該失敗")
+
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	handler := NewUserHandler(userService, WithLoginAudit(mockLogger))
+	router := setupUserRouter()
+	handler.RegisterRoutes(router)
+
+	login := func(username, password string) int {
+		reqJSON, _ := json.Marshal(LoginRequest{Username: username, Password: password})
+		req, _ := http.NewRequest("POST", "/api/login", bytes.NewBuffer(reqJSON))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "203.0.113.7:54321"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	record := func(i int) string {
+		call := mockLogger.Calls[i]
+		return fmt.Sprintf(call.Arguments.String(0), call.Arguments.Get(1).([]interface{})...)
+	}
+
+	// 動作 (Act)
+	wrongPasswordCode := login("alice", "WrongPassword")
+	unknownUserCode := login("ghost", "WrongPassword")
+	successCode := login("alice", "Password123")
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusUnauthorized, wrongPasswordCode, "密碼錯誤應該返回 401")
+	assert.Equal(t, http.StatusUnauthorized, unknownUserCode, "用戶不存在應該返回 401")
+	assert.Equal(t, http.StatusOK, successCode, "正確憑證應該登入成功")
+	if !assert.Len(t, mockLogger.Calls, 3, "每次登入嘗試都應該產生一筆稽核記錄") {
+		return
+	}
+	assert.Equal(t, `login_audit result=failure username="alice" ip=203.0.113.7 time=2024-01-02T03:04:05Z`, record(0), "密碼錯誤的稽核記錄應該匹配")
+	assert.Equal(t, `login_audit result=failure username="ghost" ip=203.0.113.7 time=2024-01-02T03:04:05Z`, record(1), "用戶不存在的稽核記錄應該匹配")
+	assert.Equal(t, `login_audit result=success username="alice" ip=203.0.113.7 time=2024-01-02T03:04:05Z`, record(2), "成功登入的稽核記錄應該匹配")
+	assert.Equal(t,
+		strings.Replace(record(0), `"alice"`, `"ghost"`, 1), record(1),
+		"兩種失敗記錄除用戶名外應該完全相同")
+}
+
+// 測試登入後的會話在後續請求中有效，登出後失效
+//
+// 測試目標：
+// 1. 登入返回的 session_id cookie 能讓會話中間件識別用戶
+// 2. 登出後同一個 cookie 不再被識別
+func TestLoginSessionRoundTrip(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockUserService)
+	store := middleware.NewMemorySessionStore()
+	handler := NewUserHandler(mockService, WithSessionStore(store))
+	router := setupUserRouter()
+	router.Use(middleware.SessionMiddleware(store))
+	handler.RegisterRoutes(router)
+
+	user := &model.User{ID: "1", Username: "testuser", Email: "test@example.com", Role: "user"}
+	mockService.On("LoginUser", "testuser", "Password123").Return(user, nil)
+
+	reqJSON, _ := json.Marshal(LoginRequest{Username: "testuser", Password: "Password123"})
+	loginReq, _ := http.NewRequest("POST", "/api/login", bytes.NewBuffer(reqJSON))
+	loginReq.Header.Set("Content-Type", "application/json")
+	loginW := httptest.NewRecorder()
+	router.ServeHTTP(loginW, loginReq)
+	assert.Equal(t, http.StatusOK, loginW.Code, "登入應該成功")
+
+	var sessionCookie *http.Cookie
+	for _, cookie := range loginW.Result().Cookies() {
+		if cookie.Name == "session_id" {
+			sessionCookie = cookie
+		}
+	}
+	if !assert.NotNil(t, sessionCookie, "登入應該設置 session_id cookie") {
+		return
+	}
+	assert.Equal(t, int(middleware.DefaultSessionTTL/time.Second), sessionCookie.MaxAge, "cookie 的 MaxAge 應該與會話有效時間一致")
+
+	// 動作 (Act)：攜帶 cookie 獲取當前用戶
+	userReq, _ := http.NewRequest("GET", "/api/user", nil)
+	userReq.AddCookie(sessionCookie)
+	userW := httptest.NewRecorder()
+	router.ServeHTTP(userW, userReq)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, userW.Code, "攜帶會話 cookie 應該能識別用戶")
+	var response middleware.UserResponse
+	assert.NoError(t, json.Unmarshal(userW.Body.Bytes(), &response), "應該能夠解析響應")
+	assert.Equal(t, "1", response.ID, "用戶 ID 應該匹配")
+	assert.Equal(t, "testuser", response.Username, "用戶名應該匹配")
+
+	// 動作 & 斷言：登出後會話失效
+	logoutReq, _ := http.NewRequest("GET", "/api/logout", nil)
+	logoutReq.AddCookie(sessionCookie)
+	router.ServeHTTP(httptest.NewRecorder(), logoutReq)
+
+	afterReq, _ := http.NewRequest("GET", "/api/user", nil)
+	afterReq.AddCookie(sessionCookie)
+	afterW := httptest.NewRecorder()
+	router.ServeHTTP(afterW, afterReq)
+	assert.Equal(t, http.StatusUnauthorized, afterW.Code, "登出後會話不應該再被識別")
+
+	mockService.AssertExpectations(t)
+}
+
 // 測試登出用戶
 func TestLogout(t *testing.T) {
 	// 安排 (Arrange)
@@ -268,3 +509,591 @@ func TestLogout(t *testing.T) {
 	assert.NotNil(t, sessionCookie, "應該有 session_id cookie")
 	assert.True(t, sessionCookie.MaxAge < 0, "cookie 應該被設置為過期")
 }
+
+// 測試更新顯示名稱
+func TestUpdateDisplayName(t *testing.T) {
+	testCases := []struct {
+		name           string
+		displayName    string
+		serviceResult  *model.User
+		serviceErr     error
+		expectedStatus int
+	}{
+		{
+			name:           "有效的顯示名稱",
+			displayName:    "小明",
+			serviceResult:  &model.User{ID: "1", Username: "testuser", DisplayName: "小明", Role: "user"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "過長的顯示名稱",
+			displayName:    strings.Repeat("長", 31),
+			serviceErr:     service.ErrInvalidDisplayName,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 安排 (Arrange)
+			mockService := new(MockUserService)
+			handler := NewUserHandler(mockService)
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				middleware.SetCurrentUser(c, &middleware.UserResponse{ID: "1", Username: "testuser"})
+				c.Next()
+			})
+			router.PUT("/api/user/display-name", handler.UpdateDisplayName)
+
+			if tc.serviceResult != nil {
+				mockService.On("UpdateDisplayName", "1", tc.displayName).Return(tc.serviceResult, nil)
+			} else {
+				mockService.On("UpdateDisplayName", "1", tc.displayName).Return(nil, tc.serviceErr)
+			}
+
+			reqJSON, _ := json.Marshal(UpdateDisplayNameRequest{DisplayName: tc.displayName})
+			req, _ := http.NewRequest("PUT", "/api/user/display-name", bytes.NewBuffer(reqJSON))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			// 動作 (Act)
+			router.ServeHTTP(w, req)
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.expectedStatus, w.Code, "狀態碼應該匹配")
+			if tc.expectedStatus == http.StatusOK {
+				var response UserResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+				assert.Equal(t, "testuser", response.Username, "登入用戶名不應該改變")
+				assert.Equal(t, "小明", response.DisplayName, "顯示名稱應該被更新")
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+// 測試正式與開發環境設定下會話 cookie 的安全屬性
+func TestSessionCookieAttributes(t *testing.T) {
+	testCases := []struct {
+		name           string
+		opts           []UserHandlerOption
+		expectSecure   bool
+		expectSameSite http.SameSite
+	}{
+		{
+			name:           "開發環境預設值",
+			expectSecure:   false,
+			expectSameSite: http.SameSiteLaxMode,
+		},
+		{
+			name:           "正式環境",
+			opts:           []UserHandlerOption{WithCookieConfig(CookieConfig{Secure: true, SameSite: http.SameSiteLaxMode})},
+			expectSecure:   true,
+			expectSameSite: http.SameSiteLaxMode,
+		},
+		{
+			name:           "嚴格 SameSite",
+			opts:           []UserHandlerOption{WithCookieConfig(CookieConfig{Secure: true, SameSite: http.SameSiteStrictMode})},
+			expectSecure:   true,
+			expectSameSite: http.SameSiteStrictMode,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 安排 (Arrange)
+			mockService := new(MockUserService)
+			handler := NewUserHandler(mockService, tc.opts...)
+			router := setupUserRouter()
+			handler.RegisterRoutes(router)
+
+			user := &model.User{ID: "1", Username: "testuser", Email: "test@example.com", Role: "user"}
+			mockService.On("LoginUser", "testuser", "Password123").Return(user, nil)
+
+			reqJSON, _ := json.Marshal(LoginRequest{Username: "testuser", Password: "Password123"})
+			req, _ := http.NewRequest("POST", "/api/login", bytes.NewBuffer(reqJSON))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			// 動作 (Act)
+			router.ServeHTTP(w, req)
+
+			// 斷言 (Assert)
+			assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+
+			cookies := w.Result().Cookies()
+			assert.Len(t, cookies, 1, "應該設置一個會話 cookie")
+			cookie := cookies[0]
+			assert.Equal(t, "session_id", cookie.Name, "cookie 名稱應該匹配")
+			assert.True(t, cookie.HttpOnly, "會話 cookie 應該一律是 HttpOnly")
+			assert.Equal(t, tc.expectSecure, cookie.Secure, "Secure 屬性應該匹配")
+			assert.Equal(t, tc.expectSameSite, cookie.SameSite, "SameSite 屬性應該匹配")
+		})
+	}
+}
+
+// 測試刪除帳號前會先處理用戶創建的聊天室並清除會話
+func TestDeleteAccount(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockUserService)
+	mockRooms := new(MockRoomService)
+	handler := NewUserHandler(mockService, WithOwnedRoomsReleaser(mockRooms))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetCurrentUser(c, &middleware.UserResponse{ID: "1", Username: "testuser"})
+		c.Next()
+	})
+	router.DELETE("/api/user", handler.DeleteAccount)
+
+	var released, left bool
+	mockService.On("CheckPassword", "1", "Password123").Return(nil)
+	mockRooms.On("ReleaseOwnedRooms", "1").Run(func(args mock.Arguments) {
+		released = true
+	}).Return([]model.Room{{ID: "room-1", CreatedBy: "admin-2"}}, nil)
+	mockRooms.On("LeaveAllRooms", "1").Run(func(args mock.Arguments) {
+		left = true
+	}).Return(nil)
+	mockService.On("DeleteUser", "1").Run(func(args mock.Arguments) {
+		assert.True(t, released, "刪除帳號前應該先處理用戶創建的聊天室")
+		assert.True(t, left, "刪除帳號前應該先將用戶移出所有聊天室")
+	}).Return(nil)
+
+	// 動作 (Act)
+	req, _ := http.NewRequest("DELETE", "/api/user", strings.NewReader(`{"password":"Password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1, "應該設置會話 cookie") {
+		assert.Equal(t, "session_id", cookies[0].Name, "應該清除會話 cookie")
+		assert.True(t, cookies[0].MaxAge < 0, "會話 cookie 應該立即過期")
+	}
+	mockService.AssertExpectations(t)
+	mockRooms.AssertExpectations(t)
+}
+
+// 測試刪除帳號 - 缺少或錯誤的確認密碼時不刪除任何資料
+func TestDeleteAccountRequiresPassword(t *testing.T) {
+	testCases := []struct {
+		name           string
+		body           string
+		checkErr       error
+		expectedStatus int
+	}{
+		{name: "缺少密碼", body: `{}`, expectedStatus: http.StatusBadRequest},
+		{name: "密碼錯誤", body: `{"password":"WrongPassword1"}`, checkErr: repository.ErrInvalidCredentials, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 安排 (Arrange)
+			mockService := new(MockUserService)
+			mockRooms := new(MockRoomService)
+			mockService.On("CheckPassword", "1", "WrongPassword1").Return(tc.checkErr)
+			handler := NewUserHandler(mockService, WithOwnedRoomsReleaser(mockRooms))
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				middleware.SetCurrentUser(c, &middleware.UserResponse{ID: "1", Username: "testuser"})
+				c.Next()
+			})
+			router.DELETE("/api/user", handler.DeleteAccount)
+
+			req, _ := http.NewRequest("DELETE", "/api/user", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			// 動作 (Act)
+			router.ServeHTTP(w, req)
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.expectedStatus, w.Code, "狀態碼應該匹配")
+			mockRooms.AssertNotCalled(t, "ReleaseOwnedRooms", mock.Anything)
+			mockRooms.AssertNotCalled(t, "LeaveAllRooms", mock.Anything)
+			mockService.AssertNotCalled(t, "DeleteUser", mock.Anything)
+		})
+	}
+}
+
+// 測試啟用存取令牌時登入返回可驗證的 JWT
+func TestLoginReturnsToken(t *testing.T) {
+	// 安排 (Arrange)
+	mockService := new(MockUserService)
+	tokenService := service.NewTokenService([]byte("secret"), time.Hour)
+	handler := NewUserHandler(mockService, WithTokenService(tokenService))
+	router := setupUserRouter()
+	handler.RegisterRoutes(router)
+
+	user := &model.User{ID: "1", Username: "testuser", Email: "test@example.com", Role: "admin"}
+	mockService.On("LoginUser", "testuser", "Password123").Return(user, nil)
+
+	// 動作 (Act)
+	reqJSON, _ := json.Marshal(LoginRequest{Username: "testuser", Password: "Password123"})
+	req, _ := http.NewRequest("POST", "/api/login", bytes.NewBuffer(reqJSON))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, w.Code, "登入應該成功")
+	var response LoginResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+	assert.Equal(t, "testuser", response.Username, "用戶名應該匹配")
+
+	claims, err := tokenService.ValidateToken(response.Token)
+	assert.NoError(t, err, "返回的令牌應該有效")
+	assert.Equal(t, "1", claims.Subject, "令牌中的用戶 ID 應該匹配")
+	assert.Equal(t, "admin", claims.Role, "令牌中的角色應該匹配")
+}
+
+// 測試完整的密碼重設流程
+//
+// 測試目標：
+// 1. 申請重設後令牌寫入日誌，響應不包含令牌
+// 2. 以令牌設定新密碼後可用新密碼登入
+// 3. 已使用的令牌不能再次使用
+// 4. 未註冊的電子郵件返回相同響應，不洩漏帳號是否存在
+func TestPasswordResetFlow(t *testing.T) {
+	// 安排 (Arrange)：使用真實的用戶服務與記憶體資料庫
+	userService := service.NewUserService(repository.NewUserRepository(repository.NewMockDBWithSchema()))
+	_, err := userService.RegisterUser("alice", "alice@example.com", "Password123")
+	assert.NoError(t, err, "註冊用戶不應該失敗")
+
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	handler := NewUserHandler(userService, WithPasswordResetLogger(mockLogger))
+	router := setupUserRouter()
+	handler.RegisterRoutes(router)
+
+	post := func(path string, body interface{}) *httptest.ResponseRecorder {
+		reqJSON, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", path, bytes.NewBuffer(reqJSON))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 動作 (Act)
+	requestResp := post("/api/password-reset/request", PasswordResetRequest{Email: "alice@example.com"})
+	unknownResp := post("/api/password-reset/request", PasswordResetRequest{Email: "ghost@example.com"})
+	if !assert.Len(t, mockLogger.Calls, 1, "只有已註冊的電子郵件應該產生令牌") {
+		return
+	}
+	token := mockLogger.Calls[0].Arguments.Get(1).([]interface{})[1].(string)
+	confirmResp := post("/api/password-reset/confirm", PasswordResetConfirmRequest{Token: token, Password: "NewPassword1"})
+	reuseResp := post("/api/password-reset/confirm", PasswordResetConfirmRequest{Token: token, Password: "OtherPassword1"})
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, requestResp.Code, "申請密碼重設應該返回 200")
+	assert.NotContains(t, requestResp.Body.String(), token, "響應不應該包含令牌")
+	assert.Equal(t, http.StatusOK, unknownResp.Code, "未註冊的電子郵件也應該返回 200")
+	assert.Equal(t, requestResp.Body.String(), unknownResp.Body.String(), "兩種情況的響應應該相同")
+	assert.Equal(t, http.StatusOK, confirmResp.Code, "使用有效令牌重設密碼應該返回 200")
+	assert.Equal(t, http.StatusBadRequest, reuseResp.Code, "重複使用令牌應該返回 400")
+	_, err = userService.LoginUser("alice", "NewPassword1")
+	assert.NoError(t, err, "應該可以使用新密碼登入")
+}
+
+// 測試確認密碼重設的失敗情況
+func TestConfirmPasswordResetFailures(t *testing.T) {
+	testCases := []struct {
+		name         string
+		body         string
+		serviceErr   error
+		expectedCode int
+	}{
+		{name: "缺少欄位", body: `{"token":"token"}`, expectedCode: http.StatusBadRequest},
+		{name: "令牌不存在", body: `{"token":"token","password":"NewPassword1"}`, serviceErr: repository.ErrResetTokenNotFound, expectedCode: http.StatusBadRequest},
+		{name: "令牌已過期", body: `{"token":"token","password":"NewPassword1"}`, serviceErr: service.ErrResetTokenExpired, expectedCode: http.StatusBadRequest},
+		{name: "令牌已被使用", body: `{"token":"token","password":"NewPassword1"}`, serviceErr: repository.ErrResetTokenUsed, expectedCode: http.StatusBadRequest},
+		{name: "新密碼強度不足", body: `{"token":"token","password":"NewPassword1"}`, serviceErr: service.ErrWeakPassword, expectedCode: http.StatusBadRequest},
+		{name: "內部錯誤", body: `{"token":"token","password":"NewPassword1"}`, serviceErr: errors.New("db down"), expectedCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 安排 (Arrange)
+			mockService := new(MockUserService)
+			mockService.On("ResetPassword", "token", "NewPassword1").Return(tc.serviceErr)
+			handler := NewUserHandler(mockService)
+			router := setupUserRouter()
+			handler.RegisterRoutes(router)
+
+			req, _ := http.NewRequest("POST", "/api/password-reset/confirm", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			// 動作 (Act)
+			router.ServeHTTP(w, req)
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.expectedCode, w.Code, "狀態碼應該匹配")
+		})
+	}
+}
+
+// 測試電子郵件驗證端點
+//
+// 測試目標：
+// 1. 註冊返回的驗證令牌可以驗證電子郵件
+// 2. 重複驗證返回 409，無效或缺少令牌返回 400
+// 3. 啟用驗證要求時，未驗證的用戶不能創建聊天室
+func TestVerifyEmailEndpoint(t *testing.T) {
+	// 安排 (Arrange)：使用真實的用戶服務與記憶體資料庫
+	userService := service.NewUserService(repository.NewUserRepository(repository.NewMockDBWithSchema()))
+	handler := NewUserHandler(userService)
+	router := setupUserRouter()
+	handler.RegisterRoutes(router)
+
+	reqJSON, _ := json.Marshal(RegisterRequest{Username: "alice", Email: "alice@example.com", Password: "Password123"})
+	req, _ := http.NewRequest("POST", "/api/register", bytes.NewBuffer(reqJSON))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var registered RegisterResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &registered), "應該能夠解析註冊響應")
+	if !assert.NotEmpty(t, registered.VerificationToken, "註冊應該返回驗證令牌") {
+		return
+	}
+
+	verify := func(query string) int {
+		req, _ := http.NewRequest("GET", "/api/verify"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 動作 (Act)
+	verifyCode := verify("?token=" + registered.VerificationToken)
+	doubleCode := verify("?token=" + registered.VerificationToken)
+	invalidCode := verify("?token=invalid-token")
+	missingCode := verify("")
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, verifyCode, "有效令牌應該驗證成功")
+	assert.Equal(t, http.StatusConflict, doubleCode, "重複驗證應該返回 409")
+	assert.Equal(t, http.StatusBadRequest, invalidCode, "無效令牌應該返回 400")
+	assert.Equal(t, http.StatusBadRequest, missingCode, "缺少令牌應該返回 400")
+	user, err := userService.GetUserByID(registered.ID)
+	assert.NoError(t, err, "查詢用戶不應該失敗")
+	assert.True(t, user.IsVerified, "用戶應該被標記為已驗證")
+}
+
+// 測試啟用驗證要求時，只有已驗證電子郵件的用戶可以創建聊天室
+func TestVerifiedRoomCreation(t *testing.T) {
+	testCases := []struct {
+		name         string
+		loggedIn     bool
+		verified     bool
+		expectedCode int
+	}{
+		{name: "未登入", expectedCode: http.StatusUnauthorized},
+		{name: "未驗證", loggedIn: true, expectedCode: http.StatusForbidden},
+		{name: "已驗證", loggedIn: true, verified: true, expectedCode: http.StatusCreated},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 安排 (Arrange)
+			mockUserService := new(MockUserService)
+			mockUserService.On("GetUserByID", "1").Return(&model.User{ID: "1", IsVerified: tc.verified}, nil)
+			mockRoomService := new(MockRoomService)
+			mockRoomService.On("CreateRoom", mock.Anything, "1").Return(&model.Room{ID: "room-1", Name: "新聊天室"}, nil)
+			handler := NewRoomHandler(mockRoomService, WithVerifiedRoomCreation(mockUserService))
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			if tc.loggedIn {
+				router.Use(func(c *gin.Context) {
+					middleware.SetCurrentUser(c, &middleware.UserResponse{ID: "1", Username: "alice"})
+					c.Next()
+				})
+			}
+			handler.RegisterRoutes(router)
+
+			req, _ := http.NewRequest("POST", "/api/rooms", strings.NewReader(`{"name":"新聊天室"}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			// 動作 (Act)
+			router.ServeHTTP(w, req)
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.expectedCode, w.Code, "狀態碼應該匹配")
+		})
+	}
+}
+
+// 測試更新個人資料的狀態碼對應
+func TestUpdateProfile(t *testing.T) {
+	testCases := []struct {
+		name           string
+		serviceResult  *model.User
+		serviceErr     error
+		expectedStatus int
+	}{
+		{
+			name:           "成功更新",
+			serviceResult:  &model.User{ID: "1", Username: "alice2", Email: "alice@example.org", Role: "user"},
+			expectedStatus: http.StatusOK,
+		},
+		{name: "無效的電子郵件", serviceErr: service.ErrInvalidEmail, expectedStatus: http.StatusBadRequest},
+		{name: "用戶名衝突", serviceErr: repository.ErrUserAlreadyExists, expectedStatus: http.StatusConflict},
+		{name: "電子郵件衝突", serviceErr: repository.ErrEmailAlreadyExists, expectedStatus: http.StatusConflict},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 安排 (Arrange)
+			mockService := new(MockUserService)
+			handler := NewUserHandler(mockService)
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				middleware.SetCurrentUser(c, &middleware.UserResponse{ID: "1", Username: "alice"})
+				c.Next()
+			})
+			handler.RegisterRoutes(router)
+
+			if tc.serviceResult != nil {
+				mockService.On("UpdateProfile", "1", "alice@example.org", "alice2").Return(tc.serviceResult, nil)
+			} else {
+				mockService.On("UpdateProfile", "1", "alice@example.org", "alice2").Return(nil, tc.serviceErr)
+			}
+
+			reqJSON, _ := json.Marshal(UpdateProfileRequest{Email: "alice@example.org", Username: "alice2"})
+			req, _ := http.NewRequest("PUT", "/api/user", bytes.NewBuffer(reqJSON))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			// 動作 (Act)
+			router.ServeHTTP(w, req)
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.expectedStatus, w.Code, "狀態碼應該匹配")
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+// 測試更換密碼的狀態碼對應
+func TestChangePassword(t *testing.T) {
+	testCases := []struct {
+		name           string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{name: "成功更換", expectedStatus: http.StatusOK},
+		{name: "目前密碼錯誤", serviceErr: repository.ErrInvalidCredentials, expectedStatus: http.StatusForbidden},
+		{name: "新密碼強度不足", serviceErr: service.ErrWeakPassword, expectedStatus: http.StatusBadRequest},
+		{name: "新密碼與目前相同", serviceErr: service.ErrSamePassword, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 安排 (Arrange)
+			mockService := new(MockUserService)
+			mockService.On("ChangePassword", "1", "Password123", "NewPassword1").Return(tc.serviceErr)
+			handler := NewUserHandler(mockService)
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				middleware.SetCurrentUser(c, &middleware.UserResponse{ID: "1", Username: "alice"})
+				c.Next()
+			})
+			handler.RegisterRoutes(router)
+
+			reqJSON, _ := json.Marshal(ChangePasswordRequest{CurrentPassword: "Password123", NewPassword: "NewPassword1"})
+			req, _ := http.NewRequest("POST", "/api/user/password", bytes.NewBuffer(reqJSON))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			// 動作 (Act)
+			router.ServeHTTP(w, req)
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.expectedStatus, w.Code, "狀態碼應該匹配")
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+// 測試取得密碼強度規則
+func TestGetPasswordRequirements(t *testing.T) {
+	// 安排 (Arrange)
+	policy := service.PasswordPolicy{MinLength: 12, RequireSpecial: true}
+	userService := service.NewUserService(repository.NewUserRepository(repository.NewMockDB()), service.WithPasswordPolicy(policy))
+	handler := NewUserHandler(userService)
+	router := setupUserRouter()
+	handler.RegisterRoutes(router)
+
+	req, _ := http.NewRequest("GET", "/api/password-requirements", nil)
+	w := httptest.NewRecorder()
+
+	// 動作 (Act)
+	router.ServeHTTP(w, req)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+	var response service.PasswordPolicy
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+	assert.Equal(t, policy, response, "應該返回設置的密碼規則")
+}
+
+// 測試登入失敗次數過多時鎖定
+//
+// 測試目標：
+// 1. 失敗達上限後，即使密碼正確也返回 429
+// 2. 存在與不存在的用戶名被鎖定時響應相同
+// 3. 鎖定期間過後可以正常登入
+func TestLoginLockout(t *testing.T) {
+	// 安排 (Arrange)：使用真實的用戶服務與記憶體資料庫
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time { return now })
+	defer model.ResetTimeNow()
+
+	userService := service.NewUserService(repository.NewUserRepository(repository.NewMockDBWithSchema()))
+	_, err := userService.RegisterUser("alice", "alice@example.com", "Password123")
+	assert.NoError(t, err, "註冊用戶不應該失敗")
+
+	handler := NewUserHandler(userService, WithLoginLimiter(service.NewLoginLimiter(2, time.Minute)))
+	router := setupUserRouter()
+	handler.RegisterRoutes(router)
+
+	login := func(username, password, ip string) *httptest.ResponseRecorder {
+		reqJSON, _ := json.Marshal(LoginRequest{Username: username, Password: password})
+		req, _ := http.NewRequest("POST", "/api/login", bytes.NewBuffer(reqJSON))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":54321"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 動作 (Act)
+	login("alice", "WrongPassword1", "203.0.113.1")
+	login("alice", "WrongPassword1", "203.0.113.1")
+	login("ghost", "WrongPassword1", "203.0.113.2")
+	login("ghost", "WrongPassword1", "203.0.113.2")
+	lockedResp := login("alice", "Password123", "203.0.113.3")
+	ghostResp := login("ghost", "Password123", "203.0.113.4")
+	now = now.Add(time.Minute)
+	afterWindowResp := login("alice", "Password123", "203.0.113.1")
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusTooManyRequests, lockedResp.Code, "鎖定期間即使密碼正確也應該返回 429")
+	assert.Equal(t, http.StatusTooManyRequests, ghostResp.Code, "不存在的用戶名同樣會被鎖定")
+	assert.Equal(t, lockedResp.Body.String(), ghostResp.Body.String(), "鎖定響應不應洩漏用戶名是否存在")
+	assert.Equal(t, http.StatusOK, afterWindowResp.Code, "鎖定期間過後應該可以登入")
+}
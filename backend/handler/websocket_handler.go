@@ -2,12 +2,23 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"livechat/backend/metrics"
+	"livechat/backend/middleware"
 	"livechat/backend/model"
+	"livechat/backend/repository"
 	"livechat/backend/service"
+	"net"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
@@ -16,25 +27,139 @@ type MessagePayload struct {
 	Type    string `json:"type"`
 	Content string `json:"content"`
 	Target  string `json:"target,omitempty"` // 用於私人訊息
+	Before  uint   `json:"before,omitempty"` // 用於載入歷史訊息的游標（訊息 ID）
+	Limit   int    `json:"limit,omitempty"`  // 用於載入歷史訊息的筆數
+
+	IsTyping  *bool `json:"isTyping,omitempty"`  // 用於輸入中提示，未提供時視為正在輸入
+	MessageID uint  `json:"messageId,omitempty"` // 用於編輯、刪除或回應訊息
+
+	Messages []MessagePayload `json:"messages,omitempty"` // 用於批次發送的訊息列表
+
+	ClientMsgID string `json:"clientMsgId,omitempty"` // 客戶端產生的訊息 ID，提供時伺服器會回覆 ack 或 nack
+}
+
+// nack 的原因
+const (
+	nackRateLimited     = "rate_limited"     // 超過訊息速率限制
+	nackInvalidContent  = "invalid_content"  // 內容為空、過長或包含不允許的字元
+	nackUnknownType     = "unknown_type"     // 不支援的訊息類型
+	nackNotRoomMember   = "not_room_member"  // 不是聊天室的有效成員
+	nackBroadcastFailed = "broadcast_failed" // 廣播失敗
+)
+
+// knownMessageTypes 列出 JSON 訊息支援的類型，空字串與 message 為一般訊息
+var knownMessageTypes = map[string]bool{
+	"":               true,
+	"message":        true,
+	"private":        true,
+	"join_room":      true,
+	"leave_room":     true,
+	"load_history":   true,
+	"batch":          true,
+	"typing":         true,
+	"edit_message":   true,
+	"delete_message": true,
+	"reaction":       true,
+}
+
+// BatchResult 描述批次發送中單則訊息的處理結果
+type BatchResult struct {
+	Index int    `json:"index"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
 }
 
+const (
+	defaultHistoryPageSize = 50
+	maxHistoryPageSize     = 100
+	defaultJoinHistorySize = 50
+	maxBatchSize           = 20
+)
+
+// 定義訊息處理錯誤
+var (
+	errNotRoomMember = errors.New("不是聊天室的有效成員，無法發送訊息")
+	errSelfDM        = errors.New("不能發送私人訊息給自己")
+	errEmptyContent  = errors.New("訊息內容不能為空")
+	errMissingTarget = errors.New("私人訊息必須指定目標")
+	errControlChars  = errors.New("訊息包含不允許的控制字元")
+	errContentLength = errors.New("訊息內容過長")
+
+	errLoginRequiredToCreate = errors.New("需要登入才能創建聊天室")
+	errJoinRoomFailed        = errors.New("加入聊天室失敗")
+)
+
+// DisconnectReason 定義客戶端斷線的原因分類
+type DisconnectReason string
+
+const (
+	DisconnectNormal  DisconnectReason = "normal"  // 客戶端正常關閉連接
+	DisconnectTimeout DisconnectReason = "timeout" // 讀取逾時（例如未回應 ping）
+	DisconnectError   DisconnectReason = "error"   // 其他非預期的連接錯誤
+)
+
 // BroadcastService 定義了廣播服務的接口
 type BroadcastService interface {
 	AddClient(client *model.Client) error
 	RemoveClient(clientID string) error
 	BroadcastMessage(message []byte) error
 	BroadcastToRoom(roomID string, message []byte) error
+	BroadcastToRoomExcept(roomID string, excludeClientID string, message []byte) error
+	BroadcastEventToRoom(roomID string, excludeClientID string, message []byte) error
 	SendPrivateMessage(targetID string, message []byte) error
 	GetClient(clientID string) (*model.Client, error)
 	GetMessageHistory(roomID string) []service.ChatMessage
 	GetClientsInRoom(roomID string) []*model.Client
+	GetClientsByUser(userID string) []*model.Client
+	ClearRoomLog(roomID string)
+}
+
+// DirectMessenger 定義保存用戶之間私人訊息的接口，由 service.UserService 實作
+type DirectMessenger interface {
+	GetUserByUsername(username string) (*model.User, error)
+	SendDirectMessage(senderID, recipientID, content string) (*model.DirectMessage, error)
+}
+
+// TypingTracker 定義在伺服器端記錄輸入中狀態的接口，由 service.TypingService 實作
+type TypingTracker interface {
+	SetTyping(roomID string, client *model.Client, isTyping bool)
+	ClearClient(clientID string)
 }
 
+// 連接參數的預設值
+const (
+	defaultReadLimit        int64 = 4096
+	defaultReadTimeout            = 60 * time.Second
+	defaultPingInterval           = 30 * time.Second
+	defaultMaxContentLength       = 2000
+)
+
 // WebSocketHandler 處理 WebSocket 連接
 type WebSocketHandler struct {
-	upgrader         websocket.Upgrader
-	broadcastService BroadcastService
-	logger           Logger
+	upgrader          websocket.Upgrader
+	allowedOrigins    []string // 允許跨來源連接的來源清單，空表示只允許相同來源
+	broadcastService  BroadcastService
+	roomService       RoomService
+	requireMembership bool
+	allowSelfDM       bool
+	autoCreateRooms   bool
+	rejectControls    bool
+	allowNewlines     bool
+	echoToSender      bool // 聊天室訊息是否也發送回發送者本身
+	guestSecret       []byte
+	guestCookieConfig CookieConfig
+	pingInterval      time.Duration
+	readLimit         int64           // 單一訊息的最大位元組數，超過時連接會被關閉
+	readTimeout       time.Duration   // 未收到任何訊息或 pong 時的讀取逾時，應大於 ping 間隔
+	maxContentLength  int             // 訊息內容的最大字元數，0 表示不限制
+	connSlots         chan struct{}   // 同時處理中的連接數上限，nil 表示不限制
+	connQueueTimeout  time.Duration   // 連接數已滿時新連接最多等待的時間，0 表示立即拒絕
+	typingTracker     TypingTracker   // 記錄輸入中狀態，nil 表示只轉發不記錄
+	directMessages    DirectMessenger // 保存用戶之間的私人訊息，nil 表示私人訊息只發送給在線的連接
+	rateLimiter       *rateLimiter    // 每個客戶端的訊息速率限制，nil 表示不限制
+	joinHistorySize   int             // 加入聊天室時發送給客戶端的最近訊息數，0 表示不發送
+	metrics           *metrics.Metrics
+	logger            Logger
 }
 
 // HandlerOption 定義處理器選項
@@ -47,6 +172,170 @@ func WithLogger(logger Logger) HandlerOption {
 	}
 }
 
+// WithTypingTracker 設置輸入中狀態的記錄器，讓重新連接的客戶端可以查詢目前正在輸入的用戶
+func WithTypingTracker(tracker TypingTracker) HandlerOption {
+	return func(h *WebSocketHandler) {
+		h.typingTracker = tracker
+	}
+}
+
+// WithDirectMessages 設置保存私人訊息的服務
+// 設置後已登入用戶的 private 訊息可以用用戶名（或對方的連接 ID）指定接收者，訊息依用戶 ID 保存，
+// 接收者在線時同時推送到其所有連接，離線時可在之後透過 GET /api/dm/:userId 取得
+func WithDirectMessages(directMessages DirectMessenger) HandlerOption {
+	return func(h *WebSocketHandler) {
+		h.directMessages = directMessages
+	}
+}
+
+// WithRoomService 設置聊天室服務，用於邀請連結等需要存取聊天室資料的功能
+func WithRoomService(roomService RoomService) HandlerOption {
+	return func(h *WebSocketHandler) {
+		h.roomService = roomService
+	}
+}
+
+// WithRequireMembership 設置是否要求用戶為聊天室成員才能加入及發送訊息，
+// 啟用後被移出聊天室的用戶必須重新被加入才能再次發言；斷線或離開聊天室不影響成員資格
+func WithRequireMembership(require bool) HandlerOption {
+	return func(h *WebSocketHandler) {
+		h.requireMembership = require
+	}
+}
+
+// WithAllowSelfDM 設置是否允許客戶端發送私人訊息給自己
+func WithAllowSelfDM(allow bool) HandlerOption {
+	return func(h *WebSocketHandler) {
+		h.allowSelfDM = allow
+	}
+}
+
+// WithAutoCreateRooms 設置 join_room 是否以名稱開啟聊天室，並在聊天室不存在時自動創建
+func WithAutoCreateRooms(enable bool) HandlerOption {
+	return func(h *WebSocketHandler) {
+		h.autoCreateRooms = enable
+	}
+}
+
+// WithRejectControlChars 設置是否拒絕包含空字元或其他控制字元的訊息
+func WithRejectControlChars(reject bool) HandlerOption {
+	return func(h *WebSocketHandler) {
+		h.rejectControls = reject
+	}
+}
+
+// WithAllowNewlines 設置拒絕控制字元時是否仍允許換行與定位字元
+func WithAllowNewlines(allow bool) HandlerOption {
+	return func(h *WebSocketHandler) {
+		h.allowNewlines = allow
+	}
+}
+
+// WithEchoToSender 設置聊天室訊息是否也發送回發送者，
+// 自行在本地顯示已發送訊息的客戶端可以停用以避免重複顯示
+func WithEchoToSender(echo bool) HandlerOption {
+	return func(h *WebSocketHandler) {
+		h.echoToSender = echo
+	}
+}
+
+// WithGuestIdentity 啟用匿名訪客的簽名 cookie，讓訪客重新連線後保持相同的 ID 與名稱
+func WithGuestIdentity(secret []byte, cookie CookieConfig) HandlerOption {
+	return func(h *WebSocketHandler) {
+		h.guestSecret = secret
+		h.guestCookieConfig = cookie
+	}
+}
+
+// WithPingInterval 設置向客戶端發送 ping 的間隔，非正數時保留預設值
+func WithPingInterval(interval time.Duration) HandlerOption {
+	return func(h *WebSocketHandler) {
+		if interval > 0 {
+			h.pingInterval = interval
+		}
+	}
+}
+
+// WithReadLimit 設置單一訊息的最大位元組數，客戶端送出更大的訊息時連接會被關閉，非正數時保留預設值
+func WithReadLimit(limit int64) HandlerOption {
+	return func(h *WebSocketHandler) {
+		if limit > 0 {
+			h.readLimit = limit
+		}
+	}
+}
+
+// WithMaxContentLength 設置訊息內容的最大字元數，小於等於 0 時不限制（仍受 WithReadLimit 的位元組上限約束）
+func WithMaxContentLength(max int) HandlerOption {
+	return func(h *WebSocketHandler) {
+		if max < 0 {
+			max = 0
+		}
+		h.maxContentLength = max
+	}
+}
+
+// WithReadTimeout 設置讀取逾時，連接在此時間內未回應 pong 即視為逾時斷線，非正數時保留預設值
+// 逾時應大於 ping 間隔，否則正常的連接也會被中斷
+func WithReadTimeout(timeout time.Duration) HandlerOption {
+	return func(h *WebSocketHandler) {
+		if timeout > 0 {
+			h.readTimeout = timeout
+		}
+	}
+}
+
+// WithMaxConnections 設置同時處理的連接數上限，每個連接佔用讀取與 ping 兩個 goroutine
+// 已滿時新連接最多排隊等待 queueTimeout，逾時或 queueTimeout 為 0 時以 503 拒絕；max 非正數表示不限制
+func WithMaxConnections(max int, queueTimeout time.Duration) HandlerOption {
+	return func(h *WebSocketHandler) {
+		if max <= 0 {
+			h.connSlots = nil
+			return
+		}
+		h.connSlots = make(chan struct{}, max)
+		h.connQueueTimeout = queueTimeout
+	}
+}
+
+// WithRateLimit 以令牌桶限制每個客戶端的訊息速率，每秒補充 msgsPerSecond 個令牌，最多累積 burst 個
+// 超過限制的訊息會被丟棄並回傳 rate_limited 通知，連接不會被中斷；msgsPerSecond 小於等於 0 時不限制
+func WithRateLimit(msgsPerSecond float64, burst int) HandlerOption {
+	return func(h *WebSocketHandler) {
+		if msgsPerSecond > 0 {
+			h.rateLimiter = newRateLimiter(msgsPerSecond, burst)
+		} else {
+			h.rateLimiter = nil
+		}
+	}
+}
+
+// WithCompression 設置是否與支援的客戶端協商 permessage-deflate 壓縮，
+// 啟用時會記錄每個連接是否實際協商成功。壓縮可以大幅減少大量或重複性高的訊息所需的頻寬，
+// 但每則訊息都需要額外的 CPU 進行壓縮與解壓縮，連接數多時應評估伺服器負載，默認關閉
+func WithCompression(enabled bool) HandlerOption {
+	return func(h *WebSocketHandler) {
+		h.upgrader.EnableCompression = enabled
+	}
+}
+
+// WithMetrics 設置記錄加入聊天室次數的 Prometheus 指標
+func WithMetrics(m *metrics.Metrics) HandlerOption {
+	return func(h *WebSocketHandler) {
+		h.metrics = m
+	}
+}
+
+// WithJoinHistorySize 設置加入聊天室時發送給客戶端的最近訊息數，小於等於 0 時不發送
+func WithJoinHistorySize(size int) HandlerOption {
+	return func(h *WebSocketHandler) {
+		if size < 0 {
+			size = 0
+		}
+		h.joinHistorySize = size
+	}
+}
+
 // WithCheckOrigin 設置來源檢查函數
 func WithCheckOrigin(checkOrigin func(r *http.Request) bool) HandlerOption {
 	return func(h *WebSocketHandler) {
@@ -54,6 +343,16 @@ func WithCheckOrigin(checkOrigin func(r *http.Request) bool) HandlerOption {
 	}
 }
 
+// WithAllowedOrigins 設置允許建立 WebSocket 連接的來源清單，支援完整來源與 *.example.com 形式的子網域萬用字元，
+// 符合的請求會以該來源作為 Access-Control-Allow-Origin 回應。"*" 表示允許所有來源，需明確設定；
+// 與伺服器相同的來源一律允許，未設置時只允許相同來源
+func WithAllowedOrigins(origins []string) HandlerOption {
+	return func(h *WebSocketHandler) {
+		h.allowedOrigins = origins
+		h.upgrader.CheckOrigin = originChecker(origins)
+	}
+}
+
 // Logger 定義日誌接口
 type Logger interface {
 	Info(msg string, args ...interface{})
@@ -75,13 +374,20 @@ func (l *DefaultLogger) Error(msg string, args ...interface{}) {
 func NewWebSocketHandler(broadcastService BroadcastService, opts ...HandlerOption) *WebSocketHandler {
 	h := &WebSocketHandler{
 		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true // 默認允許所有來源
-			},
+			// 未設置 CheckOrigin 時 gorilla/websocket 只允許與 Host 相同的來源
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
 		},
 		broadcastService: broadcastService,
+		allowSelfDM:      true, // 默認允許發送私人訊息給自己以保持相容
+		rejectControls:   true,
+		allowNewlines:    true,
+		echoToSender:     true, // 默認回傳給發送者以保持相容
+		pingInterval:     defaultPingInterval,
+		readLimit:        defaultReadLimit,
+		readTimeout:      defaultReadTimeout,
+		maxContentLength: defaultMaxContentLength,
+		joinHistorySize:  defaultJoinHistorySize,
 		logger:           &DefaultLogger{},
 	}
 
@@ -95,10 +401,62 @@ func NewWebSocketHandler(broadcastService BroadcastService, opts ...HandlerOptio
 
 // HandleConnection 處理新的 WebSocket 連接
 func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*") // 或限定域名
+	// 允許的跨來源請求以符合的來源回應 CORS 標頭，HTTP 錯誤與升級成功的回應都包含此標頭
+	responseHeader := http.Header{}
+	if origin, ok := matchOrigin(h.allowedOrigins, r.Header.Get("Origin")); ok {
+		responseHeader.Set("Access-Control-Allow-Origin", origin)
+		responseHeader.Set("Vary", "Origin")
+		for key, values := range responseHeader {
+			w.Header()[key] = values
+		}
+	}
+
+	// 取得連接名額，連接結束（本函數返回）時釋放
+	if !h.acquireConnectionSlot(r) {
+		h.logger.Error("Rejected connection from %s: connection limit reached", r.RemoteAddr)
+		http.Error(w, "伺服器連接數已達上限，請稍後再試", http.StatusServiceUnavailable)
+		return
+	}
+	defer h.releaseConnectionSlot()
+
+	// 從請求 context 獲取登入用戶（由會話中間件設置）
+	user, authenticated := middleware.UserFromContext(r.Context())
+
+	// 從查詢參數獲取聊天室 ID（如果有）
+	roomID := r.URL.Query().Get("roomId")
+
+	// 透過邀請碼加入聊天室需在升級前驗證，以便返回 HTTP 錯誤
+	if inviteToken := r.URL.Query().Get("invite"); inviteToken != "" {
+		if h.roomService == nil || !authenticated {
+			http.Error(w, "需要登入才能使用邀請", http.StatusUnauthorized)
+			return
+		}
+
+		room, err := h.roomService.AcceptInvite(inviteToken, user.ID)
+		if err != nil {
+			h.logger.Error("Failed to accept invite for user %s: %v", user.ID, err)
+			status := inviteErrorStatus(err)
+			http.Error(w, inviteErrorMessage(err, status), status)
+			return
+		}
+		roomID = room.ID
+	}
+
+	if roomID != "" && !h.canAccessRoom(user, roomID) {
+		http.Error(w, "不是聊天室的有效成員", http.StatusForbidden)
+		return
+	}
+
+	// 匿名訪客透過簽名 cookie 保持跨連線一致的身分
+	var guest *guestIdentity
+	if !authenticated && len(h.guestSecret) > 0 {
+		identity := h.resolveGuestIdentity(r, r.URL.Query().Get("username"))
+		guest = &identity
+		responseHeader.Add("Set-Cookie", h.guestCookie(identity).String())
+	}
 
 	// 將 HTTP 連接升級為 WebSocket 連接
-	conn, err := h.upgrader.Upgrade(w, r, nil)
+	conn, err := h.upgrader.Upgrade(w, r, responseHeader)
 	if err != nil {
 		h.logger.Error("Failed to upgrade connection: %v", err)
 		http.Error(w, "Could not open websocket connection", http.StatusBadRequest)
@@ -106,27 +464,49 @@ func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Reque
 	}
 
 	// 設置連接參數
-	conn.SetReadLimit(4096) // 限制讀取大小
-	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	conn.SetPongHandler(func(string) error {
-		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-		return nil
-	})
+	conn.SetReadLimit(h.readLimit) // 限制讀取大小
+	conn.SetReadDeadline(time.Now().Add(h.readTimeout))
 
 	// 為每個新連接創建一個唯一的 ID
 	clientID := fmt.Sprintf("%p", conn)
 	client := model.NewClient(clientID, conn)
 
+	// 收到 pong 表示連接仍然存活，延長讀取期限並更新活躍時間，避免只接收訊息的客戶端被視為閒置
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(h.readTimeout))
+		client.UpdateActivity()
+		return nil
+	})
+
+	if h.upgrader.EnableCompression {
+		client.SetCompressed(offersCompression(r))
+		conn.EnableWriteCompression(client.Compressed)
+		h.logger.Info("Client %s compression negotiated: %t", clientID, client.Compressed)
+	}
+
 	// 從查詢參數獲取用戶名（如果有）
 	userName := r.URL.Query().Get("username")
 	if userName != "" {
 		client.SetUserName(userName)
 	}
 
-	// 從查詢參數獲取聊天室 ID（如果有）
-	roomID := r.URL.Query().Get("roomId")
+	// 已登入的連接使用會話中的用戶身分
+	if authenticated {
+		client.SetUserID(user.ID)
+		client.SetUserName(user.Username)
+	} else if guest != nil {
+		client.SetGuestID(guest.ID)
+		client.SetUserName(guest.Name)
+	}
+
+	// 無法加入指定的聊天室（例如人數已滿）時，連接仍保留但不進入聊天室
+	var joinErr error
 	if roomID != "" {
-		client.SetRoomID(roomID)
+		if joinErr = h.recordJoin(client, roomID); joinErr != nil {
+			roomID = ""
+		} else {
+			client.SetRoomID(roomID)
+		}
 	}
 
 	// 將客戶端添加到服務
@@ -137,16 +517,26 @@ func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if joinErr != nil {
+		h.sendError(client, joinErr.Error())
+	}
+
 	h.logger.Info("New client connected: %s, Room: %s", clientID, roomID)
 
 	// 確保在連接關閉時清理資源
+	reason := DisconnectError
 	defer func() {
-		h.logger.Info("Client disconnected: %s", clientID)
+		h.logger.Info("Client disconnected: %s, reason: %s", clientID, reason)
+
+		h.clearTyping(client)
+		if h.rateLimiter != nil {
+			h.rateLimiter.Remove(clientID)
+		}
 
 		// 如果客戶端在聊天室中，發送離開通知
 		if client.RoomID != "" {
-			systemMsg := fmt.Sprintf("使用者 %s 已離開聊天室", client.UserName)
-			h.broadcastService.BroadcastToRoom(client.RoomID, []byte(systemMsg))
+			h.broadcastService.BroadcastToRoom(client.RoomID, presenceEvent(presenceLeave, client.UserName, client.RoomID, reason))
+			h.recordLeave(client, client.RoomID)
 		}
 
 		h.broadcastService.RemoveClient(clientID)
@@ -154,34 +544,78 @@ func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Reque
 	}()
 
 	// 啟動 ping 發送器
-	go h.startPingSender(conn)
+	go h.startPingSender(client)
 
 	// 處理接收到的訊息
-	h.handleMessages(conn, client)
+	reason = h.handleMessages(conn, client)
+}
+
+// acquireConnectionSlot 取得一個連接名額，名額已滿時最多等待 connQueueTimeout，
+// 等待期間請求被取消或逾時返回 false
+func (h *WebSocketHandler) acquireConnectionSlot(r *http.Request) bool {
+	if h.connSlots == nil {
+		return true
+	}
+
+	select {
+	case h.connSlots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if h.connQueueTimeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(h.connQueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case h.connSlots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// releaseConnectionSlot 釋放連接名額
+func (h *WebSocketHandler) releaseConnectionSlot() {
+	if h.connSlots != nil {
+		<-h.connSlots
+	}
 }
 
 // 啟動 ping 發送器
-func (h *WebSocketHandler) startPingSender(conn *websocket.Conn) {
-	ticker := time.NewTicker(30 * time.Second)
+func (h *WebSocketHandler) startPingSender(client *model.Client) {
+	ticker := time.NewTicker(h.pingInterval)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		if err := conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(10*time.Second)); err != nil {
+		// 透過客戶端的寫入鎖發送，避免與廣播訊息並發寫入同一個連接
+		if err := client.SafeWritePing(time.Now().Add(10 * time.Second)); err != nil {
 			return
 		}
 	}
 }
 
-// 處理接收到的訊息
-func (h *WebSocketHandler) handleMessages(conn *websocket.Conn, client *model.Client) {
+// 處理接收到的訊息，返回連接結束的原因
+func (h *WebSocketHandler) handleMessages(conn *websocket.Conn, client *model.Client) DisconnectReason {
 	for {
 		// 讀取 WebSocket 訊息
 		messageType, msg, err := conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				h.logger.Error("Read error: %v", err)
+			reason := classifyDisconnect(err)
+			switch {
+			case reason == DisconnectNormal:
+			case websocket.IsCloseError(err, websocket.CloseAbnormalClosure):
+				// 未送出關閉幀就斷開（例如直接關閉分頁）很常見，不視為伺服器錯誤
+				h.logger.Info("Connection from %s dropped without close frame (%s): %v", client.ID, reason, err)
+			default:
+				h.logger.Error("Read error from %s (%s): %v", client.ID, reason, err)
 			}
-			break
+			return reason
 		}
 
 		// 更新客戶端活躍狀態
@@ -197,99 +631,1060 @@ func (h *WebSocketHandler) handleMessages(conn *websocket.Conn, client *model.Cl
 	}
 }
 
+// classifyDisconnect 根據讀取錯誤判斷斷線原因
+func classifyDisconnect(err error) DisconnectReason {
+	if err == nil || websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+		return DisconnectNormal
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return DisconnectTimeout
+	}
+
+	return DisconnectError
+}
+
+// 加入與離開聊天室的 presence 事件種類
+const (
+	presenceJoin  = "join"
+	presenceLeave = "leave"
+)
+
+// presenceEvent 建立加入或離開聊天室的 presence 事件，由前端依用戶語言顯示
+// 因斷線而離開時 reason 標示斷線原因，主動離開或正常關閉時省略
+func presenceEvent(event string, userName string, roomID string, reason DisconnectReason) []byte {
+	payload := map[string]interface{}{
+		"type":   "presence",
+		"event":  event,
+		"user":   userName,
+		"roomId": roomID,
+		"time":   time.Now().Unix(),
+	}
+	if reason != "" && reason != DisconnectNormal {
+		payload["reason"] = reason
+	}
+
+	message, _ := json.Marshal(payload)
+	return message
+}
+
+// rosterEvent 建立聊天室目前成員名單的事件，不包含加入的客戶端本身
+// 同一用戶名的多個連接（例如多個分頁）只列一次；沒有用戶名的匿名連接不列出，只計入 guests
+func rosterEvent(roomID string, self *model.Client, clients []*model.Client) []byte {
+	users := []string{}
+	seen := make(map[string]bool)
+	guests := 0
+	for _, client := range clients {
+		if client.ID == self.ID {
+			continue
+		}
+		if client.UserName == "" {
+			guests++
+			continue
+		}
+		if !seen[client.UserName] {
+			seen[client.UserName] = true
+			users = append(users, client.UserName)
+		}
+	}
+	sort.Strings(users)
+
+	message, _ := json.Marshal(map[string]interface{}{
+		"type":   "roster",
+		"roomId": roomID,
+		"users":  users,
+		"guests": guests,
+		"time":   time.Now().Unix(),
+	})
+	return message
+}
+
+// offersCompression 檢查客戶端是否在握手時提供 permessage-deflate 擴充，
+// 與 gorilla/websocket 的協商規則相同：啟用壓縮時只要客戶端提供即會協商成功
+func offersCompression(r *http.Request) bool {
+	for _, header := range r.Header.Values("Sec-WebSocket-Extensions") {
+		for _, extension := range strings.Split(header, ",") {
+			name, _, _ := strings.Cut(extension, ";")
+			if strings.EqualFold(strings.TrimSpace(name), "permessage-deflate") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // 處理文本訊息
 func (h *WebSocketHandler) processTextMessage(client *model.Client, msg []byte) {
+	if h.rateLimiter != nil && !h.rateLimiter.AllowN(client.ID, messageCost(msg)) {
+		h.logger.Info("Dropped message from %s: rate limited", client.ID)
+		h.sendRateLimited(client)
+		h.sendNack(client, clientMsgID(msg), nackRateLimited)
+		return
+	}
+
 	h.logger.Info("Received from %s: %s", client.ID, string(msg))
 
+	// 保存到資料庫的訊息內容：JSON 訊息取其 content 欄位，否則使用原始文字
+	content := string(msg)
+
 	// 嘗試解析為 JSON 格式
 	var payload MessagePayload
-	if err := json.Unmarshal(msg, &payload); err == nil {
+	isJSON := json.Unmarshal(msg, &payload) == nil
+	if isJSON {
+		if !knownMessageTypes[payload.Type] {
+			h.sendError(client, fmt.Sprintf("不支援的訊息類型：%s", payload.Type))
+			h.sendNack(client, payload.ClientMsgID, nackUnknownType)
+			return
+		}
+
+		payload.Content = strings.TrimSpace(payload.Content)
+		content = payload.Content
+
 		// 成功解析為 JSON
 		switch payload.Type {
 		case "private":
 			if payload.Target != "" {
-				h.handlePrivateMessage(client, payload)
+				if err := h.validateContent(payload.Content); err != nil {
+					h.sendError(client, err.Error())
+					return
+				}
+				if err := h.handlePrivateMessage(client, payload); errors.Is(err, errSelfDM) {
+					h.sendError(client, err.Error())
+				}
 				return
 			}
 		case "join_room":
 			if payload.Target != "" {
-				h.handleJoinRoom(client, payload.Target)
+				roomID, err := h.resolveJoinTarget(client, payload.Target)
+				if err != nil {
+					h.sendError(client, err.Error())
+					return
+				}
+				if !h.canAccessRoom(clientUser(client), roomID) {
+					h.sendError(client, "不是聊天室的有效成員，無法加入")
+					return
+				}
+				h.handleJoinRoom(client, roomID)
 				return
 			}
 		case "leave_room":
 			h.handleLeaveRoom(client)
 			return
+		case "load_history":
+			h.handleLoadHistory(client, payload)
+			return
+		case "batch":
+			h.handleBatch(client, payload.Messages)
+			return
+		case "typing":
+			h.handleTyping(client, payload)
+			return
+		case "edit_message":
+			h.handleEditMessage(client, payload)
+			return
+		case "delete_message":
+			h.handleDeleteMessage(client, payload)
+			return
+		case "reaction":
+			h.handleReaction(client, payload)
+			return
 		}
 	}
 
-	// 如果客戶端在聊天室中，將訊息廣播到該聊天室
-	if client.RoomID != "" {
-		err := h.broadcastService.BroadcastToRoom(client.RoomID, msg)
-		if err != nil {
-			h.logger.Error("Failed to broadcast message to room: %v", err)
-		}
-	} else {
-		// 否則廣播到所有客戶端
-		err := h.broadcastService.BroadcastMessage(msg)
-		if err != nil {
-			h.logger.Error("Failed to broadcast message: %v", err)
-		}
+	validate := h.validateContent
+	if isJSON {
+		validate = h.validateMessageContent
 	}
-}
-
-// 處理私人訊息
-func (h *WebSocketHandler) handlePrivateMessage(client *model.Client, payload MessagePayload) {
-	// 創建私人訊息
-	privateMsg, err := json.Marshal(map[string]interface{}{
-		"type":    "private",
-		"content": payload.Content,
-		"from":    client.UserName,
-		"time":    time.Now().Unix(),
-	})
-
-	if err != nil {
-		h.logger.Error("Failed to marshal private message: %v", err)
+	if err := validate(content); err != nil {
+		h.sendError(client, err.Error())
+		h.sendNack(client, payload.ClientMsgID, nackInvalidContent)
 		return
 	}
+	if isJSON {
+		msg = withContent(msg, content)
+	}
 
-	// 發送私人訊息
-	err = h.broadcastService.SendPrivateMessage(payload.Target, privateMsg)
+	err := h.broadcastFromClient(client, msg)
+	if errors.Is(err, errNotRoomMember) {
+		h.sendError(client, err.Error())
+		h.sendNack(client, payload.ClientMsgID, nackNotRoomMember)
+		return
+	}
 	if err != nil {
-		h.logger.Error("Failed to send private message: %v", err)
+		h.sendNack(client, payload.ClientMsgID, nackBroadcastFailed)
+		return
 	}
+
+	serverID := h.persistRoomMessage(client, content)
+	h.notifyMentions(client, content)
+	h.sendAck(client, payload.ClientMsgID, serverID)
 }
 
-// 處理加入聊天室
-func (h *WebSocketHandler) handleJoinRoom(client *model.Client, roomID string) {
-	// 如果客戶端已經在聊天室中，先離開
-	if client.RoomID != "" {
-		h.handleLeaveRoom(client)
+// messageCost 返回訊息消耗的速率限制令牌數，批次訊息每則各消耗一個令牌
+// 超過批次上限的批次會整批被拒絕而不處理任何訊息，只消耗一個令牌
+func messageCost(msg []byte) int {
+	var payload struct {
+		Type     string            `json:"type"`
+		Messages []json.RawMessage `json:"messages"`
 	}
+	if err := json.Unmarshal(msg, &payload); err != nil || payload.Type != "batch" {
+		return 1
+	}
+	if len(payload.Messages) == 0 || len(payload.Messages) > maxBatchSize {
+		return 1
+	}
+	return len(payload.Messages)
+}
 
-	// 設置新的聊天室 ID
-	client.SetRoomID(roomID)
-
-	// 發送系統訊息通知其他用戶
-	systemMsg := fmt.Sprintf("使用者 %s 已加入聊天室", client.UserName)
-	h.broadcastService.BroadcastToRoom(roomID, []byte(systemMsg))
+// clientMsgID 從原始訊息中取出客戶端產生的訊息 ID，非 JSON 訊息或未提供時返回空字串
+func clientMsgID(msg []byte) string {
+	var payload struct {
+		ClientMsgID string `json:"clientMsgId"`
+	}
+	if err := json.Unmarshal(msg, &payload); err != nil {
+		return ""
+	}
+	return payload.ClientMsgID
+}
 
-	h.logger.Info("Client %s joined room %s", client.ID, roomID)
+// withContent 將 JSON 訊息的 content 欄位替換為整理後的內容，保留其他欄位（例如前端附帶的 sender、time）
+func withContent(msg []byte, content string) []byte {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(msg, &fields); err != nil || fields["content"] == content {
+		return msg
+	}
+	fields["content"] = content
+	normalized, err := json.Marshal(fields)
+	if err != nil {
+		return msg
+	}
+	return normalized
 }
 
-// 處理離開聊天室
-func (h *WebSocketHandler) handleLeaveRoom(client *model.Client) {
-	if client.RoomID == "" {
-		return
+// validateContent 檢查訊息內容是否超過長度上限、包含空字元或不允許的控制字元
+func (h *WebSocketHandler) validateContent(content string) error {
+	if h.maxContentLength > 0 && utf8.RuneCountInString(content) > h.maxContentLength {
+		return errContentLength
+	}
+	if !h.rejectControls {
+		return nil
 	}
 
-	roomID := client.RoomID
+	invalid := strings.IndexFunc(content, func(r rune) bool {
+		if h.allowNewlines && (r == '\n' || r == '\r' || r == '\t') {
+			return false
+		}
+		return unicode.IsControl(r)
+	})
+	if invalid >= 0 {
+		return errControlChars
+	}
+	return nil
+}
+
+// validateMessageContent 檢查整理後的訊息內容不為空且符合 validateContent 的規則
+func (h *WebSocketHandler) validateMessageContent(content string) error {
+	if content == "" {
+		return errEmptyContent
+	}
+	return h.validateContent(content)
+}
+
+// ValidateContent 以 WebSocket 訊息相同的規則檢查內容，供透過 REST 發送的訊息使用
+func (h *WebSocketHandler) ValidateContent(content string) error {
+	return h.validateContent(content)
+}
+
+// 將聊天室中的訊息保存到資料庫並返回訊息 ID，未設置聊天室服務、不在聊天室中或保存失敗時返回 0
+func (h *WebSocketHandler) persistRoomMessage(client *model.Client, content string) uint {
+	if h.roomService == nil || client.RoomID == "" {
+		return 0
+	}
+
+	message, err := h.roomService.SendMessage(client.RoomID, senderID(client), content)
+	if err != nil {
+		h.logger.Error("Failed to persist message from %s to room %s: %v", client.ID, client.RoomID, err)
+		return 0
+	}
+	return message.ID
+}
+
+// senderID 返回保存訊息時使用的發送者 ID，只使用伺服器簽發的身分：
+// 登入用戶使用其用戶 ID，持有訪客 cookie 的匿名連接使用加上前綴的訪客 ID，其餘為空。
+// 使用者名稱來自客戶端的查詢參數，不能作為 ID，否則匿名連接可冒用其他用戶的身分
+func senderID(client *model.Client) string {
+	if client.UserID != "" {
+		return client.UserID
+	}
+	if client.GuestID != "" {
+		return guestSenderPrefix + client.GuestID
+	}
+	return ""
+}
+
+// 廣播客戶端發送的訊息：在聊天室中時廣播到該聊天室（停用回傳時略過發送者），否則廣播到所有客戶端
+func (h *WebSocketHandler) broadcastFromClient(client *model.Client, msg []byte) error {
+	if client.RoomID != "" {
+		if !h.canAccessRoom(clientUser(client), client.RoomID) {
+			h.logger.Info("Rejected message from %s: not an active member of room %s", client.ID, client.RoomID)
+			client.SetRoomID("")
+			return errNotRoomMember
+		}
+
+		var err error
+		if h.echoToSender {
+			err = h.broadcastService.BroadcastToRoom(client.RoomID, msg)
+		} else {
+			err = h.broadcastService.BroadcastToRoomExcept(client.RoomID, client.ID, msg)
+		}
+		if err != nil {
+			h.logger.Error("Failed to broadcast message to room: %v", err)
+		}
+		return err
+	}
+
+	err := h.broadcastService.BroadcastMessage(msg)
+	if err != nil {
+		h.logger.Error("Failed to broadcast message: %v", err)
+	}
+	return err
+}
+
+// 處理批次訊息，逐則走一般的發送流程，並回覆一則合併的確認給發送者
+func (h *WebSocketHandler) handleBatch(client *model.Client, messages []MessagePayload) {
+	if len(messages) == 0 {
+		h.sendError(client, "批次訊息不能為空")
+		return
+	}
+	if len(messages) > maxBatchSize {
+		h.sendError(client, fmt.Sprintf("批次訊息最多 %d 則", maxBatchSize))
+		return
+	}
+
+	results := make([]BatchResult, len(messages))
+	for i, item := range messages {
+		results[i] = BatchResult{Index: i, OK: true}
+		if err := h.processBatchItem(client, item); err != nil {
+			results[i].OK = false
+			results[i].Error = err.Error()
+		}
+	}
+
+	ackMsg, err := json.Marshal(map[string]interface{}{
+		"type":    "batch_ack",
+		"results": results,
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal batch ack: %v", err)
+		return
+	}
+
+	if err := h.broadcastService.SendPrivateMessage(client.ID, ackMsg); err != nil {
+		h.logger.Error("Failed to send batch ack: %v", err)
+	}
+}
+
+// 處理批次中的單則訊息，只支援一般訊息與私人訊息，內容與單則訊息使用相同的整理與檢查規則
+func (h *WebSocketHandler) processBatchItem(client *model.Client, item MessagePayload) error {
+	switch item.Type {
+	case "", "message", "private":
+	default:
+		return fmt.Errorf("不支援的訊息類型：%s", item.Type)
+	}
+
+	item.Content = strings.TrimSpace(item.Content)
+	if err := h.validateMessageContent(item.Content); err != nil {
+		return err
+	}
+
+	if item.Type == "private" {
+		if item.Target == "" {
+			return errMissingTarget
+		}
+		return h.handlePrivateMessage(client, item)
+	}
+
+	msg, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if err := h.broadcastFromClient(client, msg); err != nil {
+		return err
+	}
+
+	h.persistRoomMessage(client, item.Content)
+	h.notifyMentions(client, item.Content)
+	return nil
+}
+
+// 處理私人訊息
+func (h *WebSocketHandler) handlePrivateMessage(client *model.Client, payload MessagePayload) error {
+	if recipientID, ok := h.resolveDirectRecipient(client, payload.Target); ok {
+		if !h.allowSelfDM && recipientID == client.UserID {
+			return errSelfDM
+		}
+		return h.sendDirectMessage(client, recipientID, payload.Content)
+	}
+
+	if !h.allowSelfDM && payload.Target == client.ID {
+		return errSelfDM
+	}
+
+	// 創建私人訊息
+	privateMsg, err := json.Marshal(map[string]interface{}{
+		"type":    "private",
+		"content": payload.Content,
+		"from":    client.UserName,
+		"time":    time.Now().Unix(),
+	})
+
+	if err != nil {
+		h.logger.Error("Failed to marshal private message: %v", err)
+		return err
+	}
+
+	// 發送私人訊息
+	err = h.broadcastService.SendPrivateMessage(payload.Target, privateMsg)
+	if err != nil {
+		h.logger.Error("Failed to send private message: %v", err)
+	}
+	return err
+}
+
+// resolveDirectRecipient 將私人訊息的目標解析為接收者的用戶 ID
+// 只有設置私人訊息服務且發送者已登入時才會解析：目標為已登入用戶的連接 ID 時使用該連接的用戶，否則視為用戶名
+func (h *WebSocketHandler) resolveDirectRecipient(client *model.Client, target string) (string, bool) {
+	if h.directMessages == nil || client.UserID == "" {
+		return "", false
+	}
+
+	if targetClient, err := h.broadcastService.GetClient(target); err == nil {
+		return targetClient.UserID, targetClient.UserID != ""
+	}
+
+	user, err := h.directMessages.GetUserByUsername(target)
+	if err != nil {
+		return "", false
+	}
+	return user.ID, true
+}
+
+// sendDirectMessage 保存發送給指定用戶的私人訊息，並推送到接收者目前所有的連接
+func (h *WebSocketHandler) sendDirectMessage(client *model.Client, recipientID string, content string) error {
+	message, err := h.directMessages.SendDirectMessage(client.UserID, recipientID, content)
+	if err != nil {
+		h.logger.Error("Failed to save direct message from %s to %s: %v", client.UserID, recipientID, err)
+		return err
+	}
+
+	privateMsg, err := json.Marshal(map[string]interface{}{
+		"type":       "private",
+		"content":    message.Content,
+		"from":       client.UserName,
+		"fromUserId": client.UserID,
+		"messageId":  message.ID,
+		"time":       message.CreatedAt.Unix(),
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal private message: %v", err)
+		return err
+	}
+
+	for _, target := range h.broadcastService.GetClientsByUser(recipientID) {
+		if err := h.broadcastService.SendPrivateMessage(target.ID, privateMsg); err != nil {
+			h.logger.Error("Failed to send private message to %s: %v", target.ID, err)
+		}
+	}
+	return nil
+}
+
+// 處理加入聊天室
+func (h *WebSocketHandler) handleJoinRoom(client *model.Client, roomID string) {
+	// 先記錄成員資格，無法加入（例如人數已滿）時客戶端留在原本的聊天室
+	if err := h.recordJoin(client, roomID); err != nil {
+		h.sendError(client, err.Error())
+		return
+	}
+
+	// 如果客戶端已經在其他聊天室中，先離開
+	if client.RoomID != "" && client.RoomID != roomID {
+		h.handleLeaveRoom(client)
+	}
+
+	// 設置新的聊天室 ID
+	client.SetRoomID(roomID)
+	h.metrics.RoomJoined()
+
+	// 發送 presence 事件通知其他用戶
+	h.broadcastService.BroadcastToRoom(roomID, presenceEvent(presenceJoin, client.UserName, roomID, ""))
+
+	// 讓加入的客戶端知道聊天室中已經有哪些人
+	if err := h.broadcastService.SendPrivateMessage(client.ID, rosterEvent(roomID, client, h.broadcastService.GetClientsInRoom(roomID))); err != nil {
+		h.logger.Error("Failed to send roster of room %s to %s: %v", roomID, client.ID, err)
+	}
+
+	h.sendJoinHistory(client, roomID)
+
+	h.logger.Info("Client %s joined room %s", client.ID, roomID)
+}
+
+// sendJoinHistory 將聊天室最近的訊息（由舊到新）只發送給剛加入的客戶端
+func (h *WebSocketHandler) sendJoinHistory(client *model.Client, roomID string) {
+	if h.roomService == nil || h.joinHistorySize == 0 {
+		return
+	}
+
+	messages, err := h.roomService.GetRoomMessages(roomID, h.joinHistorySize)
+	if err != nil {
+		h.logger.Error("Failed to load recent messages for room %s: %v", roomID, err)
+		return
+	}
+
+	// 轉為由舊到新的順序以便顯示
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	history, err := historyMessageResponses(h.roomService, messages)
+	if err != nil {
+		h.logger.Error("Failed to load reactions for room %s: %v", roomID, err)
+		return
+	}
+
+	historyMsg, err := json.Marshal(map[string]interface{}{
+		"type":     "history",
+		"roomId":   roomID,
+		"messages": history,
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal history: %v", err)
+		return
+	}
 
-	// 發送系統訊息通知其他用戶
-	systemMsg := fmt.Sprintf("使用者 %s 已離開聊天室", client.UserName)
-	h.broadcastService.BroadcastToRoom(roomID, []byte(systemMsg))
+	if err := h.broadcastService.SendPrivateMessage(client.ID, historyMsg); err != nil {
+		h.logger.Error("Failed to send history: %v", err)
+	}
+}
+
+// resolveJoinTarget 解析 join_room 的目標聊天室 ID
+// 啟用自動創建時，非 ID 格式的目標視為聊天室名稱，找不到同名聊天室時以預設設定創建公開聊天室，
+// 並記錄為加入的用戶所創建
+func (h *WebSocketHandler) resolveJoinTarget(client *model.Client, target string) (string, error) {
+	if !h.autoCreateRooms || h.roomService == nil {
+		return target, nil
+	}
+	if _, err := uuid.Parse(target); err == nil {
+		return target, nil
+	}
+
+	room, err := h.roomService.GetRoomByName(target)
+	if err == nil {
+		return room.ID, nil
+	}
+	if !errors.Is(err, repository.ErrRoomNotFound) {
+		h.logger.Error("Failed to look up room %s: %v", target, err)
+		return "", errJoinRoomFailed
+	}
+
+	if client.UserID == "" {
+		return "", errLoginRequiredToCreate
+	}
+
+	room, err = h.roomService.CreateRoom(service.RoomData{Name: target, IsPublic: true, IsListed: true}, client.UserID)
+	if err != nil {
+		if errors.Is(err, service.ErrRoomCapacityReached) {
+			return "", err
+		}
+		h.logger.Error("Failed to auto-create room %s: %v", target, err)
+		return "", errJoinRoomFailed
+	}
+
+	// 創建者成為聊天室管理員
+	if err := h.roomService.JoinRoom(room.ID, client.UserID, "admin"); err != nil {
+		h.logger.Error("Failed to add creator %s to room %s: %v", client.UserID, room.ID, err)
+	}
+
+	h.logger.Info("Client %s auto-created room %s (%s)", client.ID, room.ID, target)
+	return room.ID, nil
+}
+
+// 處理輸入中提示，只通知同一聊天室的其他客戶端，不記錄到訊息歷史
+func (h *WebSocketHandler) handleTyping(client *model.Client, payload MessagePayload) {
+	if client.RoomID == "" || !h.canAccessRoom(clientUser(client), client.RoomID) {
+		return
+	}
+
+	isTyping := true
+	if payload.IsTyping != nil {
+		isTyping = *payload.IsTyping
+	}
+
+	if h.typingTracker != nil {
+		h.typingTracker.SetTyping(client.RoomID, client, isTyping)
+	}
+
+	typingMsg, err := json.Marshal(map[string]interface{}{
+		"type":     "typing",
+		"userName": client.UserName,
+		"roomId":   client.RoomID,
+		"isTyping": isTyping,
+		"time":     time.Now().Unix(),
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal typing event: %v", err)
+		return
+	}
+
+	if err := h.broadcastService.BroadcastEventToRoom(client.RoomID, client.ID, typingMsg); err != nil {
+		h.logger.Error("Failed to broadcast typing event: %v", err)
+	}
+}
+
+// 處理編輯訊息，只有登入的訊息作者可以編輯，成功後通知聊天室中的所有客戶端
+func (h *WebSocketHandler) handleEditMessage(client *model.Client, payload MessagePayload) {
+	if h.roomService == nil {
+		return
+	}
+	if client.UserID == "" {
+		h.sendError(client, "需要登入才能編輯訊息")
+		return
+	}
+	if err := h.validateContent(payload.Content); err != nil {
+		h.sendError(client, err.Error())
+		return
+	}
+
+	message, err := h.roomService.EditMessage(payload.MessageID, client.UserID, payload.Content)
+	if err != nil {
+		h.sendMessageError(client, err, "編輯訊息失敗")
+		return
+	}
+
+	if err := h.broadcastService.BroadcastEventToRoom(message.RoomID, "", messageEditedEvent(message)); err != nil {
+		h.logger.Error("Failed to broadcast message edit: %v", err)
+	}
+}
+
+// 處理刪除訊息，權限檢查與 REST 的 DELETE /api/rooms/:id/messages/:msgId 相同，成功後通知聊天室中的所有客戶端
+func (h *WebSocketHandler) handleDeleteMessage(client *model.Client, payload MessagePayload) {
+	if h.roomService == nil {
+		return
+	}
+	if client.UserID == "" {
+		h.sendError(client, "需要登入才能刪除訊息")
+		return
+	}
+
+	message, err := h.roomService.DeleteMessage(payload.MessageID, client.UserID)
+	if err != nil {
+		h.sendMessageError(client, err, "刪除訊息失敗")
+		return
+	}
+
+	if err := h.broadcastService.BroadcastEventToRoom(message.RoomID, "", messageDeletedEvent(message)); err != nil {
+		h.logger.Error("Failed to broadcast message deletion: %v", err)
+	}
+}
+
+// 處理表情回應：用戶尚未以該表情回應時加入，已回應過時取消，並向聊天室廣播最新的回應數量
+// target 為訊息 ID，content 為表情；只能回應目前所在聊天室的訊息
+func (h *WebSocketHandler) handleReaction(client *model.Client, payload MessagePayload) {
+	if h.roomService == nil {
+		return
+	}
+	if client.UserID == "" {
+		h.sendError(client, "需要登入才能回應訊息")
+		return
+	}
+	if client.RoomID == "" {
+		h.sendError(client, "需要加入聊天室才能回應訊息")
+		return
+	}
+
+	messageID := payload.MessageID
+	if payload.Target != "" {
+		id, err := strconv.ParseUint(payload.Target, 10, 64)
+		if err != nil || id == 0 {
+			h.sendError(client, "無效的訊息 ID")
+			return
+		}
+		messageID = uint(id)
+	}
+
+	counts, err := h.roomService.RemoveReaction(client.RoomID, messageID, client.UserID, payload.Content)
+	if errors.Is(err, repository.ErrReactionNotFound) {
+		counts, err = h.roomService.AddReaction(client.RoomID, messageID, client.UserID, payload.Content)
+	}
+	if err != nil {
+		h.sendMessageError(client, err, "回應訊息失敗")
+		return
+	}
+
+	if err := h.broadcastService.BroadcastEventToRoom(client.RoomID, "", reactionUpdateEvent(client.RoomID, messageID, counts)); err != nil {
+		h.logger.Error("Failed to broadcast reaction update: %v", err)
+	}
+}
+
+// sendMessageError 回覆編輯、刪除或回應訊息的錯誤，非預期的錯誤只記錄日誌並回覆通用訊息
+func (h *WebSocketHandler) sendMessageError(client *model.Client, err error, fallback string) {
+	if errors.Is(err, repository.ErrMessageNotFound) ||
+		errors.Is(err, service.ErrNotMessageAuthor) ||
+		errors.Is(err, service.ErrEmptyMessageContent) ||
+		errors.Is(err, service.ErrInvalidReaction) {
+		h.sendError(client, err.Error())
+		return
+	}
+
+	h.logger.Error("Failed to modify message for client %s: %v", client.ID, err)
+	h.sendError(client, fallback)
+}
+
+// MoveUserToRoom 將登入用戶目前的所有 WebSocket 連接移入指定的聊天室，
+// 每個連接會先收到 room_created 通知，返回被移動的連接數
+func (h *WebSocketHandler) MoveUserToRoom(userID string, room *model.Room) int {
+	clients := h.broadcastService.GetClientsByUser(userID)
+	if len(clients) == 0 {
+		return 0
+	}
+
+	notice, err := json.Marshal(map[string]interface{}{
+		"type": "room_created",
+		"room": RoomResponse{
+			ID:                room.ID,
+			Name:              room.Name,
+			Description:       room.Description,
+			IsPublic:          room.IsPublic,
+			MaxUsers:          room.MaxUsers,
+			IsListed:          room.IsListed,
+			CreatedBy:         room.CreatedBy,
+			MessageTTLSeconds: room.MessageTTLSeconds,
+		},
+		"autoJoin": true,
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal room created notice: %v", err)
+		return 0
+	}
+
+	for _, client := range clients {
+		if err := h.broadcastService.SendPrivateMessage(client.ID, notice); err != nil {
+			h.logger.Error("Failed to notify client %s of new room: %v", client.ID, err)
+		}
+		h.handleJoinRoom(client, room.ID)
+	}
+
+	return len(clients)
+}
+
+// CloseRoom 通知已刪除聊天室中的所有連接聊天室已關閉，並將它們移出聊天室，返回受影響的連接數
+// 成員記錄已在刪除聊天室時標記為不活躍，因此不另外記錄離開
+func (h *WebSocketHandler) CloseRoom(roomID string) int {
+	// 已刪除的聊天室不會再有訊息，釋放其訊息日誌
+	h.broadcastService.ClearRoomLog(roomID)
+
+	clients := h.broadcastService.GetClientsInRoom(roomID)
+	if len(clients) == 0 {
+		return 0
+	}
+
+	notice, err := json.Marshal(map[string]interface{}{
+		"type":   "room_closed",
+		"roomId": roomID,
+		"time":   time.Now().Unix(),
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal room closed notice: %v", err)
+		return 0
+	}
+
+	for _, client := range clients {
+		client.SetRoomID("")
+		if err := h.broadcastService.SendPrivateMessage(client.ID, notice); err != nil {
+			h.logger.Error("Failed to notify client %s of closed room: %v", client.ID, err)
+		}
+	}
+
+	h.logger.Info("Room %s closed, %d clients removed", roomID, len(clients))
+	return len(clients)
+}
+
+// RemoveUserFromRoom 將用戶在聊天室中的所有連接移出，並通知被移出的客戶端，返回移出的連接數
+// 成員記錄由呼叫方（聊天室服務）負責更新
+func (h *WebSocketHandler) RemoveUserFromRoom(roomID string, userID string, banned bool) int {
+	notice, err := json.Marshal(map[string]interface{}{
+		"type":   "kicked",
+		"roomId": roomID,
+		"banned": banned,
+		"time":   time.Now().Unix(),
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal kicked notice: %v", err)
+		return 0
+	}
+
+	removed := 0
+	for _, client := range h.broadcastService.GetClientsInRoom(roomID) {
+		if client.UserID != userID {
+			continue
+		}
+		client.SetRoomID("")
+		if err := h.broadcastService.SendPrivateMessage(client.ID, notice); err != nil {
+			h.logger.Error("Failed to notify client %s of removal: %v", client.ID, err)
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		h.logger.Info("User %s removed from room %s, %d clients affected", userID, roomID, removed)
+	}
+	return removed
+}
+
+// 處理離開聊天室
+func (h *WebSocketHandler) handleLeaveRoom(client *model.Client) {
+	if client.RoomID == "" {
+		return
+	}
+
+	roomID := client.RoomID
+	h.clearTyping(client)
+
+	// 發送 presence 事件通知其他用戶
+	h.broadcastService.BroadcastToRoom(roomID, presenceEvent(presenceLeave, client.UserName, roomID, ""))
+
+	h.recordLeave(client, roomID)
 
 	// 清除聊天室 ID
 	client.SetRoomID("")
 
 	h.logger.Info("Client %s left room %s", client.ID, roomID)
 }
+
+// clearTyping 清除客戶端的輸入中狀態
+func (h *WebSocketHandler) clearTyping(client *model.Client) {
+	if h.typingTracker != nil {
+		h.typingTracker.ClearClient(client.ID)
+	}
+}
+
+// recordJoin 在資料庫中記錄登入用戶加入聊天室，重新加入時會重新啟用先前的成員記錄
+// 只有聊天室人數已滿或未受邀進入私人聊天室時返回錯誤，其他記錄失敗不影響加入
+func (h *WebSocketHandler) recordJoin(client *model.Client, roomID string) error {
+	if h.roomService == nil {
+		return nil
+	}
+
+	// 匿名訪客無法受邀，不能進入私人聊天室
+	if client.UserID == "" {
+		if room, err := h.roomService.GetRoom(roomID); err == nil && !room.IsPublic {
+			return service.ErrRoomPrivate
+		}
+		return nil
+	}
+
+	err := h.roomService.JoinRoom(roomID, client.UserID, "member")
+	if errors.Is(err, service.ErrRoomFull) || errors.Is(err, service.ErrRoomPrivate) || errors.Is(err, service.ErrUserBanned) {
+		return err
+	}
+	if err != nil {
+		h.logger.Error("Failed to record membership of user %s in room %s: %v", client.UserID, roomID, err)
+	}
+	return nil
+}
+
+// recordLeave 在資料庫中將登入用戶標記為離開聊天室
+// 同一用戶仍有其他連接在該聊天室中時（例如多個分頁）保留成員記錄
+func (h *WebSocketHandler) recordLeave(client *model.Client, roomID string) {
+	if h.roomService == nil || client.UserID == "" {
+		return
+	}
+
+	for _, other := range h.broadcastService.GetClientsInRoom(roomID) {
+		if other.ID != client.ID && other.UserID == client.UserID {
+			return
+		}
+	}
+
+	err := h.roomService.LeaveRoom(roomID, client.UserID)
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		h.logger.Error("Failed to record user %s leaving room %s: %v", client.UserID, roomID, err)
+	}
+}
+
+// 處理載入歷史訊息，只回覆給請求的客戶端
+func (h *WebSocketHandler) handleLoadHistory(client *model.Client, payload MessagePayload) {
+	roomID := payload.Target
+	if roomID == "" {
+		roomID = client.RoomID
+	}
+	if h.roomService == nil || roomID == "" {
+		return
+	}
+	if !h.canAccessRoom(clientUser(client), roomID) || !h.canReadRoom(client, roomID) {
+		h.sendError(client, "不是聊天室的有效成員，無法載入歷史訊息")
+		return
+	}
+
+	limit := payload.Limit
+	if limit <= 0 {
+		limit = defaultHistoryPageSize
+	}
+	if limit > maxHistoryPageSize {
+		limit = maxHistoryPageSize
+	}
+
+	// 多取一筆以判斷是否還有更舊的訊息
+	messages, err := h.roomService.GetRoomMessagesBefore(roomID, payload.Before, limit+1)
+	if err != nil {
+		h.logger.Error("Failed to load history for room %s: %v", roomID, err)
+		return
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+
+	// 轉為由舊到新的順序以便顯示
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	history, err := historyMessageResponses(h.roomService, messages)
+	if err != nil {
+		h.logger.Error("Failed to load reactions for room %s: %v", roomID, err)
+		return
+	}
+
+	historyMsg, err := json.Marshal(map[string]interface{}{
+		"type":     "history",
+		"messages": history,
+		"hasMore":  hasMore,
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal history: %v", err)
+		return
+	}
+
+	if err := h.broadcastService.SendPrivateMessage(client.ID, historyMsg); err != nil {
+		h.logger.Error("Failed to send history: %v", err)
+	}
+}
+
+// canAccessRoom 檢查用戶是否可以進入聊天室，未啟用成員檢查時一律允許
+func (h *WebSocketHandler) canAccessRoom(user *middleware.UserResponse, roomID string) bool {
+	if !h.requireMembership {
+		return true
+	}
+	if h.roomService == nil || user == nil || user.ID == "" {
+		return false
+	}
+
+	// 只檢查成員資格與封禁，不要求目前連線在聊天室中，斷線或離開的成員可以重新進入
+	member, err := h.roomService.IsMember(roomID, user.ID)
+	if err != nil {
+		h.logger.Error("Failed to check membership of user %s in room %s: %v", user.ID, roomID, err)
+		return false
+	}
+	return member
+}
+
+// canReadRoom 檢查客戶端是否可以讀取聊天室的訊息，私人聊天室只有創建者與有效成員可以讀取
+// 與 canAccessRoom 不同，不論是否啟用成員檢查都會套用
+func (h *WebSocketHandler) canReadRoom(client *model.Client, roomID string) bool {
+	allowed, err := h.roomService.CanReadRoom(roomID, client.UserID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrRoomNotFound) {
+			h.logger.Error("Failed to check read access of %s to room %s: %v", client.ID, roomID, err)
+		}
+		return false
+	}
+	return allowed
+}
+
+// clientUser 取得客戶端對應的登入用戶，匿名客戶端返回 nil
+func clientUser(client *model.Client) *middleware.UserResponse {
+	if client.UserID == "" {
+		return nil
+	}
+	return &middleware.UserResponse{ID: client.UserID, Username: client.UserName}
+}
+
+// sendError 發送錯誤訊息給指定的客戶端
+func (h *WebSocketHandler) sendError(client *model.Client, content string) {
+	errorMsg, err := json.Marshal(map[string]interface{}{
+		"type":    "error",
+		"content": content,
+		"time":    time.Now().Unix(),
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal error message: %v", err)
+		return
+	}
+
+	if err := h.broadcastService.SendPrivateMessage(client.ID, errorMsg); err != nil {
+		h.logger.Error("Failed to send error message: %v", err)
+	}
+}
+
+// sendAck 通知發送者訊息已被接受，serverID 為保存後的訊息 ID，訊息未保存（例如不在聊天室中）時省略
+// 客戶端未提供 clientMsgID 時不回覆
+func (h *WebSocketHandler) sendAck(client *model.Client, clientMsgID string, serverID uint) {
+	if clientMsgID == "" {
+		return
+	}
+
+	ack := map[string]interface{}{
+		"type":        "ack",
+		"clientMsgId": clientMsgID,
+		"time":        time.Now().Unix(),
+	}
+	if serverID != 0 {
+		ack["serverId"] = serverID
+	}
+	h.sendNotice(client, ack)
+}
+
+// sendNack 通知發送者訊息被拒絕及其原因，客戶端未提供 clientMsgID 時不回覆
+func (h *WebSocketHandler) sendNack(client *model.Client, clientMsgID string, reason string) {
+	if clientMsgID == "" {
+		return
+	}
+
+	h.sendNotice(client, map[string]interface{}{
+		"type":        "nack",
+		"clientMsgId": clientMsgID,
+		"reason":      reason,
+		"time":        time.Now().Unix(),
+	})
+}
+
+// sendNotice 將通知序列化後只發送給指定的客戶端
+func (h *WebSocketHandler) sendNotice(client *model.Client, notice map[string]interface{}) {
+	msg, err := json.Marshal(notice)
+	if err != nil {
+		h.logger.Error("Failed to marshal %v notice: %v", notice["type"], err)
+		return
+	}
+
+	if err := h.broadcastService.SendPrivateMessage(client.ID, msg); err != nil {
+		h.logger.Error("Failed to send %v notice: %v", notice["type"], err)
+	}
+}
+
+// sendRateLimited 通知客戶端訊息因超過速率限制而被丟棄
+func (h *WebSocketHandler) sendRateLimited(client *model.Client) {
+	notice, err := json.Marshal(map[string]interface{}{
+		"type": "rate_limited",
+		"time": time.Now().Unix(),
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal rate limited notice: %v", err)
+		return
+	}
+
+	if err := h.broadcastService.SendPrivateMessage(client.ID, notice); err != nil {
+		h.logger.Error("Failed to send rate limited notice: %v", err)
+	}
+}
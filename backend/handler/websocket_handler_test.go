@@ -2,14 +2,24 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"livechat/backend/middleware"
 	"livechat/backend/model"
+	"livechat/backend/repository"
 	"livechat/backend/service"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 )
 
 // MockLogger 是模擬的日誌記錄器，用於測試 WebSocket 處理器的日誌記錄功能
@@ -74,6 +84,16 @@ func (m *MockBroadcastService) BroadcastToRoom(roomID string, message []byte) er
 	return args.Error(0)
 }
 
+func (m *MockBroadcastService) BroadcastToRoomExcept(roomID string, excludeClientID string, message []byte) error {
+	args := m.Called(roomID, excludeClientID, message)
+	return args.Error(0)
+}
+
+func (m *MockBroadcastService) BroadcastEventToRoom(roomID string, excludeClientID string, message []byte) error {
+	args := m.Called(roomID, excludeClientID, message)
+	return args.Error(0)
+}
+
 // SendPrivateMessage 模擬私人訊息發送
 // 測試場景：點對點的私人訊息傳送
 func (m *MockBroadcastService) SendPrivateMessage(targetID string, message []byte) error {
@@ -105,6 +125,15 @@ func (m *MockBroadcastService) GetClientsInRoom(roomID string) []*model.Client {
 	return args.Get(0).([]*model.Client)
 }
 
+func (m *MockBroadcastService) GetClientsByUser(userID string) []*model.Client {
+	args := m.Called(userID)
+	return args.Get(0).([]*model.Client)
+}
+
+func (m *MockBroadcastService) ClearRoomLog(roomID string) {
+	m.Called(roomID)
+}
+
 // TestNewWebSocketHandler 測試 WebSocket 處理器的建構子
 //
 // 測試目標：
@@ -259,6 +288,8 @@ func TestProcessTextMessage(t *testing.T) {
 
 	// 設定加入聊天室的模擬行為
 	mockBroadcastService.On("BroadcastToRoom", "room-2", mock.Anything).Return(nil)
+	mockBroadcastService.On("GetClientsInRoom", "room-2").Return([]*model.Client{client})
+	mockBroadcastService.On("SendPrivateMessage", client.ID, mock.Anything).Return(nil)
 
 	// 動作 (Act)：處理加入聊天室命令
 	handler.processTextMessage(client, joinRoomMessage)
@@ -328,80 +359,2118 @@ func TestHandlePrivateMessage(t *testing.T) {
 	assert.NotNil(t, parsedMessage["time"], "時間戳應該存在")
 }
 
-// TestHandleJoinRoom 測試客戶端加入聊天室的處理邏輯
+// TestHandleLoadHistory 測試透過 WebSocket 指令分頁載入歷史訊息
 //
 // 測試目標：
-// 1. 驗證客戶端能正確加入指定的聊天室
-// 2. 測試客戶端狀態的正確更新
-// 3. 確保加入聊天室的通知被正確廣播
-// 4. 驗證房間管理的基本功能
+// 1. 驗證 load_history 指令會以 before 游標查詢較舊訊息
+// 2. 驗證多取一筆以判斷 hasMore，且回覆的訊息由舊到新排列
+// 3. 驗證每則訊息附上表情回應數量
+// 4. 確保歷史訊息只回覆給請求的客戶端
+func TestHandleLoadHistory(t *testing.T) {
+	// 安排 (Arrange)
+	mockBroadcastService := new(MockBroadcastService)
+	mockRoomService := new(MockRoomService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRoomService(mockRoomService))
+
+	client := &model.Client{ID: "test-id", UserName: "TestUser", RoomID: "room-1"}
+
+	olderMessages := []model.Message{
+		{Model: gorm.Model{ID: 9}, RoomID: "room-1", Content: "訊息9"},
+		{Model: gorm.Model{ID: 8}, RoomID: "room-1", Content: "訊息8"},
+		{Model: gorm.Model{ID: 7}, RoomID: "room-1", Content: "訊息7"},
+	}
+	mockRoomService.On("CanReadRoom", "room-1", "").Return(true, nil)
+	mockRoomService.On("GetRoomMessagesBefore", "room-1", uint(10), 3).Return(olderMessages, nil)
+	mockRoomService.On("GetMessagesReactionCounts", mock.Anything).Return(map[uint][]model.ReactionCount{
+		8: {{Emoji: "👍", Count: 3}},
+	}, nil)
+	mockBroadcastService.On("SendPrivateMessage", "test-id", mock.Anything).Return(nil)
+
+	// 動作 (Act)
+	handler.processTextMessage(client, []byte(`{"type":"load_history","before":10,"limit":2}`))
+
+	// 斷言 (Assert)
+	mockRoomService.AssertExpectations(t)
+	mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", mock.Anything, mock.Anything)
+	mockBroadcastService.AssertCalled(t, "SendPrivateMessage", "test-id", mock.Anything)
+
+	call := mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1]
+	var response struct {
+		Type     string                   `json:"type"`
+		Messages []HistoryMessageResponse `json:"messages"`
+		HasMore  bool                     `json:"hasMore"`
+	}
+	err := json.Unmarshal(call.Arguments.Get(1).([]byte), &response)
+	assert.NoError(t, err, "應該能夠解析歷史訊息回應")
+	assert.Equal(t, "history", response.Type, "訊息類型應該是 history")
+	assert.True(t, response.HasMore, "還有更舊的訊息時 hasMore 應該為 true")
+	assert.Len(t, response.Messages, 2, "應該只回傳 limit 筆訊息")
+	assert.Equal(t, "訊息8", response.Messages[0].Content, "訊息應該由舊到新排列")
+	assert.Equal(t, "訊息9", response.Messages[1].Content)
+	assert.Equal(t, []ReactionResponse{{Emoji: "👍", Count: 3}}, response.Messages[0].Reactions, "訊息應該附上表情回應數量")
+	assert.Empty(t, response.Messages[1].Reactions, "沒有回應的訊息應該返回空陣列")
+}
+
+// TestHandleLoadHistoryLastPage 測試載入最後一頁歷史訊息時 hasMore 為 false
+func TestHandleLoadHistoryLastPage(t *testing.T) {
+	// 安排 (Arrange)
+	mockBroadcastService := new(MockBroadcastService)
+	mockRoomService := new(MockRoomService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRoomService(mockRoomService))
+
+	client := &model.Client{ID: "test-id", UserName: "TestUser", RoomID: "room-1"}
+
+	mockRoomService.On("CanReadRoom", "room-1", "").Return(true, nil)
+	mockRoomService.On("GetRoomMessagesBefore", "room-1", uint(2), defaultHistoryPageSize+1).Return([]model.Message{
+		{Model: gorm.Model{ID: 1}, RoomID: "room-1", Content: "訊息1"},
+	}, nil)
+	mockRoomService.On("GetMessagesReactionCounts", mock.Anything).Return(map[uint][]model.ReactionCount{}, nil)
+	mockBroadcastService.On("SendPrivateMessage", "test-id", mock.Anything).Return(nil)
+
+	// 動作 (Act)
+	handler.processTextMessage(client, []byte(`{"type":"load_history","before":2}`))
+
+	// 斷言 (Assert)
+	call := mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1]
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &response))
+	assert.Equal(t, false, response["hasMore"], "沒有更舊的訊息時 hasMore 應該為 false")
+	assert.Len(t, response["messages"], 1, "應該回傳剩餘的 1 筆訊息")
+}
+
+// 測試停用回傳給發送者時，聊天室訊息以排除發送者的方式廣播，預設仍回傳給發送者
+func TestEchoToSender(t *testing.T) {
+	// 安排 (Arrange)
+	mockBroadcastService := new(MockBroadcastService)
+	mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Return(nil)
+	mockBroadcastService.On("BroadcastToRoomExcept", "room-1", "sender-id", mock.Anything).Return(nil)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	echoing := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger))
+	silent := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithEchoToSender(false))
+	client := &model.Client{ID: "sender-id", UserName: "Sender", RoomID: "room-1"}
+
+	// 動作 (Act)
+	echoing.processTextMessage(client, []byte("預設回傳"))
+	silent.processTextMessage(client, []byte("不回傳"))
+
+	// 斷言 (Assert)
+	mockBroadcastService.AssertCalled(t, "BroadcastToRoom", "room-1", []byte("預設回傳"))
+	mockBroadcastService.AssertCalled(t, "BroadcastToRoomExcept", "room-1", "sender-id", []byte("不回傳"))
+	mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", "room-1", []byte("不回傳"))
+}
+
+// 測試透過 WebSocket 回應訊息，並透過 API 載入回應數量
 //
-// 測試策略：
-// - 測試從無房間狀態到加入房間的轉換
-// - 驗證客戶端的 RoomID 屬性被正確設定
-// - 檢查房間廣播通知的發送
-// - 確保 Mock 服務的方法被正確調用
-func TestHandleJoinRoom(t *testing.T) {
-	// 安排 (Arrange)：準備加入聊天室的測試環境
+// 測試目標：
+// 1. 回應後向聊天室廣播 reaction_update 事件與最新的回應數量
+// 2. 多位用戶的相同表情會累計，同一用戶再次送出相同表情會取消回應
+// 3. 匿名用戶、其他聊天室的訊息與無效的訊息 ID 會被拒絕
+// 4. GET /api/rooms/:id/messages/:msgId/reactions 返回目前的回應數量
+func TestHandleReaction(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	roomService := service.NewRoomService(roomRepo)
+	assert.NoError(t, roomRepo.CreateRoom(&model.Room{ID: "room-1", Name: "測試聊天室", IsPublic: true, IsActive: true}))
+
+	message := &model.Message{RoomID: "room-1", UserID: "author-1", Content: "大家好"}
+	assert.NoError(t, roomRepo.SaveMessage(message))
+
 	mockBroadcastService := new(MockBroadcastService)
+	mockBroadcastService.On("BroadcastEventToRoom", "room-1", "", mock.Anything).Return(nil)
+	mockBroadcastService.On("SendPrivateMessage", mock.Anything, mock.Anything).Return(nil)
 	mockLogger := new(MockLogger)
 	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRoomService(roomService))
 
-	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger))
+	alice := &model.Client{ID: "alice-id", UserID: "user-1", UserName: "Alice", RoomID: "room-1"}
+	bob := &model.Client{ID: "bob-id", UserID: "user-2", UserName: "Bob", RoomID: "room-1"}
+	react := func(client *model.Client, emoji string) {
+		handler.processTextMessage(client, []byte(fmt.Sprintf(`{"type":"reaction","target":"%d","content":"%s"}`, message.ID, emoji)))
+	}
+	lastUpdate := func() map[string]interface{} {
+		call := mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1]
+		assert.Equal(t, "BroadcastEventToRoom", call.Method, "應該向聊天室廣播回應更新")
+		var event map[string]interface{}
+		assert.NoError(t, json.Unmarshal(call.Arguments.Get(2).([]byte), &event))
+		return event
+	}
+	lastError := func() string {
+		call := mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1]
+		assert.Equal(t, "SendPrivateMessage", call.Method, "錯誤應該只回覆給發送者")
+		var payload map[string]interface{}
+		json.Unmarshal(call.Arguments.Get(1).([]byte), &payload)
+		errorText, _ := payload["content"].(string)
+		return errorText
+	}
 
-	// 創建尚未加入任何聊天室的客戶端
-	client := &model.Client{
-		ID:       "test-id",
-		UserName: "TestUser",
-		RoomID:   "", // 初始狀態：未加入任何聊天室
+	// 動作 & 斷言：回應與累計
+	react(alice, "👍")
+	event := lastUpdate()
+	assert.Equal(t, "reaction_update", event["type"], "應該廣播回應更新事件")
+	assert.Equal(t, float64(message.ID), event["messageId"], "事件應該包含訊息 ID")
+	assert.Equal(t, []interface{}{map[string]interface{}{"emoji": "👍", "count": float64(1)}}, event["reactions"], "應該包含最新的回應數量")
+
+	react(bob, "👍")
+	react(bob, "🎉")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"emoji": "👍", "count": float64(2)},
+		map[string]interface{}{"emoji": "🎉", "count": float64(1)},
+	}, lastUpdate()["reactions"], "多位用戶的相同表情應該累計")
+
+	// 動作 & 斷言：再次送出相同表情取消回應
+	react(alice, "👍")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"emoji": "👍", "count": float64(1)},
+		map[string]interface{}{"emoji": "🎉", "count": float64(1)},
+	}, lastUpdate()["reactions"], "再次送出相同表情應該取消回應")
+
+	// 動作 & 斷言：無效的回應
+	react(&model.Client{ID: "guest-id", UserName: "訪客", RoomID: "room-1"}, "👍")
+	assert.Equal(t, "需要登入才能回應訊息", lastError(), "匿名用戶不能回應")
+	react(&model.Client{ID: "carol-id", UserID: "user-3", UserName: "Carol", RoomID: "room-2"}, "👍")
+	assert.Equal(t, repository.ErrMessageNotFound.Error(), lastError(), "不能回應其他聊天室的訊息")
+	handler.processTextMessage(alice, []byte(`{"type":"reaction","target":"abc","content":"👍"}`))
+	assert.Equal(t, "無效的訊息 ID", lastError(), "無效的訊息 ID 應該被拒絕")
+	react(alice, "")
+	assert.Equal(t, service.ErrInvalidReaction.Error(), lastError(), "空的表情應該被拒絕")
+
+	// 動作 & 斷言：透過 API 載入回應數量
+	roomHandler := NewRoomHandler(roomService)
+	router := setupRouter()
+	roomHandler.RegisterRoutes(router)
+	fetch := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
 	}
 
-	// 設定房間廣播的模擬行為
+	w := fetch(fmt.Sprintf("/api/rooms/room-1/messages/%d/reactions", message.ID))
+	assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+	var reactions []ReactionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &reactions), "應該能夠解析響應")
+	assert.Equal(t, []ReactionResponse{{Emoji: "👍", Count: 1}, {Emoji: "🎉", Count: 1}}, reactions, "API 應該返回目前的回應數量")
+	assert.Equal(t, http.StatusNotFound, fetch(fmt.Sprintf("/api/rooms/room-2/messages/%d/reactions", message.ID)).Code, "其他聊天室的訊息應該返回 404")
+	assert.Equal(t, http.StatusBadRequest, fetch("/api/rooms/room-1/messages/abc/reactions").Code, "無效的訊息 ID 應該返回 400")
+}
+
+// 測試重新連線後載入的歷史訊息顯示編輯後的內容與編輯時間
+func TestLoadHistoryReplaysEditedContent(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫
+	editedAt := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time {
+		return editedAt
+	})
+	defer model.ResetTimeNow()
+
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	roomService := service.NewRoomService(roomRepo)
+	assert.NoError(t, roomRepo.CreateRoom(&model.Room{ID: "room-1", Name: "測試聊天室", IsPublic: true, IsActive: true}))
+
+	edited := &model.Message{RoomID: "room-1", UserID: "author-1", Content: "原始內容"}
+	untouched := &model.Message{RoomID: "room-1", UserID: "author-1", Content: "未編輯的訊息"}
+	assert.NoError(t, roomRepo.SaveMessage(edited))
+	assert.NoError(t, roomRepo.SaveMessage(untouched))
+	_, err := roomService.EditMessage(edited.ID, "author-1", "編輯後的內容")
+	assert.NoError(t, err, "編輯訊息不應該失敗")
+
+	mockBroadcastService := new(MockBroadcastService)
+	mockBroadcastService.On("SendPrivateMessage", "reconnected-id", mock.Anything).Return(nil)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRoomService(roomService))
+
+	client := &model.Client{ID: "reconnected-id", UserName: "TestUser", RoomID: "room-1"}
+
+	// 動作 (Act)
+	handler.processTextMessage(client, []byte(`{"type":"load_history"}`))
+
+	// 斷言 (Assert)
+	call := mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1]
+	var response struct {
+		Messages []model.Message `json:"messages"`
+	}
+	assert.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &response))
+	if assert.Len(t, response.Messages, 2, "應該回傳兩筆歷史訊息") {
+		assert.Equal(t, "編輯後的內容", response.Messages[0].Content, "歷史訊息應該顯示編輯後的內容")
+		if assert.NotNil(t, response.Messages[0].EditedAt, "編輯過的訊息應該帶有編輯時間") {
+			assert.True(t, editedAt.Equal(*response.Messages[0].EditedAt), "編輯時間應該匹配")
+		}
+		assert.Nil(t, response.Messages[1].EditedAt, "未編輯的訊息不應該有編輯時間")
+	}
+}
+
+// TestRequireMembership 測試啟用成員檢查時，被移出聊天室的用戶無法再次加入或發言
+//
+// 測試目標：
+// 1. 被移出的用戶無法透過 join_room 重新加入聊天室
+// 2. 仍停留在聊天室中的被移出用戶發送的訊息不會被廣播，並被移出聊天室
+// 3. 重新被加入聊天室後可以正常發言
+func TestRequireMembership(t *testing.T) {
+	// 安排 (Arrange)
+	mockBroadcastService := new(MockBroadcastService)
+	mockRoomService := new(MockRoomService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(
+		mockBroadcastService,
+		WithLogger(mockLogger),
+		WithRoomService(mockRoomService),
+		WithRequireMembership(true),
+	)
+
+	mockRoomService.On("IsMember", "room-1", "kicked-user").Return(false, nil).Times(2)
+	mockBroadcastService.On("SendPrivateMessage", "kicked-id", mock.Anything).Return(nil)
+
+	// 動作 & 斷言：被移出的用戶無法重新加入
+	client := &model.Client{ID: "kicked-id", UserID: "kicked-user", UserName: "Kicked"}
+	handler.processTextMessage(client, []byte(`{"type":"join_room","target":"room-1"}`))
+
+	assert.Equal(t, "", client.RoomID, "被移出的用戶不應該能重新加入聊天室")
+	mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", mock.Anything, mock.Anything)
+
+	call := mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1]
+	var errorMsg map[string]interface{}
+	assert.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &errorMsg))
+	assert.Equal(t, "error", errorMsg["type"], "應該回覆錯誤訊息給客戶端")
+
+	// 動作 & 斷言：仍停留在聊天室的被移出用戶無法發言
+	client.SetRoomID("room-1")
+	handler.processTextMessage(client, []byte("Hello"))
+
+	assert.Equal(t, "", client.RoomID, "被拒絕的客戶端應該被移出聊天室")
+	mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", mock.Anything, mock.Anything)
+
+	// 動作 & 斷言：重新被加入後可以發言
+	mockRoomService.On("IsMember", "room-1", "kicked-user").Return(true, nil)
+	mockRoomService.On("SendMessage", "room-1", "kicked-user", "Hello again").Return(&model.Message{}, nil)
 	mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Return(nil)
 
-	// 動作 (Act)：執行加入聊天室操作
-	handler.handleJoinRoom(client, "room-1")
+	client.SetRoomID("room-1")
+	handler.processTextMessage(client, []byte("Hello again"))
 
-	// 斷言 (Assert)：驗證加入聊天室的結果
-	assert.Equal(t, "room-1", client.RoomID, "客戶端應該加入聊天室 room-1")
-	mockBroadcastService.AssertCalled(t, "BroadcastToRoom", "room-1", mock.Anything)
+	mockBroadcastService.AssertCalled(t, "BroadcastToRoom", "room-1", []byte("Hello again"))
 }
 
-// TestHandleLeaveRoom 測試客戶端離開聊天室的處理邏輯
+// TestRequireMembershipReconnect 測試啟用成員檢查時，成員斷線後可以重新連線，被移出後則不行
 //
 // 測試目標：
-// 1. 驗證客戶端能正確離開當前聊天室
-// 2. 測試客戶端狀態的正確清理
-// 3. 確保離開聊天室的通知被正確廣播
-// 4. 驗證房間狀態管理的完整性
+// 1. 斷線會將成員記錄標記為不在線，但不影響成員資格
+// 2. 斷線的成員可以重新連線並進入聊天室
+// 3. 被移出的成員重新連線時被拒絕
+func TestRequireMembershipReconnect(t *testing.T) {
+	// 安排 (Arrange)：使用真實的廣播服務、聊天室服務與記憶體資料庫
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	roomService := service.NewRoomService(roomRepo)
+	assert.NoError(t, roomRepo.CreateRoom(&model.Room{ID: "room-1", Name: "測試聊天室", IsPublic: true, IsActive: true, IsListed: true, CreatedBy: "owner-1"}))
+	assert.NoError(t, roomService.JoinRoom("room-1", "user-1", "member"))
+
+	broadcastService := service.NewBroadcastService(repository.NewClientRepository())
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	handler := NewWebSocketHandler(broadcastService, WithLogger(mockLogger), WithRoomService(roomService), WithRequireMembership(true))
+
+	router := setupRouter()
+	router.Use(func(c *gin.Context) {
+		middleware.SetCurrentUser(c, &middleware.UserResponse{ID: "user-1", Username: "Alice"})
+		c.Next()
+	})
+	router.GET("/ws", func(c *gin.Context) {
+		handler.HandleConnection(c.Writer, c.Request)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?roomId=room-1"
+
+	connectedToRoom := func() bool {
+		for _, client := range broadcastService.GetClientsInRoom("room-1") {
+			if client.UserID == "user-1" {
+				return true
+			}
+		}
+		return false
+	}
+
+	// 動作 (Act)：連線後斷線
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.NoError(t, err, "成員應該能夠連線")
+	assert.Eventually(t, connectedToRoom, time.Second, 10*time.Millisecond, "成員應該進入聊天室")
+	conn.Close()
+	assert.Eventually(t, func() bool {
+		count, err := roomService.GetRoomActiveUserCount("room-1")
+		return err == nil && count == 0
+	}, time.Second, 10*time.Millisecond, "斷線後成員記錄應該標記為不在線")
+
+	// 斷言 (Assert)：斷線的成員可以重新連線
+	conn, _, err = websocket.DefaultDialer.Dial(wsURL, nil)
+	if assert.NoError(t, err, "斷線的成員應該能夠重新連線") {
+		assert.Eventually(t, connectedToRoom, time.Second, 10*time.Millisecond, "重新連線的成員應該進入聊天室")
+		conn.Close()
+	}
+	assert.Eventually(t, func() bool { return !connectedToRoom() }, time.Second, 10*time.Millisecond)
+
+	// 斷言 (Assert)：被移出後不能重新連線
+	assert.NoError(t, roomService.KickUser("room-1", "user-1", "owner-1"), "創建者移出成員不應該失敗")
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.Error(t, err, "被移出的用戶不應該能夠重新連線")
+	if resp != nil {
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, "被移出的用戶應該收到 403")
+	}
+}
+
+// TestRejectControlChars 測試包含控制字元的訊息會被拒絕
 //
-// 測試策略：
-// - 測試從已加入房間狀態到離開房間的轉換
-// - 驗證客戶端的 RoomID 被正確清空
-// - 檢查離開房間通知的發送
-// - 確保通知在狀態清理前發送到正確的房間
-func TestHandleLeaveRoom(t *testing.T) {
-	// 安排 (Arrange)：準備離開聊天室的測試環境
+// 測試目標：
+// 1. 包含空字元或其他控制字元的訊息回覆錯誤且不被廣播或保存
+// 2. 換行與定位字元默認允許
+// 3. 停用換行後換行字元也會被拒絕
+// 4. 停用檢查後不限制控制字元
+func TestRejectControlChars(t *testing.T) {
+	setup := func(opts ...HandlerOption) (*WebSocketHandler, *MockBroadcastService) {
+		mockBroadcastService := new(MockBroadcastService)
+		mockLogger := new(MockLogger)
+		mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+		mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Return(nil)
+		mockBroadcastService.On("SendPrivateMessage", mock.Anything, mock.Anything).Return(nil)
+		opts = append([]HandlerOption{WithLogger(mockLogger)}, opts...)
+		return NewWebSocketHandler(mockBroadcastService, opts...), mockBroadcastService
+	}
+
+	rejected := []struct {
+		name    string
+		payload []byte
+	}{
+		{"純文字中的空字元", []byte("hello\x00world")},
+		{"JSON 中的空字元", []byte(`{"type":"message","content":"hello\u0000world"}`)},
+		{"跳脫字元", []byte("\x1b[31mred")},
+		{"C1 控制字元", []byte("bad\u0085char")},
+		{"私人訊息中的控制字元", []byte(`{"type":"private","target":"client-2","content":"hi\u0007"}`)},
+	}
+
+	for _, tc := range rejected {
+		t.Run(tc.name, func(t *testing.T) {
+			// 安排 (Arrange)
+			handler, mockBroadcastService := setup()
+			client := &model.Client{ID: "client-1", UserName: "Alice", RoomID: "room-1"}
+
+			// 動作 (Act)
+			handler.processTextMessage(client, tc.payload)
+
+			// 斷言 (Assert)
+			mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", mock.Anything, mock.Anything)
+			mockBroadcastService.AssertNumberOfCalls(t, "SendPrivateMessage", 1)
+
+			call := mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1]
+			assert.Equal(t, "client-1", call.Arguments.Get(0), "錯誤訊息應該只回覆給發送者")
+			var errorMsg map[string]interface{}
+			assert.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &errorMsg))
+			assert.Equal(t, "error", errorMsg["type"], "應該回覆錯誤訊息給客戶端")
+			assert.Equal(t, errControlChars.Error(), errorMsg["content"], "錯誤訊息應該說明包含控制字元")
+		})
+	}
+
+	t.Run("默認允許換行與定位字元", func(t *testing.T) {
+		handler, mockBroadcastService := setup()
+		client := &model.Client{ID: "client-1", UserName: "Alice", RoomID: "room-1"}
+
+		handler.processTextMessage(client, []byte("第一行\n第二行\t結尾\r\n"))
+
+		mockBroadcastService.AssertCalled(t, "BroadcastToRoom", "room-1", []byte("第一行\n第二行\t結尾\r\n"))
+	})
+
+	t.Run("停用換行", func(t *testing.T) {
+		handler, mockBroadcastService := setup(WithAllowNewlines(false))
+		client := &model.Client{ID: "client-1", UserName: "Alice", RoomID: "room-1"}
+
+		handler.processTextMessage(client, []byte("第一行\n第二行"))
+
+		mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", mock.Anything, mock.Anything)
+	})
+
+	t.Run("停用檢查", func(t *testing.T) {
+		handler, mockBroadcastService := setup(WithRejectControlChars(false))
+		client := &model.Client{ID: "client-1", UserName: "Alice", RoomID: "room-1"}
+
+		handler.processTextMessage(client, []byte("hello\x00world"))
+
+		mockBroadcastService.AssertCalled(t, "BroadcastToRoom", "room-1", []byte("hello\x00world"))
+	})
+
+	t.Run("批次訊息中的控制字元", func(t *testing.T) {
+		handler, mockBroadcastService := setup()
+		client := &model.Client{ID: "client-1", UserName: "Alice", RoomID: "room-1"}
+
+		handler.processTextMessage(client, []byte(`{"type":"batch","messages":[{"content":"ok"},{"content":"bad\u0000"}]}`))
+
+		call := mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1]
+		var ack struct {
+			Results []BatchResult `json:"results"`
+		}
+		assert.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &ack))
+		if assert.Len(t, ack.Results, 2) {
+			assert.True(t, ack.Results[0].OK, "正常的訊息應該成功")
+			assert.False(t, ack.Results[1].OK, "包含控制字元的訊息應該失敗")
+			assert.Equal(t, errControlChars.Error(), ack.Results[1].Error, "錯誤應該說明包含控制字元")
+		}
+	})
+}
+
+// TestValidateMessagePayload 測試 JSON 訊息的類型與內容驗證
+//
+// 測試目標：
+// 1. 超過長度上限的內容回覆錯誤與 nack，且不被廣播
+// 2. 不支援的類型回覆錯誤與 nack，不會作為一般訊息廣播
+// 3. 支援的類型正常處理，內容前後的空白會被去除，其他欄位保留
+// 4. 非 JSON 的純文字仍作為一般訊息廣播
+func TestValidateMessagePayload(t *testing.T) {
+	setup := func() (*WebSocketHandler, *MockBroadcastService, *model.Client) {
+		mockBroadcastService := new(MockBroadcastService)
+		mockLogger := new(MockLogger)
+		mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+		mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Return(nil)
+		mockBroadcastService.On("SendPrivateMessage", mock.Anything, mock.Anything).Return(nil)
+		handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithMaxContentLength(5))
+		return handler, mockBroadcastService, &model.Client{ID: "client-1", UserName: "Alice", RoomID: "room-1"}
+	}
+	replies := func(mockBroadcastService *MockBroadcastService) map[string]map[string]interface{} {
+		result := make(map[string]map[string]interface{})
+		for _, call := range mockBroadcastService.Calls {
+			if call.Method != "SendPrivateMessage" {
+				continue
+			}
+			var reply map[string]interface{}
+			assert.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &reply))
+			result[reply["type"].(string)] = reply
+		}
+		return result
+	}
+
+	t.Run("內容過長", func(t *testing.T) {
+		// 安排 (Arrange)
+		handler, mockBroadcastService, client := setup()
+
+		// 動作 (Act)
+		handler.processTextMessage(client, []byte(`{"type":"message","content":"超過五個字元","clientMsgId":"c-1"}`))
+
+		// 斷言 (Assert)
+		mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", mock.Anything, mock.Anything)
+		reply := replies(mockBroadcastService)
+		assert.Equal(t, errContentLength.Error(), reply["error"]["content"], "錯誤訊息應該說明內容過長")
+		assert.Equal(t, nackInvalidContent, reply["nack"]["reason"], "nack 原因應該是 invalid_content")
+	})
+
+	t.Run("不支援的類型", func(t *testing.T) {
+		// 安排 (Arrange)
+		handler, mockBroadcastService, client := setup()
+
+		// 動作 (Act)
+		handler.processTextMessage(client, []byte(`{"type":"shout","content":"hi","clientMsgId":"c-2"}`))
+
+		// 斷言 (Assert)
+		mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", mock.Anything, mock.Anything)
+		reply := replies(mockBroadcastService)
+		assert.Contains(t, reply["error"]["content"], "shout", "錯誤訊息應該包含不支援的類型")
+		assert.Equal(t, nackUnknownType, reply["nack"]["reason"], "nack 原因應該是 unknown_type")
+	})
+
+	t.Run("支援的類型去除前後空白", func(t *testing.T) {
+		// 安排 (Arrange)
+		handler, mockBroadcastService, client := setup()
+
+		// 動作 (Act)
+		handler.processTextMessage(client, []byte(`{"type":"message","content":"  hi \n","sender":"Alice"}`))
+		handler.processTextMessage(client, []byte(`{"content":"hey"}`))
+
+		// 斷言 (Assert)
+		mockBroadcastService.AssertNumberOfCalls(t, "BroadcastToRoom", 2)
+		var broadcast map[string]interface{}
+		assert.NoError(t, json.Unmarshal(mockBroadcastService.Calls[0].Arguments.Get(1).([]byte), &broadcast))
+		assert.Equal(t, "hi", broadcast["content"], "內容前後的空白應該被去除")
+		assert.Equal(t, "Alice", broadcast["sender"], "其他欄位應該保留")
+		mockBroadcastService.AssertCalled(t, "BroadcastToRoom", "room-1", []byte(`{"content":"hey"}`))
+	})
+
+	t.Run("只有空白的內容", func(t *testing.T) {
+		// 安排 (Arrange)
+		handler, mockBroadcastService, client := setup()
+
+		// 動作 (Act)
+		handler.processTextMessage(client, []byte(`{"type":"message","content":"   "}`))
+
+		// 斷言 (Assert)
+		mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", mock.Anything, mock.Anything)
+		assert.Equal(t, errEmptyContent.Error(), replies(mockBroadcastService)["error"]["content"], "錯誤訊息應該說明內容為空")
+	})
+
+	t.Run("純文字訊息", func(t *testing.T) {
+		// 安排 (Arrange)
+		handler, mockBroadcastService, client := setup()
+
+		// 動作 (Act)
+		handler.processTextMessage(client, []byte("hello"))
+
+		// 斷言 (Assert)
+		mockBroadcastService.AssertCalled(t, "BroadcastToRoom", "room-1", []byte("hello"))
+	})
+}
+
+// TestTypingIndicator 測試輸入中提示的處理
+//
+// 測試目標：
+// 1. typing 訊息透過暫態事件發送給同聊天室的其他客戶端，並排除發送者
+// 2. 事件包含發送者名稱、聊天室 ID 與輸入狀態
+// 3. typing 訊息不會保存到資料庫，也不會作為一般訊息廣播
+// 4. 不在聊天室中的客戶端發送 typing 訊息不會產生事件
+func TestTypingIndicator(t *testing.T) {
+	// 安排 (Arrange)
 	mockBroadcastService := new(MockBroadcastService)
+	mockRoomService := new(MockRoomService)
 	mockLogger := new(MockLogger)
 	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	mockBroadcastService.On("BroadcastEventToRoom", "room-1", "client-1", mock.Anything).Return(nil)
 
-	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger))
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRoomService(mockRoomService))
+	client := &model.Client{ID: "client-1", UserID: "user-1", UserName: "Alice", RoomID: "room-1"}
 
-	// 創建已加入聊天室的客戶端
-	client := &model.Client{
-		ID:       "test-id",
-		UserName: "TestUser",
-		RoomID:   "room-1", // 初始狀態：已在聊天室 room-1 中
+	lastEvent := func() map[string]interface{} {
+		call := mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1]
+		var event map[string]interface{}
+		assert.NoError(t, json.Unmarshal(call.Arguments.Get(2).([]byte), &event))
+		return event
 	}
 
-	// 設定房間廣播的模擬行為
-	mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Return(nil)
+	// 動作 (Act)
+	handler.processTextMessage(client, []byte(`{"type":"typing"}`))
 
-	// 動作 (Act)：執行離開聊天室操作
-	handler.handleLeaveRoom(client)
+	// 斷言 (Assert)
+	event := lastEvent()
+	assert.Equal(t, "typing", event["type"], "事件類型應該是 typing")
+	assert.Equal(t, "Alice", event["userName"], "事件應該包含發送者名稱")
+	assert.Equal(t, "room-1", event["roomId"], "事件應該包含聊天室 ID")
+	assert.Equal(t, true, event["isTyping"], "未提供 isTyping 時應該視為正在輸入")
 
-	// 斷言 (Assert)：驗證離開聊天室的結果
-	assert.Equal(t, "", client.RoomID, "客戶端應該離開聊天室")
-	mockBroadcastService.AssertCalled(t, "BroadcastToRoom", "room-1", mock.Anything)
+	// 動作 & 斷言：停止輸入
+	handler.processTextMessage(client, []byte(`{"type":"typing","isTyping":false}`))
+	assert.Equal(t, false, lastEvent()["isTyping"], "應該轉發停止輸入的狀態")
+
+	mockBroadcastService.AssertNumberOfCalls(t, "BroadcastEventToRoom", 2)
+	mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", mock.Anything, mock.Anything)
+	mockRoomService.AssertNotCalled(t, "SendMessage", mock.Anything, mock.Anything, mock.Anything)
+
+	// 動作 & 斷言：不在聊天室中
+	lobbyClient := &model.Client{ID: "client-2", UserName: "Bob"}
+	handler.processTextMessage(lobbyClient, []byte(`{"type":"typing"}`))
+	mockBroadcastService.AssertNumberOfCalls(t, "BroadcastEventToRoom", 2)
+}
+
+// TestEditAndDeleteMessageOverWebSocket 測試透過 WebSocket 編輯與刪除訊息
+//
+// 測試目標：
+// 1. 作者編輯或刪除訊息後，事件廣播給聊天室中的所有客戶端（包括發送者）
+// 2. 非作者收到錯誤回覆，不產生事件
+// 3. 匿名客戶端不能編輯訊息
+func TestEditAndDeleteMessageOverWebSocket(t *testing.T) {
+	// 安排 (Arrange)
+	mockBroadcastService := new(MockBroadcastService)
+	mockRoomService := new(MockRoomService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRoomService(mockRoomService))
+
+	edited := &model.Message{RoomID: "room-1", UserID: "author-1", Content: "已編輯"}
+	edited.ID = 7
+	mockRoomService.On("EditMessage", uint(7), "author-1", "已編輯").Return(edited, nil)
+	mockRoomService.On("EditMessage", uint(7), "other-user", "竄改").Return(nil, service.ErrNotMessageAuthor)
+	mockRoomService.On("DeleteMessage", uint(7), "author-1").Return(edited, nil)
+	mockBroadcastService.On("BroadcastEventToRoom", "room-1", "", mock.Anything).Return(nil)
+	mockBroadcastService.On("SendPrivateMessage", mock.Anything, mock.Anything).Return(nil)
+
+	author := &model.Client{ID: "client-1", UserID: "author-1", UserName: "Alice", RoomID: "room-1"}
+	other := &model.Client{ID: "client-2", UserID: "other-user", UserName: "Bob", RoomID: "room-1"}
+	anonymous := &model.Client{ID: "client-3", UserName: "Guest", RoomID: "room-1"}
+
+	lastPayload := func(index int) map[string]interface{} {
+		call := mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1]
+		var payload map[string]interface{}
+		assert.NoError(t, json.Unmarshal(call.Arguments.Get(index).([]byte), &payload))
+		return payload
+	}
+
+	// 動作 & 斷言：作者編輯
+	handler.processTextMessage(author, []byte(`{"type":"edit_message","messageId":7,"content":"已編輯"}`))
+	event := lastPayload(2)
+	assert.Equal(t, "message_edited", event["type"], "應該廣播訊息已編輯事件")
+	assert.Equal(t, "已編輯", event["content"], "事件應該包含新的內容")
+
+	// 動作 & 斷言：非作者編輯
+	handler.processTextMessage(other, []byte(`{"type":"edit_message","messageId":7,"content":"竄改"}`))
+	errorMsg := lastPayload(1)
+	assert.Equal(t, "error", errorMsg["type"], "非作者應該收到錯誤回覆")
+	assert.Equal(t, service.ErrNotMessageAuthor.Error(), errorMsg["content"])
+
+	// 動作 & 斷言：匿名客戶端
+	handler.processTextMessage(anonymous, []byte(`{"type":"edit_message","messageId":7,"content":"竄改"}`))
+	assert.Equal(t, "error", lastPayload(1)["type"], "匿名客戶端應該收到錯誤回覆")
+
+	// 動作 & 斷言：作者刪除
+	handler.processTextMessage(author, []byte(`{"type":"delete_message","messageId":7}`))
+	assert.Equal(t, "message_deleted", lastPayload(2)["type"], "應該廣播訊息已刪除事件")
+
+	mockBroadcastService.AssertNumberOfCalls(t, "BroadcastEventToRoom", 2)
+	mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", mock.Anything, mock.Anything)
+	mockRoomService.AssertNotCalled(t, "SendMessage", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestJoinFullRoom 測試加入人數已滿的聊天室時回覆錯誤且保留原本的聊天室
+func TestJoinFullRoom(t *testing.T) {
+	// 安排 (Arrange)
+	mockBroadcastService := new(MockBroadcastService)
+	mockRoomService := new(MockRoomService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRoomService(mockRoomService))
+
+	mockRoomService.On("JoinRoom", "full-room", "user-1", "member").Return(service.ErrRoomFull)
+	mockBroadcastService.On("SendPrivateMessage", "client-1", mock.Anything).Return(nil)
+
+	client := &model.Client{ID: "client-1", UserID: "user-1", UserName: "Alice", RoomID: "room-1"}
+
+	// 動作 (Act)
+	handler.processTextMessage(client, []byte(`{"type":"join_room","target":"full-room"}`))
+
+	// 斷言 (Assert)
+	assert.Equal(t, "room-1", client.RoomID, "無法加入時客戶端應該留在原本的聊天室")
+	mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", mock.Anything, mock.Anything)
+
+	call := mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1]
+	var errorMsg map[string]interface{}
+	assert.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &errorMsg))
+	assert.Equal(t, "error", errorMsg["type"], "應該回覆錯誤訊息給客戶端")
+	assert.Equal(t, service.ErrRoomFull.Error(), errorMsg["content"], "錯誤訊息應該說明聊天室已滿")
+}
+
+// TestAutoCreateRoomOnJoin 測試 join_room 以名稱開啟聊天室並自動創建的行為
+//
+// 測試目標：
+// 1. 啟用時，不存在的聊天室名稱會以預設設定創建公開聊天室，並記錄為加入的用戶所創建
+// 2. 啟用時，已存在的同名聊天室直接加入，不重複創建
+// 3. 啟用時，匿名客戶端不能創建聊天室
+// 4. 停用時（默認），目標直接作為聊天室 ID，不查詢也不創建聊天室
+func TestAutoCreateRoomOnJoin(t *testing.T) {
+	newRoomID := "7f1c9a52-3b6e-4d2a-9c1e-2f4b8d6a0e11"
+	joinMsg := []byte(`{"type":"join_room","target":"lobby"}`)
+
+	setup := func(opts ...HandlerOption) (*WebSocketHandler, *MockBroadcastService, *MockRoomService) {
+		mockBroadcastService := new(MockBroadcastService)
+		mockRoomService := new(MockRoomService)
+		mockLogger := new(MockLogger)
+		mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+		mockBroadcastService.On("BroadcastToRoom", mock.Anything, mock.Anything).Return(nil)
+		mockBroadcastService.On("SendPrivateMessage", mock.Anything, mock.Anything).Return(nil)
+		mockBroadcastService.On("GetClientsInRoom", mock.Anything).Return([]*model.Client{})
+		mockRoomService.On("GetRoomMessages", mock.Anything, mock.Anything).Return([]model.Message{}, nil)
+
+		opts = append([]HandlerOption{WithLogger(mockLogger), WithRoomService(mockRoomService)}, opts...)
+		return NewWebSocketHandler(mockBroadcastService, opts...), mockBroadcastService, mockRoomService
+	}
+
+	t.Run("不存在的聊天室自動創建", func(t *testing.T) {
+		// 安排 (Arrange)
+		handler, _, mockRoomService := setup(WithAutoCreateRooms(true))
+		mockRoomService.On("GetRoomByName", "lobby").Return(nil, repository.ErrRoomNotFound)
+		mockRoomService.On("CreateRoom", service.RoomData{Name: "lobby", IsPublic: true, IsListed: true}, "user-1").
+			Return(&model.Room{ID: newRoomID, Name: "lobby"}, nil)
+		mockRoomService.On("JoinRoom", newRoomID, "user-1", "admin").Return(nil)
+		mockRoomService.On("JoinRoom", newRoomID, "user-1", "member").Return(nil)
+		client := &model.Client{ID: "client-1", UserID: "user-1", UserName: "Alice"}
+
+		// 動作 (Act)
+		handler.processTextMessage(client, joinMsg)
+
+		// 斷言 (Assert)
+		assert.Equal(t, newRoomID, client.RoomID, "客戶端應該加入新創建的聊天室")
+		mockRoomService.AssertExpectations(t)
+	})
+
+	t.Run("已存在的同名聊天室直接加入", func(t *testing.T) {
+		// 安排 (Arrange)
+		handler, _, mockRoomService := setup(WithAutoCreateRooms(true))
+		mockRoomService.On("GetRoomByName", "lobby").Return(&model.Room{ID: newRoomID, Name: "lobby"}, nil)
+		mockRoomService.On("JoinRoom", newRoomID, "user-2", "member").Return(nil)
+		client := &model.Client{ID: "client-2", UserID: "user-2", UserName: "Bob"}
+
+		// 動作 (Act)
+		handler.processTextMessage(client, joinMsg)
+
+		// 斷言 (Assert)
+		assert.Equal(t, newRoomID, client.RoomID, "客戶端應該加入已存在的聊天室")
+		mockRoomService.AssertNotCalled(t, "CreateRoom", mock.Anything, mock.Anything)
+	})
+
+	t.Run("匿名客戶端不能創建聊天室", func(t *testing.T) {
+		// 安排 (Arrange)
+		handler, mockBroadcastService, mockRoomService := setup(WithAutoCreateRooms(true))
+		mockRoomService.On("GetRoomByName", "lobby").Return(nil, repository.ErrRoomNotFound)
+		client := &model.Client{ID: "anon-id", UserName: "Anonymous"}
+
+		// 動作 (Act)
+		handler.processTextMessage(client, joinMsg)
+
+		// 斷言 (Assert)
+		assert.Equal(t, "", client.RoomID, "匿名客戶端不應該加入聊天室")
+		mockRoomService.AssertNotCalled(t, "CreateRoom", mock.Anything, mock.Anything)
+		mockBroadcastService.AssertCalled(t, "SendPrivateMessage", "anon-id", mock.Anything)
+	})
+
+	t.Run("停用時不創建聊天室", func(t *testing.T) {
+		// 安排 (Arrange)
+		handler, _, mockRoomService := setup()
+		mockRoomService.On("JoinRoom", "lobby", "user-1", "member").Return(nil)
+		client := &model.Client{ID: "client-1", UserID: "user-1", UserName: "Alice"}
+
+		// 動作 (Act)
+		handler.processTextMessage(client, joinMsg)
+
+		// 斷言 (Assert)
+		assert.Equal(t, "lobby", client.RoomID, "停用時目標應該直接作為聊天室 ID")
+		mockRoomService.AssertNotCalled(t, "GetRoomByName", mock.Anything)
+		mockRoomService.AssertNotCalled(t, "CreateRoom", mock.Anything, mock.Anything)
+	})
+}
+
+// TestRequireMembershipRejectsAnonymous 測試啟用成員檢查時匿名客戶端無法加入聊天室
+func TestRequireMembershipRejectsAnonymous(t *testing.T) {
+	// 安排 (Arrange)
+	mockBroadcastService := new(MockBroadcastService)
+	mockRoomService := new(MockRoomService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(
+		mockBroadcastService,
+		WithLogger(mockLogger),
+		WithRoomService(mockRoomService),
+		WithRequireMembership(true),
+	)
+	mockBroadcastService.On("SendPrivateMessage", "anon-id", mock.Anything).Return(nil)
+
+	client := &model.Client{ID: "anon-id", UserName: "Anonymous"}
+
+	// 動作 (Act)
+	handler.processTextMessage(client, []byte(`{"type":"join_room","target":"room-1"}`))
+
+	// 斷言 (Assert)
+	assert.Equal(t, "", client.RoomID, "匿名客戶端不應該能加入聊天室")
+	mockRoomService.AssertNotCalled(t, "IsMember", mock.Anything, mock.Anything)
+}
+
+// TestHandlePrivateMessageSelfDM 測試停用自我私訊時，發送給自己的私人訊息會被拒絕
+func TestHandlePrivateMessageSelfDM(t *testing.T) {
+	// 安排 (Arrange)
+	mockBroadcastService := new(MockBroadcastService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithAllowSelfDM(false))
+
+	client := &model.Client{ID: "test-id", UserName: "TestUser"}
+	payload := MessagePayload{Type: "private", Content: "Hello me", Target: "test-id"}
+
+	mockBroadcastService.On("SendPrivateMessage", "test-id", mock.Anything).Return(nil)
+
+	// 動作 (Act)
+	msg, _ := json.Marshal(payload)
+	handler.processTextMessage(client, msg)
+
+	// 斷言 (Assert)：只收到一則錯誤事件，而不是私人訊息
+	mockBroadcastService.AssertNumberOfCalls(t, "SendPrivateMessage", 1)
+
+	call := mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1]
+	var parsedMessage map[string]interface{}
+	assert.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &parsedMessage))
+	assert.Equal(t, "error", parsedMessage["type"], "自我私訊應該返回錯誤事件")
+}
+
+// TestHandleBatch 測試批次發送訊息，部分成功部分失敗時的合併確認
+//
+// 測試目標：
+// 1. 每則訊息都經過一般的廣播或私人訊息流程
+// 2. 單則失敗不影響其他訊息
+// 3. 發送者只收到一則列出每則結果的 batch_ack
+// 4. 一般訊息與私人訊息以外的類型被拒絕，內容去除前後空白後才檢查與發送
+func TestHandleBatch(t *testing.T) {
+	// 安排 (Arrange)
+	mockBroadcastService := new(MockBroadcastService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger))
+
+	client := &model.Client{ID: "bot-id", UserName: "LogBot", RoomID: "room-1"}
+
+	mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Return(nil)
+	mockBroadcastService.On("SendPrivateMessage", "friend-id", mock.Anything).Return(nil)
+	mockBroadcastService.On("SendPrivateMessage", "offline-id", mock.Anything).Return(errors.New("客戶端不存在"))
+	mockBroadcastService.On("SendPrivateMessage", "bot-id", mock.Anything).Return(nil)
+
+	batch := `{"type":"batch","messages":[
+		{"content":"log line 1"},
+		{"content":""},
+		{"type":"private","content":"hi","target":"offline-id"},
+		{"type":"private","content":"hi","target":"friend-id"},
+		{"type":"join_room","content":"room-2","target":"room-2"},
+		{"type":"message","content":"   "},
+		{"type":"message","content":"  log line 2  "}
+	]}`
+
+	// 動作 (Act)
+	handler.processTextMessage(client, []byte(batch))
+
+	// 斷言 (Assert)
+	mockBroadcastService.AssertNumberOfCalls(t, "BroadcastToRoom", 2)
+	mockBroadcastService.AssertCalled(t, "SendPrivateMessage", "friend-id", mock.Anything)
+	mockBroadcastService.AssertCalled(t, "BroadcastToRoom", "room-1", mock.MatchedBy(func(msg []byte) bool {
+		return strings.Contains(string(msg), `"content":"log line 2"`)
+	}))
+
+	call := mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1]
+	assert.Equal(t, "bot-id", call.Arguments.Get(0), "確認應該只回覆給發送者")
+
+	var ack struct {
+		Type    string        `json:"type"`
+		Results []BatchResult `json:"results"`
+	}
+	assert.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &ack), "應該能夠解析批次確認")
+	assert.Equal(t, "batch_ack", ack.Type, "訊息類型應該是 batch_ack")
+	assert.Len(t, ack.Results, 7, "每則訊息都應該有結果")
+	assert.True(t, ack.Results[0].OK, "一般訊息應該成功")
+	assert.False(t, ack.Results[1].OK, "空白訊息應該失敗")
+	assert.Equal(t, errEmptyContent.Error(), ack.Results[1].Error)
+	assert.False(t, ack.Results[2].OK, "發送給離線客戶端的私人訊息應該失敗")
+	assert.True(t, ack.Results[3].OK, "私人訊息應該成功")
+	assert.False(t, ack.Results[4].OK, "批次中不支援的訊息類型應該失敗")
+	assert.Equal(t, "不支援的訊息類型：join_room", ack.Results[4].Error)
+	assert.False(t, ack.Results[5].OK, "只有空白的訊息應該失敗")
+	assert.Equal(t, errEmptyContent.Error(), ack.Results[5].Error)
+	assert.True(t, ack.Results[6].OK, "前後空白應該被去除後發送")
+}
+
+// TestHandleBatchTooLarge 測試超過批次上限時整批被拒絕
+func TestHandleBatchTooLarge(t *testing.T) {
+	// 安排 (Arrange)
+	mockBroadcastService := new(MockBroadcastService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger))
+
+	client := &model.Client{ID: "bot-id", UserName: "LogBot", RoomID: "room-1"}
+	mockBroadcastService.On("SendPrivateMessage", "bot-id", mock.Anything).Return(nil)
+
+	messages := make([]MessagePayload, maxBatchSize+1)
+	for i := range messages {
+		messages[i] = MessagePayload{Content: fmt.Sprintf("log line %d", i)}
+	}
+	batch, _ := json.Marshal(MessagePayload{Type: "batch", Messages: messages})
+
+	// 動作 (Act)
+	handler.processTextMessage(client, batch)
+
+	// 斷言 (Assert)
+	mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", mock.Anything, mock.Anything)
+
+	call := mockBroadcastService.Calls[len(mockBroadcastService.Calls)-1]
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &response))
+	assert.Equal(t, "error", response["type"], "超過上限應該返回錯誤事件")
+}
+
+// TestJoinPrivateRoomRequiresInvite 測試透過 WebSocket 加入私人聊天室需要邀請
+//
+// 測試目標：
+// 1. 未受邀的登入用戶與匿名訪客收到錯誤，且不會進入聊天室
+// 2. 接受邀請後的用戶可以加入私人聊天室
+func TestJoinPrivateRoomRequiresInvite(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	roomService := service.NewRoomService(roomRepo)
+	room := &model.Room{ID: "private-1", Name: "私人聊天室", IsPublic: false, IsActive: true, IsListed: true, CreatedBy: "owner-1"}
+	assert.NoError(t, roomRepo.CreateRoom(room), "創建聊天室不應該失敗")
+
+	user := &model.Client{ID: "client-1", UserID: "user-1", UserName: "Alice"}
+	guest := &model.Client{ID: "client-2", UserName: "訪客"}
+
+	mockBroadcastService := new(MockBroadcastService)
+	mockBroadcastService.On("SendPrivateMessage", mock.Anything, mock.Anything).Return(nil)
+	mockBroadcastService.On("BroadcastToRoom", room.ID, mock.Anything).Return(nil)
+	mockBroadcastService.On("GetClientsInRoom", room.ID).Return([]*model.Client{})
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRoomService(roomService))
+	joinMessage := []byte(fmt.Sprintf(`{"type":"join_room","target":"%s"}`, room.ID))
+
+	// 動作 (Act)
+	handler.processTextMessage(user, joinMessage)
+	handler.processTextMessage(guest, joinMessage)
+	rejectedUserRoom, rejectedGuestRoom := user.RoomID, guest.RoomID
+
+	invite, err := roomService.CreateInvite(room.ID, "owner-1", time.Hour, 0)
+	assert.NoError(t, err, "創建者應該可以創建邀請")
+	_, err = roomService.AcceptInvite(invite.Token, "user-1")
+	assert.NoError(t, err, "接受邀請不應該失敗")
+	handler.processTextMessage(user, joinMessage)
+
+	// 斷言 (Assert)
+	assert.Empty(t, rejectedUserRoom, "未受邀的用戶不應該進入私人聊天室")
+	assert.Empty(t, rejectedGuestRoom, "匿名訪客不應該進入私人聊天室")
+	for _, clientID := range []string{"client-1", "client-2"} {
+		mockBroadcastService.AssertCalled(t, "SendPrivateMessage", clientID, mock.MatchedBy(func(msg []byte) bool {
+			return strings.Contains(string(msg), service.ErrRoomPrivate.Error())
+		}))
+	}
+	assert.Equal(t, room.ID, user.RoomID, "接受邀請後應該可以加入私人聊天室")
+}
+
+// TestRoomMessagePersisted 測試透過 WebSocket 發送到聊天室的訊息會被保存到資料庫
+//
+// 測試目標：
+// 1. 聊天訊息以正確的 RoomID 與 UserID 保存，並能透過 GetRoomMessages 取得
+// 2. JSON 訊息保存其 content 欄位而非整個 JSON
+// 3. 加入與離開等指令不會被保存為聊天內容
+func TestRoomMessagePersisted(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	roomService := service.NewRoomService(roomRepo)
+	room := &model.Room{ID: "room-1", Name: "測試聊天室", IsPublic: true, IsActive: true, IsListed: true}
+	assert.NoError(t, roomRepo.CreateRoom(room), "創建聊天室不應該失敗")
+
+	client := &model.Client{ID: "client-1", UserID: "user-1", UserName: "TestUser"}
+
+	mockBroadcastService := new(MockBroadcastService)
+	mockBroadcastService.On("BroadcastToRoom", room.ID, mock.Anything).Return(nil)
+	mockBroadcastService.On("GetClientsInRoom", room.ID).Return([]*model.Client{client})
+	mockBroadcastService.On("SendPrivateMessage", client.ID, mock.Anything).Return(nil)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRoomService(roomService))
+
+	// 動作 (Act)
+	handler.processTextMessage(client, []byte(fmt.Sprintf(`{"type":"join_room","target":"%s"}`, room.ID)))
+	handler.processTextMessage(client, []byte(`{"content":"Hello, room!","sender":"TestUser"}`))
+	handler.processTextMessage(client, []byte("plain text"))
+	handler.processTextMessage(client, []byte(`{"type":"leave_room"}`))
+
+	// 斷言 (Assert)
+	messages, err := roomService.GetRoomMessages(room.ID, 50)
+	assert.NoError(t, err, "獲取聊天室訊息不應該返回錯誤")
+	assert.Len(t, messages, 2, "只有聊天訊息應該被保存")
+
+	contents := []string{messages[0].Content, messages[1].Content}
+	assert.ElementsMatch(t, []string{"Hello, room!", "plain text"}, contents, "應該保存訊息內容")
+	for _, message := range messages {
+		assert.Equal(t, room.ID, message.RoomID, "訊息的聊天室 ID 應該匹配")
+		assert.Equal(t, "user-1", message.UserID, "訊息的用戶 ID 應該來自客戶端")
+		assert.False(t, message.IsSystemMessage, "聊天訊息不應該是系統訊息")
+	}
+}
+
+// TestAnonymousSenderCannotSpoofUser 測試匿名連接以其他用戶的 ID 作為使用者名稱時，保存的訊息不會歸屬於該用戶
+//
+// 測試目標：
+// 1. 沒有訪客 cookie 的匿名連接保存的訊息沒有發送者 ID
+// 2. 持有訪客身分的匿名連接使用加上前綴的訪客 ID
+// 3. 搜尋結果不會將匿名訊息的作者填為被冒用的用戶
+func TestAnonymousSenderCannotSpoofUser(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫，並建立一個真實用戶
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	roomService := service.NewRoomService(roomRepo)
+	room := &model.Room{ID: "room-1", Name: "測試聊天室", IsPublic: true, IsActive: true, IsListed: true}
+	assert.NoError(t, roomRepo.CreateRoom(room), "創建聊天室不應該失敗")
+	victim := &model.User{ID: "2b1f7c9e-0000-4000-8000-000000000001", Username: "victim", Email: "victim@example.com", Password: "hash"}
+	assert.NoError(t, mockDB.DB.Create(victim).Error, "創建用戶不應該失敗")
+
+	anonymous := &model.Client{ID: "anon-socket", UserName: victim.ID, RoomID: room.ID}
+	guest := &model.Client{ID: "guest-socket", UserName: victim.ID, GuestID: "guest-1", RoomID: room.ID}
+
+	mockBroadcastService := new(MockBroadcastService)
+	mockBroadcastService.On("BroadcastToRoom", room.ID, mock.Anything).Return(nil)
+	mockBroadcastService.On("GetClientsInRoom", room.ID).Return([]*model.Client{anonymous, guest})
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRoomService(roomService))
+
+	// 動作 (Act)
+	handler.processTextMessage(anonymous, []byte("spoofed anonymous"))
+	handler.processTextMessage(guest, []byte("spoofed guest"))
+
+	// 斷言 (Assert)
+	messages, err := roomService.GetRoomMessages(room.ID, 50)
+	assert.NoError(t, err, "獲取聊天室訊息不應該返回錯誤")
+	senders := map[string]string{}
+	for _, message := range messages {
+		senders[message.Content] = message.UserID
+	}
+	assert.Equal(t, "", senders["spoofed anonymous"], "沒有訪客身分的匿名訊息不應該有發送者 ID")
+	assert.Equal(t, "guest:guest-1", senders["spoofed guest"], "訪客訊息應該使用伺服器簽發的訪客 ID")
+
+	results, err := roomService.SearchMessages(room.ID, "spoofed", 50)
+	assert.NoError(t, err, "搜尋訊息不應該返回錯誤")
+	assert.Len(t, results, 2, "應該找到兩則匿名訊息")
+	for _, result := range results {
+		assert.NotEqual(t, victim.ID, result.UserID, "匿名訊息不應該歸屬於被冒用的用戶")
+		assert.NotEqual(t, victim.Username, result.Username, "匿名訊息不應該填入被冒用用戶的名稱")
+	}
+}
+
+// TestJoinLeaveRecordsMembership 測試透過 WebSocket 加入與離開聊天室時會更新資料庫中的成員記錄
+//
+// 測試目標：
+// 1. join_room 會新增成員記錄，使活躍用戶數反映 WebSocket 的在線狀態
+// 2. leave_room 會將成員記錄標記為非活躍
+// 3. 重新加入會重新啟用同一筆記錄，而不是新增重複的記錄
+// 4. 同一用戶仍有其他分頁在聊天室中時，離開不會移除成員記錄
+func TestJoinLeaveRecordsMembership(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	roomService := service.NewRoomService(roomRepo)
+	assert.NoError(t, roomRepo.CreateRoom(&model.Room{ID: "room-1", Name: "測試聊天室", IsPublic: true, IsActive: true, IsListed: true}))
+
+	client := &model.Client{ID: "tab-1", UserID: "user-1", UserName: "TestUser"}
+	otherTab := &model.Client{ID: "tab-2", UserID: "user-1", UserName: "TestUser", RoomID: "room-1"}
+
+	mockBroadcastService := new(MockBroadcastService)
+	mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Return(nil)
+	mockBroadcastService.On("SendPrivateMessage", "tab-1", mock.Anything).Return(nil)
+	mockBroadcastService.On("GetClientsInRoom", "room-1").Return([]*model.Client{}).Once()
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRoomService(roomService))
+	joinMsg := []byte(`{"type":"join_room","target":"room-1"}`)
+	leaveMsg := []byte(`{"type":"leave_room"}`)
+
+	// 動作 & 斷言：加入聊天室
+	handler.processTextMessage(client, joinMsg)
+
+	count, err := roomService.GetRoomActiveUserCount("room-1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count, "加入後應該有 1 位活躍用戶")
+	original, err := roomRepo.GetRoomUser("room-1", "user-1")
+	assert.NoError(t, err, "加入後應該有成員記錄")
+
+	// 動作 & 斷言：另一個分頁仍在聊天室時離開
+	mockBroadcastService.On("GetClientsInRoom", "room-1").Return([]*model.Client{client, otherTab}).Once()
+	handler.processTextMessage(client, leaveMsg)
+
+	count, _ = roomService.GetRoomActiveUserCount("room-1")
+	assert.Equal(t, int64(1), count, "其他分頁仍在聊天室時應該保留成員記錄")
+
+	// 動作 & 斷言：最後一個連接離開
+	mockBroadcastService.On("GetClientsInRoom", "room-1").Return([]*model.Client{client})
+	handler.processTextMessage(client, joinMsg)
+	handler.processTextMessage(client, leaveMsg)
+
+	count, _ = roomService.GetRoomActiveUserCount("room-1")
+	assert.Equal(t, int64(0), count, "離開後應該沒有活躍用戶")
+
+	// 動作 & 斷言：重新加入
+	handler.processTextMessage(client, joinMsg)
+
+	count, _ = roomService.GetRoomActiveUserCount("room-1")
+	assert.Equal(t, int64(1), count, "重新加入後應該有 1 位活躍用戶")
+	rejoined, err := roomRepo.GetRoomUser("room-1", "user-1")
+	assert.NoError(t, err)
+	assert.Equal(t, original.ID, rejoined.ID, "重新加入應該重新啟用同一筆成員記錄")
+
+	var total int64
+	mockDB.DB.Model(&model.RoomUser{}).Where("room_id = ?", "room-1").Count(&total)
+	assert.Equal(t, int64(1), total, "不應該產生重複的成員記錄")
+}
+
+// TestHandleJoinRoom 測試客戶端加入聊天室的處理邏輯
+//
+// 測試目標：
+// 1. 驗證客戶端能正確加入指定的聊天室
+// 2. 測試客戶端狀態的正確更新
+// 3. 確保加入聊天室的通知被正確廣播
+// 4. 驗證房間管理的基本功能
+//
+// 測試策略：
+// - 測試從無房間狀態到加入房間的轉換
+// - 驗證客戶端的 RoomID 屬性被正確設定
+// - 檢查房間廣播通知的發送
+// - 確保 Mock 服務的方法被正確調用
+func TestHandleJoinRoom(t *testing.T) {
+	// 安排 (Arrange)：準備加入聊天室的測試環境
+	mockBroadcastService := new(MockBroadcastService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger))
+
+	// 創建尚未加入任何聊天室的客戶端
+	client := &model.Client{
+		ID:       "test-id",
+		UserName: "TestUser",
+		RoomID:   "", // 初始狀態：未加入任何聊天室
+	}
+
+	// 設定房間廣播與名單私訊的模擬行為
+	mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Return(nil)
+	mockBroadcastService.On("GetClientsInRoom", "room-1").Return([]*model.Client{})
+	mockBroadcastService.On("SendPrivateMessage", "test-id", mock.Anything).Return(nil)
+
+	// 動作 (Act)：執行加入聊天室操作
+	handler.handleJoinRoom(client, "room-1")
+
+	// 斷言 (Assert)：驗證加入聊天室的結果
+	assert.Equal(t, "room-1", client.RoomID, "客戶端應該加入聊天室 room-1")
+	assertPresenceEvent(t, mockBroadcastService, "join", "TestUser", "room-1")
+}
+
+// TestJoinRoomSendsRoster 測試加入聊天室時會私訊目前的成員名單給加入者
+//
+// 測試目標：
+// 1. 加入者收到 roster 事件，包含聊天室中已有的用戶（不含自己）
+// 2. 同一用戶的多個連接只列一次，匿名連接只計入 guests
+// 3. 房間內其他人仍然收到加入的 presence 事件
+func TestJoinRoomSendsRoster(t *testing.T) {
+	// 安排 (Arrange)：Bob 與 Alice（兩個分頁）及一位匿名訪客已在聊天室中
+	mockBroadcastService := new(MockBroadcastService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger))
+
+	carol := &model.Client{ID: "carol-tab", UserName: "Carol"}
+	inRoom := []*model.Client{
+		{ID: "bob-tab", UserName: "Bob", RoomID: "room-1"},
+		{ID: "alice-tab-1", UserName: "Alice", RoomID: "room-1"},
+		{ID: "alice-tab-2", UserName: "Alice", RoomID: "room-1"},
+		{ID: "guest-tab", RoomID: "room-1"},
+		carol,
+	}
+	mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Return(nil)
+	mockBroadcastService.On("GetClientsInRoom", "room-1").Return(inRoom)
+	mockBroadcastService.On("SendPrivateMessage", "carol-tab", mock.Anything).Return(nil)
+
+	// 動作 (Act)：Carol 加入聊天室
+	handler.handleJoinRoom(carol, "room-1")
+
+	// 斷言 (Assert)：Carol 收到不含自己的成員名單
+	var roster struct {
+		Type   string   `json:"type"`
+		RoomID string   `json:"roomId"`
+		Users  []string `json:"users"`
+		Guests int      `json:"guests"`
+	}
+	mockBroadcastService.AssertCalled(t, "SendPrivateMessage", "carol-tab", mock.Anything)
+	for _, call := range mockBroadcastService.Calls {
+		if call.Method == "SendPrivateMessage" {
+			assert.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &roster), "名單應該是 JSON 事件")
+		}
+	}
+	assert.Equal(t, "roster", roster.Type, "事件類型應該是 roster")
+	assert.Equal(t, "room-1", roster.RoomID, "名單應該包含聊天室 ID")
+	assert.Equal(t, []string{"Alice", "Bob"}, roster.Users, "名單應該列出其他用戶且不重複")
+	assert.Equal(t, 1, roster.Guests, "匿名連接應該只計入 guests")
+	assertPresenceEvent(t, mockBroadcastService, "join", "Carol", "room-1")
+}
+
+// TestJoinRoomSendsRecentHistory 測試加入聊天室時只對加入者發送最近的訊息
+//
+// 測試目標：
+// 1. 只發送設定數量的最新訊息，並依由舊到新的順序排列
+// 2. 歷史訊息以私訊發送，不會廣播給聊天室中的其他人
+// 3. 每則訊息附上表情回應數量
+func TestJoinRoomSendsRecentHistory(t *testing.T) {
+	// 安排 (Arrange)：聊天室中已有三則訊息，最新的一則有表情回應
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	assert.NoError(t, roomRepo.CreateRoom(&model.Room{ID: "room-1", Name: "測試聊天室", IsPublic: true, IsActive: true, IsListed: true}))
+	base := time.Now().Add(-time.Hour)
+	var latest *model.Message
+	for i, content := range []string{"第一則", "第二則", "第三則"} {
+		latest = &model.Message{RoomID: "room-1", UserID: "user-1", Content: content}
+		latest.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		assert.NoError(t, roomRepo.SaveMessage(latest))
+	}
+	for _, reaction := range []model.MessageReaction{
+		{MessageID: latest.ID, UserID: "user-1", Emoji: "👍"},
+		{MessageID: latest.ID, UserID: "user-2", Emoji: "👍"},
+		{MessageID: latest.ID, UserID: "user-2", Emoji: "🎉"},
+	} {
+		assert.NoError(t, roomRepo.AddReaction(&reaction))
+	}
+
+	client := &model.Client{ID: "tab-1", UserName: "Carol"}
+	mockBroadcastService := new(MockBroadcastService)
+	mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Return(nil)
+	mockBroadcastService.On("GetClientsInRoom", "room-1").Return([]*model.Client{client})
+	mockBroadcastService.On("SendPrivateMessage", "tab-1", mock.Anything).Return(nil)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger),
+		WithRoomService(service.NewRoomService(roomRepo)), WithJoinHistorySize(2))
+
+	// 動作 (Act)：加入聊天室
+	handler.handleJoinRoom(client, "room-1")
+
+	// 斷言 (Assert)：加入者收到最近兩則訊息，由舊到新
+	var history struct {
+		Type     string                   `json:"type"`
+		RoomID   string                   `json:"roomId"`
+		Messages []HistoryMessageResponse `json:"messages"`
+	}
+	for _, call := range mockBroadcastService.Calls {
+		if call.Method == "GetClientsInRoom" {
+			continue
+		}
+		var event map[string]interface{}
+		assert.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &event))
+		if event["type"] != "history" {
+			continue
+		}
+		assert.Equal(t, "SendPrivateMessage", call.Method, "歷史訊息不應該廣播給其他人")
+		assert.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &history))
+	}
+	assert.Equal(t, "history", history.Type, "加入者應該收到歷史訊息")
+	assert.Equal(t, "room-1", history.RoomID, "歷史訊息應該包含聊天室 ID")
+	if assert.Len(t, history.Messages, 2, "應該只發送設定數量的訊息") {
+		assert.Equal(t, "第二則", history.Messages[0].Content, "訊息應該由舊到新排列")
+		assert.Equal(t, "第三則", history.Messages[1].Content, "最新的訊息應該在最後")
+		assert.Empty(t, history.Messages[0].Reactions, "沒有回應的訊息應該返回空陣列")
+		assert.Equal(t, []ReactionResponse{{Emoji: "👍", Count: 2}, {Emoji: "🎉", Count: 1}}, history.Messages[1].Reactions, "訊息應該附上表情回應數量")
+	}
+}
+
+// TestHandleLeaveRoom 測試客戶端離開聊天室的處理邏輯
+//
+// 測試目標：
+// 1. 驗證客戶端能正確離開當前聊天室
+// 2. 測試客戶端狀態的正確清理
+// 3. 確保離開聊天室的通知被正確廣播
+// 4. 驗證房間狀態管理的完整性
+//
+// 測試策略：
+// - 測試從已加入房間狀態到離開房間的轉換
+// - 驗證客戶端的 RoomID 被正確清空
+// - 檢查離開房間通知的發送
+// - 確保通知在狀態清理前發送到正確的房間
+func TestHandleLeaveRoom(t *testing.T) {
+	// 安排 (Arrange)：準備離開聊天室的測試環境
+	mockBroadcastService := new(MockBroadcastService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger))
+
+	// 創建已加入聊天室的客戶端
+	client := &model.Client{
+		ID:       "test-id",
+		UserName: "TestUser",
+		RoomID:   "room-1", // 初始狀態：已在聊天室 room-1 中
+	}
+
+	// 設定房間廣播的模擬行為
+	mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Return(nil)
+
+	// 動作 (Act)：執行離開聊天室操作
+	handler.handleLeaveRoom(client)
+
+	// 斷言 (Assert)：驗證離開聊天室的結果
+	assert.Equal(t, "", client.RoomID, "客戶端應該離開聊天室")
+	event := assertPresenceEvent(t, mockBroadcastService, "leave", "TestUser", "room-1")
+	assert.NotContains(t, event, "reason", "主動離開不應該包含斷線原因")
+}
+
+// assertPresenceEvent 檢查最後一次廣播到聊天室的內容為指定的 presence 事件，並返回解析後的事件
+func assertPresenceEvent(t *testing.T, mockBroadcastService *MockBroadcastService, eventType string, user string, roomID string) map[string]interface{} {
+	t.Helper()
+
+	var event map[string]interface{}
+	for _, call := range mockBroadcastService.Calls {
+		if call.Method == "BroadcastToRoom" {
+			event = nil
+			assert.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &event), "廣播內容應該是 JSON 事件")
+		}
+	}
+	if !assert.NotNil(t, event, "應該廣播 presence 事件") {
+		return nil
+	}
+
+	assert.Equal(t, "presence", event["type"], "事件類型應該是 presence")
+	assert.Equal(t, eventType, event["event"], "presence 事件種類應該匹配")
+	assert.Equal(t, user, event["user"], "事件應該包含用戶名稱")
+	assert.Equal(t, roomID, event["roomId"], "事件應該包含聊天室 ID")
+	assert.NotZero(t, event["time"], "事件應該包含時間")
+	return event
+}
+
+// timeoutError 模擬網路讀取逾時錯誤
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// TestClassifyDisconnect 測試斷線原因的分類邏輯
+//
+// 測試目標：
+// 1. 正常關閉（1000/1001）應分類為 normal
+// 2. 讀取逾時應分類為 timeout
+// 3. 異常關閉或其他錯誤應分類為 error
+func TestClassifyDisconnect(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected DisconnectReason
+	}{
+		{"正常關閉", &websocket.CloseError{Code: websocket.CloseNormalClosure}, DisconnectNormal},
+		{"客戶端離開", &websocket.CloseError{Code: websocket.CloseGoingAway}, DisconnectNormal},
+		{"讀取逾時", fmt.Errorf("read: %w", timeoutError{}), DisconnectTimeout},
+		{"異常關閉", &websocket.CloseError{Code: websocket.CloseAbnormalClosure}, DisconnectError},
+		{"其他錯誤", errors.New("connection reset by peer"), DisconnectError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, classifyDisconnect(tc.err), "斷線原因分類應該正確")
+		})
+	}
+}
+
+// TestDisconnectLeaveNotice 測試正常與異常斷線時廣播的離開通知
+//
+// 測試目標：
+// 1. 正常關閉時廣播不含斷線原因的 leave 事件
+// 2. 異常斷線時的事件應以 reason 標示連線異常
+// 3. 未送出關閉幀的斷線（1006）只記錄為一般資訊，不記錄為錯誤
+func TestDisconnectLeaveNotice(t *testing.T) {
+	// 安排 (Arrange)
+	mockBroadcastService := new(MockBroadcastService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger))
+
+	leaveNotices := make(chan map[string]interface{}, 2)
+	mockBroadcastService.On("AddClient", mock.Anything).Return(nil)
+	mockBroadcastService.On("RemoveClient", mock.Anything).Return(nil)
+	mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Run(func(args mock.Arguments) {
+		var event map[string]interface{}
+		json.Unmarshal(args.Get(1).([]byte), &event)
+		leaveNotices <- event
+	}).Return(nil)
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleConnection))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "?username=TestUser&roomId=room-1"
+
+	waitNotice := func() map[string]interface{} {
+		select {
+		case notice := <-leaveNotices:
+			return notice
+		case <-time.After(time.Second):
+			t.Fatal("應該廣播離開通知")
+			return nil
+		}
+	}
+
+	// 動作 (Act)：正常關閉
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.NoError(t, err, "應該能夠建立連接")
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	conn.Close()
+
+	// 斷言 (Assert)
+	notice := waitNotice()
+	assert.Equal(t, "presence", notice["type"], "離開通知應該是 presence 事件")
+	assert.Equal(t, "leave", notice["event"], "正常關閉應該廣播離開事件")
+	assert.Equal(t, "TestUser", notice["user"], "離開事件應該包含用戶名稱")
+	assert.NotContains(t, notice, "reason", "正常關閉不應該標示斷線原因")
+
+	// 動作 (Act)：不發送關閉幀直接斷開（異常關閉）
+	conn2, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.NoError(t, err, "應該能夠建立連接")
+	conn2.UnderlyingConn().Close()
+
+	// 斷言 (Assert)
+	notice = waitNotice()
+	assert.Equal(t, "leave", notice["event"], "異常斷線應該廣播離開事件")
+	assert.Equal(t, string(DisconnectError), notice["reason"], "異常斷線應該標示連線異常")
+	mockLogger.AssertNotCalled(t, "Error", mock.Anything, mock.Anything)
+}
+
+// TestCompressionNegotiation 測試啟用壓縮時記錄每個連接是否協商成功，並透過管理員 API 查詢
+//
+// 測試目標：
+// 1. 提供 permessage-deflate 的客戶端記錄為已壓縮
+// 2. 未提供壓縮擴充的客戶端記錄為未壓縮
+// 3. 未啟用壓縮時即使客戶端提供擴充也不會協商
+// 4. GET /api/admin/connections 返回每個連接的壓縮狀態
+func TestCompressionNegotiation(t *testing.T) {
+	// 安排 (Arrange)：使用真實的廣播服務與在線狀態服務
+	clientRepo := repository.NewClientRepository()
+	broadcastService := service.NewBroadcastService(clientRepo)
+	presenceService := service.NewPresenceService(clientRepo, nil)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+
+	compressing := httptest.NewServer(http.HandlerFunc(NewWebSocketHandler(broadcastService, WithLogger(mockLogger), WithCompression(true)).HandleConnection))
+	defer compressing.Close()
+	plain := httptest.NewServer(http.HandlerFunc(NewWebSocketHandler(broadcastService, WithLogger(mockLogger)).HandleConnection))
+	defer plain.Close()
+
+	connect := func(server *httptest.Server, userName string, offerCompression bool) *websocket.Conn {
+		dialer := websocket.Dialer{EnableCompression: offerCompression}
+		conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?username="+userName, nil)
+		assert.NoError(t, err, "應該能夠建立連接")
+		return conn
+	}
+	connections := func() map[string]ConnectionResponse {
+		mockUserService := new(MockUserService)
+		admin := &model.User{ID: "admin-1", Role: "admin"}
+		mockUserService.On("GetUserByID", "admin-1").Return(admin, nil)
+		mockUserService.On("IsAdmin", admin).Return(true)
+		router := setupAdminRouter(&middleware.UserResponse{ID: "admin-1", Role: "admin"})
+		NewAdminHandler(mockUserService, presenceService).RegisterRoutes(router)
+
+		req, _ := http.NewRequest("GET", "/api/admin/connections", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, "狀態碼應該是 200")
+
+		var response []ConnectionResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), "應該能夠解析響應")
+		byName := make(map[string]ConnectionResponse)
+		for _, connection := range response {
+			byName[connection.UserName] = connection
+		}
+		return byName
+	}
+
+	// 動作 (Act)
+	conns := []*websocket.Conn{
+		connect(compressing, "deflate", true),
+		connect(compressing, "identity", false),
+		connect(plain, "disabled", true),
+	}
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	assert.Eventually(t, func() bool {
+		return len(presenceService.GetConnections()) == len(conns)
+	}, time.Second, 10*time.Millisecond, "所有連接都應該被記錄")
+	result := connections()
+
+	// 斷言 (Assert)
+	assert.True(t, result["deflate"].Compressed, "提供壓縮擴充的客戶端應該記錄為已壓縮")
+	assert.False(t, result["identity"].Compressed, "未提供壓縮擴充的客戶端應該記錄為未壓縮")
+	assert.False(t, result["disabled"].Compressed, "未啟用壓縮時不應該協商壓縮")
+	assert.NotEmpty(t, result["deflate"].ID, "應該包含連接 ID")
+	mockLogger.AssertCalled(t, "Info", "Client %s compression negotiated: %t", []interface{}{result["deflate"].ID, true})
+	mockLogger.AssertCalled(t, "Info", "Client %s compression negotiated: %t", []interface{}{result["identity"].ID, false})
+}
+
+// TestCompressedLargeMessage 測試啟用壓縮時可以透過協商壓縮的連接交換大型訊息
+func TestCompressedLargeMessage(t *testing.T) {
+	// 安排 (Arrange)：啟用壓縮並放寬讀取上限的伺服器，以及支援壓縮的客戶端
+	broadcastService := service.NewBroadcastService(repository.NewClientRepository())
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	handler := NewWebSocketHandler(broadcastService, WithLogger(mockLogger), WithCompression(true), WithReadLimit(1<<20), WithMaxContentLength(0))
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleConnection))
+	defer server.Close()
+
+	dialer := websocket.Dialer{EnableCompression: true}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?username=Alice", nil)
+	if !assert.NoError(t, err, "應該能夠建立連接") {
+		return
+	}
+	defer conn.Close()
+	assert.Contains(t, resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate", "應該協商 permessage-deflate")
+
+	content := strings.Repeat("大型訊息內容", 8192)
+	payload, _ := json.Marshal(MessagePayload{Type: "message", Content: content})
+
+	// 動作 (Act)：加入聊天室後發送大型訊息
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"join_room","target":"room-1"}`)))
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, payload))
+
+	// 斷言 (Assert)：發送者收到完整的訊息內容
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if !assert.NoError(t, err, "應該收到廣播的大型訊息") {
+			return
+		}
+		if strings.Contains(string(data), content) {
+			break
+		}
+	}
+}
+
+// TestHandleConnectionWithInvite 測試透過邀請碼建立 WebSocket 連接
+//
+// 測試目標：
+// 1. 已登入用戶使用有效邀請會加入邀請的聊天室
+// 2. 過期的邀請在升級前被拒絕
+// 3. 未預期的錯誤以通用訊息拒絕，不透露內部細節
+// 4. 未登入的連接不能使用邀請
+func TestHandleConnectionWithInvite(t *testing.T) {
+	// 安排 (Arrange)
+	mockBroadcastService := new(MockBroadcastService)
+	mockRoomService := new(MockRoomService)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRoomService(mockRoomService))
+
+	added := make(chan *model.Client, 1)
+	mockBroadcastService.On("AddClient", mock.Anything).Run(func(args mock.Arguments) {
+		added <- args.Get(0).(*model.Client)
+	}).Return(nil)
+	mockBroadcastService.On("RemoveClient", mock.Anything).Return(nil)
+	mockBroadcastService.On("BroadcastToRoom", mock.Anything, mock.Anything).Return(nil)
+	mockRoomService.On("AcceptInvite", "valid", "user-1").Return(&model.Room{ID: "private-room"}, nil)
+	mockRoomService.On("AcceptInvite", "expired", "user-1").Return(nil, service.ErrInviteExpired)
+	mockRoomService.On("AcceptInvite", "broken", "user-1").Return(nil, errors.New("pq: connection refused"))
+	mockRoomService.On("JoinRoom", "private-room", "user-1", "member").Return(nil)
+	mockRoomService.On("LeaveRoom", "private-room", "user-1").Return(nil)
+	mockBroadcastService.On("GetClientsInRoom", "private-room").Return([]*model.Client{})
+
+	router := setupRouter()
+	router.Use(func(c *gin.Context) {
+		if c.Query("auth") == "1" {
+			middleware.SetCurrentUser(c, &middleware.UserResponse{ID: "user-1", Username: "Alice"})
+		}
+		c.Next()
+	})
+	router.GET("/ws", func(c *gin.Context) {
+		handler.HandleConnection(c.Writer, c.Request)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	// 動作 & 斷言：有效邀請
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?auth=1&invite=valid", nil)
+	assert.NoError(t, err, "使用有效邀請應該能夠建立連接")
+	select {
+	case client := <-added:
+		assert.Equal(t, "private-room", client.RoomID, "客戶端應該加入邀請的聊天室")
+		assert.Equal(t, "user-1", client.UserID, "客戶端應該使用會話中的用戶 ID")
+		assert.Equal(t, "Alice", client.UserName, "客戶端應該使用會話中的用戶名")
+	case <-time.After(time.Second):
+		t.Fatal("客戶端應該被添加")
+	}
+	conn.Close()
+
+	// 過期邀請
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL+"?auth=1&invite=expired", nil)
+	assert.Error(t, err, "過期邀請不應該建立連接")
+	assert.Equal(t, http.StatusGone, resp.StatusCode, "過期邀請應該返回 410")
+
+	// 未預期的錯誤
+	_, resp, err = websocket.DefaultDialer.Dial(wsURL+"?auth=1&invite=broken", nil)
+	assert.Error(t, err, "使用邀請失敗時不應該建立連接")
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode, "未預期的錯誤應該返回 500")
+	body, _ := io.ReadAll(resp.Body)
+	assert.NotContains(t, string(body), "connection refused", "錯誤回應不應該透露內部錯誤")
+
+	// 未登入
+	_, resp, err = websocket.DefaultDialer.Dial(wsURL+"?invite=valid", nil)
+	assert.Error(t, err, "未登入不應該建立連接")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "未登入應該返回 401")
+}
+
+// TestMaxConnections 測試同時連接數上限
+//
+// 測試目標：
+// 1. 未設置排隊時間時，超過上限的連接立即以 503 拒絕，名額釋放後可以再次連接
+// 2. 設置排隊時間時，超過上限的連接會等待名額釋放後建立
+// 3. 排隊逾時的連接以 503 拒絕
+func TestMaxConnections(t *testing.T) {
+	newServer := func(max int, queueTimeout time.Duration) (string, func()) {
+		mockBroadcastService := new(MockBroadcastService)
+		mockBroadcastService.On("AddClient", mock.Anything).Return(nil)
+		mockBroadcastService.On("RemoveClient", mock.Anything).Return(nil)
+		mockLogger := new(MockLogger)
+		mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+		mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+
+		handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithMaxConnections(max, queueTimeout))
+		router := setupRouter()
+		router.GET("/ws", func(c *gin.Context) {
+			handler.HandleConnection(c.Writer, c.Request)
+		})
+		server := httptest.NewServer(router)
+		return "ws" + strings.TrimPrefix(server.URL, "http") + "/ws", server.Close
+	}
+
+	t.Run("超過上限時拒絕", func(t *testing.T) {
+		// 安排 (Arrange)
+		wsURL, closeServer := newServer(1, 0)
+		defer closeServer()
+
+		first, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		assert.NoError(t, err, "上限內的連接應該能夠建立")
+
+		// 動作 (Act)
+		_, resp, rejectErr := websocket.DefaultDialer.Dial(wsURL, nil)
+		first.Close()
+
+		// 斷言 (Assert)
+		assert.Error(t, rejectErr, "超過上限的連接應該被拒絕")
+		if assert.NotNil(t, resp, "應該收到 HTTP 響應") {
+			assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "超過上限時應該返回 503")
+		}
+		assert.Eventually(t, func() bool {
+			conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+			if err != nil {
+				return false
+			}
+			conn.Close()
+			return true
+		}, time.Second, 20*time.Millisecond, "連接關閉後名額應該被釋放")
+	})
+
+	t.Run("排隊等待釋放的名額", func(t *testing.T) {
+		// 安排 (Arrange)
+		wsURL, closeServer := newServer(1, 5*time.Second)
+		defer closeServer()
+
+		first, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		assert.NoError(t, err, "上限內的連接應該能夠建立")
+
+		// 動作 (Act)
+		queued := make(chan error, 1)
+		go func() {
+			conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+			if err == nil {
+				conn.Close()
+			}
+			queued <- err
+		}()
+
+		// 斷言 (Assert)
+		select {
+		case <-queued:
+			t.Fatal("名額已滿時新連接應該排隊等待")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		first.Close()
+		select {
+		case err := <-queued:
+			assert.NoError(t, err, "名額釋放後排隊的連接應該建立")
+		case <-time.After(2 * time.Second):
+			t.Fatal("排隊的連接應該在名額釋放後建立")
+		}
+	})
+
+	t.Run("排隊逾時", func(t *testing.T) {
+		// 安排 (Arrange)
+		wsURL, closeServer := newServer(1, 50*time.Millisecond)
+		defer closeServer()
+
+		first, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		assert.NoError(t, err, "上限內的連接應該能夠建立")
+		defer first.Close()
+
+		// 動作 (Act)
+		_, resp, rejectErr := websocket.DefaultDialer.Dial(wsURL, nil)
+
+		// 斷言 (Assert)
+		assert.Error(t, rejectErr, "排隊逾時的連接應該被拒絕")
+		if assert.NotNil(t, resp, "應該收到 HTTP 響應") {
+			assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "排隊逾時應該返回 503")
+		}
+	})
+}
+
+// TestPingSenderWithConcurrentBroadcasts 測試 ping 發送器與廣播同時寫入同一個連接
+//
+// 測試目標：
+// 1. ping 與廣播訊息都透過客戶端的寫入鎖發送，不應該產生並發寫入（使用 -race 執行）
+// 2. 客戶端在 ping 持續發送期間能完整收到所有廣播訊息
+func TestPingSenderWithConcurrentBroadcasts(t *testing.T) {
+	// 安排 (Arrange)：使用真實的廣播服務與極短的 ping 間隔
+	broadcastService := service.NewBroadcastService(repository.NewClientRepository())
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(broadcastService, WithLogger(mockLogger), WithPingInterval(time.Millisecond))
+
+	router := setupRouter()
+	router.GET("/ws", func(c *gin.Context) {
+		handler.HandleConnection(c.Writer, c.Request)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	assert.NoError(t, err, "應該能夠建立連接")
+	defer conn.Close()
+
+	pings := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pings <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+
+	const total = 200
+	received := make(chan int, 1)
+	go func() {
+		count := 0
+		for count < total {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				break
+			}
+			count++
+		}
+		received <- count
+	}()
+
+	// 等待第一個 ping 確認 ping 發送器已在運行
+	select {
+	case <-pings:
+	case <-time.After(time.Second):
+		t.Fatal("應該收到 ping")
+	}
+
+	// 動作 (Act)：在 ping 持續發送期間大量廣播
+	for i := 0; i < total; i++ {
+		assert.NoError(t, broadcastService.BroadcastMessage([]byte(fmt.Sprintf("message %d", i))))
+	}
+
+	// 斷言 (Assert)
+	select {
+	case count := <-received:
+		assert.Equal(t, total, count, "客戶端應該收到所有廣播訊息")
+	case <-time.After(5 * time.Second):
+		t.Fatal("應該在時限內收到所有廣播訊息")
+	}
+}
+
+// TestRateLimit 測試每個客戶端的訊息速率限制
+//
+// 測試目標：
+// 1. 瞬間送出大量訊息時，只有 burst 數量的訊息被廣播
+// 2. 超過限制的訊息被丟棄並收到 rate_limited 通知，連接不會被中斷
+// 3. 經過時間補充令牌後可以再次發送，且各客戶端的限制互不影響
+// 4. 批次訊息依則數消耗令牌，令牌不足時整批被拒絕
+func TestRateLimit(t *testing.T) {
+	// 安排 (Arrange)
+	now := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time {
+		return now
+	})
+	defer model.ResetTimeNow()
+
+	mockBroadcastService := new(MockBroadcastService)
+	mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Return(nil)
+	mockBroadcastService.On("SendPrivateMessage", mock.Anything, mock.Anything).Return(nil)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRateLimit(2, 3))
+	flooder := &model.Client{ID: "client-1", UserName: "Flooder", RoomID: "room-1"}
+	other := &model.Client{ID: "client-2", UserName: "Other", RoomID: "room-1"}
+	isRateLimited := mock.MatchedBy(func(msg []byte) bool {
+		var notice map[string]interface{}
+		return json.Unmarshal(msg, &notice) == nil && notice["type"] == "rate_limited"
+	})
+
+	// 動作 (Act)
+	for i := 0; i < 10; i++ {
+		handler.processTextMessage(flooder, []byte(fmt.Sprintf("訊息%d", i)))
+	}
+
+	// 斷言 (Assert)
+	mockBroadcastService.AssertNumberOfCalls(t, "BroadcastToRoom", 3)
+	mockBroadcastService.AssertCalled(t, "BroadcastToRoom", "room-1", []byte("訊息2"))
+	mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", "room-1", []byte("訊息3"))
+	mockBroadcastService.AssertNumberOfCalls(t, "SendPrivateMessage", 7)
+	mockBroadcastService.AssertCalled(t, "SendPrivateMessage", "client-1", isRateLimited)
+	assert.Equal(t, "room-1", flooder.RoomID, "超過限制的客戶端應該留在聊天室")
+
+	// 動作 & 斷言：其他客戶端不受影響
+	handler.processTextMessage(other, []byte("其他人的訊息"))
+	mockBroadcastService.AssertCalled(t, "BroadcastToRoom", "room-1", []byte("其他人的訊息"))
+
+	// 動作 & 斷言：經過 1 秒補充 2 個令牌
+	now = now.Add(time.Second)
+	for i := 10; i < 13; i++ {
+		handler.processTextMessage(flooder, []byte(fmt.Sprintf("訊息%d", i)))
+	}
+	mockBroadcastService.AssertCalled(t, "BroadcastToRoom", "room-1", []byte("訊息11"))
+	mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", "room-1", []byte("訊息12"))
+	mockBroadcastService.AssertNumberOfCalls(t, "BroadcastToRoom", 6)
+
+	// 動作 & 斷言：批次訊息每則消耗一個令牌，令牌不足時整批被拒絕
+	now = now.Add(time.Second)
+	handler.processTextMessage(flooder, []byte(`{"type":"batch","messages":[{"content":"批次1"},{"content":"批次2"},{"content":"批次3"}]}`))
+	mockBroadcastService.AssertNumberOfCalls(t, "BroadcastToRoom", 6)
+
+	now = now.Add(500 * time.Millisecond)
+	handler.processTextMessage(flooder, []byte(`{"type":"batch","messages":[{"content":"批次1"},{"content":"批次2"},{"content":"批次3"}]}`))
+	mockBroadcastService.AssertNumberOfCalls(t, "BroadcastToRoom", 9)
+	handler.processTextMessage(flooder, []byte("訊息13"))
+	mockBroadcastService.AssertNotCalled(t, "BroadcastToRoom", "room-1", []byte("訊息13"))
+}
+
+// TestDeliveryAcknowledgements 測試訊息送達確認
+//
+// 測試目標：
+// 1. 提供 clientMsgId 的訊息保存並廣播後，發送者收到帶有保存後訊息 ID 的 ack
+// 2. 被拒絕的訊息（超過速率限制、非聊天室成員）收到帶有原因的 nack
+// 3. 未提供 clientMsgId 時不回覆 ack
+func TestDeliveryAcknowledgements(t *testing.T) {
+	// 安排 (Arrange)
+	now := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time {
+		return now
+	})
+	defer model.ResetTimeNow()
+
+	mockBroadcastService := new(MockBroadcastService)
+	mockBroadcastService.On("BroadcastToRoom", "room-1", mock.Anything).Return(nil)
+	mockBroadcastService.On("SendPrivateMessage", mock.Anything, mock.Anything).Return(nil)
+	mockRoomService := new(MockRoomService)
+	mockRoomService.On("SendMessage", "room-1", "user-1", mock.Anything).Return(&model.Message{Model: gorm.Model{ID: 42}}, nil)
+	mockRoomService.On("IsMember", "room-1", "user-1").Return(true, nil)
+	mockRoomService.On("IsMember", "room-2", "user-1").Return(false, nil)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(
+		mockBroadcastService,
+		WithLogger(mockLogger),
+		WithRoomService(mockRoomService),
+		WithRequireMembership(true),
+		WithRateLimit(1, 2),
+	)
+	client := &model.Client{ID: "client-1", UserID: "user-1", UserName: "Alice", RoomID: "room-1"}
+	notices := func(noticeType string) []map[string]interface{} {
+		var result []map[string]interface{}
+		for _, call := range mockBroadcastService.Calls {
+			if call.Method != "SendPrivateMessage" {
+				continue
+			}
+			var notice map[string]interface{}
+			if json.Unmarshal(call.Arguments.Get(1).([]byte), &notice) == nil && notice["type"] == noticeType {
+				result = append(result, notice)
+			}
+		}
+		return result
+	}
+
+	// 動作 (Act)
+	handler.processTextMessage(client, []byte(`{"content":"沒有 ID 的訊息"}`))
+	handler.processTextMessage(client, []byte(`{"content":"第一則","clientMsgId":"c-1"}`))
+	handler.processTextMessage(client, []byte(`{"content":"太快了","clientMsgId":"c-2"}`))
+	now = now.Add(time.Second)
+	client.SetRoomID("room-2")
+	handler.processTextMessage(client, []byte(`{"content":"不是成員","clientMsgId":"c-3"}`))
+
+	// 斷言 (Assert)
+	acks := notices("ack")
+	if assert.Len(t, acks, 1, "只有提供 clientMsgId 且被接受的訊息應該收到 ack") {
+		assert.Equal(t, "c-1", acks[0]["clientMsgId"], "ack 應該帶回客戶端的訊息 ID")
+		assert.Equal(t, float64(42), acks[0]["serverId"], "ack 應該包含保存後的訊息 ID")
+		assert.NotNil(t, acks[0]["time"], "ack 應該包含時間")
+	}
+
+	nacks := notices("nack")
+	if assert.Len(t, nacks, 2, "被拒絕的訊息應該收到 nack") {
+		assert.Equal(t, "c-2", nacks[0]["clientMsgId"])
+		assert.Equal(t, nackRateLimited, nacks[0]["reason"], "超過速率限制的原因應該是 rate_limited")
+		assert.Equal(t, "c-3", nacks[1]["clientMsgId"])
+		assert.Equal(t, nackNotRoomMember, nacks[1]["reason"], "非成員的原因應該是 not_room_member")
+	}
+	mockRoomService.AssertNumberOfCalls(t, "SendMessage", 2)
+}
+
+// TestMessageModificationParity 測試 REST 與 WebSocket 的編輯與刪除訊息使用相同的權限規則
+//
+// 測試目標：
+// 1. 非作者透過兩條路徑編輯或刪除訊息都被拒絕，且錯誤訊息相同
+// 2. 被拒絕的操作不會改動訊息
+// 3. 啟用管理員刪除訊息後，聊天室創建者透過兩條路徑都可以刪除他人的訊息
+func TestMessageModificationParity(t *testing.T) {
+	// 安排 (Arrange)：使用真實的聊天室服務與記憶體資料庫
+	mockDB := repository.NewMockDBWithSchema()
+	roomRepo := repository.NewRoomRepository(mockDB)
+	roomService := service.NewRoomService(roomRepo, service.WithModeratorMessageDeletion(true))
+	room := &model.Room{ID: "room-1", Name: "測試聊天室", IsPublic: true, IsActive: true, IsListed: true, CreatedBy: "owner-1"}
+	assert.NoError(t, roomRepo.CreateRoom(room), "創建聊天室不應該失敗")
+
+	messages := make([]*model.Message, 2)
+	for i := range messages {
+		messages[i] = &model.Message{RoomID: room.ID, UserID: "author-1", Content: fmt.Sprintf("原始內容%d", i)}
+		assert.NoError(t, roomRepo.SaveMessage(messages[i]), "保存訊息不應該失敗")
+	}
+
+	mockBroadcastService := new(MockBroadcastService)
+	mockBroadcastService.On("SendPrivateMessage", mock.Anything, mock.Anything).Return(nil)
+	mockBroadcastService.On("BroadcastEventToRoom", room.ID, "", mock.Anything).Return(nil)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	wsHandler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithRoomService(roomService))
+	roomHandler := NewRoomHandler(roomService)
+
+	viaREST := func(userID string, method string, messageID uint, body string) (int, string) {
+		router := setupRoomRouterWithUser(userID)
+		roomHandler.RegisterRoutes(router)
+		req, _ := http.NewRequest(method, fmt.Sprintf("/api/rooms/%s/messages/%d", room.ID, messageID), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		errorText, _ := response["error"].(string)
+		return w.Code, errorText
+	}
+	viaWebSocket := func(userID string, command string) string {
+		client := &model.Client{ID: "client-" + userID, UserID: userID, UserName: userID, RoomID: room.ID}
+		before := len(mockBroadcastService.Calls)
+		wsHandler.processTextMessage(client, []byte(command))
+		for _, call := range mockBroadcastService.Calls[before:] {
+			if call.Method == "SendPrivateMessage" {
+				var payload map[string]interface{}
+				json.Unmarshal(call.Arguments.Get(1).([]byte), &payload)
+				errorText, _ := payload["content"].(string)
+				return errorText
+			}
+		}
+		return ""
+	}
+
+	// 動作 (Act)：非作者的一般成員嘗試編輯與刪除
+	editCode, restEditErr := viaREST("intruder", "PUT", messages[0].ID, `{"content":"竄改"}`)
+	deleteCode, restDeleteErr := viaREST("intruder", "DELETE", messages[0].ID, "")
+	wsEditErr := viaWebSocket("intruder", fmt.Sprintf(`{"type":"edit_message","messageId":%d,"content":"竄改"}`, messages[0].ID))
+	wsDeleteErr := viaWebSocket("intruder", fmt.Sprintf(`{"type":"delete_message","messageId":%d}`, messages[0].ID))
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusForbidden, editCode, "REST 非作者編輯應該返回 403")
+	assert.Equal(t, http.StatusForbidden, deleteCode, "REST 非作者刪除應該返回 403")
+	assert.Equal(t, service.ErrNotMessageAuthor.Error(), restEditErr, "REST 編輯應該返回權限錯誤")
+	assert.Equal(t, restEditErr, wsEditErr, "WebSocket 編輯應該返回與 REST 相同的錯誤")
+	assert.Equal(t, restDeleteErr, wsDeleteErr, "WebSocket 刪除應該返回與 REST 相同的錯誤")
+
+	stored, err := roomRepo.GetMessage(messages[0].ID)
+	assert.NoError(t, err, "被拒絕的刪除不應該移除訊息")
+	assert.Equal(t, "原始內容0", stored.Content, "被拒絕的編輯不應該改動訊息")
+
+	// 動作 & 斷言：聊天室創建者不能編輯，但可以透過兩條路徑刪除他人的訊息
+	ownerEditCode, _ := viaREST("owner-1", "PUT", messages[0].ID, `{"content":"竄改"}`)
+	ownerWSEditErr := viaWebSocket("owner-1", fmt.Sprintf(`{"type":"edit_message","messageId":%d,"content":"竄改"}`, messages[0].ID))
+	assert.Equal(t, http.StatusForbidden, ownerEditCode, "REST 創建者編輯他人訊息應該返回 403")
+	assert.Equal(t, service.ErrNotMessageAuthor.Error(), ownerWSEditErr, "WebSocket 創建者編輯他人訊息應該被拒絕")
+
+	ownerDeleteCode, _ := viaREST("owner-1", "DELETE", messages[0].ID, "")
+	ownerWSDeleteErr := viaWebSocket("owner-1", fmt.Sprintf(`{"type":"delete_message","messageId":%d}`, messages[1].ID))
+	assert.Equal(t, http.StatusOK, ownerDeleteCode, "REST 創建者應該可以刪除他人的訊息")
+	assert.Empty(t, ownerWSDeleteErr, "WebSocket 創建者應該可以刪除他人的訊息")
+	for _, message := range messages {
+		_, err := roomRepo.GetMessage(message.ID)
+		assert.ErrorIs(t, err, repository.ErrMessageNotFound, "被刪除的訊息不應該再被查詢到")
+	}
+}
+
+// TestReadLimit 測試可設定的單一訊息大小上限
+//
+// 測試目標：
+// 1. 上限內的訊息正常處理
+// 2. 送出超過上限的訊息時伺服器以 1009 (message too big) 關閉連接
+func TestReadLimit(t *testing.T) {
+	// 安排 (Arrange)
+	mockBroadcastService := new(MockBroadcastService)
+	mockBroadcastService.On("AddClient", mock.Anything).Return(nil)
+	mockBroadcastService.On("RemoveClient", mock.Anything).Return(nil)
+	mockBroadcastService.On("BroadcastMessage", mock.Anything).Return(nil)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+
+	handler := NewWebSocketHandler(mockBroadcastService, WithLogger(mockLogger), WithReadLimit(32))
+	router := setupRouter()
+	router.GET("/ws", func(c *gin.Context) {
+		handler.HandleConnection(c.Writer, c.Request)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if !assert.NoError(t, err, "應該能夠建立連接") {
+		return
+	}
+	defer conn.Close()
+
+	// 動作 (Act)
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("短訊息")))
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("x", 64))))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, readErr := conn.ReadMessage()
+
+	// 斷言 (Assert)
+	assert.True(t, websocket.IsCloseError(readErr, websocket.CloseMessageTooBig), "超過上限的訊息應該以 1009 關閉連接，實際為 %v", readErr)
+	mockBroadcastService.AssertCalled(t, "BroadcastMessage", []byte("短訊息"))
+	mockBroadcastService.AssertNotCalled(t, "BroadcastMessage", []byte(strings.Repeat("x", 64)))
+}
+
+// TestConnectionSettingsDefaults 測試連接參數的預設值，非正數的設定保留預設值
+func TestConnectionSettingsDefaults(t *testing.T) {
+	// 動作 (Act)
+	defaults := NewWebSocketHandler(new(MockBroadcastService))
+	ignored := NewWebSocketHandler(new(MockBroadcastService), WithReadLimit(0), WithReadTimeout(-time.Second), WithPingInterval(0))
+	configured := NewWebSocketHandler(new(MockBroadcastService), WithReadLimit(1<<20), WithReadTimeout(2*time.Minute), WithPingInterval(time.Minute))
+
+	// 斷言 (Assert)
+	assert.Equal(t, int64(4096), defaults.readLimit, "預設讀取上限應該是 4096")
+	assert.Equal(t, 60*time.Second, defaults.readTimeout, "預設讀取逾時應該是 60 秒")
+	assert.Equal(t, 30*time.Second, defaults.pingInterval, "預設 ping 間隔應該是 30 秒")
+	assert.Equal(t, defaults.readLimit, ignored.readLimit, "非正數的讀取上限應該保留預設值")
+	assert.Equal(t, defaults.readTimeout, ignored.readTimeout, "非正數的讀取逾時應該保留預設值")
+	assert.Equal(t, defaults.pingInterval, ignored.pingInterval, "非正數的 ping 間隔應該保留預設值")
+	assert.Equal(t, int64(1<<20), configured.readLimit, "讀取上限應該套用設定")
+	assert.Equal(t, 2*time.Minute, configured.readTimeout, "讀取逾時應該套用設定")
+	assert.Equal(t, time.Minute, configured.pingInterval, "ping 間隔應該套用設定")
 }
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics 保存即時聊天負載相關的 Prometheus 指標
+// 所有方法都可以在 nil 上呼叫，未啟用指標的服務不需要另外判斷
+type Metrics struct {
+	ActiveConnections prometheus.Gauge
+	MessagesBroadcast prometheus.Counter
+	RoomJoins         prometheus.Counter
+	BroadcastErrors   prometheus.Counter
+}
+
+// New 創建聊天指標並註冊到 registerer
+func New(registerer prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		ActiveConnections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "livechat_active_connections",
+			Help: "目前連接中的 WebSocket 客戶端數量",
+		}),
+		MessagesBroadcast: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "livechat_messages_broadcast_total",
+			Help: "已廣播的訊息總數",
+		}),
+		RoomJoins: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "livechat_room_joins_total",
+			Help: "客戶端加入聊天室的總次數",
+		}),
+		BroadcastErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "livechat_broadcast_errors_total",
+			Help: "寫入客戶端失敗的總次數",
+		}),
+	}
+
+	registerer.MustRegister(m.ActiveConnections, m.MessagesBroadcast, m.RoomJoins, m.BroadcastErrors)
+	return m
+}
+
+// ConnectionAdded 記錄新增一個連接
+func (m *Metrics) ConnectionAdded() {
+	if m != nil {
+		m.ActiveConnections.Inc()
+	}
+}
+
+// ConnectionRemoved 記錄移除一個連接
+func (m *Metrics) ConnectionRemoved() {
+	if m != nil {
+		m.ActiveConnections.Dec()
+	}
+}
+
+// MessageBroadcast 記錄廣播一則訊息
+func (m *Metrics) MessageBroadcast() {
+	if m != nil {
+		m.MessagesBroadcast.Inc()
+	}
+}
+
+// RoomJoined 記錄客戶端加入聊天室
+func (m *Metrics) RoomJoined() {
+	if m != nil {
+		m.RoomJoins.Inc()
+	}
+}
+
+// BroadcastFailed 記錄一次寫入客戶端失敗
+func (m *Metrics) BroadcastFailed() {
+	if m != nil {
+		m.BroadcastErrors.Inc()
+	}
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 測試指標註冊到 registry 並依事件更新
+func TestMetrics(t *testing.T) {
+	// 安排 (Arrange)
+	registry := prometheus.NewRegistry()
+	m := New(registry)
+
+	// 動作 (Act)
+	m.ConnectionAdded()
+	m.ConnectionAdded()
+	m.ConnectionRemoved()
+	m.RoomJoined()
+	m.BroadcastFailed()
+
+	// 斷言 (Assert)
+	count, err := testutil.GatherAndCount(registry)
+	assert.NoError(t, err, "應該能夠收集指標")
+	assert.Equal(t, 4, count, "應該註冊四個指標")
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.ActiveConnections), "活躍連接數應該反映新增與移除")
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.RoomJoins), "加入聊天室次數應該匹配")
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.BroadcastErrors), "廣播錯誤次數應該匹配")
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.MessagesBroadcast), "沒有廣播時訊息數應該為 0")
+}
+
+// 測試未啟用指標時呼叫方法不會 panic
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+
+	assert.NotPanics(t, func() {
+		m.ConnectionAdded()
+		m.ConnectionRemoved()
+		m.MessageBroadcast()
+		m.RoomJoined()
+		m.BroadcastFailed()
+	}, "nil 指標的方法應該什麼都不做")
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"errors"
+	"livechat/backend/service"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader 是攜帶 API 金鑰的請求標頭
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyMiddleware 創建一個驗證 X-API-Key 標頭的中間件
+// 金鑰有效時設置與 SessionMiddleware 相同的登入用戶；未提供金鑰時交由其他驗證方式處理，
+// 提供了不存在或已撤銷的金鑰時返回 401
+func APIKeyMiddleware(keyService *service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(APIKeyHeader))
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		user, err := keyService.Authenticate(key)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidAPIKey) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "驗證 API 金鑰失敗"})
+			}
+			c.Abort()
+			return
+		}
+
+		SetCurrentUser(c, &UserResponse{
+			ID:          user.ID,
+			Username:    user.Username,
+			DisplayName: user.DisplayName,
+			Email:       user.Email,
+			Role:        user.Role,
+		})
+
+		c.Next()
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"encoding/json"
+	"livechat/backend/model"
+	"livechat/backend/repository"
+	"livechat/backend/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// 測試 API 金鑰中間件
+//
+// 測試目標：
+// 1. 有效的金鑰設置綁定的用戶，角色以金鑰為準
+// 2. 已撤銷或不存在的金鑰返回 401
+// 3. 未提供金鑰時視為未登入，由後續的驗證處理
+func TestAPIKeyMiddleware(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := repository.NewMockDB()
+	userRepo := repository.NewUserRepository(mockDB)
+	user := &model.User{ID: "bot-1", Username: "bot", Email: "bot@example.com", Password: "Password1", Role: "user"}
+	assert.NoError(t, userRepo.CreateUser(user))
+
+	keyService := service.NewAPIKeyService(repository.NewAPIKeyRepository(mockDB), service.NewUserService(userRepo))
+	validKey, _, err := keyService.CreateKey("bot-1", "valid", "")
+	assert.NoError(t, err, "簽發金鑰不應該失敗")
+	revokedKey, revoked, err := keyService.CreateKey("bot-1", "revoked", "")
+	assert.NoError(t, err, "簽發金鑰不應該失敗")
+	assert.NoError(t, keyService.RevokeKey(revoked.ID), "撤銷金鑰不應該失敗")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(APIKeyMiddleware(keyService))
+	router.GET("/me", AuthRequired(), func(c *gin.Context) {
+		user, _ := c.Get("user")
+		c.JSON(http.StatusOK, user)
+	})
+
+	request := func(key string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/me", nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 動作 (Act)
+	valid := request(validKey)
+	revokedResponse := request(revokedKey)
+	unknown := request("lck_unknown")
+	missing := request("")
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, valid.Code, "有效的金鑰應該通過驗證")
+	var current UserResponse
+	assert.NoError(t, json.Unmarshal(valid.Body.Bytes(), &current), "應該能夠解析響應")
+	assert.Equal(t, UserResponse{ID: "bot-1", Username: "bot", Email: "bot@example.com", Role: "user"}, current, "上下文中的用戶應該是金鑰綁定的用戶")
+
+	assert.Equal(t, http.StatusUnauthorized, revokedResponse.Code, "已撤銷的金鑰應該返回 401")
+	assert.Equal(t, http.StatusUnauthorized, unknown.Code, "不存在的金鑰應該返回 401")
+	assert.Equal(t, http.StatusUnauthorized, missing.Code, "未提供金鑰時應該由 AuthRequired 拒絕")
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"livechat/backend/model"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisSessionKeyPrefix 是 Redis 中會話鍵的前綴
+const redisSessionKeyPrefix = "livechat:session:"
+
+// RedisSessionStore 將會話以 JSON 保存在 Redis 中，多個伺服器實例可以共用且重啟後不會遺失
+// 設有過期時間的會話在過期後也會從 Redis 中移除
+type RedisSessionStore struct {
+	client *redis.Client
+	ttl    time.Duration // 會話在 Redis 中的最長存活時間，0 表示不限制
+}
+
+// NewRedisSessionStore 創建一個新的 Redis 會話存儲
+func NewRedisSessionStore(client *redis.Client, ttl time.Duration) *RedisSessionStore {
+	return &RedisSessionStore{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+// Get 獲取會話，不存在或已從 Redis 中過期時返回 ErrSessionNotFound
+func (s *RedisSessionStore) Get(sessionID string) (*Session, error) {
+	data, err := s.client.Get(context.Background(), redisSessionKeyPrefix+sessionID).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	if session.User == nil {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+// Set 保存會話，密碼哈希不會寫入 Redis
+func (s *RedisSessionStore) Set(sessionID string, session *Session) error {
+	user := *session.User
+	user.Password = ""
+
+	data, err := json.Marshal(Session{User: &user, ExpiresAt: session.ExpiresAt})
+	if err != nil {
+		return err
+	}
+
+	return s.client.Set(context.Background(), redisSessionKeyPrefix+sessionID, data, s.keyTTL(session)).Err()
+}
+
+// keyTTL 返回會話鍵在 Redis 中的存活時間，取設置的上限與會話剩餘時間中較短者
+func (s *RedisSessionStore) keyTTL(session *Session) time.Duration {
+	ttl := s.ttl
+	if !session.ExpiresAt.IsZero() {
+		remaining := session.ExpiresAt.Sub(model.Now())
+		if remaining <= 0 {
+			remaining = time.Millisecond // 已過期的會話立即移除
+		}
+		if ttl == 0 || remaining < ttl {
+			ttl = remaining
+		}
+	}
+	return ttl
+}
+
+// Delete 移除會話，會話不存在時不返回錯誤
+func (s *RedisSessionStore) Delete(sessionID string) error {
+	return s.client.Del(context.Background(), redisSessionKeyPrefix+sessionID).Err()
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"livechat/backend/model"
 	"livechat/backend/service"
 	"net/http"
@@ -10,17 +11,19 @@ import (
 
 // UserResponse 是用戶的 API 響應格式
 type UserResponse struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Role     string `json:"role"`
+	ID          string `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"displayName,omitempty"`
+	Email       string `json:"email"`
+	Role        string `json:"role"`
 }
 
-// 全局session存儲 - 在實際應用中應該使用Redis或數據庫
-var sessionStore = make(map[string]*model.User)
+// userContextKey 是請求 context 中存放登入用戶的鍵
+type userContextKey struct{}
 
-// SessionMiddleware 創建一個會話中間件
-func SessionMiddleware(userService service.UserService) gin.HandlerFunc {
+// SessionMiddleware 創建一個會話中間件，從 store 中查詢 session_id cookie 對應的用戶
+// 會話的過期時間在創建時設置（見 UserHandler 的 WithSessionTTL）
+func SessionMiddleware(store SessionStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 從 cookie 中獲取會話 ID
 		sessionID, err := c.Cookie("session_id")
@@ -29,33 +32,45 @@ func SessionMiddleware(userService service.UserService) gin.HandlerFunc {
 			return
 		}
 
-		// 從會話存儲中獲取用戶
-		user, exists := sessionStore[sessionID]
-		if !exists {
+		// 從會話存儲中獲取用戶，存儲無法使用時視為未登入
+		session, err := store.Get(sessionID)
+		if err != nil {
 			c.Next()
 			return
 		}
 
+		// 過期的會話視為未登入並從存儲中移除
+		if session.Expired(model.Now()) {
+			store.Delete(sessionID)
+			c.Next()
+			return
+		}
+		user := session.User
+
 		// 將用戶信息設置到上下文中
-		c.Set("user", &UserResponse{
-			ID:       user.ID,
-			Username: user.Username,
-			Email:    user.Email,
-			Role:     user.Role,
+		SetCurrentUser(c, &UserResponse{
+			ID:          user.ID,
+			Username:    user.Username,
+			DisplayName: user.DisplayName,
+			Email:       user.Email,
+			Role:        user.Role,
 		})
 
 		c.Next()
 	}
 }
 
-// SetSession 設置用戶session
-func SetSession(sessionID string, user *model.User) {
-	sessionStore[sessionID] = user
+// SetCurrentUser 將登入用戶設置到 Gin 上下文與請求 context 中
+// 請求 context 讓只接收 *http.Request 的處理器（如 WebSocket）也能取得用戶
+func SetCurrentUser(c *gin.Context, user *UserResponse) {
+	c.Set("user", user)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), userContextKey{}, user))
 }
 
-// RemoveSession 移除用戶session
-func RemoveSession(sessionID string) {
-	delete(sessionStore, sessionID)
+// UserFromContext 從請求 context 中獲取登入用戶
+func UserFromContext(ctx context.Context) (*UserResponse, bool) {
+	user, ok := ctx.Value(userContextKey{}).(*UserResponse)
+	return user, ok
 }
 
 // AuthRequired 創建一個需要認證的中間件
@@ -106,3 +121,38 @@ func AdminRequired(userService service.UserService) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// VerifiedRequired 創建一個需要已驗證電子郵件的中間件
+func VerifiedRequired(userService service.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userValue, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "未登入"})
+			c.Abort()
+			return
+		}
+
+		userResponse, ok := userValue.(*UserResponse)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "用戶數據格式錯誤"})
+			c.Abort()
+			return
+		}
+
+		// 驗證狀態以資料庫為準，會話中的用戶資料可能在驗證前建立
+		user, err := userService.GetUserByID(userResponse.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取用戶信息失敗"})
+			c.Abort()
+			return
+		}
+
+		if !user.IsVerified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "需要先驗證電子郵件"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"errors"
+	"livechat/backend/model"
+	"sync"
+	"time"
+)
+
+// ErrSessionNotFound 表示會話不存在或已被移除
+var ErrSessionNotFound = errors.New("會話不存在")
+
+// DefaultSessionTTL 是未另外設置時登入會話的有效時間
+const DefaultSessionTTL = 24 * time.Hour
+
+// Session 是保存在會話存儲中的登入會話
+type Session struct {
+	User      *model.User `json:"user"`
+	ExpiresAt time.Time   `json:"expiresAt"` // 零值表示不過期
+}
+
+// NewSession 創建一個在 ttl 後過期的會話，ttl 為 0 表示不過期
+func NewSession(user *model.User, ttl time.Duration) *Session {
+	session := &Session{User: user}
+	if ttl > 0 {
+		session.ExpiresAt = model.Now().Add(ttl)
+	}
+	return session
+}
+
+// Expired 檢查會話在指定時間是否已過期
+func (s *Session) Expired(now time.Time) bool {
+	return !s.ExpiresAt.IsZero() && !now.Before(s.ExpiresAt)
+}
+
+// SessionStore 定義會話存儲的接口，以會話 ID 保存登入會話
+type SessionStore interface {
+	Get(sessionID string) (*Session, error)
+	Set(sessionID string, session *Session) error
+	Delete(sessionID string) error
+}
+
+// MemorySessionStore 是並發安全的記憶體會話存儲，伺服器重啟後會話會遺失
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+// NewMemorySessionStore 創建一個新的記憶體會話存儲
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]*Session)}
+}
+
+// Get 獲取會話，不存在時返回 ErrSessionNotFound；過期的會話仍會返回，由呼叫者判斷
+func (s *MemorySessionStore) Get(sessionID string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, exists := s.sessions[sessionID]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+// Set 保存會話
+func (s *MemorySessionStore) Set(sessionID string, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionID] = session
+	return nil
+}
+
+// Delete 移除會話，會話不存在時不返回錯誤
+func (s *MemorySessionStore) Delete(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+	return nil
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"livechat/backend/model"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRedisSessionStore 創建連接到記憶體 Redis 伺服器的會話存儲
+func newTestRedisSessionStore(t *testing.T, ttl time.Duration) (*RedisSessionStore, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisSessionStore(client, ttl), server
+}
+
+// 測試各種會話存儲都能設置、讀取與移除會話
+func TestSessionStores(t *testing.T) {
+	redisStore, _ := newTestRedisSessionStore(t, time.Hour)
+	stores := map[string]SessionStore{
+		"記憶體":   NewMemorySessionStore(),
+		"Redis": redisStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			// 安排 (Arrange)
+			user := &model.User{ID: "user-1", Username: "testuser", DisplayName: "測試", Email: "test@example.com", Role: "admin"}
+
+			// 動作 (Act)
+			expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+			_, missingErr := store.Get("session-1")
+			setErr := store.Set("session-1", &Session{User: user, ExpiresAt: expiresAt})
+			found, getErr := store.Get("session-1")
+			deleteErr := store.Delete("session-1")
+			_, deletedErr := store.Get("session-1")
+
+			// 斷言 (Assert)
+			assert.ErrorIs(t, missingErr, ErrSessionNotFound, "未設置的會話應該返回 ErrSessionNotFound")
+			assert.NoError(t, setErr, "設置會話不應該返回錯誤")
+			assert.NoError(t, getErr, "獲取會話不應該返回錯誤")
+			if assert.NotNil(t, found, "應該找到會話") {
+				assert.Equal(t, user.ID, found.User.ID, "用戶 ID 應該匹配")
+				assert.Equal(t, user.Username, found.User.Username, "用戶名應該匹配")
+				assert.Equal(t, user.DisplayName, found.User.DisplayName, "顯示名稱應該匹配")
+				assert.Equal(t, user.Role, found.User.Role, "角色應該匹配")
+				assert.True(t, expiresAt.Equal(found.ExpiresAt), "過期時間應該匹配")
+			}
+			assert.NoError(t, deleteErr, "移除會話不應該返回錯誤")
+			assert.ErrorIs(t, deletedErr, ErrSessionNotFound, "移除後的會話不應該存在")
+			assert.NoError(t, store.Delete("session-1"), "移除不存在的會話不應該返回錯誤")
+		})
+	}
+}
+
+// 測試 Redis 會話鍵在存儲上限或會話過期時間到達後移除，且不保存密碼哈希
+func TestRedisSessionStoreTTL(t *testing.T) {
+	// 安排 (Arrange)
+	store, server := newTestRedisSessionStore(t, time.Hour)
+	user := &model.User{ID: "user-1", Username: "testuser", Password: "hashed"}
+
+	// 動作 (Act)
+	assert.NoError(t, store.Set("capped", &Session{User: user}))
+	assert.NoError(t, store.Set("short", NewSession(user, time.Minute)))
+	stored, _ := server.Get(redisSessionKeyPrefix + "capped")
+	found, err := store.Get("capped")
+	cappedTTL := server.TTL(redisSessionKeyPrefix + "capped")
+	shortTTL := server.TTL(redisSessionKeyPrefix + "short")
+	server.FastForward(time.Minute + time.Second)
+	_, shortErr := store.Get("short")
+	_, cappedErr := store.Get("capped")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "過期前應該能獲取會話")
+	assert.Empty(t, found.User.Password, "會話中不應該包含密碼哈希")
+	assert.NotContains(t, stored, "hashed", "密碼哈希不應該寫入 Redis")
+	assert.Equal(t, "hashed", user.Password, "不應該修改傳入的用戶")
+	assert.Equal(t, time.Hour, cappedTTL, "不過期的會話應該使用存儲的存活時間上限")
+	assert.True(t, shortTTL <= time.Minute, "會話鍵不應該比會話本身存活更久")
+	assert.ErrorIs(t, shortErr, ErrSessionNotFound, "超過會話過期時間後應該從 Redis 移除")
+	assert.NoError(t, cappedErr, "未超過存活時間上限的會話應該仍然存在")
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"fmt"
+	"livechat/backend/model"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSessionStoreConcurrentAccess 測試會話存儲在並發存取下的安全性
+//
+// 測試目標：
+// 1. 並發設置、讀取與移除會話時不應該產生資料競爭（使用 -race 執行）
+// 2. 移除後的會話不應該再被找到
+func TestSessionStoreConcurrentAccess(t *testing.T) {
+	// 安排 (Arrange)
+	const workers = 50
+	store := NewMemorySessionStore()
+	session := &Session{User: &model.User{ID: "user-1", Username: "testuser"}}
+
+	// 動作 (Act)：每個 goroutine 對自己的會話反覆設置、讀取與移除
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sessionID := fmt.Sprintf("session-%d", i)
+			for j := 0; j < 100; j++ {
+				store.Set(sessionID, session)
+				if found, err := store.Get(sessionID); err == nil {
+					assert.Equal(t, session, found, "取得的會話應該匹配")
+				}
+				store.Delete(sessionID)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 斷言 (Assert)
+	for i := 0; i < workers; i++ {
+		_, err := store.Get(fmt.Sprintf("session-%d", i))
+		assert.ErrorIs(t, err, ErrSessionNotFound, "移除後的會話不應該存在")
+	}
+}
+
+// 測試會話在有效時間後失效並從存儲中移除
+//
+// 測試目標：
+// 1. 有效時間內會話中間件能識別用戶
+// 2. 過期後視為未登入，且會話從存儲中移除
+func TestSessionMiddlewareExpiry(t *testing.T) {
+	// 安排 (Arrange)
+	now := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time {
+		return now
+	})
+	defer model.ResetTimeNow()
+
+	store := NewMemorySessionStore()
+	store.Set("session-1", NewSession(&model.User{ID: "user-1", Username: "testuser"}, time.Minute))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(SessionMiddleware(store))
+	router.GET("/me", AuthRequired(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func() int {
+		req, _ := http.NewRequest("GET", "/me", nil)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: "session-1"})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 動作 (Act)
+	beforeExpiry := request()
+	now = now.Add(time.Minute)
+	afterExpiry := request()
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, beforeExpiry, "有效時間內會話應該被識別")
+	assert.Equal(t, http.StatusUnauthorized, afterExpiry, "過期後會話不應該再被識別")
+	_, err := store.Get("session-1")
+	assert.ErrorIs(t, err, ErrSessionNotFound, "過期的會話應該從存儲中移除")
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"livechat/backend/service"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TokenAuthMiddleware 創建一個驗證 Authorization: Bearer 存取令牌的中間件
+// 令牌有效時設置與 SessionMiddleware 相同的登入用戶；未提供令牌時交由其他驗證方式處理，
+// 提供了無效或過期的令牌時返回 401
+func TokenAuthMiddleware(tokenService *service.TokenService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		scheme, token, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			c.Next()
+			return
+		}
+
+		claims, err := tokenService.ValidateToken(strings.TrimSpace(token))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		SetCurrentUser(c, &UserResponse{
+			ID:       claims.Subject,
+			Username: claims.Username,
+			Role:     claims.Role,
+		})
+
+		c.Next()
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"encoding/json"
+	"livechat/backend/model"
+	"livechat/backend/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// 測試存取令牌中間件
+//
+// 測試目標：
+// 1. 有效的令牌設置登入用戶
+// 2. 過期或簽名被竄改的令牌返回 401
+// 3. 未提供令牌時視為未登入，由後續的驗證處理
+func TestTokenAuthMiddleware(t *testing.T) {
+	// 安排 (Arrange)
+	now := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time {
+		return now
+	})
+	defer model.ResetTimeNow()
+
+	tokenService := service.NewTokenService([]byte("secret"), time.Minute)
+	token, _ := tokenService.IssueToken(&model.User{ID: "user-1", Username: "testuser", Role: "admin"})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(TokenAuthMiddleware(tokenService))
+	router.GET("/me", AuthRequired(), func(c *gin.Context) {
+		user, _ := c.Get("user")
+		c.JSON(http.StatusOK, user)
+	})
+
+	request := func(authorization string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/me", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 動作 (Act)
+	valid := request("Bearer " + token)
+	tampered := request("Bearer " + tamperSignature(token))
+	missing := request("")
+	now = now.Add(2 * time.Minute)
+	expired := request("Bearer " + token)
+
+	// 斷言 (Assert)
+	assert.Equal(t, http.StatusOK, valid.Code, "有效的令牌應該通過驗證")
+	var user UserResponse
+	assert.NoError(t, json.Unmarshal(valid.Body.Bytes(), &user), "應該能夠解析響應")
+	assert.Equal(t, UserResponse{ID: "user-1", Username: "testuser", Role: "admin"}, user, "上下文中的用戶應該來自令牌")
+
+	assert.Equal(t, http.StatusUnauthorized, tampered.Code, "簽名被竄改的令牌應該返回 401")
+	assert.Equal(t, http.StatusUnauthorized, expired.Code, "過期的令牌應該返回 401")
+	assert.Equal(t, http.StatusUnauthorized, missing.Code, "未提供令牌時應該由 AuthRequired 拒絕")
+}
+
+// tamperSignature 修改 JWT 簽名的第一個字元
+func tamperSignature(token string) string {
+	signatureStart := strings.LastIndex(token, ".") + 1
+	replacement := "A"
+	if token[signatureStart] == 'A' {
+		replacement = "B"
+	}
+	return token[:signatureStart] + replacement + token[signatureStart+1:]
+}
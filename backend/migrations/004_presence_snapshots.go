@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration004PresenceSnapshots 添加在線人數快照表
+type Migration004PresenceSnapshots struct{}
+
+// ID 返回遷移 ID
+func (m Migration004PresenceSnapshots) ID() string {
+	return "004_presence_snapshots"
+}
+
+// Up 執行遷移
+func (m Migration004PresenceSnapshots) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 004_presence_snapshots")
+
+	// 創建 presence_snapshots 表
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS presence_snapshots (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP,
+			updated_at TIMESTAMP,
+			deleted_at TIMESTAMP,
+			room_id VARCHAR(255),
+			active_connections INTEGER DEFAULT 0,
+			captured_at TIMESTAMP
+		)
+	`).Error; err != nil {
+		return fmt.Errorf("failed to create presence_snapshots table: %w", err)
+	}
+
+	// 創建索引
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_presence_snapshots_room_id ON presence_snapshots(room_id)").Error; err != nil {
+		return fmt.Errorf("failed to create index on presence_snapshots.room_id: %w", err)
+	}
+
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_presence_snapshots_captured_at ON presence_snapshots(captured_at)").Error; err != nil {
+		return fmt.Errorf("failed to create index on presence_snapshots.captured_at: %w", err)
+	}
+
+	fmt.Println("Migration 004_presence_snapshots completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration004PresenceSnapshots) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 004_presence_snapshots")
+
+	if err := db.Exec("DROP TABLE IF EXISTS presence_snapshots").Error; err != nil {
+		return fmt.Errorf("failed to drop presence_snapshots table: %w", err)
+	}
+
+	fmt.Println("Rollback of 004_presence_snapshots completed successfully")
+	return nil
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration005RoomIsListed 添加聊天室是否列出欄位
+type Migration005RoomIsListed struct{}
+
+// ID 返回遷移 ID
+func (m Migration005RoomIsListed) ID() string {
+	return "005_room_is_listed"
+}
+
+// Up 執行遷移
+func (m Migration005RoomIsListed) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 005_room_is_listed")
+
+	if err := db.Exec("ALTER TABLE rooms ADD COLUMN IF NOT EXISTS is_listed BOOLEAN DEFAULT true").Error; err != nil {
+		return fmt.Errorf("failed to add is_listed column to rooms: %w", err)
+	}
+
+	fmt.Println("Migration 005_room_is_listed completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration005RoomIsListed) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 005_room_is_listed")
+
+	if err := db.Exec("ALTER TABLE rooms DROP COLUMN IF EXISTS is_listed").Error; err != nil {
+		return fmt.Errorf("failed to drop is_listed column from rooms: %w", err)
+	}
+
+	fmt.Println("Rollback of 005_room_is_listed completed successfully")
+	return nil
+}
@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration006RoomInvites 添加聊天室邀請表
+type Migration006RoomInvites struct{}
+
+// ID 返回遷移 ID
+func (m Migration006RoomInvites) ID() string {
+	return "006_room_invites"
+}
+
+// Up 執行遷移
+func (m Migration006RoomInvites) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 006_room_invites")
+
+	// 創建 room_invites 表
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS room_invites (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP,
+			updated_at TIMESTAMP,
+			deleted_at TIMESTAMP,
+			token VARCHAR(255) NOT NULL,
+			room_id VARCHAR(255),
+			created_by VARCHAR(255),
+			expires_at TIMESTAMP,
+			max_uses INTEGER DEFAULT 0,
+			uses INTEGER DEFAULT 0,
+			CONSTRAINT room_invites_token_unique UNIQUE (token)
+		)
+	`).Error; err != nil {
+		return fmt.Errorf("failed to create room_invites table: %w", err)
+	}
+
+	// 創建索引
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_room_invites_room_id ON room_invites(room_id)").Error; err != nil {
+		return fmt.Errorf("failed to create index on room_invites.room_id: %w", err)
+	}
+
+	fmt.Println("Migration 006_room_invites completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration006RoomInvites) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 006_room_invites")
+
+	if err := db.Exec("DROP TABLE IF EXISTS room_invites").Error; err != nil {
+		return fmt.Errorf("failed to drop room_invites table: %w", err)
+	}
+
+	fmt.Println("Rollback of 006_room_invites completed successfully")
+	return nil
+}
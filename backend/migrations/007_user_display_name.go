@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration007UserDisplayName 添加用戶顯示名稱欄位
+type Migration007UserDisplayName struct{}
+
+// ID 返回遷移 ID
+func (m Migration007UserDisplayName) ID() string {
+	return "007_user_display_name"
+}
+
+// Up 執行遷移
+func (m Migration007UserDisplayName) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 007_user_display_name")
+
+	if err := db.Exec("ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(50)").Error; err != nil {
+		return fmt.Errorf("failed to add display_name column to users: %w", err)
+	}
+
+	fmt.Println("Migration 007_user_display_name completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration007UserDisplayName) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 007_user_display_name")
+
+	if err := db.Exec("ALTER TABLE users DROP COLUMN IF EXISTS display_name").Error; err != nil {
+		return fmt.Errorf("failed to drop display_name column from users: %w", err)
+	}
+
+	fmt.Println("Rollback of 007_user_display_name completed successfully")
+	return nil
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration008MessageCompressed 添加訊息是否壓縮欄位
+type Migration008MessageCompressed struct{}
+
+// ID 返回遷移 ID
+func (m Migration008MessageCompressed) ID() string {
+	return "008_message_compressed"
+}
+
+// Up 執行遷移
+func (m Migration008MessageCompressed) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 008_message_compressed")
+
+	if err := db.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS compressed BOOLEAN DEFAULT false").Error; err != nil {
+		return fmt.Errorf("failed to add compressed column to messages: %w", err)
+	}
+
+	fmt.Println("Migration 008_message_compressed completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration008MessageCompressed) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 008_message_compressed")
+
+	if err := db.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS compressed").Error; err != nil {
+		return fmt.Errorf("failed to drop compressed column from messages: %w", err)
+	}
+
+	fmt.Println("Rollback of 008_message_compressed completed successfully")
+	return nil
+}
@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"fmt"
+	"livechat/backend/model"
+
+	"gorm.io/gorm"
+)
+
+// Migration009SystemUser 建立作為系統訊息發送者的保留用戶，並回填既有的系統訊息
+type Migration009SystemUser struct{}
+
+// ID 返回遷移 ID
+func (m Migration009SystemUser) ID() string {
+	return "009_system_user"
+}
+
+// Up 執行遷移
+func (m Migration009SystemUser) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 009_system_user")
+
+	// 密碼雜湊使用無效值，系統用戶無法登入
+	if err := db.Exec(`
+		INSERT INTO users (id, created_at, updated_at, username, display_name, email, password_hash, role, is_verified)
+		VALUES (?, NOW(), NOW(), ?, '系統', 'system@livechat.invalid', '!', 'system', TRUE)
+		ON CONFLICT (id) DO NOTHING
+	`, model.SystemUserID, model.SystemUsername).Error; err != nil {
+		return fmt.Errorf("failed to create system user: %w", err)
+	}
+
+	if err := db.Exec(
+		"UPDATE messages SET user_id = ? WHERE is_system_message = TRUE AND (user_id IS NULL OR user_id = '')",
+		model.SystemUserID,
+	).Error; err != nil {
+		return fmt.Errorf("failed to backfill system message authors: %w", err)
+	}
+
+	fmt.Println("Migration 009_system_user completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration009SystemUser) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 009_system_user")
+
+	if err := db.Exec("UPDATE messages SET user_id = '' WHERE user_id = ?", model.SystemUserID).Error; err != nil {
+		return fmt.Errorf("failed to clear system message authors: %w", err)
+	}
+
+	if err := db.Exec("DELETE FROM users WHERE id = ?", model.SystemUserID).Error; err != nil {
+		return fmt.Errorf("failed to delete system user: %w", err)
+	}
+
+	fmt.Println("Rollback of 009_system_user completed successfully")
+	return nil
+}
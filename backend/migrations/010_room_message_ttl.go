@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration010RoomMessageTTL 添加聊天室訊息存活秒數欄位
+type Migration010RoomMessageTTL struct{}
+
+// ID 返回遷移 ID
+func (m Migration010RoomMessageTTL) ID() string {
+	return "010_room_message_ttl"
+}
+
+// Up 執行遷移
+func (m Migration010RoomMessageTTL) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 010_room_message_ttl")
+
+	if err := db.Exec("ALTER TABLE rooms ADD COLUMN IF NOT EXISTS message_ttl_seconds INTEGER DEFAULT 0").Error; err != nil {
+		return fmt.Errorf("failed to add message_ttl_seconds column to rooms: %w", err)
+	}
+
+	// 過期訊息的查詢與清除都依 created_at 篩選
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_messages_room_id_created_at ON messages(room_id, created_at)").Error; err != nil {
+		return fmt.Errorf("failed to create messages room_id/created_at index: %w", err)
+	}
+
+	fmt.Println("Migration 010_room_message_ttl completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration010RoomMessageTTL) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 010_room_message_ttl")
+
+	if err := db.Exec("DROP INDEX IF EXISTS idx_messages_room_id_created_at").Error; err != nil {
+		return fmt.Errorf("failed to drop messages room_id/created_at index: %w", err)
+	}
+
+	if err := db.Exec("ALTER TABLE rooms DROP COLUMN IF EXISTS message_ttl_seconds").Error; err != nil {
+		return fmt.Errorf("failed to drop message_ttl_seconds column from rooms: %w", err)
+	}
+
+	fmt.Println("Rollback of 010_room_message_ttl completed successfully")
+	return nil
+}
@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration011RoomBans 添加聊天室封禁記錄表
+type Migration011RoomBans struct{}
+
+// ID 返回遷移 ID
+func (m Migration011RoomBans) ID() string {
+	return "011_room_bans"
+}
+
+// Up 執行遷移
+func (m Migration011RoomBans) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 011_room_bans")
+
+	// 創建 room_bans 表
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS room_bans (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP,
+			updated_at TIMESTAMP,
+			deleted_at TIMESTAMP,
+			room_id VARCHAR(255),
+			user_id VARCHAR(255),
+			banned_by VARCHAR(255)
+		)
+	`).Error; err != nil {
+		return fmt.Errorf("failed to create room_bans table: %w", err)
+	}
+
+	// 創建索引
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_room_bans_room_id_user_id ON room_bans(room_id, user_id)").Error; err != nil {
+		return fmt.Errorf("failed to create index on room_bans: %w", err)
+	}
+
+	fmt.Println("Migration 011_room_bans completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration011RoomBans) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 011_room_bans")
+
+	if err := db.Exec("DROP TABLE IF EXISTS room_bans").Error; err != nil {
+		return fmt.Errorf("failed to drop room_bans table: %w", err)
+	}
+
+	fmt.Println("Rollback of 011_room_bans completed successfully")
+	return nil
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration012MessageEditedAt 添加訊息最後編輯時間欄位
+type Migration012MessageEditedAt struct{}
+
+// ID 返回遷移 ID
+func (m Migration012MessageEditedAt) ID() string {
+	return "012_message_edited_at"
+}
+
+// Up 執行遷移
+func (m Migration012MessageEditedAt) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 012_message_edited_at")
+
+	if err := db.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP").Error; err != nil {
+		return fmt.Errorf("failed to add edited_at column to messages: %w", err)
+	}
+
+	fmt.Println("Migration 012_message_edited_at completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration012MessageEditedAt) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 012_message_edited_at")
+
+	if err := db.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS edited_at").Error; err != nil {
+		return fmt.Errorf("failed to drop edited_at column from messages: %w", err)
+	}
+
+	fmt.Println("Rollback of 012_message_edited_at completed successfully")
+	return nil
+}
@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration013MessageReactions 添加訊息表情回應表
+type Migration013MessageReactions struct{}
+
+// ID 返回遷移 ID
+func (m Migration013MessageReactions) ID() string {
+	return "013_message_reactions"
+}
+
+// Up 執行遷移
+func (m Migration013MessageReactions) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 013_message_reactions")
+
+	// 創建 message_reactions 表
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS message_reactions (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP,
+			updated_at TIMESTAMP,
+			deleted_at TIMESTAMP,
+			message_id INTEGER,
+			user_id VARCHAR(255),
+			emoji VARCHAR(64)
+		)
+	`).Error; err != nil {
+		return fmt.Errorf("failed to create message_reactions table: %w", err)
+	}
+
+	// 創建索引
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_message_reactions_message_user ON message_reactions(message_id, user_id)").Error; err != nil {
+		return fmt.Errorf("failed to create index on message_reactions: %w", err)
+	}
+
+	fmt.Println("Migration 013_message_reactions completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration013MessageReactions) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 013_message_reactions")
+
+	if err := db.Exec("DROP TABLE IF EXISTS message_reactions").Error; err != nil {
+		return fmt.Errorf("failed to drop message_reactions table: %w", err)
+	}
+
+	fmt.Println("Rollback of 013_message_reactions completed successfully")
+	return nil
+}
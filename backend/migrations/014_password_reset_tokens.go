@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration014PasswordResetTokens 添加密碼重設令牌表
+type Migration014PasswordResetTokens struct{}
+
+// ID 返回遷移 ID
+func (m Migration014PasswordResetTokens) ID() string {
+	return "014_password_reset_tokens"
+}
+
+// Up 執行遷移
+func (m Migration014PasswordResetTokens) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 014_password_reset_tokens")
+
+	// 創建 password_reset_tokens 表
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS password_reset_tokens (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP,
+			updated_at TIMESTAMP,
+			deleted_at TIMESTAMP,
+			token VARCHAR(255) NOT NULL UNIQUE,
+			user_id VARCHAR(255),
+			expires_at TIMESTAMP,
+			used_at TIMESTAMP
+		)
+	`).Error; err != nil {
+		return fmt.Errorf("failed to create password_reset_tokens table: %w", err)
+	}
+
+	// 創建索引
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id)").Error; err != nil {
+		return fmt.Errorf("failed to create index on password_reset_tokens: %w", err)
+	}
+
+	fmt.Println("Migration 014_password_reset_tokens completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration014PasswordResetTokens) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 014_password_reset_tokens")
+
+	if err := db.Exec("DROP TABLE IF EXISTS password_reset_tokens").Error; err != nil {
+		return fmt.Errorf("failed to drop password_reset_tokens table: %w", err)
+	}
+
+	fmt.Println("Rollback of 014_password_reset_tokens completed successfully")
+	return nil
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration015EmailVerificationTokens 添加電子郵件驗證令牌表
+type Migration015EmailVerificationTokens struct{}
+
+// ID 返回遷移 ID
+func (m Migration015EmailVerificationTokens) ID() string {
+	return "015_email_verification_tokens"
+}
+
+// Up 執行遷移
+func (m Migration015EmailVerificationTokens) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 015_email_verification_tokens")
+
+	// 創建 email_verification_tokens 表
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS email_verification_tokens (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP,
+			updated_at TIMESTAMP,
+			deleted_at TIMESTAMP,
+			token VARCHAR(255) NOT NULL UNIQUE,
+			user_id VARCHAR(255)
+		)
+	`).Error; err != nil {
+		return fmt.Errorf("failed to create email_verification_tokens table: %w", err)
+	}
+
+	// 創建索引
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_user_id ON email_verification_tokens(user_id)").Error; err != nil {
+		return fmt.Errorf("failed to create index on email_verification_tokens: %w", err)
+	}
+
+	fmt.Println("Migration 015_email_verification_tokens completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration015EmailVerificationTokens) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 015_email_verification_tokens")
+
+	if err := db.Exec("DROP TABLE IF EXISTS email_verification_tokens").Error; err != nil {
+		return fmt.Errorf("failed to drop email_verification_tokens table: %w", err)
+	}
+
+	fmt.Println("Rollback of 015_email_verification_tokens completed successfully")
+	return nil
+}
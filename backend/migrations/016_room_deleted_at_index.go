@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration016RoomDeletedAtIndex 為聊天室的軟刪除欄位建立索引
+// 所有聊天室查詢都會加上 deleted_at IS NULL 條件，初始結構只建立了欄位而沒有模型宣告的索引
+type Migration016RoomDeletedAtIndex struct{}
+
+// ID 返回遷移 ID
+func (m Migration016RoomDeletedAtIndex) ID() string {
+	return "016_room_deleted_at_index"
+}
+
+// Up 執行遷移
+func (m Migration016RoomDeletedAtIndex) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 016_room_deleted_at_index")
+
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_rooms_deleted_at ON rooms(deleted_at)").Error; err != nil {
+		return fmt.Errorf("failed to create deleted_at index on rooms: %w", err)
+	}
+
+	fmt.Println("Migration 016_room_deleted_at_index completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration016RoomDeletedAtIndex) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 016_room_deleted_at_index")
+
+	if err := db.Exec("DROP INDEX IF EXISTS idx_rooms_deleted_at").Error; err != nil {
+		return fmt.Errorf("failed to drop deleted_at index on rooms: %w", err)
+	}
+
+	fmt.Println("Rollback of 016_room_deleted_at_index completed successfully")
+	return nil
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration017APIKeys 添加 API 金鑰表
+type Migration017APIKeys struct{}
+
+// ID 返回遷移 ID
+func (m Migration017APIKeys) ID() string {
+	return "017_api_keys"
+}
+
+// Up 執行遷移
+func (m Migration017APIKeys) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 017_api_keys")
+
+	// 創建 api_keys 表
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP,
+			updated_at TIMESTAMP,
+			deleted_at TIMESTAMP,
+			name VARCHAR(255),
+			key_hash VARCHAR(64) NOT NULL UNIQUE,
+			user_id VARCHAR(255),
+			role VARCHAR(20),
+			revoked_at TIMESTAMP
+		)
+	`).Error; err != nil {
+		return fmt.Errorf("failed to create api_keys table: %w", err)
+	}
+
+	// 創建索引
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id)").Error; err != nil {
+		return fmt.Errorf("failed to create index on api_keys: %w", err)
+	}
+
+	fmt.Println("Migration 017_api_keys completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration017APIKeys) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 017_api_keys")
+
+	if err := db.Exec("DROP TABLE IF EXISTS api_keys").Error; err != nil {
+		return fmt.Errorf("failed to drop api_keys table: %w", err)
+	}
+
+	fmt.Println("Rollback of 017_api_keys completed successfully")
+	return nil
+}
@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration018DirectMessages 添加用戶之間的私人訊息表
+type Migration018DirectMessages struct{}
+
+// ID 返回遷移 ID
+func (m Migration018DirectMessages) ID() string {
+	return "018_direct_messages"
+}
+
+// Up 執行遷移
+func (m Migration018DirectMessages) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 018_direct_messages")
+
+	// 創建 direct_messages 表
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS direct_messages (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP,
+			updated_at TIMESTAMP,
+			deleted_at TIMESTAMP,
+			sender_id VARCHAR(255) NOT NULL,
+			recipient_id VARCHAR(255) NOT NULL,
+			content TEXT NOT NULL
+		)
+	`).Error; err != nil {
+		return fmt.Errorf("failed to create direct_messages table: %w", err)
+	}
+
+	// 創建索引
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_direct_messages_sender_id ON direct_messages(sender_id)").Error; err != nil {
+		return fmt.Errorf("failed to create index on direct_messages: %w", err)
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_direct_messages_recipient_id ON direct_messages(recipient_id)").Error; err != nil {
+		return fmt.Errorf("failed to create index on direct_messages: %w", err)
+	}
+
+	fmt.Println("Migration 018_direct_messages completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration018DirectMessages) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 018_direct_messages")
+
+	if err := db.Exec("DROP TABLE IF EXISTS direct_messages").Error; err != nil {
+		return fmt.Errorf("failed to drop direct_messages table: %w", err)
+	}
+
+	fmt.Println("Rollback of 018_direct_messages completed successfully")
+	return nil
+}
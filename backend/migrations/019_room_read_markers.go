@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration019RoomReadMarkers 添加記錄用戶在聊天室中已讀位置的表
+type Migration019RoomReadMarkers struct{}
+
+// ID 返回遷移 ID
+func (m Migration019RoomReadMarkers) ID() string {
+	return "019_room_read_markers"
+}
+
+// Up 執行遷移
+func (m Migration019RoomReadMarkers) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 019_room_read_markers")
+
+	// 創建 room_read_markers 表
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS room_read_markers (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP,
+			updated_at TIMESTAMP,
+			deleted_at TIMESTAMP,
+			user_id VARCHAR(255) NOT NULL,
+			room_id VARCHAR(255) NOT NULL,
+			last_read_message_id INTEGER NOT NULL DEFAULT 0,
+			last_read_at TIMESTAMP
+		)
+	`).Error; err != nil {
+		return fmt.Errorf("failed to create room_read_markers table: %w", err)
+	}
+
+	// 創建唯一索引，每個用戶在每個聊天室只有一筆已讀記錄，標記已讀時以此 upsert
+	if err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_room_read_markers_user_room ON room_read_markers(user_id, room_id)").Error; err != nil {
+		return fmt.Errorf("failed to create index on room_read_markers: %w", err)
+	}
+
+	fmt.Println("Migration 019_room_read_markers completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration019RoomReadMarkers) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 019_room_read_markers")
+
+	if err := db.Exec("DROP TABLE IF EXISTS room_read_markers").Error; err != nil {
+		return fmt.Errorf("failed to drop room_read_markers table: %w", err)
+	}
+
+	fmt.Println("Rollback of 019_room_read_markers completed successfully")
+	return nil
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migration020RoomUserKickedAt 添加成員被移出聊天室的時間欄位
+type Migration020RoomUserKickedAt struct{}
+
+// ID 返回遷移 ID
+func (m Migration020RoomUserKickedAt) ID() string {
+	return "020_room_user_kicked_at"
+}
+
+// Up 執行遷移
+func (m Migration020RoomUserKickedAt) Up(db *gorm.DB) error {
+	fmt.Println("Running migration: 020_room_user_kicked_at")
+
+	if err := db.Exec("ALTER TABLE room_users ADD COLUMN IF NOT EXISTS kicked_at TIMESTAMP").Error; err != nil {
+		return fmt.Errorf("failed to add kicked_at column to room_users: %w", err)
+	}
+
+	fmt.Println("Migration 020_room_user_kicked_at completed successfully")
+	return nil
+}
+
+// Down 回滾遷移
+func (m Migration020RoomUserKickedAt) Down(db *gorm.DB) error {
+	fmt.Println("Rolling back migration: 020_room_user_kicked_at")
+
+	if err := db.Exec("ALTER TABLE room_users DROP COLUMN IF EXISTS kicked_at").Error; err != nil {
+		return fmt.Errorf("failed to drop kicked_at column from room_users: %w", err)
+	}
+
+	fmt.Println("Rollback of 020_room_user_kicked_at completed successfully")
+	return nil
+}
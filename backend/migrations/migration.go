@@ -41,6 +41,23 @@ func NewMigrator(db *gorm.DB) *Migrator {
 			Migration001InitialSchema{},
 			Migration002UserSchema{},
 			Migration003RenamePasswordColumn{},
+			Migration004PresenceSnapshots{},
+			Migration005RoomIsListed{},
+			Migration006RoomInvites{},
+			Migration007UserDisplayName{},
+			Migration008MessageCompressed{},
+			Migration009SystemUser{},
+			Migration010RoomMessageTTL{},
+			Migration011RoomBans{},
+			Migration012MessageEditedAt{},
+			Migration013MessageReactions{},
+			Migration014PasswordResetTokens{},
+			Migration015EmailVerificationTokens{},
+			Migration016RoomDeletedAtIndex{},
+			Migration017APIKeys{},
+			Migration018DirectMessages{},
+			Migration019RoomReadMarkers{},
+			Migration020RoomUserKickedAt{},
 		},
 	}
 }
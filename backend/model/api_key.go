@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKey 代表供外部服務（例如機器人）使用的 API 金鑰，以綁定用戶的身分存取 API
+// 金鑰本身只在創建時返回一次，資料庫只保存其 SHA-256 雜湊
+type APIKey struct {
+	gorm.Model
+	Name      string     `gorm:"size:255"`
+	KeyHash   string     `gorm:"size:64;not null;uniqueIndex"`
+	UserID    string     `gorm:"size:255;index"`
+	Role      string     `gorm:"size:20"`
+	RevokedAt *time.Time // 撤銷時間，nil 表示仍然有效
+}
+
+// TableName 指定 APIKey 模型的表名
+func (APIKey) TableName() string {
+	return "api_keys"
+}
@@ -0,0 +1,16 @@
+package model
+
+import "gorm.io/gorm"
+
+// RoomBan 代表用戶被禁止進入聊天室的記錄
+type RoomBan struct {
+	gorm.Model
+	RoomID   string `gorm:"size:255;index"`
+	UserID   string `gorm:"size:255;index"`
+	BannedBy string `gorm:"size:255"`
+}
+
+// TableName 指定 RoomBan 模型的表名
+func (RoomBan) TableName() string {
+	return "room_bans"
+}
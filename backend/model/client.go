@@ -3,6 +3,7 @@ package model
 import (
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -18,15 +19,20 @@ var (
 // 1. writeMu 保護 WebSocket 寫入操作，防止並發寫入錯誤
 // 2. 提供 SafeWriteMessage 方法確保線程安全的訊息發送
 // 3. 所有 WebSocket 寫入操作都應通過 SafeWriteMessage 進行
+// 4. stateMu 保護 IsActive 與 LastActive，並發環境下應透過 Active/Deactivate 與 UpdateActivity/LastActiveAt 存取
 type Client struct {
 	ID         string          // 客戶端唯一識別碼
 	Conn       *websocket.Conn // WebSocket 連接
 	UserName   string          // 使用者名稱，可選
+	UserID     string          // 登入用戶 ID，匿名連接為空
+	GuestID    string          // 匿名訪客的持久 ID，未啟用訪客 cookie 時為空
 	RoomID     string          // 當前所在聊天室 ID
+	Compressed bool            // 是否與客戶端協商啟用了 permessage-deflate 壓縮
 	IsActive   bool            // 客戶端是否活躍
 	JoinedAt   int64           // 加入時間戳
-	LastActive int64           // 最後活躍時間戳
+	LastActive int64           // 最後活躍時間戳，只在收到客戶端的訊息或 pong 時更新，用於回收閒置連接
 	writeMu    sync.Mutex      // WebSocket 寫入操作保護鎖
+	stateMu    sync.RWMutex    // 活躍狀態保護鎖
 }
 
 // NewClient 創建一個新的客戶端
@@ -47,6 +53,21 @@ func (c *Client) SetUserName(name string) {
 	c.UserName = name
 }
 
+// SetUserID 設置客戶端的登入用戶 ID
+func (c *Client) SetUserID(userID string) {
+	c.UserID = userID
+}
+
+// SetGuestID 設置匿名訪客的持久 ID
+func (c *Client) SetGuestID(guestID string) {
+	c.GuestID = guestID
+}
+
+// SetCompressed 設置連接是否協商啟用了壓縮
+func (c *Client) SetCompressed(compressed bool) {
+	c.Compressed = compressed
+}
+
 // SetRoomID 設置客戶端的聊天室 ID
 func (c *Client) SetRoomID(roomID string) {
 	c.RoomID = roomID
@@ -54,11 +75,39 @@ func (c *Client) SetRoomID(roomID string) {
 
 // UpdateActivity 更新客戶端的活躍狀態
 func (c *Client) UpdateActivity() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
 	c.LastActive = getCurrentTimestamp()
 }
 
+// LastActiveAt 返回客戶端的最後活躍時間戳
+func (c *Client) LastActiveAt() int64 {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	return c.LastActive
+}
+
+// JoinedAtTime 以 time.Time 返回客戶端的加入時間，與 RoomUser 等模型的時間欄位一致
+func (c *Client) JoinedAtTime() time.Time {
+	return time.Unix(c.JoinedAt, 0)
+}
+
+// LastActiveTime 以 time.Time 返回客戶端的最後活躍時間
+func (c *Client) LastActiveTime() time.Time {
+	return time.Unix(c.LastActiveAt(), 0)
+}
+
+// Active 返回客戶端是否活躍
+func (c *Client) Active() bool {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	return c.IsActive
+}
+
 // Deactivate 將客戶端標記為非活躍
 func (c *Client) Deactivate() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
 	c.IsActive = false
 }
 
@@ -76,24 +125,51 @@ func (c *Client) Deactivate() {
 // 回傳：
 // - error: 寫入錯誤，如果成功則為 nil
 func (c *Client) SafeWriteMessage(messageType int, data []byte) error {
-	// 檢查客戶端是否仍然活躍
-	if !c.IsActive {
-		return ErrClientInactive
-	}
-
 	// 使用鎖保護寫入操作
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
 
+	// 在取得寫入鎖後、實際寫入前再次檢查客戶端是否仍然活躍，
+	// 避免寫入到在等待鎖期間被其他 goroutine 停用的客戶端
+	if !c.Active() {
+		return ErrClientInactive
+	}
+
 	// 執行實際的寫入操作
 	err := c.Conn.WriteMessage(messageType, data)
 	if err != nil {
 		// 寫入失敗時自動停用客戶端
-		c.IsActive = false
+		c.Deactivate()
 		return err
 	}
 
-	// 寫入成功時更新活躍時間
-	c.UpdateActivity()
 	return nil
 }
+
+// SafeWritePing 線程安全的 ping 控制訊息寫入方法，與 SafeWriteMessage 共用寫入鎖
+func (c *Client) SafeWritePing(deadline time.Time) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if !c.Active() {
+		return ErrClientInactive
+	}
+
+	if err := c.Conn.WriteControl(websocket.PingMessage, []byte{}, deadline); err != nil {
+		c.Deactivate()
+		return err
+	}
+	return nil
+}
+
+// SafeWriteClose 線程安全的關閉幀寫入方法，與 SafeWriteMessage 共用寫入鎖
+func (c *Client) SafeWriteClose(code int, text string, deadline time.Time) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if !c.Active() {
+		return ErrClientInactive
+	}
+
+	return c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), deadline)
+}
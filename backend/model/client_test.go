@@ -67,6 +67,32 @@ func TestUpdateActivity(t *testing.T) {
 	ResetTimeNow()
 }
 
+// 測試以 time.Time 取得客戶端的時間戳
+func TestClientTimeAccessors(t *testing.T) {
+	// 安排 (Arrange)
+	joinedAt := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	SetTimeNow(func() time.Time {
+		return joinedAt
+	})
+	defer ResetTimeNow()
+	client := NewClient("test-id", nil)
+
+	lastActive := joinedAt.Add(90 * time.Second)
+	SetTimeNow(func() time.Time {
+		return lastActive
+	})
+
+	// 動作 (Act)
+	client.UpdateActivity()
+
+	// 斷言 (Assert)
+	assert.True(t, joinedAt.Equal(client.JoinedAtTime()), "加入時間應該匹配模擬時間")
+	assert.True(t, lastActive.Equal(client.LastActiveTime()), "最後活躍時間應該匹配更新時的模擬時間")
+	assert.Equal(t, client.JoinedAt, client.JoinedAtTime().Unix(), "應該與整數時間戳一致")
+	assert.Equal(t, client.LastActive, client.LastActiveTime().Unix(), "應該與整數時間戳一致")
+	assert.Equal(t, 90*time.Second, client.LastActiveTime().Sub(client.JoinedAtTime()), "時間差應該正確")
+}
+
 // 測試停用客戶端
 func TestDeactivate(t *testing.T) {
 	// 安排 (Arrange)
@@ -96,3 +122,16 @@ func TestClientWithConnection(t *testing.T) {
 	assert.NotNil(t, client.Conn, "WebSocket 連接不應該為 nil")
 	assert.Equal(t, mockConn, client.Conn, "WebSocket 連接應該匹配")
 }
+
+// 測試停用的客戶端不會再發送 ping
+func TestSafeWritePingInactiveClient(t *testing.T) {
+	// 安排 (Arrange)：停用的客戶端沒有可用的連接，若嘗試寫入將會失敗
+	client := NewClient("test-id", nil)
+	client.Deactivate()
+
+	// 動作 (Act)
+	err := client.SafeWritePing(time.Now().Add(time.Second))
+
+	// 斷言 (Assert)
+	assert.ErrorIs(t, err, ErrClientInactive, "停用的客戶端應該返回 ErrClientInactive")
+}
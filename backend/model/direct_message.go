@@ -0,0 +1,16 @@
+package model
+
+import "gorm.io/gorm"
+
+// DirectMessage 代表用戶之間的私人訊息，以用戶 ID 記錄發送者與接收者，接收者離線時也會保存
+type DirectMessage struct {
+	gorm.Model
+	SenderID    string `gorm:"size:255;not null;index"`
+	RecipientID string `gorm:"size:255;not null;index"`
+	Content     string `gorm:"type:text;not null"`
+}
+
+// TableName 指定 DirectMessage 模型的表名
+func (DirectMessage) TableName() string {
+	return "direct_messages"
+}
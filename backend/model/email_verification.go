@@ -0,0 +1,15 @@
+package model
+
+import "gorm.io/gorm"
+
+// EmailVerificationToken 代表用於驗證用戶電子郵件的令牌
+type EmailVerificationToken struct {
+	gorm.Model
+	Token  string `gorm:"size:255;not null;uniqueIndex"`
+	UserID string `gorm:"size:255;index"`
+}
+
+// TableName 指定 EmailVerificationToken 模型的表名
+func (EmailVerificationToken) TableName() string {
+	return "email_verification_tokens"
+}
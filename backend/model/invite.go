@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RoomInvite 代表聊天室的邀請連結
+type RoomInvite struct {
+	gorm.Model
+	Token     string    `gorm:"size:255;not null;uniqueIndex"`
+	RoomID    string    `gorm:"size:255;index"`
+	CreatedBy string    `gorm:"size:255"`
+	ExpiresAt time.Time // 過期時間
+	MaxUses   int       `gorm:"default:0"` // 最大使用次數，0 表示不限
+	Uses      int       `gorm:"default:0"` // 已使用次數
+}
+
+// TableName 指定 RoomInvite 模型的表名
+func (RoomInvite) TableName() string {
+	return "room_invites"
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PasswordResetToken 代表一次性的密碼重設令牌
+type PasswordResetToken struct {
+	gorm.Model
+	Token     string     `gorm:"size:255;not null;uniqueIndex"`
+	UserID    string     `gorm:"size:255;index"`
+	ExpiresAt time.Time  // 過期時間
+	UsedAt    *time.Time // 使用時間，nil 表示尚未使用
+}
+
+// TableName 指定 PasswordResetToken 模型的表名
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
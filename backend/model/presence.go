@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PresenceSnapshot 代表某一時間點聊天室的在線連線數快照
+type PresenceSnapshot struct {
+	gorm.Model
+	RoomID            string    `gorm:"size:255;index"`
+	ActiveConnections int       `gorm:"default:0"`
+	CapturedAt        time.Time `gorm:"index"`
+}
+
+// TableName 指定 PresenceSnapshot 模型的表名
+func (PresenceSnapshot) TableName() string {
+	return "presence_snapshots"
+}
@@ -0,0 +1,22 @@
+package model
+
+import "gorm.io/gorm"
+
+// MessageReaction 代表用戶對訊息的表情回應，同一用戶對同一訊息的每種表情只記錄一次
+type MessageReaction struct {
+	gorm.Model
+	MessageID uint   `gorm:"index:idx_message_reactions_message_user"`
+	UserID    string `gorm:"size:255;index:idx_message_reactions_message_user"`
+	Emoji     string `gorm:"size:64"`
+}
+
+// ReactionCount 代表訊息上某個表情的回應數量
+type ReactionCount struct {
+	Emoji string
+	Count int64
+}
+
+// TableName 指定 MessageReaction 模型的表名
+func (MessageReaction) TableName() string {
+	return "message_reactions"
+}
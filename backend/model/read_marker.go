@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RoomReadMarker 記錄用戶在聊天室中最後讀到的訊息，之後的訊息視為未讀
+type RoomReadMarker struct {
+	gorm.Model
+	UserID            string `gorm:"size:255;not null;uniqueIndex:idx_room_read_markers_user_room"`
+	RoomID            string `gorm:"size:255;not null;uniqueIndex:idx_room_read_markers_user_room"`
+	LastReadMessageID uint   // 標記已讀時聊天室中最新的訊息 ID，0 表示當時沒有任何訊息
+	LastReadAt        time.Time
+}
+
+// TableName 指定 RoomReadMarker 模型的表名
+func (RoomReadMarker) TableName() string {
+	return "room_read_markers"
+}
@@ -8,36 +8,64 @@ import (
 
 // Room 代表一個聊天室
 type Room struct {
-	ID          string `gorm:"primaryKey;type:uuid"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	DeletedAt   gorm.DeletedAt `gorm:"index"`
-	Name        string         `gorm:"size:255;not null"`
-	Description string         `gorm:"type:text"`
-	IsPublic    bool           `gorm:"default:true"`
-	MaxUsers    int            `gorm:"default:100"`
-	CreatedBy   string         `gorm:"size:255"`
-	IsActive    bool           `gorm:"default:true"`
+	ID                string `gorm:"primaryKey;type:uuid"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         gorm.DeletedAt `gorm:"index"`
+	Name              string         `gorm:"size:255;not null"`
+	Description       string         `gorm:"type:text"`
+	IsPublic          bool           `gorm:"default:true"`
+	MaxUsers          int            `gorm:"default:100"`
+	CreatedBy         string         `gorm:"size:255"`
+	IsActive          bool           `gorm:"default:true"`
+	IsListed          bool           `gorm:"default:true"` // 是否顯示在公開聊天室列表中
+	MessageTTLSeconds int            `gorm:"default:0"`    // 訊息存活秒數，過期後不再顯示並由背景任務清除，0 表示永久保存
 }
 
 // RoomUser 代表用戶與聊天室的關聯
 type RoomUser struct {
 	gorm.Model
-	RoomID       string    `gorm:"size:255;index"`
-	UserID       string    `gorm:"size:255;index"`
-	Role         string    `gorm:"size:20;default:'member'"`
-	JoinedAt     time.Time `gorm:"default:CURRENT_TIMESTAMP"`
-	LastActiveAt time.Time `gorm:"default:CURRENT_TIMESTAMP"`
-	IsActive     bool      `gorm:"default:true"`
+	RoomID       string     `gorm:"size:255;index"`
+	UserID       string     `gorm:"size:255;index"`
+	Role         string     `gorm:"size:20;default:'member'"`
+	JoinedAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP"`
+	LastActiveAt time.Time  `gorm:"default:CURRENT_TIMESTAMP"`
+	IsActive     bool       `gorm:"default:true"` // 目前是否連線在聊天室中
+	KickedAt     *time.Time // 被移出聊天室的時間，重新加入時清除；nil 表示仍是聊天室成員
+}
+
+// RoomUserDetail 是聊天室成員記錄與用戶資料合併的查詢結果，找不到對應用戶時用戶欄位為空
+type RoomUserDetail struct {
+	UserID       string
+	Username     string
+	DisplayName  string
+	Role         string // 聊天室中的角色
+	JoinedAt     time.Time
+	LastActiveAt time.Time
+}
+
+// MessageSearchResult 是訊息搜尋的結果，包含作者的用戶資料，找不到對應用戶時用戶欄位為空
+type MessageSearchResult struct {
+	Message
+	Username    string
+	DisplayName string
+}
+
+// RoomWithActiveCount 是聊天室與其目前活躍成員數合併的查詢結果
+type RoomWithActiveCount struct {
+	Room
+	ActiveUsers int64
 }
 
 // Message 代表聊天訊息
 type Message struct {
 	gorm.Model
-	RoomID          string `gorm:"size:255;index"`
-	UserID          string `gorm:"size:255;index"`
-	Content         string `gorm:"type:text;not null"`
-	IsSystemMessage bool   `gorm:"default:false"`
+	RoomID          string     `gorm:"size:255;index"`
+	UserID          string     `gorm:"size:255;index"`
+	Content         string     `gorm:"type:text;not null"`
+	IsSystemMessage bool       `gorm:"default:false"`
+	Compressed      bool       `gorm:"default:false"` // Content 是否以 gzip+base64 壓縮儲存
+	EditedAt        *time.Time // 最後編輯時間，未編輯過為 nil
 }
 
 // TableName 指定 Room 模型的表名
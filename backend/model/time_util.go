@@ -18,3 +18,8 @@ func SetTimeNow(f func() time.Time) {
 func ResetTimeNow() {
 	timeNow = time.Now
 }
+
+// Now 返回當前時間，可透過 SetTimeNow 注入，供其他套件共用同一時鐘
+func Now() time.Time {
+	return timeNow()
+}
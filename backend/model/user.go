@@ -7,17 +7,24 @@ import (
 	"gorm.io/gorm"
 )
 
+// 系統用戶是由遷移建立的保留帳號，作為系統訊息的發送者，無法用於登入
+const (
+	SystemUserID   = "00000000-0000-0000-0000-000000000001"
+	SystemUsername = "__system__"
+)
+
 // User 代表一個用戶
 type User struct {
-	ID         string `gorm:"primaryKey;type:uuid"`
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
-	DeletedAt  gorm.DeletedAt `gorm:"index"`
-	Username   string         `gorm:"size:255;not null;uniqueIndex"`
-	Email      string         `gorm:"size:255;not null;uniqueIndex"`
-	Password   string         `gorm:"column:password_hash;size:255;not null"` // 存儲哈希後的密碼
-	Role       string         `gorm:"size:50;default:'user'"`
-	IsVerified bool           `gorm:"default:false"`
+	ID          string `gorm:"primaryKey;type:uuid"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+	Username    string         `gorm:"size:255;not null;uniqueIndex"`
+	DisplayName string         `gorm:"size:50"` // 顯示名稱，可重複且不影響登入用戶名
+	Email       string         `gorm:"size:255;not null;uniqueIndex"`
+	Password    string         `gorm:"column:password_hash;size:255;not null"` // 存儲哈希後的密碼
+	Role        string         `gorm:"size:50;default:'user'"`
+	IsVerified  bool           `gorm:"default:false"`
 }
 
 // BeforeCreate hook在創建用戶前自動生成UUID
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"errors"
+	"livechat/backend/model"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository 管理 API 金鑰數據
+type APIKeyRepository struct {
+	db DB
+}
+
+// NewAPIKeyRepository 創建一個新的 API 金鑰儲存庫
+func NewAPIKeyRepository(db DB) *APIKeyRepository {
+	return &APIKeyRepository{
+		db: db,
+	}
+}
+
+// CreateAPIKey 創建 API 金鑰
+func (r *APIKeyRepository) CreateAPIKey(key *model.APIKey) error {
+	result := r.db.Create(key)
+	return result.Error
+}
+
+// GetAPIKeyByHash 根據金鑰的雜湊獲取 API 金鑰，包含已撤銷的金鑰
+func (r *APIKeyRepository) GetAPIKeyByHash(keyHash string) (*model.APIKey, error) {
+	var key model.APIKey
+	result := r.db.First(&key, "key_hash = ?", keyHash)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, result.Error
+	}
+	return &key, nil
+}
+
+// RevokeAPIKey 撤銷 API 金鑰，金鑰不存在或已撤銷時返回 ErrAPIKeyNotFound
+func (r *APIKeyRepository) RevokeAPIKey(id uint) error {
+	result := r.db.Model(&model.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", model.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
@@ -89,7 +89,7 @@ func (r *ClientRepository) GetActiveClients() []*model.Client {
 
 	var activeClients []*model.Client
 	for _, client := range r.clients {
-		if client.IsActive {
+		if client.Active() {
 			activeClients = append(activeClients, client)
 		}
 	}
@@ -4,9 +4,19 @@ import "errors"
 
 // 共同的錯誤定義
 var (
-	ErrUserNotFound       = errors.New("用戶不存在")
-	ErrUserAlreadyExists  = errors.New("用戶已存在")
-	ErrEmailAlreadyExists = errors.New("電子郵件已被使用")
-	ErrInvalidCredentials = errors.New("用戶名或密碼錯誤")
-	ErrRoomNotFound       = errors.New("聊天室不存在")
+	ErrUserNotFound              = errors.New("用戶不存在")
+	ErrUserAlreadyExists         = errors.New("用戶已存在（已刪除帳號的用戶名不會釋出）")
+	ErrEmailAlreadyExists        = errors.New("電子郵件已被使用（已刪除帳號的電子郵件不會釋出）")
+	ErrInvalidCredentials        = errors.New("用戶名或密碼錯誤")
+	ErrRoomNotFound              = errors.New("聊天室不存在")
+	ErrInviteNotFound            = errors.New("邀請不存在")
+	ErrInviteExhausted           = errors.New("邀請已達使用上限")
+	ErrRoomFull                  = errors.New("聊天室人數已滿")
+	ErrInvalidRoomOrder          = errors.New("無效的聊天室排序方式")
+	ErrMessageNotFound           = errors.New("訊息不存在")
+	ErrReactionNotFound          = errors.New("表情回應不存在")
+	ErrResetTokenNotFound        = errors.New("密碼重設令牌不存在")
+	ErrResetTokenUsed            = errors.New("密碼重設令牌已被使用")
+	ErrVerificationTokenNotFound = errors.New("電子郵件驗證令牌不存在")
+	ErrAPIKeyNotFound            = errors.New("API 金鑰不存在")
 )
@@ -100,10 +100,13 @@ func (m *MockDB) Save(value interface{}) *gorm.DB {
 // Delete 模擬 GORM 的 Delete 方法，用於刪除記錄
 // 在測試中驗證刪除操作的邏輯正確性
 func (m *MockDB) Delete(value interface{}, conds ...interface{}) *gorm.DB {
-	m.Called(value, conds)
+	// 如果有真實的 DB 實例，直接使用真實的 Delete 操作
 	if m.DB != nil {
-		return m.DB
+		return m.DB.Delete(value, conds...)
 	}
+
+	// 否則使用 Mock 行為
+	m.Called(value, conds)
 	return &gorm.DB{}
 }
 
@@ -211,10 +214,19 @@ func NewMockDB() *MockDB {
 	// 自動遷移所有必要的資料表結構
 	// 這樣 NewMockDB() 就可以支援完整的資料庫操作
 	err = db.AutoMigrate(
-		&model.User{},     // 使用者表
-		&model.Room{},     // 聊天室表
-		&model.RoomUser{}, // 聊天室使用者關聯表
-		&model.Message{},  // 訊息表
+		&model.User{},                   // 使用者表
+		&model.Room{},                   // 聊天室表
+		&model.RoomUser{},               // 聊天室使用者關聯表
+		&model.Message{},                // 訊息表
+		&model.PresenceSnapshot{},       // 在線人數快照表
+		&model.RoomInvite{},             // 聊天室邀請表
+		&model.RoomBan{},                // 聊天室封禁記錄表
+		&model.MessageReaction{},        // 訊息表情回應表
+		&model.PasswordResetToken{},     // 密碼重設令牌表
+		&model.EmailVerificationToken{}, // 電子郵件驗證令牌表
+		&model.APIKey{},                 // API 金鑰表
+		&model.DirectMessage{},          // 用戶私人訊息表
+		&model.RoomReadMarker{},         // 聊天室已讀位置表
 	)
 	if err != nil {
 		panic("failed to migrate database schema: " + err.Error())
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"livechat/backend/model"
+)
+
+// PresenceRepository 管理聊天室在線人數快照數據
+type PresenceRepository struct {
+	db DB
+}
+
+// NewPresenceRepository 創建一個新的在線人數快照儲存庫
+func NewPresenceRepository(db DB) *PresenceRepository {
+	return &PresenceRepository{
+		db: db,
+	}
+}
+
+// SaveSnapshots 批次保存在線人數快照
+func (r *PresenceRepository) SaveSnapshots(snapshots []model.PresenceSnapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	result := r.db.Create(&snapshots)
+	return result.Error
+}
+
+// GetPresenceHistory 獲取聊天室的在線人數快照歷史（最新的在前）
+func (r *PresenceRepository) GetPresenceHistory(roomID string, limit int) ([]model.PresenceSnapshot, error) {
+	var snapshots []model.PresenceSnapshot
+
+	result := r.db.Where("room_id = ?", roomID).Order("captured_at desc").Limit(limit).Find(&snapshots)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return snapshots, nil
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"livechat/backend/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 測試批次保存快照並依時間倒序查詢歷史
+func TestPresenceRepositorySaveAndHistory(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDB()
+	repo := NewPresenceRepository(mockDB)
+
+	base := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	snapshots := []model.PresenceSnapshot{
+		{RoomID: "room-1", ActiveConnections: 3, CapturedAt: base},
+		{RoomID: "room-1", ActiveConnections: 5, CapturedAt: base.Add(time.Minute)},
+		{RoomID: "room-2", ActiveConnections: 1, CapturedAt: base},
+	}
+
+	// 動作 (Act)
+	err := repo.SaveSnapshots(snapshots)
+	assert.NoError(t, err, "保存快照不應該返回錯誤")
+
+	history, err := repo.GetPresenceHistory("room-1", 10)
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "獲取快照歷史不應該返回錯誤")
+	assert.Equal(t, 2, len(history), "room-1 應該有 2 筆快照")
+	assert.Equal(t, 5, history[0].ActiveConnections, "最新的快照應該排在最前面")
+	assert.Equal(t, 3, history[1].ActiveConnections, "較舊的快照應該排在後面")
+
+	// 測試數量限制
+	limited, err := repo.GetPresenceHistory("room-1", 1)
+	assert.NoError(t, err, "獲取快照歷史不應該返回錯誤")
+	assert.Equal(t, 1, len(limited), "應該只返回 1 筆快照")
+
+	// 空快照列表不應寫入也不應報錯
+	assert.NoError(t, repo.SaveSnapshots(nil), "保存空快照不應該返回錯誤")
+}
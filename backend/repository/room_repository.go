@@ -1,17 +1,58 @@
 package repository
 
 import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"livechat/backend/model"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // RoomRepository 管理聊天室數據
 type RoomRepository struct {
-	db DB
+	db                   DB
+	compressionThreshold int // 超過此位元組數的訊息會被壓縮儲存，0 表示停用
+}
+
+// RoomOrder 定義聊天室列表的排序方式
+type RoomOrder string
+
+const (
+	RoomOrderName     RoomOrder = "name"     // 依名稱排序
+	RoomOrderCreated  RoomOrder = "created"  // 依創建時間排序，最新的在前
+	RoomOrderActivity RoomOrder = "activity" // 依最近訊息時間排序，最活躍的在前
+	RoomOrderActive   RoomOrder = "active"   // 依目前活躍成員數排序，人數最多的在前
+)
+
+// roomOrderClauses 對應各排序方式的 ORDER BY 子句
+// activity 以最新訊息時間排序，沒有訊息的聊天室以創建時間代替；active 人數相同時依創建時間排序
+var roomOrderClauses = map[RoomOrder]string{
+	RoomOrderName:     "rooms.name asc",
+	RoomOrderCreated:  "rooms.created_at desc",
+	RoomOrderActivity: "COALESCE((SELECT MAX(messages.created_at) FROM messages WHERE messages.room_id = rooms.id AND messages.deleted_at IS NULL), rooms.created_at) desc",
+	RoomOrderActive:   "(SELECT COUNT(*) FROM room_users WHERE room_users.room_id = rooms.id AND room_users.is_active) desc, rooms.created_at asc",
+}
+
+// recentActivityColumn 取用戶在聊天室最後發送訊息與最後活躍時間中較晚者
+const recentActivityColumn = "CASE WHEN last_messages.last_message_at IS NOT NULL AND last_messages.last_message_at > memberships.last_active_at " +
+	"THEN last_messages.last_message_at ELSE memberships.last_active_at END"
+
+// RoomRepositoryOption 定義聊天室儲存庫選項
+type RoomRepositoryOption func(*RoomRepository)
+
+// WithMessageCompression 設置訊息壓縮的門檻，內容超過 threshold 位元組的訊息會以 gzip 壓縮後儲存
+func WithMessageCompression(threshold int) RoomRepositoryOption {
+	return func(r *RoomRepository) {
+		r.compressionThreshold = threshold
+	}
 }
 
 // DB 接口定義了 RoomRepository 所需的 GORM 方法
@@ -26,16 +67,26 @@ type DB interface {
 	Limit(limit int) *gorm.DB
 	Count(count *int64) *gorm.DB
 	Model(value interface{}) *gorm.DB
+	Clauses(conds ...clause.Expression) *gorm.DB
+	Transaction(fc func(tx *gorm.DB) error, opts ...*sql.TxOptions) error
 }
 
 // NewRoomRepository 創建一個新的聊天室儲存庫
-func NewRoomRepository(db DB) *RoomRepository {
-	return &RoomRepository{
+func NewRoomRepository(db DB, opts ...RoomRepositoryOption) *RoomRepository {
+	r := &RoomRepository{
 		db: db,
 	}
+
+	// 應用選項
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
 }
 
-// GetRoom 獲取指定的聊天室
+// GetRoom 獲取指定的聊天室，已軟刪除的聊天室返回 ErrRoomNotFound
+// Room 嵌入 gorm.DeletedAt，透過模型進行的查詢都會自動加上 deleted_at IS NULL 條件
 func (r *RoomRepository) GetRoom(roomID string) (*model.Room, error) {
 	var room model.Room
 
@@ -51,24 +102,135 @@ func (r *RoomRepository) GetRoom(roomID string) (*model.Room, error) {
 	return &room, nil
 }
 
-// GetAllRooms 獲取所有聊天室
-func (r *RoomRepository) GetAllRooms() ([]model.Room, error) {
+// GetRoomByName 依名稱獲取活躍的聊天室，名稱重複時返回最早創建的聊天室
+func (r *RoomRepository) GetRoomByName(name string) (*model.Room, error) {
+	var room model.Room
+
+	result := r.db.Where("name = ? AND is_active = ?", name, true).Order("created_at asc").First(&room)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrRoomNotFound
+		}
+		return nil, result.Error
+	}
+
+	return &room, nil
+}
+
+// RoomFilter 定義聊天室列表的過濾與分頁條件，零值表示不過濾也不分頁
+type RoomFilter struct {
+	Query      string // 名稱或描述包含的子字串，不區分大小寫
+	PublicOnly bool   // 排除私人聊天室
+	Limit      int    // 最多返回的筆數，0 表示不限
+	Offset     int    // 略過的筆數
+}
+
+// GetAllRooms 依指定的排序方式與過濾條件獲取列在公開列表中的聊天室（不列出的聊天室仍可透過 ID 取得）
+// 返回的總數為符合條件的聊天室數量，不受分頁影響
+func (r *RoomRepository) GetAllRooms(order RoomOrder, filter RoomFilter) ([]model.Room, int64, error) {
 	var rooms []model.Room
 
 	fmt.Println("Repository: Getting all rooms from database...")
-	result := r.db.Find(&rooms, "is_active = ?", true)
+	query, total, err := r.listedRoomsQuery(order, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := query.Find(&rooms)
 	if result.Error != nil {
 		fmt.Printf("Repository: Error getting rooms: %v\n", result.Error)
-		return nil, result.Error
+		return nil, 0, result.Error
 	}
 
 	fmt.Printf("Repository: Found %d rooms in database\n", len(rooms))
-	return rooms, nil
+	return rooms, total, nil
+}
+
+// GetAllRoomsWithActiveCounts 與 GetAllRooms 相同，但以單一分組查詢同時取得各聊天室的活躍成員數，
+// 沒有活躍成員的聊天室數量為 0
+func (r *RoomRepository) GetAllRoomsWithActiveCounts(order RoomOrder, filter RoomFilter) ([]model.RoomWithActiveCount, int64, error) {
+	var rooms []model.RoomWithActiveCount
+
+	query, total, err := r.listedRoomsQuery(order, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := withActiveCounts(query).Scan(&rooms)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return rooms, total, nil
+}
+
+// withActiveCounts 以單一分組子查詢為聊天室查詢加上 active_users 欄位，沒有活躍成員的聊天室數量為 0
+func withActiveCounts(query *gorm.DB) *gorm.DB {
+	return query.
+		Select("rooms.*, COALESCE(active_counts.active_users, 0) AS active_users").
+		Joins("LEFT JOIN (SELECT room_id, COUNT(*) AS active_users FROM room_users WHERE is_active = ? AND deleted_at IS NULL GROUP BY room_id) active_counts ON active_counts.room_id = rooms.id", true)
+}
+
+// listedRoomsQuery 建立符合過濾條件、已套用排序與分頁的聊天室列表查詢，同時返回不受分頁影響的總數
+func (r *RoomRepository) listedRoomsQuery(order RoomOrder, filter RoomFilter) (*gorm.DB, int64, error) {
+	orderClause, ok := roomOrderClauses[order]
+	if !ok {
+		return nil, 0, ErrInvalidRoomOrder
+	}
+
+	// 欄位皆加上表名，避免與 JOIN 進來的 room_users 欄位衝突
+	query := r.db.Model(&model.Room{}).Where("rooms.is_active = ? AND rooms.is_listed = ?", true, true)
+	if filter.PublicOnly {
+		query = query.Where("rooms.is_public = ?", true)
+	}
+	if filter.Query != "" {
+		pattern := "%" + escapeLike(strings.ToLower(filter.Query)) + "%"
+		query = query.Where(`(LOWER(rooms.name) LIKE ? ESCAPE '\' OR LOWER(rooms.description) LIKE ? ESCAPE '\')`, pattern, pattern)
+	}
+
+	var total int64
+	if result := query.Session(&gorm.Session{}).Count(&total); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	query = query.Order(orderClause)
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	return query, total, nil
+}
+
+// likeEscaper 跳脫 LIKE 模式中的萬用字元，讓搜尋字串按字面比對
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike 跳脫字串中的 LIKE 萬用字元，搭配 ESCAPE '\' 使用
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
 }
 
 // CreateRoom 創建一個新的聊天室
 func (r *RoomRepository) CreateRoom(room *model.Room) error {
+	// GORM 會以資料庫預設值取代零值，不列出或私人的聊天室需在創建後明確寫入 false
+	isListed := room.IsListed
+	isPublic := room.IsPublic
+
 	result := r.db.Create(room)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if !isListed {
+		if result = r.db.Model(room).Update("is_listed", false); result.Error != nil {
+			return result.Error
+		}
+	}
+	if !isPublic {
+		result = r.db.Model(room).Update("is_public", false)
+	}
 	return result.Error
 }
 
@@ -78,6 +240,32 @@ func (r *RoomRepository) UpdateRoom(room *model.Room) error {
 	return result.Error
 }
 
+// GetRoomsCreatedBy 獲取用戶創建的所有聊天室，包含已停用的聊天室
+func (r *RoomRepository) GetRoomsCreatedBy(userID string) ([]model.Room, error) {
+	var rooms []model.Room
+
+	result := r.db.Where("created_by = ?", userID).Find(&rooms)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return rooms, nil
+}
+
+// DeleteRoom 軟刪除聊天室，並將所有成員記錄標記為不活躍
+func (r *RoomRepository) DeleteRoom(roomID string) error {
+	result := r.db.Where("id = ?", roomID).Delete(&model.Room{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRoomNotFound
+	}
+
+	result = r.db.Model(&model.RoomUser{}).Where("room_id = ? AND is_active = ?", roomID, true).Update("is_active", false)
+	return result.Error
+}
+
 // GetRoomUsers 獲取聊天室的所有活躍用戶
 func (r *RoomRepository) GetRoomUsers(roomID string) ([]model.RoomUser, error) {
 	var users []model.RoomUser
@@ -90,8 +278,58 @@ func (r *RoomRepository) GetRoomUsers(roomID string) ([]model.RoomUser, error) {
 	return users, nil
 }
 
+// GetRoomUsersWithDetails 獲取聊天室的所有活躍用戶及其用戶資料，依加入時間排序
+// 沒有對應用戶記錄的成員仍會返回，用戶名稱欄位為空
+func (r *RoomRepository) GetRoomUsersWithDetails(roomID string) ([]model.RoomUserDetail, error) {
+	var details []model.RoomUserDetail
+
+	result := r.db.Model(&model.RoomUser{}).
+		Select("room_users.user_id, COALESCE(users.username, '') AS username, COALESCE(users.display_name, '') AS display_name, room_users.role, room_users.joined_at, room_users.last_active_at").
+		Joins("LEFT JOIN users ON users.id = room_users.user_id AND users.deleted_at IS NULL").
+		Where("room_users.room_id = ? AND room_users.is_active = ?", roomID, true).
+		Order("room_users.joined_at, room_users.id").
+		Scan(&details)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return details, nil
+}
+
+// GetMembershipHistory 獲取聊天室的成員記錄（包含已離開的成員），依加入時間由新到舊分頁
+func (r *RoomRepository) GetMembershipHistory(roomID string, limit int, offset int) ([]model.RoomUser, error) {
+	var users []model.RoomUser
+
+	result := r.db.Where("room_id = ?", roomID).Order("joined_at desc, id desc").Limit(limit).Offset(offset).Find(&users)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return users, nil
+}
+
 // JoinRoom 用戶加入聊天室
+// 已是活躍成員時不做任何事；曾經離開時重新啟用最近的成員記錄並保留原有角色，避免產生重複的活躍記錄
 func (r *RoomRepository) JoinRoom(roomID string, userID string, role string) error {
+	var existing model.RoomUser
+
+	result := r.db.Where("room_id = ? AND user_id = ?", roomID, userID).Order("id desc").First(&existing)
+	if result.Error == nil {
+		if existing.IsActive {
+			return nil
+		}
+
+		existing.JoinedAt = time.Now()
+		existing.LastActiveAt = time.Now()
+		existing.IsActive = true
+		existing.KickedAt = nil
+		result = r.db.Save(&existing)
+		return result.Error
+	}
+	if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return result.Error
+	}
+
 	roomUser := model.RoomUser{
 		RoomID:       roomID,
 		UserID:       userID,
@@ -101,7 +339,7 @@ func (r *RoomRepository) JoinRoom(roomID string, userID string, role string) err
 		IsActive:     true,
 	}
 
-	result := r.db.Create(&roomUser)
+	result = r.db.Create(&roomUser)
 	return result.Error
 }
 
@@ -122,6 +360,29 @@ func (r *RoomRepository) LeaveRoom(roomID string, userID string) error {
 	return result.Error
 }
 
+// KickRoomUser 將用戶移出聊天室：標記所有尚未被移出的成員記錄為不活躍並記錄移出時間
+// 用戶沒有成員記錄或已被移出時返回 ErrUserNotFound
+func (r *RoomRepository) KickRoomUser(roomID string, userID string) error {
+	result := r.db.Model(&model.RoomUser{}).
+		Where("room_id = ? AND user_id = ? AND kicked_at IS NULL", roomID, userID).
+		Updates(map[string]interface{}{"is_active": false, "kicked_at": model.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// LeaveAllRooms 將用戶在所有聊天室的活躍成員記錄標記為不活躍
+func (r *RoomRepository) LeaveAllRooms(userID string) error {
+	result := r.db.Model(&model.RoomUser{}).Where("user_id = ? AND is_active = ?", userID, true).Update("is_active", false)
+	return result.Error
+}
+
 // UpdateUserActivity 更新用戶在聊天室的活躍狀態
 func (r *RoomRepository) UpdateUserActivity(roomID string, userID string) error {
 	var roomUser model.RoomUser
@@ -143,20 +404,396 @@ func (r *RoomRepository) UpdateUserActivity(roomID string, userID string) error
 func (r *RoomRepository) GetRoomMessages(roomID string, limit int) ([]model.Message, error) {
 	var messages []model.Message
 
-	result := r.db.Where("room_id = ?", roomID).Order("created_at desc").Limit(limit).Find(&messages)
+	query, err := r.unexpiredMessages(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := query.Order("created_at desc").Limit(limit).Find(&messages)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if err := decompressMessages(messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// GetRoomMessagesBefore 獲取聊天室中 ID 小於 beforeID 的訊息（最新的在前），beforeID 為 0 時從最新訊息開始
+func (r *RoomRepository) GetRoomMessagesBefore(roomID string, beforeID uint, limit int) ([]model.Message, error) {
+	var messages []model.Message
+
+	query, err := r.unexpiredMessages(roomID)
+	if err != nil {
+		return nil, err
+	}
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+
+	result := query.Order("id desc").Limit(limit).Find(&messages)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 
+	if err := decompressMessages(messages); err != nil {
+		return nil, err
+	}
+
 	return messages, nil
 }
 
-// SaveMessage 保存聊天訊息
+// SearchMessages 搜尋聊天室中內容包含關鍵字的訊息（不區分大小寫，排除系統訊息），最新的在前
+// 壓縮儲存的訊息無法在資料庫中比對，會在解壓縮後比對
+func (r *RoomRepository) SearchMessages(roomID string, query string, limit int) ([]model.MessageSearchResult, error) {
+	keyword := strings.ToLower(query)
+
+	scope, err := r.unexpiredMessages(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := scope.Model(&model.Message{}).
+		Where("is_system_message = ?", false).
+		Where(`(compressed = ? AND LOWER(content) LIKE ? ESCAPE '\') OR compressed = ?`, false, "%"+escapeLike(keyword)+"%", true).
+		Order("created_at desc, id desc").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []model.MessageSearchResult
+	for rows.Next() && (limit <= 0 || len(results) < limit) {
+		var message model.Message
+		if err := scope.ScanRows(rows, &message); err != nil {
+			return nil, err
+		}
+		if message.Compressed {
+			if err := decompressMessage(&message); err != nil {
+				return nil, err
+			}
+			if !strings.Contains(strings.ToLower(message.Content), keyword) {
+				continue
+			}
+		}
+		results = append(results, model.MessageSearchResult{Message: message})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// 達到數量限制時結果集尚未讀完，先釋放連接再查詢作者
+	rows.Close()
+
+	if err := r.fillMessageAuthors(results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// fillMessageAuthors 為搜尋結果填入作者的用戶名稱與顯示名稱，已刪除的用戶保持空白
+func (r *RoomRepository) fillMessageAuthors(results []model.MessageSearchResult) error {
+	userIDs := make([]string, 0, len(results))
+	for _, result := range results {
+		if result.UserID != "" {
+			userIDs = append(userIDs, result.UserID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	var users []model.User
+	if err := r.db.Model(&model.User{}).Select("id, username, display_name").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return err
+	}
+
+	authors := make(map[string]model.User, len(users))
+	for _, user := range users {
+		authors[user.ID] = user
+	}
+	for i := range results {
+		if author, ok := authors[results[i].UserID]; ok {
+			results[i].Username = author.Username
+			results[i].DisplayName = author.DisplayName
+		}
+	}
+	return nil
+}
+
+// unexpiredMessages 返回聊天室中尚未過期的訊息查詢，聊天室設有 TTL 時排除超過存活時間的訊息
+func (r *RoomRepository) unexpiredMessages(roomID string) (*gorm.DB, error) {
+	query := r.db.Where("room_id = ?", roomID)
+
+	var room model.Room
+	if err := r.db.Where("id = ?", roomID).Limit(1).Find(&room).Error; err != nil {
+		return nil, err
+	}
+	if room.MessageTTLSeconds > 0 {
+		query = query.Where("created_at > ?", messageExpiryCutoff(room.MessageTTLSeconds))
+	}
+
+	return query, nil
+}
+
+// messageExpiryCutoff 返回依 TTL 計算的過期時間點，在此之前（含）創建的訊息視為已過期
+func messageExpiryCutoff(ttlSeconds int) time.Time {
+	return model.Now().Add(-time.Duration(ttlSeconds) * time.Second)
+}
+
+// PurgeExpiredMessages 永久刪除所有設有 TTL 的聊天室中已過期的訊息，返回刪除的訊息數量
+func (r *RoomRepository) PurgeExpiredMessages() (int64, error) {
+	var rooms []model.Room
+	if err := r.db.Where("message_ttl_seconds > 0").Find(&rooms).Error; err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for _, room := range rooms {
+		result := r.db.Where("room_id = ? AND created_at <= ?", room.ID, messageExpiryCutoff(room.MessageTTLSeconds)).
+			Unscoped().Delete(&model.Message{})
+		if result.Error != nil {
+			return purged, result.Error
+		}
+		purged += result.RowsAffected
+	}
+
+	return purged, nil
+}
+
+// SaveMessage 保存聊天訊息，啟用壓縮時過長的內容會被壓縮後儲存
 func (r *RoomRepository) SaveMessage(message *model.Message) error {
+	if r.compressionThreshold <= 0 || len(message.Content) <= r.compressionThreshold {
+		result := r.db.Create(message)
+		return result.Error
+	}
+
+	content := message.Content
+	compressed, err := compressContent(content)
+	if err != nil {
+		return err
+	}
+
+	message.Content = compressed
+	message.Compressed = true
 	result := r.db.Create(message)
+
+	// 呼叫端持有的訊息仍保留原始內容
+	message.Content = content
 	return result.Error
 }
 
+// GetMessage 獲取指定的訊息，已刪除的訊息視為不存在
+func (r *RoomRepository) GetMessage(messageID uint) (*model.Message, error) {
+	var message model.Message
+
+	result := r.db.First(&message, messageID)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, result.Error
+	}
+
+	if err := decompressMessage(&message); err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
+
+// UpdateMessageContent 更新訊息內容並記錄編輯時間，與 SaveMessage 相同地依設定壓縮過長的內容
+func (r *RoomRepository) UpdateMessageContent(messageID uint, content string) error {
+	compressed := r.compressionThreshold > 0 && len(content) > r.compressionThreshold
+	if compressed {
+		var err error
+		if content, err = compressContent(content); err != nil {
+			return err
+		}
+	}
+
+	result := r.db.Model(&model.Message{}).Where("id = ?", messageID).Updates(map[string]interface{}{
+		"content":    content,
+		"compressed": compressed,
+		"edited_at":  model.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMessageNotFound
+	}
+
+	return nil
+}
+
+// DeleteMessage 軟刪除訊息，刪除後的訊息不再出現在查詢結果中
+func (r *RoomRepository) DeleteMessage(messageID uint) error {
+	result := r.db.Where("id = ?", messageID).Delete(&model.Message{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMessageNotFound
+	}
+
+	return nil
+}
+
+// DeleteMessagesByUser 軟刪除用戶在所有聊天室發送的訊息，返回被刪除的訊息以便通知各聊天室
+func (r *RoomRepository) DeleteMessagesByUser(userID string) ([]model.Message, error) {
+	var messages []model.Message
+
+	result := r.db.Where("user_id = ?", userID).Order("id").Find(&messages)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(messages) == 0 {
+		return messages, nil
+	}
+
+	ids := make([]uint, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+	if err := r.db.Where("id IN ?", ids).Delete(&model.Message{}).Error; err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// MarkRead 將用戶在聊天室中的已讀位置移到目前最新的訊息
+func (r *RoomRepository) MarkRead(userID string, roomID string) error {
+	// 包含已刪除的訊息，確保已讀位置不會因最新訊息被刪除而倒退
+	var latest model.Message
+	if err := r.db.Model(&model.Message{}).Unscoped().Where("room_id = ?", roomID).Order("id desc").Limit(1).Find(&latest).Error; err != nil {
+		return err
+	}
+
+	// 以 upsert 寫入，並發標記已讀時不會產生重複的記錄；已讀位置只前進不倒退
+	now := model.Now()
+	marker := model.RoomReadMarker{
+		UserID:            userID,
+		RoomID:            roomID,
+		LastReadMessageID: latest.ID,
+		LastReadAt:        now,
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "room_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"last_read_message_id": gorm.Expr("CASE WHEN excluded.last_read_message_id > room_read_markers.last_read_message_id THEN excluded.last_read_message_id ELSE room_read_markers.last_read_message_id END"),
+			"last_read_at":         now,
+			"updated_at":           now,
+		}),
+	}).Create(&marker).Error
+}
+
+// GetUnreadCount 統計用戶在聊天室中的未讀訊息數
+func (r *RoomRepository) GetUnreadCount(userID string, roomID string) (int64, error) {
+	counts, err := r.GetUnreadCounts(userID, []string{roomID})
+	if err != nil {
+		return 0, err
+	}
+	return counts[roomID], nil
+}
+
+// GetUnreadCounts 統計用戶在多個聊天室中的未讀訊息數，結果包含每個傳入的聊天室
+// 未讀訊息為已讀位置之後由其他人發送且尚未過期的非系統訊息，從未標記已讀的聊天室中所有訊息皆視為未讀
+func (r *RoomRepository) GetUnreadCounts(userID string, roomIDs []string) (map[string]int64, error) {
+	counts := make(map[string]int64, len(roomIDs))
+	if len(roomIDs) == 0 {
+		return counts, nil
+	}
+	for _, roomID := range roomIDs {
+		counts[roomID] = 0
+	}
+
+	var markers []model.RoomReadMarker
+	if err := r.db.Where("user_id = ? AND room_id IN ?", userID, roomIDs).Find(&markers).Error; err != nil {
+		return nil, err
+	}
+	lastRead := make(map[string]uint, len(markers))
+	for _, marker := range markers {
+		lastRead[marker.RoomID] = marker.LastReadMessageID
+	}
+
+	var rooms []model.Room
+	if err := r.db.Where("id IN ?", roomIDs).Find(&rooms).Error; err != nil {
+		return nil, err
+	}
+	ttls := make(map[string]int, len(rooms))
+	for _, room := range rooms {
+		ttls[room.ID] = room.MessageTTLSeconds
+	}
+
+	// 各聊天室的已讀位置與過期時間不同，以 OR 組合各聊天室的條件後一次統計
+	conditions := make([]string, 0, len(roomIDs))
+	args := make([]interface{}, 0, len(roomIDs)*3)
+	for roomID := range counts {
+		condition := "(room_id = ? AND id > ?"
+		args = append(args, roomID, lastRead[roomID])
+		if ttl := ttls[roomID]; ttl > 0 {
+			condition += " AND created_at > ?"
+			args = append(args, messageExpiryCutoff(ttl))
+		}
+		conditions = append(conditions, condition+")")
+	}
+
+	var rows []struct {
+		RoomID string
+		Count  int64
+	}
+	result := r.db.Model(&model.Message{}).
+		Select("room_id, COUNT(*) AS count").
+		Where("("+strings.Join(conditions, " OR ")+")", args...).
+		Where("user_id <> ? AND is_system_message = ?", userID, false).
+		Group("room_id").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	for _, row := range rows {
+		counts[row.RoomID] = row.Count
+	}
+	return counts, nil
+}
+
+// GetRecentRooms 獲取用戶參與過（包含已離開）的活躍聊天室，依用戶最近的活動時間排序，最近的在前
+// 活動時間取用戶在聊天室最後發送訊息的時間與成員記錄的最後活躍時間中較晚者，並一併返回各聊天室的活躍成員數
+func (r *RoomRepository) GetRecentRooms(userID string, limit int, offset int) ([]model.RoomWithActiveCount, error) {
+	var rooms []model.RoomWithActiveCount
+
+	result := withActiveCounts(r.db.Model(&model.Room{})).
+		Joins("JOIN (SELECT room_id, MAX(last_active_at) AS last_active_at FROM room_users WHERE user_id = ? AND deleted_at IS NULL GROUP BY room_id) memberships ON memberships.room_id = rooms.id", userID).
+		Joins("LEFT JOIN (SELECT room_id, MAX(created_at) AS last_message_at FROM messages WHERE user_id = ? AND deleted_at IS NULL GROUP BY room_id) last_messages ON last_messages.room_id = rooms.id", userID).
+		Where("rooms.is_active = ?", true).
+		Order(recentActivityColumn + " desc, rooms.id").
+		Limit(limit).Offset(offset).
+		Scan(&rooms)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return rooms, nil
+}
+
+// CountActiveRooms 計算活躍聊天室的數量
+func (r *RoomRepository) CountActiveRooms() (int64, error) {
+	var count int64
+
+	result := r.db.Model(&model.Room{}).Where("is_active = ?", true).Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return count, nil
+}
+
 // CountActiveUsers 計算聊天室的活躍用戶數
 func (r *RoomRepository) CountActiveUsers(roomID string) (int64, error) {
 	var count int64
@@ -168,3 +805,260 @@ func (r *RoomRepository) CountActiveUsers(roomID string) (int64, error) {
 
 	return count, nil
 }
+
+// GetRoomUser 獲取用戶在聊天室中的活躍成員記錄
+func (r *RoomRepository) GetRoomUser(roomID string, userID string) (*model.RoomUser, error) {
+	var roomUser model.RoomUser
+
+	result := r.db.Where("room_id = ? AND user_id = ? AND is_active = ?", roomID, userID, true).First(&roomUser)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, result.Error
+	}
+
+	return &roomUser, nil
+}
+
+// IsRoomMember 檢查用戶是否為聊天室成員，離開或斷線的成員仍是成員，被移出的用戶不是
+func (r *RoomRepository) IsRoomMember(roomID string, userID string) (bool, error) {
+	var count int64
+
+	result := r.db.Model(&model.RoomUser{}).Where("room_id = ? AND user_id = ? AND kicked_at IS NULL", roomID, userID).Count(&count)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
+
+// HasRoomMembership 檢查用戶是否曾經是聊天室的成員（包含已離開的成員記錄）
+func (r *RoomRepository) HasRoomMembership(roomID string, userID string) (bool, error) {
+	var count int64
+
+	result := r.db.Model(&model.RoomUser{}).Where("room_id = ? AND user_id = ?", roomID, userID).Count(&count)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
+
+// BanUser 記錄用戶被禁止進入聊天室
+func (r *RoomRepository) BanUser(ban *model.RoomBan) error {
+	result := r.db.Create(ban)
+	return result.Error
+}
+
+// IsUserBanned 檢查用戶是否被禁止進入聊天室
+func (r *RoomRepository) IsUserBanned(roomID string, userID string) (bool, error) {
+	var count int64
+
+	result := r.db.Model(&model.RoomBan{}).Where("room_id = ? AND user_id = ?", roomID, userID).Count(&count)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
+
+// AddReaction 記錄用戶對訊息的表情回應，同一用戶重複回應相同表情時不會重複記錄
+func (r *RoomRepository) AddReaction(reaction *model.MessageReaction) error {
+	var count int64
+
+	result := r.db.Model(&model.MessageReaction{}).
+		Where("message_id = ? AND user_id = ? AND emoji = ?", reaction.MessageID, reaction.UserID, reaction.Emoji).
+		Count(&count)
+	if result.Error != nil {
+		return result.Error
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return r.db.Create(reaction).Error
+}
+
+// RemoveReaction 移除用戶對訊息的表情回應，沒有對應的回應時返回 ErrReactionNotFound
+func (r *RoomRepository) RemoveReaction(messageID uint, userID string, emoji string) error {
+	result := r.db.Where("message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji).Delete(&model.MessageReaction{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrReactionNotFound
+	}
+
+	return nil
+}
+
+// GetReactionCounts 統計訊息上各表情的回應數量，依數量由多到少排序，數量相同時先出現的表情在前
+func (r *RoomRepository) GetReactionCounts(messageID uint) ([]model.ReactionCount, error) {
+	var counts []model.ReactionCount
+
+	result := r.db.Model(&model.MessageReaction{}).
+		Select("emoji, COUNT(*) AS count").
+		Where("message_id = ?", messageID).
+		Group("emoji").
+		Order("count DESC, MIN(id)").
+		Scan(&counts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return counts, nil
+}
+
+// GetReactionCountsForMessages 以一次分組查詢統計多則訊息上各表情的回應數量，以訊息 ID 為鍵
+// 每則訊息的排序與 GetReactionCounts 相同，沒有回應的訊息不會出現在結果中
+func (r *RoomRepository) GetReactionCountsForMessages(messageIDs []uint) (map[uint][]model.ReactionCount, error) {
+	counts := make(map[uint][]model.ReactionCount)
+	if len(messageIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		MessageID uint
+		Emoji     string
+		Count     int64
+	}
+	result := r.db.Model(&model.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count").
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Order("message_id, count DESC, MIN(id)").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	for _, row := range rows {
+		counts[row.MessageID] = append(counts[row.MessageID], model.ReactionCount{Emoji: row.Emoji, Count: row.Count})
+	}
+	return counts, nil
+}
+
+// CreateInvite 創建聊天室邀請
+func (r *RoomRepository) CreateInvite(invite *model.RoomInvite) error {
+	result := r.db.Create(invite)
+	return result.Error
+}
+
+// GetInviteByToken 根據邀請碼獲取聊天室邀請
+func (r *RoomRepository) GetInviteByToken(token string) (*model.RoomInvite, error) {
+	var invite model.RoomInvite
+
+	result := r.db.First(&invite, "token = ?", token)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrInviteNotFound
+		}
+		return nil, result.Error
+	}
+
+	return &invite, nil
+}
+
+// ConsumeInvite 使用一次邀請，以條件更新避免並發時超過使用上限
+func (r *RoomRepository) ConsumeInvite(inviteID uint) error {
+	result := r.db.Model(&model.RoomInvite{}).
+		Where("id = ? AND (max_uses = 0 OR uses < max_uses)", inviteID).
+		Update("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrInviteExhausted
+	}
+
+	return nil
+}
+
+// JoinRoomWithInvite 在同一個交易中讓用戶加入聊天室並使用一次邀請，
+// maxUsers 大於 0 且聊天室已滿時返回 ErrRoomFull，邀請已用盡時返回 ErrInviteExhausted，兩者皆不會留下加入記錄或使用邀請
+func (r *RoomRepository) JoinRoomWithInvite(roomID string, userID string, role string, maxUsers int, inviteID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		txRepo := &RoomRepository{db: tx, compressionThreshold: r.compressionThreshold}
+
+		// 檢查人數上限，已是活躍成員的重複加入不佔用新名額
+		if maxUsers > 0 {
+			_, err := txRepo.GetRoomUser(roomID, userID)
+			switch {
+			case errors.Is(err, ErrUserNotFound):
+				count, err := txRepo.CountActiveUsers(roomID)
+				if err != nil {
+					return err
+				}
+				if count >= int64(maxUsers) {
+					return ErrRoomFull
+				}
+			case err != nil:
+				return err
+			}
+		}
+
+		if err := txRepo.JoinRoom(roomID, userID, role); err != nil {
+			return err
+		}
+		return txRepo.ConsumeInvite(inviteID)
+	})
+}
+
+// compressContent 以 gzip 壓縮內容並以 base64 編碼，以便存入文字欄位
+func compressContent(content string) (string, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(content)); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// decompressContent 還原 compressContent 產生的內容
+func decompressContent(encoded string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// decompressMessages 還原被壓縮儲存的訊息內容
+func decompressMessages(messages []model.Message) error {
+	for i := range messages {
+		if err := decompressMessage(&messages[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decompressMessage 還原單則被壓縮儲存的訊息內容
+func decompressMessage(message *model.Message) error {
+	if !message.Compressed {
+		return nil
+	}
+
+	content, err := decompressContent(message.Content)
+	if err != nil {
+		return fmt.Errorf("解壓縮訊息 %d 失敗: %w", message.ID, err)
+	}
+	message.Content = content
+	return nil
+}
@@ -1,10 +1,14 @@
 package repository
 
 import (
+	"fmt"
 	"livechat/backend/model"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 // 測試創建新的聊天室儲存庫
@@ -51,6 +55,30 @@ func TestGetRoom(t *testing.T) {
 	assert.Equal(t, expectedRoom.Name, room.Name, "聊天室名稱應該匹配")
 }
 
+// 測試依名稱獲取聊天室
+func TestGetRoomByName(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "room-1", Name: "lobby", IsPublic: true, IsActive: true, IsListed: true}))
+	assert.NoError(t, mockDB.DB.Create(&model.Room{ID: "room-2", Name: "archived"}).Error)
+	assert.NoError(t, mockDB.DB.Model(&model.Room{ID: "room-2"}).Update("is_active", false).Error)
+
+	// 動作 (Act)
+	room, err := repo.GetRoomByName("lobby")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "獲取存在的聊天室不應該返回錯誤")
+	assert.Equal(t, "room-1", room.ID, "聊天室 ID 應該匹配")
+
+	_, err = repo.GetRoomByName("archived")
+	assert.ErrorIs(t, err, ErrRoomNotFound, "停用的聊天室不應該被找到")
+
+	_, err = repo.GetRoomByName("missing")
+	assert.ErrorIs(t, err, ErrRoomNotFound, "不存在的聊天室應該返回 ErrRoomNotFound")
+}
+
 // TestGetAllRooms 測試獲取所有聊天室功能
 //
 // 測試目標：
@@ -106,7 +134,7 @@ func TestGetAllRooms(t *testing.T) {
 	}
 
 	// 動作 (Act)：執行獲取所有聊天室查詢
-	rooms, err := repo.GetAllRooms()
+	rooms, _, err := repo.GetAllRooms(RoomOrderActivity, RoomFilter{})
 
 	// 斷言 (Assert)：驗證查詢結果
 	assert.NoError(t, err, "獲取所有聊天室不應該返回錯誤")
@@ -130,6 +158,137 @@ func TestGetAllRooms(t *testing.T) {
 	}
 }
 
+// 測試聊天室列表的排序方式
+func TestGetAllRoomsOrder(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	base := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	rooms := []*model.Room{
+		{ID: "room-b", Name: "B 聊天室", IsActive: true, IsListed: true, CreatedAt: base},
+		{ID: "room-c", Name: "C 聊天室", IsActive: true, IsListed: true, CreatedAt: base.Add(time.Hour)},
+		{ID: "room-a", Name: "A 聊天室", IsActive: true, IsListed: true, CreatedAt: base.Add(2 * time.Hour)},
+	}
+	for _, room := range rooms {
+		assert.NoError(t, repo.CreateRoom(room), "創建聊天室不應該失敗")
+	}
+
+	// room-b 最近有訊息，room-c 較早有訊息，room-a 沒有訊息
+	messages := []model.Message{
+		{Model: gorm.Model{CreatedAt: base.Add(3 * time.Hour)}, RoomID: "room-c", UserID: "user-1", Content: "較早的訊息"},
+		{Model: gorm.Model{CreatedAt: base.Add(5 * time.Hour)}, RoomID: "room-b", UserID: "user-1", Content: "最新的訊息"},
+	}
+	for i := range messages {
+		assert.NoError(t, mockDB.DB.Create(&messages[i]).Error, "插入測試訊息不應該失敗")
+	}
+
+	testCases := []struct {
+		order    RoomOrder
+		expected []string
+	}{
+		{order: RoomOrderName, expected: []string{"room-a", "room-b", "room-c"}},
+		{order: RoomOrderCreated, expected: []string{"room-a", "room-c", "room-b"}},
+		{order: RoomOrderActivity, expected: []string{"room-b", "room-c", "room-a"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.order), func(t *testing.T) {
+			// 動作 (Act)
+			result, _, err := repo.GetAllRooms(tc.order, RoomFilter{})
+
+			// 斷言 (Assert)
+			assert.NoError(t, err, "獲取聊天室不應該返回錯誤")
+			ids := make([]string, len(result))
+			for i, room := range result {
+				ids[i] = room.ID
+			}
+			assert.Equal(t, tc.expected, ids, "聊天室順序應該匹配")
+		})
+	}
+
+	_, _, err := repo.GetAllRooms("unknown", RoomFilter{})
+	assert.Equal(t, ErrInvalidRoomOrder, err, "無效的排序方式應該返回錯誤")
+}
+
+// 測試以關鍵字搜尋聊天室名稱與描述
+func TestGetAllRoomsSearch(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "golang", Name: "Golang 討論區", Description: "語言交流", IsPublic: true, IsActive: true, IsListed: true}))
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "music", Name: "音樂", Description: "分享 golang 寫的播放器", IsPublic: true, IsActive: true, IsListed: true}))
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "percent", Name: "100% 純聊天", IsPublic: true, IsActive: true, IsListed: true}))
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "other", Name: "閒聊", Description: "什麼都聊", IsPublic: true, IsActive: true, IsListed: true}))
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{name: "比對名稱與描述且不區分大小寫", query: "GOLANG", expected: []string{"golang", "music"}},
+		{name: "中文子字串", query: "聊", expected: []string{"percent", "other"}},
+		{name: "萬用字元按字面比對", query: "%", expected: []string{"percent"}},
+		{name: "底線按字面比對", query: "_", expected: []string{}},
+		{name: "沒有符合的聊天室", query: "不存在", expected: []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 動作 (Act)
+			rooms, total, err := repo.GetAllRooms(RoomOrderName, RoomFilter{Query: tc.query})
+
+			// 斷言 (Assert)
+			assert.NoError(t, err, "搜尋聊天室不應該返回錯誤")
+			ids := make([]string, 0, len(rooms))
+			for _, room := range rooms {
+				ids = append(ids, room.ID)
+			}
+			assert.ElementsMatch(t, tc.expected, ids, "搜尋結果應該匹配")
+			assert.Equal(t, int64(len(tc.expected)), total, "總數應該等於符合的聊天室數量")
+		})
+	}
+}
+
+// 測試聊天室列表的分頁邊界，總數不受分頁影響
+func TestGetAllRoomsPaging(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		assert.NoError(t, repo.CreateRoom(&model.Room{ID: "room-" + name, Name: name, IsPublic: true, IsActive: true, IsListed: true}))
+	}
+
+	testCases := []struct {
+		name     string
+		limit    int
+		offset   int
+		expected []string
+	}{
+		{name: "第一頁", limit: 2, offset: 0, expected: []string{"room-a", "room-b"}},
+		{name: "最後一頁不足一頁", limit: 2, offset: 4, expected: []string{"room-e"}},
+		{name: "超過最後一頁", limit: 2, offset: 6, expected: []string{}},
+		{name: "不分頁", expected: []string{"room-a", "room-b", "room-c", "room-d", "room-e"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 動作 (Act)
+			rooms, total, err := repo.GetAllRooms(RoomOrderName, RoomFilter{Limit: tc.limit, Offset: tc.offset})
+
+			// 斷言 (Assert)
+			assert.NoError(t, err, "獲取聊天室不應該返回錯誤")
+			ids := make([]string, 0, len(rooms))
+			for _, room := range rooms {
+				ids = append(ids, room.ID)
+			}
+			assert.Equal(t, tc.expected, ids, "分頁結果應該匹配")
+			assert.Equal(t, int64(5), total, "總數不應受分頁影響")
+		})
+	}
+}
+
 // 測試創建聊天室
 func TestCreateRoom(t *testing.T) {
 	// 安排 (Arrange) - 使用帶有完整結構的模擬資料庫
@@ -159,6 +318,271 @@ func TestCreateRoom(t *testing.T) {
 	assert.Equal(t, room.Name, createdRoom.Name, "聊天室名稱應該匹配")
 }
 
+// 測試獲取用戶創建的聊天室
+func TestGetRoomsCreatedBy(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "owned-1", Name: "聊天室一", CreatedBy: "user-1", IsPublic: true, IsActive: true, IsListed: true}))
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "owned-2", Name: "聊天室二", CreatedBy: "user-1", IsPublic: true, IsActive: true, IsListed: true}))
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "other", Name: "其他聊天室", CreatedBy: "user-2", IsPublic: true, IsActive: true, IsListed: true}))
+
+	// 動作 (Act)
+	rooms, err := repo.GetRoomsCreatedBy("user-1")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "獲取聊天室不應該返回錯誤")
+	ids := make([]string, 0, len(rooms))
+	for _, room := range rooms {
+		ids = append(ids, room.ID)
+	}
+	assert.ElementsMatch(t, []string{"owned-1", "owned-2"}, ids, "應該只返回用戶創建的聊天室")
+}
+
+// 測試用戶離開所有聊天室後不再計入活躍人數
+func TestLeaveAllRooms(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	assert.NoError(t, repo.JoinRoom("room-1", "user-1", "member"))
+	assert.NoError(t, repo.JoinRoom("room-2", "user-1", "member"))
+	assert.NoError(t, repo.JoinRoom("room-1", "user-2", "member"))
+
+	// 動作 (Act)
+	err := repo.LeaveAllRooms("user-1")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "離開所有聊天室不應該返回錯誤")
+	count, err := repo.CountActiveUsers("room-1")
+	assert.NoError(t, err, "計算活躍人數不應該返回錯誤")
+	assert.Equal(t, int64(1), count, "聊天室一應該只剩其他用戶")
+	count, err = repo.CountActiveUsers("room-2")
+	assert.NoError(t, err, "計算活躍人數不應該返回錯誤")
+	assert.Equal(t, int64(0), count, "聊天室二應該沒有活躍用戶")
+}
+
+// 測試不列出的聊天室不會出現在列表中，但仍可透過 ID 取得
+func TestUnlistedRoomHiddenFromListing(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	listedRoom := &model.Room{ID: "listed-room", Name: "公開列表聊天室", IsPublic: true, IsActive: true, IsListed: true}
+	unlistedRoom := &model.Room{ID: "unlisted-room", Name: "隱藏聊天室", IsActive: true, IsListed: false}
+	assert.NoError(t, repo.CreateRoom(listedRoom), "創建列出的聊天室不應該失敗")
+	assert.NoError(t, repo.CreateRoom(unlistedRoom), "創建不列出的聊天室不應該失敗")
+
+	// 動作 (Act)
+	rooms, _, err := repo.GetAllRooms(RoomOrderActivity, RoomFilter{})
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "獲取所有聊天室不應該返回錯誤")
+	assert.Len(t, rooms, 1, "列表中應該只有 1 個聊天室")
+	assert.Equal(t, "listed-room", rooms[0].ID, "列表中應該只有列出的聊天室")
+
+	room, err := repo.GetRoom("unlisted-room")
+	assert.NoError(t, err, "不列出的聊天室應該仍可透過 ID 取得")
+	assert.False(t, room.IsListed, "聊天室應該保持不列出狀態")
+}
+
+// 測試公開與私人聊天室混合時，只列出公開聊天室
+//
+// 測試目標：
+// 1. 創建私人聊天室時 IsPublic=false 會被正確寫入（不被資料庫預設值取代）
+// 2. publicOnly 為 true 時列表排除私人聊天室
+// 3. publicOnly 為 false 時私人聊天室仍會列出
+func TestGetAllRoomsPublicOnly(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "public-1", Name: "公開聊天室1", IsPublic: true, IsActive: true, IsListed: true}))
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "private-1", Name: "私人聊天室1", IsPublic: false, IsActive: true, IsListed: true}))
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "public-2", Name: "公開聊天室2", IsPublic: true, IsActive: true, IsListed: true}))
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "private-2", Name: "私人聊天室2", IsPublic: false, IsActive: true, IsListed: true}))
+
+	roomIDs := func(rooms []model.Room) []string {
+		ids := make([]string, 0, len(rooms))
+		for _, room := range rooms {
+			ids = append(ids, room.ID)
+		}
+		return ids
+	}
+
+	// 動作 (Act)
+	publicRooms, _, err := repo.GetAllRooms(RoomOrderName, RoomFilter{PublicOnly: true})
+	assert.NoError(t, err, "獲取公開聊天室不應該返回錯誤")
+	allRooms, _, err := repo.GetAllRooms(RoomOrderName, RoomFilter{})
+	assert.NoError(t, err, "獲取所有聊天室不應該返回錯誤")
+
+	// 斷言 (Assert)
+	assert.Equal(t, []string{"public-1", "public-2"}, roomIDs(publicRooms), "公開列表不應該包含私人聊天室")
+	assert.ElementsMatch(t, []string{"public-1", "public-2", "private-1", "private-2"}, roomIDs(allRooms), "不限公開時應該包含私人聊天室")
+
+	room, err := repo.GetRoom("private-1")
+	assert.NoError(t, err, "私人聊天室應該仍可透過 ID 取得")
+	assert.False(t, room.IsPublic, "聊天室應該保持私人狀態")
+}
+
+// 測試以單一查詢取得聊天室列表與活躍成員數，結果應與逐一計算的數量一致
+//
+// 測試資料：
+// - room-a：2 位活躍成員
+// - room-b：1 位活躍成員、1 位已離開的成員
+// - room-c：沒有任何成員，數量應為 0 而非缺漏
+func TestGetAllRoomsWithActiveCounts(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	for _, id := range []string{"room-a", "room-b", "room-c"} {
+		assert.NoError(t, repo.CreateRoom(&model.Room{ID: id, Name: id, IsPublic: true, IsActive: true, IsListed: true}))
+	}
+	assert.NoError(t, repo.JoinRoom("room-a", "user-1", "member"))
+	assert.NoError(t, repo.JoinRoom("room-a", "user-2", "member"))
+	assert.NoError(t, repo.JoinRoom("room-b", "user-1", "member"))
+	assert.NoError(t, repo.JoinRoom("room-b", "user-3", "member"))
+	assert.NoError(t, repo.LeaveRoom("room-b", "user-3"))
+
+	// 動作 (Act)
+	rooms, total, err := repo.GetAllRoomsWithActiveCounts(RoomOrderName, RoomFilter{})
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "獲取聊天室與活躍成員數不應該返回錯誤")
+	assert.Equal(t, int64(3), total, "總數應該包含所有聊天室")
+	counts := make(map[string]int64, len(rooms))
+	for _, room := range rooms {
+		expected, err := repo.CountActiveUsers(room.ID)
+		assert.NoError(t, err, "逐一計算活躍成員數不應該返回錯誤")
+		assert.Equal(t, expected, room.ActiveUsers, "活躍成員數應該與逐一計算的結果一致")
+		counts[room.ID] = room.ActiveUsers
+	}
+	assert.Equal(t, map[string]int64{"room-a": 2, "room-b": 1, "room-c": 0}, counts, "沒有活躍成員的聊天室數量應該為 0")
+	if assert.Len(t, rooms, 3) {
+		assert.Equal(t, "room-a", rooms[0].Name, "應該保留原本的排序方式")
+	}
+}
+
+// BenchmarkListRoomsActiveCounts 比較逐一查詢活躍成員數與單一分組查詢的差異，queries/op 為每次列表執行的查詢數
+func BenchmarkListRoomsActiveCounts(b *testing.B) {
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+	for i := 0; i < 50; i++ {
+		roomID := fmt.Sprintf("room-%02d", i)
+		if err := repo.CreateRoom(&model.Room{ID: roomID, Name: roomID, IsPublic: true, IsActive: true, IsListed: true}); err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < i%5; j++ {
+			if err := repo.JoinRoom(roomID, fmt.Sprintf("user-%d", j), "member"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	// Find、Count 走 Query 回呼，Scan 走 Row 回呼，兩者都需計入
+	var queries int
+	countQuery := func(*gorm.DB) { queries++ }
+	if err := mockDB.DB.Callback().Query().After("gorm:query").Register("benchmark:count_queries", countQuery); err != nil {
+		b.Fatal(err)
+	}
+	if err := mockDB.DB.Callback().Row().After("gorm:row").Register("benchmark:count_rows", countQuery); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("PerRoom", func(b *testing.B) {
+		queries = 0
+		for i := 0; i < b.N; i++ {
+			rooms, _, err := repo.GetAllRooms(RoomOrderName, RoomFilter{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, room := range rooms {
+				if _, err := repo.CountActiveUsers(room.ID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		b.ReportMetric(float64(queries)/float64(b.N), "queries/op")
+	})
+
+	b.Run("Grouped", func(b *testing.B) {
+		queries = 0
+		for i := 0; i < b.N; i++ {
+			if _, _, err := repo.GetAllRoomsWithActiveCounts(RoomOrderName, RoomFilter{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(queries)/float64(b.N), "queries/op")
+	})
+}
+
+// 測試檢查用戶是否曾是聊天室成員，已離開的成員記錄也算在內
+func TestHasRoomMembership(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	assert.NoError(t, repo.JoinRoom("room-1", "current", "member"))
+	assert.NoError(t, repo.JoinRoom("room-1", "former", "member"))
+	assert.NoError(t, repo.LeaveRoom("room-1", "former"))
+
+	// 動作 (Act)
+	current, err1 := repo.HasRoomMembership("room-1", "current")
+	former, err2 := repo.HasRoomMembership("room-1", "former")
+	stranger, err3 := repo.HasRoomMembership("room-1", "stranger")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.NoError(t, err3)
+	assert.True(t, current, "目前的成員應該有成員記錄")
+	assert.True(t, former, "已離開的成員應該仍有成員記錄")
+	assert.False(t, stranger, "從未加入的用戶不應該有成員記錄")
+}
+
+// 測試記錄與查詢聊天室封禁，封禁只對指定的聊天室有效
+func TestBanUser(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	// 動作 (Act)
+	err := repo.BanUser(&model.RoomBan{RoomID: "room-1", UserID: "user-1", BannedBy: "owner-1"})
+	banned, err1 := repo.IsUserBanned("room-1", "user-1")
+	otherRoom, err2 := repo.IsUserBanned("room-2", "user-1")
+	otherUser, err3 := repo.IsUserBanned("room-1", "user-2")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "記錄封禁不應該失敗")
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.NoError(t, err3)
+	assert.True(t, banned, "被封禁的用戶應該被識別")
+	assert.False(t, otherRoom, "封禁不應該影響其他聊天室")
+	assert.False(t, otherUser, "未被封禁的用戶不應該被識別為封禁")
+}
+
+// 測試計算活躍聊天室數量
+func TestCountActiveRooms(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "room-1", Name: "聊天室1", IsPublic: true, IsActive: true, IsListed: true}))
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "room-2", Name: "聊天室2", IsActive: true, IsListed: false}))
+	assert.NoError(t, mockDB.DB.Create(&model.Room{ID: "room-3", Name: "聊天室3"}).Error)
+	assert.NoError(t, mockDB.DB.Model(&model.Room{ID: "room-3"}).Update("is_active", false).Error)
+
+	// 動作 (Act)
+	count, err := repo.CountActiveRooms()
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "計算活躍聊天室數量不應該返回錯誤")
+	assert.Equal(t, int64(2), count, "不列出的聊天室也應該計入，停用的不計入")
+}
+
 // 測試更新聊天室
 func TestUpdateRoom(t *testing.T) {
 	// 安排 (Arrange) - 使用帶有完整結構的模擬資料庫
@@ -195,6 +619,95 @@ func TestUpdateRoom(t *testing.T) {
 	assert.Equal(t, "這是一個更新的聊天室", updatedRoom.Description, "聊天室描述應該已更新")
 }
 
+// 測試刪除聊天室後聊天室不再出現在列表中，且成員記錄都被標記為不活躍
+func TestDeleteRoom(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "room-1", Name: "待刪除聊天室", IsPublic: true, IsActive: true, IsListed: true}))
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "room-2", Name: "保留的聊天室", IsPublic: true, IsActive: true, IsListed: true}))
+	assert.NoError(t, repo.JoinRoom("room-1", "user-1", "admin"))
+	assert.NoError(t, repo.JoinRoom("room-1", "user-2", "member"))
+	assert.NoError(t, repo.JoinRoom("room-2", "user-1", "member"))
+
+	// 動作 (Act)
+	err := repo.DeleteRoom("room-1")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "刪除聊天室不應該返回錯誤")
+
+	_, err = repo.GetRoom("room-1")
+	assert.ErrorIs(t, err, ErrRoomNotFound, "已刪除的聊天室不應該能被取得")
+
+	rooms, _, err := repo.GetAllRooms(RoomOrderActivity, RoomFilter{})
+	assert.NoError(t, err, "獲取所有聊天室不應該返回錯誤")
+	if assert.Len(t, rooms, 1, "已刪除的聊天室不應該出現在列表中") {
+		assert.Equal(t, "room-2", rooms[0].ID)
+	}
+
+	count, err := repo.CountActiveUsers("room-1")
+	assert.NoError(t, err)
+	assert.Zero(t, count, "已刪除聊天室的成員記錄應該都被標記為不活躍")
+	count, err = repo.CountActiveUsers("room-2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count, "其他聊天室的成員記錄不應該受影響")
+
+	assert.ErrorIs(t, repo.DeleteRoom("room-1"), ErrRoomNotFound, "重複刪除應該返回 ErrRoomNotFound")
+}
+
+// 測試所有聊天室讀取都排除已軟刪除的聊天室
+// 直接寫入 deleted_at 而不經過 DeleteRoom，確保排除是依據軟刪除欄位而非 is_active 或成員狀態
+func TestRoomReadsExcludeSoftDeleted(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "room-1", Name: "已刪除聊天室", IsPublic: true, IsActive: true, IsListed: true, CreatedBy: "user-1"}))
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "room-2", Name: "保留的聊天室", IsPublic: true, IsActive: true, IsListed: true, CreatedBy: "user-1"}))
+	assert.NoError(t, repo.JoinRoom("room-1", "user-1", "admin"))
+	assert.NoError(t, repo.JoinRoom("room-2", "user-1", "admin"))
+
+	// 動作 (Act)
+	assert.NoError(t, mockDB.DB.Delete(&model.Room{ID: "room-1"}).Error, "軟刪除聊天室不應該失敗")
+
+	// 斷言 (Assert)
+	var deleted model.Room
+	assert.NoError(t, mockDB.DB.Unscoped().First(&deleted, "id = ?", "room-1").Error, "軟刪除的記錄應該仍保留在資料表中")
+	assert.True(t, deleted.DeletedAt.Valid, "軟刪除的聊天室應該有刪除時間")
+	assert.True(t, deleted.IsActive, "直接軟刪除不應該改變 is_active")
+
+	_, err := repo.GetRoom("room-1")
+	assert.ErrorIs(t, err, ErrRoomNotFound, "軟刪除的聊天室應該返回 ErrRoomNotFound")
+	_, err = repo.GetRoomByName("已刪除聊天室")
+	assert.ErrorIs(t, err, ErrRoomNotFound, "軟刪除的聊天室不應該能依名稱取得")
+
+	rooms, total, err := repo.GetAllRooms(RoomOrderActivity, RoomFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total, "總數不應該包含軟刪除的聊天室")
+	if assert.Len(t, rooms, 1, "軟刪除的聊天室不應該出現在列表中") {
+		assert.Equal(t, "room-2", rooms[0].ID)
+	}
+
+	counted, _, err := repo.GetAllRoomsWithActiveCounts(RoomOrderActive, RoomFilter{})
+	assert.NoError(t, err)
+	if assert.Len(t, counted, 1, "軟刪除的聊天室不應該出現在含活躍人數的列表中") {
+		assert.Equal(t, "room-2", counted[0].ID)
+	}
+
+	created, err := repo.GetRoomsCreatedBy("user-1")
+	assert.NoError(t, err)
+	assert.Len(t, created, 1, "創建者的聊天室不應該包含軟刪除的聊天室")
+
+	recent, err := repo.GetRecentRooms("user-1", 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, recent, 1, "最近的聊天室不應該包含軟刪除的聊天室")
+
+	activeRooms, err := repo.CountActiveRooms()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), activeRooms, "活躍聊天室數不應該包含軟刪除的聊天室")
+}
+
 // TestGetRoomUsers 測試獲取聊天室用戶功能
 //
 // 測試目標：
@@ -294,6 +807,35 @@ func TestGetRoomUsers(t *testing.T) {
 	}
 }
 
+// 測試獲取聊天室用戶及其用戶資料，沒有用戶記錄的成員仍會返回
+func TestGetRoomUsersWithDetails(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	joinedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, mockDB.DB.Create(&model.User{ID: "user-1", Username: "alice", DisplayName: "Alice", Email: "alice@example.com", Password: "hash"}).Error)
+	assert.NoError(t, mockDB.DB.Create(&model.RoomUser{RoomID: "room-1", UserID: "user-1", Role: "admin", IsActive: true, JoinedAt: joinedAt, LastActiveAt: joinedAt}).Error)
+	assert.NoError(t, mockDB.DB.Create(&model.RoomUser{RoomID: "room-1", UserID: "ghost", Role: "member", IsActive: true, JoinedAt: joinedAt.Add(time.Minute), LastActiveAt: joinedAt.Add(time.Minute)}).Error)
+	assert.NoError(t, repo.JoinRoom("room-1", "former", "member"))
+	assert.NoError(t, repo.LeaveRoom("room-1", "former"))
+	assert.NoError(t, repo.JoinRoom("room-2", "user-1", "member"))
+
+	// 動作 (Act)
+	details, err := repo.GetRoomUsersWithDetails("room-1")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "獲取聊天室用戶資料不應該返回錯誤")
+	assert.Len(t, details, 2, "應該只返回指定聊天室的活躍用戶")
+	assert.Equal(t, "user-1", details[0].UserID, "應該依加入時間排序")
+	assert.Equal(t, "alice", details[0].Username, "應該帶出用戶名稱")
+	assert.Equal(t, "Alice", details[0].DisplayName, "應該帶出顯示名稱")
+	assert.Equal(t, "admin", details[0].Role, "應該帶出聊天室角色")
+	assert.True(t, joinedAt.Equal(details[0].JoinedAt), "應該帶出加入時間")
+	assert.Equal(t, "ghost", details[1].UserID, "沒有用戶記錄的成員仍應該返回")
+	assert.Empty(t, details[1].Username, "沒有用戶記錄時用戶名稱應該為空")
+}
+
 // 測試用戶加入聊天室
 func TestJoinRoom(t *testing.T) {
 	// 安排 (Arrange) - 使用帶有完整結構的模擬資料庫
@@ -313,6 +855,187 @@ func TestJoinRoom(t *testing.T) {
 	assert.Equal(t, "user-123", users[0].UserID, "用戶 ID 應該匹配")
 }
 
+// 測試重複加入聊天室不會產生重複的活躍成員記錄
+func TestJoinRoomIsIdempotent(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	// 動作 (Act)
+	assert.NoError(t, repo.JoinRoom("test-room-1", "user-123", "member"), "第一次加入不應該返回錯誤")
+	assert.NoError(t, repo.JoinRoom("test-room-1", "user-123", "member"), "重複加入不應該返回錯誤")
+
+	// 斷言 (Assert)
+	count, err := repo.CountActiveUsers("test-room-1")
+	assert.NoError(t, err, "計算活躍用戶數不應該返回錯誤")
+	assert.Equal(t, int64(1), count, "重複加入不應該增加活躍用戶數")
+
+	var total int64
+	mockDB.DB.Model(&model.RoomUser{}).Where("room_id = ?", "test-room-1").Count(&total)
+	assert.Equal(t, int64(1), total, "應該只有 1 筆成員記錄")
+}
+
+// 測試離開後重新加入會重新啟用同一筆成員記錄
+func TestRejoinRoomReactivatesMembership(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	assert.NoError(t, repo.JoinRoom("test-room-1", "user-123", "member"))
+	original, err := repo.GetRoomUser("test-room-1", "user-123")
+	assert.NoError(t, err)
+	assert.NoError(t, repo.LeaveRoom("test-room-1", "user-123"))
+
+	// 動作 (Act)
+	err = repo.JoinRoom("test-room-1", "user-123", "member")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "重新加入不應該返回錯誤")
+	rejoined, err := repo.GetRoomUser("test-room-1", "user-123")
+	assert.NoError(t, err, "重新加入後應該是活躍成員")
+	assert.Equal(t, original.ID, rejoined.ID, "應該重新啟用同一筆成員記錄")
+
+	var total int64
+	mockDB.DB.Model(&model.RoomUser{}).Where("room_id = ?", "test-room-1").Count(&total)
+	assert.Equal(t, int64(1), total, "不應該新增成員記錄")
+}
+
+// 測試獲取包含已離開成員的成員記錄並分頁
+func TestGetMembershipHistory(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	assert.NoError(t, repo.JoinRoom("test-room-1", "user-1", "member"))
+	assert.NoError(t, repo.JoinRoom("test-room-1", "user-2", "member"))
+	assert.NoError(t, repo.JoinRoom("test-room-1", "user-3", "member"))
+	assert.NoError(t, repo.JoinRoom("other-room", "user-4", "member"))
+	assert.NoError(t, repo.LeaveRoom("test-room-1", "user-2"))
+
+	// 動作 (Act)
+	firstPage, err := repo.GetMembershipHistory("test-room-1", 2, 0)
+	assert.NoError(t, err, "獲取第一頁不應該返回錯誤")
+	secondPage, err := repo.GetMembershipHistory("test-room-1", 2, 2)
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "獲取第二頁不應該返回錯誤")
+	assert.Len(t, firstPage, 2, "第一頁應該有 2 筆記錄")
+	assert.Len(t, secondPage, 1, "第二頁應該有 1 筆記錄")
+
+	all := append(firstPage, secondPage...)
+	active := make(map[string]bool)
+	for _, member := range all {
+		active[member.UserID] = member.IsActive
+	}
+	assert.Equal(t, map[string]bool{"user-1": true, "user-2": false, "user-3": true}, active, "應該包含已離開的成員且不包含其他聊天室")
+}
+
+// 測試依用戶最近的活動時間排序其參與過的聊天室
+//
+// 測試目標：
+// 1. 活動時間取用戶最後發送訊息與最後活躍時間中較晚者
+// 2. 其他用戶的訊息不影響排序，未參與的聊天室與已停用的聊天室不會出現
+// 3. 已離開的聊天室仍會出現，並支援分頁
+// 4. 每個聊天室附帶目前的活躍成員數
+func TestGetRecentRooms(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+	base := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+
+	for _, id := range []string{"room-a", "room-b", "room-c", "room-other", "room-inactive"} {
+		assert.NoError(t, repo.CreateRoom(&model.Room{ID: id, Name: id, IsPublic: true, IsActive: true, IsListed: true}))
+	}
+	assert.NoError(t, mockDB.DB.Model(&model.Room{ID: "room-inactive"}).Update("is_active", false).Error)
+
+	for _, m := range []struct {
+		roomID, userID string
+		lastActive     time.Duration
+		isActive       bool
+	}{
+		{"room-a", "user-1", -2 * time.Hour, true},
+		{"room-b", "user-1", -5 * time.Minute, false}, // 已離開，但最近才活躍
+		{"room-c", "user-1", -time.Hour, true},
+		{"room-other", "user-2", 0, true},
+		{"room-inactive", "user-1", 0, true},
+	} {
+		member := &model.RoomUser{RoomID: m.roomID, UserID: m.userID, Role: "member", JoinedAt: base.Add(-3 * time.Hour), LastActiveAt: base.Add(m.lastActive), IsActive: true}
+		assert.NoError(t, mockDB.DB.Create(member).Error, "插入成員記錄不應該失敗")
+		if !m.isActive {
+			assert.NoError(t, mockDB.DB.Model(member).Update("is_active", false).Error)
+		}
+	}
+
+	for _, m := range []struct {
+		roomID, userID string
+		at             time.Duration
+	}{
+		{"room-a", "user-1", -10 * time.Minute}, // 比成員記錄的最後活躍時間更晚
+		{"room-c", "user-1", -30 * time.Minute},
+		{"room-c", "user-2", 0}, // 其他用戶的訊息不影響排序
+	} {
+		msg := &model.Message{RoomID: m.roomID, UserID: m.userID, Content: "訊息"}
+		msg.CreatedAt = base.Add(m.at)
+		assert.NoError(t, mockDB.DB.Create(msg).Error, "插入測試訊息不應該失敗")
+	}
+
+	roomIDs := func(rooms []model.RoomWithActiveCount) []string {
+		ids := make([]string, 0, len(rooms))
+		for _, room := range rooms {
+			ids = append(ids, room.ID)
+		}
+		return ids
+	}
+
+	// 動作 (Act)
+	all, err := repo.GetRecentRooms("user-1", 50, 0)
+	assert.NoError(t, err, "獲取最近的聊天室不應該返回錯誤")
+	firstPage, err := repo.GetRecentRooms("user-1", 2, 0)
+	assert.NoError(t, err, "獲取第一頁不應該返回錯誤")
+	secondPage, err := repo.GetRecentRooms("user-1", 2, 2)
+	assert.NoError(t, err, "獲取第二頁不應該返回錯誤")
+
+	// 斷言 (Assert)
+	assert.Equal(t, []string{"room-b", "room-a", "room-c"}, roomIDs(all), "聊天室應該依用戶最近的活動時間排序")
+	assert.Equal(t, []string{"room-b", "room-a"}, roomIDs(firstPage), "第一頁應該是最近的 2 個聊天室")
+	assert.Equal(t, []string{"room-c"}, roomIDs(secondPage), "第二頁應該是剩餘的聊天室")
+	activeUsers := make(map[string]int64, len(all))
+	for _, room := range all {
+		activeUsers[room.ID] = room.ActiveUsers
+	}
+	assert.Equal(t, map[string]int64{"room-b": 0, "room-a": 1, "room-c": 1}, activeUsers, "應該一併返回各聊天室的活躍成員數")
+}
+
+// 測試成員資格與在線狀態分開記錄
+//
+// 測試目標：
+// 1. 離開聊天室只標記為不在線，仍是聊天室成員
+// 2. 移出聊天室後不再是成員，重複移出返回 ErrUserNotFound
+// 3. 重新加入會清除移出記錄
+func TestKickRoomUser(t *testing.T) {
+	// 安排 (Arrange)
+	repo := NewRoomRepository(NewMockDBWithSchema())
+	assert.NoError(t, repo.JoinRoom("room-1", "user-1", "member"))
+
+	// 動作 & 斷言：離開後仍是成員
+	assert.NoError(t, repo.LeaveRoom("room-1", "user-1"))
+	member, err := repo.IsRoomMember("room-1", "user-1")
+	assert.NoError(t, err)
+	assert.True(t, member, "離開聊天室不應該影響成員資格")
+
+	// 動作 & 斷言：移出後不再是成員
+	assert.NoError(t, repo.KickRoomUser("room-1", "user-1"), "移出不在線的成員不應該失敗")
+	member, _ = repo.IsRoomMember("room-1", "user-1")
+	assert.False(t, member, "被移出的用戶不應該是成員")
+	assert.Equal(t, ErrUserNotFound, repo.KickRoomUser("room-1", "user-1"), "重複移出應該返回 ErrUserNotFound")
+	assert.Equal(t, ErrUserNotFound, repo.KickRoomUser("room-1", "stranger"), "移出非成員應該返回 ErrUserNotFound")
+
+	// 動作 & 斷言：重新加入後恢復成員資格
+	assert.NoError(t, repo.JoinRoom("room-1", "user-1", "member"))
+	member, _ = repo.IsRoomMember("room-1", "user-1")
+	assert.True(t, member, "重新加入後應該恢復成員資格")
+}
+
 // 測試用戶離開聊天室
 func TestLeaveRoom(t *testing.T) {
 	// 安排 (Arrange) - 使用帶有完整結構的模擬資料庫
@@ -370,6 +1093,332 @@ func TestGetRoomMessages(t *testing.T) {
 	assert.Contains(t, messageContents, "訊息2", "應該包含訊息2")
 }
 
+// 測試以游標分頁獲取較舊的聊天室訊息
+func TestGetRoomMessagesBefore(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	for i := 1; i <= 5; i++ {
+		msg := &model.Message{RoomID: "test-room-1", UserID: "user-1", Content: "訊息" + string(rune('0'+i))}
+		assert.NoError(t, mockDB.DB.Create(msg).Error, "插入測試訊息不應該失敗")
+	}
+	assert.NoError(t, mockDB.DB.Create(&model.Message{RoomID: "other-room", UserID: "user-1", Content: "其他"}).Error)
+
+	// 動作 (Act)
+	latest, err := repo.GetRoomMessagesBefore("test-room-1", 0, 2)
+	assert.NoError(t, err, "獲取最新訊息不應該返回錯誤")
+	older, err := repo.GetRoomMessagesBefore("test-room-1", latest[len(latest)-1].ID, 10)
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "獲取較舊訊息不應該返回錯誤")
+	assert.Len(t, latest, 2, "第一頁應該有 2 條訊息")
+	assert.Equal(t, "訊息5", latest[0].Content, "第一頁應該從最新訊息開始")
+	assert.Equal(t, "訊息4", latest[1].Content)
+	assert.Len(t, older, 3, "游標之前應該有 3 條訊息")
+	assert.Equal(t, "訊息3", older[0].Content, "較舊訊息應該從游標之前開始")
+	assert.Equal(t, "訊息1", older[2].Content)
+}
+
+// 測試在聊天室中搜尋訊息
+//
+// 測試目標：
+// 1. 只返回內容包含關鍵字的訊息，最新的在前並包含作者資料
+// 2. 比對不區分大小寫，關鍵字中的 % 與 _ 以字面比對
+// 3. 排除系統訊息、其他聊天室與已刪除的訊息
+// 4. 壓縮儲存的訊息在解壓縮後比對
+func TestSearchMessages(t *testing.T) {
+	setup := func(t *testing.T, opts ...RoomRepositoryOption) (*MockDB, *RoomRepository) {
+		mockDB := NewMockDBWithSchema()
+		repo := NewRoomRepository(mockDB, opts...)
+		assert.NoError(t, mockDB.DB.Create(&model.User{ID: "user-1", Username: "alice", DisplayName: "Alice", Email: "alice@example.com", Password: "hash"}).Error)
+		return mockDB, repo
+	}
+
+	t.Run("比對關鍵字", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockDB, repo := setup(t)
+		base := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+		messages := []model.Message{
+			{RoomID: "room-1", UserID: "user-1", Content: "明天一起吃午餐嗎"},
+			{RoomID: "room-1", UserID: "ghost", Content: "午餐我請客"},
+			{RoomID: "room-1", UserID: "user-1", Content: "晚餐見"},
+			{RoomID: "other-room", UserID: "user-1", Content: "午餐在其他聊天室"},
+		}
+		for i := range messages {
+			messages[i].CreatedAt = base.Add(time.Duration(i) * time.Minute)
+			assert.NoError(t, mockDB.DB.Create(&messages[i]).Error)
+		}
+
+		// 動作 (Act)
+		results, err := repo.SearchMessages("room-1", "午餐", 50)
+
+		// 斷言 (Assert)
+		assert.NoError(t, err, "搜尋訊息不應該返回錯誤")
+		if assert.Len(t, results, 2, "應該只返回此聊天室中包含關鍵字的訊息") {
+			assert.Equal(t, "午餐我請客", results[0].Content, "最新的訊息應該在前")
+			assert.Equal(t, "明天一起吃午餐嗎", results[1].Content)
+			assert.Equal(t, base.Add(time.Minute).Unix(), results[0].CreatedAt.Unix(), "應該包含訊息時間")
+			assert.Empty(t, results[0].Username, "找不到作者時用戶名稱應該為空")
+			assert.Equal(t, "alice", results[1].Username, "應該包含作者的用戶名稱")
+			assert.Equal(t, "Alice", results[1].DisplayName, "應該包含作者的顯示名稱")
+		}
+
+		limited, err := repo.SearchMessages("room-1", "午餐", 1)
+		assert.NoError(t, err)
+		assert.Len(t, limited, 1, "應該套用數量限制")
+	})
+
+	t.Run("不區分大小寫", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockDB, repo := setup(t)
+		assert.NoError(t, mockDB.DB.Create(&model.Message{RoomID: "room-1", UserID: "user-1", Content: "Deploy at FRIDAY"}).Error)
+		assert.NoError(t, mockDB.DB.Create(&model.Message{RoomID: "room-1", UserID: "user-1", Content: "100% done_ok"}).Error)
+		assert.NoError(t, mockDB.DB.Create(&model.Message{RoomID: "room-1", UserID: "user-1", Content: "100 done ok"}).Error)
+
+		// 動作 (Act)
+		lower, err := repo.SearchMessages("room-1", "friday", 50)
+		assert.NoError(t, err)
+		mixed, err := repo.SearchMessages("room-1", "dEpLoY", 50)
+		assert.NoError(t, err)
+		literal, err := repo.SearchMessages("room-1", "% done_", 50)
+		assert.NoError(t, err)
+
+		// 斷言 (Assert)
+		assert.Len(t, lower, 1, "小寫關鍵字應該匹配大寫內容")
+		assert.Len(t, mixed, 1, "大小寫混合的關鍵字應該匹配")
+		if assert.Len(t, literal, 1, "萬用字元應該以字面比對") {
+			assert.Equal(t, "100% done_ok", literal[0].Content)
+		}
+	})
+
+	t.Run("排除系統訊息與已刪除的訊息", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockDB, repo := setup(t)
+		assert.NoError(t, mockDB.DB.Create(&model.Message{RoomID: "room-1", Content: "alice 已加入聊天室", IsSystemMessage: true}).Error)
+		deleted := &model.Message{RoomID: "room-1", UserID: "user-1", Content: "alice 刪除的訊息"}
+		assert.NoError(t, mockDB.DB.Create(deleted).Error)
+		assert.NoError(t, repo.DeleteMessage(deleted.ID))
+		assert.NoError(t, mockDB.DB.Create(&model.Message{RoomID: "room-1", UserID: "user-1", Content: "我是 alice"}).Error)
+
+		// 動作 (Act)
+		results, err := repo.SearchMessages("room-1", "alice", 50)
+
+		// 斷言 (Assert)
+		assert.NoError(t, err)
+		if assert.Len(t, results, 1, "不應該返回系統訊息或已刪除的訊息") {
+			assert.Equal(t, "我是 alice", results[0].Content)
+		}
+	})
+
+	t.Run("壓縮儲存的訊息", func(t *testing.T) {
+		// 安排 (Arrange)
+		_, repo := setup(t, WithMessageCompression(16))
+		long := "這是一則很長的訊息，其中提到 Needle 關鍵字，因此會被壓縮儲存"
+		assert.NoError(t, repo.SaveMessage(&model.Message{RoomID: "room-1", UserID: "user-1", Content: long}))
+		assert.NoError(t, repo.SaveMessage(&model.Message{RoomID: "room-1", UserID: "user-1", Content: "另一則很長但沒有關鍵字的訊息，同樣會被壓縮儲存"}))
+
+		// 動作 (Act)
+		results, err := repo.SearchMessages("room-1", "needle", 50)
+
+		// 斷言 (Assert)
+		assert.NoError(t, err)
+		if assert.Len(t, results, 1, "應該在解壓縮後比對") {
+			assert.Equal(t, long, results[0].Content, "應該返回解壓縮後的內容")
+		}
+	})
+}
+
+// 測試聊天室的未讀訊息數
+//
+// 測試目標：
+// 1. 從未標記已讀時聊天室中其他人發送的訊息皆為未讀
+// 2. 標記已讀後未讀數歸零，之後的新訊息重新累計
+// 3. 用戶自己發送的訊息與系統訊息不算未讀，已讀位置依用戶與聊天室分開記錄
+// 4. 重複標記已讀只更新同一筆記錄，已讀位置不會倒退
+func TestUnreadCounts(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+	send := func(roomID string, userID string) {
+		assert.NoError(t, repo.SaveMessage(&model.Message{RoomID: roomID, UserID: userID, Content: "訊息"}))
+	}
+	unread := func(userID string, roomID string) int64 {
+		count, err := repo.GetUnreadCount(userID, roomID)
+		assert.NoError(t, err, "統計未讀訊息數不應該返回錯誤")
+		return count
+	}
+
+	send("room-1", "bob")
+	send("room-1", "bob")
+	send("room-1", "alice")
+	send("room-2", "bob")
+	assert.NoError(t, repo.SaveMessage(&model.Message{RoomID: "room-1", UserID: model.SystemUserID, Content: "bob 加入了聊天室", IsSystemMessage: true}))
+
+	// 動作與斷言 (Act & Assert)：從未標記已讀
+	assert.Equal(t, int64(2), unread("alice", "room-1"), "從未標記已讀時其他人的訊息皆為未讀")
+	assert.Equal(t, int64(1), unread("bob", "room-1"), "自己發送的訊息不應該算未讀")
+
+	// 標記已讀後歸零
+	assert.NoError(t, repo.MarkRead("alice", "room-1"))
+	assert.Equal(t, int64(0), unread("alice", "room-1"), "標記已讀後未讀數應該歸零")
+	assert.Equal(t, int64(1), unread("bob", "room-1"), "其他用戶的未讀數不應該受影響")
+
+	// 新訊息重新累計
+	send("room-1", "bob")
+	send("room-1", "alice")
+	send("room-1", "carol")
+	assert.Equal(t, int64(2), unread("alice", "room-1"), "已讀之後的新訊息應該累計為未讀")
+
+	// 再次標記已讀
+	assert.NoError(t, repo.MarkRead("alice", "room-1"))
+	assert.Equal(t, int64(0), unread("alice", "room-1"), "再次標記已讀後未讀數應該歸零")
+
+	counts, err := repo.GetUnreadCounts("alice", []string{"room-1", "room-2", "empty-room"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"room-1": 0, "room-2": 1, "empty-room": 0}, counts, "應該分別統計每個聊天室")
+
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "ttl-room", Name: "短暫聊天室", IsActive: true, MessageTTLSeconds: 60}))
+	assert.NoError(t, mockDB.DB.Create(&model.Message{Model: gorm.Model{CreatedAt: time.Now().Add(-time.Hour)}, RoomID: "ttl-room", UserID: "bob", Content: "已過期"}).Error)
+	send("ttl-room", "bob")
+	assert.Equal(t, int64(1), unread("alice", "ttl-room"), "過期的訊息不應該算未讀")
+
+	var markers int64
+	mockDB.DB.Model(&model.RoomReadMarker{}).Where("user_id = ? AND room_id = ?", "alice", "room-1").Count(&markers)
+	assert.Equal(t, int64(1), markers, "重複標記已讀應該更新同一筆記錄")
+
+	var marker model.RoomReadMarker
+	assert.NoError(t, mockDB.DB.Where("user_id = ? AND room_id = ?", "alice", "room-1").First(&marker).Error)
+	assert.NoError(t, mockDB.DB.Unscoped().Where("room_id = ?", "room-1").Delete(&model.Message{}).Error)
+	assert.NoError(t, repo.MarkRead("alice", "room-1"))
+	var updated model.RoomReadMarker
+	assert.NoError(t, mockDB.DB.Where("user_id = ? AND room_id = ?", "alice", "room-1").First(&updated).Error)
+	assert.Equal(t, marker.LastReadMessageID, updated.LastReadMessageID, "已讀位置不應該倒退")
+}
+
+// 測試設有訊息 TTL 的聊天室在清除前就不返回過期訊息，且清除任務只刪除過期訊息
+//
+// 測試目標：
+// 1. GetRoomMessages 與 GetRoomMessagesBefore 排除超過存活時間的訊息
+// 2. 時鐘前進後，原本未過期的訊息也會過期
+// 3. PurgeExpiredMessages 永久刪除過期訊息，不影響未設 TTL 的聊天室
+func TestMessageTTLExpiry(t *testing.T) {
+	// 安排 (Arrange)
+	mockTime := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time {
+		return mockTime
+	})
+	defer model.ResetTimeNow()
+
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "ephemeral", Name: "短暫聊天室", MessageTTLSeconds: 60}))
+	assert.NoError(t, repo.CreateRoom(&model.Room{ID: "permanent", Name: "永久聊天室"}))
+
+	for _, m := range []struct {
+		roomID  string
+		content string
+		age     time.Duration
+	}{
+		{"ephemeral", "過期訊息", 90 * time.Second},
+		{"ephemeral", "較舊訊息", 30 * time.Second},
+		{"ephemeral", "最新訊息", 5 * time.Second},
+		{"permanent", "永久訊息", 24 * time.Hour},
+	} {
+		msg := &model.Message{RoomID: m.roomID, UserID: "user-1", Content: m.content}
+		msg.CreatedAt = mockTime.Add(-m.age)
+		assert.NoError(t, mockDB.DB.Create(msg).Error, "插入測試訊息不應該失敗")
+	}
+
+	contents := func(messages []model.Message) []string {
+		result := make([]string, 0, len(messages))
+		for _, msg := range messages {
+			result = append(result, msg.Content)
+		}
+		return result
+	}
+
+	// 動作 (Act)
+	beforePurge, err := repo.GetRoomMessages("ephemeral", 50)
+	assert.NoError(t, err, "獲取訊息不應該返回錯誤")
+	paged, err := repo.GetRoomMessagesBefore("ephemeral", 0, 50)
+	assert.NoError(t, err, "以游標獲取訊息不應該返回錯誤")
+
+	mockTime = mockTime.Add(40 * time.Second)
+	afterAdvance, err := repo.GetRoomMessages("ephemeral", 50)
+	assert.NoError(t, err, "獲取訊息不應該返回錯誤")
+
+	purged, purgeErr := repo.PurgeExpiredMessages()
+
+	// 斷言 (Assert)
+	assert.ElementsMatch(t, []string{"較舊訊息", "最新訊息"}, contents(beforePurge), "過期訊息在清除前就不應該被返回")
+	assert.ElementsMatch(t, []string{"較舊訊息", "最新訊息"}, contents(paged), "游標分頁也應該排除過期訊息")
+	assert.Equal(t, []string{"最新訊息"}, contents(afterAdvance), "時鐘前進後超過 TTL 的訊息應該過期")
+
+	assert.NoError(t, purgeErr, "清除過期訊息不應該返回錯誤")
+	assert.Equal(t, int64(2), purged, "應該清除 2 條過期訊息")
+
+	var remaining []model.Message
+	mockDB.DB.Unscoped().Order("id").Find(&remaining)
+	assert.Equal(t, []string{"最新訊息", "永久訊息"}, contents(remaining), "過期訊息應該被永久刪除，未設 TTL 的聊天室不受影響")
+
+	permanent, err := repo.GetRoomMessages("permanent", 50)
+	assert.NoError(t, err, "獲取訊息不應該返回錯誤")
+	assert.Len(t, permanent, 1, "TTL 為 0 的聊天室訊息應該永久保存")
+}
+
+// 測試啟用壓縮時長訊息的壓縮儲存與讀取還原
+func TestSaveMessageCompression(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB, WithMessageCompression(64))
+
+	longContent := strings.Repeat("這是一則很長的訊息。", 50)
+	longMessage := &model.Message{RoomID: "test-room-1", UserID: "user-1", Content: longContent}
+	shortMessage := &model.Message{RoomID: "test-room-1", UserID: "user-1", Content: "短訊息"}
+
+	// 動作 (Act)
+	assert.NoError(t, repo.SaveMessage(longMessage), "保存長訊息不應該返回錯誤")
+	assert.NoError(t, repo.SaveMessage(shortMessage), "保存短訊息不應該返回錯誤")
+	messages, err := repo.GetRoomMessagesBefore("test-room-1", 0, 10)
+
+	// 斷言 (Assert)
+	assert.Equal(t, longContent, longMessage.Content, "呼叫端的訊息內容不應該被改變")
+
+	var storedLong, storedShort model.Message
+	mockDB.DB.First(&storedLong, longMessage.ID)
+	mockDB.DB.First(&storedShort, shortMessage.ID)
+	assert.True(t, storedLong.Compressed, "長訊息應該被壓縮儲存")
+	assert.Less(t, len(storedLong.Content), len(longContent), "壓縮後的內容應該較小")
+	assert.False(t, storedShort.Compressed, "短訊息不應該被壓縮")
+	assert.Equal(t, "短訊息", storedShort.Content, "短訊息應該以原文儲存")
+
+	assert.NoError(t, err, "獲取訊息不應該返回錯誤")
+	assert.Len(t, messages, 2, "應該有 2 條訊息")
+	assert.Equal(t, "短訊息", messages[0].Content)
+	assert.Equal(t, longContent, messages[1].Content, "讀取時應該透明解壓縮")
+}
+
+// 測試未啟用壓縮時長訊息以原文儲存
+func TestSaveMessageCompressionDisabled(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	longContent := strings.Repeat("a", 1000)
+	message := &model.Message{RoomID: "test-room-1", UserID: "user-1", Content: longContent}
+
+	// 動作 (Act)
+	err := repo.SaveMessage(message)
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "保存訊息不應該返回錯誤")
+	var stored model.Message
+	mockDB.DB.First(&stored, message.ID)
+	assert.False(t, stored.Compressed, "未啟用時不應該壓縮")
+	assert.Equal(t, longContent, stored.Content)
+}
+
 // 測試保存訊息
 func TestSaveMessage(t *testing.T) {
 	// 安排 (Arrange) - 使用帶有完整結構的模擬資料庫
@@ -395,6 +1444,140 @@ func TestSaveMessage(t *testing.T) {
 	assert.Equal(t, "Hello, World!", messages[0].Content, "訊息內容應該匹配")
 }
 
+// 測試獲取、編輯與軟刪除單則訊息
+func TestEditAndDeleteMessage(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB, WithMessageCompression(64))
+
+	message := &model.Message{RoomID: "test-room-1", UserID: "user-1", Content: "原始內容"}
+	assert.NoError(t, repo.SaveMessage(message))
+
+	// 動作 & 斷言：獲取訊息
+	found, err := repo.GetMessage(message.ID)
+	assert.NoError(t, err, "獲取訊息不應該返回錯誤")
+	assert.Equal(t, "user-1", found.UserID, "訊息作者應該匹配")
+
+	_, err = repo.GetMessage(9999)
+	assert.ErrorIs(t, err, ErrMessageNotFound, "不存在的訊息應該返回 ErrMessageNotFound")
+
+	// 動作 & 斷言：編輯為需要壓縮的長內容
+	longContent := strings.Repeat("編輯後的長訊息。", 50)
+	assert.NoError(t, repo.UpdateMessageContent(message.ID, longContent), "編輯訊息不應該返回錯誤")
+
+	var stored model.Message
+	mockDB.DB.First(&stored, message.ID)
+	assert.True(t, stored.Compressed, "過長的編輯內容應該被壓縮儲存")
+	found, err = repo.GetMessage(message.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, longContent, found.Content, "讀取時應該透明解壓縮")
+
+	assert.ErrorIs(t, repo.UpdateMessageContent(9999, "內容"), ErrMessageNotFound, "編輯不存在的訊息應該返回 ErrMessageNotFound")
+
+	// 動作 & 斷言：軟刪除
+	assert.NoError(t, repo.DeleteMessage(message.ID), "刪除訊息不應該返回錯誤")
+
+	_, err = repo.GetMessage(message.ID)
+	assert.ErrorIs(t, err, ErrMessageNotFound, "已刪除的訊息應該視為不存在")
+	messages, err := repo.GetRoomMessages("test-room-1", 50)
+	assert.NoError(t, err)
+	assert.Empty(t, messages, "已刪除的訊息不應該出現在訊息列表中")
+
+	var deleted model.Message
+	assert.NoError(t, mockDB.DB.Unscoped().First(&deleted, message.ID).Error, "軟刪除的訊息應該仍保留在資料庫中")
+	assert.True(t, deleted.DeletedAt.Valid, "軟刪除的訊息應該有刪除時間")
+
+	assert.ErrorIs(t, repo.DeleteMessage(message.ID), ErrMessageNotFound, "重複刪除應該返回 ErrMessageNotFound")
+}
+
+// 測試刪除用戶在所有聊天室的訊息，其他用戶的訊息保留
+func TestDeleteMessagesByUser(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	assert.NoError(t, repo.SaveMessage(&model.Message{RoomID: "room-1", UserID: "user-1", Content: "第一則"}))
+	assert.NoError(t, repo.SaveMessage(&model.Message{RoomID: "room-2", UserID: "user-1", Content: "第二則"}))
+	assert.NoError(t, repo.SaveMessage(&model.Message{RoomID: "room-1", UserID: "user-2", Content: "別人的訊息"}))
+
+	// 動作 (Act)
+	deleted, err := repo.DeleteMessagesByUser("user-1")
+	none, noneErr := repo.DeleteMessagesByUser("user-3")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "刪除用戶訊息不應該返回錯誤")
+	if assert.Len(t, deleted, 2, "應該返回被刪除的訊息") {
+		assert.Equal(t, "room-1", deleted[0].RoomID, "應該返回訊息所屬的聊天室")
+		assert.Equal(t, "room-2", deleted[1].RoomID, "應該返回訊息所屬的聊天室")
+	}
+
+	remaining, err := repo.GetRoomMessages("room-1", 50)
+	assert.NoError(t, err)
+	if assert.Len(t, remaining, 1, "其他用戶的訊息應該保留") {
+		assert.Equal(t, "user-2", remaining[0].UserID, "保留的訊息應該屬於其他用戶")
+	}
+	var count int64
+	mockDB.DB.Unscoped().Model(&model.Message{}).Where("user_id = ? AND deleted_at IS NOT NULL", "user-1").Count(&count)
+	assert.Equal(t, int64(2), count, "訊息應該被軟刪除而非永久刪除")
+
+	assert.NoError(t, noneErr, "沒有訊息的用戶不應該返回錯誤")
+	assert.Empty(t, none, "沒有訊息的用戶應該返回空列表")
+}
+
+// 測試記錄、取消與統計訊息的表情回應
+func TestMessageReactions(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	react := func(userID, emoji string) {
+		assert.NoError(t, repo.AddReaction(&model.MessageReaction{MessageID: 1, UserID: userID, Emoji: emoji}), "記錄表情回應不應該失敗")
+	}
+
+	// 動作 (Act)
+	react("user-1", "👍")
+	react("user-1", "👍")
+	react("user-2", "🎉")
+	react("user-3", "🎉")
+	react("user-2", "👍")
+	react("user-3", "❤️")
+	assert.NoError(t, repo.AddReaction(&model.MessageReaction{MessageID: 2, UserID: "user-1", Emoji: "👍"}))
+	counts, err := repo.GetReactionCounts(1)
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "統計表情回應不應該返回錯誤")
+	assert.Equal(t, []model.ReactionCount{
+		{Emoji: "👍", Count: 2},
+		{Emoji: "🎉", Count: 2},
+		{Emoji: "❤️", Count: 1},
+	}, counts, "同一用戶重複回應不應該重複計算，數量相同時先出現的表情在前")
+
+	// 動作 & 斷言：取消回應
+	assert.NoError(t, repo.RemoveReaction(1, "user-1", "👍"), "取消表情回應不應該失敗")
+	assert.ErrorIs(t, repo.RemoveReaction(1, "user-1", "👍"), ErrReactionNotFound, "重複取消應該返回 ErrReactionNotFound")
+	counts, err = repo.GetReactionCounts(1)
+	assert.NoError(t, err)
+	assert.Equal(t, model.ReactionCount{Emoji: "🎉", Count: 2}, counts[0], "取消後數量應該減少")
+	assert.Equal(t, model.ReactionCount{Emoji: "👍", Count: 1}, counts[1], "取消後數量應該減少")
+
+	react("user-1", "👍")
+	counts, err = repo.GetReactionCounts(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), counts[0].Count, "取消後應該可以再次回應")
+
+	none, err := repo.GetReactionCounts(3)
+	assert.NoError(t, err)
+	assert.Empty(t, none, "沒有回應的訊息應該返回空列表")
+
+	// 動作 & 斷言：一次載入多則訊息的回應數量
+	assert.NoError(t, repo.AddReaction(&model.MessageReaction{MessageID: 2, UserID: "user-3", Emoji: "❤️"}))
+	grouped, err := repo.GetReactionCountsForMessages([]uint{1, 2, 3})
+	assert.NoError(t, err, "統計多則訊息的回應不應該返回錯誤")
+	assert.Equal(t, counts, grouped[1], "每則訊息的回應數量應該與單獨統計相同")
+	assert.Equal(t, []model.ReactionCount{{Emoji: "👍", Count: 1}, {Emoji: "❤️", Count: 1}}, grouped[2], "應該包含其他訊息的回應數量")
+	assert.NotContains(t, grouped, uint(3), "沒有回應的訊息不應該出現在結果中")
+}
+
 // 測試計算活躍用戶數
 func TestCountActiveUsers(t *testing.T) {
 	// 安排 (Arrange) - 使用帶有完整結構的模擬資料庫
@@ -415,3 +1598,39 @@ func TestCountActiveUsers(t *testing.T) {
 	assert.NoError(t, err, "計算活躍用戶數不應該返回錯誤")
 	assert.Equal(t, int64(5), count, "活躍用戶數應該是 5")
 }
+
+// 測試邀請的創建、查詢與使用次數上限
+func TestRoomInvites(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDBWithSchema()
+	repo := NewRoomRepository(mockDB)
+
+	invite := &model.RoomInvite{
+		Token:     "invite-token",
+		RoomID:    "room-1",
+		CreatedBy: "owner-1",
+		ExpiresAt: time.Now().Add(time.Hour),
+		MaxUses:   2,
+	}
+
+	// 動作 (Act)
+	err := repo.CreateInvite(invite)
+	assert.NoError(t, err, "創建邀請不應該返回錯誤")
+
+	found, err := repo.GetInviteByToken("invite-token")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "查詢邀請不應該返回錯誤")
+	assert.Equal(t, "room-1", found.RoomID, "邀請的聊天室 ID 應該匹配")
+
+	assert.NoError(t, repo.ConsumeInvite(found.ID), "第一次使用邀請不應該失敗")
+	assert.NoError(t, repo.ConsumeInvite(found.ID), "第二次使用邀請不應該失敗")
+	assert.Equal(t, ErrInviteExhausted, repo.ConsumeInvite(found.ID), "超過使用上限應該返回 ErrInviteExhausted")
+
+	assert.Equal(t, ErrInviteExhausted, repo.JoinRoomWithInvite("room-1", "user-1", "member", 0, found.ID), "邀請已用盡時應該返回 ErrInviteExhausted")
+	_, err = repo.GetRoomUser("room-1", "user-1")
+	assert.Equal(t, ErrUserNotFound, err, "邀請已用盡時不應該留下成員記錄")
+
+	_, err = repo.GetInviteByToken("unknown")
+	assert.Equal(t, ErrInviteNotFound, err, "不存在的邀請應該返回 ErrInviteNotFound")
+}
@@ -17,6 +17,14 @@ type UserRepository interface {
 	UpdateUser(user *model.User) error
 	DeleteUser(id string) error
 	CheckUserCredentials(username, password string) (*model.User, error)
+	CountUsers() (int64, error)
+	CreatePasswordResetToken(token *model.PasswordResetToken) error
+	GetPasswordResetToken(token string) (*model.PasswordResetToken, error)
+	ConsumePasswordResetToken(id uint) error
+	CreateVerificationToken(token *model.EmailVerificationToken) error
+	GetVerificationToken(token string) (*model.EmailVerificationToken, error)
+	SaveDirectMessage(message *model.DirectMessage) error
+	GetDirectMessages(userID string, otherUserID string, limit int) ([]model.DirectMessage, error)
 }
 
 // UserDB 接口定義了 UserRepository 所需的 GORM 方法
@@ -45,15 +53,15 @@ func NewUserRepository(db UserDB) UserRepository {
 
 // CreateUser 創建一個新用戶
 func (r *UserRepositoryImpl) CreateUser(user *model.User) error {
-	// 檢查用戶名是否已存在
+	// 檢查用戶名是否已存在，已刪除帳號的用戶名不會釋出
 	var count int64
-	r.db.Model(&model.User{}).Where("username = ?", user.Username).Count(&count)
+	r.db.Model(&model.User{}).Unscoped().Where("username = ?", user.Username).Count(&count)
 	if count > 0 {
 		return ErrUserAlreadyExists
 	}
 
-	// 檢查電子郵件是否已存在
-	r.db.Model(&model.User{}).Where("email = ?", user.Email).Count(&count)
+	// 檢查電子郵件是否已存在，已刪除帳號的電子郵件不會釋出
+	r.db.Model(&model.User{}).Unscoped().Where("email = ?", user.Email).Count(&count)
 	if count > 0 {
 		return ErrEmailAlreadyExists
 	}
@@ -115,7 +123,7 @@ func (r *UserRepositoryImpl) UpdateUser(user *model.User) error {
 	return result.Error
 }
 
-// DeleteUser 刪除用戶
+// DeleteUser 軟刪除用戶，用戶名與電子郵件仍保留在資料表中，不能再用於註冊
 func (r *UserRepositoryImpl) DeleteUser(id string) error {
 	result := r.db.Delete(&model.User{}, "id = ?", id)
 	return result.Error
@@ -136,3 +144,85 @@ func (r *UserRepositoryImpl) CheckUserCredentials(username, password string) (*m
 
 	return user, nil
 }
+
+// CountUsers 計算用戶總數，不包含系統用戶
+func (r *UserRepositoryImpl) CountUsers() (int64, error) {
+	var count int64
+	result := r.db.Model(&model.User{}).Where("id <> ?", model.SystemUserID).Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return count, nil
+}
+
+// CreatePasswordResetToken 創建密碼重設令牌
+func (r *UserRepositoryImpl) CreatePasswordResetToken(token *model.PasswordResetToken) error {
+	result := r.db.Create(token)
+	return result.Error
+}
+
+// GetPasswordResetToken 根據令牌字串獲取密碼重設令牌
+func (r *UserRepositoryImpl) GetPasswordResetToken(token string) (*model.PasswordResetToken, error) {
+	var resetToken model.PasswordResetToken
+	result := r.db.First(&resetToken, "token = ?", token)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrResetTokenNotFound
+		}
+		return nil, result.Error
+	}
+	return &resetToken, nil
+}
+
+// ConsumePasswordResetToken 將令牌標記為已使用，以條件更新確保令牌只能使用一次
+func (r *UserRepositoryImpl) ConsumePasswordResetToken(id uint) error {
+	result := r.db.Model(&model.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", model.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrResetTokenUsed
+	}
+
+	return nil
+}
+
+// CreateVerificationToken 創建電子郵件驗證令牌
+func (r *UserRepositoryImpl) CreateVerificationToken(token *model.EmailVerificationToken) error {
+	result := r.db.Create(token)
+	return result.Error
+}
+
+// GetVerificationToken 根據令牌字串獲取電子郵件驗證令牌
+func (r *UserRepositoryImpl) GetVerificationToken(token string) (*model.EmailVerificationToken, error) {
+	var verificationToken model.EmailVerificationToken
+	result := r.db.First(&verificationToken, "token = ?", token)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrVerificationTokenNotFound
+		}
+		return nil, result.Error
+	}
+	return &verificationToken, nil
+}
+
+// SaveDirectMessage 保存用戶之間的私人訊息
+func (r *UserRepositoryImpl) SaveDirectMessage(message *model.DirectMessage) error {
+	result := r.db.Create(message)
+	return result.Error
+}
+
+// GetDirectMessages 獲取兩位用戶之間雙向的私人訊息（最新的在前）
+func (r *UserRepositoryImpl) GetDirectMessages(userID string, otherUserID string, limit int) ([]model.DirectMessage, error) {
+	var messages []model.DirectMessage
+	result := r.db.Where("(sender_id = ? AND recipient_id = ?) OR (sender_id = ? AND recipient_id = ?)",
+		userID, otherUserID, otherUserID, userID).
+		Order("id desc").Limit(limit).Find(&messages)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return messages, nil
+}
@@ -328,3 +328,56 @@ func TestCheckUserCredentialsInvalidPassword(t *testing.T) {
 	assert.Equal(t, ErrInvalidCredentials, err, "錯誤應為 ErrInvalidCredentials")
 	assert.Nil(t, user, "用戶應為 nil，避免洩漏資料")
 }
+
+// TestCountUsers 測試計算用戶總數
+func TestCountUsers(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDB()
+	repo := NewUserRepository(mockDB)
+
+	count, err := repo.CountUsers()
+	assert.NoError(t, err, "計算用戶數不應返回錯誤")
+	assert.Equal(t, int64(0), count, "新資料庫應該沒有用戶")
+
+	err = mockDB.DB.Create(&model.User{ID: model.SystemUserID, Username: model.SystemUsername, Email: "system@livechat.invalid", Password: "!"}).Error
+	assert.NoError(t, err, "插入系統用戶不應失敗")
+
+	count, err = repo.CountUsers()
+	assert.NoError(t, err, "計算用戶數不應返回錯誤")
+	assert.Equal(t, int64(0), count, "系統用戶不應該計入用戶數")
+
+	err = mockDB.DB.Create(&model.User{Username: "testuser", Email: "test@example.com", Password: "hash"}).Error
+	assert.NoError(t, err, "插入測試用戶不應失敗")
+
+	// 動作 (Act)
+	count, err = repo.CountUsers()
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "計算用戶數不應返回錯誤")
+	assert.Equal(t, int64(1), count, "應該有 1 個用戶")
+}
+
+// TestPasswordResetToken 測試密碼重設令牌的創建、查詢與單次使用
+func TestPasswordResetToken(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := NewMockDB()
+	repo := NewUserRepository(mockDB)
+
+	resetToken := &model.PasswordResetToken{Token: "reset-token", UserID: "1", ExpiresAt: time.Now().Add(time.Hour)}
+	err := repo.CreatePasswordResetToken(resetToken)
+	assert.NoError(t, err, "創建密碼重設令牌不應失敗")
+
+	_, err = repo.GetPasswordResetToken("unknown-token")
+	assert.Equal(t, ErrResetTokenNotFound, err, "不存在的令牌應返回 ErrResetTokenNotFound")
+
+	// 動作 (Act)
+	firstErr := repo.ConsumePasswordResetToken(resetToken.ID)
+	secondErr := repo.ConsumePasswordResetToken(resetToken.ID)
+
+	// 斷言 (Assert)
+	assert.NoError(t, firstErr, "第一次使用令牌不應失敗")
+	assert.Equal(t, ErrResetTokenUsed, secondErr, "重複使用令牌應返回 ErrResetTokenUsed")
+	stored, err := repo.GetPasswordResetToken("reset-token")
+	assert.NoError(t, err, "查詢令牌不應失敗")
+	assert.NotNil(t, stored.UsedAt, "令牌應該被標記為已使用")
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"livechat/backend/model"
+	"livechat/backend/repository"
+)
+
+var (
+	// ErrInvalidAPIKey 表示 API 金鑰不存在或已被撤銷
+	ErrInvalidAPIKey = errors.New("無效的 API 金鑰")
+	// ErrInvalidAPIKeyRole 表示 API 金鑰的角色無效，或超出綁定用戶本身的權限
+	ErrInvalidAPIKeyRole = errors.New("無效的 API 金鑰角色")
+)
+
+// apiKeyPrefix 是 API 金鑰的前綴，方便辨識外洩的金鑰
+const apiKeyPrefix = "lck_"
+
+// APIKeyRepository 定義了 API 金鑰儲存庫的接口
+type APIKeyRepository interface {
+	CreateAPIKey(key *model.APIKey) error
+	GetAPIKeyByHash(keyHash string) (*model.APIKey, error)
+	RevokeAPIKey(id uint) error
+}
+
+// APIKeyService 簽發、驗證與撤銷 API 金鑰，讓外部服務不需用戶密碼即可以綁定用戶的身分存取 API
+type APIKeyService struct {
+	keyRepo     APIKeyRepository
+	userService UserService
+}
+
+// NewAPIKeyService 創建一個新的 API 金鑰服務
+func NewAPIKeyService(keyRepo APIKeyRepository, userService UserService) *APIKeyService {
+	return &APIKeyService{
+		keyRepo:     keyRepo,
+		userService: userService,
+	}
+}
+
+// CreateKey 為用戶簽發 API 金鑰，返回只會出現這一次的金鑰明文與保存的記錄
+// role 為空時沿用用戶的角色；只有管理員可以擁有 admin 角色的金鑰
+func (s *APIKeyService) CreateKey(userID string, name string, role string) (string, *model.APIKey, error) {
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch role {
+	case "":
+		role = user.Role
+	case "user":
+	case "admin":
+		if !s.userService.IsAdmin(user) {
+			return "", nil, ErrInvalidAPIKeyRole
+		}
+	default:
+		return "", nil, ErrInvalidAPIKeyRole
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	plaintext := apiKeyPrefix + hex.EncodeToString(secret)
+
+	key := &model.APIKey{
+		Name:    name,
+		KeyHash: hashAPIKey(plaintext),
+		UserID:  user.ID,
+		Role:    role,
+	}
+	if err := s.keyRepo.CreateAPIKey(key); err != nil {
+		return "", nil, err
+	}
+
+	return plaintext, key, nil
+}
+
+// Authenticate 驗證 API 金鑰並返回綁定的用戶，用戶的角色以金鑰的角色為準
+// 綁定的用戶已不再是管理員時，admin 角色的金鑰降為一般用戶
+func (s *APIKeyService) Authenticate(plaintext string) (*model.User, error) {
+	key, err := s.keyRepo.GetAPIKeyByHash(hashAPIKey(plaintext))
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.userService.GetUserByID(key.UserID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	identity := *user
+	identity.Role = key.Role
+	if key.Role == "admin" && !s.userService.IsAdmin(user) {
+		identity.Role = "user"
+	}
+	return &identity, nil
+}
+
+// RevokeKey 撤銷 API 金鑰，撤銷後的金鑰無法再通過驗證
+func (s *APIKeyService) RevokeKey(id uint) error {
+	return s.keyRepo.RevokeAPIKey(id)
+}
+
+// hashAPIKey 計算 API 金鑰的 SHA-256 雜湊，金鑰本身為高熵的隨機值，不需要加鹽
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
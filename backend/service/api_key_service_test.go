@@ -0,0 +1,58 @@
+package service
+
+import (
+	"livechat/backend/model"
+	"livechat/backend/repository"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 測試簽發與驗證 API 金鑰
+//
+// 測試目標：
+// 1. 資料庫只保存金鑰的雜湊，不保存明文
+// 2. 未指定角色時沿用用戶的角色，一般用戶不能擁有 admin 角色的金鑰
+// 3. 用戶失去管理員身分後，admin 角色的金鑰降為一般用戶
+func TestAPIKeyService(t *testing.T) {
+	// 安排 (Arrange)
+	mockDB := repository.NewMockDB()
+	userRepo := repository.NewUserRepository(mockDB)
+	assert.NoError(t, userRepo.CreateUser(&model.User{ID: "admin-1", Username: "admin1", Email: "admin@example.com", Password: "Password1", Role: "admin"}))
+	assert.NoError(t, userRepo.CreateUser(&model.User{ID: "user-1", Username: "user1", Email: "user@example.com", Password: "Password1", Role: "user"}))
+	keyService := NewAPIKeyService(repository.NewAPIKeyRepository(mockDB), NewUserService(userRepo))
+
+	// 動作 (Act)
+	adminKey, adminRecord, err := keyService.CreateKey("admin-1", "管理機器人", "")
+	assert.NoError(t, err, "簽發金鑰不應該失敗")
+	_, _, escalateErr := keyService.CreateKey("user-1", "提權", "admin")
+	_, _, invalidErr := keyService.CreateKey("user-1", "無效角色", "owner")
+	_, _, missingErr := keyService.CreateKey("missing", "不存在的用戶", "")
+
+	// 斷言 (Assert)
+	assert.True(t, strings.HasPrefix(adminKey, apiKeyPrefix), "金鑰應該帶有前綴")
+	assert.Equal(t, "admin", adminRecord.Role, "未指定角色時應該沿用用戶的角色")
+	var stored model.APIKey
+	assert.NoError(t, mockDB.DB.First(&stored, adminRecord.ID).Error)
+	assert.NotEqual(t, adminKey, stored.KeyHash, "資料庫不應該保存金鑰明文")
+	assert.Equal(t, hashAPIKey(adminKey), stored.KeyHash, "資料庫應該保存金鑰的雜湊")
+
+	assert.ErrorIs(t, escalateErr, ErrInvalidAPIKeyRole, "一般用戶不能擁有 admin 角色的金鑰")
+	assert.ErrorIs(t, invalidErr, ErrInvalidAPIKeyRole, "無效的角色應該被拒絕")
+	assert.ErrorIs(t, missingErr, repository.ErrUserNotFound, "不存在的用戶應該返回 ErrUserNotFound")
+
+	user, err := keyService.Authenticate(adminKey)
+	assert.NoError(t, err, "有效的金鑰應該通過驗證")
+	assert.Equal(t, "admin", user.Role, "角色應該以金鑰為準")
+
+	assert.NoError(t, mockDB.DB.Model(&model.User{}).Where("id = ?", "admin-1").Update("role", "user").Error)
+	user, err = keyService.Authenticate(adminKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "user", user.Role, "用戶失去管理員身分後金鑰應該降為一般用戶")
+
+	assert.NoError(t, keyService.RevokeKey(adminRecord.ID))
+	_, err = keyService.Authenticate(adminKey)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey, "撤銷後的金鑰應該無法通過驗證")
+	assert.ErrorIs(t, keyService.RevokeKey(adminRecord.ID), repository.ErrAPIKeyNotFound, "重複撤銷應該返回 ErrAPIKeyNotFound")
+}
@@ -1,10 +1,13 @@
 package service
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"livechat/backend/metrics"
 	"livechat/backend/model"
 	"livechat/backend/repository"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -38,8 +41,13 @@ type ChatMessage struct {
 type BroadcastService struct {
 	clientRepo   *repository.ClientRepository
 	messageLog   map[string][]ChatMessage // 按聊天室 ID 組織訊息日誌
+	logMutex     sync.RWMutex             // 保護 messageLog，WebSocket 與 REST 的處理流程都會寫入
 	maxLogSize   int
 	errorHandler func(error)
+	wordFilter   *wordFilter      // 廣播前遮蔽封鎖詞，nil 表示不過濾
+	metrics      *metrics.Metrics // Prometheus 指標，nil 表示不記錄
+	reaperStop   chan struct{}    // 停止閒置連接回收任務，nil 表示未啟動
+	reaperMutex  sync.Mutex
 }
 
 // BroadcastServiceOption 定義服務選項
@@ -59,6 +67,21 @@ func WithErrorHandler(handler func(error)) BroadcastServiceOption {
 	}
 }
 
+// WithWordFilter 設置封鎖詞列表，廣播與記錄訊息前會將完整出現的封鎖詞（不分大小寫）替換為星號，
+// 列表為空時不過濾
+func WithWordFilter(words []string) BroadcastServiceOption {
+	return func(s *BroadcastService) {
+		s.wordFilter = newWordFilter(words)
+	}
+}
+
+// WithMetrics 設置記錄連接數、廣播訊息數與廣播錯誤的 Prometheus 指標
+func WithMetrics(m *metrics.Metrics) BroadcastServiceOption {
+	return func(s *BroadcastService) {
+		s.metrics = m
+	}
+}
+
 // NewBroadcastService 創建一個新的廣播服務
 func NewBroadcastService(clientRepo *repository.ClientRepository, opts ...BroadcastServiceOption) *BroadcastService {
 	service := &BroadcastService{
@@ -86,6 +109,7 @@ func (s *BroadcastService) AddClient(client *model.Client) error {
 	if err != nil {
 		return err
 	}
+	s.metrics.ConnectionAdded()
 
 	// 如果客戶端已加入聊天室，發送系統訊息通知
 	if client.RoomID != "" {
@@ -108,7 +132,11 @@ func (s *BroadcastService) AddClient(client *model.Client) error {
 
 // RemoveClient 移除一個客戶端
 func (s *BroadcastService) RemoveClient(clientID string) error {
-	return s.clientRepo.Remove(clientID)
+	if err := s.clientRepo.Remove(clientID); err != nil {
+		return err
+	}
+	s.metrics.ConnectionRemoved()
+	return nil
 }
 
 // GetClient 獲取一個客戶端
@@ -127,6 +155,8 @@ func (s *BroadcastService) BroadcastMessage(message []byte) error {
 		return ErrNoClients
 	}
 
+	message = s.filterWords(message)
+
 	// 記錄訊息
 	chatMsg := ChatMessage{
 		Type:      TextMessage,
@@ -134,14 +164,11 @@ func (s *BroadcastService) BroadcastMessage(message []byte) error {
 		Timestamp: time.Now().Unix(),
 	}
 	s.logMessage(chatMsg)
+	s.metrics.MessageBroadcast()
 
 	// 廣播訊息
 	for _, client := range clients {
-		// 使用線程安全的寫入方法
-		err := client.SafeWriteMessage(websocket.TextMessage, message)
-		if err != nil {
-			s.handleClientError(client, err)
-		}
+		s.writeToClient(client, message)
 	}
 
 	return nil
@@ -149,6 +176,12 @@ func (s *BroadcastService) BroadcastMessage(message []byte) error {
 
 // BroadcastToRoom 向特定聊天室的所有客戶端廣播消息
 func (s *BroadcastService) BroadcastToRoom(roomID string, message []byte) error {
+	return s.BroadcastToRoomExcept(roomID, "", message)
+}
+
+// BroadcastToRoomExcept 向特定聊天室中除指定客戶端外的所有客戶端廣播消息，
+// 與 BroadcastToRoom 相同地記錄到訊息歷史；excludeClientID 為空時不排除任何客戶端
+func (s *BroadcastService) BroadcastToRoomExcept(roomID string, excludeClientID string, message []byte) error {
 	if len(message) == 0 {
 		return ErrEmptyMessage
 	}
@@ -162,25 +195,27 @@ func (s *BroadcastService) BroadcastToRoom(roomID string, message []byte) error
 		return ErrNoClients
 	}
 
+	message = s.filterWords(message)
+
 	// 記錄訊息
 	chatMsg := ChatMessage{
-		Type:      TextMessage,
+		Type:      roomMessageType(message),
 		Content:   string(message),
 		RoomID:    roomID,
 		Timestamp: time.Now().Unix(),
 	}
 	s.logMessage(chatMsg)
+	s.metrics.MessageBroadcast()
 
 	// 廣播訊息到特定聊天室
 	roomClients := 0
 	for _, client := range clients {
-		if client.RoomID == roomID {
-			roomClients++
-			// 使用線程安全的寫入方法
-			err := client.SafeWriteMessage(websocket.TextMessage, message)
-			if err != nil {
-				s.handleClientError(client, err)
-			}
+		if client.RoomID != roomID {
+			continue
+		}
+		roomClients++
+		if client.ID != excludeClientID {
+			s.writeToClient(client, message)
 		}
 	}
 
@@ -191,6 +226,26 @@ func (s *BroadcastService) BroadcastToRoom(roomID string, message []byte) error
 	return nil
 }
 
+// BroadcastEventToRoom 向聊天室中除指定客戶端外的所有客戶端發送暫態事件（例如輸入中提示），
+// 事件不會記錄到訊息歷史
+func (s *BroadcastService) BroadcastEventToRoom(roomID string, excludeClientID string, message []byte) error {
+	if len(message) == 0 {
+		return ErrEmptyMessage
+	}
+
+	if roomID == "" {
+		return errors.New("聊天室 ID 不能為空")
+	}
+
+	for _, client := range s.clientRepo.GetActiveClients() {
+		if client.RoomID == roomID && client.ID != excludeClientID {
+			s.writeToClient(client, message)
+		}
+	}
+
+	return nil
+}
+
 // SendPrivateMessage 發送私人訊息給指定客戶端
 func (s *BroadcastService) SendPrivateMessage(targetID string, message []byte) error {
 	if len(message) == 0 {
@@ -202,34 +257,200 @@ func (s *BroadcastService) SendPrivateMessage(targetID string, message []byte) e
 		return err
 	}
 
-	if !client.IsActive {
+	if !client.Active() {
 		return errors.New("客戶端不活躍")
 	}
 
 	// 使用線程安全的寫入方法
 	err = client.SafeWriteMessage(websocket.TextMessage, message)
 	if err != nil {
-		s.handleClientError(client, err)
+		// 檢查後才被停用的客戶端不視為連線錯誤
+		if !errors.Is(err, model.ErrClientInactive) {
+			s.handleClientError(client, err)
+		}
 		return err
 	}
 
 	return nil
 }
 
-// GetAllMessageHistory 獲取所有訊息歷史
+// serverShutdownNotice 是伺服器關閉前發送給所有客戶端的通知，客戶端可據此稍後重新連線
+var serverShutdownNotice = []byte(`{"type":"server_shutdown"}`)
+
+// closeFrameTimeout 是寫入關閉幀的最長等待時間
+const closeFrameTimeout = time.Second
+
+// CloseAll 通知所有活躍客戶端伺服器即將關閉，發送關閉幀後關閉連接並清空客戶端儲存庫，返回被關閉的連接數
+// 閒置連接回收任務也會一併停止
+func (s *BroadcastService) CloseAll() int {
+	s.StopReaper()
+
+	clients := s.clientRepo.GetActiveClients()
+	for _, client := range clients {
+		if client.Conn == nil {
+			continue
+		}
+		client.SafeWriteMessage(websocket.TextMessage, serverShutdownNotice)
+		client.SafeWriteClose(websocket.CloseGoingAway, "server shutdown", time.Now().Add(closeFrameTimeout))
+		client.Deactivate()
+		client.Conn.Close()
+	}
+
+	for range s.clientRepo.GetAll() {
+		s.metrics.ConnectionRemoved()
+	}
+	s.clientRepo.Clear()
+
+	return len(clients)
+}
+
+// GetAllClients 返回目前所有客戶端的快照
+func (s *BroadcastService) GetAllClients() []*model.Client {
+	all := s.clientRepo.GetAll()
+	clients := make([]*model.Client, 0, len(all))
+	for _, client := range all {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// DisconnectClient 發送關閉幀後強制關閉指定客戶端的連接並將其移除，客戶端不存在時返回 repository.ErrClientNotFound
+func (s *BroadcastService) DisconnectClient(clientID string) error {
+	client, err := s.clientRepo.Get(clientID)
+	if err != nil {
+		return err
+	}
+
+	return s.closeClient(client, websocket.ClosePolicyViolation, "disconnected by admin")
+}
+
+// closeClient 發送關閉幀後關閉客戶端的連接並將其移除
+func (s *BroadcastService) closeClient(client *model.Client, code int, reason string) error {
+	if client.Conn != nil {
+		client.SafeWriteClose(code, reason, time.Now().Add(closeFrameTimeout))
+	}
+	client.Deactivate()
+	if client.Conn != nil {
+		client.Conn.Close()
+	}
+
+	// 連接關閉後其處理流程也會移除客戶端，已被移除時不視為錯誤
+	if err := s.RemoveClient(client.ID); err != nil && !errors.Is(err, repository.ErrClientNotFound) {
+		return err
+	}
+	return nil
+}
+
+// ReapIdleClients 關閉並移除最後活躍時間早於 idleTimeout 之前的客戶端，返回被回收的連接數
+// 回收後已沒有客戶端的聊天室會一併刪除訊息日誌
+func (s *BroadcastService) ReapIdleClients(idleTimeout time.Duration) int {
+	defer s.clearEmptyRoomLogs()
+
+	cutoff := model.Now().Add(-idleTimeout)
+
+	reaped := 0
+	for _, client := range s.clientRepo.GetActiveClients() {
+		if !client.LastActiveTime().Before(cutoff) {
+			continue
+		}
+		if err := s.closeClient(client, websocket.CloseGoingAway, "idle timeout"); err != nil {
+			s.errorHandler(fmt.Errorf("回收閒置客戶端 %s 失敗: %w", client.ID, err))
+			continue
+		}
+		reaped++
+	}
+	return reaped
+}
+
+// StartReaper 啟動背景任務，每隔 interval 回收閒置超過 idleTimeout 的客戶端
+// 用於清除已失去回應但未關閉的連接，可透過 StopReaper 或 CloseAll 停止
+func (s *BroadcastService) StartReaper(interval time.Duration, idleTimeout time.Duration) {
+	s.reaperMutex.Lock()
+	defer s.reaperMutex.Unlock()
+
+	if s.reaperStop != nil || interval <= 0 || idleTimeout <= 0 {
+		return
+	}
+
+	stopChan := make(chan struct{})
+	s.reaperStop = stopChan
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.ReapIdleClients(idleTimeout)
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// StopReaper 停止閒置連接回收任務
+func (s *BroadcastService) StopReaper() {
+	s.reaperMutex.Lock()
+	defer s.reaperMutex.Unlock()
+
+	if s.reaperStop != nil {
+		close(s.reaperStop)
+		s.reaperStop = nil
+	}
+}
+
+// GetAllMessageHistory 獲取所有訊息歷史的副本
 func (s *BroadcastService) GetAllMessageHistory() map[string][]ChatMessage {
-	return s.messageLog
+	s.logMutex.RLock()
+	defer s.logMutex.RUnlock()
+
+	history := make(map[string][]ChatMessage, len(s.messageLog))
+	for roomID, messages := range s.messageLog {
+		history[roomID] = append([]ChatMessage(nil), messages...)
+	}
+	return history
+}
+
+// writeToClient 寫入訊息到客戶端，略過在廣播期間被停用的客戶端
+func (s *BroadcastService) writeToClient(client *model.Client, message []byte) {
+	// 使用線程安全的寫入方法，寫入前會再次檢查客戶端是否活躍
+	err := client.SafeWriteMessage(websocket.TextMessage, message)
+	if err != nil && !errors.Is(err, model.ErrClientInactive) {
+		s.handleClientError(client, err)
+	}
 }
 
 // 處理客戶端錯誤
 func (s *BroadcastService) handleClientError(client *model.Client, err error) {
 	s.errorHandler(fmt.Errorf("客戶端 %s 錯誤: %w", client.ID, err))
+	s.metrics.BroadcastFailed()
 	client.Deactivate()
 	if client.Conn != nil {
 		client.Conn.Close()
 	}
 }
 
+// roomMessageType 判斷聊天室廣播內容在訊息日誌中的類型，加入與離開聊天室的 presence 事件記錄為系統訊息
+func roomMessageType(message []byte) MessageType {
+	var event struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(message, &event) == nil && event.Type == "presence" {
+		return SystemMessage
+	}
+	return TextMessage
+}
+
+// filterWords 遮蔽訊息中的封鎖詞，未設置封鎖詞時返回原始訊息
+func (s *BroadcastService) filterWords(message []byte) []byte {
+	if s.wordFilter == nil {
+		return message
+	}
+	return s.wordFilter.filterMessage(message)
+}
+
 // 記錄訊息
 func (s *BroadcastService) logMessage(msg ChatMessage) {
 	roomID := msg.RoomID
@@ -237,6 +458,9 @@ func (s *BroadcastService) logMessage(msg ChatMessage) {
 		roomID = "global" // 全局訊息使用 "global" 作為鍵
 	}
 
+	s.logMutex.Lock()
+	defer s.logMutex.Unlock()
+
 	// 確保聊天室的訊息日誌已初始化
 	if _, exists := s.messageLog[roomID]; !exists {
 		s.messageLog[roomID] = make([]ChatMessage, 0)
@@ -257,13 +481,43 @@ func (s *BroadcastService) GetMessageHistory(roomID string) []ChatMessage {
 		roomID = "global" // 全局訊息使用 "global" 作為鍵
 	}
 
+	s.logMutex.RLock()
+	defer s.logMutex.RUnlock()
+
 	if messages, exists := s.messageLog[roomID]; exists {
-		return messages
+		return append([]ChatMessage(nil), messages...)
 	}
 
 	return []ChatMessage{}
 }
 
+// ClearRoomLog 刪除特定聊天室的訊息日誌，用於聊天室被刪除或閒置回收時釋放記憶體
+func (s *BroadcastService) ClearRoomLog(roomID string) {
+	if roomID == "" {
+		roomID = "global" // 全局訊息使用 "global" 作為鍵
+	}
+
+	s.logMutex.Lock()
+	defer s.logMutex.Unlock()
+	delete(s.messageLog, roomID)
+}
+
+// clearEmptyRoomLogs 刪除已沒有任何活躍客戶端的聊天室的訊息日誌，全局訊息日誌不受影響
+func (s *BroadcastService) clearEmptyRoomLogs() {
+	occupied := make(map[string]bool)
+	for _, client := range s.clientRepo.GetActiveClients() {
+		occupied[client.RoomID] = true
+	}
+
+	s.logMutex.Lock()
+	defer s.logMutex.Unlock()
+	for roomID := range s.messageLog {
+		if roomID != "global" && !occupied[roomID] {
+			delete(s.messageLog, roomID)
+		}
+	}
+}
+
 // GetClientsInRoom 獲取特定聊天室的所有客戶端
 func (s *BroadcastService) GetClientsInRoom(roomID string) []*model.Client {
 	clients := s.clientRepo.GetActiveClients()
@@ -277,3 +531,19 @@ func (s *BroadcastService) GetClientsInRoom(roomID string) []*model.Client {
 
 	return roomClients
 }
+
+// GetClientsByUser 獲取登入用戶的所有連接（例如多個分頁）
+func (s *BroadcastService) GetClientsByUser(userID string) []*model.Client {
+	var userClients []*model.Client
+	if userID == "" {
+		return userClients
+	}
+
+	for _, client := range s.clientRepo.GetActiveClients() {
+		if client.UserID == userID {
+			userClients = append(userClients, client)
+		}
+	}
+
+	return userClients
+}
@@ -1,12 +1,21 @@
 package service
 
 import (
+	"encoding/json"
 	"errors"
+	"livechat/backend/metrics"
 	"livechat/backend/model"
 	"livechat/backend/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -169,3 +178,474 @@ func TestMessageLogSizeLimit(t *testing.T) {
 	globalMessages := service.GetMessageHistory("global")
 	assert.Equal(t, 3, len(globalMessages), "訊息日誌大小應該被限制為 3")
 }
+
+// 測試清除聊天室訊息日誌
+func TestClearRoomLog(t *testing.T) {
+	// 安排 (Arrange)
+	repo := repository.NewClientRepository()
+	service := NewBroadcastService(repo)
+
+	service.logMessage(ChatMessage{Type: TextMessage, Content: "Room 1", RoomID: "room-1", Timestamp: time.Now().Unix()})
+	service.logMessage(ChatMessage{Type: TextMessage, Content: "Room 2", RoomID: "room-2", Timestamp: time.Now().Unix()})
+
+	// 動作 (Act)
+	service.ClearRoomLog("room-1")
+
+	// 斷言 (Assert)
+	_, exists := service.GetAllMessageHistory()["room-1"]
+	assert.False(t, exists, "被清除的聊天室不應該保留訊息日誌項目")
+	assert.Empty(t, service.GetMessageHistory("room-1"), "被清除的聊天室不應該有歷史訊息")
+	assert.Len(t, service.GetMessageHistory("room-2"), 1, "其他聊天室的訊息日誌不應該受影響")
+}
+
+// 測試聊天室廣播在訊息日誌中的類型，presence 事件記錄為系統訊息，其他內容記錄為文字訊息
+func TestBroadcastToRoomLogsPresenceAsSystemMessage(t *testing.T) {
+	// 安排 (Arrange)
+	repo := repository.NewClientRepository()
+	service := NewBroadcastService(repo)
+
+	member := model.NewClient("member", newTestWebSocketConn(t))
+	member.SetRoomID("room-1")
+	repo.Add(member)
+
+	// 動作 (Act)
+	service.BroadcastToRoom("room-1", []byte(`{"type":"presence","event":"join","user":"Alice","roomId":"room-1"}`))
+	service.BroadcastToRoom("room-1", []byte(`{"type":"message","content":"大家好"}`))
+	service.BroadcastToRoom("room-1", []byte("純文字訊息"))
+
+	// 斷言 (Assert)
+	history := service.GetMessageHistory("room-1")
+	if assert.Len(t, history, 3, "所有廣播都應該記錄到訊息歷史") {
+		assert.Equal(t, SystemMessage, history[0].Type, "presence 事件應該記錄為系統訊息")
+		assert.Equal(t, TextMessage, history[1].Type, "JSON 聊天訊息應該記錄為文字訊息")
+		assert.Equal(t, TextMessage, history[2].Type, "純文字訊息應該記錄為文字訊息")
+	}
+}
+
+// TestWordFilter 測試廣播前遮蔽封鎖詞
+//
+// 測試目標：
+// 1. 完整出現的封鎖詞被替換為等長的星號，不分大小寫
+// 2. 封鎖詞作為其他詞的一部分出現時不被遮蔽
+// 3. JSON 聊天訊息只遮蔽 content 欄位，其他類型的 JSON 指令保持原始位元組不變
+// 4. 全域廣播同樣會被過濾
+func TestWordFilter(t *testing.T) {
+	// 安排 (Arrange)
+	repo := repository.NewClientRepository()
+	service := NewBroadcastService(repo, WithWordFilter([]string{"darn", " heck ", ""}))
+
+	member := model.NewClient("member", newTestWebSocketConn(t))
+	member.SetRoomID("room-1")
+	repo.Add(member)
+
+	broadcast := func(message string) string {
+		assert.NoError(t, service.BroadcastToRoom("room-1", []byte(message)))
+		history := service.GetMessageHistory("room-1")
+		return history[len(history)-1].Content
+	}
+
+	// 動作 & 斷言：完整的詞與大小寫
+	assert.Equal(t, "****", broadcast("darn"), "完全相同的封鎖詞應該被遮蔽")
+	assert.Equal(t, "oh ****, what the ****!", broadcast("oh DaRn, what the HECK!"), "不分大小寫的封鎖詞應該被遮蔽")
+
+	// 動作 & 斷言：作為其他詞的一部分
+	assert.Equal(t, "darned checkers darn_it", broadcast("darned checkers darn_it"), "封鎖詞作為其他詞的一部分時不應該被遮蔽")
+
+	// 動作 & 斷言：JSON 訊息
+	var chat map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(broadcast(`{"content":"darn it","sender":"darn"}`)), &chat))
+	assert.Equal(t, "**** it", chat["content"], "JSON 聊天訊息的內容應該被遮蔽")
+	assert.Equal(t, "darn", chat["sender"], "content 以外的欄位不應該被改變")
+
+	command := `{"type":"presence","event":"join","user":"darn","roomId":"room-1"}`
+	assert.Equal(t, command, broadcast(command), "指令與事件訊息應該保持原始位元組不變")
+	clean := `{"content":"你好",  "sender":"alice"}`
+	assert.Equal(t, clean, broadcast(clean), "沒有封鎖詞的 JSON 訊息應該保持原始位元組不變")
+
+	// 動作 & 斷言：全域廣播
+	assert.NoError(t, service.BroadcastMessage([]byte("darn")))
+	global := service.GetMessageHistory("")
+	assert.Equal(t, "****", global[len(global)-1].Content, "全域廣播也應該遮蔽封鎖詞")
+}
+
+// 測試廣播與連接變化會更新 Prometheus 指標
+func TestBroadcastMetrics(t *testing.T) {
+	// 安排 (Arrange)
+	registry := prometheus.NewRegistry()
+	repo := repository.NewClientRepository()
+	service := NewBroadcastService(repo, WithMetrics(metrics.New(registry)))
+
+	member := model.NewClient("member", newTestWebSocketConn(t))
+	other := model.NewClient("other", nil)
+
+	// 動作 (Act)
+	assert.NoError(t, service.AddClient(member))
+	assert.NoError(t, service.AddClient(other))
+	member.SetRoomID("room-1") // 加入後才設置聊天室，避免加入通知也被計入廣播
+	assert.NoError(t, service.BroadcastToRoom("room-1", []byte("hello")))
+	assert.NoError(t, service.RemoveClient("other"))
+	assert.Error(t, service.RemoveClient("missing"), "移除不存在的客戶端應該返回錯誤")
+
+	// 斷言 (Assert)
+	expected := `
+# HELP livechat_active_connections 目前連接中的 WebSocket 客戶端數量
+# TYPE livechat_active_connections gauge
+livechat_active_connections 1
+# HELP livechat_messages_broadcast_total 已廣播的訊息總數
+# TYPE livechat_messages_broadcast_total counter
+livechat_messages_broadcast_total 1
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"livechat_active_connections", "livechat_messages_broadcast_total")
+	assert.NoError(t, err, "指標應該反映廣播與連接變化")
+}
+
+// 測試發送聊天室暫態事件
+//
+// 測試目標：
+// 1. 事件發送給同聊天室的其他客戶端，略過發送者（發送者沒有連接，若被寫入將會失敗）
+// 2. 事件不記錄到訊息歷史
+func TestBroadcastEventToRoom(t *testing.T) {
+	// 安排 (Arrange)
+	repo := repository.NewClientRepository()
+	service := NewBroadcastService(repo)
+
+	sender := model.NewClient("sender", nil)
+	sender.SetRoomID("room-1")
+	receiver := model.NewClient("receiver", newTestWebSocketConn(t))
+	receiver.SetRoomID("room-1")
+	repo.Add(sender)
+	repo.Add(receiver)
+
+	// 動作 (Act)
+	err := service.BroadcastEventToRoom("room-1", "sender", []byte(`{"type":"typing"}`))
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "發送暫態事件不應該返回錯誤")
+	assert.True(t, receiver.Active(), "接收者應該成功收到事件")
+	assert.Empty(t, service.GetMessageHistory("room-1"), "暫態事件不應該記錄到訊息歷史")
+
+	assert.Equal(t, ErrEmptyMessage, service.BroadcastEventToRoom("room-1", "sender", []byte{}), "空事件應該返回 ErrEmptyMessage")
+	assert.Error(t, service.BroadcastEventToRoom("", "sender", []byte("event")), "未指定聊天室應該返回錯誤")
+}
+
+// 測試向聊天室廣播時排除發送者
+//
+// 測試目標：
+// 1. 訊息發送給同聊天室的其他客戶端，略過發送者（發送者沒有連接，若被寫入將會失敗）
+// 2. 與 BroadcastToRoom 相同地記錄到訊息歷史
+// 3. 聊天室中只有發送者時不視為錯誤
+func TestBroadcastToRoomExcept(t *testing.T) {
+	// 安排 (Arrange)
+	repo := repository.NewClientRepository()
+	service := NewBroadcastService(repo)
+
+	sender := model.NewClient("sender", nil)
+	sender.SetRoomID("room-1")
+	receiver := model.NewClient("receiver", newTestWebSocketConn(t))
+	receiver.SetRoomID("room-1")
+	alone := model.NewClient("alone", nil)
+	alone.SetRoomID("room-2")
+	repo.Add(sender)
+	repo.Add(receiver)
+	repo.Add(alone)
+
+	// 動作 (Act)
+	err := service.BroadcastToRoomExcept("room-1", "sender", []byte("大家好"))
+	aloneErr := service.BroadcastToRoomExcept("room-2", "alone", []byte("有人在嗎"))
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "廣播訊息不應該返回錯誤")
+	assert.True(t, sender.Active(), "發送者不應該收到自己的訊息")
+	assert.True(t, receiver.Active(), "其他客戶端應該成功收到訊息")
+	assert.Len(t, service.GetMessageHistory("room-1"), 1, "訊息應該記錄到訊息歷史")
+	assert.NoError(t, aloneErr, "聊天室中只有發送者時不應該返回錯誤")
+	assert.True(t, alone.Active(), "唯一的發送者不應該收到自己的訊息")
+}
+
+// 測試獲取登入用戶的所有連接
+func TestGetClientsByUser(t *testing.T) {
+	// 安排 (Arrange)
+	repo := repository.NewClientRepository()
+	service := NewBroadcastService(repo)
+
+	firstTab := model.NewClient("tab-1", nil)
+	firstTab.SetUserID("user-1")
+	secondTab := model.NewClient("tab-2", nil)
+	secondTab.SetUserID("user-1")
+	secondTab.SetRoomID("room-1")
+	other := model.NewClient("other", nil)
+	other.SetUserID("user-2")
+	guest := model.NewClient("guest", nil)
+	repo.Add(firstTab)
+	repo.Add(secondTab)
+	repo.Add(other)
+	repo.Add(guest)
+
+	// 動作 (Act)
+	clients := service.GetClientsByUser("user-1")
+
+	// 斷言 (Assert)
+	assert.ElementsMatch(t, []*model.Client{firstTab, secondTab}, clients, "應該返回該用戶的所有連接")
+	assert.Empty(t, service.GetClientsByUser(""), "空的用戶 ID 不應該匹配匿名連接")
+}
+
+// 創建連接到測試 WebSocket 伺服器的客戶端連接，伺服器端持續讀取並丟棄訊息
+func newTestWebSocketConn(t *testing.T) *websocket.Conn {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("連接測試 WebSocket 伺服器失敗: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// 測試關閉所有連接
+//
+// 測試目標：
+// 1. 每個客戶端先收到 server_shutdown 通知，再收到 CloseGoingAway 關閉幀
+// 2. 客戶端儲存庫被清空
+func TestCloseAll(t *testing.T) {
+	// 安排 (Arrange)
+	repo := repository.NewClientRepository()
+	service := NewBroadcastService(repo)
+
+	upgrader := websocket.Upgrader{}
+	serverConns := make(chan *websocket.Conn, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		serverConns <- conn
+	}))
+	defer server.Close()
+
+	var peers []*websocket.Conn
+	for _, id := range []string{"client-1", "client-2"} {
+		peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err != nil {
+			t.Fatalf("連接測試 WebSocket 伺服器失敗: %v", err)
+		}
+		defer peer.Close()
+		peers = append(peers, peer)
+		repo.Add(model.NewClient(id, <-serverConns))
+	}
+
+	// 動作 (Act)
+	closed := service.CloseAll()
+
+	// 斷言 (Assert)
+	assert.Equal(t, 2, closed, "應該關閉所有連接")
+	assert.Equal(t, 0, repo.Count(), "客戶端儲存庫應該被清空")
+
+	for _, peer := range peers {
+		peer.SetReadDeadline(time.Now().Add(time.Second))
+
+		_, notice, err := peer.ReadMessage()
+		assert.NoError(t, err, "關閉前應該收到通知")
+		assert.JSONEq(t, `{"type":"server_shutdown"}`, string(notice), "通知內容應該匹配")
+
+		_, _, err = peer.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "應該收到 CloseGoingAway 關閉幀")
+	}
+}
+
+// 測試列出所有客戶端與強制中斷指定的客戶端
+func TestDisconnectClient(t *testing.T) {
+	// 安排 (Arrange)
+	repo := repository.NewClientRepository()
+	service := NewBroadcastService(repo)
+
+	upgrader := websocket.Upgrader{}
+	serverConns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		serverConns <- conn
+	}))
+	defer server.Close()
+
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("連接測試 WebSocket 伺服器失敗: %v", err)
+	}
+	defer peer.Close()
+	target := model.NewClient("client-1", <-serverConns)
+	repo.Add(target)
+	repo.Add(&model.Client{ID: "client-2", IsActive: true})
+
+	// 動作 (Act)
+	before := service.GetAllClients()
+	err = service.DisconnectClient("client-1")
+	missingErr := service.DisconnectClient("unknown")
+
+	// 斷言 (Assert)
+	assert.Len(t, before, 2, "應該列出所有客戶端")
+	assert.NoError(t, err, "中斷客戶端不應返回錯誤")
+	assert.Equal(t, repository.ErrClientNotFound, missingErr, "不存在的客戶端應該返回 ErrClientNotFound")
+	assert.False(t, target.Active(), "被中斷的客戶端應該被停用")
+	if remaining := service.GetAllClients(); assert.Len(t, remaining, 1, "被中斷的客戶端應該被移除") {
+		assert.Equal(t, "client-2", remaining[0].ID)
+	}
+
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = peer.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), "應該收到 ClosePolicyViolation 關閉幀")
+}
+
+// 測試閒置連接回收
+//
+// 測試目標：
+// 1. 最後活躍時間早於閒置期限的客戶端被關閉並移除
+// 2. 期限內仍有活動的客戶端不受影響
+// 3. CloseAll 會停止回收任務
+func TestReapIdleClients(t *testing.T) {
+	// 安排 (Arrange)：以假時鐘控制最後活躍時間
+	var clockMu sync.Mutex
+	now := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time {
+		clockMu.Lock()
+		defer clockMu.Unlock()
+		return now
+	})
+	defer model.ResetTimeNow()
+	advance := func(d time.Duration) {
+		clockMu.Lock()
+		defer clockMu.Unlock()
+		now = now.Add(d)
+	}
+
+	repo := repository.NewClientRepository()
+	service := NewBroadcastService(repo)
+	idleClient := model.NewClient("idle", newTestWebSocketConn(t))
+	activeClient := model.NewClient("active", newTestWebSocketConn(t))
+	repo.Add(idleClient)
+	repo.Add(activeClient)
+
+	advance(4 * time.Minute)
+	activeClient.UpdateActivity()
+	advance(2 * time.Minute)
+
+	// 動作 (Act)
+	service.StartReaper(10*time.Millisecond, 5*time.Minute)
+
+	// 斷言 (Assert)
+	assert.Eventually(t, func() bool {
+		_, err := repo.Get("idle")
+		return errors.Is(err, repository.ErrClientNotFound)
+	}, time.Second, 10*time.Millisecond, "閒置的客戶端應該被回收")
+	assert.False(t, idleClient.Active(), "被回收的客戶端應該被停用")
+	assert.True(t, activeClient.Active(), "仍有活動的客戶端不應該被停用")
+	_, err := repo.Get("active")
+	assert.NoError(t, err, "仍有活動的客戶端不應該被移除")
+
+	assert.Equal(t, 1, service.CloseAll(), "CloseAll 應該關閉剩餘的客戶端")
+	service.reaperMutex.Lock()
+	assert.Nil(t, service.reaperStop, "CloseAll 應該停止回收任務")
+	service.reaperMutex.Unlock()
+}
+
+// 測試廣播期間被並發停用的客戶端會被乾淨地略過
+//
+// 測試目標：
+// 1. 廣播與停用同時發生時不應該產生資料競爭（使用 -race 執行）
+// 2. 被停用的客戶端不應該被當作連線錯誤處理（不呼叫錯誤處理器、不關閉連接）
+// 3. 其他客戶端的廣播不受影響
+func TestBroadcastSkipsClientDeactivatedMidBroadcast(t *testing.T) {
+	// 安排 (Arrange)
+	var mu sync.Mutex
+	var reportedErrors []error
+	repo := repository.NewClientRepository()
+	service := NewBroadcastService(repo, WithErrorHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reportedErrors = append(reportedErrors, err)
+	}))
+
+	stayingClient := model.NewClient("staying", newTestWebSocketConn(t))
+	stayingClient.SetRoomID("room-1")
+	leavingClient := model.NewClient("leaving", newTestWebSocketConn(t))
+	leavingClient.SetRoomID("room-1")
+	repo.Add(stayingClient)
+	repo.Add(leavingClient)
+
+	// 動作 (Act)：一邊持續廣播，一邊停用其中一個客戶端
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			service.BroadcastToRoom("room-1", []byte("message"))
+			service.BroadcastMessage([]byte("global message"))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		time.Sleep(time.Millisecond)
+		leavingClient.Deactivate()
+	}()
+	wg.Wait()
+
+	err := service.SendPrivateMessage("leaving", []byte("private"))
+
+	// 斷言 (Assert)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Empty(t, reportedErrors, "被停用的客戶端不應該被當作連線錯誤處理")
+	assert.False(t, leavingClient.Active(), "客戶端應該保持停用")
+	assert.True(t, stayingClient.Active(), "其他客戶端應該保持活躍")
+	assert.Error(t, err, "發送私人訊息給停用的客戶端應該返回錯誤")
+	assert.NoError(t, leavingClient.Conn.WriteMessage(websocket.TextMessage, []byte("still open")), "停用的客戶端連接不應該被廣播關閉")
+}
+
+// 測試回收閒置客戶端後，釋放已沒有活躍客戶端的聊天室的訊息日誌
+func TestReapIdleClientsClearsEmptyRoomLogs(t *testing.T) {
+	// 安排 (Arrange)
+	now := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time { return now })
+	defer model.ResetTimeNow()
+
+	repo := repository.NewClientRepository()
+	service := NewBroadcastService(repo)
+	idleClient := model.NewClient("idle", newTestWebSocketConn(t))
+	idleClient.RoomID = "room-1"
+	activeClient := model.NewClient("active", newTestWebSocketConn(t))
+	activeClient.RoomID = "room-2"
+	repo.Add(idleClient)
+	repo.Add(activeClient)
+
+	service.logMessage(ChatMessage{Type: TextMessage, Content: "Room 1", RoomID: "room-1", Timestamp: now.Unix()})
+	service.logMessage(ChatMessage{Type: TextMessage, Content: "Room 2", RoomID: "room-2", Timestamp: now.Unix()})
+	service.logMessage(ChatMessage{Type: TextMessage, Content: "Global", RoomID: "global", Timestamp: now.Unix()})
+
+	now = now.Add(10 * time.Minute)
+	activeClient.UpdateActivity()
+
+	// 動作 (Act)
+	reaped := service.ReapIdleClients(5 * time.Minute)
+
+	// 斷言 (Assert)
+	assert.Equal(t, 1, reaped, "應該只回收閒置的客戶端")
+	_, exists := service.GetAllMessageHistory()["room-1"]
+	assert.False(t, exists, "已沒有活躍客戶端的聊天室應該釋放訊息日誌")
+	assert.Len(t, service.GetMessageHistory("room-2"), 1, "仍有活躍客戶端的聊天室應該保留訊息日誌")
+	assert.Len(t, service.GetMessageHistory("global"), 1, "全局訊息日誌不應該被釋放")
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"errors"
+	"livechat/backend/model"
+	"sync"
+	"time"
+)
+
+// ErrAccountLocked 表示登入失敗次數過多，暫時無法登入
+// 鎖定只依失敗次數判斷，用戶名存在與否的響應完全相同
+var ErrAccountLocked = errors.New("登入失敗次數過多，請稍後再試")
+
+// 登入失敗限制的默認值
+const (
+	DefaultLoginMaxAttempts = 5
+	DefaultLoginWindow      = 15 * time.Minute
+)
+
+// maxTrackedLoginKeys 是記錄的用戶名與 IP 數量上限，超過時先清除已過期的記錄
+const maxTrackedLoginKeys = 10000
+
+// loginAttempts 是單一用戶名或 IP 的登入失敗記錄
+type loginAttempts struct {
+	failures     int       // 目前計算區間內的失敗次數
+	firstFailure time.Time // 目前計算區間的第一次失敗時間
+	lockedUntil  time.Time // 鎖定結束時間，零值表示未鎖定
+}
+
+// expired 檢查記錄是否已不影響登入，可以移除
+func (a *loginAttempts) expired(now time.Time, window time.Duration) bool {
+	if !a.lockedUntil.IsZero() {
+		return !now.Before(a.lockedUntil)
+	}
+	return !now.Before(a.firstFailure.Add(window))
+}
+
+// LoginLimiter 分別以用戶名與 IP 計算登入失敗次數，在 window 內失敗達 maxAttempts 次時鎖定 window 時間
+type LoginLimiter struct {
+	maxAttempts int
+	window      time.Duration
+	attempts    map[string]*loginAttempts // 以 "user:" 或 "ip:" 加上值為鍵
+	mutex       sync.Mutex
+}
+
+// NewLoginLimiter 創建一個新的登入失敗限制器，參數小於等於 0 時使用默認值
+func NewLoginLimiter(maxAttempts int, window time.Duration) *LoginLimiter {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultLoginMaxAttempts
+	}
+	if window <= 0 {
+		window = DefaultLoginWindow
+	}
+
+	return &LoginLimiter{
+		maxAttempts: maxAttempts,
+		window:      window,
+		attempts:    make(map[string]*loginAttempts),
+	}
+}
+
+// Check 檢查用戶名或 IP 是否被鎖定，鎖定時返回 ErrAccountLocked
+func (l *LoginLimiter) Check(username, ip string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := model.Now()
+	for _, key := range loginKeys(username, ip) {
+		entry, ok := l.attempts[key]
+		if !ok {
+			continue
+		}
+		if entry.expired(now, l.window) {
+			delete(l.attempts, key)
+			continue
+		}
+		if !entry.lockedUntil.IsZero() {
+			return ErrAccountLocked
+		}
+	}
+	return nil
+}
+
+// RecordFailure 記錄一次登入失敗，用戶名不存在時同樣計算
+func (l *LoginLimiter) RecordFailure(username, ip string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := model.Now()
+	if len(l.attempts) >= maxTrackedLoginKeys {
+		l.pruneLocked(now)
+	}
+
+	for _, key := range loginKeys(username, ip) {
+		entry, ok := l.attempts[key]
+		if !ok || entry.expired(now, l.window) {
+			entry = &loginAttempts{firstFailure: now}
+			l.attempts[key] = entry
+		}
+
+		entry.failures++
+		if entry.failures >= l.maxAttempts {
+			entry.lockedUntil = now.Add(l.window)
+		}
+	}
+}
+
+// RecordSuccess 在登入成功後清除用戶名的失敗記錄
+// IP 的記錄不清除，避免攻擊者以自己的帳號登入來重置同一 IP 的失敗次數
+func (l *LoginLimiter) RecordSuccess(username string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.attempts, "user:"+username)
+}
+
+// pruneLocked 移除所有已過期的記錄，呼叫前必須持有鎖
+func (l *LoginLimiter) pruneLocked(now time.Time) {
+	for key, entry := range l.attempts {
+		if entry.expired(now, l.window) {
+			delete(l.attempts, key)
+		}
+	}
+}
+
+// loginKeys 返回用戶名與 IP 對應的記錄鍵，空值不計算
+func loginKeys(username, ip string) []string {
+	keys := make([]string, 0, 2)
+	if username != "" {
+		keys = append(keys, "user:"+username)
+	}
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	return keys
+}
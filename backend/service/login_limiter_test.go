@@ -0,0 +1,68 @@
+package service
+
+import (
+	"livechat/backend/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 測試失敗次數達上限後鎖定用戶名，且不論用戶是否存在行為都相同
+func TestLoginLimiterLocksAfterMaxAttempts(t *testing.T) {
+	// 安排 (Arrange)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time { return now })
+	defer model.ResetTimeNow()
+
+	limiter := NewLoginLimiter(3, time.Minute)
+
+	// 動作 (Act)
+	for i := 0; i < 2; i++ {
+		limiter.RecordFailure("alice", "203.0.113.1")
+	}
+	beforeLimit := limiter.Check("alice", "203.0.113.2")
+	limiter.RecordFailure("alice", "203.0.113.1")
+
+	// 斷言 (Assert)
+	assert.NoError(t, beforeLimit, "未達上限前不應鎖定")
+	assert.Equal(t, ErrAccountLocked, limiter.Check("alice", "203.0.113.2"), "用戶名應該被鎖定，即使從其他 IP 登入")
+	assert.Equal(t, ErrAccountLocked, limiter.Check("bob", "203.0.113.1"), "同一 IP 應該被鎖定，即使使用其他用戶名")
+	assert.NoError(t, limiter.Check("bob", "203.0.113.2"), "其他用戶名與 IP 不應受影響")
+}
+
+// 測試鎖定在 window 後解除，且舊的失敗次數不再計算
+func TestLoginLimiterResetsAfterWindow(t *testing.T) {
+	// 安排 (Arrange)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time { return now })
+	defer model.ResetTimeNow()
+
+	limiter := NewLoginLimiter(2, time.Minute)
+	limiter.RecordFailure("alice", "203.0.113.1")
+	limiter.RecordFailure("alice", "203.0.113.1")
+	assert.Equal(t, ErrAccountLocked, limiter.Check("alice", "203.0.113.1"), "應該被鎖定")
+
+	// 動作 (Act)
+	now = now.Add(time.Minute)
+	afterWindow := limiter.Check("alice", "203.0.113.1")
+	limiter.RecordFailure("alice", "203.0.113.1")
+
+	// 斷言 (Assert)
+	assert.NoError(t, afterWindow, "window 過後應該解除鎖定")
+	assert.NoError(t, limiter.Check("alice", "203.0.113.1"), "解除鎖定後應該重新計算失敗次數")
+}
+
+// 測試登入成功後清除用戶名的失敗次數
+func TestLoginLimiterResetsOnSuccess(t *testing.T) {
+	// 安排 (Arrange)
+	limiter := NewLoginLimiter(2, time.Minute)
+	limiter.RecordFailure("alice", "203.0.113.1")
+
+	// 動作 (Act)
+	limiter.RecordSuccess("alice")
+	limiter.RecordFailure("alice", "203.0.113.2")
+
+	// 斷言 (Assert)
+	assert.NoError(t, limiter.Check("alice", "203.0.113.3"), "登入成功後用戶名的失敗次數應該重新計算")
+}
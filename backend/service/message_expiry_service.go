@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MessageExpiryRepository 定義了清除過期訊息所需的儲存庫接口
+type MessageExpiryRepository interface {
+	PurgeExpiredMessages() (int64, error)
+}
+
+// MessageExpiryService 定期清除設有訊息 TTL 的聊天室中已過期的訊息
+// 過期訊息在清除前就已不會出現在訊息查詢中，背景任務只負責回收儲存空間
+type MessageExpiryService struct {
+	repo         MessageExpiryRepository
+	errorHandler func(error)
+	stopChan     chan struct{}
+	mutex        sync.Mutex
+}
+
+// NewMessageExpiryService 創建一個新的過期訊息清除服務
+func NewMessageExpiryService(repo MessageExpiryRepository) *MessageExpiryService {
+	return &MessageExpiryService{
+		repo:         repo,
+		errorHandler: func(err error) { fmt.Println("Error:", err) },
+	}
+}
+
+// PurgeExpired 立即清除所有已過期的訊息，返回清除的訊息數量
+func (s *MessageExpiryService) PurgeExpired() (int64, error) {
+	return s.repo.PurgeExpiredMessages()
+}
+
+// Start 啟動背景任務，每隔 interval 清除一次過期訊息
+func (s *MessageExpiryService) Start(interval time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stopChan != nil || interval <= 0 {
+		return
+	}
+
+	stopChan := make(chan struct{})
+	s.stopChan = stopChan
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.PurgeExpired(); err != nil {
+					s.errorHandler(fmt.Errorf("清除過期訊息失敗: %w", err))
+				}
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止背景清除任務
+func (s *MessageExpiryService) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stopChan != nil {
+		close(s.stopChan)
+		s.stopChan = nil
+	}
+}
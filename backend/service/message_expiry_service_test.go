@@ -0,0 +1,60 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockMessageExpiryRepository 是一個模擬的過期訊息儲存庫
+type MockMessageExpiryRepository struct {
+	mock.Mock
+}
+
+func (m *MockMessageExpiryRepository) PurgeExpiredMessages() (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// 測試立即清除過期訊息並返回清除數量
+func TestPurgeExpired(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockMessageExpiryRepository)
+	mockRepo.On("PurgeExpiredMessages").Return(int64(3), nil)
+
+	service := NewMessageExpiryService(mockRepo)
+
+	// 動作 (Act)
+	purged, err := service.PurgeExpired()
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "清除過期訊息不應該返回錯誤")
+	assert.Equal(t, int64(3), purged, "應該返回清除的訊息數量")
+	mockRepo.AssertExpectations(t)
+}
+
+// 測試背景任務會定期清除過期訊息，失敗時交由錯誤處理器處理
+func TestMessageExpiryServiceStartStop(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockMessageExpiryRepository)
+	mockRepo.On("PurgeExpiredMessages").Return(int64(0), errors.New("資料庫錯誤"))
+
+	service := NewMessageExpiryService(mockRepo)
+	failures := make(chan error, 10)
+	service.errorHandler = func(err error) { failures <- err }
+
+	// 動作 (Act)
+	service.Start(10 * time.Millisecond)
+	defer service.Stop()
+
+	// 斷言 (Assert)
+	select {
+	case err := <-failures:
+		assert.Contains(t, err.Error(), "清除過期訊息失敗", "錯誤應該說明清除失敗")
+	case <-time.After(time.Second):
+		t.Fatal("背景任務應該嘗試清除過期訊息")
+	}
+}
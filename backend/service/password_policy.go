@@ -0,0 +1,53 @@
+package service
+
+import "unicode"
+
+// PasswordPolicy 定義密碼強度規則，會以 JSON 提供給前端顯示
+type PasswordPolicy struct {
+	MinLength      int  `json:"minLength"`      // 最少字元數（以位元組計）
+	RequireUpper   bool `json:"requireUpper"`   // 必須包含大寫字母
+	RequireLower   bool `json:"requireLower"`   // 必須包含小寫字母
+	RequireDigit   bool `json:"requireDigit"`   // 必須包含數字
+	RequireSpecial bool `json:"requireSpecial"` // 必須包含特殊字元（字母、數字與空白以外的字元）
+	MinCharClasses int  `json:"minCharClasses"` // 大寫字母、小寫字母、數字三者中至少需包含的種類數
+}
+
+// DefaultPasswordPolicy 是未設置密碼規則時使用的默認規則：至少 8 位，且包含大小寫字母、數字至少其 2 者
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:      8,
+	MinCharClasses: 2,
+}
+
+// Allows 檢查密碼是否符合規則
+func (p PasswordPolicy) Allows(password string) bool {
+	if len(password) < p.MinLength {
+		return false
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, char := range password {
+		switch {
+		case 'A' <= char && char <= 'Z':
+			hasUpper = true
+		case 'a' <= char && char <= 'z':
+			hasLower = true
+		case '0' <= char && char <= '9':
+			hasDigit = true
+		case !unicode.IsLetter(char) && !unicode.IsDigit(char) && !unicode.IsSpace(char):
+			hasSpecial = true
+		}
+	}
+
+	if (p.RequireUpper && !hasUpper) || (p.RequireLower && !hasLower) ||
+		(p.RequireDigit && !hasDigit) || (p.RequireSpecial && !hasSpecial) {
+		return false
+	}
+
+	classes := 0
+	for _, has := range []bool{hasUpper, hasLower, hasDigit} {
+		if has {
+			classes++
+		}
+	}
+	return classes >= p.MinCharClasses
+}
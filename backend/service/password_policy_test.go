@@ -0,0 +1,82 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 測試默認密碼規則與原本的規則一致：至少 8 位，且包含大小寫字母、數字至少其 2 者
+func TestDefaultPasswordPolicy(t *testing.T) {
+	testCases := []struct {
+		password string
+		allowed  bool
+	}{
+		{password: "Password", allowed: true},
+		{password: "password1", allowed: true},
+		{password: "PASSWORD1", allowed: true},
+		{password: "Pass1", allowed: false},
+		{password: "password", allowed: false},
+		{password: "password!@#", allowed: false},
+		{password: "Password!@#", allowed: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.password, func(t *testing.T) {
+			// 動作 (Act)
+			allowed := DefaultPasswordPolicy.Allows(tc.password)
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.allowed, allowed, "默認規則的判斷結果應該匹配")
+		})
+	}
+}
+
+// 測試個別啟用的密碼要求
+func TestPasswordPolicyRequirements(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		allowed  bool
+	}{
+		{name: "最少長度不足", policy: PasswordPolicy{MinLength: 12}, password: "Password123", allowed: false},
+		{name: "最少長度足夠", policy: PasswordPolicy{MinLength: 12}, password: "Password1234", allowed: true},
+		{name: "缺少大寫字母", policy: PasswordPolicy{RequireUpper: true}, password: "password1", allowed: false},
+		{name: "包含大寫字母", policy: PasswordPolicy{RequireUpper: true}, password: "Password1", allowed: true},
+		{name: "缺少小寫字母", policy: PasswordPolicy{RequireLower: true}, password: "PASSWORD1", allowed: false},
+		{name: "包含小寫字母", policy: PasswordPolicy{RequireLower: true}, password: "PASSWORd1", allowed: true},
+		{name: "缺少數字", policy: PasswordPolicy{RequireDigit: true}, password: "Password", allowed: false},
+		{name: "包含數字", policy: PasswordPolicy{RequireDigit: true}, password: "Password1", allowed: true},
+		{name: "缺少特殊字元", policy: PasswordPolicy{RequireSpecial: true}, password: "Password1", allowed: false},
+		{name: "包含特殊字元", policy: PasswordPolicy{RequireSpecial: true}, password: "Pass word!1", allowed: true},
+		{name: "空白不算特殊字元", policy: PasswordPolicy{RequireSpecial: true}, password: "Pass word1", allowed: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 動作 (Act)
+			allowed := tc.policy.Allows(tc.password)
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.allowed, allowed, "判斷結果應該匹配")
+		})
+	}
+}
+
+// 測試用戶服務使用設置的密碼規則並提供給前端
+func TestUserServicePasswordPolicy(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockUserRepository)
+	policy := PasswordPolicy{MinLength: 10, RequireSpecial: true, MinCharClasses: 2}
+	service := NewUserService(mockRepo, WithPasswordPolicy(policy))
+
+	// 動作 (Act)
+	_, err := service.RegisterUser("testuser", "test@example.com", "Password123")
+
+	// 斷言 (Assert)
+	assert.Equal(t, ErrWeakPassword, err, "不含特殊字元的密碼應該被拒絕")
+	assert.Equal(t, policy, service.PasswordRequirements(), "應該返回設置的密碼規則")
+	assert.Equal(t, DefaultPasswordPolicy, NewUserService(mockRepo).PasswordRequirements(), "未設置時應該使用默認規則")
+	mockRepo.AssertNotCalled(t, "CreateUser")
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"fmt"
+	"livechat/backend/model"
+	"livechat/backend/repository"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PresenceRepository 定義了在線人數快照儲存庫的接口
+type PresenceRepository interface {
+	SaveSnapshots(snapshots []model.PresenceSnapshot) error
+	GetPresenceHistory(roomID string, limit int) ([]model.PresenceSnapshot, error)
+}
+
+// OnlineCount 是全站在線人數統計
+type OnlineCount struct {
+	Users  int // 不重複的登入用戶數（同一用戶多個分頁只計一次）
+	Guests int // 匿名訪客的連線數
+}
+
+// PresenceService 定期記錄各聊天室的在線連線數快照
+type PresenceService struct {
+	clientRepo   *repository.ClientRepository
+	presenceRepo PresenceRepository
+	errorHandler func(error)
+	stopChan     chan struct{}
+	mutex        sync.Mutex
+}
+
+// NewPresenceService 創建一個新的在線人數快照服務
+func NewPresenceService(clientRepo *repository.ClientRepository, presenceRepo PresenceRepository) *PresenceService {
+	return &PresenceService{
+		clientRepo:   clientRepo,
+		presenceRepo: presenceRepo,
+		errorHandler: func(err error) { fmt.Println("Error:", err) },
+	}
+}
+
+// TakeSnapshot 統計當前每個聊天室的活躍連線數並寫入快照
+// 只記錄目前有連線的聊天室，未加入聊天室的連線不計入
+func (s *PresenceService) TakeSnapshot() error {
+	counts := make(map[string]int)
+	for _, client := range s.clientRepo.GetActiveClients() {
+		if client.RoomID == "" {
+			continue
+		}
+		counts[client.RoomID]++
+	}
+
+	capturedAt := model.Now()
+	snapshots := make([]model.PresenceSnapshot, 0, len(counts))
+	for roomID, count := range counts {
+		snapshots = append(snapshots, model.PresenceSnapshot{
+			RoomID:            roomID,
+			ActiveConnections: count,
+			CapturedAt:        capturedAt,
+		})
+	}
+
+	return s.presenceRepo.SaveSnapshots(snapshots)
+}
+
+// Start 啟動背景任務，每隔 interval 記錄一次快照
+func (s *PresenceService) Start(interval time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stopChan != nil || interval <= 0 {
+		return
+	}
+
+	stopChan := make(chan struct{})
+	s.stopChan = stopChan
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.TakeSnapshot(); err != nil {
+					s.errorHandler(fmt.Errorf("記錄在線人數快照失敗: %w", err))
+				}
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止背景快照任務
+func (s *PresenceService) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stopChan != nil {
+		close(s.stopChan)
+		s.stopChan = nil
+	}
+}
+
+// GetPresenceHistory 獲取聊天室的在線人數快照歷史
+func (s *PresenceService) GetPresenceHistory(roomID string, limit int) ([]model.PresenceSnapshot, error) {
+	return s.presenceRepo.GetPresenceHistory(roomID, limit)
+}
+
+// GetOnlineCount 統計全站的在線人數，登入用戶依用戶 ID 去重，匿名訪客以連線數計算
+func (s *PresenceService) GetOnlineCount() OnlineCount {
+	users := make(map[string]struct{})
+	guests := 0
+	for _, client := range s.clientRepo.GetActiveClients() {
+		if client.UserID == "" {
+			guests++
+			continue
+		}
+		users[client.UserID] = struct{}{}
+	}
+
+	return OnlineCount{Users: len(users), Guests: guests}
+}
+
+// GetConnections 獲取所有活躍的連接，依連接時間由舊到新排序
+func (s *PresenceService) GetConnections() []*model.Client {
+	clients := s.clientRepo.GetActiveClients()
+	sort.Slice(clients, func(i, j int) bool {
+		if clients[i].JoinedAt != clients[j].JoinedAt {
+			return clients[i].JoinedAt < clients[j].JoinedAt
+		}
+		return clients[i].ID < clients[j].ID
+	})
+	return clients
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"livechat/backend/model"
+	"livechat/backend/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPresenceRepository 是一個模擬的在線人數快照儲存庫
+type MockPresenceRepository struct {
+	mock.Mock
+}
+
+func (m *MockPresenceRepository) SaveSnapshots(snapshots []model.PresenceSnapshot) error {
+	args := m.Called(snapshots)
+	return args.Error(0)
+}
+
+func (m *MockPresenceRepository) GetPresenceHistory(roomID string, limit int) ([]model.PresenceSnapshot, error) {
+	args := m.Called(roomID, limit)
+	return args.Get(0).([]model.PresenceSnapshot), args.Error(1)
+}
+
+// 測試快照按聊天室統計活躍連線數並使用注入的時鐘
+func TestTakeSnapshot(t *testing.T) {
+	// 安排 (Arrange)
+	mockTime := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time {
+		return mockTime
+	})
+	defer model.ResetTimeNow()
+
+	clientRepo := repository.NewClientRepository()
+	for _, c := range []struct{ id, roomID string }{
+		{"c1", "room-1"}, {"c2", "room-1"}, {"c3", "room-2"}, {"c4", ""},
+	} {
+		client := model.NewClient(c.id, nil)
+		client.SetRoomID(c.roomID)
+		clientRepo.Add(client)
+	}
+	inactive := model.NewClient("c5", nil)
+	inactive.SetRoomID("room-1")
+	inactive.Deactivate()
+	clientRepo.Add(inactive)
+
+	mockRepo := new(MockPresenceRepository)
+	var saved []model.PresenceSnapshot
+	mockRepo.On("SaveSnapshots", mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(0).([]model.PresenceSnapshot)
+	}).Return(nil)
+
+	service := NewPresenceService(clientRepo, mockRepo)
+
+	// 動作 (Act)
+	err := service.TakeSnapshot()
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "記錄快照不應該返回錯誤")
+	counts := make(map[string]int)
+	for _, snapshot := range saved {
+		counts[snapshot.RoomID] = snapshot.ActiveConnections
+		assert.Equal(t, mockTime, snapshot.CapturedAt, "快照時間應該來自注入的時鐘")
+	}
+	assert.Equal(t, map[string]int{"room-1": 2, "room-2": 1}, counts, "應該只統計聊天室中的活躍連線")
+}
+
+// 測試背景任務會定期寫入快照並可停止
+func TestPresenceServiceStartStop(t *testing.T) {
+	// 安排 (Arrange)
+	clientRepo := repository.NewClientRepository()
+	client := model.NewClient("c1", nil)
+	client.SetRoomID("room-1")
+	clientRepo.Add(client)
+
+	mockRepo := new(MockPresenceRepository)
+	written := make(chan struct{}, 10)
+	mockRepo.On("SaveSnapshots", mock.Anything).Run(func(args mock.Arguments) {
+		written <- struct{}{}
+	}).Return(nil)
+
+	service := NewPresenceService(clientRepo, mockRepo)
+
+	// 動作 (Act)
+	service.Start(10 * time.Millisecond)
+	defer service.Stop()
+
+	// 斷言 (Assert)
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatal("背景任務應該寫入快照")
+	}
+}
+
+// 測試全站在線人數統計，同一用戶的多個分頁只計一次
+func TestGetOnlineCount(t *testing.T) {
+	// 安排 (Arrange)
+	clientRepo := repository.NewClientRepository()
+	for _, c := range []struct{ id, userID string }{
+		{"tab-1", "user-1"}, {"tab-2", "user-1"}, {"tab-3", "user-2"}, {"guest-1", ""}, {"guest-2", ""},
+	} {
+		client := model.NewClient(c.id, nil)
+		client.SetUserID(c.userID)
+		clientRepo.Add(client)
+	}
+	inactive := model.NewClient("tab-4", nil)
+	inactive.SetUserID("user-3")
+	inactive.Deactivate()
+	clientRepo.Add(inactive)
+
+	service := NewPresenceService(clientRepo, new(MockPresenceRepository))
+
+	// 動作 (Act)
+	count := service.GetOnlineCount()
+
+	// 斷言 (Assert)
+	assert.Equal(t, 2, count.Users, "同一用戶的多個分頁應該只計一次，且不計入不活躍連線")
+	assert.Equal(t, 2, count.Guests, "匿名訪客應該分開計算")
+}
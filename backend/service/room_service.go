@@ -1,27 +1,149 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"livechat/backend/model"
+	"livechat/backend/repository"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 定義錯誤
+var (
+	ErrNotRoomAdmin        = errors.New("需要聊天室管理員權限")
+	ErrInviteExpired       = errors.New("邀請已過期")
+	ErrRoomCapacityReached = errors.New("已達到聊天室數量上限")
+	ErrRoomFull            = repository.ErrRoomFull // 與儲存庫共用，透過邀請加入時由交易中的人數檢查返回
+	ErrRoomPrivate         = errors.New("私人聊天室需要邀請才能加入")
+	ErrUserBanned          = errors.New("你已被禁止進入此聊天室")
+	ErrCannotKickSelf      = errors.New("不能將自己移出聊天室")
+	ErrCannotKickCreator   = errors.New("不能將聊天室創建者移出聊天室")
+	ErrNotMessageAuthor    = errors.New("只有訊息作者可以編輯或刪除訊息")
+	ErrEmptyMessageContent = errors.New("訊息內容不能為空")
+	ErrInvalidReaction     = errors.New("無效的表情回應")
+	ErrInvalidInviteUses   = errors.New("無效的邀請使用次數")
+	ErrInvalidInviteTTL    = errors.New("無效的邀請有效期限")
+	ErrEmptySearchQuery    = errors.New("搜尋關鍵字不能為空")
 )
 
+// AnonymousUserName 是找不到用戶記錄的聊天室成員顯示的名稱
+const AnonymousUserName = "匿名用戶"
+
+// DefaultInviteTTL 是未指定有效期限時邀請的有效時間，設置上限時不會超過上限
+const DefaultInviteTTL = 24 * time.Hour
+
+// maxReactionLength 是表情回應的最大位元組數，足以容納由多個字元組合而成的表情符號
+const maxReactionLength = 32
+
 // RoomRepository 定義了聊天室儲存庫的接口
 type RoomRepository interface {
 	GetRoom(roomID string) (*model.Room, error)
-	GetAllRooms() ([]model.Room, error)
+	GetRoomByName(name string) (*model.Room, error)
+	GetAllRooms(order repository.RoomOrder, filter repository.RoomFilter) ([]model.Room, int64, error)
+	GetAllRoomsWithActiveCounts(order repository.RoomOrder, filter repository.RoomFilter) ([]model.RoomWithActiveCount, int64, error)
 	CreateRoom(room *model.Room) error
 	UpdateRoom(room *model.Room) error
+	GetRoomsCreatedBy(userID string) ([]model.Room, error)
+	LeaveAllRooms(userID string) error
+	DeleteRoom(roomID string) error
 	GetRoomUsers(roomID string) ([]model.RoomUser, error)
+	GetRoomUsersWithDetails(roomID string) ([]model.RoomUserDetail, error)
 	JoinRoom(roomID string, userID string, role string) error
 	LeaveRoom(roomID string, userID string) error
 	UpdateUserActivity(roomID string, userID string) error
 	GetRoomMessages(roomID string, limit int) ([]model.Message, error)
+	GetRoomMessagesBefore(roomID string, beforeID uint, limit int) ([]model.Message, error)
+	SearchMessages(roomID string, query string, limit int) ([]model.MessageSearchResult, error)
 	SaveMessage(message *model.Message) error
+	GetMessage(messageID uint) (*model.Message, error)
+	UpdateMessageContent(messageID uint, content string) error
+	DeleteMessage(messageID uint) error
+	DeleteMessagesByUser(userID string) ([]model.Message, error)
+	AddReaction(reaction *model.MessageReaction) error
+	RemoveReaction(messageID uint, userID string, emoji string) error
+	GetReactionCounts(messageID uint) ([]model.ReactionCount, error)
+	GetReactionCountsForMessages(messageIDs []uint) (map[uint][]model.ReactionCount, error)
 	CountActiveUsers(roomID string) (int64, error)
+	CountActiveRooms() (int64, error)
+	GetRoomUser(roomID string, userID string) (*model.RoomUser, error)
+	HasRoomMembership(roomID string, userID string) (bool, error)
+	IsRoomMember(roomID string, userID string) (bool, error)
+	KickRoomUser(roomID string, userID string) error
+	BanUser(ban *model.RoomBan) error
+	IsUserBanned(roomID string, userID string) (bool, error)
+	GetMembershipHistory(roomID string, limit int, offset int) ([]model.RoomUser, error)
+	GetRecentRooms(userID string, limit int, offset int) ([]model.RoomWithActiveCount, error)
+	MarkRead(userID string, roomID string) error
+	GetUnreadCount(userID string, roomID string) (int64, error)
+	GetUnreadCounts(userID string, roomIDs []string) (map[string]int64, error)
+	CreateInvite(invite *model.RoomInvite) error
+	GetInviteByToken(token string) (*model.RoomInvite, error)
+	JoinRoomWithInvite(roomID string, userID string, role string, maxUsers int, inviteID uint) error
 }
 
 // RoomService 處理聊天室的業務邏輯
 type RoomService struct {
-	roomRepo RoomRepository
+	roomRepo                 RoomRepository
+	maxRooms                 int           // 活躍聊天室數量上限，0 表示不限制
+	moderatorDeletesMessages bool          // 聊天室創建者與管理員是否可以刪除他人的訊息
+	maxInviteUses            int           // 邀請最大使用次數的上限，0 表示不限制
+	maxInviteTTL             time.Duration // 邀請有效期限的上限，0 表示不限制
+	ownerDeletionPolicy      OwnerDeletionPolicy
+	wordFilter               *wordFilter // 保存訊息前遮蔽封鎖詞，nil 表示不過濾
+}
+
+// OwnerDeletionPolicy 決定聊天室創建者帳號被刪除時如何處理其聊天室
+type OwnerDeletionPolicy string
+
+const (
+	// OwnerDeletionReassign 將擁有權轉移給最早加入的聊天室管理員，沒有管理員時標記為無擁有者
+	OwnerDeletionReassign OwnerDeletionPolicy = "reassign"
+	// OwnerDeletionOrphan 一律將聊天室標記為無擁有者（CreatedBy 為空），由聊天室管理員繼續管理
+	OwnerDeletionOrphan OwnerDeletionPolicy = "orphan"
+)
+
+// RoomServiceOption 定義聊天室服務選項
+type RoomServiceOption func(*RoomService)
+
+// WithMaxRooms 設置伺服器上活躍聊天室的數量上限
+func WithMaxRooms(maxRooms int) RoomServiceOption {
+	return func(s *RoomService) {
+		s.maxRooms = maxRooms
+	}
+}
+
+// WithInviteLimits 設置創建邀請時允許的最大使用次數與最長有效期限，0 表示不限制
+// 設置使用次數上限後不能再創建不限使用次數的邀請
+func WithInviteLimits(maxUses int, maxTTL time.Duration) RoomServiceOption {
+	return func(s *RoomService) {
+		s.maxInviteUses = maxUses
+		s.maxInviteTTL = maxTTL
+	}
+}
+
+// WithOwnerDeletionPolicy 設置聊天室創建者帳號被刪除時的處理方式，默認為 OwnerDeletionReassign
+func WithOwnerDeletionPolicy(policy OwnerDeletionPolicy) RoomServiceOption {
+	return func(s *RoomService) {
+		s.ownerDeletionPolicy = policy
+	}
+}
+
+// WithModeratorMessageDeletion 設置聊天室創建者與管理員是否可以刪除他人的訊息，編輯仍只限訊息作者
+func WithModeratorMessageDeletion(allow bool) RoomServiceOption {
+	return func(s *RoomService) {
+		s.moderatorDeletesMessages = allow
+	}
+}
+
+// WithMessageWordFilter 設置封鎖詞列表，發送與編輯的訊息在保存前會將封鎖詞替換為星號，
+// 規則與 WithWordFilter 相同，列表為空時不過濾
+func WithMessageWordFilter(words []string) RoomServiceOption {
+	return func(s *RoomService) {
+		s.wordFilter = newWordFilter(words)
+	}
 }
 
 // RoomData 包含創建聊天室所需的數據
@@ -30,13 +152,26 @@ type RoomData struct {
 	Description string
 	IsPublic    bool
 	MaxUsers    int
+	IsListed    bool
+	// MessageTTLSeconds 訊息的存活秒數，0 表示永久保存
+	MessageTTLSeconds int
+	// BypassRoomLimit 為 true 時略過聊天室數量上限（例如管理員創建）
+	BypassRoomLimit bool
 }
 
 // NewRoomService 創建一個新的聊天室服務
-func NewRoomService(roomRepo RoomRepository) *RoomService {
-	return &RoomService{
-		roomRepo: roomRepo,
+func NewRoomService(roomRepo RoomRepository, opts ...RoomServiceOption) *RoomService {
+	s := &RoomService{
+		roomRepo:            roomRepo,
+		ownerDeletionPolicy: OwnerDeletionReassign,
+	}
+
+	// 應用選項
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
 }
 
 // GetRoom 獲取指定的聊天室
@@ -44,20 +179,43 @@ func (s *RoomService) GetRoom(roomID string) (*model.Room, error) {
 	return s.roomRepo.GetRoom(roomID)
 }
 
-// GetAllRooms 獲取所有聊天室
-func (s *RoomService) GetAllRooms() ([]model.Room, error) {
-	return s.roomRepo.GetAllRooms()
+// GetRoomByName 依名稱獲取聊天室
+func (s *RoomService) GetRoomByName(name string) (*model.Room, error) {
+	return s.roomRepo.GetRoomByName(name)
+}
+
+// GetAllRooms 依指定的排序方式與過濾條件獲取聊天室，同時返回符合條件的總數
+func (s *RoomService) GetAllRooms(order repository.RoomOrder, filter repository.RoomFilter) ([]model.Room, int64, error) {
+	return s.roomRepo.GetAllRooms(order, filter)
+}
+
+// GetAllRoomsWithActiveCounts 與 GetAllRooms 相同，但一併返回各聊天室的活躍成員數
+func (s *RoomService) GetAllRoomsWithActiveCounts(order repository.RoomOrder, filter repository.RoomFilter) ([]model.RoomWithActiveCount, int64, error) {
+	return s.roomRepo.GetAllRoomsWithActiveCounts(order, filter)
 }
 
 // CreateRoom 創建一個新的聊天室
 func (s *RoomService) CreateRoom(data RoomData, createdBy string) (*model.Room, error) {
+	// 檢查聊天室數量上限
+	if s.maxRooms > 0 && !data.BypassRoomLimit {
+		count, err := s.roomRepo.CountActiveRooms()
+		if err != nil {
+			return nil, err
+		}
+		if count >= int64(s.maxRooms) {
+			return nil, ErrRoomCapacityReached
+		}
+	}
+
 	room := &model.Room{
-		Name:        data.Name,
-		Description: data.Description,
-		IsPublic:    data.IsPublic,
-		MaxUsers:    data.MaxUsers,
-		CreatedBy:   createdBy,
-		IsActive:    true,
+		Name:              data.Name,
+		Description:       data.Description,
+		IsPublic:          data.IsPublic,
+		MaxUsers:          data.MaxUsers,
+		CreatedBy:         createdBy,
+		IsActive:          true,
+		IsListed:          data.IsListed,
+		MessageTTLSeconds: data.MessageTTLSeconds,
 	}
 
 	err := s.roomRepo.CreateRoom(room)
@@ -68,14 +226,61 @@ func (s *RoomService) CreateRoom(data RoomData, createdBy string) (*model.Room,
 	return room, nil
 }
 
-// JoinRoom 用戶加入聊天室
+// DeleteRoom 刪除聊天室，只有聊天室創建者或管理員可以刪除
+func (s *RoomService) DeleteRoom(roomID string, requesterID string) error {
+	room, err := s.roomRepo.GetRoom(roomID)
+	if err != nil {
+		return err
+	}
+
+	if !s.isRoomAdmin(room, requesterID) {
+		return ErrNotRoomAdmin
+	}
+
+	return s.roomRepo.DeleteRoom(roomID)
+}
+
+// JoinRoom 用戶加入聊天室，聊天室設有人數上限（MaxUsers > 0）且已滿時返回 ErrRoomFull
+// 私人聊天室只有受邀的用戶可以加入，否則返回 ErrRoomPrivate；被封禁的用戶返回 ErrUserBanned
 func (s *RoomService) JoinRoom(roomID string, userID string, role string) error {
 	// 檢查聊天室是否存在
-	_, err := s.roomRepo.GetRoom(roomID)
+	room, err := s.roomRepo.GetRoom(roomID)
 	if err != nil {
 		return err
 	}
 
+	// 檢查私人聊天室的邀請
+	if !room.IsPublic {
+		invited, err := s.isInvited(room, userID)
+		if err != nil {
+			return err
+		}
+		if !invited {
+			return ErrRoomPrivate
+		}
+	}
+
+	if err := s.checkNotBanned(roomID, userID); err != nil {
+		return err
+	}
+
+	// 檢查人數上限，已是活躍成員的重複加入不佔用新名額
+	if room.MaxUsers > 0 {
+		_, err := s.roomRepo.GetRoomUser(roomID, userID)
+		switch {
+		case errors.Is(err, repository.ErrUserNotFound):
+			count, err := s.roomRepo.CountActiveUsers(roomID)
+			if err != nil {
+				return err
+			}
+			if count >= int64(room.MaxUsers) {
+				return ErrRoomFull
+			}
+		case err != nil:
+			return err
+		}
+	}
+
 	// 加入聊天室
 	return s.roomRepo.JoinRoom(roomID, userID, role)
 }
@@ -90,30 +295,66 @@ func (s *RoomService) GetRoomMessages(roomID string, limit int) ([]model.Message
 	return s.roomRepo.GetRoomMessages(roomID, limit)
 }
 
-// SendMessage 發送訊息到聊天室
-func (s *RoomService) SendMessage(roomID string, userID string, content string) error {
+// GetRoomMessagesBefore 獲取聊天室中指定訊息 ID 之前的較舊訊息
+func (s *RoomService) GetRoomMessagesBefore(roomID string, beforeID uint, limit int) ([]model.Message, error) {
+	return s.roomRepo.GetRoomMessagesBefore(roomID, beforeID, limit)
+}
+
+// SearchMessages 搜尋聊天室中內容包含關鍵字的訊息（不區分大小寫，排除系統訊息），最新的在前
+// 找不到用戶記錄的作者（例如已刪除的帳號）以 AnonymousUserName 顯示
+func (s *RoomService) SearchMessages(roomID string, query string, limit int) ([]model.MessageSearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrEmptySearchQuery
+	}
+
+	results, err := s.roomRepo.SearchMessages(roomID, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		if results[i].Username == "" {
+			results[i].Username = AnonymousUserName
+		}
+	}
+
+	return results, nil
+}
+
+// SendMessage 發送訊息到聊天室，返回保存後的訊息（包含資料庫分配的 ID）
+// 私人聊天室只有創建者與有效成員可以發送，否則返回 ErrRoomPrivate；被封禁的用戶返回 ErrUserBanned
+func (s *RoomService) SendMessage(roomID string, userID string, content string) (*model.Message, error) {
 	// 檢查聊天室是否存在
-	_, err := s.roomRepo.GetRoom(roomID)
+	room, err := s.roomRepo.GetRoom(roomID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if err := s.checkCanPost(room, userID); err != nil {
+		return nil, err
 	}
 
 	// 創建訊息
 	message := &model.Message{
 		RoomID:          roomID,
 		UserID:          userID,
-		Content:         content,
+		Content:         s.wordFilter.mask(content),
 		IsSystemMessage: false,
 	}
 
 	// 保存訊息
 	err = s.roomRepo.SaveMessage(message)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// 更新用戶活躍狀態
-	return s.roomRepo.UpdateUserActivity(roomID, userID)
+	// 更新用戶活躍狀態，非聊天室成員（例如匿名的 WebSocket 連接）沒有成員記錄可更新
+	err = s.roomRepo.UpdateUserActivity(roomID, userID)
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		return nil, err
+	}
+	return message, nil
 }
 
 // SendSystemMessage 發送系統訊息到聊天室
@@ -127,7 +368,7 @@ func (s *RoomService) SendSystemMessage(roomID string, content string) error {
 	// 創建系統訊息
 	message := &model.Message{
 		RoomID:          roomID,
-		UserID:          "",
+		UserID:          model.SystemUserID,
 		Content:         content,
 		IsSystemMessage: true,
 	}
@@ -136,6 +377,214 @@ func (s *RoomService) SendSystemMessage(roomID string, content string) error {
 	return s.roomRepo.SaveMessage(message)
 }
 
+// EditMessage 編輯訊息內容，只有訊息作者可以編輯，返回更新後的訊息
+func (s *RoomService) EditMessage(messageID uint, userID string, newContent string) (*model.Message, error) {
+	if strings.TrimSpace(newContent) == "" {
+		return nil, ErrEmptyMessageContent
+	}
+
+	if _, err := s.modifiableMessage(messageID, userID, false); err != nil {
+		return nil, err
+	}
+
+	if err := s.roomRepo.UpdateMessageContent(messageID, s.wordFilter.mask(newContent)); err != nil {
+		return nil, err
+	}
+
+	return s.roomRepo.GetMessage(messageID)
+}
+
+// DeleteMessage 軟刪除訊息，返回被刪除的訊息
+// 訊息作者可以刪除；啟用 WithModeratorMessageDeletion 時聊天室創建者與管理員也可以刪除
+func (s *RoomService) DeleteMessage(messageID uint, userID string) (*model.Message, error) {
+	message, err := s.modifiableMessage(messageID, userID, s.moderatorDeletesMessages)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.roomRepo.DeleteMessage(messageID); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// DeleteMessagesByUser 軟刪除用戶在所有聊天室發送的訊息，返回被刪除的訊息
+func (s *RoomService) DeleteMessagesByUser(userID string) ([]model.Message, error) {
+	return s.roomRepo.DeleteMessagesByUser(userID)
+}
+
+// ReleaseOwnedRooms 在用戶帳號刪除前依設定的策略處理其創建的聊天室，返回更新後的聊天室
+// 無擁有者的聊天室 CreatedBy 為空，只能由聊天室管理員管理
+func (s *RoomService) ReleaseOwnedRooms(userID string) ([]model.Room, error) {
+	rooms, err := s.roomRepo.GetRoomsCreatedBy(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rooms {
+		newOwner := ""
+		if s.ownerDeletionPolicy == OwnerDeletionReassign {
+			newOwner, err = s.successorAdmin(rooms[i].ID, userID)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		rooms[i].CreatedBy = newOwner
+		if err := s.roomRepo.UpdateRoom(&rooms[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return rooms, nil
+}
+
+// LeaveAllRooms 將用戶移出所有聊天室，使其不再計入活躍人數，用於刪除帳號
+func (s *RoomService) LeaveAllRooms(userID string) error {
+	return s.roomRepo.LeaveAllRooms(userID)
+}
+
+// successorAdmin 返回聊天室中最早加入的活躍管理員，沒有其他管理員時返回空字串
+func (s *RoomService) successorAdmin(roomID string, ownerID string) (string, error) {
+	members, err := s.roomRepo.GetRoomUsers(roomID)
+	if err != nil {
+		return "", err
+	}
+
+	var successor *model.RoomUser
+	for i := range members {
+		member := &members[i]
+		if member.Role != "admin" || member.UserID == ownerID {
+			continue
+		}
+		if successor == nil || member.JoinedAt.Before(successor.JoinedAt) {
+			successor = member
+		}
+	}
+
+	if successor == nil {
+		return "", nil
+	}
+	return successor.UserID, nil
+}
+
+// AddReaction 記錄用戶對聊天室訊息的表情回應，返回訊息目前各表情的回應數量
+func (s *RoomService) AddReaction(roomID string, messageID uint, userID string, emoji string) ([]model.ReactionCount, error) {
+	if err := validateReaction(emoji); err != nil {
+		return nil, err
+	}
+	if _, err := s.roomMessage(roomID, messageID); err != nil {
+		return nil, err
+	}
+
+	reaction := &model.MessageReaction{MessageID: messageID, UserID: userID, Emoji: emoji}
+	if err := s.roomRepo.AddReaction(reaction); err != nil {
+		return nil, err
+	}
+
+	return s.roomRepo.GetReactionCounts(messageID)
+}
+
+// RemoveReaction 移除用戶對聊天室訊息的表情回應，返回訊息目前各表情的回應數量
+// 用戶沒有以該表情回應過時返回 repository.ErrReactionNotFound
+func (s *RoomService) RemoveReaction(roomID string, messageID uint, userID string, emoji string) ([]model.ReactionCount, error) {
+	if err := validateReaction(emoji); err != nil {
+		return nil, err
+	}
+	if _, err := s.roomMessage(roomID, messageID); err != nil {
+		return nil, err
+	}
+
+	if err := s.roomRepo.RemoveReaction(messageID, userID, emoji); err != nil {
+		return nil, err
+	}
+
+	return s.roomRepo.GetReactionCounts(messageID)
+}
+
+// GetReactionCounts 獲取聊天室訊息上各表情的回應數量
+func (s *RoomService) GetReactionCounts(roomID string, messageID uint) ([]model.ReactionCount, error) {
+	if _, err := s.roomMessage(roomID, messageID); err != nil {
+		return nil, err
+	}
+
+	return s.roomRepo.GetReactionCounts(messageID)
+}
+
+// GetMessagesReactionCounts 獲取多則訊息上各表情的回應數量，以訊息 ID 為鍵，供載入歷史訊息時一併顯示
+// 訊息應已透過聊天室的讀取權限檢查取得，此處不再逐一確認所屬聊天室
+func (s *RoomService) GetMessagesReactionCounts(messages []model.Message) (map[uint][]model.ReactionCount, error) {
+	messageIDs := make([]uint, 0, len(messages))
+	for _, message := range messages {
+		messageIDs = append(messageIDs, message.ID)
+	}
+	return s.roomRepo.GetReactionCountsForMessages(messageIDs)
+}
+
+// GetMessageInRoom 獲取屬於指定聊天室的訊息，訊息不存在或屬於其他聊天室時返回 repository.ErrMessageNotFound
+func (s *RoomService) GetMessageInRoom(roomID string, messageID uint) (*model.Message, error) {
+	return s.roomMessage(roomID, messageID)
+}
+
+// roomMessage 獲取訊息並確認屬於指定的聊天室，其他聊天室的訊息視為不存在
+func (s *RoomService) roomMessage(roomID string, messageID uint) (*model.Message, error) {
+	message, err := s.roomRepo.GetMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.RoomID != roomID {
+		return nil, repository.ErrMessageNotFound
+	}
+
+	return message, nil
+}
+
+// validateReaction 檢查表情回應不為空、沒有前後空白且不超過長度上限
+func validateReaction(emoji string) error {
+	if emoji == "" || len(emoji) > maxReactionLength || strings.TrimSpace(emoji) != emoji {
+		return ErrInvalidReaction
+	}
+	return nil
+}
+
+// modifiableMessage 獲取訊息並確認用戶可以修改，REST 與 WebSocket 的編輯與刪除都經由此檢查
+// allowModerator 為 true 時才會查詢聊天室以判斷用戶是否為聊天室管理員
+func (s *RoomService) modifiableMessage(messageID uint, userID string, allowModerator bool) (*model.Message, error) {
+	message, err := s.roomRepo.GetMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	var room *model.Room
+	if allowModerator && message.UserID != userID {
+		room, err = s.roomRepo.GetRoom(message.RoomID)
+		if err != nil && !errors.Is(err, repository.ErrRoomNotFound) {
+			return nil, err
+		}
+	}
+
+	if !s.canModifyMessage(userID, message, room) {
+		return nil, ErrNotMessageAuthor
+	}
+
+	return message, nil
+}
+
+// canModifyMessage 檢查用戶是否可以修改訊息
+// 系統訊息不屬於任何用戶；作者可以修改自己的訊息；提供 room 時聊天室創建者與管理員也可以修改
+func (s *RoomService) canModifyMessage(userID string, message *model.Message, room *model.Room) bool {
+	if userID == "" || message.IsSystemMessage {
+		return false
+	}
+
+	if message.UserID == userID {
+		return true
+	}
+
+	return room != nil && s.isRoomAdmin(room, userID)
+}
+
 // GetRoomActiveUserCount 獲取聊天室的活躍用戶數
 func (s *RoomService) GetRoomActiveUserCount(roomID string) (int64, error) {
 	return s.roomRepo.CountActiveUsers(roomID)
@@ -145,3 +594,331 @@ func (s *RoomService) GetRoomActiveUserCount(roomID string) (int64, error) {
 func (s *RoomService) GetRoomUsers(roomID string) ([]model.RoomUser, error) {
 	return s.roomRepo.GetRoomUsers(roomID)
 }
+
+// GetRoomUsersWithDetails 獲取聊天室的活躍用戶及其用戶名稱、角色與加入時間
+// 找不到用戶記錄的成員（例如已刪除的帳號）以 AnonymousUserName 顯示
+func (s *RoomService) GetRoomUsersWithDetails(roomID string) ([]model.RoomUserDetail, error) {
+	details, err := s.roomRepo.GetRoomUsersWithDetails(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range details {
+		if details[i].Username == "" {
+			details[i].Username = AnonymousUserName
+		}
+	}
+
+	return details, nil
+}
+
+// CanReadRoom 檢查用戶是否可以讀取聊天室的訊息、表情回應與成員列表
+// 公開聊天室任何人都可以讀取；私人聊天室只有創建者與成員可以讀取，匿名用戶（userID 為空）不能讀取
+func (s *RoomService) CanReadRoom(roomID string, userID string) (bool, error) {
+	room, err := s.roomRepo.GetRoom(roomID)
+	if err != nil {
+		return false, err
+	}
+	if room.IsPublic {
+		return true, nil
+	}
+	if userID == "" {
+		return false, nil
+	}
+	if room.CreatedBy == userID {
+		return true, nil
+	}
+
+	return s.IsMember(roomID, userID)
+}
+
+// IsMember 檢查用戶是否為聊天室成員：有尚未被移出的成員記錄且未被封禁，與目前是否連線在聊天室中無關
+func (s *RoomService) IsMember(roomID string, userID string) (bool, error) {
+	if userID == "" {
+		return false, nil
+	}
+
+	member, err := s.roomRepo.IsRoomMember(roomID, userID)
+	if err != nil || !member {
+		return false, err
+	}
+
+	banned, err := s.roomRepo.IsUserBanned(roomID, userID)
+	if err != nil {
+		return false, err
+	}
+	return !banned, nil
+}
+
+// IsActiveMember 檢查用戶目前是否連線在聊天室中（被移出、已離開或已斷線的用戶不算）
+func (s *RoomService) IsActiveMember(roomID string, userID string) (bool, error) {
+	_, err := s.roomRepo.GetRoomUser(roomID, userID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetMembershipHistory 獲取聊天室的成員加入與離開記錄，只有聊天室創建者或管理員可以查看
+func (s *RoomService) GetMembershipHistory(roomID string, requesterID string, limit int, offset int) ([]model.RoomUser, error) {
+	room, err := s.roomRepo.GetRoom(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.isRoomAdmin(room, requesterID) {
+		return nil, ErrNotRoomAdmin
+	}
+
+	return s.roomRepo.GetMembershipHistory(roomID, limit, offset)
+}
+
+// GetRecentRooms 獲取用戶參與過的聊天室，依用戶在各聊天室最近的活動時間排序
+func (s *RoomService) GetRecentRooms(userID string, limit int, offset int) ([]model.RoomWithActiveCount, error) {
+	return s.roomRepo.GetRecentRooms(userID, limit, offset)
+}
+
+// MarkRead 將聊天室中目前所有的訊息標記為用戶已讀
+func (s *RoomService) MarkRead(userID string, roomID string) error {
+	if _, err := s.roomRepo.GetRoom(roomID); err != nil {
+		return err
+	}
+
+	return s.roomRepo.MarkRead(userID, roomID)
+}
+
+// GetUnreadCount 獲取用戶在聊天室中上次標記已讀之後的未讀訊息數，不包含用戶自己發送的訊息
+func (s *RoomService) GetUnreadCount(userID string, roomID string) (int64, error) {
+	return s.roomRepo.GetUnreadCount(userID, roomID)
+}
+
+// GetUnreadCounts 獲取用戶在多個聊天室中的未讀訊息數，以聊天室 ID 為鍵
+func (s *RoomService) GetUnreadCounts(userID string, roomIDs []string) (map[string]int64, error) {
+	return s.roomRepo.GetUnreadCounts(userID, roomIDs)
+}
+
+// CreateInvite 為聊天室創建邀請連結，只有聊天室創建者或管理員可以創建
+// ttl 為 0 時使用 DefaultInviteTTL；maxUses 為 0 表示不限使用次數
+func (s *RoomService) CreateInvite(roomID string, requesterID string, ttl time.Duration, maxUses int) (*model.RoomInvite, error) {
+	room, err := s.roomRepo.GetRoom(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.isRoomAdmin(room, requesterID) {
+		return nil, ErrNotRoomAdmin
+	}
+
+	ttl, err = s.inviteTTL(ttl)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateInviteUses(maxUses); err != nil {
+		return nil, err
+	}
+
+	invite := &model.RoomInvite{
+		Token:     uuid.New().String(),
+		RoomID:    roomID,
+		CreatedBy: requesterID,
+		ExpiresAt: model.Now().Add(ttl),
+		MaxUses:   maxUses,
+	}
+
+	if err := s.roomRepo.CreateInvite(invite); err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+// inviteTTL 返回邀請實際使用的有效期限，未指定時使用預設值，超過上限或為負數時返回錯誤
+func (s *RoomService) inviteTTL(ttl time.Duration) (time.Duration, error) {
+	if ttl < 0 {
+		return 0, fmt.Errorf("%w：有效期限必須大於 0", ErrInvalidInviteTTL)
+	}
+	if ttl == 0 {
+		ttl = DefaultInviteTTL
+		if s.maxInviteTTL > 0 && ttl > s.maxInviteTTL {
+			ttl = s.maxInviteTTL
+		}
+	}
+	if s.maxInviteTTL > 0 && ttl > s.maxInviteTTL {
+		return 0, fmt.Errorf("%w：有效期限最長為 %v", ErrInvalidInviteTTL, s.maxInviteTTL)
+	}
+
+	return ttl, nil
+}
+
+// validateInviteUses 檢查邀請的最大使用次數，設置上限時必須介於 1 到上限之間
+func (s *RoomService) validateInviteUses(maxUses int) error {
+	if maxUses < 0 {
+		return fmt.Errorf("%w：使用次數不能為負數", ErrInvalidInviteUses)
+	}
+	if s.maxInviteUses > 0 && (maxUses == 0 || maxUses > s.maxInviteUses) {
+		return fmt.Errorf("%w：使用次數必須介於 1 到 %d 之間", ErrInvalidInviteUses, s.maxInviteUses)
+	}
+
+	return nil
+}
+
+// AcceptInvite 使用邀請碼加入聊天室，驗證邀請是否過期或已達使用上限
+// 已是成員時不使用邀請；聊天室已滿時返回 ErrRoomFull；加入成功後才計入邀請的使用次數
+func (s *RoomService) AcceptInvite(token string, userID string) (*model.Room, error) {
+	invite, err := s.roomRepo.GetInviteByToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if !model.Now().Before(invite.ExpiresAt) {
+		return nil, ErrInviteExpired
+	}
+
+	room, err := s.roomRepo.GetRoom(invite.RoomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkNotBanned(room.ID, userID); err != nil {
+		return nil, err
+	}
+
+	// 已是成員（包含目前未連線的成員）時不需要使用邀請
+	isMember, err := s.IsMember(room.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if isMember {
+		return room, nil
+	}
+
+	if err := s.roomRepo.JoinRoomWithInvite(room.ID, userID, "member", room.MaxUsers, invite.ID); err != nil {
+		return nil, err
+	}
+
+	return room, nil
+}
+
+// KickUser 將用戶移出聊天室，只有聊天室創建者或管理員可以操作
+// 被移出的用戶之後仍可重新加入；不是聊天室成員的用戶返回 repository.ErrUserNotFound
+func (s *RoomService) KickUser(roomID string, targetUserID string, moderatorID string) error {
+	if err := s.checkModeration(roomID, targetUserID, moderatorID); err != nil {
+		return err
+	}
+
+	return s.roomRepo.KickRoomUser(roomID, targetUserID)
+}
+
+// BanUser 將用戶移出聊天室並記錄封禁，被封禁的用戶無法再加入或透過邀請進入聊天室
+// 不在聊天室中的用戶同樣可以被封禁
+func (s *RoomService) BanUser(roomID string, targetUserID string, moderatorID string) error {
+	if err := s.checkModeration(roomID, targetUserID, moderatorID); err != nil {
+		return err
+	}
+
+	err := s.roomRepo.LeaveRoom(roomID, targetUserID)
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		return err
+	}
+
+	banned, err := s.roomRepo.IsUserBanned(roomID, targetUserID)
+	if err != nil || banned {
+		return err
+	}
+
+	return s.roomRepo.BanUser(&model.RoomBan{
+		RoomID:   roomID,
+		UserID:   targetUserID,
+		BannedBy: moderatorID,
+	})
+}
+
+// checkModeration 檢查管理員是否可以將目標用戶移出聊天室
+func (s *RoomService) checkModeration(roomID string, targetUserID string, moderatorID string) error {
+	room, err := s.roomRepo.GetRoom(roomID)
+	if err != nil {
+		return err
+	}
+
+	if !s.isRoomAdmin(room, moderatorID) {
+		return ErrNotRoomAdmin
+	}
+
+	if targetUserID == moderatorID {
+		return ErrCannotKickSelf
+	}
+
+	if targetUserID == room.CreatedBy {
+		return ErrCannotKickCreator
+	}
+
+	return nil
+}
+
+// checkNotBanned 檢查用戶是否被禁止進入聊天室，匿名訪客沒有封禁記錄
+func (s *RoomService) checkNotBanned(roomID string, userID string) error {
+	if userID == "" {
+		return nil
+	}
+
+	banned, err := s.roomRepo.IsUserBanned(roomID, userID)
+	if err != nil {
+		return err
+	}
+	if banned {
+		return ErrUserBanned
+	}
+
+	return nil
+}
+
+// checkCanPost 檢查用戶是否可以在聊天室中發送訊息，規則與加入聊天室相同：
+// 私人聊天室只有創建者與成員可以發送，被封禁的用戶不能發送
+func (s *RoomService) checkCanPost(room *model.Room, userID string) error {
+	if !room.IsPublic {
+		allowed := room.CreatedBy != "" && room.CreatedBy == userID
+		if !allowed && userID != "" {
+			member, err := s.IsMember(room.ID, userID)
+			if err != nil {
+				return err
+			}
+			allowed = member
+		}
+		if !allowed {
+			return ErrRoomPrivate
+		}
+	}
+
+	return s.checkNotBanned(room.ID, userID)
+}
+
+// isInvited 檢查用戶是否受邀進入私人聊天室
+// 聊天室創建者，以及曾透過邀請或其他方式成為成員的用戶（包含已離開者）視為受邀
+func (s *RoomService) isInvited(room *model.Room, userID string) (bool, error) {
+	if userID == "" {
+		return false, nil
+	}
+
+	if room.CreatedBy == userID {
+		return true, nil
+	}
+
+	return s.roomRepo.HasRoomMembership(room.ID, userID)
+}
+
+// isRoomAdmin 檢查用戶是否為聊天室的創建者或管理員
+func (s *RoomService) isRoomAdmin(room *model.Room, userID string) bool {
+	if userID == "" {
+		return false
+	}
+
+	if room.CreatedBy == userID {
+		return true
+	}
+
+	roomUser, err := s.roomRepo.GetRoomUser(room.ID, userID)
+	return err == nil && roomUser.Role == "admin"
+}
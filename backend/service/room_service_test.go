@@ -3,6 +3,7 @@ package service
 import (
 	"livechat/backend/model"
 	"livechat/backend/repository"
+	"strings"
 	"testing"
 	"time"
 
@@ -23,9 +24,22 @@ func (m *MockRoomRepository) GetRoom(roomID string) (*model.Room, error) {
 	return args.Get(0).(*model.Room), args.Error(1)
 }
 
-func (m *MockRoomRepository) GetAllRooms() ([]model.Room, error) {
-	args := m.Called()
-	return args.Get(0).([]model.Room), args.Error(1)
+func (m *MockRoomRepository) GetRoomByName(name string) (*model.Room, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Room), args.Error(1)
+}
+
+func (m *MockRoomRepository) GetAllRooms(order repository.RoomOrder, filter repository.RoomFilter) ([]model.Room, int64, error) {
+	args := m.Called(order, filter)
+	return args.Get(0).([]model.Room), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockRoomRepository) GetAllRoomsWithActiveCounts(order repository.RoomOrder, filter repository.RoomFilter) ([]model.RoomWithActiveCount, int64, error) {
+	args := m.Called(order, filter)
+	return args.Get(0).([]model.RoomWithActiveCount), args.Get(1).(int64), args.Error(2)
 }
 
 func (m *MockRoomRepository) CreateRoom(room *model.Room) error {
@@ -38,11 +52,34 @@ func (m *MockRoomRepository) UpdateRoom(room *model.Room) error {
 	return args.Error(0)
 }
 
+func (m *MockRoomRepository) GetRoomsCreatedBy(userID string) ([]model.Room, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Room), args.Error(1)
+}
+
+func (m *MockRoomRepository) LeaveAllRooms(userID string) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) DeleteRoom(roomID string) error {
+	args := m.Called(roomID)
+	return args.Error(0)
+}
+
 func (m *MockRoomRepository) GetRoomUsers(roomID string) ([]model.RoomUser, error) {
 	args := m.Called(roomID)
 	return args.Get(0).([]model.RoomUser), args.Error(1)
 }
 
+func (m *MockRoomRepository) GetRoomUsersWithDetails(roomID string) ([]model.RoomUserDetail, error) {
+	args := m.Called(roomID)
+	return args.Get(0).([]model.RoomUserDetail), args.Error(1)
+}
+
 func (m *MockRoomRepository) JoinRoom(roomID string, userID string, role string) error {
 	args := m.Called(roomID, userID, role)
 	return args.Error(0)
@@ -63,16 +100,159 @@ func (m *MockRoomRepository) GetRoomMessages(roomID string, limit int) ([]model.
 	return args.Get(0).([]model.Message), args.Error(1)
 }
 
+func (m *MockRoomRepository) GetRoomMessagesBefore(roomID string, beforeID uint, limit int) ([]model.Message, error) {
+	args := m.Called(roomID, beforeID, limit)
+	return args.Get(0).([]model.Message), args.Error(1)
+}
+
+func (m *MockRoomRepository) SearchMessages(roomID string, query string, limit int) ([]model.MessageSearchResult, error) {
+	args := m.Called(roomID, query, limit)
+	return args.Get(0).([]model.MessageSearchResult), args.Error(1)
+}
+
 func (m *MockRoomRepository) SaveMessage(message *model.Message) error {
 	args := m.Called(message)
 	return args.Error(0)
 }
 
+func (m *MockRoomRepository) GetMessage(messageID uint) (*model.Message, error) {
+	args := m.Called(messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Message), args.Error(1)
+}
+
+func (m *MockRoomRepository) UpdateMessageContent(messageID uint, content string) error {
+	args := m.Called(messageID, content)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) DeleteMessage(messageID uint) error {
+	args := m.Called(messageID)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) DeleteMessagesByUser(userID string) ([]model.Message, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Message), args.Error(1)
+}
+
+func (m *MockRoomRepository) AddReaction(reaction *model.MessageReaction) error {
+	args := m.Called(reaction)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) RemoveReaction(messageID uint, userID string, emoji string) error {
+	args := m.Called(messageID, userID, emoji)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) GetReactionCounts(messageID uint) ([]model.ReactionCount, error) {
+	args := m.Called(messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.ReactionCount), args.Error(1)
+}
+
+func (m *MockRoomRepository) GetReactionCountsForMessages(messageIDs []uint) (map[uint][]model.ReactionCount, error) {
+	args := m.Called(messageIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uint][]model.ReactionCount), args.Error(1)
+}
+
 func (m *MockRoomRepository) CountActiveUsers(roomID string) (int64, error) {
 	args := m.Called(roomID)
 	return args.Get(0).(int64), args.Error(1)
 }
 
+func (m *MockRoomRepository) CountActiveRooms() (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRoomRepository) GetRoomUser(roomID string, userID string) (*model.RoomUser, error) {
+	args := m.Called(roomID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RoomUser), args.Error(1)
+}
+
+func (m *MockRoomRepository) IsRoomMember(roomID string, userID string) (bool, error) {
+	args := m.Called(roomID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRoomRepository) KickRoomUser(roomID string, userID string) error {
+	args := m.Called(roomID, userID)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) HasRoomMembership(roomID string, userID string) (bool, error) {
+	args := m.Called(roomID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRoomRepository) BanUser(ban *model.RoomBan) error {
+	args := m.Called(ban)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) IsUserBanned(roomID string, userID string) (bool, error) {
+	args := m.Called(roomID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRoomRepository) GetMembershipHistory(roomID string, limit int, offset int) ([]model.RoomUser, error) {
+	args := m.Called(roomID, limit, offset)
+	return args.Get(0).([]model.RoomUser), args.Error(1)
+}
+
+func (m *MockRoomRepository) GetRecentRooms(userID string, limit int, offset int) ([]model.RoomWithActiveCount, error) {
+	args := m.Called(userID, limit, offset)
+	return args.Get(0).([]model.RoomWithActiveCount), args.Error(1)
+}
+
+func (m *MockRoomRepository) MarkRead(userID string, roomID string) error {
+	args := m.Called(userID, roomID)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) GetUnreadCount(userID string, roomID string) (int64, error) {
+	args := m.Called(userID, roomID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRoomRepository) GetUnreadCounts(userID string, roomIDs []string) (map[string]int64, error) {
+	args := m.Called(userID, roomIDs)
+	return args.Get(0).(map[string]int64), args.Error(1)
+}
+
+func (m *MockRoomRepository) CreateInvite(invite *model.RoomInvite) error {
+	args := m.Called(invite)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) GetInviteByToken(token string) (*model.RoomInvite, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RoomInvite), args.Error(1)
+}
+
+func (m *MockRoomRepository) JoinRoomWithInvite(roomID string, userID string, role string, maxUsers int, inviteID uint) error {
+	args := m.Called(roomID, userID, role, maxUsers, inviteID)
+	return args.Error(0)
+}
+
 // 測試創建新的聊天室服務
 func TestNewRoomService(t *testing.T) {
 	// 安排 (Arrange)
@@ -119,16 +299,18 @@ func TestGetAllRooms(t *testing.T) {
 		{ID: "2", CreatedAt: time.Now(), UpdatedAt: time.Now(), Name: "聊天室2"},
 	}
 
-	mockRepo.On("GetAllRooms").Return(expectedRooms, nil)
+	filter := repository.RoomFilter{PublicOnly: true}
+	mockRepo.On("GetAllRooms", repository.RoomOrderName, filter).Return(expectedRooms, int64(2), nil)
 
 	service := NewRoomService(mockRepo)
 
 	// 動作 (Act)
-	rooms, err := service.GetAllRooms()
+	rooms, total, err := service.GetAllRooms(repository.RoomOrderName, filter)
 
 	// 斷言 (Assert)
 	assert.NoError(t, err, "獲取所有聊天室不應該返回錯誤")
 	assert.Equal(t, expectedRooms, rooms, "聊天室列表應該匹配")
+	assert.Equal(t, int64(2), total, "總數應該匹配")
 	mockRepo.AssertExpectations(t)
 }
 
@@ -161,6 +343,47 @@ func TestCreateRoom(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+// 測試聊天室數量上限
+func TestCreateRoomCapacity(t *testing.T) {
+	testCases := []struct {
+		name        string
+		activeRooms int64
+		bypass      bool
+		expectedErr error
+	}{
+		{name: "低於上限", activeRooms: 1, expectedErr: nil},
+		{name: "達到上限", activeRooms: 2, expectedErr: ErrRoomCapacityReached},
+		{name: "超過上限", activeRooms: 3, expectedErr: ErrRoomCapacityReached},
+		{name: "管理員略過上限", activeRooms: 2, bypass: true, expectedErr: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 安排 (Arrange)
+			mockRepo := new(MockRoomRepository)
+			mockRepo.On("CountActiveRooms").Return(tc.activeRooms, nil)
+			mockRepo.On("CreateRoom", mock.AnythingOfType("*model.Room")).Return(nil)
+
+			service := NewRoomService(mockRepo, WithMaxRooms(2))
+
+			// 動作 (Act)
+			room, err := service.CreateRoom(RoomData{Name: "新聊天室", BypassRoomLimit: tc.bypass}, "user-123")
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.expectedErr, err, "錯誤應該匹配")
+			if tc.expectedErr != nil {
+				assert.Nil(t, room, "超過上限時不應該創建聊天室")
+				mockRepo.AssertNotCalled(t, "CreateRoom", mock.Anything)
+			} else {
+				assert.NotNil(t, room, "應該成功創建聊天室")
+			}
+			if tc.bypass {
+				mockRepo.AssertNotCalled(t, "CountActiveRooms")
+			}
+		})
+	}
+}
+
 // 測試加入聊天室
 func TestJoinRoom(t *testing.T) {
 	// 安排 (Arrange)
@@ -170,9 +393,11 @@ func TestJoinRoom(t *testing.T) {
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		Name:      "測試聊天室",
+		IsPublic:  true,
 	}
 
 	mockRepo.On("GetRoom", "1").Return(room, nil)
+	mockRepo.On("IsUserBanned", "1", mock.Anything).Return(false, nil)
 	mockRepo.On("JoinRoom", "1", "user-123", "member").Return(nil)
 
 	service := NewRoomService(mockRepo)
@@ -192,6 +417,145 @@ func TestJoinRoom(t *testing.T) {
 	assert.Equal(t, repository.ErrRoomNotFound, err, "錯誤應該是 ErrRoomNotFound")
 }
 
+// 測試加入私人聊天室時的邀請檢查
+//
+// 測試目標：
+// 1. 未受邀的用戶與匿名訪客返回 ErrRoomPrivate 且不寫入成員記錄
+// 2. 聊天室創建者可以直接加入
+// 3. 曾透過邀請成為成員的用戶可以重新加入
+func TestJoinPrivateRoom(t *testing.T) {
+	privateRoom := &model.Room{ID: "1", Name: "私人聊天室", IsPublic: false, CreatedBy: "owner-1"}
+
+	t.Run("未受邀的用戶", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockRepo := new(MockRoomRepository)
+		mockRepo.On("GetRoom", "1").Return(privateRoom, nil)
+		mockRepo.On("IsUserBanned", "1", mock.Anything).Return(false, nil)
+		mockRepo.On("HasRoomMembership", "1", "stranger").Return(false, nil)
+		service := NewRoomService(mockRepo)
+
+		// 動作 (Act)
+		err := service.JoinRoom("1", "stranger", "member")
+		guestErr := service.JoinRoom("1", "", "member")
+
+		// 斷言 (Assert)
+		assert.ErrorIs(t, err, ErrRoomPrivate, "未受邀的用戶應該被拒絕")
+		assert.ErrorIs(t, guestErr, ErrRoomPrivate, "匿名訪客應該被拒絕")
+		mockRepo.AssertNotCalled(t, "JoinRoom", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("聊天室創建者", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockRepo := new(MockRoomRepository)
+		mockRepo.On("GetRoom", "1").Return(privateRoom, nil)
+		mockRepo.On("IsUserBanned", "1", mock.Anything).Return(false, nil)
+		mockRepo.On("JoinRoom", "1", "owner-1", "member").Return(nil)
+		service := NewRoomService(mockRepo)
+
+		// 動作 (Act)
+		err := service.JoinRoom("1", "owner-1", "member")
+
+		// 斷言 (Assert)
+		assert.NoError(t, err, "創建者應該可以加入自己的私人聊天室")
+		mockRepo.AssertNotCalled(t, "HasRoomMembership", mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("受邀的成員", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockRepo := new(MockRoomRepository)
+		mockRepo.On("GetRoom", "1").Return(privateRoom, nil)
+		mockRepo.On("IsUserBanned", "1", mock.Anything).Return(false, nil)
+		mockRepo.On("HasRoomMembership", "1", "member-1").Return(true, nil)
+		mockRepo.On("JoinRoom", "1", "member-1", "member").Return(nil)
+		service := NewRoomService(mockRepo)
+
+		// 動作 (Act)
+		err := service.JoinRoom("1", "member-1", "member")
+
+		// 斷言 (Assert)
+		assert.NoError(t, err, "受邀的成員應該可以重新加入")
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+// 測試加入聊天室時的人數上限檢查
+//
+// 測試目標：
+// 1. 聊天室已滿時返回 ErrRoomFull 且不寫入成員記錄
+// 2. 尚差一人額滿時可以加入
+// 3. MaxUsers 為 0 表示不限制人數
+// 4. 已是活躍成員的用戶在聊天室已滿時仍可重複加入
+func TestJoinRoomCapacity(t *testing.T) {
+	t.Run("聊天室已滿", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockRepo := new(MockRoomRepository)
+		mockRepo.On("GetRoom", "1").Return(&model.Room{ID: "1", IsPublic: true, MaxUsers: 2}, nil)
+		mockRepo.On("IsUserBanned", "1", mock.Anything).Return(false, nil)
+		mockRepo.On("GetRoomUser", "1", "user-123").Return(nil, repository.ErrUserNotFound)
+		mockRepo.On("CountActiveUsers", "1").Return(int64(2), nil)
+		service := NewRoomService(mockRepo)
+
+		// 動作 (Act)
+		err := service.JoinRoom("1", "user-123", "member")
+
+		// 斷言 (Assert)
+		assert.ErrorIs(t, err, ErrRoomFull, "聊天室已滿時應該返回 ErrRoomFull")
+		mockRepo.AssertNotCalled(t, "JoinRoom", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("尚差一人額滿", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockRepo := new(MockRoomRepository)
+		mockRepo.On("GetRoom", "1").Return(&model.Room{ID: "1", IsPublic: true, MaxUsers: 2}, nil)
+		mockRepo.On("IsUserBanned", "1", mock.Anything).Return(false, nil)
+		mockRepo.On("GetRoomUser", "1", "user-123").Return(nil, repository.ErrUserNotFound)
+		mockRepo.On("CountActiveUsers", "1").Return(int64(1), nil)
+		mockRepo.On("JoinRoom", "1", "user-123", "member").Return(nil)
+		service := NewRoomService(mockRepo)
+
+		// 動作 (Act)
+		err := service.JoinRoom("1", "user-123", "member")
+
+		// 斷言 (Assert)
+		assert.NoError(t, err, "未滿的聊天室應該可以加入")
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("不限制人數", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockRepo := new(MockRoomRepository)
+		mockRepo.On("GetRoom", "1").Return(&model.Room{ID: "1", IsPublic: true, MaxUsers: 0}, nil)
+		mockRepo.On("IsUserBanned", "1", mock.Anything).Return(false, nil)
+		mockRepo.On("JoinRoom", "1", "user-123", "member").Return(nil)
+		service := NewRoomService(mockRepo)
+
+		// 動作 (Act)
+		err := service.JoinRoom("1", "user-123", "member")
+
+		// 斷言 (Assert)
+		assert.NoError(t, err, "MaxUsers 為 0 時應該不限制人數")
+		mockRepo.AssertNotCalled(t, "CountActiveUsers", mock.Anything)
+	})
+
+	t.Run("已是成員的用戶重複加入", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockRepo := new(MockRoomRepository)
+		mockRepo.On("GetRoom", "1").Return(&model.Room{ID: "1", IsPublic: true, MaxUsers: 2}, nil)
+		mockRepo.On("IsUserBanned", "1", mock.Anything).Return(false, nil)
+		mockRepo.On("GetRoomUser", "1", "user-123").Return(&model.RoomUser{RoomID: "1", UserID: "user-123", IsActive: true}, nil)
+		mockRepo.On("JoinRoom", "1", "user-123", "member").Return(nil)
+		service := NewRoomService(mockRepo)
+
+		// 動作 (Act)
+		err := service.JoinRoom("1", "user-123", "member")
+
+		// 斷言 (Assert)
+		assert.NoError(t, err, "已是活躍成員時不應該受人數上限影響")
+		mockRepo.AssertNotCalled(t, "CountActiveUsers", mock.Anything)
+	})
+}
+
 // 測試離開聊天室
 func TestLeaveRoom(t *testing.T) {
 	// 安排 (Arrange)
@@ -240,27 +604,60 @@ func TestSendMessage(t *testing.T) {
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		Name:      "測試聊天室",
+		IsPublic:  true,
 	}
 
 	mockRepo.On("GetRoom", "1").Return(room, nil)
+	mockRepo.On("IsUserBanned", "1", mock.Anything).Return(false, nil)
 	mockRepo.On("SaveMessage", mock.AnythingOfType("*model.Message")).Return(nil)
 	mockRepo.On("UpdateUserActivity", "1", "user-123").Return(nil)
 
 	service := NewRoomService(mockRepo)
 
 	// 動作 (Act)
-	err := service.SendMessage("1", "user-123", "Hello, World!")
+	message, err := service.SendMessage("1", "user-123", "Hello, World!")
 
 	// 斷言 (Assert)
 	assert.NoError(t, err, "發送訊息不應該返回錯誤")
+	if assert.NotNil(t, message, "應該返回保存的訊息") {
+		assert.Equal(t, "Hello, World!", message.Content, "訊息內容應該匹配")
+	}
 	mockRepo.AssertExpectations(t)
 
 	// 測試聊天室不存在的情況
 	mockRepo.On("GetRoom", "999").Return(nil, repository.ErrRoomNotFound)
 
-	err = service.SendMessage("999", "user-123", "Hello, World!")
+	_, err = service.SendMessage("999", "user-123", "Hello, World!")
 	assert.Error(t, err, "發送訊息到不存在的聊天室應該返回錯誤")
 	assert.Equal(t, repository.ErrRoomNotFound, err, "錯誤應該是 ErrRoomNotFound")
+
+	// 測試非聊天室成員發送訊息：訊息仍被保存，不回報找不到成員記錄
+	mockRepo.On("UpdateUserActivity", "1", "guest").Return(repository.ErrUserNotFound)
+
+	_, err = service.SendMessage("1", "guest", "Hello from guest")
+	assert.NoError(t, err, "非成員發送訊息不應該返回錯誤")
+}
+
+// 測試設置封鎖詞後，發送與編輯的訊息在保存前遮蔽封鎖詞
+func TestMessageWordFilter(t *testing.T) {
+	// 安排 (Arrange)
+	roomRepo := repository.NewRoomRepository(repository.NewMockDBWithSchema())
+	room := &model.Room{ID: "room-1", Name: "測試聊天室", IsPublic: true, IsActive: true}
+	assert.NoError(t, roomRepo.CreateRoom(room), "創建聊天室不應該失敗")
+	service := NewRoomService(roomRepo, WithMessageWordFilter([]string{"darn"}))
+
+	// 動作 (Act)
+	sent, sendErr := service.SendMessage(room.ID, "author", "well Darn it")
+	edited, editErr := service.EditMessage(sent.ID, "author", "darn again")
+	stored, getErr := roomRepo.GetMessage(sent.ID)
+
+	// 斷言 (Assert)
+	assert.NoError(t, sendErr, "發送訊息不應該返回錯誤")
+	assert.Equal(t, "well **** it", sent.Content, "返回的訊息應該已遮蔽封鎖詞")
+	assert.NoError(t, editErr, "編輯訊息不應該返回錯誤")
+	assert.Equal(t, "**** again", edited.Content, "編輯後的訊息應該已遮蔽封鎖詞")
+	assert.NoError(t, getErr, "獲取訊息不應該返回錯誤")
+	assert.Equal(t, "**** again", stored.Content, "資料庫中不應該保存封鎖詞")
 }
 
 // 測試發送系統訊息
@@ -285,6 +682,161 @@ func TestSendSystemMessage(t *testing.T) {
 	// 斷言 (Assert)
 	assert.NoError(t, err, "發送系統訊息不應該返回錯誤")
 	mockRepo.AssertExpectations(t)
+
+	saved := mockRepo.Calls[len(mockRepo.Calls)-1].Arguments.Get(0).(*model.Message)
+	assert.Equal(t, model.SystemUserID, saved.UserID, "系統訊息的發送者應該是系統用戶")
+	assert.True(t, saved.IsSystemMessage, "應該標記為系統訊息")
+}
+
+// 測試編輯訊息只允許訊息作者
+//
+// 測試目標：
+// 1. 作者可以編輯訊息並取得更新後的內容
+// 2. 非作者與匿名用戶無法編輯
+// 3. 系統訊息無法被編輯
+// 4. 空白內容與不存在的訊息返回對應錯誤
+func TestEditMessage(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockRoomRepository)
+	original := &model.Message{RoomID: "1", UserID: "author", Content: "原始內容"}
+	original.ID = 10
+	edited := &model.Message{RoomID: "1", UserID: "author", Content: "新內容"}
+	edited.ID = 10
+	system := &model.Message{RoomID: "1", UserID: model.SystemUserID, Content: "系統訊息", IsSystemMessage: true}
+	system.ID = 11
+
+	mockRepo.On("GetMessage", uint(10)).Return(original, nil).Once()
+	mockRepo.On("UpdateMessageContent", uint(10), "新內容").Return(nil)
+	mockRepo.On("GetMessage", uint(10)).Return(edited, nil).Once()
+	service := NewRoomService(mockRepo)
+
+	// 動作 (Act)
+	message, err := service.EditMessage(10, "author", "新內容")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "作者編輯訊息不應該返回錯誤")
+	assert.Equal(t, "新內容", message.Content, "應該返回更新後的訊息")
+
+	mockRepo.On("GetMessage", uint(10)).Return(original, nil)
+	_, err = service.EditMessage(10, "someone-else", "竄改內容")
+	assert.ErrorIs(t, err, ErrNotMessageAuthor, "非作者不應該能編輯訊息")
+	_, err = service.EditMessage(10, "", "竄改內容")
+	assert.ErrorIs(t, err, ErrNotMessageAuthor, "匿名用戶不應該能編輯訊息")
+
+	mockRepo.On("GetMessage", uint(11)).Return(system, nil)
+	_, err = service.EditMessage(11, model.SystemUserID, "竄改內容")
+	assert.ErrorIs(t, err, ErrNotMessageAuthor, "系統訊息不應該能被編輯")
+
+	_, err = service.EditMessage(10, "author", "   ")
+	assert.ErrorIs(t, err, ErrEmptyMessageContent, "空白內容應該返回 ErrEmptyMessageContent")
+
+	mockRepo.On("GetMessage", uint(99)).Return(nil, repository.ErrMessageNotFound)
+	_, err = service.EditMessage(99, "author", "新內容")
+	assert.ErrorIs(t, err, repository.ErrMessageNotFound, "不存在的訊息應該返回 ErrMessageNotFound")
+
+	mockRepo.AssertNumberOfCalls(t, "UpdateMessageContent", 1)
+}
+
+// 測試刪除訊息只允許訊息作者
+func TestDeleteMessage(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockRoomRepository)
+	message := &model.Message{RoomID: "1", UserID: "author", Content: "內容"}
+	message.ID = 10
+
+	mockRepo.On("GetMessage", uint(10)).Return(message, nil)
+	mockRepo.On("DeleteMessage", uint(10)).Return(nil)
+	service := NewRoomService(mockRepo)
+
+	// 動作 & 斷言：非作者
+	_, err := service.DeleteMessage(10, "someone-else")
+	assert.ErrorIs(t, err, ErrNotMessageAuthor, "非作者不應該能刪除訊息")
+	mockRepo.AssertNotCalled(t, "DeleteMessage", mock.Anything)
+
+	// 動作 & 斷言：作者
+	deleted, err := service.DeleteMessage(10, "author")
+	assert.NoError(t, err, "作者刪除訊息不應該返回錯誤")
+	assert.Equal(t, "1", deleted.RoomID, "應該返回被刪除訊息所屬的聊天室")
+	mockRepo.AssertCalled(t, "DeleteMessage", uint(10))
+}
+
+// 測試啟用管理員刪除訊息後，聊天室創建者與管理員可以刪除他人的訊息
+//
+// 測試目標：
+// 1. 未啟用時只有作者可以刪除，也不會查詢聊天室
+// 2. 啟用後聊天室創建者與管理員可以刪除，一般成員仍被拒絕
+// 3. 管理員仍不能編輯他人的訊息
+func TestDeleteMessageByModerator(t *testing.T) {
+	room := &model.Room{ID: "1", Name: "測試聊天室", IsPublic: true, CreatedBy: "owner-1"}
+	message := &model.Message{RoomID: "1", UserID: "author", Content: "內容"}
+	message.ID = 10
+
+	t.Run("未啟用", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockRepo := new(MockRoomRepository)
+		mockRepo.On("GetMessage", uint(10)).Return(message, nil)
+		service := NewRoomService(mockRepo)
+
+		// 動作 (Act)
+		_, err := service.DeleteMessage(10, "owner-1")
+
+		// 斷言 (Assert)
+		assert.ErrorIs(t, err, ErrNotMessageAuthor, "未啟用時聊天室創建者不應該能刪除他人的訊息")
+		mockRepo.AssertNotCalled(t, "GetRoom", mock.Anything)
+		mockRepo.AssertNotCalled(t, "DeleteMessage", mock.Anything)
+	})
+
+	t.Run("已啟用", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockRepo := new(MockRoomRepository)
+		mockRepo.On("GetMessage", uint(10)).Return(message, nil)
+		mockRepo.On("GetRoom", "1").Return(room, nil)
+		mockRepo.On("GetRoomUser", "1", "mod-1").Return(&model.RoomUser{RoomID: "1", UserID: "mod-1", Role: "admin"}, nil)
+		mockRepo.On("GetRoomUser", "1", "member-1").Return(&model.RoomUser{RoomID: "1", UserID: "member-1", Role: "member"}, nil)
+		mockRepo.On("DeleteMessage", uint(10)).Return(nil)
+		service := NewRoomService(mockRepo, WithModeratorMessageDeletion(true))
+
+		// 動作 (Act)
+		_, memberErr := service.DeleteMessage(10, "member-1")
+		_, editErr := service.EditMessage(10, "mod-1", "竄改")
+		_, ownerErr := service.DeleteMessage(10, "owner-1")
+		_, modErr := service.DeleteMessage(10, "mod-1")
+
+		// 斷言 (Assert)
+		assert.ErrorIs(t, memberErr, ErrNotMessageAuthor, "一般成員不應該能刪除他人的訊息")
+		assert.ErrorIs(t, editErr, ErrNotMessageAuthor, "管理員不應該能編輯他人的訊息")
+		assert.NoError(t, ownerErr, "聊天室創建者應該能刪除他人的訊息")
+		assert.NoError(t, modErr, "聊天室管理員應該能刪除他人的訊息")
+		mockRepo.AssertNumberOfCalls(t, "DeleteMessage", 2)
+		mockRepo.AssertNotCalled(t, "UpdateMessageContent", mock.Anything, mock.Anything)
+	})
+}
+
+// 測試訊息修改權限的判斷規則
+//
+// 測試目標：
+// 1. 作者可以修改自己的訊息，未登入與非作者不行
+// 2. 只有提供聊天室時，聊天室創建者才能修改他人的訊息
+// 3. 系統訊息不屬於任何用戶，任何人都不能修改
+func TestCanModifyMessage(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockRoomRepository)
+	mockRepo.On("GetRoomUser", "1", mock.Anything).Return(nil, repository.ErrUserNotFound)
+	service := NewRoomService(mockRepo)
+
+	room := &model.Room{ID: "1", CreatedBy: "owner-1"}
+	userMessage := &model.Message{RoomID: "1", UserID: "author"}
+	systemMessage := &model.Message{RoomID: "1", UserID: "system", IsSystemMessage: true}
+
+	// 動作 & 斷言
+	assert.True(t, service.canModifyMessage("author", userMessage, nil), "作者應該可以修改自己的訊息")
+	assert.False(t, service.canModifyMessage("someone-else", userMessage, nil), "非作者不應該可以修改訊息")
+	assert.False(t, service.canModifyMessage("", &model.Message{RoomID: "1"}, room), "未登入不應該可以修改訊息")
+	assert.False(t, service.canModifyMessage("owner-1", userMessage, nil), "未提供聊天室時創建者不應該可以修改他人的訊息")
+	assert.True(t, service.canModifyMessage("owner-1", userMessage, room), "提供聊天室時創建者應該可以修改他人的訊息")
+	assert.False(t, service.canModifyMessage("someone-else", userMessage, room), "提供聊天室時非管理員不應該可以修改他人的訊息")
+	assert.False(t, service.canModifyMessage("system", systemMessage, room), "系統訊息不應該可以被修改")
+	assert.False(t, service.canModifyMessage("owner-1", systemMessage, room), "創建者不應該可以修改系統訊息")
 }
 
 // 測試獲取聊天室活躍用戶數
@@ -326,3 +878,490 @@ func TestGetRoomUsers(t *testing.T) {
 	assert.Equal(t, expectedUsers, users, "用戶列表應該匹配")
 	mockRepo.AssertExpectations(t)
 }
+
+// 測試獲取聊天室用戶資料時，沒有用戶名稱的成員以匿名名稱顯示
+func TestGetRoomUsersWithDetails(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockRoomRepository)
+	mockRepo.On("GetRoomUsersWithDetails", "1").Return([]model.RoomUserDetail{
+		{UserID: "user-1", Username: "alice", Role: "admin"},
+		{UserID: "ghost", Role: "member"},
+	}, nil)
+
+	service := NewRoomService(mockRepo)
+
+	// 動作 (Act)
+	details, err := service.GetRoomUsersWithDetails("1")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "獲取聊天室用戶資料不應該返回錯誤")
+	assert.Len(t, details, 2, "應該返回所有活躍用戶")
+	assert.Equal(t, "alice", details[0].Username, "已有用戶名稱不應該被改動")
+	assert.Equal(t, AnonymousUserName, details[1].Username, "沒有用戶記錄的成員應該以匿名名稱顯示")
+	mockRepo.AssertExpectations(t)
+}
+
+// 測試表情回應只能針對指定聊天室中的訊息，且表情必須有效
+func TestAddReaction(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockRoomRepository)
+	message := &model.Message{RoomID: "room-1", UserID: "author-1", Content: "大家好"}
+	counts := []model.ReactionCount{{Emoji: "👍", Count: 1}}
+
+	mockRepo.On("GetMessage", uint(1)).Return(message, nil)
+	mockRepo.On("AddReaction", &model.MessageReaction{MessageID: 1, UserID: "user-1", Emoji: "👍"}).Return(nil)
+	mockRepo.On("GetReactionCounts", uint(1)).Return(counts, nil)
+
+	service := NewRoomService(mockRepo)
+
+	// 動作 (Act)
+	result, err := service.AddReaction("room-1", 1, "user-1", "👍")
+	_, otherRoomErr := service.AddReaction("room-2", 1, "user-1", "👍")
+	_, emptyErr := service.AddReaction("room-1", 1, "user-1", "")
+	_, spaceErr := service.AddReaction("room-1", 1, "user-1", " 👍")
+	_, longErr := service.AddReaction("room-1", 1, "user-1", strings.Repeat("👍", 10))
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "回應訊息不應該返回錯誤")
+	assert.Equal(t, counts, result, "應該返回目前的回應數量")
+	assert.ErrorIs(t, otherRoomErr, repository.ErrMessageNotFound, "其他聊天室的訊息應該視為不存在")
+	assert.ErrorIs(t, emptyErr, ErrInvalidReaction, "空的表情應該被拒絕")
+	assert.ErrorIs(t, spaceErr, ErrInvalidReaction, "帶有空白的表情應該被拒絕")
+	assert.ErrorIs(t, longErr, ErrInvalidReaction, "過長的表情應該被拒絕")
+	mockRepo.AssertNumberOfCalls(t, "AddReaction", 1)
+	mockRepo.AssertExpectations(t)
+}
+
+// 測試聊天室創建者可以創建邀請，非管理員會被拒絕
+func TestCreateInvite(t *testing.T) {
+	// 安排 (Arrange)
+	mockTime := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time {
+		return mockTime
+	})
+	defer model.ResetTimeNow()
+
+	mockRepo := new(MockRoomRepository)
+	room := &model.Room{ID: "1", Name: "私人聊天室", CreatedBy: "owner-1"}
+
+	mockRepo.On("GetRoom", "1").Return(room, nil)
+	mockRepo.On("CreateInvite", mock.AnythingOfType("*model.RoomInvite")).Return(nil)
+	mockRepo.On("GetRoomUser", "1", "member-1").Return(&model.RoomUser{RoomID: "1", UserID: "member-1", Role: "member"}, nil)
+
+	service := NewRoomService(mockRepo)
+
+	// 動作 (Act)
+	invite, err := service.CreateInvite("1", "owner-1", time.Hour, 5)
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "創建者創建邀請不應該返回錯誤")
+	assert.NotEmpty(t, invite.Token, "邀請碼不應該為空")
+	assert.Equal(t, "1", invite.RoomID, "邀請的聊天室 ID 應該匹配")
+	assert.Equal(t, 5, invite.MaxUses, "最大使用次數應該匹配")
+	assert.Equal(t, mockTime.Add(time.Hour), invite.ExpiresAt, "過期時間應該依注入的時鐘計算")
+
+	// 一般成員不能創建邀請
+	_, err = service.CreateInvite("1", "member-1", time.Hour, 5)
+	assert.Equal(t, ErrNotRoomAdmin, err, "一般成員創建邀請應該返回 ErrNotRoomAdmin")
+}
+
+// 測試創建邀請時的使用次數與有效期限上限
+func TestCreateInviteLimits(t *testing.T) {
+	// 安排 (Arrange)
+	mockTime := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time {
+		return mockTime
+	})
+	defer model.ResetTimeNow()
+
+	mockRepo := new(MockRoomRepository)
+	room := &model.Room{ID: "1", Name: "私人聊天室", CreatedBy: "owner-1"}
+
+	mockRepo.On("GetRoom", "1").Return(room, nil)
+	mockRepo.On("CreateInvite", mock.AnythingOfType("*model.RoomInvite")).Return(nil)
+
+	limited := NewRoomService(mockRepo, WithInviteLimits(10, 2*time.Hour))
+	unlimited := NewRoomService(mockRepo)
+
+	// 動作 (Act)
+	atLimit, atLimitErr := limited.CreateInvite("1", "owner-1", 2*time.Hour, 10)
+	_, overUsesErr := limited.CreateInvite("1", "owner-1", time.Hour, 11)
+	_, unlimitedUsesErr := limited.CreateInvite("1", "owner-1", time.Hour, 0)
+	_, negativeUsesErr := unlimited.CreateInvite("1", "owner-1", time.Hour, -1)
+	_, overTTLErr := limited.CreateInvite("1", "owner-1", 2*time.Hour+time.Second, 1)
+	_, negativeTTLErr := unlimited.CreateInvite("1", "owner-1", -time.Hour, 0)
+	cappedDefault, cappedDefaultErr := limited.CreateInvite("1", "owner-1", 0, 1)
+	defaultInvite, defaultErr := unlimited.CreateInvite("1", "owner-1", 0, 0)
+
+	// 斷言 (Assert)
+	assert.NoError(t, atLimitErr, "剛好等於上限的邀請應該可以創建")
+	assert.Equal(t, 10, atLimit.MaxUses, "最大使用次數應該匹配")
+	assert.Equal(t, mockTime.Add(2*time.Hour), atLimit.ExpiresAt, "過期時間應該等於上限")
+
+	assert.ErrorIs(t, overUsesErr, ErrInvalidInviteUses, "超過使用次數上限應該被拒絕")
+	assert.ErrorIs(t, unlimitedUsesErr, ErrInvalidInviteUses, "設置上限時不能創建不限次數的邀請")
+	assert.ErrorIs(t, negativeUsesErr, ErrInvalidInviteUses, "負數的使用次數應該被拒絕")
+	assert.ErrorIs(t, overTTLErr, ErrInvalidInviteTTL, "超過有效期限上限應該被拒絕")
+	assert.ErrorIs(t, negativeTTLErr, ErrInvalidInviteTTL, "負數的有效期限應該被拒絕")
+
+	assert.NoError(t, cappedDefaultErr, "未指定有效期限時應該使用預設值")
+	assert.Equal(t, mockTime.Add(2*time.Hour), cappedDefault.ExpiresAt, "預設有效期限不應該超過上限")
+	assert.NoError(t, defaultErr, "未設置上限時應該可以創建不限次數的邀請")
+	assert.Equal(t, mockTime.Add(DefaultInviteTTL), defaultInvite.ExpiresAt, "未指定有效期限時應該使用 DefaultInviteTTL")
+}
+
+// 測試刪除聊天室創建者帳號時依策略處理其聊天室
+func TestReleaseOwnedRooms(t *testing.T) {
+	joinedAt := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	members := []model.RoomUser{
+		{RoomID: "1", UserID: "owner-1", Role: "admin", JoinedAt: joinedAt.Add(-time.Hour)},
+		{RoomID: "1", UserID: "admin-late", Role: "admin", JoinedAt: joinedAt.Add(time.Hour)},
+		{RoomID: "1", UserID: "admin-early", Role: "admin", JoinedAt: joinedAt},
+		{RoomID: "1", UserID: "member-1", Role: "member", JoinedAt: joinedAt.Add(-2 * time.Hour)},
+	}
+
+	testCases := []struct {
+		name           string
+		opts           []RoomServiceOption
+		expectedOwners map[string]string
+	}{
+		{
+			name:           "默認轉移給最早加入的管理員，沒有管理員時標記為無擁有者",
+			expectedOwners: map[string]string{"1": "admin-early", "2": ""},
+		},
+		{
+			name:           "無擁有者策略一律清除創建者",
+			opts:           []RoomServiceOption{WithOwnerDeletionPolicy(OwnerDeletionOrphan)},
+			expectedOwners: map[string]string{"1": "", "2": ""},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 安排 (Arrange)
+			mockRepo := new(MockRoomRepository)
+			owned := []model.Room{
+				{ID: "1", Name: "有管理員的聊天室", CreatedBy: "owner-1"},
+				{ID: "2", Name: "沒有管理員的聊天室", CreatedBy: "owner-1"},
+			}
+			updated := map[string]string{}
+
+			mockRepo.On("GetRoomsCreatedBy", "owner-1").Return(owned, nil)
+			mockRepo.On("GetRoomUsers", "1").Return(members, nil)
+			mockRepo.On("GetRoomUsers", "2").Return([]model.RoomUser{{RoomID: "2", UserID: "member-1", Role: "member"}}, nil)
+			mockRepo.On("UpdateRoom", mock.AnythingOfType("*model.Room")).Run(func(args mock.Arguments) {
+				room := args.Get(0).(*model.Room)
+				updated[room.ID] = room.CreatedBy
+			}).Return(nil)
+
+			service := NewRoomService(mockRepo, tc.opts...)
+
+			// 動作 (Act)
+			rooms, err := service.ReleaseOwnedRooms("owner-1")
+
+			// 斷言 (Assert)
+			assert.NoError(t, err, "處理聊天室不應該返回錯誤")
+			assert.Len(t, rooms, 2, "應該返回用戶創建的所有聊天室")
+			assert.Equal(t, tc.expectedOwners, updated, "聊天室的擁有者應該依策略更新")
+		})
+	}
+}
+
+// 測試只有聊天室創建者或管理員可以刪除聊天室
+func TestDeleteRoom(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockRoomRepository)
+	room := &model.Room{ID: "1", Name: "測試聊天室", CreatedBy: "owner-1"}
+
+	mockRepo.On("GetRoom", "1").Return(room, nil)
+	mockRepo.On("GetRoom", "999").Return(nil, repository.ErrRoomNotFound)
+	mockRepo.On("GetRoomUser", "1", "admin-1").Return(&model.RoomUser{RoomID: "1", UserID: "admin-1", Role: "admin"}, nil)
+	mockRepo.On("GetRoomUser", "1", "member-1").Return(&model.RoomUser{RoomID: "1", UserID: "member-1", Role: "member"}, nil)
+	mockRepo.On("DeleteRoom", "1").Return(nil)
+
+	service := NewRoomService(mockRepo)
+
+	// 動作 (Act)
+	memberErr := service.DeleteRoom("1", "member-1")
+	anonymousErr := service.DeleteRoom("1", "")
+	notFoundErr := service.DeleteRoom("999", "owner-1")
+	mockRepo.AssertNotCalled(t, "DeleteRoom", mock.Anything)
+	ownerErr := service.DeleteRoom("1", "owner-1")
+	adminErr := service.DeleteRoom("1", "admin-1")
+
+	// 斷言 (Assert)
+	assert.ErrorIs(t, memberErr, ErrNotRoomAdmin, "一般成員刪除聊天室應該返回 ErrNotRoomAdmin")
+	assert.ErrorIs(t, anonymousErr, ErrNotRoomAdmin, "未登入的用戶刪除聊天室應該返回 ErrNotRoomAdmin")
+	assert.ErrorIs(t, notFoundErr, repository.ErrRoomNotFound, "刪除不存在的聊天室應該返回 ErrRoomNotFound")
+	assert.NoError(t, ownerErr, "創建者應該可以刪除聊天室")
+	assert.NoError(t, adminErr, "聊天室管理員應該可以刪除聊天室")
+	mockRepo.AssertNumberOfCalls(t, "DeleteRoom", 2)
+}
+
+// 測試只有聊天室管理員可以查看成員記錄
+func TestGetMembershipHistory(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockRoomRepository)
+	room := &model.Room{ID: "1", Name: "測試聊天室", CreatedBy: "owner-1"}
+	history := []model.RoomUser{
+		{RoomID: "1", UserID: "user-1", IsActive: true},
+		{RoomID: "1", UserID: "user-2", IsActive: false},
+	}
+
+	mockRepo.On("GetRoom", "1").Return(room, nil)
+	mockRepo.On("GetMembershipHistory", "1", 50, 0).Return(history, nil)
+	mockRepo.On("GetRoomUser", "1", "member-1").Return(&model.RoomUser{RoomID: "1", UserID: "member-1", Role: "member"}, nil)
+
+	service := NewRoomService(mockRepo)
+
+	// 動作 (Act)
+	members, err := service.GetMembershipHistory("1", "owner-1", 50, 0)
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "創建者查看成員記錄不應該返回錯誤")
+	assert.Equal(t, history, members, "成員記錄應該匹配")
+
+	// 一般成員不能查看成員記錄
+	_, err = service.GetMembershipHistory("1", "member-1", 50, 0)
+	assert.Equal(t, ErrNotRoomAdmin, err, "一般成員查看成員記錄應該返回 ErrNotRoomAdmin")
+}
+
+// 測試使用有效、過期與已用盡的邀請，已是成員時不使用邀請
+func TestAcceptInvite(t *testing.T) {
+	// 安排 (Arrange)
+	mockTime := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time {
+		return mockTime
+	})
+	defer model.ResetTimeNow()
+
+	mockRepo := new(MockRoomRepository)
+	room := &model.Room{ID: "1", Name: "私人聊天室", CreatedBy: "owner-1"}
+
+	validInvite := &model.RoomInvite{Token: "valid", RoomID: "1", ExpiresAt: mockTime.Add(time.Hour), MaxUses: 1}
+	validInvite.ID = 1
+	expiredInvite := &model.RoomInvite{Token: "expired", RoomID: "1", ExpiresAt: mockTime.Add(-time.Minute)}
+	expiredInvite.ID = 2
+	exhaustedInvite := &model.RoomInvite{Token: "exhausted", RoomID: "1", ExpiresAt: mockTime.Add(time.Hour), MaxUses: 1, Uses: 1}
+	exhaustedInvite.ID = 3
+
+	mockRepo.On("GetRoom", "1").Return(room, nil)
+	mockRepo.On("GetInviteByToken", "valid").Return(validInvite, nil)
+	mockRepo.On("GetInviteByToken", "expired").Return(expiredInvite, nil)
+	mockRepo.On("GetInviteByToken", "exhausted").Return(exhaustedInvite, nil)
+	mockRepo.On("GetInviteByToken", "unknown").Return(nil, repository.ErrInviteNotFound)
+	mockRepo.On("JoinRoomWithInvite", "1", "user-123", "member", 0, uint(1)).Return(nil)
+	mockRepo.On("JoinRoomWithInvite", "1", "user-123", "member", 0, uint(3)).Return(repository.ErrInviteExhausted)
+	mockRepo.On("IsRoomMember", "1", "user-123").Return(false, nil)
+	mockRepo.On("IsRoomMember", "1", "member-1").Return(true, nil)
+	mockRepo.On("IsUserBanned", "1", "user-123").Return(false, nil)
+	mockRepo.On("IsUserBanned", "1", "member-1").Return(false, nil)
+	mockRepo.On("IsUserBanned", "1", "banned-user").Return(true, nil)
+
+	service := NewRoomService(mockRepo)
+
+	// 動作 & 斷言：有效邀請
+	joinedRoom, err := service.AcceptInvite("valid", "user-123")
+	assert.NoError(t, err, "使用有效邀請不應該返回錯誤")
+	assert.Equal(t, room, joinedRoom, "應該返回邀請的聊天室")
+	mockRepo.AssertCalled(t, "JoinRoomWithInvite", "1", "user-123", "member", 0, uint(1))
+
+	// 已是成員時直接返回聊天室，不使用邀請
+	joinedRoom, err = service.AcceptInvite("valid", "member-1")
+	assert.NoError(t, err, "成員使用邀請不應該返回錯誤")
+	assert.Equal(t, room, joinedRoom, "應該返回邀請的聊天室")
+	mockRepo.AssertNotCalled(t, "JoinRoomWithInvite", "1", "member-1", "member", 0, uint(1))
+
+	// 過期邀請
+	_, err = service.AcceptInvite("expired", "user-123")
+	assert.Equal(t, ErrInviteExpired, err, "過期邀請應該返回 ErrInviteExpired")
+	mockRepo.AssertNotCalled(t, "JoinRoomWithInvite", "1", "user-123", "member", 0, uint(2))
+
+	// 已用盡的邀請
+	_, err = service.AcceptInvite("exhausted", "user-123")
+	assert.Equal(t, repository.ErrInviteExhausted, err, "已用盡的邀請應該返回 ErrInviteExhausted")
+
+	// 不存在的邀請
+	_, err = service.AcceptInvite("unknown", "user-123")
+	assert.Equal(t, repository.ErrInviteNotFound, err, "不存在的邀請應該返回 ErrInviteNotFound")
+
+	// 被封禁的用戶不能透過邀請加入
+	_, err = service.AcceptInvite("valid", "banned-user")
+	assert.ErrorIs(t, err, ErrUserBanned, "被封禁的用戶應該返回 ErrUserBanned")
+	mockRepo.AssertNotCalled(t, "JoinRoomWithInvite", "1", "banned-user", "member", 0, uint(1))
+}
+
+// 測試聊天室已滿時透過邀請加入返回 ErrRoomFull，且邀請不會被使用
+func TestAcceptInviteRoomFull(t *testing.T) {
+	// 安排 (Arrange)：使用真實的儲存庫與記憶體資料庫
+	roomRepo := repository.NewRoomRepository(repository.NewMockDBWithSchema())
+	room := &model.Room{ID: "full-room", Name: "已滿的聊天室", IsPublic: false, IsActive: true, MaxUsers: 1, CreatedBy: "owner-1"}
+	assert.NoError(t, roomRepo.CreateRoom(room), "創建聊天室不應該失敗")
+	assert.NoError(t, roomRepo.JoinRoom(room.ID, "owner-1", "admin"), "創建者加入聊天室不應該失敗")
+
+	service := NewRoomService(roomRepo)
+	invite, err := service.CreateInvite(room.ID, "owner-1", time.Hour, 1)
+	assert.NoError(t, err, "創建邀請不應該失敗")
+
+	// 動作 (Act)
+	_, acceptErr := service.AcceptInvite(invite.Token, "user-1")
+
+	// 斷言 (Assert)
+	assert.ErrorIs(t, acceptErr, ErrRoomFull, "聊天室已滿時應該返回 ErrRoomFull")
+	stored, err := roomRepo.GetInviteByToken(invite.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stored.Uses, "聊天室已滿時邀請不應該被使用")
+	member, err := roomRepo.IsRoomMember(room.ID, "user-1")
+	assert.NoError(t, err)
+	assert.False(t, member, "聊天室已滿時不應該留下成員記錄")
+
+	// 動作 & 斷言：有空位後可以使用同一個邀請加入
+	assert.NoError(t, roomRepo.LeaveRoom(room.ID, "owner-1"))
+	_, acceptErr = service.AcceptInvite(invite.Token, "user-1")
+	assert.NoError(t, acceptErr, "有空位後應該可以使用邀請加入")
+	stored, _ = roomRepo.GetInviteByToken(invite.Token)
+	assert.Equal(t, 1, stored.Uses, "加入成功後邀請應該被使用一次")
+}
+
+// 測試移出與封禁聊天室用戶
+//
+// 測試目標：
+// 1. 管理員可以移出成員，成員記錄被標記為離開
+// 2. 不能移出自己或聊天室創建者
+// 3. 非管理員的請求返回 ErrNotRoomAdmin
+// 4. 封禁會記錄封禁並移出用戶，重複封禁不會重複記錄
+func TestKickAndBanUser(t *testing.T) {
+	room := &model.Room{ID: "1", Name: "測試聊天室", IsPublic: true, CreatedBy: "owner-1"}
+
+	t.Run("管理員移出成員", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockRepo := new(MockRoomRepository)
+		mockRepo.On("GetRoom", "1").Return(room, nil)
+		mockRepo.On("GetRoomUser", "1", "mod-1").Return(&model.RoomUser{RoomID: "1", UserID: "mod-1", Role: "admin"}, nil)
+		mockRepo.On("KickRoomUser", "1", "user-1").Return(nil)
+		service := NewRoomService(mockRepo)
+
+		// 動作 (Act)
+		err := service.KickUser("1", "user-1", "mod-1")
+
+		// 斷言 (Assert)
+		assert.NoError(t, err, "管理員移出成員不應該返回錯誤")
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "BanUser", mock.Anything)
+	})
+
+	t.Run("不能移出自己或創建者", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockRepo := new(MockRoomRepository)
+		mockRepo.On("GetRoom", "1").Return(room, nil)
+		mockRepo.On("GetRoomUser", "1", "mod-1").Return(&model.RoomUser{RoomID: "1", UserID: "mod-1", Role: "admin"}, nil)
+		service := NewRoomService(mockRepo)
+
+		// 動作 (Act)
+		selfErr := service.KickUser("1", "mod-1", "mod-1")
+		ownerSelfErr := service.BanUser("1", "owner-1", "owner-1")
+		creatorErr := service.KickUser("1", "owner-1", "mod-1")
+
+		// 斷言 (Assert)
+		assert.ErrorIs(t, selfErr, ErrCannotKickSelf, "管理員不應該能移出自己")
+		assert.ErrorIs(t, ownerSelfErr, ErrCannotKickSelf, "創建者不應該能封禁自己")
+		assert.ErrorIs(t, creatorErr, ErrCannotKickCreator, "不應該能移出聊天室創建者")
+		mockRepo.AssertNotCalled(t, "KickRoomUser", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "LeaveRoom", mock.Anything, mock.Anything)
+	})
+
+	t.Run("非管理員被拒絕", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockRepo := new(MockRoomRepository)
+		mockRepo.On("GetRoom", "1").Return(room, nil)
+		mockRepo.On("GetRoomUser", "1", "member-1").Return(&model.RoomUser{RoomID: "1", UserID: "member-1", Role: "member"}, nil)
+		service := NewRoomService(mockRepo)
+
+		// 動作 (Act)
+		kickErr := service.KickUser("1", "user-1", "member-1")
+		banErr := service.BanUser("1", "user-1", "member-1")
+		guestErr := service.KickUser("1", "user-1", "")
+
+		// 斷言 (Assert)
+		assert.ErrorIs(t, kickErr, ErrNotRoomAdmin, "一般成員不應該能移出用戶")
+		assert.ErrorIs(t, banErr, ErrNotRoomAdmin, "一般成員不應該能封禁用戶")
+		assert.ErrorIs(t, guestErr, ErrNotRoomAdmin, "未登入的請求不應該能移出用戶")
+		mockRepo.AssertNotCalled(t, "KickRoomUser", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "LeaveRoom", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "BanUser", mock.Anything)
+	})
+
+	t.Run("封禁用戶", func(t *testing.T) {
+		// 安排 (Arrange)
+		mockRepo := new(MockRoomRepository)
+		mockRepo.On("GetRoom", "1").Return(room, nil)
+		mockRepo.On("LeaveRoom", "1", "user-1").Return(nil)
+		mockRepo.On("LeaveRoom", "1", "user-2").Return(repository.ErrUserNotFound)
+		mockRepo.On("IsUserBanned", "1", "user-1").Return(false, nil)
+		mockRepo.On("IsUserBanned", "1", "user-2").Return(true, nil)
+		mockRepo.On("BanUser", mock.MatchedBy(func(ban *model.RoomBan) bool {
+			return ban.RoomID == "1" && ban.UserID == "user-1" && ban.BannedBy == "owner-1"
+		})).Return(nil)
+		service := NewRoomService(mockRepo)
+
+		// 動作 (Act)
+		err := service.BanUser("1", "user-1", "owner-1")
+		repeatErr := service.BanUser("1", "user-2", "owner-1")
+
+		// 斷言 (Assert)
+		assert.NoError(t, err, "封禁成員不應該返回錯誤")
+		assert.NoError(t, repeatErr, "重複封禁不在聊天室中的用戶不應該返回錯誤")
+		mockRepo.AssertNumberOfCalls(t, "BanUser", 1)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+// 測試檢查聊天室成員資格，斷線的成員仍是成員，被移出或被封禁的用戶不是
+func TestIsMember(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockRoomRepository)
+	mockRepo.On("IsRoomMember", "1", "member-1").Return(true, nil)
+	mockRepo.On("IsRoomMember", "1", "banned-1").Return(true, nil)
+	mockRepo.On("IsRoomMember", "1", "kicked-1").Return(false, nil)
+	mockRepo.On("IsUserBanned", "1", "member-1").Return(false, nil)
+	mockRepo.On("IsUserBanned", "1", "banned-1").Return(true, nil)
+
+	service := NewRoomService(mockRepo)
+
+	// 動作 (Act)
+	member, memberErr := service.IsMember("1", "member-1")
+	banned, bannedErr := service.IsMember("1", "banned-1")
+	kicked, kickedErr := service.IsMember("1", "kicked-1")
+	anonymous, anonymousErr := service.IsMember("1", "")
+
+	// 斷言 (Assert)
+	assert.NoError(t, memberErr)
+	assert.True(t, member, "成員應該返回 true")
+	assert.NoError(t, bannedErr)
+	assert.False(t, banned, "被封禁的用戶不應該是成員")
+	assert.NoError(t, kickedErr)
+	assert.False(t, kicked, "被移出的用戶不應該是成員")
+	assert.NoError(t, anonymousErr)
+	assert.False(t, anonymous, "匿名用戶不應該是成員")
+	mockRepo.AssertNotCalled(t, "IsUserBanned", "1", "kicked-1")
+}
+
+// 測試檢查聊天室有效成員
+func TestIsActiveMember(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockRoomRepository)
+	mockRepo.On("GetRoomUser", "1", "member-1").Return(&model.RoomUser{RoomID: "1", UserID: "member-1", IsActive: true}, nil)
+	mockRepo.On("GetRoomUser", "1", "kicked-1").Return(nil, repository.ErrUserNotFound)
+
+	service := NewRoomService(mockRepo)
+
+	// 動作 (Act)
+	memberActive, memberErr := service.IsActiveMember("1", "member-1")
+	kickedActive, kickedErr := service.IsActiveMember("1", "kicked-1")
+
+	// 斷言 (Assert)
+	assert.NoError(t, memberErr, "檢查有效成員不應該返回錯誤")
+	assert.True(t, memberActive, "有效成員應該返回 true")
+	assert.NoError(t, kickedErr, "找不到成員關係不應該視為錯誤")
+	assert.False(t, kickedActive, "被移出的用戶不應該是有效成員")
+}
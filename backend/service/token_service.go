@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"livechat/backend/model"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken 表示存取令牌無效、已過期或簽名不符
+var ErrInvalidToken = errors.New("無效的存取令牌")
+
+// DefaultTokenTTL 是存取令牌的預設有效時間
+const DefaultTokenTTL = time.Hour
+
+// TokenClaims 是存取令牌中攜帶的用戶資料
+type TokenClaims struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// TokenService 簽發與驗證以 HS256 簽名的 JWT 存取令牌，供無法使用 cookie 的客戶端登入
+type TokenService struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewTokenService 創建一個新的令牌服務，ttl 小於等於 0 時使用 DefaultTokenTTL
+func NewTokenService(secret []byte, ttl time.Duration) *TokenService {
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	}
+
+	return &TokenService{
+		secret: secret,
+		ttl:    ttl,
+	}
+}
+
+// IssueToken 為用戶簽發存取令牌，令牌包含用戶 ID（sub）、用戶名與角色
+func (s *TokenService) IssueToken(user *model.User) (string, error) {
+	now := model.Now()
+	claims := TokenClaims{
+		Username: user.Username,
+		Role:     user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.ttl)),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+}
+
+// ValidateToken 驗證存取令牌的簽名與有效期限，無效時返回 ErrInvalidToken
+func (s *TokenService) ValidateToken(tokenString string) (*TokenClaims, error) {
+	claims := &TokenClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return s.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithTimeFunc(model.Now),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
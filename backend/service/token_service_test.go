@@ -0,0 +1,79 @@
+package service
+
+import (
+	"livechat/backend/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 測試簽發的令牌可以被驗證並還原用戶資料
+func TestTokenServiceValidToken(t *testing.T) {
+	// 安排 (Arrange)
+	service := NewTokenService([]byte("secret"), time.Hour)
+	user := &model.User{ID: "user-1", Username: "testuser", Role: "admin"}
+
+	// 動作 (Act)
+	token, issueErr := service.IssueToken(user)
+	claims, err := service.ValidateToken(token)
+
+	// 斷言 (Assert)
+	assert.NoError(t, issueErr, "簽發令牌不應該返回錯誤")
+	assert.NoError(t, err, "有效的令牌不應該返回錯誤")
+	assert.Equal(t, "user-1", claims.Subject, "用戶 ID 應該匹配")
+	assert.Equal(t, "testuser", claims.Username, "用戶名應該匹配")
+	assert.Equal(t, "admin", claims.Role, "角色應該匹配")
+}
+
+// 測試過期的令牌被拒絕
+func TestTokenServiceExpiredToken(t *testing.T) {
+	// 安排 (Arrange)
+	now := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time {
+		return now
+	})
+	defer model.ResetTimeNow()
+
+	service := NewTokenService([]byte("secret"), time.Minute)
+	token, _ := service.IssueToken(&model.User{ID: "user-1", Username: "testuser", Role: "user"})
+
+	// 動作 (Act)
+	_, beforeErr := service.ValidateToken(token)
+	now = now.Add(time.Minute + time.Second)
+	_, afterErr := service.ValidateToken(token)
+
+	// 斷言 (Assert)
+	assert.NoError(t, beforeErr, "有效期限內的令牌應該有效")
+	assert.Equal(t, ErrInvalidToken, afterErr, "過期的令牌應該返回 ErrInvalidToken")
+}
+
+// 測試簽名被竄改或使用其他密鑰簽名的令牌被拒絕
+func TestTokenServiceTamperedSignature(t *testing.T) {
+	// 安排 (Arrange)
+	service := NewTokenService([]byte("secret"), time.Hour)
+	other := NewTokenService([]byte("other-secret"), time.Hour)
+	user := &model.User{ID: "user-1", Username: "testuser", Role: "user"}
+
+	token, _ := service.IssueToken(user)
+	parts := strings.Split(token, ".")
+	signature := []byte(parts[2])
+	if signature[0] == 'A' {
+		signature[0] = 'B'
+	} else {
+		signature[0] = 'A'
+	}
+	tampered := parts[0] + "." + parts[1] + "." + string(signature)
+	forged, _ := other.IssueToken(&model.User{ID: "user-1", Username: "testuser", Role: "admin"})
+
+	// 動作 (Act)
+	_, tamperedErr := service.ValidateToken(tampered)
+	_, forgedErr := service.ValidateToken(forged)
+	_, garbageErr := service.ValidateToken("not-a-token")
+
+	// 斷言 (Assert)
+	assert.Equal(t, ErrInvalidToken, tamperedErr, "簽名被竄改的令牌應該被拒絕")
+	assert.Equal(t, ErrInvalidToken, forgedErr, "以其他密鑰簽名的令牌應該被拒絕")
+	assert.Equal(t, ErrInvalidToken, garbageErr, "格式錯誤的令牌應該被拒絕")
+}
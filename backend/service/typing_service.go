@@ -0,0 +1,99 @@
+package service
+
+import (
+	"livechat/backend/model"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTypingExpiry 是輸入中狀態的預設有效時間，客戶端未再次發送 typing 時狀態自動過期
+const DefaultTypingExpiry = 5 * time.Second
+
+// TypingUser 是聊天室中正在輸入的用戶
+type TypingUser struct {
+	UserID    string    // 登入用戶的 ID，匿名訪客為空
+	UserName  string    // 顯示名稱
+	UpdatedAt time.Time // 最近一次發送 typing 的時間
+}
+
+// typingEntry 是單一連接的輸入中狀態
+type typingEntry struct {
+	roomID string
+	user   TypingUser
+}
+
+// TypingService 在伺服器端記錄各聊天室正在輸入的連接，供重新連接的客戶端查詢
+type TypingService struct {
+	expiry  time.Duration
+	entries map[string]typingEntry // 以客戶端 ID 為鍵，每個連接同時只會在一個聊天室中輸入
+	mutex   sync.Mutex
+}
+
+// NewTypingService 創建一個新的輸入中狀態服務，expiry 小於等於 0 時使用 DefaultTypingExpiry
+func NewTypingService(expiry time.Duration) *TypingService {
+	if expiry <= 0 {
+		expiry = DefaultTypingExpiry
+	}
+
+	return &TypingService{
+		expiry:  expiry,
+		entries: make(map[string]typingEntry),
+	}
+}
+
+// SetTyping 更新客戶端在聊天室中的輸入中狀態，isTyping 為 false 時清除狀態
+func (s *TypingService) SetTyping(roomID string, client *model.Client, isTyping bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !isTyping {
+		delete(s.entries, client.ID)
+		return
+	}
+
+	s.entries[client.ID] = typingEntry{
+		roomID: roomID,
+		user: TypingUser{
+			UserID:    client.UserID,
+			UserName:  client.UserName,
+			UpdatedAt: model.Now(),
+		},
+	}
+}
+
+// ClearClient 清除客戶端的輸入中狀態，用於離開聊天室或斷線時
+func (s *TypingService) ClearClient(clientID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.entries, clientID)
+}
+
+// GetTypingUsers 獲取聊天室中尚未過期的輸入中用戶，依最近一次輸入的時間排序，時間相同時依名稱排序
+// 查詢時順便移除所有已過期的狀態
+func (s *TypingService) GetTypingUsers(roomID string) []TypingUser {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cutoff := model.Now().Add(-s.expiry)
+	users := []TypingUser{}
+	for clientID, entry := range s.entries {
+		if !entry.user.UpdatedAt.After(cutoff) {
+			delete(s.entries, clientID)
+			continue
+		}
+		if entry.roomID == roomID {
+			users = append(users, entry.user)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].UpdatedAt.Equal(users[j].UpdatedAt) {
+			return users[i].UpdatedAt.Before(users[j].UpdatedAt)
+		}
+		return users[i].UserName < users[j].UserName
+	})
+
+	return users
+}
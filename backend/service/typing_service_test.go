@@ -0,0 +1,67 @@
+package service
+
+import (
+	"livechat/backend/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 測試輸入中狀態的記錄與過期
+//
+// 測試目標：
+// 1. 正在輸入的連接只出現在其所在的聊天室
+// 2. 超過有效時間未更新的狀態不再返回，再次發送 typing 會延長有效時間
+// 3. 停止輸入或清除連接後狀態立即移除
+func TestTypingService(t *testing.T) {
+	// 安排 (Arrange)
+	now := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time {
+		return now
+	})
+	defer model.ResetTimeNow()
+
+	typingService := NewTypingService(5 * time.Second)
+	alice := &model.Client{ID: "client-1", UserID: "user-1", UserName: "Alice"}
+	bob := &model.Client{ID: "client-2", UserName: "訪客"}
+	carol := &model.Client{ID: "client-3", UserID: "user-3", UserName: "Carol"}
+
+	// 動作 (Act)
+	typingService.SetTyping("room-1", alice, true)
+	now = now.Add(2 * time.Second)
+	typingService.SetTyping("room-1", bob, true)
+	typingService.SetTyping("room-2", carol, true)
+	typing := typingService.GetTypingUsers("room-1")
+
+	// 斷言 (Assert)
+	assert.Equal(t, []TypingUser{
+		{UserID: "user-1", UserName: "Alice", UpdatedAt: now.Add(-2 * time.Second)},
+		{UserName: "訪客", UpdatedAt: now},
+	}, typing, "應該依最近輸入時間返回聊天室中正在輸入的用戶")
+
+	// 動作 & 斷言：Alice 的狀態過期，訪客再次輸入後延長有效時間
+	now = now.Add(3 * time.Second)
+	typingService.SetTyping("room-1", bob, true)
+	typing = typingService.GetTypingUsers("room-1")
+	assert.Len(t, typing, 1, "過期的輸入中狀態不應該返回")
+	assert.Equal(t, "訪客", typing[0].UserName, "仍在輸入的用戶應該保留")
+
+	now = now.Add(4 * time.Second)
+	assert.Len(t, typingService.GetTypingUsers("room-1"), 1, "再次發送 typing 後應該延長有效時間")
+
+	// 動作 & 斷言：停止輸入與清除連接
+	typingService.SetTyping("room-1", bob, false)
+	typingService.ClearClient(carol.ID)
+	assert.Empty(t, typingService.GetTypingUsers("room-1"), "停止輸入後狀態應該立即移除")
+	assert.Empty(t, typingService.GetTypingUsers("room-2"), "清除連接後狀態應該立即移除")
+}
+
+// 測試未指定有效時間時使用預設值
+func TestNewTypingServiceDefaultExpiry(t *testing.T) {
+	// 動作 (Act)
+	typingService := NewTypingService(0)
+
+	// 斷言 (Assert)
+	assert.Equal(t, DefaultTypingExpiry, typingService.expiry, "有效時間為 0 時應該使用預設值")
+}
@@ -5,51 +5,135 @@ import (
 	"livechat/backend/model"
 	"livechat/backend/repository"
 	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // 定義錯誤
 var (
-	ErrInvalidUsername = errors.New("無效的用戶名")
-	ErrInvalidEmail    = errors.New("無效的電子郵件格式")
-	ErrWeakPassword    = errors.New("密碼必須包含大小寫字母、數字至少其2者，且長度至少為8位")
-	ErrUnauthorized    = errors.New("未授權的操作")
+	ErrInvalidUsername    = errors.New("無效的用戶名")
+	ErrInvalidEmail       = errors.New("無效的電子郵件格式")
+	ErrWeakPassword       = errors.New("密碼不符合強度規則")
+	ErrUnauthorized       = errors.New("未授權的操作")
+	ErrInvalidDisplayName = errors.New("顯示名稱長度必須為 1 到 30 個字元，且不能包含控制字元")
+	ErrReservedUsername   = errors.New("此用戶名為系統保留名稱")
+	ErrResetTokenExpired  = errors.New("密碼重設令牌已過期")
+	ErrAlreadyVerified    = errors.New("電子郵件已經驗證過")
+	ErrSamePassword       = errors.New("新密碼不能與目前密碼相同")
 )
 
+// DefaultPasswordResetTTL 是密碼重設令牌的默認有效期限
+const DefaultPasswordResetTTL = time.Hour
+
+// DefaultReservedUsernames 是默認不允許註冊的用戶名，供系統訊息與訪客名稱等內部用途使用
+var DefaultReservedUsernames = []string{"system", "admin", "guest", model.SystemUsername}
+
+// maxDisplayNameLength 顯示名稱的最大字元數
+const maxDisplayNameLength = 30
+
 // UserService 定義用戶服務接口
 type UserService interface {
 	RegisterUser(username, email, password string) (*model.User, error)
 	LoginUser(username, password string) (*model.User, error)
 	GetUserByID(id string) (*model.User, error)
+	GetUserByUsername(username string) (*model.User, error)
 	IsAdmin(user *model.User) bool
+	UpdateDisplayName(userID, displayName string) (*model.User, error)
+	UpdateProfile(userID, newEmail, newUsername string) (*model.User, error)
+	CheckPassword(userID, password string) error
+	PasswordRequirements() PasswordPolicy
+	DeleteUser(id string) error
+	RequestPasswordReset(email string) (*model.PasswordResetToken, error)
+	ResetPassword(token, newPassword string) error
+	ChangePassword(userID, oldPassword, newPassword string) error
+	GenerateVerificationToken(userID string) (*model.EmailVerificationToken, error)
+	VerifyEmail(token string) (*model.User, error)
+	SendDirectMessage(senderID, recipientID, content string) (*model.DirectMessage, error)
+	GetDirectMessages(userID, otherUserID string, limit int) ([]model.DirectMessage, error)
 }
 
 // UserServiceImpl 實現 UserService 接口
 type UserServiceImpl struct {
-	userRepo repository.UserRepository
+	userRepo       repository.UserRepository
+	firstUserAdmin bool
+	reservedNames  map[string]bool // 不允許註冊的用戶名（小寫）
+	resetTTL       time.Duration   // 密碼重設令牌的有效期限
+	passwordPolicy PasswordPolicy  // 註冊、重設與更換密碼時的強度規則
+	wordFilter     *wordFilter     // 保存私人訊息前遮蔽封鎖詞，nil 表示不過濾
+}
+
+// UserServiceOption 定義用戶服務選項
+type UserServiceOption func(*UserServiceImpl)
+
+// WithFirstUserAdmin 設置是否將系統中第一個註冊的用戶設為管理員
+func WithFirstUserAdmin(enabled bool) UserServiceOption {
+	return func(s *UserServiceImpl) {
+		s.firstUserAdmin = enabled
+	}
+}
+
+// WithReservedUsernames 設置不允許註冊的用戶名，比對時不區分大小寫，傳入空列表可停用檢查
+func WithReservedUsernames(names []string) UserServiceOption {
+	return func(s *UserServiceImpl) {
+		s.reservedNames = reservedNameSet(names)
+	}
+}
+
+// WithPasswordResetTTL 設置密碼重設令牌的有效期限，非正數時沿用默認值
+func WithPasswordResetTTL(ttl time.Duration) UserServiceOption {
+	return func(s *UserServiceImpl) {
+		if ttl > 0 {
+			s.resetTTL = ttl
+		}
+	}
+}
+
+// WithPasswordPolicy 設置密碼強度規則，未設置時使用 DefaultPasswordPolicy
+func WithPasswordPolicy(policy PasswordPolicy) UserServiceOption {
+	return func(s *UserServiceImpl) {
+		s.passwordPolicy = policy
+	}
+}
+
+// WithDirectMessageWordFilter 設置封鎖詞列表，私人訊息在保存前會將封鎖詞替換為星號，
+// 規則與 WithWordFilter 相同，列表為空時不過濾
+func WithDirectMessageWordFilter(words []string) UserServiceOption {
+	return func(s *UserServiceImpl) {
+		s.wordFilter = newWordFilter(words)
+	}
 }
 
 // NewUserService 創建一個新的用戶服務
-func NewUserService(userRepo repository.UserRepository) UserService {
-	return &UserServiceImpl{
-		userRepo: userRepo,
+func NewUserService(userRepo repository.UserRepository, opts ...UserServiceOption) UserService {
+	s := &UserServiceImpl{
+		userRepo:       userRepo,
+		reservedNames:  reservedNameSet(DefaultReservedUsernames),
+		resetTTL:       DefaultPasswordResetTTL,
+		passwordPolicy: DefaultPasswordPolicy,
 	}
+
+	// 應用選項
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 // RegisterUser 註冊新用戶
 func (s *UserServiceImpl) RegisterUser(username, email, password string) (*model.User, error) {
-	// 驗證用戶名
-	if len(username) < 3 || len(username) > 20 {
-		return nil, ErrInvalidUsername
-	}
-
-	// 驗證電子郵件
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
-	if !emailRegex.MatchString(email) {
-		return nil, ErrInvalidEmail
+	// 驗證用戶名與電子郵件
+	if err := s.validateUsernameAndEmail(username, email); err != nil {
+		return nil, err
 	}
 
 	// 驗證密碼強度
-	if !isStrongPassword(password) {
+	if !s.passwordPolicy.Allows(password) {
 		return nil, ErrWeakPassword
 	}
 
@@ -61,6 +145,17 @@ func (s *UserServiceImpl) RegisterUser(username, email, password string) (*model
 		Role:     "user", // 默認角色為普通用戶
 	}
 
+	// 啟用時，系統中的第一個用戶自動成為管理員
+	if s.firstUserAdmin {
+		count, err := s.userRepo.CountUsers()
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			user.Role = "admin"
+		}
+	}
+
 	// 保存用戶
 	err := s.userRepo.CreateUser(user)
 	if err != nil {
@@ -70,6 +165,35 @@ func (s *UserServiceImpl) RegisterUser(username, email, password string) (*model
 	return user, nil
 }
 
+// emailRegex 是電子郵件格式的驗證規則
+var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+// validateUsernameAndEmail 檢查用戶名長度、保留名稱與電子郵件格式，註冊與更新個人資料共用
+func (s *UserServiceImpl) validateUsernameAndEmail(username, email string) error {
+	if len(username) < 3 || len(username) > 20 {
+		return ErrInvalidUsername
+	}
+	if s.reservedNames[strings.ToLower(username)] {
+		return ErrReservedUsername
+	}
+	if !emailRegex.MatchString(email) {
+		return ErrInvalidEmail
+	}
+	return nil
+}
+
+// reservedNameSet 將保留名稱列表轉換為以小寫名稱為鍵的集合，忽略空白項目
+func reservedNameSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" {
+			set[name] = true
+		}
+	}
+	return set
+}
+
 // LoginUser 用戶登入
 func (s *UserServiceImpl) LoginUser(username, password string) (*model.User, error) {
 	return s.userRepo.CheckUserCredentials(username, password)
@@ -80,28 +204,267 @@ func (s *UserServiceImpl) GetUserByID(id string) (*model.User, error) {
 	return s.userRepo.GetUserByID(id)
 }
 
+// GetUserByUsername 根據用戶名獲取用戶
+func (s *UserServiceImpl) GetUserByUsername(username string) (*model.User, error) {
+	return s.userRepo.GetUserByUsername(username)
+}
+
 // IsAdmin 檢查用戶是否為管理員
 func (s *UserServiceImpl) IsAdmin(user *model.User) bool {
 	return user != nil && user.Role == "admin"
 }
 
-// isStrongPassword 檢查密碼是否足夠強
-func isStrongPassword(password string) bool {
-	if len(password) < 8 {
-		return false
+// PasswordRequirements 返回目前使用的密碼強度規則，供前端顯示
+func (s *UserServiceImpl) PasswordRequirements() PasswordPolicy {
+	return s.passwordPolicy
+}
+
+// CheckPassword 確認密碼是否為用戶目前的密碼，用於敏感操作前的再次驗證
+func (s *UserServiceImpl) CheckPassword(userID, password string) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.userRepo.CheckUserCredentials(user.Username, password)
+	return err
+}
+
+// DeleteUser 刪除用戶帳號，用戶創建的聊天室需先由 RoomService.ReleaseOwnedRooms 處理
+func (s *UserServiceImpl) DeleteUser(id string) error {
+	if _, err := s.userRepo.GetUserByID(id); err != nil {
+		return err
+	}
+
+	return s.userRepo.DeleteUser(id)
+}
+
+// RequestPasswordReset 為指定電子郵件的用戶產生一次性的密碼重設令牌
+func (s *UserServiceImpl) RequestPasswordReset(email string) (*model.PasswordResetToken, error) {
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	resetToken := &model.PasswordResetToken{
+		Token:     uuid.New().String(),
+		UserID:    user.ID,
+		ExpiresAt: model.Now().Add(s.resetTTL),
+	}
+	if err := s.userRepo.CreatePasswordResetToken(resetToken); err != nil {
+		return nil, err
 	}
 
-	var hasUpper, hasLower, hasDigit bool
-	for _, char := range password {
-		if 'A' <= char && char <= 'Z' {
-			hasUpper = true
-		} else if 'a' <= char && char <= 'z' {
-			hasLower = true
-		} else if '0' <= char && char <= '9' {
-			hasDigit = true
+	return resetToken, nil
+}
+
+// ResetPassword 使用密碼重設令牌設定新密碼，令牌在成功後即失效
+func (s *UserServiceImpl) ResetPassword(token, newPassword string) error {
+	resetToken, err := s.userRepo.GetPasswordResetToken(token)
+	if err != nil {
+		return err
+	}
+	if resetToken.UsedAt != nil {
+		return repository.ErrResetTokenUsed
+	}
+	if !model.Now().Before(resetToken.ExpiresAt) {
+		return ErrResetTokenExpired
+	}
+
+	// 驗證密碼強度，失敗時不消耗令牌以便用戶重試
+	if !s.passwordPolicy.Allows(newPassword) {
+		return ErrWeakPassword
+	}
+
+	user, err := s.userRepo.GetUserByID(resetToken.UserID)
+	if err != nil {
+		return err
+	}
+
+	// 先消耗令牌，避免並發請求重複使用同一令牌
+	if err := s.userRepo.ConsumePasswordResetToken(resetToken.ID); err != nil {
+		return err
+	}
+
+	return s.setPassword(user, newPassword)
+}
+
+// ChangePassword 在確認目前密碼正確後更換密碼，新密碼須符合強度要求且不能與目前密碼相同
+func (s *UserServiceImpl) ChangePassword(userID, oldPassword, newPassword string) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.userRepo.CheckUserCredentials(user.Username, oldPassword); err != nil {
+		return err
+	}
+	if newPassword == oldPassword {
+		return ErrSamePassword
+	}
+	if !s.passwordPolicy.Allows(newPassword) {
+		return ErrWeakPassword
+	}
+
+	return s.setPassword(user, newPassword)
+}
+
+// setPassword 以 bcrypt 哈希新密碼並保存用戶
+func (s *UserServiceImpl) setPassword(user *model.User, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user.Password = string(hashedPassword)
+	return s.userRepo.UpdateUser(user)
+}
+
+// GenerateVerificationToken 為尚未驗證電子郵件的用戶產生驗證令牌
+func (s *UserServiceImpl) GenerateVerificationToken(userID string) (*model.EmailVerificationToken, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsVerified {
+		return nil, ErrAlreadyVerified
+	}
+
+	verificationToken := &model.EmailVerificationToken{
+		Token:  uuid.New().String(),
+		UserID: user.ID,
+	}
+	if err := s.userRepo.CreateVerificationToken(verificationToken); err != nil {
+		return nil, err
+	}
+
+	return verificationToken, nil
+}
+
+// VerifyEmail 以驗證令牌將對應用戶標記為已驗證電子郵件
+func (s *UserServiceImpl) VerifyEmail(token string) (*model.User, error) {
+	verificationToken, err := s.userRepo.GetVerificationToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(verificationToken.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsVerified {
+		return nil, ErrAlreadyVerified
+	}
+
+	user.IsVerified = true
+	if err := s.userRepo.UpdateUser(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// UpdateDisplayName 更新用戶的顯示名稱，不影響登入用戶名
+func (s *UserServiceImpl) UpdateDisplayName(userID, displayName string) (*model.User, error) {
+	displayName = strings.TrimSpace(displayName)
+	if !isValidDisplayName(displayName) {
+		return nil, ErrInvalidDisplayName
+	}
+
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.DisplayName = displayName
+	if err := s.userRepo.UpdateUser(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// UpdateProfile 更新用戶的電子郵件與用戶名，驗證規則與註冊相同，且不能與其他用戶重複
+func (s *UserServiceImpl) UpdateProfile(userID, newEmail, newUsername string) (*model.User, error) {
+	if err := s.validateUsernameAndEmail(newUsername, newEmail); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 檢查是否與其他用戶衝突，與自己目前的資料相同時不視為衝突
+	if newUsername != user.Username {
+		if _, err := s.userRepo.GetUserByUsername(newUsername); err == nil {
+			return nil, repository.ErrUserAlreadyExists
+		} else if !errors.Is(err, repository.ErrUserNotFound) {
+			return nil, err
 		}
 	}
+	if newEmail != user.Email {
+		if _, err := s.userRepo.GetUserByEmail(newEmail); err == nil {
+			return nil, repository.ErrEmailAlreadyExists
+		} else if !errors.Is(err, repository.ErrUserNotFound) {
+			return nil, err
+		}
+	}
+
+	user.Username = newUsername
+	user.Email = newEmail
+	if err := s.userRepo.UpdateUser(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SendDirectMessage 保存發送給指定用戶的私人訊息，接收者離線時也會保存，之後可透過 GetDirectMessages 取得
+// 接收者不存在時返回 repository.ErrUserNotFound
+func (s *UserServiceImpl) SendDirectMessage(senderID, recipientID, content string) (*model.DirectMessage, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, ErrEmptyMessageContent
+	}
+	if _, err := s.userRepo.GetUserByID(recipientID); err != nil {
+		return nil, err
+	}
 
-	// 至少滿足兩個條件
-	return (hasUpper && hasLower) || (hasUpper && hasDigit) || (hasLower && hasDigit)
+	message := &model.DirectMessage{
+		SenderID:    senderID,
+		RecipientID: recipientID,
+		Content:     s.wordFilter.mask(content),
+	}
+	if err := s.userRepo.SaveDirectMessage(message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// GetDirectMessages 獲取用戶與另一位用戶之間最近的私人訊息，依時間由舊到新排列
+func (s *UserServiceImpl) GetDirectMessages(userID, otherUserID string, limit int) ([]model.DirectMessage, error) {
+	messages, err := s.userRepo.GetDirectMessages(userID, otherUserID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// isValidDisplayName 檢查顯示名稱的長度與字元是否合法
+func isValidDisplayName(displayName string) bool {
+	length := utf8.RuneCountInString(displayName)
+	if length == 0 || length > maxDisplayNameLength {
+		return false
+	}
+
+	for _, char := range displayName {
+		if !unicode.IsPrint(char) {
+			return false
+		}
+	}
+	return true
 }
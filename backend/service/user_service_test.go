@@ -3,6 +3,7 @@ package service
 import (
 	"livechat/backend/model"
 	"livechat/backend/repository"
+	"strings"
 	"testing"
 	"time"
 
@@ -54,6 +55,11 @@ func (m *MockUserRepository) DeleteUser(id string) error {
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) CountUsers() (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockUserRepository) CheckUserCredentials(username, password string) (*model.User, error) {
 	args := m.Called(username, password)
 	if args.Get(0) == nil {
@@ -62,6 +68,50 @@ func (m *MockUserRepository) CheckUserCredentials(username, password string) (*m
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
+func (m *MockUserRepository) CreatePasswordResetToken(token *model.PasswordResetToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetPasswordResetToken(token string) (*model.PasswordResetToken, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PasswordResetToken), args.Error(1)
+}
+
+func (m *MockUserRepository) ConsumePasswordResetToken(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) CreateVerificationToken(token *model.EmailVerificationToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetVerificationToken(token string) (*model.EmailVerificationToken, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.EmailVerificationToken), args.Error(1)
+}
+
+func (m *MockUserRepository) SaveDirectMessage(message *model.DirectMessage) error {
+	args := m.Called(message)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetDirectMessages(userID string, otherUserID string, limit int) ([]model.DirectMessage, error) {
+	args := m.Called(userID, otherUserID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.DirectMessage), args.Error(1)
+}
+
 // 測試創建新的用戶服務
 func TestNewUserService(t *testing.T) {
 	// 安排 (Arrange)
@@ -94,6 +144,45 @@ func TestRegisterUserSuccess(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+// 測試啟用第一個用戶成為管理員時的角色分配
+func TestRegisterUserFirstUserAdmin(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("CreateUser", mock.AnythingOfType("*model.User")).Return(nil)
+	mockRepo.On("CountUsers").Return(int64(0), nil).Once()
+	mockRepo.On("CountUsers").Return(int64(1), nil)
+
+	service := NewUserService(mockRepo, WithFirstUserAdmin(true))
+
+	// 動作 (Act)
+	firstUser, err := service.RegisterUser("firstuser", "first@example.com", "Password123")
+	assert.NoError(t, err, "註冊第一個用戶不應返回錯誤")
+	secondUser, err := service.RegisterUser("seconduser", "second@example.com", "Password123")
+	assert.NoError(t, err, "註冊第二個用戶不應返回錯誤")
+
+	// 斷言 (Assert)
+	assert.Equal(t, "admin", firstUser.Role, "第一個用戶應該成為管理員")
+	assert.Equal(t, "user", secondUser.Role, "後續用戶應該為 user")
+	mockRepo.AssertExpectations(t)
+}
+
+// 測試停用第一個用戶成為管理員時不查詢用戶數
+func TestRegisterUserFirstUserAdminDisabled(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("CreateUser", mock.AnythingOfType("*model.User")).Return(nil)
+
+	service := NewUserService(mockRepo, WithFirstUserAdmin(false))
+
+	// 動作 (Act)
+	user, err := service.RegisterUser("firstuser", "first@example.com", "Password123")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "註冊用戶不應返回錯誤")
+	assert.Equal(t, "user", user.Role, "停用時第一個用戶應該為 user")
+	mockRepo.AssertNotCalled(t, "CountUsers")
+}
+
 // 測試註冊用戶 - 無效用戶名
 func TestRegisterUserInvalidUsername(t *testing.T) {
 	// 安排 (Arrange)
@@ -109,6 +198,34 @@ func TestRegisterUserInvalidUsername(t *testing.T) {
 	assert.Nil(t, user, "用戶應為 nil")
 }
 
+// 測試註冊用戶 - 保留名稱不區分大小寫地被拒絕
+func TestRegisterUserReservedUsername(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("CreateUser", mock.AnythingOfType("*model.User")).Return(nil)
+
+	defaultService := NewUserService(mockRepo)
+	customService := NewUserService(mockRepo, WithReservedUsernames([]string{"moderator"}))
+
+	// 動作 (Act)
+	_, systemErr := defaultService.RegisterUser("System", "system@example.com", "Password123")
+	_, adminErr := defaultService.RegisterUser("ADMIN", "admin@example.com", "Password123")
+	_, guestErr := defaultService.RegisterUser("guest", "guest@example.com", "Password123")
+	normalUser, normalErr := defaultService.RegisterUser("guestuser", "user@example.com", "Password123")
+	_, customErr := customService.RegisterUser("Moderator", "mod@example.com", "Password123")
+	adminUser, adminAllowedErr := customService.RegisterUser("admin", "admin@example.com", "Password123")
+
+	// 斷言 (Assert)
+	assert.Equal(t, ErrReservedUsername, systemErr, "註冊 System 應返回 ErrReservedUsername")
+	assert.Equal(t, ErrReservedUsername, adminErr, "註冊 ADMIN 應返回 ErrReservedUsername")
+	assert.Equal(t, ErrReservedUsername, guestErr, "註冊 guest 應返回 ErrReservedUsername")
+	assert.NoError(t, normalErr, "包含保留名稱的一般用戶名應該可以註冊")
+	assert.Equal(t, "guestuser", normalUser.Username, "用戶名應該匹配")
+	assert.Equal(t, ErrReservedUsername, customErr, "自訂的保留名稱應該被拒絕")
+	assert.NoError(t, adminAllowedErr, "自訂列表取代默認列表後 admin 應該可以註冊")
+	assert.Equal(t, "admin", adminUser.Username, "用戶名應該匹配")
+}
+
 // 測試註冊用戶 - 無效電子郵件
 func TestRegisterUserInvalidEmail(t *testing.T) {
 	// 安排 (Arrange)
@@ -224,3 +341,388 @@ func TestIsAdmin(t *testing.T) {
 	assert.False(t, service.IsAdmin(regularUser), "普通用戶應返回 false")
 	assert.False(t, service.IsAdmin(nil), "nil 用戶應返回 false")
 }
+
+// 測試更新顯示名稱
+func TestUpdateDisplayName(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockUserRepository)
+	user := &model.User{ID: "1", Username: "testuser", Email: "test@example.com"}
+	mockRepo.On("GetUserByID", "1").Return(user, nil)
+	mockRepo.On("UpdateUser", user).Return(nil)
+
+	service := NewUserService(mockRepo)
+
+	// 動作 (Act)
+	updatedUser, err := service.UpdateDisplayName("1", "  小明  ")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "更新有效的顯示名稱不應返回錯誤")
+	assert.Equal(t, "小明", updatedUser.DisplayName, "顯示名稱應該被去除前後空白後更新")
+	assert.Equal(t, "testuser", updatedUser.Username, "登入用戶名不應該改變")
+	mockRepo.AssertExpectations(t)
+}
+
+// 測試更新顯示名稱 - 無效的顯示名稱
+func TestUpdateDisplayNameInvalid(t *testing.T) {
+	testCases := []struct {
+		name        string
+		displayName string
+	}{
+		{name: "空白名稱", displayName: "   "},
+		{name: "過長名稱", displayName: strings.Repeat("長", 31)},
+		{name: "包含控制字元", displayName: "bad\nname"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 安排 (Arrange)
+			mockRepo := new(MockUserRepository)
+			service := NewUserService(mockRepo)
+
+			// 動作 (Act)
+			user, err := service.UpdateDisplayName("1", tc.displayName)
+
+			// 斷言 (Assert)
+			assert.Equal(t, ErrInvalidDisplayName, err, "應該返回無效顯示名稱錯誤")
+			assert.Nil(t, user, "用戶應該為 nil")
+			mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything)
+		})
+	}
+}
+
+// 測試申請密碼重設 - 成功產生有期限的令牌
+func TestRequestPasswordReset(t *testing.T) {
+	// 安排 (Arrange)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	model.SetTimeNow(func() time.Time { return now })
+	defer model.ResetTimeNow()
+
+	mockRepo := new(MockUserRepository)
+	user := &model.User{ID: "1", Username: "testuser", Email: "test@example.com"}
+	mockRepo.On("GetUserByEmail", "test@example.com").Return(user, nil)
+	mockRepo.On("CreatePasswordResetToken", mock.AnythingOfType("*model.PasswordResetToken")).Return(nil)
+
+	service := NewUserService(mockRepo, WithPasswordResetTTL(30*time.Minute))
+
+	// 動作 (Act)
+	resetToken, err := service.RequestPasswordReset("test@example.com")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "申請密碼重設不應返回錯誤")
+	assert.NotEmpty(t, resetToken.Token, "應該產生令牌")
+	assert.Equal(t, "1", resetToken.UserID, "令牌應該屬於該用戶")
+	assert.Equal(t, now.Add(30*time.Minute), resetToken.ExpiresAt, "過期時間應該依設定的有效期限計算")
+	mockRepo.AssertExpectations(t)
+}
+
+// 測試申請密碼重設 - 電子郵件不存在
+func TestRequestPasswordResetUnknownEmail(t *testing.T) {
+	// 安排 (Arrange)
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("GetUserByEmail", "nobody@example.com").Return(nil, repository.ErrUserNotFound)
+
+	service := NewUserService(mockRepo)
+
+	// 動作 (Act)
+	resetToken, err := service.RequestPasswordReset("nobody@example.com")
+
+	// 斷言 (Assert)
+	assert.Equal(t, repository.ErrUserNotFound, err, "應該返回用戶不存在錯誤")
+	assert.Nil(t, resetToken, "令牌應該為 nil")
+	mockRepo.AssertNotCalled(t, "CreatePasswordResetToken", mock.Anything)
+}
+
+// 測試完整的密碼重設流程：申請令牌、重設密碼後可用新密碼登入，且令牌不能再次使用
+func TestResetPasswordFlow(t *testing.T) {
+	// 安排 (Arrange)
+	userRepo := repository.NewUserRepository(repository.NewMockDB())
+	assert.NoError(t, userRepo.CreateUser(&model.User{ID: "1", Username: "testuser", Email: "test@example.com", Password: "OldPassword1"}))
+
+	service := NewUserService(userRepo)
+	resetToken, err := service.RequestPasswordReset("test@example.com")
+	assert.NoError(t, err, "申請密碼重設不應返回錯誤")
+
+	// 動作 (Act)
+	err = service.ResetPassword(resetToken.Token, "NewPassword1")
+
+	// 斷言 (Assert)
+	assert.NoError(t, err, "重設密碼不應返回錯誤")
+	_, err = service.LoginUser("testuser", "NewPassword1")
+	assert.NoError(t, err, "應該可以使用新密碼登入")
+	_, err = service.LoginUser("testuser", "OldPassword1")
+	assert.Equal(t, repository.ErrInvalidCredentials, err, "舊密碼應該失效")
+	err = service.ResetPassword(resetToken.Token, "AnotherPassword1")
+	assert.Equal(t, repository.ErrResetTokenUsed, err, "令牌不應該能再次使用")
+}
+
+// 測試重設密碼 - 各種失敗情況
+func TestResetPasswordFailures(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	usedAt := now.Add(-time.Minute)
+
+	testCases := []struct {
+		name        string
+		resetToken  *model.PasswordResetToken
+		lookupErr   error
+		password    string
+		expectedErr error
+	}{
+		{
+			name:        "令牌不存在",
+			lookupErr:   repository.ErrResetTokenNotFound,
+			password:    "NewPassword1",
+			expectedErr: repository.ErrResetTokenNotFound,
+		},
+		{
+			name:        "令牌已過期",
+			resetToken:  &model.PasswordResetToken{UserID: "1", ExpiresAt: now.Add(-time.Second)},
+			password:    "NewPassword1",
+			expectedErr: ErrResetTokenExpired,
+		},
+		{
+			name:        "令牌已被使用",
+			resetToken:  &model.PasswordResetToken{UserID: "1", ExpiresAt: now.Add(time.Hour), UsedAt: &usedAt},
+			password:    "NewPassword1",
+			expectedErr: repository.ErrResetTokenUsed,
+		},
+		{
+			name:        "新密碼強度不足",
+			resetToken:  &model.PasswordResetToken{UserID: "1", ExpiresAt: now.Add(time.Hour)},
+			password:    "weak",
+			expectedErr: ErrWeakPassword,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 安排 (Arrange)
+			model.SetTimeNow(func() time.Time { return now })
+			defer model.ResetTimeNow()
+
+			mockRepo := new(MockUserRepository)
+			if tc.resetToken != nil {
+				mockRepo.On("GetPasswordResetToken", "token").Return(tc.resetToken, nil)
+			} else {
+				mockRepo.On("GetPasswordResetToken", "token").Return(nil, tc.lookupErr)
+			}
+
+			service := NewUserService(mockRepo)
+
+			// 動作 (Act)
+			err := service.ResetPassword("token", tc.password)
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.expectedErr, err, "應該返回對應的錯誤")
+			mockRepo.AssertNotCalled(t, "ConsumePasswordResetToken", mock.Anything)
+			mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything)
+		})
+	}
+}
+
+// 測試驗證電子郵件：令牌可將用戶標記為已驗證，重複驗證與無效令牌都被拒絕
+func TestVerifyEmail(t *testing.T) {
+	// 安排 (Arrange)
+	userRepo := repository.NewUserRepository(repository.NewMockDB())
+	service := NewUserService(userRepo)
+	user, err := service.RegisterUser("testuser", "test@example.com", "Password123")
+	assert.NoError(t, err, "註冊用戶不應返回錯誤")
+	verificationToken, err := service.GenerateVerificationToken(user.ID)
+	assert.NoError(t, err, "產生驗證令牌不應返回錯誤")
+
+	// 動作 (Act)
+	verifiedUser, verifyErr := service.VerifyEmail(verificationToken.Token)
+	_, doubleErr := service.VerifyEmail(verificationToken.Token)
+	_, invalidErr := service.VerifyEmail("invalid-token")
+	_, regenerateErr := service.GenerateVerificationToken(user.ID)
+
+	// 斷言 (Assert)
+	assert.NoError(t, verifyErr, "驗證電子郵件不應返回錯誤")
+	assert.True(t, verifiedUser.IsVerified, "用戶應該被標記為已驗證")
+	stored, err := userRepo.GetUserByID(user.ID)
+	assert.NoError(t, err, "查詢用戶不應返回錯誤")
+	assert.True(t, stored.IsVerified, "驗證狀態應該被保存")
+	assert.Equal(t, ErrAlreadyVerified, doubleErr, "重複驗證應該返回已驗證錯誤")
+	assert.Equal(t, repository.ErrVerificationTokenNotFound, invalidErr, "無效令牌應該返回令牌不存在錯誤")
+	assert.Equal(t, ErrAlreadyVerified, regenerateErr, "已驗證的用戶不應該再產生驗證令牌")
+}
+
+// 測試更新個人資料 - 成功更新用戶名與電子郵件，只更新其中一項時不與自己衝突
+func TestUpdateProfile(t *testing.T) {
+	// 安排 (Arrange)
+	userRepo := repository.NewUserRepository(repository.NewMockDB())
+	service := NewUserService(userRepo)
+	user, err := service.RegisterUser("alice", "alice@example.com", "Password123")
+	assert.NoError(t, err, "註冊用戶不應返回錯誤")
+
+	// 動作 (Act)
+	updated, err := service.UpdateProfile(user.ID, "alice@example.org", "alice2")
+	assert.NoError(t, err, "更新個人資料不應返回錯誤")
+	sameEmail, sameEmailErr := service.UpdateProfile(user.ID, "alice@example.org", "alice3")
+
+	// 斷言 (Assert)
+	assert.Equal(t, "alice2", updated.Username, "用戶名應該被更新")
+	assert.Equal(t, "alice@example.org", updated.Email, "電子郵件應該被更新")
+	assert.NoError(t, sameEmailErr, "保留原本的電子郵件不應視為衝突")
+	assert.Equal(t, "alice3", sameEmail.Username, "用戶名應該被更新")
+	_, err = service.LoginUser("alice3", "Password123")
+	assert.NoError(t, err, "應該可以使用新的用戶名登入")
+}
+
+// 測試更新個人資料 - 與其他用戶的用戶名或電子郵件衝突
+func TestUpdateProfileConflicts(t *testing.T) {
+	testCases := []struct {
+		name        string
+		email       string
+		username    string
+		expectedErr error
+	}{
+		{name: "用戶名衝突", email: "alice@example.com", username: "bob", expectedErr: repository.ErrUserAlreadyExists},
+		{name: "電子郵件衝突", email: "bob@example.com", username: "alice", expectedErr: repository.ErrEmailAlreadyExists},
+		{name: "無效的用戶名", email: "alice@example.com", username: "al", expectedErr: ErrInvalidUsername},
+		{name: "無效的電子郵件", email: "invalid-email", username: "alice", expectedErr: ErrInvalidEmail},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 安排 (Arrange)
+			userRepo := repository.NewUserRepository(repository.NewMockDB())
+			service := NewUserService(userRepo)
+			alice, err := service.RegisterUser("alice", "alice@example.com", "Password123")
+			assert.NoError(t, err, "註冊用戶不應返回錯誤")
+			_, err = service.RegisterUser("bob", "bob@example.com", "Password123")
+			assert.NoError(t, err, "註冊用戶不應返回錯誤")
+
+			// 動作 (Act)
+			user, err := service.UpdateProfile(alice.ID, tc.email, tc.username)
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.expectedErr, err, "應該返回對應的錯誤")
+			assert.Nil(t, user, "用戶應該為 nil")
+			stored, err := userRepo.GetUserByID(alice.ID)
+			assert.NoError(t, err, "查詢用戶不應返回錯誤")
+			assert.Equal(t, "alice", stored.Username, "用戶名不應該改變")
+			assert.Equal(t, "alice@example.com", stored.Email, "電子郵件不應該改變")
+		})
+	}
+}
+
+// 測試更換密碼：成功後可用新密碼登入，並拒絕錯誤的目前密碼、強度不足或與目前相同的新密碼
+func TestChangePassword(t *testing.T) {
+	testCases := []struct {
+		name        string
+		oldPassword string
+		newPassword string
+		expectedErr error
+	}{
+		{name: "成功更換", oldPassword: "Password123", newPassword: "NewPassword1"},
+		{name: "目前密碼錯誤", oldPassword: "WrongPassword1", newPassword: "NewPassword1", expectedErr: repository.ErrInvalidCredentials},
+		{name: "新密碼強度不足", oldPassword: "Password123", newPassword: "weak", expectedErr: ErrWeakPassword},
+		{name: "新密碼與目前相同", oldPassword: "Password123", newPassword: "Password123", expectedErr: ErrSamePassword},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 安排 (Arrange)
+			service := NewUserService(repository.NewUserRepository(repository.NewMockDB()))
+			user, err := service.RegisterUser("alice", "alice@example.com", "Password123")
+			assert.NoError(t, err, "註冊用戶不應返回錯誤")
+
+			// 動作 (Act)
+			err = service.ChangePassword(user.ID, tc.oldPassword, tc.newPassword)
+
+			// 斷言 (Assert)
+			assert.Equal(t, tc.expectedErr, err, "應該返回對應的錯誤")
+			if tc.expectedErr == nil {
+				_, err = service.LoginUser("alice", tc.newPassword)
+				assert.NoError(t, err, "應該可以使用新密碼登入")
+				_, err = service.LoginUser("alice", tc.oldPassword)
+				assert.Equal(t, repository.ErrInvalidCredentials, err, "舊密碼應該失效")
+			} else {
+				_, err = service.LoginUser("alice", "Password123")
+				assert.NoError(t, err, "失敗時原密碼應該仍然有效")
+			}
+		})
+	}
+}
+
+// 測試刪除帳號：確認密碼後軟刪除，已刪除帳號的用戶名與電子郵件不能再用於註冊
+func TestDeleteUserKeepsCredentialsReserved(t *testing.T) {
+	// 安排 (Arrange)
+	service := NewUserService(repository.NewUserRepository(repository.NewMockDB()))
+	user, err := service.RegisterUser("alice", "alice@example.com", "Password123")
+	assert.NoError(t, err, "註冊用戶不應返回錯誤")
+
+	// 動作 (Act)
+	wrongErr := service.CheckPassword(user.ID, "WrongPassword1")
+	checkErr := service.CheckPassword(user.ID, "Password123")
+	deleteErr := service.DeleteUser(user.ID)
+
+	// 斷言 (Assert)
+	assert.Equal(t, repository.ErrInvalidCredentials, wrongErr, "錯誤的密碼應該返回憑證錯誤")
+	assert.NoError(t, checkErr, "正確的密碼不應返回錯誤")
+	assert.NoError(t, deleteErr, "刪除用戶不應返回錯誤")
+	_, err = service.GetUserByID(user.ID)
+	assert.Equal(t, repository.ErrUserNotFound, err, "已刪除的用戶不應該被查詢到")
+	_, err = service.LoginUser("alice", "Password123")
+	assert.Equal(t, repository.ErrInvalidCredentials, err, "已刪除的用戶不應該能登入")
+	_, err = service.RegisterUser("alice", "other@example.com", "Password123")
+	assert.Equal(t, repository.ErrUserAlreadyExists, err, "已刪除帳號的用戶名不應該釋出")
+	_, err = service.RegisterUser("alice2", "alice@example.com", "Password123")
+	assert.Equal(t, repository.ErrEmailAlreadyExists, err, "已刪除帳號的電子郵件不應該釋出")
+}
+
+// 測試用戶之間的私人訊息：保存後可取得雙向的對話，依時間由舊到新排列
+func TestDirectMessages(t *testing.T) {
+	// 安排 (Arrange)
+	service := NewUserService(repository.NewUserRepository(repository.NewMockDB()))
+	alice, err := service.RegisterUser("alice", "alice@example.com", "Password123")
+	assert.NoError(t, err, "註冊用戶不應返回錯誤")
+	bob, err := service.RegisterUser("bob", "bob@example.com", "Password123")
+	assert.NoError(t, err, "註冊用戶不應返回錯誤")
+	carol, err := service.RegisterUser("carol", "carol@example.com", "Password123")
+	assert.NoError(t, err, "註冊用戶不應返回錯誤")
+
+	// 動作 (Act)
+	_, firstErr := service.SendDirectMessage(alice.ID, bob.ID, "嗨 Bob")
+	_, replyErr := service.SendDirectMessage(bob.ID, alice.ID, "嗨 Alice")
+	_, otherErr := service.SendDirectMessage(alice.ID, carol.ID, "嗨 Carol")
+	_, unknownErr := service.SendDirectMessage(alice.ID, "unknown-user", "有人嗎")
+	_, emptyErr := service.SendDirectMessage(alice.ID, bob.ID, "   ")
+	conversation, err := service.GetDirectMessages(bob.ID, alice.ID, 10)
+
+	// 斷言 (Assert)
+	assert.NoError(t, firstErr, "發送私人訊息不應返回錯誤")
+	assert.NoError(t, replyErr, "回覆私人訊息不應返回錯誤")
+	assert.NoError(t, otherErr, "發送私人訊息不應返回錯誤")
+	assert.Equal(t, repository.ErrUserNotFound, unknownErr, "接收者不存在時應該返回 ErrUserNotFound")
+	assert.Equal(t, ErrEmptyMessageContent, emptyErr, "空白內容應該返回 ErrEmptyMessageContent")
+	assert.NoError(t, err, "獲取對話不應返回錯誤")
+	if assert.Len(t, conversation, 2, "對話應該只包含雙方之間的訊息") {
+		assert.Equal(t, "嗨 Bob", conversation[0].Content, "訊息應該由舊到新排列")
+		assert.Equal(t, alice.ID, conversation[0].SenderID)
+		assert.Equal(t, "嗨 Alice", conversation[1].Content, "最新的訊息應該在最後")
+		assert.Equal(t, alice.ID, conversation[1].RecipientID)
+	}
+}
+
+// 測試設置封鎖詞後，私人訊息在保存前遮蔽封鎖詞
+func TestDirectMessageWordFilter(t *testing.T) {
+	// 安排 (Arrange)
+	service := NewUserService(repository.NewUserRepository(repository.NewMockDB()), WithDirectMessageWordFilter([]string{"darn"}))
+	alice, err := service.RegisterUser("alice", "alice@example.com", "Password123")
+	assert.NoError(t, err, "註冊用戶不應返回錯誤")
+	bob, err := service.RegisterUser("bob", "bob@example.com", "Password123")
+	assert.NoError(t, err, "註冊用戶不應返回錯誤")
+
+	// 動作 (Act)
+	sent, sendErr := service.SendDirectMessage(alice.ID, bob.ID, "darn it Bob")
+	conversation, err := service.GetDirectMessages(bob.ID, alice.ID, 10)
+
+	// 斷言 (Assert)
+	assert.NoError(t, sendErr, "發送私人訊息不應返回錯誤")
+	assert.Equal(t, "**** it Bob", sent.Content, "返回的訊息應該已遮蔽封鎖詞")
+	assert.NoError(t, err, "獲取對話不應返回錯誤")
+	if assert.Len(t, conversation, 1) {
+		assert.Equal(t, "**** it Bob", conversation[0].Content, "資料庫中不應該保存封鎖詞")
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// wordFilter 將封鎖詞替換為等長的星號，比對不分大小寫且只比對完整的詞
+type wordFilter struct {
+	pattern *regexp.Regexp
+}
+
+// newWordFilter 以封鎖詞列表建立過濾器，沒有有效的封鎖詞時返回 nil
+func newWordFilter(words []string) *wordFilter {
+	var quoted []string
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+
+	// 較長的詞優先比對，避免較短的詞先匹配到較長詞的開頭
+	sort.Slice(quoted, func(i, j int) bool {
+		return len(quoted[i]) > len(quoted[j])
+	})
+
+	return &wordFilter{pattern: regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))}
+}
+
+// mask 將文字中完整出現的封鎖詞替換為星號，作為其他詞一部分出現時保持不變，過濾器為 nil 時返回原始文字
+func (f *wordFilter) mask(text string) string {
+	if f == nil {
+		return text
+	}
+
+	matches := f.pattern.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var builder strings.Builder
+	last := 0
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if !isWordBoundary(text, start, end) {
+			continue
+		}
+		builder.WriteString(text[last:start])
+		builder.WriteString(strings.Repeat("*", utf8.RuneCountInString(text[start:end])))
+		last = end
+	}
+	builder.WriteString(text[last:])
+
+	return builder.String()
+}
+
+// filterMessage 遮蔽廣播訊息中的封鎖詞：純文字訊息遮蔽整段內容，JSON 聊天訊息只遮蔽 content 欄位，
+// 帶有其他 type 的 JSON 指令與事件保持原始位元組不變
+func (f *wordFilter) filterMessage(message []byte) []byte {
+	var payload map[string]json.RawMessage
+	if json.Unmarshal(message, &payload) != nil {
+		return []byte(f.mask(string(message)))
+	}
+
+	var messageType string
+	if raw, ok := payload["type"]; ok {
+		if json.Unmarshal(raw, &messageType) != nil {
+			return message
+		}
+	}
+	if messageType != "" && messageType != "message" {
+		return message
+	}
+
+	var content string
+	if json.Unmarshal(payload["content"], &content) != nil {
+		return message
+	}
+	masked := f.mask(content)
+	if masked == content {
+		return message
+	}
+
+	payload["content"], _ = json.Marshal(masked)
+	filtered, err := json.Marshal(payload)
+	if err != nil {
+		return message
+	}
+	return filtered
+}
+
+// isWordBoundary 檢查 text[start:end] 的前後是否不是字母、數字或底線
+func isWordBoundary(text string, start int, end int) bool {
+	if start > 0 {
+		if r, _ := utf8.DecodeLastRuneInString(text[:start]); isWordRune(r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		if r, _ := utf8.DecodeRuneInString(text[end:]); isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
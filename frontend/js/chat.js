@@ -156,6 +156,8 @@ document.addEventListener('DOMContentLoaded', function() {
                 
                 if (message.type === 'system') {
                     addSystemMessage(message.content);
+                } else if (message.type === 'presence') {
+                    addSystemMessage(presenceText(message));
                 } else {
                     addMessage(message.sender || '匿名', message.content, new Date(message.time * 1000));
                 }
@@ -182,6 +184,20 @@ document.addEventListener('DOMContentLoaded', function() {
         };
     }
     
+    // 將加入或離開聊天室的 presence 事件轉換為顯示文字
+    function presenceText(message) {
+        if (message.event === 'join') {
+            return `使用者 ${message.user} 已加入聊天室`;
+        }
+        if (message.reason === 'timeout') {
+            return `使用者 ${message.user} 因連線逾時已離開聊天室`;
+        }
+        if (message.reason === 'error') {
+            return `使用者 ${message.user} 因連線異常已離開聊天室`;
+        }
+        return `使用者 ${message.user} 已離開聊天室`;
+    }
+    
     // 發送加入聊天室訊息
     function sendJoinRoomMessage() {
         if (socket && socket.readyState === WebSocket.OPEN) {
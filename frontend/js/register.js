@@ -1,6 +1,52 @@
 document.addEventListener('DOMContentLoaded', function() {
     const registerForm = document.getElementById('register-form');
     const errorMessage = document.getElementById('error-message');
+    const passwordHint = document.getElementById('password-hint');
+
+    // 密碼規則由伺服器提供，載入前使用默認規則
+    let passwordPolicy = { minLength: 8, requireUpper: false, requireLower: false, requireDigit: false, requireSpecial: false, minCharClasses: 2 };
+    fetch('/api/password-requirements')
+        .then(response => response.ok ? response.json() : null)
+        .then(policy => {
+            if (policy) {
+                passwordPolicy = policy;
+                passwordHint.textContent = describePasswordPolicy(policy);
+            }
+        })
+        .catch(() => {});
+
+    // 將密碼規則轉換為顯示文字
+    function describePasswordPolicy(policy) {
+        const rules = [`長度至少為${policy.minLength}位`];
+        if (policy.requireUpper) rules.push('包含大寫字母');
+        if (policy.requireLower) rules.push('包含小寫字母');
+        if (policy.requireDigit) rules.push('包含數字');
+        if (policy.requireSpecial) rules.push('包含特殊字元');
+        if (policy.minCharClasses > 0) rules.push(`包含大小寫字母、數字至少其${policy.minCharClasses}者`);
+        return '密碼必須' + rules.join('、');
+    }
+
+    // 依密碼規則檢查密碼，不符合時返回錯誤訊息
+    function checkPassword(password) {
+        let hasUpper = false, hasLower = false, hasDigit = false, hasSpecial = false;
+        for (const char of password) {
+            if (char >= 'A' && char <= 'Z') hasUpper = true;
+            else if (char >= 'a' && char <= 'z') hasLower = true;
+            else if (char >= '0' && char <= '9') hasDigit = true;
+            else if (!/[\p{L}\p{N}\s]/u.test(char)) hasSpecial = true;
+        }
+
+        const classes = [hasUpper, hasLower, hasDigit].filter(Boolean).length;
+        if (password.length < passwordPolicy.minLength ||
+            (passwordPolicy.requireUpper && !hasUpper) ||
+            (passwordPolicy.requireLower && !hasLower) ||
+            (passwordPolicy.requireDigit && !hasDigit) ||
+            (passwordPolicy.requireSpecial && !hasSpecial) ||
+            classes < passwordPolicy.minCharClasses) {
+            return describePasswordPolicy(passwordPolicy);
+        }
+        return '';
+    }
 
     registerForm.addEventListener('submit', function(event) {
         event.preventDefault();
@@ -26,24 +72,9 @@ document.addEventListener('DOMContentLoaded', function() {
             return;
         }
 
-        if (password.length < 8) {
-            errorMessage.textContent = '密碼長度至少為 8 位';
-            errorMessage.classList.remove('d-none');
-            return;
-        }
-
-        // 檢查密碼強度
-        let hasUpper = false, hasLower = false, hasDigit = false;
-        for (let i = 0; i < password.length; i++) {
-            const char = password.charAt(i);
-            if (char >= 'A' && char <= 'Z') hasUpper = true;
-            else if (char >= 'a' && char <= 'z') hasLower = true;
-            else if (char >= '0' && char <= '9') hasDigit = true;
-        }
-
-        // 至少滿足兩個條件
-        if (!((hasUpper && hasLower) || (hasUpper && hasDigit) || (hasLower && hasDigit))) {
-            errorMessage.textContent = '密碼必須包含大小寫字母、數字至少其2者';
+        const passwordError = checkPassword(password);
+        if (passwordError) {
+            errorMessage.textContent = passwordError;
             errorMessage.classList.remove('d-none');
             return;
         }
@@ -63,7 +94,7 @@ document.addEventListener('DOMContentLoaded', function() {
         .then(response => {
             if (!response.ok) {
                 return response.json().then(data => {
-                    throw new Error(data.error || '註冊失敗');
+                    throw new Error(data.message || data.error || '註冊失敗');
                 });
             }
             return response.json();
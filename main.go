@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"livechat/backend/handler"
+	"livechat/backend/metrics"
 	"livechat/backend/middleware"
 	"livechat/backend/migrations"
 	"livechat/backend/repository"
@@ -10,10 +12,16 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -40,18 +48,175 @@ func main() {
 
 	// 創建儲存庫
 	clientRepo := repository.NewClientRepository()
-	roomRepo := repository.NewRoomRepository(db)
+	roomRepo := repository.NewRoomRepository(
+		db,
+		repository.WithMessageCompression(getIntEnv("MESSAGE_COMPRESSION_THRESHOLD", 0)),
+	)
 	userRepo := repository.NewUserRepository(db)
+	presenceRepo := repository.NewPresenceRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
 
 	// 創建服務
-	broadcastService := service.NewBroadcastService(clientRepo)
-	roomService := service.NewRoomService(roomRepo)
-	userService := service.NewUserService(userRepo)
+	chatMetrics := metrics.New(prometheus.DefaultRegisterer)
+	blockedWords := strings.Split(os.Getenv("WORD_FILTER"), ",")
+	broadcastService := service.NewBroadcastService(
+		clientRepo,
+		// WORD_FILTER 為以逗號分隔的封鎖詞列表，廣播與保存訊息前會被替換為星號
+		service.WithWordFilter(blockedWords),
+		service.WithMetrics(chatMetrics),
+	)
+	moderatorDeletesMessages := os.Getenv("MODERATOR_DELETE_MESSAGES") == "true"
+	roomService := service.NewRoomService(
+		roomRepo,
+		service.WithMaxRooms(getIntEnv("MAX_ROOMS", 0)),
+		service.WithModeratorMessageDeletion(moderatorDeletesMessages),
+		service.WithMessageWordFilter(blockedWords),
+		// MAX_INVITE_USES 與 MAX_INVITE_TTL 為創建邀請時允許的上限，0 表示不限制
+		service.WithInviteLimits(getIntEnv("MAX_INVITE_USES", 0), getDurationEnv("MAX_INVITE_TTL", 0)),
+		// OWNER_DELETION_POLICY 為 reassign（默認，轉移給聊天室管理員）或 orphan（標記為無擁有者）
+		service.WithOwnerDeletionPolicy(getOwnerDeletionPolicyEnv("OWNER_DELETION_POLICY", service.OwnerDeletionReassign)),
+	)
+	userService := service.NewUserService(
+		userRepo,
+		service.WithFirstUserAdmin(os.Getenv("FIRST_USER_ADMIN") == "true"),
+		service.WithDirectMessageWordFilter(blockedWords),
+		// RESERVED_USERNAMES 為以逗號分隔的保留用戶名列表，未設置時使用默認列表，設為空值可停用檢查
+		service.WithReservedUsernames(getListEnv("RESERVED_USERNAMES", service.DefaultReservedUsernames)),
+		// PASSWORD_RESET_TTL 為密碼重設令牌的有效期限
+		service.WithPasswordResetTTL(getDurationEnv("PASSWORD_RESET_TTL", service.DefaultPasswordResetTTL)),
+		// PASSWORD_MIN_LENGTH、PASSWORD_REQUIRE_* 與 PASSWORD_MIN_CHAR_CLASSES 設置密碼強度規則，未設置的項目使用默認值
+		service.WithPasswordPolicy(service.PasswordPolicy{
+			MinLength:      getIntEnv("PASSWORD_MIN_LENGTH", service.DefaultPasswordPolicy.MinLength),
+			RequireUpper:   getBoolEnv("PASSWORD_REQUIRE_UPPER", service.DefaultPasswordPolicy.RequireUpper),
+			RequireLower:   getBoolEnv("PASSWORD_REQUIRE_LOWER", service.DefaultPasswordPolicy.RequireLower),
+			RequireDigit:   getBoolEnv("PASSWORD_REQUIRE_DIGIT", service.DefaultPasswordPolicy.RequireDigit),
+			RequireSpecial: getBoolEnv("PASSWORD_REQUIRE_SPECIAL", service.DefaultPasswordPolicy.RequireSpecial),
+			MinCharClasses: getIntEnv("PASSWORD_MIN_CHAR_CLASSES", service.DefaultPasswordPolicy.MinCharClasses),
+		}),
+	)
+	presenceService := service.NewPresenceService(clientRepo, presenceRepo)
+	messageExpiryService := service.NewMessageExpiryService(roomRepo)
+	// 輸入中狀態在 TYPING_EXPIRY 內未更新即過期
+	typingService := service.NewTypingService(getDurationEnv("TYPING_EXPIRY", service.DefaultTypingExpiry))
+
+	// 啟動在線人數快照背景任務（PRESENCE_SNAPSHOT_INTERVAL 設為 0 可停用）
+	presenceService.Start(getDurationEnv("PRESENCE_SNAPSHOT_INTERVAL", time.Minute))
+	defer presenceService.Stop()
+
+	// 啟動過期訊息清除背景任務（MESSAGE_PURGE_INTERVAL 設為 0 可停用，過期訊息仍不會被查詢到）
+	messageExpiryService.Start(getDurationEnv("MESSAGE_PURGE_INTERVAL", time.Minute))
+	defer messageExpiryService.Stop()
+
+	// 啟動閒置連接回收背景任務：每隔 WS_REAPER_INTERVAL 關閉超過 WS_IDLE_TIMEOUT 沒有任何訊息或 pong 的連接（設為 0 可停用）
+	// 關閉伺服器時由 CloseAll 停止
+	broadcastService.StartReaper(getDurationEnv("WS_REAPER_INTERVAL", time.Minute), getDurationEnv("WS_IDLE_TIMEOUT", 5*time.Minute))
+
+	// 設置所有分頁端點共用的預設筆數與上限
+	handler.SetPaginationLimits(getIntEnv("PAGINATION_DEFAULT_LIMIT", 0), getIntEnv("PAGINATION_MAX_LIMIT", 0))
+
+	// 設置 REDIS_URL 時會話保存在 Redis 中，多個實例可共用且重啟後不會遺失
+	sessionStore, err := initSessionStore()
+	if err != nil {
+		fmt.Printf("Session store error: %v\n", err)
+		return
+	}
+
+	// 正式環境（GIN_MODE=release）默認只透過 HTTPS 傳送 cookie
+	cookieConfig := handler.CookieConfig{
+		Secure:   getBoolEnv("COOKIE_SECURE", gin.Mode() == gin.ReleaseMode),
+		SameSite: getSameSiteEnv("COOKIE_SAMESITE", http.SameSiteLaxMode),
+	}
+
+	// LOG_FORMAT=json 時以每行一個 JSON 物件的格式輸出日誌
+	var logger handler.Logger = &handler.DefaultLogger{}
+	if os.Getenv("LOG_FORMAT") == "json" {
+		logger = handler.NewJSONLogger(os.Stdout)
+	}
 
 	// 創建處理器
-	wsHandler := handler.NewWebSocketHandler(broadcastService, handler.WithLogger(&handler.DefaultLogger{}))
-	roomHandler := handler.NewRoomHandler(roomService)
-	userHandler := handler.NewUserHandler(userService)
+	wsHandler := handler.NewWebSocketHandler(
+		broadcastService,
+		handler.WithLogger(logger),
+		handler.WithMetrics(chatMetrics),
+		handler.WithRoomService(roomService),
+		handler.WithTypingTracker(typingService),
+		handler.WithDirectMessages(userService),
+		handler.WithRequireMembership(os.Getenv("REQUIRE_ROOM_MEMBERSHIP") == "true"),
+		handler.WithAllowSelfDM(os.Getenv("ALLOW_SELF_DM") != "false"),
+		handler.WithAutoCreateRooms(os.Getenv("AUTO_CREATE_ROOMS") == "true"),
+		handler.WithRejectControlChars(os.Getenv("REJECT_CONTROL_CHARS") != "false"),
+		handler.WithAllowNewlines(os.Getenv("ALLOW_MESSAGE_NEWLINES") != "false"),
+		// WS_ECHO_TO_SENDER=false 時聊天室訊息不再回傳給發送者，適合自行在本地顯示已發送訊息的前端
+		handler.WithEchoToSender(getBoolEnv("WS_ECHO_TO_SENDER", true)),
+		// 設置 GUEST_COOKIE_SECRET 後匿名訪客重新連線會保持相同的名稱
+		handler.WithGuestIdentity([]byte(os.Getenv("GUEST_COOKIE_SECRET")), cookieConfig),
+		// MAX_WS_CONNECTIONS 為 0 時不限制；超過上限的連接最多排隊 WS_CONNECTION_QUEUE_TIMEOUT 後以 503 拒絕
+		handler.WithMaxConnections(getIntEnv("MAX_WS_CONNECTIONS", 0), getDurationEnv("WS_CONNECTION_QUEUE_TIMEOUT", 0)),
+		// WS_READ_LIMIT 為單一訊息的最大位元組數；WS_READ_TIMEOUT 應大於 WS_PING_INTERVAL
+		handler.WithReadLimit(int64(getIntEnv("WS_READ_LIMIT", 4096))),
+		// WS_MAX_CONTENT_LENGTH 為訊息內容的最大字元數，0 表示只受 WS_READ_LIMIT 限制
+		handler.WithMaxContentLength(getIntEnv("WS_MAX_CONTENT_LENGTH", 2000)),
+		handler.WithReadTimeout(getDurationEnv("WS_READ_TIMEOUT", 60*time.Second)),
+		handler.WithPingInterval(getDurationEnv("WS_PING_INTERVAL", 30*time.Second)),
+		// WS_MESSAGE_RATE 為每個連接每秒可發送的訊息數，0 表示不限制；WS_MESSAGE_BURST 為允許的瞬間訊息數
+		handler.WithRateLimit(float64(getIntEnv("WS_MESSAGE_RATE", 0)), getIntEnv("WS_MESSAGE_BURST", 10)),
+		// WS_COMPRESSION=true 時與支援的客戶端協商 permessage-deflate，協商結果可在 /api/admin/connections 查詢
+		handler.WithCompression(getBoolEnv("WS_COMPRESSION", false)),
+		// WS_ALLOWED_ORIGINS 為允許跨來源連接的來源（以逗號分隔，支援 *.example.com），設為 * 允許所有來源；未設置時只允許相同來源
+		handler.WithAllowedOrigins(strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ",")),
+		// WS_JOIN_HISTORY_SIZE 為加入聊天室時發送的最近訊息數，0 表示不發送
+		handler.WithJoinHistorySize(getIntEnv("WS_JOIN_HISTORY_SIZE", 50)),
+	)
+	roomHandlerOpts := []handler.RoomHandlerOption{
+		handler.WithEventBroadcaster(broadcastService),
+		handler.WithMessageBroadcaster(broadcastService),
+		handler.WithContentValidator(wsHandler),
+		handler.WithRoomCloser(wsHandler),
+		handler.WithRoomKicker(wsHandler),
+	}
+	if os.Getenv("AUTO_JOIN_CREATED_ROOM") == "true" {
+		roomHandlerOpts = append(roomHandlerOpts, handler.WithCreatorAutoJoin(wsHandler))
+	}
+	// REQUIRE_VERIFIED_EMAIL=true 時只有已驗證電子郵件的用戶可以創建聊天室
+	if getBoolEnv("REQUIRE_VERIFIED_EMAIL", false) {
+		roomHandlerOpts = append(roomHandlerOpts, handler.WithVerifiedRoomCreation(userService))
+	}
+	roomHandler := handler.NewRoomHandler(roomService, roomHandlerOpts...)
+	userHandlerOpts := []handler.UserHandlerOption{
+		handler.WithCookieConfig(cookieConfig),
+		handler.WithSessionStore(sessionStore),
+		// SESSION_TTL 為登入會話與 cookie 的有效時間，設為 0 時會話不過期
+		handler.WithSessionTTL(getDurationEnv("SESSION_TTL", middleware.DefaultSessionTTL)),
+		handler.WithOwnedRoomsReleaser(roomService),
+		// 尚未串接郵件寄送，密碼重設令牌輸出到日誌
+		handler.WithPasswordResetLogger(logger),
+	}
+	// 在 LOGIN_LOCKOUT_WINDOW 內登入失敗 LOGIN_MAX_ATTEMPTS 次的用戶名或 IP 會被鎖定同樣長的時間，設為 0 可停用
+	if maxAttempts := getIntEnv("LOGIN_MAX_ATTEMPTS", service.DefaultLoginMaxAttempts); maxAttempts > 0 {
+		loginLimiter := service.NewLoginLimiter(maxAttempts, getDurationEnv("LOGIN_LOCKOUT_WINDOW", service.DefaultLoginWindow))
+		userHandlerOpts = append(userHandlerOpts, handler.WithLoginLimiter(loginLimiter))
+	}
+	if getBoolEnv("LOGIN_AUDIT", false) {
+		userHandlerOpts = append(userHandlerOpts, handler.WithLoginAudit(logger))
+	}
+	// 設置 JWT_SECRET 時登入會同時返回 JWT 存取令牌，API 客戶端可用 Authorization: Bearer 驗證
+	var tokenService *service.TokenService
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		tokenService = service.NewTokenService([]byte(secret), getDurationEnv("JWT_TTL", service.DefaultTokenTTL))
+		userHandlerOpts = append(userHandlerOpts, handler.WithTokenService(tokenService))
+	}
+	userHandler := handler.NewUserHandler(userService, userHandlerOpts...)
+	adminHandler := handler.NewAdminHandler(userService, presenceService, handler.WithClientManager(broadcastService))
+	// 管理員可透過 /api/keys 簽發 API 金鑰，外部服務以 X-API-Key 標頭代表綁定的用戶存取 API
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, userService)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService, userService)
+	directMessageHandler := handler.NewDirectMessageHandler(userService)
+	presenceHandler := handler.NewPresenceHandler(presenceService)
+	typingHandler := handler.NewTypingHandler(typingService)
+	capabilities := wsHandler.Capabilities()
+	capabilities.ModeratorMessageDeletion = moderatorDeletesMessages
+	capabilitiesHandler := handler.NewCapabilitiesHandler(capabilities)
+	healthHandler := handler.NewHealthHandler(db)
 
 	// 創建 Gin 路由
 	router := gin.Default()
@@ -60,7 +225,11 @@ func main() {
 	router.LoadHTMLGlob("frontend/*.html")
 
 	// 設置會話中間件
-	router.Use(middleware.SessionMiddleware(userService))
+	router.Use(middleware.SessionMiddleware(sessionStore))
+	if tokenService != nil {
+		router.Use(middleware.TokenAuthMiddleware(tokenService))
+	}
+	router.Use(middleware.APIKeyMiddleware(apiKeyService))
 
 	// 註冊用戶相關路由
 	userHandler.RegisterRoutes(router)
@@ -68,6 +237,28 @@ func main() {
 	// 註冊聊天室相關路由
 	roomHandler.RegisterRoutes(router)
 
+	// 註冊私人訊息相關路由
+	directMessageHandler.RegisterRoutes(router)
+
+	// 註冊管理員相關路由
+	adminHandler.RegisterRoutes(router)
+	apiKeyHandler.RegisterRoutes(router)
+
+	// 註冊在線狀態相關路由
+	presenceHandler.RegisterRoutes(router)
+
+	// 註冊輸入中狀態相關路由
+	typingHandler.RegisterRoutes(router)
+
+	// 註冊伺服器功能查詢路由
+	capabilitiesHandler.RegisterRoutes(router)
+
+	// 註冊存活與就緒檢查路由
+	healthHandler.RegisterRoutes(router)
+
+	// Prometheus 指標
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	// WebSocket 路由
 	router.GET("/ws", func(c *gin.Context) {
 		wsHandler.HandleConnection(c.Writer, c.Request)
@@ -108,9 +299,43 @@ func main() {
 	<-stopChan
 
 	fmt.Println("Shutting down server...")
+
+	// 停止接受新請求，並等待處理中的 HTTP 請求完成
+	ctx, cancel := context.WithTimeout(context.Background(), getDurationEnv("SHUTDOWN_TIMEOUT", 10*time.Second))
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Printf("HTTP server shutdown error: %v\n", err)
+	}
+
+	// WebSocket 連接已脫離 HTTP 伺服器管理，需另外通知並關閉
+	closed := broadcastService.CloseAll()
+	fmt.Printf("Closed %d WebSocket connections\n", closed)
+
 	fmt.Println("Server gracefully stopped")
 }
 
+// 初始化會話存儲，未設置 REDIS_URL 時使用記憶體存儲
+// SESSION_REDIS_TTL 為會話在 Redis 中的最長存活時間，0 表示只依會話本身的過期時間
+func initSessionStore() (middleware.SessionStore, error) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		return middleware.NewMemorySessionStore(), nil
+	}
+
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(options)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
+
+	fmt.Println("Using Redis session store")
+	return middleware.NewRedisSessionStore(client, getDurationEnv("SESSION_REDIS_TTL", 0)), nil
+}
+
 // 初始化數據庫
 func initDB() (*gorm.DB, error) {
 	// 從環境變數獲取資料庫連線字串
@@ -130,3 +355,91 @@ func initDB() (*gorm.DB, error) {
 	fmt.Println("Successfully connected to PostgreSQL database")
 	return db, nil
 }
+
+// 從環境變數讀取時間間隔，未設置或格式錯誤時使用預設值
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		fmt.Printf("Warning: invalid %s %q, using default %v\n", key, value, defaultValue)
+		return defaultValue
+	}
+
+	return duration
+}
+
+// 從環境變數讀取整數，未設置或格式錯誤時使用預設值
+func getIntEnv(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		fmt.Printf("Warning: invalid %s %q, using default %d\n", key, value, defaultValue)
+		return defaultValue
+	}
+
+	return number
+}
+
+// 從環境變數讀取布林值，未設置或格式錯誤時使用預設值
+func getBoolEnv(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	result, err := strconv.ParseBool(value)
+	if err != nil {
+		fmt.Printf("Warning: invalid %s %q, using default %v\n", key, value, defaultValue)
+		return defaultValue
+	}
+
+	return result
+}
+
+// 從環境變數讀取以逗號分隔的列表，未設置時使用預設值
+func getListEnv(key string, defaultValue []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultValue
+	}
+
+	return strings.Split(value, ",")
+}
+
+// 從環境變數讀取聊天室創建者帳號刪除時的處理策略，未設置或格式錯誤時使用預設值
+func getOwnerDeletionPolicyEnv(key string, defaultValue service.OwnerDeletionPolicy) service.OwnerDeletionPolicy {
+	switch value := service.OwnerDeletionPolicy(os.Getenv(key)); value {
+	case "":
+		return defaultValue
+	case service.OwnerDeletionReassign, service.OwnerDeletionOrphan:
+		return value
+	default:
+		fmt.Printf("Warning: invalid %s %q, using default %s\n", key, value, defaultValue)
+		return defaultValue
+	}
+}
+
+// 從環境變數讀取 cookie 的 SameSite 屬性（lax、strict、none），未設置或格式錯誤時使用預設值
+func getSameSiteEnv(key string, defaultValue http.SameSite) http.SameSite {
+	switch value := os.Getenv(key); value {
+	case "":
+		return defaultValue
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		fmt.Printf("Warning: invalid %s %q, using default\n", key, value)
+		return defaultValue
+	}
+}